@@ -8,13 +8,20 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"dmh/api/internal/config"
 	"dmh/api/internal/handler"
+	"dmh/api/internal/logic/order"
+	"dmh/api/internal/middleware"
+	"dmh/api/internal/service"
 	"dmh/api/internal/svc"
+	"dmh/common/metrics"
+	"dmh/common/syncadapter"
 
 	mysqlDriver "github.com/go-sql-driver/mysql"
 	"github.com/zeromicro/go-zero/core/conf"
+	"github.com/zeromicro/go-zero/core/logx"
 	"github.com/zeromicro/go-zero/rest"
 )
 
@@ -34,6 +41,81 @@ func main() {
 
 	ctx := svc.NewServiceContext(c)
 
+	// 微信提现打款Worker
+	if c.Payout.Enabled && ctx.DB != nil {
+		if err := ctx.WeChatPayService.CheckTransferConfig(); err != nil {
+			logx.Must(fmt.Errorf("微信提现打款配置无效: %w", err))
+		}
+		payoutWorker := service.NewPayoutWorker(ctx.DB, ctx.WeChatPayService, ctx.Outbox, ctx.Webhooks, service.PayoutWorkerConfig{
+			Interval:     time.Duration(c.Payout.IntervalSeconds) * time.Second,
+			BatchSize:    c.Payout.BatchSize,
+			MaxAttempts:  c.Payout.MaxAttempts,
+			RetryBackoff: time.Duration(c.Payout.RetryBackoffSeconds) * time.Second,
+		})
+		go payoutWorker.Start()
+		defer payoutWorker.Stop()
+	}
+
+	// 超时未支付订单关闭Worker
+	if c.OrderExpiry.Enabled && ctx.DB != nil {
+		seats := service.NewCampaignSeatService(ctx.DB, ctx.SeatCounter)
+		expiryWorker := service.NewOrderExpiryWorker(ctx.DB, ctx.WeChatPayService, seats, service.OrderExpiryWorkerConfig{
+			Interval:  time.Duration(c.OrderExpiry.IntervalSeconds) * time.Second,
+			Window:    time.Duration(c.OrderExpiry.WindowMinutes) * time.Minute,
+			BatchSize: c.OrderExpiry.BatchSize,
+		})
+		go expiryWorker.Start()
+		defer expiryWorker.Stop()
+	}
+
+	// Webhook 投递Worker
+	if ctx.Webhooks != nil && ctx.DB != nil {
+		webhookWorker := service.NewWebhookWorker(ctx.DB, service.WebhookWorkerConfig{
+			Interval:            time.Duration(c.Webhook.IntervalSeconds) * time.Second,
+			BatchSize:           c.Webhook.BatchSize,
+			MaxAttempts:         c.Webhook.MaxAttempts,
+			RetryBackoff:        time.Duration(c.Webhook.RetryBackoffSeconds) * time.Second,
+			Timeout:             time.Duration(c.Webhook.TimeoutSeconds) * time.Second,
+			AllowPrivateNetwork: c.Webhook.AllowPrivateNetwork,
+		})
+		go webhookWorker.Start()
+		defer webhookWorker.Stop()
+	}
+
+	// 外部数据库同步Worker
+	if ctx.SyncWorker != nil {
+		defer ctx.SyncAdapter.Close()
+		go ctx.SyncWorker.Start()
+		defer ctx.SyncWorker.Stop()
+	}
+
+	// 发件箱中继，将事务内记录的同步事件投递到同步队列
+	if ctx.Outbox != nil && ctx.SyncQueue != nil && ctx.DB != nil {
+		outboxRelay := syncadapter.NewOutboxRelay(ctx.DB, ctx.SyncQueue, syncadapter.OutboxRelayConfig{
+			Interval:     time.Duration(c.ExternalSync.OutboxIntervalSeconds) * time.Second,
+			BatchSize:    c.ExternalSync.OutboxBatchSize,
+			RetryBackoff: time.Duration(c.ExternalSync.RetryBackoffSeconds) * time.Second,
+		})
+		go outboxRelay.Start()
+		defer outboxRelay.Stop()
+	}
+
+	// 外部变更导入，将客户系统的退款、核销与会员变更按业务规则应用到本系统
+	if c.ExternalSync.Inbound.Enabled && ctx.SyncAdapter != nil {
+		importer, err := syncadapter.NewInboundImporter(ctx.SyncAdapter, ctx.DB, order.NewInboundChangeApplier(ctx), syncadapter.InboundImporterConfig{
+			Interval:       time.Duration(c.ExternalSync.Inbound.IntervalSeconds) * time.Second,
+			BatchSize:      c.ExternalSync.Inbound.BatchSize,
+			ConflictPolicy: c.ExternalSync.Inbound.ConflictPolicy,
+			Mapping:        c.ExternalSync.Inbound.Mapping,
+		})
+		if err != nil {
+			logx.Errorf("外部变更导入配置无效，导入未启动: %v", err)
+		} else {
+			go importer.Start()
+			defer importer.Stop()
+		}
+	}
+
 	// 在注册其他路由之前，先注册静态文件路由
 	server.AddRoute(rest.Route{
 		Method: http.MethodGet,
@@ -63,12 +145,48 @@ func main() {
 		}),
 	})
 
+	// Prometheus 指标端点
+	if c.Metrics.Enabled {
+		registerMetrics(ctx)
+		server.AddRoute(rest.Route{
+			Method:  http.MethodGet,
+			Path:    c.Metrics.Path,
+			Handler: metrics.Handler(c.Metrics.Token),
+		})
+	}
+
 	handler.RegisterHandlers(server, ctx)
 
+	// 请求耗时与状态码按路由模板统计，需在全部路由注册之后设置
+	if c.Metrics.Enabled {
+		server.Use(middleware.NewPerformanceMiddleware().WithRoutes(server.Routes()).Handle)
+	}
+
 	fmt.Printf("Starting server at %s:%d...\n", c.Host, c.Port)
 	server.Start()
 }
 
+// registerMetrics 导出数据库连接池与同步队列的状态
+func registerMetrics(ctx *svc.ServiceContext) {
+	if ctx.DB != nil {
+		if sqlDB, err := ctx.DB.DB(); err == nil {
+			logIfErr(metrics.RegisterDB(sqlDB, "dmh"))
+		}
+	}
+	if ctx.SyncAdapter != nil {
+		logIfErr(metrics.RegisterDB(ctx.SyncAdapter.DB(), "external"))
+	}
+	if ctx.SyncQueue != nil {
+		logIfErr(metrics.RegisterSyncQueue(ctx.SyncQueue.Length, ctx.SyncQueue.DeadLetterLength))
+	}
+}
+
+func logIfErr(err error) {
+	if err != nil {
+		logx.Errorf("注册指标失败: %v", err)
+	}
+}
+
 func applyEnvOverrides(c *config.Config) {
 	if v := strings.TrimSpace(os.Getenv("APP_HOST")); v != "" {
 		c.Host = v
@@ -81,6 +199,18 @@ func applyEnvOverrides(c *config.Config) {
 	if v := strings.TrimSpace(os.Getenv("JWT_SECRET")); v != "" {
 		c.Auth.AccessSecret = v
 	}
+	if v := strings.TrimSpace(os.Getenv("METRICS_TOKEN")); v != "" {
+		c.Metrics.Token = v
+	}
+	if v := strings.TrimSpace(os.Getenv("VERIFICATION_SECRET")); v != "" {
+		setVerificationKey(c, c.Verification.ActiveKeyId, v)
+	}
+	if v := strings.TrimSpace(os.Getenv("VERIFICATION_LEGACY_SECRET")); v != "" {
+		c.Verification.LegacySecret = v
+	}
+	if v := strings.TrimSpace(os.Getenv("VERIFICATION_SNAPSHOT_KEY")); v != "" {
+		c.Verification.SnapshotSigningKey = v
+	}
 
 	dbHost := strings.TrimSpace(os.Getenv("DB_HOST"))
 	dbPort := strings.TrimSpace(os.Getenv("DB_PORT"))
@@ -119,3 +249,14 @@ func applyEnvOverrides(c *config.Config) {
 
 	c.Mysql.DataSource = cfg.FormatDSN()
 }
+
+// setVerificationKey 设置指定ID的核销码密钥，不存在时追加
+func setVerificationKey(c *config.Config, id, secret string) {
+	for i := range c.Verification.Keys {
+		if c.Verification.Keys[i].Id == id {
+			c.Verification.Keys[i].Secret = secret
+			return
+		}
+	}
+	c.Verification.Keys = append(c.Verification.Keys, config.VerificationKey{Id: id, Secret: secret})
+}
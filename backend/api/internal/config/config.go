@@ -1,6 +1,10 @@
 package config
 
-import "github.com/zeromicro/go-zero/rest"
+import (
+	"dmh/common/syncadapter"
+
+	"github.com/zeromicro/go-zero/rest"
+)
 
 type Config struct {
 	rest.RestConf
@@ -20,6 +24,19 @@ type Config struct {
 		AccessExpire int64
 	}
 
+	// Verification 核销码签名，按密钥ID轮换
+	Verification struct {
+		ActiveKeyId        string            `json:",default=k1"`            // 签发新核销码使用的密钥
+		Keys               []VerificationKey `json:",optional"`              // 可校验的密钥，轮换后保留旧密钥直到旧码过期
+		TTLHours           int               `json:",default=168"`           // 活动结束后核销码的有效时长
+		LegacySecret       string            `json:",optional"`              // 旧格式 MD5 核销码的密钥
+		LegacyUntil        string            `json:",optional"`              // 旧格式核销码的截止日期（2006-01-02），为空时不再接受
+		ShortCodeLength    int               `json:",default=6,range=[6:8]"` // 手动输入的核销短码长度
+		SnapshotTTLMinutes int               `json:",default=720"`           // 离线核销快照的有效时长
+		SnapshotKeyId      string            `json:",default=s1"`            // 离线核销快照签名密钥ID
+		SnapshotSigningKey string            `json:",optional"`              // 快照签名的 Ed25519 私钥（base64），设备只配置对应公钥
+	}
+
 	RateLimit struct {
 		PosterGenerate struct {
 			MaxRequests    int    `json:",default=5"`
@@ -31,26 +48,88 @@ type Config struct {
 			WindowDuration int    `json:",default=60"`
 			Storage        string `json:",default=memory"`
 		}
+		// VerificationCode 短码核销失败次数限制，超过后锁定并记录安全事件
+		VerificationCode struct {
+			MaxRequests    int    `json:",default=10"`
+			WindowDuration int    `json:",default=600"`
+			Storage        string `json:",default=memory"`
+		}
 	}
 
 	WeChatPay struct {
-		AppID           string `json:",optional"`
-		MchID           string `json:",optional"`
-		APIKey          string `json:",optional"`
-		APIKeyV3        string `json:",optional"`
-		APIClientCert   string `json:",optional"`
-		APIClientKey    string `json:",optional"`
-		NotifyURL       string `json:",optional"`
-		RefundNotifyURL string `json:",optional"`
-		Sandbox         bool   `json:",default=true"`
-		CacheTTL        int    `json:",default=7200"`
-		MockEnabled     bool   `json:",default=true"`
-		UnifiedOrderURL string `json:",optional"`
-		HTTPTimeoutMs   int    `json:",default=5000"`
+		AppID            string `json:",optional"`
+		MchID            string `json:",optional"`
+		APIVersion       string `json:",default=v2,options=v2|v3"`
+		APIKey           string `json:",optional"`
+		APIKeyV3         string `json:",optional"`
+		MchSerialNo      string `json:",optional"`
+		APIClientCert    string `json:",optional"`
+		APIClientKey     string `json:",optional"`
+		NotifyURL        string `json:",optional"`
+		RefundNotifyURL  string `json:",optional"`
+		Sandbox          bool   `json:",default=true"`
+		CacheTTL         int    `json:",default=7200"`
+		MockEnabled      bool   `json:",default=true"`
+		UnifiedOrderURL  string `json:",optional"`
+		RefundURL        string `json:",optional"`
+		TransferURL      string `json:",optional"`
+		TransferQueryURL string `json:",optional"`
+		V3BaseURL        string `json:",optional"`
+		HTTPTimeoutMs    int    `json:",default=5000"`
+	}
+
+	Payout struct {
+		Enabled             bool `json:",default=false"`
+		IntervalSeconds     int  `json:",default=30"`
+		BatchSize           int  `json:",default=20"`
+		MaxAttempts         int  `json:",default=5"`
+		RetryBackoffSeconds int  `json:",default=60"`
+	}
+
+	OrderExpiry struct {
+		Enabled         bool `json:",default=true"`
+		IntervalSeconds int  `json:",default=60"`
+		WindowMinutes   int  `json:",default=30"`
+		BatchSize       int  `json:",default=100"`
+	}
+
+	Webhook struct {
+		Enabled             bool `json:",default=true"`
+		IntervalSeconds     int  `json:",default=5"`
+		BatchSize           int  `json:",default=50"`
+		MaxAttempts         int  `json:",default=8"`
+		RetryBackoffSeconds int  `json:",default=30"`
+		TimeoutSeconds      int  `json:",default=10"`
+		AllowPrivateNetwork bool `json:",default=false"` // 允许回调内网地址，仅限纯内网部署
+	}
+
+	// Metrics Prometheus 指标端点，与 API 同端口
+	Metrics struct {
+		Enabled bool   `json:",default=true"`
+		Path    string `json:",default=/metrics"`
+		Token   string `json:",optional"` // 设置后抓取请求需携带 Authorization: Bearer <Token>
 	}
 
 	ExternalSync struct {
-		Enabled  bool
+		Enabled               bool
+		QueueKey              string                    `json:",default=dmh:sync:tasks"`
+		QueueBackend          string                    `json:",default=stream,options=stream|list|memory"` // 同步队列实现：Redis Streams、Redis 列表（旧版）或进程内队列（仅单机）
+		Consumers             int                       `json:",default=4"`                                 // 同步Worker并发消费者数
+		ClaimIdleSeconds      int                       `json:",default=300"`                               // 任务取出后超过该时间未确认，由其他消费者接管（仅 stream）
+		MaxAttempts           int                       `json:",default=5"`
+		RetryBackoffSeconds   int                       `json:",default=10"`
+		MaxBackoffSeconds     int                       `json:",default=600"`
+		OutboxIntervalSeconds int                       `json:",default=2"`                        // 发件箱中继轮询间隔
+		OutboxBatchSize       int                       `json:",default=100"`                      // 发件箱中继每轮投递的事件数
+		MappingSource         string                    `json:",default=config,options=config|db"` // 字段映射来源：配置文件或 sync_field_mappings 表
+		Mapping               syncadapter.MappingConfig `json:",optional"`
+		Inbound               struct {
+			Enabled         bool                       `json:",optional"`                                              // 从外部变更表导入订单与会员变更
+			IntervalSeconds int                        `json:",default=30"`                                            // 轮询间隔
+			BatchSize       int                        `json:",default=100"`                                           // 每轮读取的变更数
+			ConflictPolicy  string                     `json:",default=latest_wins,options=latest_wins|external_wins"` // 双方都修改过时以谁为准
+			Mapping         syncadapter.InboundMapping `json:",optional"`                                              // 变更表的列映射，未配置时读取 external_changes
+		} `json:",optional"`
 		Database struct {
 			Type     string
 			Host     string
@@ -63,3 +142,9 @@ type Config struct {
 		}
 	}
 }
+
+// VerificationKey 核销码签名密钥
+type VerificationKey struct {
+	Id     string
+	Secret string
+}
@@ -0,0 +1,31 @@
+// Code scaffolded by goctl. Safe to edit.
+// goctl 1.9.2
+
+package brand
+
+import (
+	"net/http"
+
+	"dmh/api/internal/logic/brand"
+	"dmh/api/internal/svc"
+	"dmh/api/internal/types"
+	"github.com/zeromicro/go-zero/rest/httpx"
+)
+
+func GetVerificationScopesHandler(svcCtx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req types.VerificationScopeListReq
+		if err := httpx.Parse(r, &req); err != nil {
+			httpx.ErrorCtx(r.Context(), w, err)
+			return
+		}
+
+		l := brand.NewGetVerificationScopesLogic(r.Context(), svcCtx)
+		resp, err := l.GetVerificationScopes(&req)
+		if err != nil {
+			httpx.ErrorCtx(r.Context(), w, err)
+		} else {
+			httpx.OkJsonCtx(r.Context(), w, resp)
+		}
+	}
+}
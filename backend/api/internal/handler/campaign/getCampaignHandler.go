@@ -10,10 +10,12 @@ import (
 	"strconv"
 	"strings"
 
+	"dmh/api/internal/service"
 	"dmh/api/internal/svc"
 	"dmh/api/internal/types"
 	"dmh/model"
 
+	"github.com/zeromicro/go-zero/core/logx"
 	"github.com/zeromicro/go-zero/rest/httpx"
 )
 
@@ -68,9 +70,18 @@ func GetCampaignHandler(svcCtx *svc.ServiceContext) http.HandlerFunc {
 				}
 				return ""
 			}(),
-			PosterTemplateId: campaign.PosterTemplateId,
-			CreatedAt:        campaign.CreatedAt.Format("2006-01-02T15:04:05"),
-			UpdatedAt:        campaign.UpdatedAt.Format("2006-01-02T15:04:05"),
+			PosterTemplateId:    campaign.PosterTemplateId,
+			TotalQuota:          campaign.TotalQuota,
+			DailyQuota:          campaign.DailyQuota,
+			RedemptionsPerOrder: campaign.RedemptionsPerOrder,
+			Venue:               campaign.Venue,
+			CreatedAt:           campaign.CreatedAt.Format("2006-01-02T15:04:05"),
+			UpdatedAt:           campaign.UpdatedAt.Format("2006-01-02T15:04:05"),
+		}
+		if remaining, err := service.NewCampaignSeatService(svcCtx.DB, svcCtx.SeatCounter).Remaining(&campaign); err == nil {
+			resp.RemainingSeats = remaining
+		} else {
+			logx.WithContext(r.Context()).Errorf("统计活动剩余名额失败: campaignId=%d, err=%v", campaign.Id, err)
 		}
 
 		httpx.OkJsonCtx(r.Context(), w, resp)
@@ -0,0 +1,31 @@
+// Code scaffolded by goctl. Safe to edit.
+// goctl 1.9.2
+
+package order
+
+import (
+	"net/http"
+
+	"dmh/api/internal/logic/order"
+	"dmh/api/internal/svc"
+	"dmh/api/internal/types"
+	"github.com/zeromicro/go-zero/rest/httpx"
+)
+
+func GetOrderPaymentQrcodeHandler(svcCtx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req types.OrderPaymentQrcodeReq
+		if err := httpx.Parse(r, &req); err != nil {
+			httpx.ErrorCtx(r.Context(), w, err)
+			return
+		}
+
+		l := order.NewGetOrderPaymentQrcodeLogic(r.Context(), svcCtx)
+		resp, err := l.GetOrderPaymentQrcode(&req)
+		if err != nil {
+			httpx.ErrorCtx(r.Context(), w, err)
+		} else {
+			httpx.OkJsonCtx(r.Context(), w, resp)
+		}
+	}
+}
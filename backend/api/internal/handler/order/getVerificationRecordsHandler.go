@@ -8,13 +8,20 @@ import (
 
 	"dmh/api/internal/logic/order"
 	"dmh/api/internal/svc"
+	"dmh/api/internal/types"
 	"github.com/zeromicro/go-zero/rest/httpx"
 )
 
 func GetVerificationRecordsHandler(svcCtx *svc.ServiceContext) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		var req types.VerificationRecordsReq
+		if err := httpx.Parse(r, &req); err != nil {
+			httpx.ErrorCtx(r.Context(), w, err)
+			return
+		}
+
 		l := order.NewGetVerificationRecordsLogic(r.Context(), svcCtx)
-		resp, err := l.GetVerificationRecords()
+		resp, err := l.GetVerificationRecords(&req)
 		if err != nil {
 			httpx.ErrorCtx(r.Context(), w, err)
 		} else {
@@ -13,6 +13,7 @@ import (
 
 	"dmh/api/internal/svc"
 	"dmh/api/internal/types"
+	"dmh/common/wechatpay"
 	"dmh/model"
 
 	"github.com/stretchr/testify/assert"
@@ -23,7 +24,7 @@ func setupOrderHandlerTestDB(t *testing.T) *gorm.DB {
 	t.Helper()
 	db := testutil.SetupGormTestDB(t)
 
-	err := db.AutoMigrate(&model.Order{}, &model.Campaign{}, &model.Brand{})
+	err := db.AutoMigrate(&model.Order{}, &model.Campaign{}, &model.Brand{}, &model.PaymentNotification{})
 	if err != nil {
 		t.Fatalf("Failed to migrate database: %v", err)
 	}
@@ -42,6 +43,11 @@ func TestOrderHandlersConstruct(t *testing.T) {
 	assert.NotNil(t, UnverifyOrderHandler(nil))
 	assert.NotNil(t, GetVerificationRecordsHandler(nil))
 	assert.NotNil(t, PaymentCallbackHandler(nil))
+	assert.NotNil(t, RefundOrderHandler(nil))
+	assert.NotNil(t, RefundNotifyHandler(nil))
+	assert.NotNil(t, GetOrderPaymentQrcodeHandler(nil))
+	assert.NotNil(t, ReconcilePaymentsHandler(nil))
+	assert.NotNil(t, GetPaymentReconciliationHandler(nil))
 }
 
 func TestGetOrdersHandler_Success(t *testing.T) {
@@ -435,7 +441,7 @@ func TestUnverifyOrderHandler_Success(t *testing.T) {
 	assert.NotEqual(t, http.StatusInternalServerError, resp.Code)
 }
 
-func TestPaymentCallbackHandler_Success(t *testing.T) {
+func TestPaymentCallbackHandler_RejectsUnsignedNotify(t *testing.T) {
 	db := setupOrderHandlerTestDB(t)
 
 	brand := &model.Brand{Name: "Test Brand", Status: "active"}
@@ -453,22 +459,30 @@ func TestPaymentCallbackHandler_Success(t *testing.T) {
 		CampaignId: campaign.Id,
 		Phone:      testutil.GenUniquePhone(),
 		Amount:     100.00,
-		PayStatus:  "pending",
-		Status:     "active",
-		TradeNo:    "TEST123456",
+		PayStatus:  "unpaid",
+		Status:     "pending",
 	}
 	db.Create(order)
 
-	svcCtx := &svc.ServiceContext{DB: db}
+	payService := wechatpay.NewService(&wechatpay.Config{AppID: "wx-app", MchID: "mch-1", APIKey: "key123"})
+	svcCtx := &svc.ServiceContext{DB: db, WeChatPayService: payService}
 	handler := PaymentCallbackHandler(svcCtx)
 
-	req := httptest.NewRequest(http.MethodPost, "/api/v1/orders/payment-callback", strings.NewReader(`{"outTradeNo":"TEST123456","transactionId":"TXN123"}`))
-	req.Header.Set("Content-Type", "application/json")
+	body := fmt.Sprintf("<xml><return_code>SUCCESS</return_code><result_code>SUCCESS</result_code>"+
+		"<out_trade_no>%d</out_trade_no><transaction_id>TXN123</transaction_id><total_fee>10000</total_fee>"+
+		"<sign>FORGED</sign></xml>", order.Id)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/orders/payment/callback", strings.NewReader(body))
+	req.Header.Set("Content-Type", "text/xml")
 	resp := httptest.NewRecorder()
 
 	handler(resp, req)
 
-	assert.NotEqual(t, http.StatusInternalServerError, resp.Code)
+	assert.NotEqual(t, http.StatusOK, resp.Code)
+	assert.Contains(t, resp.Body.String(), "<return_code>FAIL</return_code>")
+
+	var updated model.Order
+	db.First(&updated, order.Id)
+	assert.Equal(t, "unpaid", updated.PayStatus)
 }
 
 func TestScanOrderHandler_Success(t *testing.T) {
@@ -4,28 +4,46 @@
 package order
 
 import (
+	"io"
 	"net/http"
 
 	"dmh/api/internal/logic/order"
 	"dmh/api/internal/svc"
-	"dmh/api/internal/types"
-	"github.com/zeromicro/go-zero/rest/httpx"
+	"dmh/common/wechatpay"
 )
 
+// PaymentCallbackHandler 微信支付结果通知，v2 按 XML 应答，v3 按 JSON 应答
 func PaymentCallbackHandler(svcCtx *svc.ServiceContext) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		var req types.PaymentCallbackReq
-		if err := httpx.Parse(r, &req); err != nil {
-			httpx.ErrorCtx(r.Context(), w, err)
+		body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+		if err != nil {
+			writePaymentNotifyResponse(w, body, http.StatusBadRequest, "读取请求失败")
 			return
 		}
 
 		l := order.NewPaymentCallbackLogic(r.Context(), svcCtx)
-		err := l.PaymentCallback(&req)
-		if err != nil {
-			httpx.ErrorCtx(r.Context(), w, err)
+		if err := l.PaymentNotify(r.Header, body); err != nil {
+			writePaymentNotifyResponse(w, body, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		writePaymentNotifyResponse(w, body, http.StatusOK, "")
+	}
+}
+
+func writePaymentNotifyResponse(w http.ResponseWriter, body []byte, status int, failMsg string) {
+	if order.IsXMLPaymentNotify(body) {
+		w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+		w.WriteHeader(status)
+		if status == http.StatusOK {
+			_, _ = w.Write([]byte(wechatpay.BuildNotifyResponse()))
 		} else {
-			httpx.Ok(w)
+			_, _ = w.Write([]byte(wechatpay.BuildNotifyFailResponse(failMsg)))
 		}
+		return
 	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_, _ = w.Write(wechatpay.BuildNotifyV3Response(status == http.StatusOK, failMsg))
 }
@@ -0,0 +1,28 @@
+package order
+
+import (
+	"io"
+	"net/http"
+
+	"dmh/api/internal/logic/order"
+	"dmh/api/internal/svc"
+)
+
+// RefundNotifyHandler 微信退款结果通知，v2 按 XML 应答，v3 按 JSON 应答
+func RefundNotifyHandler(svcCtx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+		if err != nil {
+			writePaymentNotifyResponse(w, body, http.StatusBadRequest, "读取请求失败")
+			return
+		}
+
+		l := order.NewRefundNotifyLogic(r.Context(), svcCtx)
+		if err := l.RefundNotify(r.Header, body); err != nil {
+			writePaymentNotifyResponse(w, body, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		writePaymentNotifyResponse(w, body, http.StatusOK, "")
+	}
+}
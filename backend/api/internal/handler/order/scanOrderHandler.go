@@ -6,8 +6,10 @@ package order
 import (
 	"fmt"
 	"net/http"
+	"strconv"
 
 	"dmh/api/internal/logic/order"
+	"dmh/api/internal/middleware"
 	"dmh/api/internal/svc"
 	"dmh/api/internal/types"
 	"github.com/zeromicro/go-zero/rest/httpx"
@@ -22,8 +24,11 @@ func ScanOrderHandler(svcCtx *svc.ServiceContext) http.HandlerFunc {
 			return
 		}
 
-		l := order.NewScanOrderLogic(r.Context(), svcCtx)
-		resp, err := l.ScanOrder(&types.ScanOrderReq{Code: code})
+		// 输入短码时可指定活动
+		campaignId, _ := strconv.ParseInt(r.URL.Query().Get("campaignId"), 10, 64)
+
+		l := order.NewScanOrderLogic(middleware.WithClientInfo(r), svcCtx)
+		resp, err := l.ScanOrder(&types.ScanOrderReq{Code: code, CampaignId: campaignId})
 		if err != nil {
 			httpx.ErrorCtx(r.Context(), w, err)
 		} else {
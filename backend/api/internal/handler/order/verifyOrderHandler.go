@@ -7,6 +7,7 @@ import (
 	"net/http"
 
 	"dmh/api/internal/logic/order"
+	"dmh/api/internal/middleware"
 	"dmh/api/internal/svc"
 	"dmh/api/internal/types"
 	"github.com/zeromicro/go-zero/rest/httpx"
@@ -20,7 +21,7 @@ func VerifyOrderHandler(svcCtx *svc.ServiceContext) http.HandlerFunc {
 			return
 		}
 
-		l := order.NewVerifyOrderLogic(r.Context(), svcCtx)
+		l := order.NewVerifyOrderLogic(middleware.WithClientInfo(r), svcCtx)
 		resp, err := l.VerifyOrder(&req)
 		if err != nil {
 			httpx.ErrorCtx(r.Context(), w, err)
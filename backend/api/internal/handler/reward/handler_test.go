@@ -31,6 +31,7 @@ func setupRewardHandlerTestDB(t *testing.T) *gorm.DB {
 func TestRewardHandlersConstruct(t *testing.T) {
 	assert.NotNil(t, GetRewardsHandler(nil))
 	assert.NotNil(t, GetBalanceHandler(nil))
+	assert.NotNil(t, GetBalanceTransactionsHandler(nil))
 }
 
 func TestGetRewardsHandler_Success(t *testing.T) {
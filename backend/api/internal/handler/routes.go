@@ -21,6 +21,7 @@ import (
 	security "dmh/api/internal/handler/security"
 	statistics "dmh/api/internal/handler/statistics"
 	sync "dmh/api/internal/handler/sync"
+	webhook "dmh/api/internal/handler/webhook"
 	withdrawal "dmh/api/internal/handler/withdrawal"
 	"dmh/api/internal/svc"
 
@@ -190,6 +191,16 @@ func RegisterHandlers(server *rest.Server, serverCtx *svc.ServiceContext) {
 				Path:    "/brands/:id/stats",
 				Handler: brand.GetBrandStatsHandler(serverCtx),
 			},
+			{
+				Method:  http.MethodGet,
+				Path:    "/brands/:id/verification-scopes",
+				Handler: brand.GetVerificationScopesHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodPut,
+				Path:    "/brands/:id/verification-scopes/:userId",
+				Handler: brand.SetVerificationScopesHandler(serverCtx),
+			},
 		},
 		rest.WithJwt(serverCtx.Config.Auth.AccessSecret),
 		rest.WithPrefix("/api/v1"),
@@ -447,18 +458,18 @@ func RegisterHandlers(server *rest.Server, serverCtx *svc.ServiceContext) {
 			},
 			{
 				Method:  http.MethodPost,
-				Path:    "/orders/payment/callback",
-				Handler: order.PaymentCallbackHandler(serverCtx),
+				Path:    "/orders/:id/payment-qrcode",
+				Handler: order.GetOrderPaymentQrcodeHandler(serverCtx),
 			},
 			{
-				Method:  http.MethodGet,
-				Path:    "/orders/scan",
-				Handler: order.ScanOrderHandler(serverCtx),
+				Method:  http.MethodPost,
+				Path:    "/orders/payment/callback",
+				Handler: order.PaymentCallbackHandler(serverCtx),
 			},
 			{
-				Method:  http.MethodGet,
-				Path:    "/orders/verification-records",
-				Handler: order.GetVerificationRecordsHandler(serverCtx),
+				Method:  http.MethodPost,
+				Path:    "/orders/refund/notify",
+				Handler: order.RefundNotifyHandler(serverCtx),
 			},
 			{
 				Method:  http.MethodGet,
@@ -471,16 +482,51 @@ func RegisterHandlers(server *rest.Server, serverCtx *svc.ServiceContext) {
 
 	server.AddRoutes(
 		[]rest.Route{
+			{
+				Method:  http.MethodPost,
+				Path:    "/orders/:id/refund",
+				Handler: order.RefundOrderHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodPost,
+				Path:    "/orders/reconciliations",
+				Handler: order.ReconcilePaymentsHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodGet,
+				Path:    "/orders/reconciliations/:id",
+				Handler: order.GetPaymentReconciliationHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodGet,
+				Path:    "/orders/scan",
+				Handler: order.ScanOrderHandler(serverCtx),
+			},
 			{
 				Method:  http.MethodPost,
 				Path:    "/orders/unverify",
 				Handler: order.UnverifyOrderHandler(serverCtx),
 			},
+			{
+				Method:  http.MethodGet,
+				Path:    "/orders/verification-records",
+				Handler: order.GetVerificationRecordsHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodGet,
+				Path:    "/orders/verification-snapshot",
+				Handler: order.GetVerificationSnapshotHandler(serverCtx),
+			},
 			{
 				Method:  http.MethodPost,
 				Path:    "/orders/verify",
 				Handler: order.VerifyOrderHandler(serverCtx),
 			},
+			{
+				Method:  http.MethodPost,
+				Path:    "/orders/verify/batch",
+				Handler: order.BatchVerifyOrdersHandler(serverCtx),
+			},
 		},
 		rest.WithJwt(serverCtx.Config.Auth.AccessSecret),
 		rest.WithPrefix("/api/v1"),
@@ -524,6 +570,11 @@ func RegisterHandlers(server *rest.Server, serverCtx *svc.ServiceContext) {
 				Path:    "/rewards/balance/:userId",
 				Handler: reward.GetBalanceHandler(serverCtx),
 			},
+			{
+				Method:  http.MethodGet,
+				Path:    "/rewards/balance/:userId/transactions",
+				Handler: reward.GetBalanceTransactionsHandler(serverCtx),
+			},
 		},
 		rest.WithJwt(serverCtx.Config.Auth.AccessSecret),
 		rest.WithPrefix("/api/v1"),
@@ -694,6 +745,16 @@ func RegisterHandlers(server *rest.Server, serverCtx *svc.ServiceContext) {
 				Path:    "/dashboard-stats",
 				Handler: statistics.GetDashboardStatsHandler(serverCtx),
 			},
+			{
+				Method:  http.MethodPost,
+				Path:    "/sync/backfill",
+				Handler: sync.StartSyncBackfillHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodGet,
+				Path:    "/sync/backfill/:name",
+				Handler: sync.GetSyncBackfillHandler(serverCtx),
+			},
 			{
 				Method:  http.MethodGet,
 				Path:    "/sync/health",
@@ -719,6 +780,43 @@ func RegisterHandlers(server *rest.Server, serverCtx *svc.ServiceContext) {
 		rest.WithPrefix("/api/v1"),
 	)
 
+	server.AddRoutes(
+		[]rest.Route{
+			{
+				Method:  http.MethodPost,
+				Path:    "/webhooks",
+				Handler: webhook.CreateWebhookHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodGet,
+				Path:    "/webhooks",
+				Handler: webhook.GetWebhooksHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodPut,
+				Path:    "/webhooks/:id",
+				Handler: webhook.UpdateWebhookHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodDelete,
+				Path:    "/webhooks/:id",
+				Handler: webhook.DeleteWebhookHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodGet,
+				Path:    "/webhooks/:id/deliveries",
+				Handler: webhook.GetWebhookDeliveriesHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodPost,
+				Path:    "/webhooks/deliveries/:id/redeliver",
+				Handler: webhook.RedeliverWebhookDeliveryHandler(serverCtx),
+			},
+		},
+		rest.WithJwt(serverCtx.Config.Auth.AccessSecret),
+		rest.WithPrefix("/api/v1"),
+	)
+
 	server.AddRoutes(
 		[]rest.Route{
 			{
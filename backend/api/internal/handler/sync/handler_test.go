@@ -2,6 +2,7 @@ package sync
 
 import (
 	"dmh/api/internal/handler/testutil"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -10,6 +11,7 @@ import (
 	"dmh/model"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/zeromicro/go-zero/rest/pathvar"
 	"gorm.io/gorm"
 )
 
@@ -59,7 +61,8 @@ func TestGetSyncStatusHandler_Success(t *testing.T) {
 	svcCtx := &svc.ServiceContext{DB: db}
 	handler := GetSyncStatusHandler(svcCtx)
 
-	req := httptest.NewRequest(http.MethodGet, "/api/v1/sync/status?page=1&pageSize=10", nil)
+	req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/sync/status/%d", order.Id), nil)
+	req = pathvar.WithVars(req, map[string]string{"orderId": fmt.Sprintf("%d", order.Id)})
 	resp := httptest.NewRecorder()
 
 	handler(resp, req)
@@ -80,8 +83,8 @@ func TestGetSyncStatsHandler_Success(t *testing.T) {
 	assert.Equal(t, http.StatusOK, resp.Code)
 }
 
-// Ensure RetrySynHandler is wired and returns 200 OK with default logic (nil response)
-func TestRetrySynHandler_Success(t *testing.T) {
+// 非平台管理员不能重试同步
+func TestRetrySynHandler_RequiresAdmin(t *testing.T) {
 	db := setupSyncHandlerTestDB(t)
 	svcCtx := &svc.ServiceContext{DB: db}
 	handler := RetrySynHandler(svcCtx)
@@ -91,5 +94,5 @@ func TestRetrySynHandler_Success(t *testing.T) {
 
 	handler(resp, req)
 
-	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.NotEqual(t, http.StatusOK, resp.Code)
 }
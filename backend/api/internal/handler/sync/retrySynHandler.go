@@ -8,13 +8,20 @@ import (
 
 	"dmh/api/internal/logic/sync"
 	"dmh/api/internal/svc"
+	"dmh/api/internal/types"
 	"github.com/zeromicro/go-zero/rest/httpx"
 )
 
 func RetrySynHandler(svcCtx *svc.ServiceContext) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		var req types.SyncOrderReq
+		if err := httpx.Parse(r, &req); err != nil {
+			httpx.ErrorCtx(r.Context(), w, err)
+			return
+		}
+
 		l := sync.NewRetrySynLogic(r.Context(), svcCtx)
-		resp, err := l.RetrySyn()
+		resp, err := l.RetrySyn(&req)
 		if err != nil {
 			httpx.ErrorCtx(r.Context(), w, err)
 		} else {
@@ -2,14 +2,18 @@ package auth
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
+	"dmh/api/internal/middleware"
 	"dmh/api/internal/svc"
 	"dmh/api/internal/testutil"
 	"dmh/api/internal/types"
 	"dmh/model"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/zeromicro/go-zero/rest/handler"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
@@ -112,3 +116,43 @@ func TestLoginLogic_DisabledUser(t *testing.T) {
 	assert.Nil(t, resp)
 	assert.Contains(t, err.Error(), "账号已被禁用")
 }
+
+func TestLoginLogic_TokenCarriesBrandIds(t *testing.T) {
+	db := setupAuthTestDB(t)
+	user := createTestUserWithPassword(t, db, "brandadmin", "password123", "active")
+
+	role := model.Role{Name: "品牌管理员", Code: "brand_admin"}
+	assert.NoError(t, db.Where("code = ?", role.Code).FirstOrCreate(&role).Error)
+	assert.NoError(t, db.Create(&model.UserRole{UserID: user.Id, RoleID: role.ID}).Error)
+	assert.NoError(t, db.Create(&model.UserBrand{UserId: user.Id, BrandId: 7}).Error)
+
+	svcCtx := &svc.ServiceContext{DB: db}
+	svcCtx.Config.Auth.AccessSecret = "test-secret-key-for-testing"
+	svcCtx.Config.Auth.AccessExpire = 3600
+
+	resp, err := NewLoginLogic(context.Background(), svcCtx).Login(&types.LoginReq{
+		Username: "brandadmin",
+		Password: "password123",
+	})
+	assert.NoError(t, err)
+
+	refreshed, err := NewRefreshTokenLogic(context.Background(), svcCtx).RefreshToken(&types.RefreshTokenReq{Token: resp.Token})
+	assert.NoError(t, err)
+
+	// 经 go-zero JWT 中间件解析真实签发的token，校验品牌权限
+	for _, token := range []string{resp.Token, refreshed.Token} {
+		var ownBrand, otherBrand bool
+		authorize := handler.Authorize(svcCtx.Config.Auth.AccessSecret)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ownBrand = middleware.CanAccessBrand(r.Context(), 7)
+			otherBrand = middleware.CanAccessBrand(r.Context(), 8)
+		}))
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		authorize.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.True(t, ownBrand)
+		assert.False(t, otherBrand)
+	}
+}
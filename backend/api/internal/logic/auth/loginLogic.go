@@ -14,6 +14,7 @@ import (
 	"dmh/model"
 	"github.com/golang-jwt/jwt/v4"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 
 	"github.com/zeromicro/go-zero/core/logx"
 )
@@ -50,30 +51,10 @@ func (l *LoginLogic) Login(req *types.LoginReq) (resp *types.LoginResp, err erro
 		return nil, fmt.Errorf("账号已被禁用")
 	}
 
-	// 查询用户角色
-	var roles []model.Role
-	l.svcCtx.DB.Table("roles").
-		Select("roles.*").
-		Joins("INNER JOIN user_roles ur ON roles.id = ur.role_id").
-		Where("ur.user_id = ?", user.Id).
-		Find(&roles)
-
-	roleCodes := make([]string, 0, len(roles))
-	for _, role := range roles {
-		roleCodes = append(roleCodes, role.Code)
-	}
+	roleCodes, brandIds := loadUserAccess(l.svcCtx.DB, user.Id)
 
-	// 查询用户关联的品牌
-	var userBrands []model.UserBrand
-	l.svcCtx.DB.Where("user_id = ?", user.Id).Find(&userBrands)
-
-	brandIds := make([]int64, 0, len(userBrands))
-	for _, ub := range userBrands {
-		brandIds = append(brandIds, ub.BrandId)
-	}
-
-	// 生成JWT token
-	token, err := l.generateToken(user.Id, user.Username, roleCodes)
+	// 生成JWT token，品牌ID随token下发供 CanAccessBrand 校验
+	token, err := generateToken(l.svcCtx, user.Id, user.Username, roleCodes, brandIds)
 	if err != nil {
 		l.Errorf("生成token失败: %v", err)
 		return nil, err
@@ -91,14 +72,39 @@ func (l *LoginLogic) Login(req *types.LoginReq) (resp *types.LoginResp, err erro
 	return resp, nil
 }
 
-func (l *LoginLogic) generateToken(userId int64, username string, roles []string) (string, error) {
+// loadUserAccess 查询用户角色编码与关联品牌ID，登录与刷新token共用
+func loadUserAccess(db *gorm.DB, userId int64) ([]string, []int64) {
+	var roles []model.Role
+	db.Table("roles").
+		Select("roles.*").
+		Joins("INNER JOIN user_roles ur ON roles.id = ur.role_id").
+		Where("ur.user_id = ?", userId).
+		Find(&roles)
+
+	roleCodes := make([]string, 0, len(roles))
+	for _, role := range roles {
+		roleCodes = append(roleCodes, role.Code)
+	}
+
+	var userBrands []model.UserBrand
+	db.Where("user_id = ?", userId).Find(&userBrands)
+
+	brandIds := make([]int64, 0, len(userBrands))
+	for _, ub := range userBrands {
+		brandIds = append(brandIds, ub.BrandId)
+	}
+	return roleCodes, brandIds
+}
+
+func generateToken(svcCtx *svc.ServiceContext, userId int64, username string, roles []string, brandIds []int64) (string, error) {
 	now := time.Now()
-	expiresAt := now.Add(time.Duration(l.svcCtx.Config.Auth.AccessExpire) * time.Second)
+	expiresAt := now.Add(time.Duration(svcCtx.Config.Auth.AccessExpire) * time.Second)
 
 	claims := &middleware.JWTClaims{
 		UserID:   userId,
 		Username: username,
 		Roles:    roles,
+		BrandIDs: brandIds,
 		RegisteredClaims: jwt.RegisteredClaims{
 			IssuedAt:  jwt.NewNumericDate(now),
 			ExpiresAt: jwt.NewNumericDate(expiresAt),
@@ -107,7 +113,7 @@ func (l *LoginLogic) generateToken(userId int64, username string, roles []string
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(l.svcCtx.Config.Auth.AccessSecret))
+	tokenString, err := token.SignedString([]byte(svcCtx.Config.Auth.AccessSecret))
 	if err != nil {
 		return "", fmt.Errorf("生成JWT token失败: %w", err)
 	}
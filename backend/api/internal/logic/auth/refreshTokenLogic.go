@@ -6,11 +6,11 @@ package auth
 import (
 	"context"
 	"errors"
-	"time"
 
 	"dmh/api/internal/middleware"
 	"dmh/api/internal/svc"
 	"dmh/api/internal/types"
+	"dmh/model"
 
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/zeromicro/go-zero/core/logx"
@@ -35,7 +35,7 @@ func (l *RefreshTokenLogic) RefreshToken(req *types.RefreshTokenReq) (resp *type
 		return nil, errors.New("token不能为空")
 	}
 
-	claims := jwt.MapClaims{}
+	claims := &middleware.JWTClaims{}
 	token, err := jwt.ParseWithClaims(req.Token, claims, func(token *jwt.Token) (interface{}, error) {
 		return []byte(l.svcCtx.Config.Auth.AccessSecret), nil
 	})
@@ -45,22 +45,18 @@ func (l *RefreshTokenLogic) RefreshToken(req *types.RefreshTokenReq) (resp *type
 		return nil, errors.New("token无效或已过期")
 	}
 
-	now := time.Now()
-	expiresAt := now.Add(time.Duration(l.svcCtx.Config.Auth.AccessExpire) * time.Second)
-
-	newClaims := &middleware.JWTClaims{
-		UserID:   int64(claims["userId"].(float64)),
-		Username: claims["username"].(string),
-		Roles:    claims["roles"].([]string),
-		RegisteredClaims: jwt.RegisteredClaims{
-			IssuedAt:  jwt.NewNumericDate(now),
-			ExpiresAt: jwt.NewNumericDate(expiresAt),
-			NotBefore: jwt.NewNumericDate(now),
-		},
+	// 角色与品牌以数据库为准重新加载，避免沿用旧token中的授权信息
+	var user model.User
+	if err := l.svcCtx.DB.Where("id = ?", claims.UserID).First(&user).Error; err != nil {
+		l.Errorf("刷新token用户不存在: userId=%d, err=%v", claims.UserID, err)
+		return nil, errors.New("token无效或已过期")
+	}
+	if user.Status != "active" {
+		return nil, errors.New("账号已被禁用")
 	}
 
-	newToken := jwt.NewWithClaims(jwt.SigningMethodHS256, newClaims)
-	tokenString, err := newToken.SignedString([]byte(l.svcCtx.Config.Auth.AccessSecret))
+	roleCodes, brandIds := loadUserAccess(l.svcCtx.DB, user.Id)
+	tokenString, err := generateToken(l.svcCtx, user.Id, user.Username, roleCodes, brandIds)
 	if err != nil {
 		l.Errorf("生成新token失败: %v", err)
 		return nil, errors.New("生成token失败")
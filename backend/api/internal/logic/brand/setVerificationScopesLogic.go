@@ -0,0 +1,119 @@
+// Code scaffolded by goctl. Safe to edit.
+// goctl 1.9.2
+
+package brand
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"dmh/api/internal/middleware"
+	"dmh/api/internal/svc"
+	"dmh/api/internal/types"
+	"dmh/model"
+
+	"github.com/zeromicro/go-zero/core/logx"
+	"gorm.io/gorm"
+)
+
+const maxVerificationScopesPerUser = 50
+
+type SetVerificationScopesLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewSetVerificationScopesLogic(ctx context.Context, svcCtx *svc.ServiceContext) *SetVerificationScopesLogic {
+	return &SetVerificationScopesLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+// SetVerificationScopes 整体替换人员在品牌下的核销范围，传空列表即取消限制
+func (l *SetVerificationScopesLogic) SetVerificationScopes(req *types.SetVerificationScopesReq) (resp *types.VerificationScopeListResp, err error) {
+	if req.BrandId <= 0 || req.UserId <= 0 {
+		return nil, errors.New("品牌ID或用户ID无效")
+	}
+	if !middleware.CanAccessBrand(l.ctx, req.BrandId) {
+		return nil, errVerificationScopeForbidden
+	}
+	if len(req.Scopes) > maxVerificationScopesPerUser {
+		return nil, fmt.Errorf("每位人员最多配置 %d 条核销范围", maxVerificationScopesPerUser)
+	}
+
+	var linked int64
+	if err := l.svcCtx.DB.Model(&model.UserBrand{}).
+		Where("user_id = ? AND brand_id = ?", req.UserId, req.BrandId).
+		Count(&linked).Error; err != nil {
+		l.Errorf("查询品牌人员失败: %v", err)
+		return nil, errors.New("设置核销范围失败")
+	}
+	if linked == 0 {
+		return nil, errors.New("该用户不属于此品牌")
+	}
+
+	var createdBy int64
+	if uid, err := middleware.GetUserIDFromContext(l.ctx); err == nil {
+		createdBy = uid
+	}
+
+	scopes := make([]model.VerificationScope, 0, len(req.Scopes))
+	seen := make(map[model.VerificationScope]bool, len(req.Scopes))
+	for _, item := range req.Scopes {
+		scope := model.VerificationScope{
+			UserId:     req.UserId,
+			BrandId:    req.BrandId,
+			CampaignId: item.CampaignId,
+			Venue:      strings.TrimSpace(item.Venue),
+			CreatedBy:  createdBy,
+		}
+		if scope.CampaignId < 0 {
+			return nil, errors.New("活动ID无效")
+		}
+		if utf8.RuneCountInString(scope.Venue) > 100 {
+			return nil, errors.New("场地名称不能超过100个字符")
+		}
+		if seen[scope] {
+			continue
+		}
+		seen[scope] = true
+
+		if scope.CampaignId > 0 {
+			var count int64
+			if err := l.svcCtx.DB.Model(&model.Campaign{}).
+				Where("id = ? AND brand_id = ? AND deleted_at IS NULL", scope.CampaignId, req.BrandId).
+				Count(&count).Error; err != nil {
+				l.Errorf("查询活动失败: %v", err)
+				return nil, errors.New("设置核销范围失败")
+			}
+			if count == 0 {
+				return nil, fmt.Errorf("活动 %d 不属于该品牌", scope.CampaignId)
+			}
+		}
+		scopes = append(scopes, scope)
+	}
+
+	err = l.svcCtx.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND brand_id = ?", req.UserId, req.BrandId).
+			Delete(&model.VerificationScope{}).Error; err != nil {
+			return err
+		}
+		if len(scopes) == 0 {
+			return nil
+		}
+		return tx.Create(&scopes).Error
+	})
+	if err != nil {
+		l.Errorf("设置核销范围失败: brandId=%d, userId=%d, err=%v", req.BrandId, req.UserId, err)
+		return nil, errors.New("设置核销范围失败")
+	}
+
+	l.Infof("设置核销范围: brandId=%d, userId=%d, scopes=%d, operator=%d", req.BrandId, req.UserId, len(scopes), createdBy)
+	return listVerificationScopes(l.svcCtx.DB.Where("user_id = ? AND brand_id = ?", req.UserId, req.BrandId))
+}
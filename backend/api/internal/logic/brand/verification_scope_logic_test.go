@@ -0,0 +1,79 @@
+package brand
+
+import (
+	"context"
+	"testing"
+
+	"dmh/api/internal/handler/testutil"
+	"dmh/api/internal/svc"
+	"dmh/api/internal/types"
+	"dmh/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func brandAdminCtx(userId int64, brandIds ...int64) context.Context {
+	ctx := context.WithValue(context.Background(), "roles", []string{"brand_admin"})
+	ctx = context.WithValue(ctx, "brandIds", brandIds)
+	return context.WithValue(ctx, "userId", userId)
+}
+
+func TestVerificationScopesLogic_OtherBrandForbidden(t *testing.T) {
+	svcCtx := &svc.ServiceContext{}
+	ctx := brandAdminCtx(1, 2)
+
+	_, err := NewGetVerificationScopesLogic(ctx, svcCtx).GetVerificationScopes(&types.VerificationScopeListReq{BrandId: 1})
+	assert.ErrorIs(t, err, errVerificationScopeForbidden)
+
+	_, err = NewSetVerificationScopesLogic(ctx, svcCtx).SetVerificationScopes(&types.SetVerificationScopesReq{BrandId: 1, UserId: 5})
+	assert.ErrorIs(t, err, errVerificationScopeForbidden)
+}
+
+func TestSetVerificationScopesLogic_ReplacesScopes(t *testing.T) {
+	db := setupBrandTestDB(t)
+	require.NoError(t, db.AutoMigrate(&model.UserBrand{}, &model.VerificationScope{}))
+	testutil.ClearTables(db, "verification_scopes", "user_brands")
+
+	brand := createTestBrand(t, db, "Scope Brand", "active")
+	other := createTestBrand(t, db, "Other Brand", "active")
+	campaign := &model.Campaign{Name: "门店活动", BrandId: brand.Id, Status: "active", FormFields: "[]"}
+	foreign := &model.Campaign{Name: "其他品牌活动", BrandId: other.Id, Status: "active", FormFields: "[]"}
+	require.NoError(t, db.Create(campaign).Error)
+	require.NoError(t, db.Create(foreign).Error)
+	require.NoError(t, db.Create(&model.UserBrand{UserId: 5, BrandId: brand.Id}).Error)
+
+	svcCtx := &svc.ServiceContext{DB: db}
+	logic := NewSetVerificationScopesLogic(brandAdminCtx(1, brand.Id), svcCtx)
+
+	// 未关联品牌的用户
+	_, err := logic.SetVerificationScopes(&types.SetVerificationScopesReq{BrandId: brand.Id, UserId: 6})
+	assert.Error(t, err)
+
+	// 其他品牌的活动
+	_, err = logic.SetVerificationScopes(&types.SetVerificationScopesReq{
+		BrandId: brand.Id,
+		UserId:  5,
+		Scopes:  []types.VerificationScopeItem{{CampaignId: foreign.Id}},
+	})
+	assert.Error(t, err)
+
+	resp, err := logic.SetVerificationScopes(&types.SetVerificationScopesReq{
+		BrandId: brand.Id,
+		UserId:  5,
+		Scopes: []types.VerificationScopeItem{
+			{CampaignId: campaign.Id},
+			{Venue: " 徐汇店 "},
+			{Venue: "徐汇店"},
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.Scopes, 2)
+	assert.Equal(t, "徐汇店", resp.Scopes[1].Venue)
+	assert.Equal(t, int64(1), resp.Scopes[0].CreatedBy)
+
+	// 空列表取消限制
+	resp, err = logic.SetVerificationScopes(&types.SetVerificationScopesReq{BrandId: brand.Id, UserId: 5})
+	require.NoError(t, err)
+	assert.Empty(t, resp.Scopes)
+}
@@ -459,14 +459,16 @@ func TestGetPageConfigLogic_GetPageConfig_NotFound(t *testing.T) {
 func TestGetPaymentQrcodeLogic_GetPaymentQrcode_Success(t *testing.T) {
 	db := setupCampaignTestDB(t)
 
+	paymentConfig := `{"requirePayment":true,"paymentType":"full","paymentAmount":99.9}`
 	campaign := &model.Campaign{
-		Name:        "测试活动",
-		Description: "测试描述",
-		RewardRule:  10.00,
-		StartTime:   time.Now().Add(-1 * time.Hour),
-		EndTime:     time.Now().Add(24 * time.Hour),
-		Status:      "active",
-		BrandId:     1,
+		Name:          "测试活动",
+		Description:   "测试描述",
+		RewardRule:    10.00,
+		StartTime:     time.Now().Add(-1 * time.Hour),
+		EndTime:       time.Now().Add(24 * time.Hour),
+		Status:        "active",
+		BrandId:       1,
+		PaymentConfig: &paymentConfig,
 	}
 	db.Create(campaign)
 
@@ -482,9 +484,27 @@ func TestGetPaymentQrcodeLogic_GetPaymentQrcode_Success(t *testing.T) {
 
 	assert.NoError(t, err)
 	assert.NotNil(t, resp)
-	assert.Equal(t, 100.00, resp.Amount)
+	assert.Equal(t, 99.9, resp.Amount)
 	assert.Equal(t, "测试活动", resp.CampaignName)
-	assert.NotEmpty(t, resp.QrcodeUrl)
+}
+
+func TestGetPaymentQrcodeLogic_GetPaymentQrcode_FreeCampaign(t *testing.T) {
+	db := setupCampaignTestDB(t)
+
+	campaign := &model.Campaign{
+		Name:      "免费活动",
+		StartTime: time.Now().Add(-1 * time.Hour),
+		EndTime:   time.Now().Add(24 * time.Hour),
+		Status:    "active",
+		BrandId:   1,
+	}
+	db.Create(campaign)
+
+	logic := NewGetPaymentQrcodeLogic(context.Background(), &svc.ServiceContext{DB: db})
+	resp, err := logic.GetPaymentQrcode(types.GetPaymentQrcodeReq{Id: campaign.Id})
+
+	assert.Error(t, err)
+	assert.Nil(t, resp)
 }
 
 func TestGetPaymentQrcodeLogic_GetPaymentQrcode_NotFound(t *testing.T) {
@@ -0,0 +1,19 @@
+package campaign
+
+import (
+	"dmh/api/internal/service"
+	"dmh/api/internal/svc"
+	"dmh/model"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// remainingSeats 活动剩余名额，不限量或统计失败时返回 nil
+func remainingSeats(logger logx.Logger, svcCtx *svc.ServiceContext, campaign *model.Campaign) *int64 {
+	remaining, err := service.NewCampaignSeatService(svcCtx.DB, svcCtx.SeatCounter).Remaining(campaign)
+	if err != nil {
+		logger.Errorf("统计活动剩余名额失败: campaignId=%d, err=%v", campaign.Id, err)
+		return nil
+	}
+	return remaining
+}
@@ -7,6 +7,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"dmh/api/internal/svc"
@@ -71,9 +72,23 @@ func (l *CreateCampaignLogic) CreateCampaign(req *types.CreateCampaignReq) (resp
 
 	var paymentConfig *string
 	if req.PaymentConfig != "" {
+		if _, err := model.ParseCampaignPaymentConfig(&req.PaymentConfig); err != nil {
+			return nil, fmt.Errorf("Invalid payment config: %v", err)
+		}
 		paymentConfig = &req.PaymentConfig
 	}
 
+	if req.TotalQuota < 0 || req.DailyQuota < 0 {
+		return nil, fmt.Errorf("Quota must not be negative")
+	}
+	redemptionsPerOrder := req.RedemptionsPerOrder
+	if redemptionsPerOrder == 0 {
+		redemptionsPerOrder = 1
+	}
+	if redemptionsPerOrder < 1 || redemptionsPerOrder > model.MaxRedemptionsPerOrder {
+		return nil, fmt.Errorf("Redemptions per order must be between 1 and %d", model.MaxRedemptionsPerOrder)
+	}
+
 	newCampaign := model.Campaign{
 		BrandId:             req.BrandId,
 		Name:                req.Name,
@@ -87,6 +102,10 @@ func (l *CreateCampaignLogic) CreateCampaign(req *types.CreateCampaignReq) (resp
 		DistributionRewards: distributionRewards,
 		PaymentConfig:       paymentConfig,
 		PosterTemplateId:    posterTemplateId,
+		TotalQuota:          req.TotalQuota,
+		DailyQuota:          req.DailyQuota,
+		RedemptionsPerOrder: redemptionsPerOrder,
+		Venue:               strings.TrimSpace(req.Venue),
 	}
 
 	// 序列化formFields数组为JSON字符串存储到数据库
@@ -138,6 +157,11 @@ func (l *CreateCampaignLogic) CreateCampaign(req *types.CreateCampaignReq) (resp
 		DistributionRewards: distributionRewardsResp,
 		PaymentConfig:       paymentConfigResp,
 		PosterTemplateId:    newCampaign.PosterTemplateId,
+		TotalQuota:          newCampaign.TotalQuota,
+		DailyQuota:          newCampaign.DailyQuota,
+		RedemptionsPerOrder: newCampaign.RedemptionsPerOrder,
+		Venue:               newCampaign.Venue,
+		RemainingSeats:      remainingSeats(l.Logger, l.svcCtx, &newCampaign),
 		CreatedAt:           newCampaign.CreatedAt.Format("2006-01-02T15:04:05"),
 		UpdatedAt:           newCampaign.UpdatedAt.Format("2006-01-02T15:04:05"),
 	}
@@ -63,6 +63,11 @@ func (l *GetCampaignLogic) GetCampaign(req *types.GetCampaignReq) (resp *types.C
 		DistributionRewards: "",
 		PaymentConfig:       "",
 		PosterTemplateId:    campaign.PosterTemplateId,
+		TotalQuota:          campaign.TotalQuota,
+		DailyQuota:          campaign.DailyQuota,
+		RedemptionsPerOrder: campaign.RedemptionsPerOrder,
+		Venue:               campaign.Venue,
+		RemainingSeats:      remainingSeats(l.Logger, l.svcCtx, &campaign),
 		CreatedAt:           campaign.CreatedAt.Format("2006-01-02T15:04:05"),
 		UpdatedAt:           campaign.UpdatedAt.Format("2006-01-02T15:04:05"),
 	}
@@ -6,7 +6,6 @@ package campaign
 import (
 	"context"
 	"fmt"
-	"time"
 
 	"dmh/api/internal/svc"
 	"dmh/api/internal/types"
@@ -29,6 +28,7 @@ func NewGetPaymentQrcodeLogic(ctx context.Context, svcCtx *svc.ServiceContext) *
 	}
 }
 
+// GetPaymentQrcode 返回活动报名应付金额；真实的支付二维码需报名后按订单获取（POST /orders/:id/payment-qrcode）
 func (l *GetPaymentQrcodeLogic) GetPaymentQrcode(req types.GetPaymentQrcodeReq) (resp *types.PaymentQrcodeResp, err error) {
 	var campaign model.Campaign
 	if err := l.svcCtx.DB.First(&campaign, req.Id).Error; err != nil {
@@ -36,16 +36,22 @@ func (l *GetPaymentQrcodeLogic) GetPaymentQrcode(req types.GetPaymentQrcodeReq)
 		return nil, fmt.Errorf("活动不存在")
 	}
 
-	amount := campaign.RewardRule * 10
+	paymentConfig, err := model.ParseCampaignPaymentConfig(campaign.PaymentConfig)
+	if err != nil {
+		l.Errorf("活动支付配置错误: campaignId=%d, err=%v", campaign.Id, err)
+		return nil, fmt.Errorf("活动支付配置错误")
+	}
 
-	qrcodeContent := fmt.Sprintf("weixin://wxpay/bizpayurl?pr=campaign_%d_%d", req.Id, time.Now().Unix())
+	amount := paymentConfig.Price()
+	if amount <= 0 {
+		return nil, fmt.Errorf("活动无需支付")
+	}
 
 	resp = &types.PaymentQrcodeResp{
-		QrcodeUrl:    qrcodeContent,
 		Amount:       amount,
 		CampaignName: campaign.Name,
 	}
 
-	l.Infof("成功生成支付二维码: campaignId=%d, amount=%.2f", req.Id, amount)
+	l.Infof("查询活动支付金额: campaignId=%d, amount=%.2f", req.Id, amount)
 	return resp, nil
 }
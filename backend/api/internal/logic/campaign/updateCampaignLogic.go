@@ -96,10 +96,35 @@ func (l *UpdateCampaignLogic) UpdateCampaign(req *types.UpdateCampaignReq) (resp
 		if strings.TrimSpace(*req.PaymentConfig) == "" {
 			campaign.PaymentConfig = nil
 		} else {
+			if _, err := model.ParseCampaignPaymentConfig(req.PaymentConfig); err != nil {
+				return nil, fmt.Errorf("Invalid payment config: %v", err)
+			}
 			campaign.PaymentConfig = req.PaymentConfig
 		}
 	}
 
+	if req.TotalQuota != nil {
+		if *req.TotalQuota < 0 {
+			return nil, fmt.Errorf("Quota must not be negative")
+		}
+		campaign.TotalQuota = *req.TotalQuota
+	}
+	if req.DailyQuota != nil {
+		if *req.DailyQuota < 0 {
+			return nil, fmt.Errorf("Quota must not be negative")
+		}
+		campaign.DailyQuota = *req.DailyQuota
+	}
+	if req.RedemptionsPerOrder != nil {
+		if *req.RedemptionsPerOrder < 1 || *req.RedemptionsPerOrder > model.MaxRedemptionsPerOrder {
+			return nil, fmt.Errorf("Redemptions per order must be between 1 and %d", model.MaxRedemptionsPerOrder)
+		}
+		campaign.RedemptionsPerOrder = *req.RedemptionsPerOrder
+	}
+	if req.Venue != nil {
+		campaign.Venue = strings.TrimSpace(*req.Venue)
+	}
+
 	if req.PosterTemplateId != nil && *req.PosterTemplateId > 0 {
 		campaign.PosterTemplateId = *req.PosterTemplateId
 	}
@@ -149,6 +174,11 @@ func (l *UpdateCampaignLogic) UpdateCampaign(req *types.UpdateCampaignReq) (resp
 		DistributionRewards: distributionRewardsResp,
 		PaymentConfig:       paymentConfigResp,
 		PosterTemplateId:    campaign.PosterTemplateId,
+		TotalQuota:          campaign.TotalQuota,
+		DailyQuota:          campaign.DailyQuota,
+		RedemptionsPerOrder: campaign.RedemptionsPerOrder,
+		Venue:               campaign.Venue,
+		RemainingSeats:      remainingSeats(l.Logger, l.svcCtx, &campaign),
 		CreatedAt:           campaign.CreatedAt.Format("2006-01-02T15:04:05"),
 		UpdatedAt:           campaign.UpdatedAt.Format("2006-01-02T15:04:05"),
 	}
@@ -10,6 +10,7 @@ import (
 	"dmh/model"
 
 	"github.com/zeromicro/go-zero/core/logx"
+	"gorm.io/gorm"
 )
 
 type ApproveDistributorApplicationLogic struct {
@@ -41,13 +42,18 @@ func (l *ApproveDistributorApplicationLogic) ApproveDistributorApplication(req *
 	now := l.svcCtx.DB.NowFunc()
 	application.ReviewedAt = &now
 
-	if err := l.svcCtx.DB.Save(application).Error; err != nil {
-		return nil, err
-	}
+	// 审核结果、分销商与 Webhook 事件同事务写入，事件记录失败时整体回滚
+	err = l.svcCtx.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(application).Error; err != nil {
+			return err
+		}
+
+		if req.Action != "approved" {
+			return nil
+		}
 
-	if req.Action == "approved" {
 		existingDistributor := &model.Distributor{}
-		result := l.svcCtx.DB.Where("user_id = ? AND brand_id = ?", application.UserId, application.BrandId).First(existingDistributor)
+		result := tx.Where("user_id = ? AND brand_id = ?", application.UserId, application.BrandId).First(existingDistributor)
 
 		if result.Error != nil {
 			newDistributor := &model.Distributor{
@@ -61,19 +67,29 @@ func (l *ApproveDistributorApplicationLogic) ApproveDistributorApplication(req *
 				SubordinatesCount: 0,
 			}
 
-			if err := l.svcCtx.DB.Create(newDistributor).Error; err != nil {
-				return nil, err
+			if err := tx.Create(newDistributor).Error; err != nil {
+				return err
 			}
+			existingDistributor = newDistributor
 		} else {
 			existingDistributor.Status = "active"
 			existingDistributor.Level = req.Level
 			existingDistributor.ApprovedBy = &reviewerId
 			existingDistributor.ApprovedAt = &now
 
-			if err := l.svcCtx.DB.Save(existingDistributor).Error; err != nil {
-				return nil, err
+			if err := tx.Save(existingDistributor).Error; err != nil {
+				return err
 			}
 		}
+
+		if err := l.svcCtx.Webhooks.PublishDistributorApproved(tx, existingDistributor); err != nil {
+			l.Errorf("记录 Webhook 事件失败: distributorId=%d, err=%v", existingDistributor.Id, err)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	if err := l.svcCtx.DB.Preload("User").Preload("Brand").Preload("Reviewer").First(application, application.Id).Error; err != nil {
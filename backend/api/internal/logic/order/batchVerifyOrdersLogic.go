@@ -0,0 +1,236 @@
+// Code scaffolded by goctl. Safe to edit.
+// goctl 1.9.2
+
+package order
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"dmh/api/internal/middleware"
+	"dmh/api/internal/service"
+	"dmh/api/internal/svc"
+	"dmh/api/internal/types"
+	"dmh/model"
+
+	"github.com/zeromicro/go-zero/core/logx"
+	"gorm.io/gorm"
+)
+
+const maxOfflineVerificationItems = 200
+
+// 离线核销单条结果
+const (
+	offlineStatusApplied   = "applied"
+	offlineStatusDuplicate = "duplicate"
+	offlineStatusConflict  = "conflict"
+	offlineStatusInvalid   = "invalid"
+)
+
+type BatchVerifyOrdersLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewBatchVerifyOrdersLogic(ctx context.Context, svcCtx *svc.ServiceContext) *BatchVerifyOrdersLogic {
+	return &BatchVerifyOrdersLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+// BatchVerifyOrders 上传离线核销记录；按 (订单品牌, 设备, 设备端核销ID) 幂等，次数已用完的订单返回冲突而不覆盖
+func (l *BatchVerifyOrdersLogic) BatchVerifyOrders(req *types.BatchVerifyOrdersReq) (resp *types.BatchVerifyOrdersResp, err error) {
+	if !hasVerificationPermission(l.ctx) {
+		return nil, fmt.Errorf("权限不足，仅品牌管理员或平台管理员可执行核销操作")
+	}
+
+	deviceId := strings.TrimSpace(req.DeviceId)
+	if deviceId == "" || len(deviceId) > 64 {
+		return nil, fmt.Errorf("设备标识无效")
+	}
+	if len(req.Items) == 0 {
+		return nil, fmt.Errorf("核销记录不能为空")
+	}
+	if len(req.Items) > maxOfflineVerificationItems {
+		return nil, fmt.Errorf("单次最多上传 %d 条核销记录", maxOfflineVerificationItems)
+	}
+
+	// 设备核销时间不早于所用快照的生成时间，也不早于快照有效期，防止伪造过早的时间绕过核销码过期
+	now := time.Now()
+	earliest := now.Add(-snapshotTTL(l.svcCtx))
+	if req.SnapshotAt != "" {
+		snapshotAt, err := time.Parse(time.RFC3339, req.SnapshotAt)
+		if err != nil {
+			return nil, fmt.Errorf("快照时间格式错误")
+		}
+		if snapshotAt.After(now) {
+			return nil, fmt.Errorf("快照时间无效")
+		}
+		if snapshotAt.After(earliest) {
+			earliest = snapshotAt
+		}
+	}
+
+	resp = &types.BatchVerifyOrdersResp{
+		Results: make([]types.OfflineVerificationResult, 0, len(req.Items)),
+	}
+	for _, item := range req.Items {
+		result := l.applyItem(deviceId, req.CampaignId, earliest, item)
+		switch result.Status {
+		case offlineStatusApplied:
+			resp.Applied++
+		case offlineStatusDuplicate:
+			resp.Duplicates++
+		case offlineStatusConflict:
+			resp.Conflicts++
+		default:
+			resp.Invalid++
+		}
+		resp.Results = append(resp.Results, result)
+	}
+
+	l.Infof("离线核销上传: deviceId=%s, applied=%d, duplicates=%d, conflicts=%d, invalid=%d",
+		deviceId, resp.Applied, resp.Duplicates, resp.Conflicts, resp.Invalid)
+	return resp, nil
+}
+
+func (l *BatchVerifyOrdersLogic) applyItem(deviceId string, campaignId int64, earliest time.Time, item types.OfflineVerificationItem) types.OfflineVerificationResult {
+	result := types.OfflineVerificationResult{ClientId: item.ClientId}
+	invalid := func(reason, message string) types.OfflineVerificationResult {
+		result.Status, result.Reason, result.Message = offlineStatusInvalid, reason, message
+		return result
+	}
+
+	clientRef := strings.TrimSpace(item.ClientId)
+	if clientRef == "" || len(clientRef) > 64 {
+		return invalid("invalid_client_id", "设备端核销ID无效")
+	}
+	verifiedAt, err := time.Parse(time.RFC3339, item.VerifiedAt)
+	if err != nil {
+		return invalid("invalid_time", "核销时间格式错误")
+	}
+	// 设备时钟可能超前，核销时间不晚于服务器收到的时间
+	if now := time.Now(); verifiedAt.After(now) {
+		verifiedAt = now
+	}
+
+	// 核销码按设备核销时间判断是否过期
+	orderId, err := resolveVerificationCode(l.ctx, l.Logger, l.svcCtx, item.Code, campaignId, verifiedAt)
+	if err != nil {
+		return invalid("invalid_code", err.Error())
+	}
+
+	var order model.Order
+	if err := l.svcCtx.DB.Where("id = ? AND deleted_at IS NULL", orderId).First(&order).Error; err != nil {
+		return invalid("order_not_found", "订单不存在")
+	}
+	if campaignId > 0 && order.CampaignId != campaignId {
+		return invalid("campaign_mismatch", "订单不属于该活动")
+	}
+	if err := authorizeVerification(l.ctx, l.Logger, l.svcCtx, &order, "离线核销"); err != nil {
+		return invalid("forbidden", err.Error())
+	}
+	result.OrderId = orderId
+
+	// 设备ID与核销ID由客户端生成，去重键按订单所属品牌隔离，且只在鉴权通过后查询
+	var campaign model.Campaign
+	if err := l.svcCtx.DB.Select("id", "brand_id").Where("id = ?", order.CampaignId).First(&campaign).Error; err != nil {
+		return invalid("order_not_found", "订单所属活动不存在")
+	}
+	brandId := campaign.BrandId
+
+	if duplicate, ok := l.findDuplicate(brandId, deviceId, clientRef, result); ok {
+		return duplicate
+	}
+	if verifiedAt.Before(earliest) {
+		return invalid("stale_time", "核销时间早于快照有效期")
+	}
+
+	conflict := func(reason, message string) types.OfflineVerificationResult {
+		result.Status, result.Reason, result.Message = offlineStatusConflict, reason, message
+		if order.VerifiedAt != nil {
+			result.VerifiedAt = order.VerifiedAt.Format(time.RFC3339)
+		}
+		return result
+	}
+	switch {
+	case order.PayStatus == "refunded":
+		return conflict("refunded", "订单已退款")
+	case order.Status == "cancelled":
+		return conflict("cancelled", "订单已取消")
+	case order.RemainingRedemptions() == 0:
+		return conflict("already_verified", "订单已核销")
+	}
+
+	if err := l.verify(&order, brandId, deviceId, clientRef, item.Code, verifiedAt); err != nil {
+		// 同一记录并发上传，唯一键冲突的一方按重复处理
+		if isDuplicateOrderError(err) {
+			if duplicate, ok := l.findDuplicate(brandId, deviceId, clientRef, result); ok {
+				return duplicate
+			}
+		}
+		switch {
+		case errors.Is(err, errOrderRefunded):
+			return conflict("refunded", "订单已退款")
+		case errors.Is(err, errOrderCancelled):
+			return conflict("cancelled", "订单已取消")
+		case errors.Is(err, errRedemptionsExhausted) || errors.Is(err, errRedemptionConflict):
+			l.svcCtx.DB.Where("id = ?", order.Id).First(&order)
+			return conflict("already_verified", "订单已核销")
+		}
+		l.Errorf("离线核销失败: orderId=%d, err=%v", order.Id, err)
+		return invalid("internal_error", "核销失败，请重新上传")
+	}
+
+	result.Status = offlineStatusApplied
+	result.VerifiedAt = verifiedAt.Format(time.RFC3339)
+	return result
+}
+
+// findDuplicate 查找同一品牌下同一设备已上传的核销记录；查询出错时返回 internal_error，由设备稍后重传
+func (l *BatchVerifyOrdersLogic) findDuplicate(brandId int64, deviceId, clientRef string, result types.OfflineVerificationResult) (types.OfflineVerificationResult, bool) {
+	var existing model.VerificationRecord
+	err := l.svcCtx.DB.Where("brand_id = ? AND device_id = ? AND client_ref = ?", brandId, deviceId, clientRef).First(&existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return result, false
+	}
+	if err != nil {
+		l.Errorf("查询离线核销记录失败: %v", err)
+		result.Status, result.Reason, result.Message = offlineStatusInvalid, "internal_error", "核销失败，请重新上传"
+		return result, true
+	}
+	result.OrderId, result.Status = existing.OrderID, offlineStatusDuplicate
+	if existing.VerifiedAt != nil {
+		result.VerifiedAt = existing.VerifiedAt.Format(time.RFC3339)
+	}
+	return result, true
+}
+
+// verify 消耗一次核销次数，与在线核销或其他设备并发时不会超出次数
+func (l *BatchVerifyOrdersLogic) verify(order *model.Order, brandId int64, deviceId, clientRef, code string, verifiedAt time.Time) error {
+	var verifiedBy int64
+	if uid, err := middleware.GetUserIDFromContext(l.ctx); err == nil {
+		verifiedBy = uid
+	}
+
+	return l.svcCtx.DB.Transaction(func(tx *gorm.DB) error {
+		_, err := redeemOrder(tx, order, model.VerificationRecord{
+			VerificationCode:   code,
+			VerificationMethod: "offline",
+			Remark:             fmt.Sprintf("离线核销，设备 %s", deviceId),
+			BrandID:            &brandId,
+			DeviceID:           &deviceId,
+			ClientRef:          &clientRef,
+		}, verifiedAt, verifiedBy)
+		if err != nil {
+			return err
+		}
+		return l.svcCtx.Webhooks.PublishOrder(tx, service.WebhookEventOrderVerified, order)
+	})
+}
@@ -5,8 +5,6 @@ package order
 
 import (
 	"context"
-	"crypto/md5"
-	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -14,6 +12,7 @@ import (
 	"strings"
 	"time"
 
+	"dmh/api/internal/service"
 	"dmh/api/internal/svc"
 	"dmh/api/internal/types"
 	"dmh/model"
@@ -23,6 +22,9 @@ import (
 	"gorm.io/gorm"
 )
 
+// errVerificationCodeIssue 核销码签发失败，订单随之回滚
+var errVerificationCodeIssue = errors.New("生成核销码失败")
+
 type CreateOrderLogic struct {
 	logx.Logger
 	ctx    context.Context
@@ -45,11 +47,18 @@ func (l *CreateOrderLogic) CreateOrder(req *types.CreateOrderReq) (resp *types.O
 		return nil, fmt.Errorf("手机号格式错误: %v", err)
 	}
 
-	if _, err := l.validateCampaign(req.CampaignId); err != nil {
+	campaign, err := l.validateCampaign(req.CampaignId)
+	if err != nil {
 		l.Errorf("Campaign validation failed: %v", err)
 		return nil, err
 	}
 
+	paymentConfig, err := model.ParseCampaignPaymentConfig(campaign.PaymentConfig)
+	if err != nil {
+		l.Errorf("Invalid campaign payment config: campaignId=%d, err=%v", campaign.Id, err)
+		return nil, fmt.Errorf("活动支付配置错误")
+	}
+
 	if err := l.checkDuplicate(req.CampaignId, req.Phone); err != nil {
 		l.Errorf("Duplicate order check failed: %v", err)
 		return nil, err
@@ -72,44 +81,69 @@ func (l *CreateOrderLogic) CreateOrder(req *types.CreateOrderReq) (resp *types.O
 		return nil, fmt.Errorf("表单数据序列化失败: %v", err)
 	}
 
-	timestamp := time.Now().Unix()
-
 	order := &model.Order{
 		CampaignId:         req.CampaignId,
 		Phone:              req.Phone,
 		FormData:           string(formDataJSON),
 		ReferrerId:         req.ReferrerId,
 		Status:             "pending",
+		Amount:             paymentConfig.Price(),
 		PayStatus:          "unpaid",
 		VerificationStatus: "unverified",
+		RedemptionQuota:    campaign.RedemptionsPerOrder,
+	}
+	if order.RedemptionQuota < 1 {
+		order.RedemptionQuota = 1
 	}
 
-	if err := l.svcCtx.DB.Create(order).Error; err != nil {
+	// 核销码依赖订单ID，与订单在同一事务内写入，签发失败时不留下无核销码的订单与占用的名额
+	var verificationCode, shortCode string
+	seats := service.NewCampaignSeatService(l.svcCtx.DB, l.svcCtx.SeatCounter)
+	err = seats.CreateOrder(l.ctx, campaign, order, func(tx *gorm.DB) error {
+		code, err := l.generateVerificationCode(order.Id, campaign.EndTime)
+		if err != nil {
+			l.Errorf("Failed to generate verification code: orderId=%d, err=%v", order.Id, err)
+			return errVerificationCodeIssue
+		}
+		if shortCode, err = l.saveVerificationCodes(tx, order, code); err != nil {
+			l.Errorf("Failed to update verification code: %v", err)
+			return fmt.Errorf("更新核销码失败: %v", err)
+		}
+		verificationCode = code
+		order.VerificationCode = code
+		order.ShortCode = &shortCode
+
+		// 事件与订单同事务写入，记录失败时订单一并回滚
+		if err := l.svcCtx.Webhooks.PublishOrder(tx, service.WebhookEventOrderCreated, order); err != nil {
+			l.Errorf("Failed to record webhook event: orderId=%d, err=%v", order.Id, err)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
 		l.Errorf("Failed to create order: %v", err)
+		if errors.Is(err, service.ErrCampaignFull) || errors.Is(err, service.ErrCampaignDailyFull) || errors.Is(err, errVerificationCodeIssue) {
+			return nil, err
+		}
 		if isDuplicateOrderError(err) {
 			return nil, fmt.Errorf("该手机号已参与此活动，请勿重复报名")
 		}
 		return nil, fmt.Errorf("创建订单失败: %v", err)
 	}
-
-	verificationCode := l.generateVerificationCode(order.Id, req.Phone, timestamp)
-	if err := l.svcCtx.DB.Model(order).Update("verification_code", verificationCode).Error; err != nil {
-		l.Errorf("Failed to update verification code: %v", err)
-		return nil, fmt.Errorf("更新核销码失败: %v", err)
-	}
-	order.VerificationCode = verificationCode
-
 	l.Infof("Order created successfully: ID=%d, CampaignID=%d, Phone=%s", order.Id, order.CampaignId, order.Phone)
 
 	resp = &types.OrderResp{
-		Id:         order.Id,
-		CampaignId: order.CampaignId,
-		Phone:      order.Phone,
-		FormData:   req.FormData,
-		ReferrerId: order.ReferrerId,
-		Status:     order.Status,
-		Amount:     order.Amount,
-		CreatedAt:  order.CreatedAt.Format("2006-01-02T15:04:05"),
+		Id:               order.Id,
+		CampaignId:       order.CampaignId,
+		Phone:            order.Phone,
+		FormData:         req.FormData,
+		ReferrerId:       order.ReferrerId,
+		Status:           order.Status,
+		Amount:           order.Amount,
+		CreatedAt:        order.CreatedAt.Format("2006-01-02T15:04:05"),
+		RedemptionQuota:  order.RedemptionQuota,
+		VerificationCode: verificationCode,
+		ShortCode:        shortCode,
 	}
 
 	l.Infof("Returning response: %+v", resp)
@@ -144,7 +178,7 @@ func (l *CreateOrderLogic) validateCampaign(campaignId int64) (*model.Campaign,
 func (l *CreateOrderLogic) checkDuplicate(campaignId int64, phone string) error {
 	var count int64
 	if err := l.svcCtx.DB.Model(&model.Order{}).
-		Where("campaign_id = ? AND phone = ? AND deleted_at IS NULL", campaignId, phone).
+		Where("campaign_id = ? AND phone = ? AND status <> ? AND deleted_at IS NULL", campaignId, phone, "cancelled").
 		Count(&count).Error; err != nil {
 		l.Errorf("Failed to check duplicate order: %v", err)
 		return fmt.Errorf("检查重复订单失败: %v", err)
@@ -280,14 +314,48 @@ func validateSelect(value string, field model.FormField) error {
 	return fmt.Errorf("请选择有效的选项")
 }
 
-func (l *CreateOrderLogic) generateVerificationCode(orderId int64, phone string, timestamp int64) string {
-	secretKey := "dmh-verification-secret-2026"
+// generateVerificationCode 签发核销码，有效期从活动结束时间起算
+func (l *CreateOrderLogic) generateVerificationCode(orderId int64, campaignEnd time.Time) (string, error) {
+	if l.svcCtx.VerificationTokens == nil {
+		return "", fmt.Errorf("核销码服务未配置")
+	}
+	return l.svcCtx.VerificationTokens.Issue(orderId, campaignEnd)
+}
+
+// maxShortCodeAttempts 短码碰撞时的最大重试次数
+const maxShortCodeAttempts = 5
 
-	signatureData := fmt.Sprintf("%d_%s_%d_%s", orderId, phone, timestamp, secretKey)
-	hash := md5.Sum([]byte(signatureData))
-	signature := hex.EncodeToString(hash[:])
+// saveVerificationCodes 在下单事务内保存核销码，并分配活动内唯一的短码；并发分配撞上唯一索引时换一个重试
+func (l *CreateOrderLogic) saveVerificationCodes(tx *gorm.DB, order *model.Order, verificationCode string) (string, error) {
+	length := l.svcCtx.Config.Verification.ShortCodeLength
+	if length == 0 {
+		length = service.MinShortCodeLength
+	}
+
+	for attempt := 0; attempt < maxShortCodeAttempts; attempt++ {
+		shortCode, err := service.GenerateShortCode(length)
+		if err != nil {
+			return "", err
+		}
 
-	return fmt.Sprintf("%d_%s_%d_%s", orderId, phone, timestamp, signature)
+		var count int64
+		if err := tx.Model(&model.Order{}).Where("campaign_id = ? AND short_code = ?", order.CampaignId, shortCode).Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count > 0 {
+			continue
+		}
+
+		err = tx.Model(order).Updates(map[string]interface{}{
+			"verification_code": verificationCode,
+			"short_code":        shortCode,
+		}).Error
+		if isDuplicateOrderError(err) {
+			continue
+		}
+		return shortCode, err
+	}
+	return "", fmt.Errorf("分配核销短码失败，已重试 %d 次", maxShortCodeAttempts)
 }
 
 func isDuplicateOrderError(err error) bool {
@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"dmh/api/internal/service"
 	"dmh/api/internal/svc"
 	"dmh/api/internal/types"
 	"dmh/model"
@@ -18,7 +19,7 @@ import (
 func TestCreateOrderLogic_CampaignNotFound(t *testing.T) {
 	db := setupTestDB(t)
 
-	logic := NewCreateOrderLogic(context.Background(), &svc.ServiceContext{DB: db})
+	logic := NewCreateOrderLogic(context.Background(), newTestSvcCtx(db))
 	req := &types.CreateOrderReq{
 		CampaignId: 9999,
 		Phone:      "13800138000",
@@ -48,7 +49,7 @@ func TestCreateOrderLogic_CampaignEnded(t *testing.T) {
 	}
 	require.NoError(t, db.Create(campaign).Error)
 
-	logic := NewCreateOrderLogic(context.Background(), &svc.ServiceContext{DB: db})
+	logic := NewCreateOrderLogic(context.Background(), newTestSvcCtx(db))
 	req := &types.CreateOrderReq{
 		CampaignId: campaign.Id,
 		Phone:      "13800138000",
@@ -63,11 +64,188 @@ func TestCreateOrderLogic_CampaignEnded(t *testing.T) {
 	assert.Contains(t, err.Error(), "活动已结束")
 }
 
+func TestCreateOrderLogic_ResignupAfterCancelledOrder(t *testing.T) {
+	db := setupTestDB(t)
+
+	campaign := &model.Campaign{
+		Name:        "进行中活动",
+		Description: "测试取消后重新报名",
+		FormFields:  `[{"type":"text","name":"name","label":"姓名","required":true}]`,
+		RewardRule:  10,
+		StartTime:   time.Now().Add(-24 * time.Hour),
+		EndTime:     time.Now().Add(24 * time.Hour),
+		Status:      "active",
+		BrandId:     1,
+	}
+	require.NoError(t, db.Create(campaign).Error)
+
+	logic := NewCreateOrderLogic(context.Background(), newTestSvcCtx(db))
+	req := &types.CreateOrderReq{
+		CampaignId: campaign.Id,
+		Phone:      "13800138000",
+		FormData: map[string]string{
+			"name": "张三",
+		},
+	}
+
+	first, err := logic.CreateOrder(req)
+	require.NoError(t, err)
+
+	_, err = logic.CreateOrder(req)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "请勿重复报名")
+
+	require.NoError(t, db.Model(&model.Order{}).Where("id = ?", first.Id).Update("status", "cancelled").Error)
+
+	second, err := logic.CreateOrder(req)
+	require.NoError(t, err)
+	assert.NotEqual(t, first.Id, second.Id)
+}
+
+func TestCreateOrderLogic_RedemptionQuotaFromCampaign(t *testing.T) {
+	db := setupTestDB(t)
+
+	campaign := &model.Campaign{
+		Name:                "五次卡",
+		Description:         "测试多次核销",
+		FormFields:          `[{"type":"text","name":"name","label":"姓名","required":true}]`,
+		RewardRule:          10,
+		StartTime:           time.Now().Add(-24 * time.Hour),
+		EndTime:             time.Now().Add(24 * time.Hour),
+		Status:              "active",
+		BrandId:             1,
+		RedemptionsPerOrder: 5,
+	}
+	require.NoError(t, db.Create(campaign).Error)
+
+	logic := NewCreateOrderLogic(context.Background(), newTestSvcCtx(db))
+	resp, err := logic.CreateOrder(&types.CreateOrderReq{
+		CampaignId: campaign.Id,
+		Phone:      "13800138000",
+		FormData:   map[string]string{"name": "张三"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 5, resp.RedemptionQuota)
+
+	var order model.Order
+	require.NoError(t, db.First(&order, resp.Id).Error)
+	assert.Equal(t, 5, order.RedemptionQuota)
+	assert.Equal(t, 0, order.RedeemedCount)
+}
+
+func TestCreateOrderLogic_TotalQuota(t *testing.T) {
+	db := setupTestDB(t)
+
+	campaign := &model.Campaign{
+		Name:        "限量活动",
+		Description: "测试名额限制",
+		FormFields:  `[{"type":"text","name":"name","label":"姓名","required":true}]`,
+		RewardRule:  10,
+		StartTime:   time.Now().Add(-24 * time.Hour),
+		EndTime:     time.Now().Add(24 * time.Hour),
+		Status:      "active",
+		BrandId:     1,
+		TotalQuota:  1,
+	}
+	require.NoError(t, db.Create(campaign).Error)
+
+	logic := NewCreateOrderLogic(context.Background(), newTestSvcCtx(db))
+	newReq := func(phone string) *types.CreateOrderReq {
+		return &types.CreateOrderReq{
+			CampaignId: campaign.Id,
+			Phone:      phone,
+			FormData:   map[string]string{"name": "张三"},
+		}
+	}
+
+	first, err := logic.CreateOrder(newReq("13800138001"))
+	require.NoError(t, err)
+
+	_, err = logic.CreateOrder(newReq("13800138002"))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "名额已满")
+
+	require.NoError(t, db.Model(&model.Order{}).Where("id = ?", first.Id).Update("status", "cancelled").Error)
+
+	_, err = logic.CreateOrder(newReq("13800138002"))
+	assert.NoError(t, err)
+}
+
+func TestCreateOrderLogic_RollsBackWhenCodeIssueFails(t *testing.T) {
+	db := setupTestDB(t)
+
+	campaign := &model.Campaign{
+		Name:        "限量活动",
+		Description: "测试核销码签发失败",
+		FormFields:  `[{"type":"text","name":"name","label":"姓名","required":true}]`,
+		RewardRule:  10,
+		StartTime:   time.Now().Add(-24 * time.Hour),
+		EndTime:     time.Now().Add(24 * time.Hour),
+		Status:      "active",
+		BrandId:     1,
+		TotalQuota:  1,
+	}
+	require.NoError(t, db.Create(campaign).Error)
+
+	req := &types.CreateOrderReq{
+		CampaignId: campaign.Id,
+		Phone:      "13800138001",
+		FormData:   map[string]string{"name": "张三"},
+	}
+
+	// 核销码服务不可用时订单整体回滚，不留下订单也不占用名额
+	_, err := NewCreateOrderLogic(context.Background(), &svc.ServiceContext{DB: db}).CreateOrder(req)
+	require.ErrorIs(t, err, errVerificationCodeIssue)
+
+	var count int64
+	require.NoError(t, db.Model(&model.Order{}).Where("campaign_id = ?", campaign.Id).Count(&count).Error)
+	assert.Equal(t, int64(0), count)
+
+	_, err = NewCreateOrderLogic(context.Background(), newTestSvcCtx(db)).CreateOrder(req)
+	assert.NoError(t, err)
+}
+
+func TestCreateOrderLogic_PublishesWebhookInTransaction(t *testing.T) {
+	db := setupTestDB(t)
+	campaign := createTestCampaign(t, db)
+	require.NoError(t, db.Create(&model.WebhookEndpoint{
+		BrandId: campaign.BrandId,
+		Url:     "http://erp.example.com/hook",
+		Secret:  "secret",
+		Events:  service.WebhookEventOrderCreated,
+		Status:  "active",
+	}).Error)
+
+	svcCtx := newTestSvcCtx(db)
+	svcCtx.Webhooks = service.NewWebhookPublisher()
+	req := &types.CreateOrderReq{
+		CampaignId: campaign.Id,
+		Phone:      "13800138001",
+		FormData:   map[string]string{"name": "张三"},
+	}
+
+	// 事件写入失败时订单回滚，不会出现有订单而无事件的情况
+	require.NoError(t, db.Migrator().RenameTable("webhook_deliveries", "webhook_deliveries_off"))
+	_, err := NewCreateOrderLogic(context.Background(), svcCtx).CreateOrder(req)
+	assert.Error(t, err)
+	var count int64
+	require.NoError(t, db.Model(&model.Order{}).Where("campaign_id = ?", campaign.Id).Count(&count).Error)
+	assert.Equal(t, int64(0), count)
+
+	require.NoError(t, db.Migrator().RenameTable("webhook_deliveries_off", "webhook_deliveries"))
+	resp, err := NewCreateOrderLogic(context.Background(), svcCtx).CreateOrder(req)
+	require.NoError(t, err)
+	var deliveries int64
+	require.NoError(t, db.Model(&model.WebhookDelivery{}).Where("event_type = ?", service.WebhookEventOrderCreated).Count(&deliveries).Error)
+	assert.Equal(t, int64(1), deliveries)
+	assert.NotZero(t, resp.Id)
+}
+
 func TestCreateOrderLogic_MissingRequiredField(t *testing.T) {
 	db := setupTestDB(t)
 
 	campaign := createTestCampaign(t, db)
-	logic := NewCreateOrderLogic(context.Background(), &svc.ServiceContext{DB: db})
+	logic := NewCreateOrderLogic(context.Background(), newTestSvcCtx(db))
 	req := &types.CreateOrderReq{
 		CampaignId: campaign.Id,
 		Phone:      "13800138000",
@@ -95,7 +273,7 @@ func TestCreateOrderLogic_FormFieldValidationFailed(t *testing.T) {
 	}
 	require.NoError(t, db.Create(campaign).Error)
 
-	logic := NewCreateOrderLogic(context.Background(), &svc.ServiceContext{DB: db})
+	logic := NewCreateOrderLogic(context.Background(), newTestSvcCtx(db))
 	req := &types.CreateOrderReq{
 		CampaignId: campaign.Id,
 		Phone:      "13800138000",
@@ -125,7 +303,7 @@ func TestCreateOrderLogic_CampaignInactiveStatus(t *testing.T) {
 	}
 	require.NoError(t, db.Create(campaign).Error)
 
-	logic := NewCreateOrderLogic(context.Background(), &svc.ServiceContext{DB: db})
+	logic := NewCreateOrderLogic(context.Background(), newTestSvcCtx(db))
 	req := &types.CreateOrderReq{
 		CampaignId: campaign.Id,
 		Phone:      "13800138000",
@@ -44,14 +44,16 @@ func (l *GetOrderLogic) GetOrder(orderId int64) (resp *types.OrderResp, err erro
 	}
 
 	resp = &types.OrderResp{
-		Id:         order.Id,
-		CampaignId: order.CampaignId,
-		Phone:      order.Phone,
-		FormData:   formData,
-		ReferrerId: order.ReferrerId,
-		Status:     order.Status,
-		Amount:     order.Amount,
-		CreatedAt:  order.CreatedAt.Format("2006-01-02 15:04:05"),
+		Id:              order.Id,
+		CampaignId:      order.CampaignId,
+		Phone:           order.Phone,
+		FormData:        formData,
+		ReferrerId:      order.ReferrerId,
+		Status:          order.Status,
+		Amount:          order.Amount,
+		CreatedAt:       order.CreatedAt.Format("2006-01-02 15:04:05"),
+		RedemptionQuota: order.RedemptionQuota,
+		RedeemedCount:   order.RedeemedCount,
 	}
 
 	return resp, nil
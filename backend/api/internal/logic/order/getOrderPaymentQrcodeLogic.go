@@ -0,0 +1,197 @@
+// Code scaffolded by goctl. Safe to edit.
+// goctl 1.9.2
+
+package order
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"dmh/api/internal/svc"
+	"dmh/api/internal/types"
+	"dmh/common/wechatpay"
+	"dmh/model"
+
+	"github.com/zeromicro/go-zero/core/logx"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 预支付结果剩余有效期不足时重新下单，避免用户扫到即将失效的二维码
+const prepayRefreshMargin = time.Minute
+
+type GetOrderPaymentQrcodeLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewGetOrderPaymentQrcodeLogic(ctx context.Context, svcCtx *svc.ServiceContext) *GetOrderPaymentQrcodeLogic {
+	return &GetOrderPaymentQrcodeLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+// GetOrderPaymentQrcode 为待支付订单创建微信 Native 支付单并返回二维码，有效期内复用已缓存的预支付结果
+func (l *GetOrderPaymentQrcodeLogic) GetOrderPaymentQrcode(req *types.OrderPaymentQrcodeReq) (resp *types.OrderPaymentQrcodeResp, err error) {
+	if l.svcCtx.WeChatPayService == nil || l.svcCtx.PosterService == nil {
+		return nil, errors.New("支付服务未初始化")
+	}
+
+	var order model.Order
+	if err := l.svcCtx.DB.Where("id = ? AND deleted_at IS NULL", req.Id).First(&order).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("订单不存在")
+		}
+		return nil, err
+	}
+	if err := checkOrderPayable(&order); err != nil {
+		return nil, err
+	}
+
+	var campaign model.Campaign
+	if err := l.svcCtx.DB.Where("id = ?", order.CampaignId).First(&campaign).Error; err != nil {
+		return nil, errors.New("活动不存在")
+	}
+
+	if order.Amount <= 0 {
+		paymentConfig, err := model.ParseCampaignPaymentConfig(campaign.PaymentConfig)
+		if err != nil {
+			return nil, errors.New("活动支付配置错误")
+		}
+		order.Amount = paymentConfig.Price()
+	}
+	if order.Amount <= 0 {
+		return nil, errors.New("活动无需支付")
+	}
+
+	if !prepayUsable(&order, time.Now()) {
+		renewed, err := l.renewPrepay(&order, &campaign)
+		if err != nil {
+			l.Errorf("获取订单支付二维码失败: orderId=%d, err=%v", req.Id, err)
+			return nil, err
+		}
+		order = *renewed
+	}
+
+	qrcode, err := l.svcCtx.PosterService.GenerateQRCodeAsBase64(order.PayCodeURL)
+	if err != nil {
+		l.Errorf("生成支付二维码失败: orderId=%d, err=%v", order.Id, err)
+		return nil, err
+	}
+
+	l.Infof("订单支付二维码已生成: orderId=%d, outTradeNo=%s, amount=%.2f", order.Id, order.OutTradeNo, order.Amount)
+
+	return &types.OrderPaymentQrcodeResp{
+		OrderId:      order.Id,
+		OutTradeNo:   order.OutTradeNo,
+		Amount:       order.Amount,
+		CampaignName: campaign.Name,
+		CodeUrl:      order.PayCodeURL,
+		QrcodeBase64: qrcode,
+		ExpireAt:     order.PayExpiresAt.Format("2006-01-02T15:04:05"),
+	}, nil
+}
+
+// renewPrepay 重新下单。先关闭旧支付单，保证用户无法再支付旧二维码；调用微信时不持有订单行锁，
+// 写回时若订单已被并发请求换单或状态已变化，则关闭本次生成的支付单并以库中结果为准
+func (l *GetOrderPaymentQrcodeLogic) renewPrepay(order *model.Order, campaign *model.Campaign) (*model.Order, error) {
+	payService := l.svcCtx.WeChatPayService
+	previous := order.OutTradeNo
+	if previous != "" {
+		err := payService.CloseOrder(previous)
+		if errors.Is(err, wechatpay.ErrOrderPaid) {
+			l.Infof("旧支付单已支付，等待支付通知: orderId=%d, outTradeNo=%s", order.Id, previous)
+			return nil, errors.New("订单已支付，正在确认支付结果")
+		}
+		if err != nil {
+			l.Errorf("关闭旧支付单失败: orderId=%d, outTradeNo=%s, err=%v", order.Id, previous, err)
+			return nil, fmt.Errorf("关闭旧支付单失败，请稍后重试: %w", err)
+		}
+	}
+
+	now := time.Now()
+	outTradeNo := fmt.Sprintf("DMH%d%s", order.Id, now.Format("20060102150405"))
+	payResp, err := payService.CreateNativePay(outTradeNo, int64(math.Round(order.Amount*100)), paymentBody(campaign.Name))
+	if err != nil {
+		l.Errorf("创建微信支付订单失败: orderId=%d, outTradeNo=%s, err=%v", order.Id, outTradeNo, err)
+		return nil, fmt.Errorf("创建支付订单失败: %w", err)
+	}
+	expiresAt := now.Add(payService.PrepayTTL())
+
+	var current model.Order
+	stale := false
+	err = l.svcCtx.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND deleted_at IS NULL", order.Id).First(&current).Error; err != nil {
+			return err
+		}
+		if current.PayStatus != "unpaid" || current.Status == "cancelled" || current.OutTradeNo != previous {
+			stale = true
+			return nil
+		}
+
+		current.Amount = order.Amount
+		current.OutTradeNo = outTradeNo
+		current.PayCodeURL = payResp.CodeURL
+		current.PayExpiresAt = &expiresAt
+		return tx.Model(&model.Order{}).Where("id = ?", current.Id).Updates(map[string]interface{}{
+			"amount":         current.Amount,
+			"out_trade_no":   current.OutTradeNo,
+			"pay_code_url":   current.PayCodeURL,
+			"pay_expires_at": current.PayExpiresAt,
+		}).Error
+	})
+	if err != nil || stale {
+		// 本次生成的支付单未返回给用户，关闭失败也不会被支付，仅记录日志
+		if closeErr := payService.CloseOrder(outTradeNo); closeErr != nil {
+			l.Errorf("关闭未使用的支付单失败: orderId=%d, outTradeNo=%s, err=%v", order.Id, outTradeNo, closeErr)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if stale {
+		if err := checkOrderPayable(&current); err != nil {
+			return nil, err
+		}
+		if !prepayUsable(&current, time.Now()) {
+			return nil, errors.New("支付单正在更新，请稍后重试")
+		}
+	}
+
+	return &current, nil
+}
+
+func checkOrderPayable(order *model.Order) error {
+	if order.Status == "cancelled" {
+		return errors.New("订单已取消")
+	}
+	if order.PayStatus != "unpaid" {
+		return errors.New("订单已支付")
+	}
+	return nil
+}
+
+// prepayUsable 已缓存的预支付结果在刷新余量之外仍有效
+func prepayUsable(order *model.Order, now time.Time) bool {
+	return order.OutTradeNo != "" && order.PayCodeURL != "" && order.PayExpiresAt != nil &&
+		order.PayExpiresAt.After(now.Add(prepayRefreshMargin))
+}
+
+// paymentBody 微信支付商品描述，长度上限 128 字节
+func paymentBody(campaignName string) string {
+	runes := []rune(campaignName)
+	if len(runes) > 40 {
+		runes = runes[:40]
+	}
+	if len(runes) == 0 {
+		return "活动报名"
+	}
+	return string(runes)
+}
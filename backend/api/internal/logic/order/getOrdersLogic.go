@@ -43,14 +43,16 @@ func (l *GetOrdersLogic) GetOrders() (resp *types.OrderListResp, err error) {
 		}
 
 		orders = append(orders, types.OrderResp{
-			Id:         order.Id,
-			CampaignId: order.CampaignId,
-			Phone:      order.Phone,
-			FormData:   formData,
-			ReferrerId: order.ReferrerId,
-			Status:     order.Status,
-			Amount:     order.Amount,
-			CreatedAt:  order.CreatedAt.Format("2006-01-02 15:04:05"),
+			Id:              order.Id,
+			CampaignId:      order.CampaignId,
+			Phone:           order.Phone,
+			FormData:        formData,
+			ReferrerId:      order.ReferrerId,
+			Status:          order.Status,
+			Amount:          order.Amount,
+			CreatedAt:       order.CreatedAt.Format("2006-01-02 15:04:05"),
+			RedemptionQuota: order.RedemptionQuota,
+			RedeemedCount:   order.RedeemedCount,
 		})
 	}
 
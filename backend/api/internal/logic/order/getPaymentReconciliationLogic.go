@@ -0,0 +1,55 @@
+// Code scaffolded by goctl. Safe to edit.
+// goctl 1.9.2
+
+package order
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"dmh/api/internal/middleware"
+	"dmh/api/internal/svc"
+	"dmh/api/internal/types"
+	"dmh/model"
+
+	"github.com/zeromicro/go-zero/core/logx"
+	"gorm.io/gorm"
+)
+
+type GetPaymentReconciliationLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewGetPaymentReconciliationLogic(ctx context.Context, svcCtx *svc.ServiceContext) *GetPaymentReconciliationLogic {
+	return &GetPaymentReconciliationLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+func (l *GetPaymentReconciliationLogic) GetPaymentReconciliation(req *types.GetPaymentReconciliationReq) (resp *types.PaymentReconciliationResp, err error) {
+	if !middleware.IsPlatformAdmin(l.ctx) {
+		return nil, fmt.Errorf("权限不足，仅平台管理员可查看对账报告")
+	}
+
+	var report model.PaymentReconciliation
+	if err := l.svcCtx.DB.First(&report, req.Id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("对账报告不存在")
+		}
+		l.Errorf("查询对账报告失败: %v", err)
+		return nil, fmt.Errorf("查询对账报告失败: %v", err)
+	}
+
+	var items []model.PaymentReconciliationItem
+	if err := l.svcCtx.DB.Where("reconciliation_id = ?", report.Id).Order("id ASC").Find(&items).Error; err != nil {
+		l.Errorf("查询对账明细失败: %v", err)
+		return nil, fmt.Errorf("查询对账明细失败: %v", err)
+	}
+
+	return buildPaymentReconciliationResp(&report, items), nil
+}
@@ -5,6 +5,7 @@ package order
 
 import (
 	"context"
+	"fmt"
 
 	"dmh/api/internal/svc"
 	"dmh/api/internal/types"
@@ -27,10 +28,38 @@ func NewGetVerificationRecordsLogic(ctx context.Context, svcCtx *svc.ServiceCont
 	}
 }
 
-func (l *GetVerificationRecordsLogic) GetVerificationRecords() (resp *types.VerificationRecordsListResp, err error) {
-	// 查询所有核销记录
+func (l *GetVerificationRecordsLogic) GetVerificationRecords(req *types.VerificationRecordsReq) (resp *types.VerificationRecordsListResp, err error) {
+	if !hasVerificationPermission(l.ctx) {
+		return nil, fmt.Errorf("权限不足，仅品牌管理员或平台管理员可查看核销记录")
+	}
+
+	// 查询核销记录，指定订单时即该订单每次核销与撤销的使用记录
+	query := l.svcCtx.DB.Model(&model.VerificationRecord{})
+	if req.OrderId > 0 {
+		var order model.Order
+		if err := l.svcCtx.DB.Select("id", "campaign_id").Where("id = ?", req.OrderId).First(&order).Error; err != nil {
+			return nil, fmt.Errorf("订单不存在")
+		}
+		if err := authorizeVerification(l.ctx, l.Logger, l.svcCtx, &order, "查看核销记录"); err != nil {
+			return nil, err
+		}
+		query = query.Where("order_id = ?", req.OrderId)
+	} else {
+		// 未指定订单时只返回可核销活动的记录
+		campaignIds, all, err := verifiableCampaignIds(l.ctx, l.svcCtx)
+		if err != nil {
+			l.Errorf("查询可核销活动失败: %v", err)
+			return nil, err
+		}
+		if !all {
+			if len(campaignIds) == 0 {
+				return &types.VerificationRecordsListResp{Records: []types.VerificationRecordResp{}}, nil
+			}
+			query = query.Where("order_id IN (?)", l.svcCtx.DB.Model(&model.Order{}).Select("id").Where("campaign_id IN ?", campaignIds))
+		}
+	}
 	var records []model.VerificationRecord
-	if err := l.svcCtx.DB.Order("created_at DESC").Find(&records).Error; err != nil {
+	if err := query.Order("created_at DESC, id DESC").Find(&records).Error; err != nil {
 		l.Errorf("查询核销记录失败: %v", err)
 		return nil, err
 	}
@@ -51,12 +80,16 @@ func (l *GetVerificationRecordsLogic) GetVerificationRecords() (resp *types.Veri
 			VerificationCode:   r.VerificationCode,
 			VerificationMethod: r.VerificationMethod,
 			Remark:             r.Remark,
+			RedemptionNo:       r.RedemptionNo,
 			CreatedAt:          r.CreatedAt.Format("2006-01-02 15:04:05"),
 		}
 
 		if r.VerifiedBy != nil {
 			record.VerifiedBy = *r.VerifiedBy
 		}
+		if r.DeviceID != nil {
+			record.DeviceId = *r.DeviceID
+		}
 
 		recordList = append(recordList, record)
 	}
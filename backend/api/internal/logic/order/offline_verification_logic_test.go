@@ -0,0 +1,231 @@
+package order
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"dmh/api/internal/types"
+	"dmh/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetVerificationSnapshotLogic_SignedHashes(t *testing.T) {
+	db := setupTestDB(t)
+	svcCtx := newTestSvcCtx(db)
+	campaign := createTestCampaign(t, db)
+
+	active := insertOrderForVerification(t, svcCtx, "paid", "unverified")
+	refunded := insertOrderForVerification(t, svcCtx, "paid", "unverified")
+	require.NoError(t, db.Model(&model.Order{}).Where("id IN ?", []int64{active.Id, refunded.Id}).
+		Update("campaign_id", campaign.Id).Error)
+	require.NoError(t, db.Model(refunded).Update("pay_status", "refunded").Error)
+
+	logic := NewGetVerificationSnapshotLogic(verificationAdminCtx(1), svcCtx)
+	resp, err := logic.GetVerificationSnapshot(&types.VerificationSnapshotReq{CampaignId: campaign.Id})
+	require.NoError(t, err)
+
+	require.Len(t, resp.Orders, 1)
+	assert.Equal(t, active.Id, resp.Orders[0].OrderId)
+	assert.Equal(t, hashVerificationCode(active.VerificationCode), resp.Orders[0].CodeHash)
+	assert.Equal(t, "138****8000", resp.Orders[0].Phone)
+
+	// 设备只用公钥校验去掉 keyId 与 signature 后的快照
+	unsigned := *resp
+	unsigned.KeyId, unsigned.Signature = "", ""
+	payload, err := json.Marshal(unsigned)
+	require.NoError(t, err)
+	publicKey, err := base64.StdEncoding.DecodeString(testSnapshotSigner.PublicKey())
+	require.NoError(t, err)
+	signature, err := base64.StdEncoding.DecodeString(resp.Signature)
+	require.NoError(t, err)
+	assert.Equal(t, "s1", resp.KeyId)
+	assert.True(t, ed25519.Verify(publicKey, payload, signature))
+}
+
+func TestGetVerificationSnapshotLogic_PermissionDenied(t *testing.T) {
+	db := setupTestDB(t)
+
+	logic := NewGetVerificationSnapshotLogic(context.Background(), newTestSvcCtx(db))
+	_, err := logic.GetVerificationSnapshot(&types.VerificationSnapshotReq{CampaignId: 1})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "权限不足")
+}
+
+func TestBatchVerifyOrdersLogic_AppliesWithDeviceTime(t *testing.T) {
+	db := setupTestDB(t)
+	svcCtx := newTestSvcCtx(db)
+	order := insertOrderForVerification(t, svcCtx, "paid", "unverified")
+
+	deviceTime := time.Now().Add(-2 * time.Hour).Truncate(time.Second)
+	logic := NewBatchVerifyOrdersLogic(verificationAdminCtx(777), svcCtx)
+	resp, err := logic.BatchVerifyOrders(&types.BatchVerifyOrdersReq{
+		DeviceId: "pos-01",
+		Items: []types.OfflineVerificationItem{
+			{ClientId: "c-1", Code: order.VerificationCode, VerifiedAt: deviceTime.Format(time.RFC3339)},
+			{ClientId: "c-2", Code: "invalid_code", VerifiedAt: deviceTime.Format(time.RFC3339)},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, resp.Applied)
+	assert.Equal(t, 1, resp.Invalid)
+	assert.Equal(t, "applied", resp.Results[0].Status)
+	assert.Equal(t, "invalid_code", resp.Results[1].Reason)
+
+	var fresh model.Order
+	require.NoError(t, db.First(&fresh, order.Id).Error)
+	assert.Equal(t, "verified", fresh.VerificationStatus)
+	require.NotNil(t, fresh.VerifiedAt)
+	assert.WithinDuration(t, deviceTime, *fresh.VerifiedAt, time.Second)
+
+	var record model.VerificationRecord
+	require.NoError(t, db.Where("order_id = ?", order.Id).First(&record).Error)
+	assert.Equal(t, "offline", record.VerificationMethod)
+	require.NotNil(t, record.DeviceID)
+	require.NotNil(t, record.ClientRef)
+	assert.Equal(t, "pos-01", *record.DeviceID)
+	assert.Equal(t, "c-1", *record.ClientRef)
+}
+
+func TestBatchVerifyOrdersLogic_IdempotentAndConflicts(t *testing.T) {
+	db := setupTestDB(t)
+	svcCtx := newTestSvcCtx(db)
+	order := insertOrderForVerification(t, svcCtx, "paid", "unverified")
+	refunded := insertOrderForVerification(t, svcCtx, "paid", "unverified")
+	require.NoError(t, db.Model(refunded).Update("pay_status", "refunded").Error)
+
+	verifiedAt := time.Now().Add(-time.Hour).Format(time.RFC3339)
+	upload := func(deviceId, clientId, code string) types.OfflineVerificationResult {
+		logic := NewBatchVerifyOrdersLogic(verificationAdminCtx(777), svcCtx)
+		resp, err := logic.BatchVerifyOrders(&types.BatchVerifyOrdersReq{
+			DeviceId: deviceId,
+			Items:    []types.OfflineVerificationItem{{ClientId: clientId, Code: code, VerifiedAt: verifiedAt}},
+		})
+		require.NoError(t, err)
+		require.Len(t, resp.Results, 1)
+		return resp.Results[0]
+	}
+
+	assert.Equal(t, "applied", upload("pos-01", "c-1", order.VerificationCode).Status)
+
+	// 网络重试重复上传
+	assert.Equal(t, "duplicate", upload("pos-01", "c-1", order.VerificationCode).Status)
+
+	// 另一台设备离线期间也核销了同一订单
+	result := upload("pos-02", "c-9", order.VerificationCode)
+	assert.Equal(t, "conflict", result.Status)
+	assert.Equal(t, "already_verified", result.Reason)
+	assert.NotEmpty(t, result.VerifiedAt)
+
+	result = upload("pos-01", "c-2", refunded.VerificationCode)
+	assert.Equal(t, "conflict", result.Status)
+	assert.Equal(t, "refunded", result.Reason)
+
+	var count int64
+	require.NoError(t, db.Model(&model.VerificationRecord{}).Where("order_id = ?", order.Id).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestBatchVerifyOrdersLogic_DedupeScopedByBrand(t *testing.T) {
+	db := setupTestDB(t)
+	svcCtx := newTestSvcCtx(db)
+	brandOne := insertOrderForVerification(t, svcCtx, "paid", "unverified")
+	brandTwo := insertOrderForVerification(t, svcCtx, "paid", "unverified")
+	require.NoError(t, db.Model(&model.Campaign{}).Where("id = ?", brandTwo.CampaignId).Update("brand_id", 2).Error)
+
+	brandTwoCtx := context.WithValue(context.Background(), "roles", []string{"brand_admin"})
+	brandTwoCtx = context.WithValue(brandTwoCtx, "brandIds", []int64{2})
+	brandTwoCtx = context.WithValue(brandTwoCtx, "userId", int64(888))
+
+	verifiedAt := time.Now().Add(-time.Hour).Format(time.RFC3339)
+	upload := func(ctx context.Context, code string) types.OfflineVerificationResult {
+		resp, err := NewBatchVerifyOrdersLogic(ctx, svcCtx).BatchVerifyOrders(&types.BatchVerifyOrdersReq{
+			DeviceId: "pos-01",
+			Items:    []types.OfflineVerificationItem{{ClientId: "c-1", Code: code, VerifiedAt: verifiedAt}},
+		})
+		require.NoError(t, err)
+		require.Len(t, resp.Results, 1)
+		return resp.Results[0]
+	}
+
+	assert.Equal(t, "applied", upload(verificationAdminCtx(777), brandOne.VerificationCode).Status)
+
+	// 另一品牌的设备碰巧使用相同的设备ID与核销ID，不应被判为重复
+	result := upload(brandTwoCtx, brandTwo.VerificationCode)
+	assert.Equal(t, "applied", result.Status)
+	assert.Equal(t, brandTwo.Id, result.OrderId)
+
+	// 重放其他品牌的核销记录先鉴权，不泄露其订单与核销时间
+	result = upload(brandTwoCtx, brandOne.VerificationCode)
+	assert.Equal(t, "invalid", result.Status)
+	assert.Equal(t, "forbidden", result.Reason)
+	assert.Zero(t, result.OrderId)
+	assert.Empty(t, result.VerifiedAt)
+}
+
+func TestBatchVerifyOrdersLogic_RejectsStaleDeviceTime(t *testing.T) {
+	db := setupTestDB(t)
+	svcCtx := newTestSvcCtx(db)
+	svcCtx.Config.Verification.SnapshotTTLMinutes = 60
+	order := insertOrderForVerification(t, svcCtx, "paid", "unverified")
+
+	upload := func(snapshotAt string, verifiedAt time.Time) types.OfflineVerificationResult {
+		logic := NewBatchVerifyOrdersLogic(verificationAdminCtx(777), svcCtx)
+		resp, err := logic.BatchVerifyOrders(&types.BatchVerifyOrdersReq{
+			DeviceId:   "pos-01",
+			SnapshotAt: snapshotAt,
+			Items: []types.OfflineVerificationItem{
+				{ClientId: verifiedAt.Format(time.RFC3339Nano), Code: order.VerificationCode, VerifiedAt: verifiedAt.Format(time.RFC3339)},
+			},
+		})
+		require.NoError(t, err)
+		require.Len(t, resp.Results, 1)
+		return resp.Results[0]
+	}
+
+	// 早于快照有效期
+	result := upload("", time.Now().Add(-2*time.Hour))
+	assert.Equal(t, "invalid", result.Status)
+	assert.Equal(t, "stale_time", result.Reason)
+
+	// 早于设备所用快照的生成时间
+	snapshotAt := time.Now().Add(-10 * time.Minute)
+	result = upload(snapshotAt.Format(time.RFC3339), snapshotAt.Add(-5*time.Minute))
+	assert.Equal(t, "invalid", result.Status)
+	assert.Equal(t, "stale_time", result.Reason)
+
+	var fresh model.Order
+	require.NoError(t, db.First(&fresh, order.Id).Error)
+	assert.Equal(t, "unverified", fresh.VerificationStatus)
+
+	assert.Equal(t, "applied", upload(snapshotAt.Format(time.RFC3339), snapshotAt.Add(time.Minute)).Status)
+}
+
+func TestBatchVerifyOrdersLogic_RejectsInvalidBatch(t *testing.T) {
+	db := setupTestDB(t)
+	logic := NewBatchVerifyOrdersLogic(verificationAdminCtx(1), newTestSvcCtx(db))
+
+	_, err := logic.BatchVerifyOrders(&types.BatchVerifyOrdersReq{DeviceId: "", Items: []types.OfflineVerificationItem{{ClientId: "c"}}})
+	assert.Error(t, err)
+
+	_, err = logic.BatchVerifyOrders(&types.BatchVerifyOrdersReq{DeviceId: "pos-01"})
+	assert.Error(t, err)
+
+	_, err = logic.BatchVerifyOrders(&types.BatchVerifyOrdersReq{
+		DeviceId: "pos-01",
+		Items:    make([]types.OfflineVerificationItem, maxOfflineVerificationItems+1),
+	})
+	assert.Error(t, err)
+
+	_, err = logic.BatchVerifyOrders(&types.BatchVerifyOrdersReq{
+		DeviceId:   "pos-01",
+		SnapshotAt: time.Now().Add(time.Hour).Format(time.RFC3339),
+		Items:      []types.OfflineVerificationItem{{ClientId: "c"}},
+	})
+	assert.Error(t, err)
+}
@@ -0,0 +1,290 @@
+package order
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"dmh/api/internal/service"
+	"dmh/api/internal/svc"
+	"dmh/common/syncadapter"
+	"dmh/model"
+
+	"github.com/zeromicro/go-zero/core/logx"
+	"gorm.io/gorm"
+)
+
+// inboundAuditUser 外部变更审计日志的操作人
+const inboundAuditUser = "external_sync"
+
+// inboundChangeApplier 按本系统的业务规则应用外部系统的订单与会员变更
+type inboundChangeApplier struct {
+	logx.Logger
+	svcCtx *svc.ServiceContext
+}
+
+// NewInboundChangeApplier 创建外部变更的应用方，由 InboundImporter 调用
+func NewInboundChangeApplier(svcCtx *svc.ServiceContext) syncadapter.InboundApplier {
+	return &inboundChangeApplier{
+		Logger: logx.WithContext(context.Background()),
+		svcCtx: svcCtx,
+	}
+}
+
+// Apply 应用一条变更，应用、冲突与拒绝均记录审计日志
+func (a *inboundChangeApplier) Apply(ctx context.Context, change *syncadapter.InboundChange, policy string) (syncadapter.InboundResult, error) {
+	var (
+		result   syncadapter.InboundResult
+		resource = "order"
+		id       = change.OrderId
+		err      error
+	)
+	switch change.Type {
+	case syncadapter.InboundOrderRefunded:
+		result, err = a.applyRefund(change)
+	case syncadapter.InboundOrderVerified:
+		result, err = a.applyVerification(change, "verified", policy)
+	case syncadapter.InboundOrderUnverified:
+		result, err = a.applyVerification(change, "cancelled", policy)
+	case syncadapter.InboundMemberUpdated:
+		resource, id = "member", change.MemberId
+		result, err = a.applyMember(change, policy)
+	default:
+		result = inboundResult(syncadapter.InboundStatusRejected, "未知的变更类型: %s", change.Type)
+	}
+	if err != nil {
+		return result, err
+	}
+
+	audit := service.NewAuditService(a.svcCtx.DB)
+	auditCtx := &service.AuditContext{Username: inboundAuditUser}
+	action := "external_" + change.Type
+	resourceId := strconv.FormatInt(id, 10)
+	switch result.Status {
+	case syncadapter.InboundStatusApplied:
+		err = audit.LogUserAction(auditCtx, action, resource, resourceId, map[string]interface{}{
+			"watermark": change.Watermark,
+			"changedAt": change.ChangedAt,
+			"detail":    result.Detail,
+		})
+	case syncadapter.InboundStatusConflict, syncadapter.InboundStatusRejected:
+		err = audit.LogFailedAction(auditCtx, action, resource, resourceId, result.Detail)
+	}
+	if err != nil {
+		a.Errorf("记录外部变更审计日志失败: type=%s, id=%d, err=%v", change.Type, id, err)
+	}
+
+	return result, nil
+}
+
+// applyRefund 外部系统退款走与管理员退款相同的流程。退款已在外部发生，不受冲突规则约束
+func (a *inboundChangeApplier) applyRefund(change *syncadapter.InboundChange) (syncadapter.InboundResult, error) {
+	order, result, err := a.loadOrder(change.OrderId)
+	if order == nil {
+		return result, err
+	}
+	if order.PayStatus == "refunded" {
+		return inboundResult(syncadapter.InboundStatusSkipped, "订单已退款"), nil
+	}
+
+	refundNo := change.Reference
+	if refundNo == "" {
+		refundNo = "EXT" + change.Watermark
+	}
+	refund, duplicated, err := applyOrderRefund(a.svcCtx.DB, a.Logger, orderRefundInput{
+		OrderId:  order.Id,
+		RefundNo: refundNo,
+		Amount:   change.Amount,
+		Source:   "external",
+		Reason:   "外部系统退款",
+		Seats:    service.NewCampaignSeatService(a.svcCtx.DB, a.svcCtx.SeatCounter),
+		Outbox:   a.svcCtx.Outbox,
+	})
+	if errors.Is(err, errOrderAlreadyRefunded) || duplicated {
+		return inboundResult(syncadapter.InboundStatusSkipped, "退款已处理: %s", refundNo), nil
+	}
+	if errors.Is(err, errRefundAlreadyFailed) {
+		return inboundResult(syncadapter.InboundStatusConflict, "退款单已失败，未覆盖: %s", refundNo), nil
+	}
+	if err != nil {
+		return inboundResult(syncadapter.InboundStatusRejected, "退款失败: %v", err), nil
+	}
+	return inboundResult(syncadapter.InboundStatusApplied, "退款 %.2f，退款单号 %s", refund.Amount, refund.RefundNo), nil
+}
+
+// applyVerification 核销或取消核销，与手动核销一样写入核销记录
+func (a *inboundChangeApplier) applyVerification(change *syncadapter.InboundChange, status, policy string) (syncadapter.InboundResult, error) {
+	order, result, err := a.loadOrder(change.OrderId)
+	if order == nil {
+		return result, err
+	}
+	// 外部系统只同步整单状态：核销即用完全部次数，取消即撤销全部已用次数
+	skip := order.VerificationStatus == "verified"
+	if status != "verified" {
+		skip = !skip && order.RedeemedCount == 0
+	}
+	if skip {
+		return inboundResult(syncadapter.InboundStatusSkipped, "订单核销状态已是 %s", order.VerificationStatus), nil
+	}
+	if status == "verified" && order.PayStatus == "refunded" {
+		return inboundResult(syncadapter.InboundStatusRejected, "订单已退款，无法核销"), nil
+	}
+	if status == "verified" && order.Status == "cancelled" {
+		return inboundResult(syncadapter.InboundStatusRejected, "订单已取消，无法核销"), nil
+	}
+
+	// 本地最后一次核销状态变更，外部导入产生的记录不算本地修改
+	var last model.VerificationRecord
+	var localChangedAt *time.Time
+	err = a.svcCtx.DB.Where("order_id = ? AND verification_method <> ?", order.Id, "external").
+		Order("created_at DESC").First(&last).Error
+	if err == nil {
+		localChangedAt = &last.CreatedAt
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return result, err
+	}
+
+	apply, conflict := syncadapter.ResolveConflict(policy, change, localChangedAt)
+	if !apply {
+		return inboundResult(syncadapter.InboundStatusConflict, "本地于 %s 修改过核销状态（%s），晚于外部变更",
+			localChangedAt.Format(time.DateTime), order.VerificationStatus), nil
+	}
+
+	var verifiedAt *time.Time
+	redeemed := 0
+	if status == "verified" {
+		now := time.Now()
+		verifiedAt = &now
+		redeemed = order.RedemptionQuota
+	}
+	err = a.svcCtx.DB.Transaction(func(tx *gorm.DB) error {
+		// 与 redeemOrder 相同，按读取到的核销状态做条件更新，期间本地核销、取消或退款时不覆盖
+		query := tx.Model(&model.Order{}).
+			Where("id = ? AND redeemed_count = ? AND verification_status = ?", order.Id, order.RedeemedCount, order.VerificationStatus)
+		if status == "verified" {
+			query = query.Where("status <> ? AND pay_status <> ?", "cancelled", "refunded")
+		}
+		updated := query.Updates(map[string]interface{}{
+			"verification_status": status,
+			"verified_at":         verifiedAt,
+			"verified_by":         nil,
+			"redeemed_count":      redeemed,
+		})
+		if updated.Error != nil {
+			return updated.Error
+		}
+		if updated.RowsAffected == 0 {
+			return errRedemptionConflict
+		}
+		if err := tx.Create(&model.VerificationRecord{
+			OrderID:            order.Id,
+			VerificationStatus: status,
+			VerifiedAt:         verifiedAt,
+			VerificationMethod: "external",
+			Remark:             "外部系统同步",
+		}).Error; err != nil {
+			return err
+		}
+		if status != "verified" {
+			return nil
+		}
+		order.VerificationStatus, order.VerifiedAt, order.RedeemedCount = status, verifiedAt, redeemed
+		return a.svcCtx.Webhooks.PublishOrder(tx, service.WebhookEventOrderVerified, order)
+	})
+	if errors.Is(err, errRedemptionConflict) {
+		return inboundResult(syncadapter.InboundStatusConflict, "订单核销状态已在本地变化，请重新同步"), nil
+	}
+	if err != nil {
+		return result, err
+	}
+
+	detail := "核销状态更新为 " + status
+	if conflict {
+		detail += "，覆盖本地修改"
+	}
+	return inboundResult(syncadapter.InboundStatusApplied, "%s", detail), nil
+}
+
+// applyMember 更新会员手机号与状态，外部未提供的字段保持不变
+func (a *inboundChangeApplier) applyMember(change *syncadapter.InboundChange, policy string) (syncadapter.InboundResult, error) {
+	var member model.Member
+	if err := a.svcCtx.DB.Where("id = ? AND deleted_at IS NULL", change.MemberId).First(&member).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return inboundResult(syncadapter.InboundStatusRejected, "会员不存在: %d", change.MemberId), nil
+		}
+		return syncadapter.InboundResult{}, err
+	}
+
+	updates := map[string]interface{}{}
+	if change.Phone != "" && change.Phone != member.Phone {
+		// 手机号不能与其他会员重复，外部系统可能仍持有已换号会员的旧数据
+		var taken int64
+		if err := a.svcCtx.DB.Model(&model.Member{}).
+			Where("phone = ? AND id <> ? AND deleted_at IS NULL", change.Phone, member.ID).
+			Count(&taken).Error; err != nil {
+			return syncadapter.InboundResult{}, err
+		}
+		if taken > 0 {
+			return inboundResult(syncadapter.InboundStatusRejected, "手机号已被其他会员使用: %s", change.Phone), nil
+		}
+		updates["phone"] = change.Phone
+	}
+	if change.Status != "" && change.Status != member.Status {
+		if change.Status != "active" && change.Status != "disabled" {
+			return inboundResult(syncadapter.InboundStatusRejected, "无效的会员状态: %s", change.Status), nil
+		}
+		updates["status"] = change.Status
+	}
+	if len(updates) == 0 {
+		return inboundResult(syncadapter.InboundStatusSkipped, "会员信息无变化"), nil
+	}
+
+	apply, conflict := syncadapter.ResolveConflict(policy, change, &member.UpdatedAt)
+	if !apply {
+		return inboundResult(syncadapter.InboundStatusConflict, "本地于 %s 修改过会员信息，晚于外部变更",
+			member.UpdatedAt.Format(time.DateTime)), nil
+	}
+
+	// 以外部变更时间作为修改时间，避免同一批后续的外部变更被误判为冲突
+	if !change.ChangedAt.IsZero() {
+		updates["updated_at"] = change.ChangedAt
+	}
+	if err := a.svcCtx.DB.Model(&member).Updates(updates).Error; err != nil {
+		return syncadapter.InboundResult{}, err
+	}
+
+	detail := fmt.Sprintf("更新会员字段 %v", changedMemberFields(updates))
+	if conflict {
+		detail += "，覆盖本地修改"
+	}
+	return inboundResult(syncadapter.InboundStatusApplied, "%s", detail), nil
+}
+
+// loadOrder 查询订单，订单不存在时返回 rejected 结果
+func (a *inboundChangeApplier) loadOrder(orderId int64) (*model.Order, syncadapter.InboundResult, error) {
+	var order model.Order
+	if err := a.svcCtx.DB.Where("id = ? AND deleted_at IS NULL", orderId).First(&order).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, inboundResult(syncadapter.InboundStatusRejected, "订单不存在: %d", orderId), nil
+		}
+		return nil, syncadapter.InboundResult{}, err
+	}
+	return &order, syncadapter.InboundResult{}, nil
+}
+
+func inboundResult(status, format string, args ...interface{}) syncadapter.InboundResult {
+	return syncadapter.InboundResult{Status: status, Detail: fmt.Sprintf(format, args...)}
+}
+
+// changedMemberFields 按固定顺序列出更新的会员字段
+func changedMemberFields(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for _, key := range []string{"phone", "status"} {
+		if _, ok := m[key]; ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
@@ -0,0 +1,129 @@
+package order
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"dmh/api/internal/svc"
+	"dmh/common/syncadapter"
+	"dmh/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInboundChangeApplier_Refund(t *testing.T) {
+	db := setupTestDB(t)
+	order, _ := createPaidOrderWithReward(t, db, 50.00)
+	applier := NewInboundChangeApplier(&svc.ServiceContext{DB: db})
+
+	change := &syncadapter.InboundChange{Watermark: "1", Type: syncadapter.InboundOrderRefunded, OrderId: order.Id, Reference: "ERP-RF-1"}
+	result, err := applier.Apply(context.Background(), change, syncadapter.ConflictLatestWins)
+	require.NoError(t, err)
+	assert.Equal(t, syncadapter.InboundStatusApplied, result.Status)
+
+	var refund model.OrderRefund
+	require.NoError(t, db.Where("refund_no = ?", "ERP-RF-1").First(&refund).Error)
+	assert.Equal(t, "external", refund.Source)
+	assert.Equal(t, 20.00, refund.ClawbackAmount)
+
+	var audits int64
+	db.Model(&model.AuditLog{}).Where("username = ? AND action = ?", inboundAuditUser, "external_order_refunded").Count(&audits)
+	assert.Equal(t, int64(1), audits)
+
+	result, err = applier.Apply(context.Background(), change, syncadapter.ConflictLatestWins)
+	require.NoError(t, err)
+	assert.Equal(t, syncadapter.InboundStatusSkipped, result.Status)
+}
+
+func TestInboundChangeApplier_VerificationConflict(t *testing.T) {
+	db := setupTestDB(t)
+	order, _ := createPaidOrderWithReward(t, db, 50.00)
+	applier := NewInboundChangeApplier(&svc.ServiceContext{DB: db})
+
+	// 本地在外部变更之后手动核销过
+	require.NoError(t, db.Model(order).Update("verification_status", "verified").Error)
+	require.NoError(t, db.Create(&model.VerificationRecord{OrderID: order.Id, VerificationStatus: "verified", VerificationMethod: "manual"}).Error)
+	change := &syncadapter.InboundChange{
+		Watermark: "2",
+		Type:      syncadapter.InboundOrderUnverified,
+		OrderId:   order.Id,
+		ChangedAt: time.Now().Add(-time.Hour),
+	}
+
+	result, err := applier.Apply(context.Background(), change, syncadapter.ConflictLatestWins)
+	require.NoError(t, err)
+	assert.Equal(t, syncadapter.InboundStatusConflict, result.Status)
+
+	var updated model.Order
+	require.NoError(t, db.First(&updated, order.Id).Error)
+	assert.Equal(t, "verified", updated.VerificationStatus)
+
+	result, err = applier.Apply(context.Background(), change, syncadapter.ConflictExternalWins)
+	require.NoError(t, err)
+	assert.Equal(t, syncadapter.InboundStatusApplied, result.Status)
+	assert.Contains(t, result.Detail, "覆盖本地修改")
+
+	require.NoError(t, db.First(&updated, order.Id).Error)
+	assert.Equal(t, "cancelled", updated.VerificationStatus)
+
+	var record model.VerificationRecord
+	require.NoError(t, db.Where("order_id = ? AND verification_method = ?", order.Id, "external").First(&record).Error)
+	assert.Equal(t, "cancelled", record.VerificationStatus)
+}
+
+func TestInboundChangeApplier_Member(t *testing.T) {
+	db := setupTestDB(t)
+	member := &model.Member{UnionID: "inbound-union", Phone: "13800138000", Status: "active"}
+	require.NoError(t, db.Create(member).Error)
+	applier := NewInboundChangeApplier(&svc.ServiceContext{DB: db})
+
+	changedAt := time.Now().Add(time.Minute).Truncate(time.Second)
+	result, err := applier.Apply(context.Background(), &syncadapter.InboundChange{
+		Watermark: "3", Type: syncadapter.InboundMemberUpdated, MemberId: member.ID, Phone: "13900139000", ChangedAt: changedAt,
+	}, syncadapter.ConflictLatestWins)
+	require.NoError(t, err)
+	assert.Equal(t, syncadapter.InboundStatusApplied, result.Status)
+
+	var updated model.Member
+	require.NoError(t, db.First(&updated, member.ID).Error)
+	assert.Equal(t, "13900139000", updated.Phone)
+
+	result, err = applier.Apply(context.Background(), &syncadapter.InboundChange{
+		Watermark: "4", Type: syncadapter.InboundMemberUpdated, MemberId: member.ID, Status: "locked",
+	}, syncadapter.ConflictLatestWins)
+	require.NoError(t, err)
+	assert.Equal(t, syncadapter.InboundStatusRejected, result.Status)
+
+	// 手机号已属于其他会员
+	other := &model.Member{UnionID: "inbound-union-2", Phone: "13700137000", Status: "active"}
+	require.NoError(t, db.Create(other).Error)
+	result, err = applier.Apply(context.Background(), &syncadapter.InboundChange{
+		Watermark: "5", Type: syncadapter.InboundMemberUpdated, MemberId: member.ID, Phone: other.Phone, ChangedAt: changedAt.Add(time.Minute),
+	}, syncadapter.ConflictLatestWins)
+	require.NoError(t, err)
+	assert.Equal(t, syncadapter.InboundStatusRejected, result.Status)
+	assert.Contains(t, result.Detail, "手机号已被其他会员使用")
+
+	require.NoError(t, db.First(&updated, member.ID).Error)
+	assert.Equal(t, "13900139000", updated.Phone)
+}
+
+func TestInboundChangeApplier_VerificationRejectsCancelledOrder(t *testing.T) {
+	db := setupTestDB(t)
+	order, _ := createPaidOrderWithReward(t, db, 50.00)
+	applier := NewInboundChangeApplier(&svc.ServiceContext{DB: db})
+	require.NoError(t, db.Model(&model.Order{}).Where("id = ?", order.Id).Update("status", "cancelled").Error)
+
+	result, err := applier.Apply(context.Background(), &syncadapter.InboundChange{
+		Watermark: "6", Type: syncadapter.InboundOrderVerified, OrderId: order.Id,
+	}, syncadapter.ConflictExternalWins)
+	require.NoError(t, err)
+	assert.Equal(t, syncadapter.InboundStatusRejected, result.Status)
+
+	var updated model.Order
+	require.NoError(t, db.First(&updated, order.Id).Error)
+	assert.Equal(t, 0, updated.RedeemedCount)
+	assert.NotEqual(t, "verified", updated.VerificationStatus)
+}
@@ -5,7 +5,6 @@ import (
 	"sync"
 	"testing"
 
-	"dmh/api/internal/svc"
 	"dmh/api/internal/types"
 	"dmh/model"
 
@@ -16,7 +15,7 @@ import (
 func TestOrderIntegration_CreateVerifyScanConsistency(t *testing.T) {
 	db := setupTestDB(t)
 	campaign := createTestCampaign(t, db)
-	svcCtx := &svc.ServiceContext{DB: db}
+	svcCtx := newTestSvcCtx(db)
 
 	createLogic := NewCreateOrderLogic(context.Background(), svcCtx)
 	createResp, err := createLogic.CreateOrder(&types.CreateOrderReq{
@@ -33,15 +32,14 @@ func TestOrderIntegration_CreateVerifyScanConsistency(t *testing.T) {
 	require.NoError(t, db.First(&created, createResp.Id).Error)
 	require.NotEmpty(t, created.VerificationCode)
 
-	verifyCtx := context.WithValue(context.Background(), "roles", []string{"brand_admin"})
-	verifyCtx = context.WithValue(verifyCtx, "userId", int64(9001))
+	verifyCtx := verificationAdminCtx(9001)
 	verifyLogic := NewVerifyOrderLogic(verifyCtx, svcCtx)
 	verifyResp, err := verifyLogic.VerifyOrder(&types.VerifyOrderReq{Code: created.VerificationCode, Remark: "integration verify"})
 	require.NoError(t, err)
 	require.NotNil(t, verifyResp)
 	assert.Equal(t, "verified", verifyResp.Status)
 
-	scanLogic := NewScanOrderLogic(context.Background(), svcCtx)
+	scanLogic := NewScanOrderLogic(verifyCtx, svcCtx)
 	scanResp, err := scanLogic.ScanOrder(&types.ScanOrderReq{Code: created.VerificationCode})
 	require.NoError(t, err)
 	require.NotNil(t, scanResp)
@@ -54,7 +52,7 @@ func TestOrderIntegration_CreateVerifyScanConsistency(t *testing.T) {
 func TestOrderIntegration_ConcurrentDuplicateCreateGuard(t *testing.T) {
 	db := setupTestDB(t)
 	campaign := createTestCampaign(t, db)
-	svcCtx := &svc.ServiceContext{DB: db}
+	svcCtx := newTestSvcCtx(db)
 
 	req := &types.CreateOrderReq{
 		CampaignId: campaign.Id,
@@ -3,9 +3,12 @@ package order
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"testing"
 	"time"
 
+	"dmh/api/internal/service"
 	"dmh/api/internal/svc"
 	"dmh/api/internal/testutil"
 	"dmh/api/internal/types"
@@ -21,6 +24,24 @@ func setupTestDB(t *testing.T) *gorm.DB {
 	return db
 }
 
+var testVerificationTokens, _ = service.NewVerificationTokenService(service.VerificationTokenConfig{
+	ActiveKeyId: "test",
+	Keys:        []service.VerificationKey{{Id: "test", Secret: "test-verification-secret"}},
+})
+
+var testSnapshotSigner, _ = service.NewSnapshotSigner("s1", "5Rg2QegicqWBLnMgQXbmjomJSf9jGjlCP4TAjipUpjk=")
+
+// newTestSvcCtx 带核销码服务与快照签名的测试上下文
+func newTestSvcCtx(db *gorm.DB) *svc.ServiceContext {
+	return &svc.ServiceContext{DB: db, VerificationTokens: testVerificationTokens, SnapshotSigner: testSnapshotSigner}
+}
+
+func issueTestVerificationCode(t *testing.T, orderId int64) string {
+	code, err := testVerificationTokens.Issue(orderId, time.Now())
+	require.NoError(t, err)
+	return code
+}
+
 func createTestCampaign(t *testing.T, db *gorm.DB) *model.Campaign {
 	campaign := &model.Campaign{
 		Name:        "测试活动",
@@ -46,7 +67,7 @@ func TestCreateOrderLogic_CreateOrder_Success(t *testing.T) {
 	campaign := createTestCampaign(t, db)
 
 	ctx := context.Background()
-	svcCtx := &svc.ServiceContext{DB: db}
+	svcCtx := newTestSvcCtx(db)
 	logic := NewCreateOrderLogic(ctx, svcCtx)
 
 	req := &types.CreateOrderReq{
@@ -64,6 +85,13 @@ func TestCreateOrderLogic_CreateOrder_Success(t *testing.T) {
 	assert.NotNil(t, resp)
 	assert.Equal(t, campaign.Id, resp.CampaignId)
 	assert.Equal(t, "13800138000", resp.Phone)
+	assert.NotEmpty(t, resp.VerificationCode)
+	assert.Len(t, resp.ShortCode, 6)
+
+	var saved model.Order
+	require.NoError(t, db.First(&saved, resp.Id).Error)
+	require.NotNil(t, saved.ShortCode)
+	assert.Equal(t, resp.ShortCode, *saved.ShortCode)
 	assert.Equal(t, "pending", resp.Status)
 	assert.NotZero(t, resp.Id)
 }
@@ -73,7 +101,7 @@ func TestCreateOrderLogic_InvalidPhone(t *testing.T) {
 	campaign := createTestCampaign(t, db)
 
 	ctx := context.Background()
-	svcCtx := &svc.ServiceContext{DB: db}
+	svcCtx := newTestSvcCtx(db)
 	logic := NewCreateOrderLogic(ctx, svcCtx)
 
 	req := &types.CreateOrderReq{
@@ -96,7 +124,7 @@ func TestCreateOrderLogic_DuplicateOrder(t *testing.T) {
 	campaign := createTestCampaign(t, db)
 
 	ctx := context.Background()
-	svcCtx := &svc.ServiceContext{DB: db}
+	svcCtx := newTestSvcCtx(db)
 	logic := NewCreateOrderLogic(ctx, svcCtx)
 
 	req := &types.CreateOrderReq{
@@ -123,16 +151,15 @@ func TestCreateOrderLogic_DuplicateOrder(t *testing.T) {
 // VerifyOrderLogic tests
 func TestVerifyOrderLogic_VerifyOrder_Success(t *testing.T) {
 	db := setupTestDB(t)
+	campaign := createTestCampaign(t, db)
 
 	// Create a test order
-	createLogic := NewCreateOrderLogic(context.Background(), &svc.ServiceContext{DB: db})
 	orderId := int64(1)
-	timestamp := time.Now().Unix()
-	verificationCode := createLogic.generateVerificationCode(orderId, "13800138000", timestamp)
+	verificationCode := issueTestVerificationCode(t, orderId)
 
 	order := &model.Order{
 		Id:                 orderId,
-		CampaignId:         1,
+		CampaignId:         campaign.Id,
 		Phone:              "13800138000",
 		FormData:           `{"name":"张三"}`,
 		Status:             "paid",
@@ -142,10 +169,8 @@ func TestVerifyOrderLogic_VerifyOrder_Success(t *testing.T) {
 	}
 	db.Create(order)
 
-	ctx := context.Background()
-	ctx = context.WithValue(ctx, "userId", int64(100))
-	ctx = context.WithValue(ctx, "roles", []string{"brand_admin"})
-	svcCtx := &svc.ServiceContext{DB: db}
+	ctx := verificationAdminCtx(100)
+	svcCtx := newTestSvcCtx(db)
 	logic := NewVerifyOrderLogic(ctx, svcCtx)
 
 	req := &types.VerifyOrderReq{
@@ -162,16 +187,15 @@ func TestVerifyOrderLogic_VerifyOrder_Success(t *testing.T) {
 
 func TestVerifyOrderLogic_VerifyOrder_AlreadyVerified(t *testing.T) {
 	db := setupTestDB(t)
+	campaign := createTestCampaign(t, db)
 
 	// Create a verified order
-	createLogic := NewCreateOrderLogic(context.Background(), &svc.ServiceContext{DB: db})
 	orderId := int64(1)
-	timestamp := time.Now().Unix()
-	verificationCode := createLogic.generateVerificationCode(orderId, "13800138000", timestamp)
+	verificationCode := issueTestVerificationCode(t, orderId)
 
 	order := &model.Order{
 		Id:                 orderId,
-		CampaignId:         1,
+		CampaignId:         campaign.Id,
 		Phone:              "13800138000",
 		FormData:           `{"name":"张三"}`,
 		Status:             "paid",
@@ -181,10 +205,8 @@ func TestVerifyOrderLogic_VerifyOrder_AlreadyVerified(t *testing.T) {
 	}
 	db.Create(order)
 
-	ctx := context.Background()
-	ctx = context.WithValue(ctx, "userId", int64(100))
-	ctx = context.WithValue(ctx, "roles", []string{"brand_admin"})
-	svcCtx := &svc.ServiceContext{DB: db}
+	ctx := verificationAdminCtx(100)
+	svcCtx := newTestSvcCtx(db)
 	logic := NewVerifyOrderLogic(ctx, svcCtx)
 
 	req := &types.VerifyOrderReq{
@@ -200,19 +222,19 @@ func TestVerifyOrderLogic_VerifyOrder_AlreadyVerified(t *testing.T) {
 
 // Test verification code generation
 func TestCreateOrderLogic_GenerateVerificationCode(t *testing.T) {
-	logic := &CreateOrderLogic{}
+	logic := NewCreateOrderLogic(context.Background(), newTestSvcCtx(nil))
 
-	orderId := int64(1)
-	timestamp := int64(1234567890)
-	code := logic.generateVerificationCode(orderId, "13800138000", timestamp)
+	code, err := logic.generateVerificationCode(1, time.Now())
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(code, "v2.test."))
+	assert.NotContains(t, code, "13800138000")
 
-	assert.NotEmpty(t, code)
-	assert.Contains(t, code, "1_13800138000_")
-	assert.Contains(t, code, "_1234567890_")
+	token, err := testVerificationTokens.Parse(code)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), token.OrderId)
 
-	// Parse and verify
-	parts := len(code)
-	assert.Greater(t, parts, 0)
+	_, err = NewCreateOrderLogic(context.Background(), &svc.ServiceContext{}).generateVerificationCode(1, time.Now())
+	assert.Error(t, err)
 }
 
 func TestPaymentCallbackLogic_Success(t *testing.T) {
@@ -263,7 +285,7 @@ func TestPaymentCallbackLogic_Success(t *testing.T) {
 	require.NoError(t, db.Create(order).Error)
 
 	ctx := context.Background()
-	svcCtx := &svc.ServiceContext{DB: db}
+	svcCtx := newTestSvcCtx(db)
 	logic := NewPaymentCallbackLogic(ctx, svcCtx)
 
 	req := &types.PaymentCallbackReq{
@@ -298,7 +320,7 @@ func TestPaymentCallbackLogic_OrderNotFound(t *testing.T) {
 	db := setupTestDB(t)
 
 	ctx := context.Background()
-	svcCtx := &svc.ServiceContext{DB: db}
+	svcCtx := newTestSvcCtx(db)
 	logic := NewPaymentCallbackLogic(ctx, svcCtx)
 
 	req := &types.PaymentCallbackReq{
@@ -328,7 +350,7 @@ func TestPaymentCallbackLogic_AlreadyPaid(t *testing.T) {
 	db.Create(order)
 
 	ctx := context.Background()
-	svcCtx := &svc.ServiceContext{DB: db}
+	svcCtx := newTestSvcCtx(db)
 	logic := NewPaymentCallbackLogic(ctx, svcCtx)
 
 	req := &types.PaymentCallbackReq{
@@ -371,7 +393,7 @@ func TestPaymentCallbackLogic_NoReferrer(t *testing.T) {
 	db.Create(order)
 
 	ctx := context.Background()
-	svcCtx := &svc.ServiceContext{DB: db}
+	svcCtx := newTestSvcCtx(db)
 	logic := NewPaymentCallbackLogic(ctx, svcCtx)
 
 	req := &types.PaymentCallbackReq{
@@ -422,7 +444,7 @@ func TestPaymentCallbackLogic_DistributionDisabled(t *testing.T) {
 	db.Create(order)
 
 	ctx := context.Background()
-	svcCtx := &svc.ServiceContext{DB: db}
+	svcCtx := newTestSvcCtx(db)
 	logic := NewPaymentCallbackLogic(ctx, svcCtx)
 
 	req := &types.PaymentCallbackReq{
@@ -473,7 +495,7 @@ func TestPaymentCallbackLogic_ReferrerNotFound(t *testing.T) {
 	db.Create(order)
 
 	ctx := context.Background()
-	svcCtx := &svc.ServiceContext{DB: db}
+	svcCtx := newTestSvcCtx(db)
 	logic := NewPaymentCallbackLogic(ctx, svcCtx)
 
 	req := &types.PaymentCallbackReq{
@@ -488,16 +510,135 @@ func TestPaymentCallbackLogic_ReferrerNotFound(t *testing.T) {
 	assert.Contains(t, err.Error(), "Referrer not found")
 }
 
+func TestPaymentCallbackLogic_MultiLevelChain(t *testing.T) {
+	db := setupTestDB(t)
+	require.NoError(t, db.Create(&model.Brand{Id: 1, Name: "Brand1", Status: "active"}).Error)
+
+	rates := `{"level1": 10, "level2": 5, "level3": 2}`
+	campaign := &model.Campaign{
+		Name:                "三级分销活动",
+		FormFields:          `[]`,
+		RewardRule:          1.00,
+		StartTime:           time.Now().Add(-1 * time.Hour),
+		EndTime:             time.Now().Add(24 * time.Hour),
+		Status:              "active",
+		BrandId:             1,
+		EnableDistribution:  true,
+		DistributionLevel:   3,
+		DistributionRewards: &rates,
+	}
+	require.NoError(t, db.Create(campaign).Error)
+
+	top := &model.Distributor{UserId: 300, BrandId: 1, Level: 3, Status: "active"}
+	require.NoError(t, db.Create(top).Error)
+	middle := &model.Distributor{UserId: 200, BrandId: 1, Level: 2, Status: "active", ParentId: &top.Id}
+	require.NoError(t, db.Create(middle).Error)
+	direct := &model.Distributor{UserId: 100, BrandId: 1, Level: 1, Status: "active", ParentId: &middle.Id}
+	require.NoError(t, db.Create(direct).Error)
+
+	require.NoError(t, db.Create(&model.UserBalance{UserId: 200, Balance: 1.00, TotalReward: 1.00}).Error)
+
+	order := &model.Order{
+		CampaignId: campaign.Id,
+		Phone:      "13800138001",
+		FormData:   `{}`,
+		ReferrerId: 100,
+		Status:     "pending",
+		PayStatus:  "unpaid",
+		Amount:     200.00,
+	}
+	require.NoError(t, db.Create(order).Error)
+
+	logic := NewPaymentCallbackLogic(context.Background(), newTestSvcCtx(db))
+	err := logic.PaymentCallback(&types.PaymentCallbackReq{OrderId: order.Id, TradeNo: "TRADE_ML", Amount: 200.00})
+	require.NoError(t, err)
+
+	var rewards []model.DistributorReward
+	require.NoError(t, db.Where("order_id = ?", order.Id).Order("level ASC").Find(&rewards).Error)
+	require.Len(t, rewards, 3)
+	assert.Equal(t, direct.Id, rewards[0].DistributorId)
+	assert.Equal(t, 20.00, rewards[0].Amount)
+	assert.Equal(t, middle.Id, rewards[1].DistributorId)
+	assert.Equal(t, 10.00, rewards[1].Amount)
+	assert.Equal(t, top.Id, rewards[2].DistributorId)
+	assert.Equal(t, 4.00, rewards[2].Amount)
+
+	var updatedOrder model.Order
+	require.NoError(t, db.First(&updatedOrder, order.Id).Error)
+	assert.Equal(t, fmt.Sprintf("%d,%d,%d", direct.Id, middle.Id, top.Id), updatedOrder.DistributorPath)
+
+	var middleBalance model.UserBalance
+	require.NoError(t, db.Where("user_id = ?", 200).First(&middleBalance).Error)
+	assert.Equal(t, 11.00, middleBalance.Balance)
+	assert.Equal(t, int64(1), middleBalance.Version)
+
+	var topBalance model.UserBalance
+	require.NoError(t, db.Where("user_id = ?", 300).First(&topBalance).Error)
+	assert.Equal(t, 4.00, topBalance.Balance)
+}
+
+func TestPaymentCallbackLogic_ChainBoundedByCampaignLevel(t *testing.T) {
+	db := setupTestDB(t)
+	require.NoError(t, db.Create(&model.Brand{Id: 1, Name: "Brand1", Status: "active"}).Error)
+
+	campaign := &model.Campaign{
+		Name:               "一级分销活动",
+		FormFields:         `[]`,
+		RewardRule:         1.00,
+		StartTime:          time.Now().Add(-1 * time.Hour),
+		EndTime:            time.Now().Add(24 * time.Hour),
+		Status:             "active",
+		BrandId:            1,
+		EnableDistribution: true,
+		DistributionLevel:  1,
+	}
+	require.NoError(t, db.Create(campaign).Error)
+	require.NoError(t, db.Create(&model.DistributorLevelReward{BrandId: 1, Level: 1, RewardPercentage: 10}).Error)
+	require.NoError(t, db.Create(&model.DistributorLevelReward{BrandId: 1, Level: 2, RewardPercentage: 5}).Error)
+
+	parent := &model.Distributor{UserId: 200, BrandId: 1, Level: 2, Status: "active"}
+	require.NoError(t, db.Create(parent).Error)
+	direct := &model.Distributor{UserId: 100, BrandId: 1, Level: 1, Status: "active", ParentId: &parent.Id}
+	require.NoError(t, db.Create(direct).Error)
+
+	order := &model.Order{CampaignId: campaign.Id, Phone: "13800138002", FormData: `{}`, ReferrerId: 100, Status: "pending", PayStatus: "unpaid", Amount: 100.00}
+	require.NoError(t, db.Create(order).Error)
+
+	logic := NewPaymentCallbackLogic(context.Background(), newTestSvcCtx(db))
+	require.NoError(t, logic.PaymentCallback(&types.PaymentCallbackReq{OrderId: order.Id, TradeNo: "TRADE_L1", Amount: 100.00}))
+
+	var rewardCount int64
+	db.Model(&model.DistributorReward{}).Where("order_id = ?", order.Id).Count(&rewardCount)
+	assert.Equal(t, int64(1), rewardCount)
+
+	var updatedOrder model.Order
+	require.NoError(t, db.First(&updatedOrder, order.Id).Error)
+	assert.Equal(t, fmt.Sprintf("%d", direct.Id), updatedOrder.DistributorPath)
+}
+
+func TestParseDistributionRewards(t *testing.T) {
+	levelKeys := `{"level1": 10, "level2": 8, "level3": 5, "level4": 1}`
+	rates := parseDistributionRewards(&levelKeys)
+	assert.Equal(t, map[int]float64{1: 10, 2: 8, 3: 5}, rates)
+
+	numericKeys := `{"1": 10.5, "2": 5}`
+	rates = parseDistributionRewards(&numericKeys)
+	assert.Equal(t, map[int]float64{1: 10.5, 2: 5}, rates)
+
+	invalid := `not-json`
+	assert.Empty(t, parseDistributionRewards(&invalid))
+	assert.Empty(t, parseDistributionRewards(nil))
+}
+
 func TestScanOrderLogic_Success(t *testing.T) {
 	db := setupTestDB(t)
+	campaign := createTestCampaign(t, db)
 
-	createLogic := NewCreateOrderLogic(context.Background(), &svc.ServiceContext{DB: db})
-	timestamp := time.Now().Unix()
-	verificationCode := createLogic.generateVerificationCode(1, "13800138000", timestamp)
+	verificationCode := issueTestVerificationCode(t, 1)
 
 	order := &model.Order{
 		Id:                 1,
-		CampaignId:         1,
+		CampaignId:         campaign.Id,
 		Phone:              "13800138000",
 		FormData:           `{"name":"张三","age":"25"}`,
 		Status:             "paid",
@@ -507,8 +648,8 @@ func TestScanOrderLogic_Success(t *testing.T) {
 	}
 	db.Create(order)
 
-	ctx := context.Background()
-	svcCtx := &svc.ServiceContext{DB: db}
+	ctx := verificationAdminCtx(1)
+	svcCtx := newTestSvcCtx(db)
 	logic := NewScanOrderLogic(ctx, svcCtx)
 
 	req := &types.ScanOrderReq{
@@ -530,7 +671,7 @@ func TestScanOrderLogic_InvalidCode(t *testing.T) {
 	db := setupTestDB(t)
 
 	ctx := context.Background()
-	svcCtx := &svc.ServiceContext{DB: db}
+	svcCtx := newTestSvcCtx(db)
 	logic := NewScanOrderLogic(ctx, svcCtx)
 
 	req := &types.ScanOrderReq{
@@ -547,12 +688,10 @@ func TestScanOrderLogic_InvalidCode(t *testing.T) {
 func TestScanOrderLogic_OrderNotFound(t *testing.T) {
 	db := setupTestDB(t)
 
-	createLogic := NewCreateOrderLogic(context.Background(), &svc.ServiceContext{DB: db})
-	timestamp := time.Now().Unix()
-	verificationCode := createLogic.generateVerificationCode(999, "13800138000", timestamp)
+	verificationCode := issueTestVerificationCode(t, 999)
 
-	ctx := context.Background()
-	svcCtx := &svc.ServiceContext{DB: db}
+	ctx := verificationAdminCtx(1)
+	svcCtx := newTestSvcCtx(db)
 	logic := NewScanOrderLogic(ctx, svcCtx)
 
 	req := &types.ScanOrderReq{
@@ -581,7 +720,7 @@ func TestGetOrderLogic_Success(t *testing.T) {
 	db.Create(order)
 
 	ctx := context.Background()
-	svcCtx := &svc.ServiceContext{DB: db}
+	svcCtx := newTestSvcCtx(db)
 	logic := NewGetOrderLogic(ctx, svcCtx)
 
 	resp, err := logic.GetOrder(1)
@@ -600,7 +739,7 @@ func TestGetOrderLogic_NotFound(t *testing.T) {
 	db := setupTestDB(t)
 
 	ctx := context.Background()
-	svcCtx := &svc.ServiceContext{DB: db}
+	svcCtx := newTestSvcCtx(db)
 	logic := NewGetOrderLogic(ctx, svcCtx)
 
 	resp, err := logic.GetOrder(999)
@@ -634,7 +773,7 @@ func TestGetOrdersLogic_Success(t *testing.T) {
 	db.Create(order2)
 
 	ctx := context.Background()
-	svcCtx := &svc.ServiceContext{DB: db}
+	svcCtx := newTestSvcCtx(db)
 	logic := NewGetOrdersLogic(ctx, svcCtx)
 
 	resp, err := logic.GetOrders()
@@ -649,7 +788,7 @@ func TestGetOrdersLogic_Empty(t *testing.T) {
 	db := setupTestDB(t)
 
 	ctx := context.Background()
-	svcCtx := &svc.ServiceContext{DB: db}
+	svcCtx := newTestSvcCtx(db)
 	logic := NewGetOrdersLogic(ctx, svcCtx)
 
 	resp, err := logic.GetOrders()
@@ -686,11 +825,11 @@ func TestGetVerificationRecordsLogic_Success(t *testing.T) {
 	}
 	db.Create(record2)
 
-	ctx := context.Background()
-	svcCtx := &svc.ServiceContext{DB: db}
+	ctx := context.WithValue(context.Background(), "roles", []string{"platform_admin"})
+	svcCtx := newTestSvcCtx(db)
 	logic := NewGetVerificationRecordsLogic(ctx, svcCtx)
 
-	resp, err := logic.GetVerificationRecords()
+	resp, err := logic.GetVerificationRecords(&types.VerificationRecordsReq{})
 
 	assert.NoError(t, err)
 	assert.NotNil(t, resp)
@@ -701,11 +840,11 @@ func TestGetVerificationRecordsLogic_Success(t *testing.T) {
 func TestGetVerificationRecordsLogic_Empty(t *testing.T) {
 	db := setupTestDB(t)
 
-	ctx := context.Background()
-	svcCtx := &svc.ServiceContext{DB: db}
+	ctx := verificationAdminCtx(1)
+	svcCtx := newTestSvcCtx(db)
 	logic := NewGetVerificationRecordsLogic(ctx, svcCtx)
 
-	resp, err := logic.GetVerificationRecords()
+	resp, err := logic.GetVerificationRecords(&types.VerificationRecordsReq{})
 
 	assert.NoError(t, err)
 	assert.NotNil(t, resp)
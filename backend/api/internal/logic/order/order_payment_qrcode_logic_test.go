@@ -0,0 +1,141 @@
+package order
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"dmh/api/internal/svc"
+	"dmh/api/internal/types"
+	"dmh/common/poster"
+	"dmh/common/wechatpay"
+	"dmh/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func newPaymentQrcodeSvcCtx(t *testing.T, db *gorm.DB) *svc.ServiceContext {
+	return &svc.ServiceContext{
+		DB:               db,
+		WeChatPayService: wechatpay.NewService(&wechatpay.Config{AppID: "wx-app", MchID: "mch-1", APIKey: "key123", MockEnabled: true, CacheTTL: 600}),
+		PosterService:    poster.NewService(t.TempDir(), ""),
+	}
+}
+
+func createPricedOrder(t *testing.T, db *gorm.DB, paymentConfig string) *model.Order {
+	campaign := &model.Campaign{
+		Name:          "付费活动",
+		FormFields:    `[]`,
+		StartTime:     time.Now().Add(-1 * time.Hour),
+		EndTime:       time.Now().Add(24 * time.Hour),
+		Status:        "active",
+		BrandId:       1,
+		PaymentConfig: &paymentConfig,
+	}
+	require.NoError(t, db.Create(campaign).Error)
+
+	order := &model.Order{CampaignId: campaign.Id, Phone: "13800138030", FormData: `{}`, Status: "pending", PayStatus: "unpaid"}
+	require.NoError(t, db.Create(order).Error)
+	return order
+}
+
+func TestGetOrderPaymentQrcodeLogic_CreatesAndCachesPrepay(t *testing.T) {
+	db := setupTestDB(t)
+	order := createPricedOrder(t, db, `{"requirePayment":true,"paymentType":"full","paymentAmount":88.8}`)
+
+	logic := NewGetOrderPaymentQrcodeLogic(context.Background(), newPaymentQrcodeSvcCtx(t, db))
+	first, err := logic.GetOrderPaymentQrcode(&types.OrderPaymentQrcodeReq{Id: order.Id})
+	require.NoError(t, err)
+	assert.Equal(t, 88.8, first.Amount)
+	assert.True(t, strings.HasPrefix(first.OutTradeNo, "DMH"))
+	assert.Contains(t, first.CodeUrl, first.OutTradeNo)
+	assert.True(t, strings.HasPrefix(first.QrcodeBase64, "data:image/png;base64,"))
+
+	second, err := logic.GetOrderPaymentQrcode(&types.OrderPaymentQrcodeReq{Id: order.Id})
+	require.NoError(t, err)
+	assert.Equal(t, first.OutTradeNo, second.OutTradeNo)
+	assert.Equal(t, first.ExpireAt, second.ExpireAt)
+
+	var updated model.Order
+	require.NoError(t, db.First(&updated, order.Id).Error)
+	assert.Equal(t, first.OutTradeNo, updated.OutTradeNo)
+	assert.Equal(t, 88.8, updated.Amount)
+	require.NotNil(t, updated.PayExpiresAt)
+
+	found, err := findOrderByTradeRef(db, "", first.OutTradeNo)
+	require.NoError(t, err)
+	assert.Equal(t, order.Id, found.Id)
+}
+
+func TestGetOrderPaymentQrcodeLogic_RenewsNearExpiry(t *testing.T) {
+	db := setupTestDB(t)
+	order := createPricedOrder(t, db, `{"requirePayment":true,"paymentAmount":10}`)
+	expiresAt := time.Now().Add(30 * time.Second)
+	require.NoError(t, db.Model(order).Updates(map[string]interface{}{
+		"out_trade_no":   "DMH_OLD",
+		"pay_code_url":   "weixin://wxpay/bizpayurl?pr=DMH_OLD",
+		"pay_expires_at": expiresAt,
+	}).Error)
+
+	logic := NewGetOrderPaymentQrcodeLogic(context.Background(), newPaymentQrcodeSvcCtx(t, db))
+	resp, err := logic.GetOrderPaymentQrcode(&types.OrderPaymentQrcodeReq{Id: order.Id})
+	require.NoError(t, err)
+	assert.NotEqual(t, "DMH_OLD", resp.OutTradeNo)
+
+	var updated model.Order
+	require.NoError(t, db.First(&updated, order.Id).Error)
+	assert.Equal(t, resp.OutTradeNo, updated.OutTradeNo)
+}
+
+func TestGetOrderPaymentQrcodeLogic_OldPrepayAlreadyPaid(t *testing.T) {
+	db := setupTestDB(t)
+	order := createPricedOrder(t, db, `{"requirePayment":true,"paymentAmount":10}`)
+	require.NoError(t, db.Model(order).Update("out_trade_no", "DMH_OLD").Error)
+
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("<xml><return_code>SUCCESS</return_code><result_code>FAIL</result_code><err_code>ORDERPAID</err_code></xml>"))
+	}))
+	defer gateway.Close()
+
+	svcCtx := newPaymentQrcodeSvcCtx(t, db)
+	svcCtx.WeChatPayService = wechatpay.NewService(&wechatpay.Config{AppID: "wx-app", MchID: "mch-1", APIKey: "key123", UnifiedOrderURL: gateway.URL})
+
+	_, err := NewGetOrderPaymentQrcodeLogic(context.Background(), svcCtx).GetOrderPaymentQrcode(&types.OrderPaymentQrcodeReq{Id: order.Id})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "订单已支付")
+
+	var updated model.Order
+	require.NoError(t, db.First(&updated, order.Id).Error)
+	assert.Equal(t, "DMH_OLD", updated.OutTradeNo)
+}
+
+func TestGetOrderPaymentQrcodeLogic_FreeCampaign(t *testing.T) {
+	db := setupTestDB(t)
+	order := createPricedOrder(t, db, `{"requirePayment":false}`)
+
+	logic := NewGetOrderPaymentQrcodeLogic(context.Background(), newPaymentQrcodeSvcCtx(t, db))
+	_, err := logic.GetOrderPaymentQrcode(&types.OrderPaymentQrcodeReq{Id: order.Id})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "活动无需支付")
+}
+
+func TestGetOrderPaymentQrcodeLogic_PaidOrder(t *testing.T) {
+	db := setupTestDB(t)
+	order := createPricedOrder(t, db, `{"requirePayment":true,"paymentAmount":10}`)
+	require.NoError(t, db.Model(order).Update("pay_status", "paid").Error)
+
+	logic := NewGetOrderPaymentQrcodeLogic(context.Background(), newPaymentQrcodeSvcCtx(t, db))
+	_, err := logic.GetOrderPaymentQrcode(&types.OrderPaymentQrcodeReq{Id: order.Id})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "订单已支付")
+}
+
+func TestPaymentBody(t *testing.T) {
+	assert.Equal(t, "活动报名", paymentBody(""))
+	assert.Len(t, []rune(paymentBody(strings.Repeat("活", 60))), 40)
+}
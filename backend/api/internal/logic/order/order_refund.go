@@ -0,0 +1,355 @@
+package order
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"dmh/api/internal/service"
+	"dmh/common/syncadapter"
+	"dmh/model"
+
+	"github.com/zeromicro/go-zero/core/logx"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 退款记录状态
+const (
+	refundStatusPending   = "pending"
+	refundStatusSucceeded = "succeeded"
+	refundStatusFailed    = "failed"
+)
+
+var errOrderAlreadyRefunded = errors.New("订单已退款")
+
+// errRefundAlreadyFailed 退款单已标记失败，不再接受成功结果
+var errRefundAlreadyFailed = errors.New("退款单已失败")
+
+// orderRefundInput 退款处理参数，管理员退款与微信退款通知共用
+type orderRefundInput struct {
+	OrderId    int64
+	RefundNo   string
+	Amount     float64
+	Source     string
+	Reason     string
+	OperatorId *int64
+	Seats      *service.CampaignSeatService // 全额退款取消订单后释放活动名额
+	Outbox     *syncadapter.Outbox          // 与退款同一事务记录外部同步事件
+}
+
+// reserveOrderRefund 调用微信退款前登记待处理退款单并占用可退金额。
+// 同一退款单号重复发起时返回已有记录且 duplicated 为 true，待处理的单据由调用方用原单号重试微信退款。
+func reserveOrderRefund(db *gorm.DB, input orderRefundInput) (refund *model.OrderRefund, order *model.Order, duplicated bool, err error) {
+	input.RefundNo = strings.TrimSpace(input.RefundNo)
+	if input.RefundNo == "" {
+		return nil, nil, false, errors.New("退款单号不能为空")
+	}
+
+	order = &model.Order{}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := lockRefundOrder(tx, input.OrderId, order); err != nil {
+			return err
+		}
+
+		existing, err := findRefundByNo(tx, input.OrderId, input.RefundNo)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			if existing.Status == refundStatusFailed {
+				return fmt.Errorf("退款单已失败，请使用新的退款单号重新发起: %s", input.RefundNo)
+			}
+			refund = existing
+			duplicated = true
+			return nil
+		}
+
+		if err := checkRefundablePayStatus(order); err != nil {
+			return err
+		}
+
+		var pending float64
+		if err := tx.Model(&model.OrderRefund{}).
+			Where("order_id = ? AND status = ?", order.Id, refundStatusPending).
+			Select("COALESCE(SUM(amount), 0)").Scan(&pending).Error; err != nil {
+			return err
+		}
+
+		remaining := roundAmount(order.Amount - order.RefundedAmount - pending)
+		if remaining <= 0 {
+			if pending > 0 {
+				return errors.New("订单有退款正在处理中，暂无可退金额")
+			}
+			return errOrderAlreadyRefunded
+		}
+		amount := roundAmount(input.Amount)
+		if amount <= 0 {
+			amount = remaining
+		}
+		if amount > remaining {
+			return fmt.Errorf("退款金额超过可退金额: %.2f", remaining)
+		}
+
+		refund = &model.OrderRefund{
+			OrderId:    order.Id,
+			RefundNo:   input.RefundNo,
+			Amount:     amount,
+			Source:     input.Source,
+			Status:     refundStatusPending,
+			Reason:     input.Reason,
+			OperatorId: input.OperatorId,
+		}
+		return tx.Create(refund).Error
+	})
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	return refund, order, duplicated, nil
+}
+
+// failOrderRefund 微信明确拒绝或关闭退款时释放占用的可退金额
+func failOrderRefund(db *gorm.DB, orderId int64, refundNo string) (bool, error) {
+	result := db.Model(&model.OrderRefund{}).
+		Where("order_id = ? AND refund_no = ? AND status = ?", orderId, strings.TrimSpace(refundNo), refundStatusPending).
+		Update("status", refundStatusFailed)
+	return result.RowsAffected > 0, result.Error
+}
+
+// applyOrderRefund 在单个事务内确认退款成功：累计已退款金额，全额退款时才标记订单已退款、取消分销奖励并追回余额。
+// 只有待处理的退款单能转为成功，已失败的退款单返回 errRefundAlreadyFailed 且不做修改；微信侧直接发起（如商户平台）的退款补记退款单。
+// 以退款单号保证幂等，已确认的退款重复调用返回已有记录且 duplicated 为 true。
+func applyOrderRefund(db *gorm.DB, logger logx.Logger, input orderRefundInput) (refund *model.OrderRefund, duplicated bool, err error) {
+	input.RefundNo = strings.TrimSpace(input.RefundNo)
+	if input.RefundNo == "" {
+		return nil, false, errors.New("退款单号不能为空")
+	}
+
+	var order model.Order
+	fullyRefunded := false
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// 先锁定订单，保证同一订单的并发退款串行执行
+		if err := lockRefundOrder(tx, input.OrderId, &order); err != nil {
+			return err
+		}
+
+		existing, err := findRefundByNo(tx, input.OrderId, input.RefundNo)
+		if err != nil {
+			return err
+		}
+		if existing != nil && existing.Status == refundStatusSucceeded {
+			refund = existing
+			duplicated = true
+			return nil
+		}
+
+		if err := checkRefundablePayStatus(&order); err != nil {
+			return err
+		}
+
+		remaining := roundAmount(order.Amount - order.RefundedAmount)
+		if existing != nil {
+			refund = existing
+			// failOrderRefund 不锁订单，以条件更新保证只有待处理的退款单转为成功
+			result := tx.Model(&model.OrderRefund{}).
+				Where("id = ? AND status = ?", refund.Id, refundStatusPending).
+				Update("status", refundStatusSucceeded)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected != 1 {
+				return fmt.Errorf("%w: %s", errRefundAlreadyFailed, input.RefundNo)
+			}
+			refund.Status = refundStatusSucceeded
+		} else {
+			amount := roundAmount(input.Amount)
+			if amount <= 0 {
+				amount = remaining
+			}
+			refund = &model.OrderRefund{
+				OrderId:    order.Id,
+				RefundNo:   input.RefundNo,
+				Amount:     amount,
+				Source:     input.Source,
+				Status:     refundStatusSucceeded,
+				Reason:     input.Reason,
+				OperatorId: input.OperatorId,
+			}
+			if err := tx.Create(refund).Error; err != nil {
+				return err
+			}
+		}
+		if refund.Amount > remaining {
+			return fmt.Errorf("退款金额超过可退金额: %.2f", remaining)
+		}
+
+		refunded := roundAmount(order.RefundedAmount + refund.Amount)
+		updates := map[string]interface{}{"refunded_amount": refunded}
+		fullyRefunded = refunded >= roundAmount(order.Amount)
+		if fullyRefunded {
+			updates["pay_status"] = "refunded"
+			updates["status"] = "cancelled"
+		}
+		if err := tx.Model(&model.Order{}).Where("id = ?", order.Id).Updates(updates).Error; err != nil {
+			return err
+		}
+
+		// 部分退款不影响订单与分销奖励，全额退款后才整单作废
+		if fullyRefunded {
+			if err := tx.Model(&model.Reward{}).
+				Where("order_id = ? AND status <> ?", order.Id, "cancelled").
+				Update("status", "cancelled").Error; err != nil {
+				return err
+			}
+
+			clawback, err := reverseDistributorRewards(tx, logger, order, refund)
+			if err != nil {
+				return err
+			}
+
+			if clawback > 0 {
+				refund.ClawbackAmount = clawback
+				if err := tx.Model(refund).Update("clawback_amount", clawback).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		return input.Outbox.Add(tx, syncadapter.OutboxEventOrderRefunded, order.Id)
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	if fullyRefunded && input.Seats != nil {
+		input.Seats.Release(context.Background(), &order)
+	}
+
+	return refund, duplicated, nil
+}
+
+func lockRefundOrder(tx *gorm.DB, orderId int64, order *model.Order) error {
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ? AND deleted_at IS NULL", orderId).First(order).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return errors.New("订单不存在")
+	}
+	return err
+}
+
+// findRefundByNo 按退款单号查找退款记录，不存在时返回 nil
+func findRefundByNo(tx *gorm.DB, orderId int64, refundNo string) (*model.OrderRefund, error) {
+	var existing model.OrderRefund
+	err := tx.Where("refund_no = ?", refundNo).First(&existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if existing.OrderId != orderId {
+		return nil, fmt.Errorf("退款单号已被其他订单使用: %s", refundNo)
+	}
+	return &existing, nil
+}
+
+func checkRefundablePayStatus(order *model.Order) error {
+	switch order.PayStatus {
+	case "paid":
+		return nil
+	case "refunded":
+		return errOrderAlreadyRefunded
+	default:
+		return errors.New("订单未支付，无法退款")
+	}
+}
+
+func roundAmount(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// reverseDistributorRewards 取消订单的已结算奖励，扣减分销商累计收益与用户余额
+func reverseDistributorRewards(tx *gorm.DB, logger logx.Logger, order model.Order, refund *model.OrderRefund) (float64, error) {
+	var rewards []model.DistributorReward
+	if err := tx.Where("order_id = ? AND status = ?", order.Id, "settled").Find(&rewards).Error; err != nil {
+		return 0, err
+	}
+
+	total := 0.0
+	for _, reward := range rewards {
+		if err := tx.Model(&model.DistributorReward{}).Where("id = ?", reward.Id).
+			Update("status", "cancelled").Error; err != nil {
+			return 0, err
+		}
+
+		if err := tx.Model(&model.Distributor{}).Where("id = ?", reward.DistributorId).
+			UpdateColumn("total_earnings", gorm.Expr("total_earnings - ?", reward.Amount)).Error; err != nil {
+			return 0, err
+		}
+
+		ledger, err := service.NewBalanceService(tx).Apply(service.BalanceChange{
+			UserId:         reward.UserId,
+			Type:           service.BalanceTxRefundClawback,
+			CounterAccount: service.BalanceAccountRewardExpense,
+			Amount:         -reward.Amount,
+			AllowNegative:  true,
+			RefType:        "refund",
+			RefId:          refund.Id,
+			Remark:         fmt.Sprintf("订单%d退款追回奖励，退款单号%s", order.Id, refund.RefundNo),
+		})
+		if err != nil {
+			return 0, err
+		}
+
+		// 用户已提现的部分无法追回，余额记为负数，由后续奖励抵扣
+		if ledger.BalanceAfter < 0 {
+			logger.Infof("Reward clawback exceeds balance: userId=%d, orderId=%d, balance=%.2f", reward.UserId, order.Id, ledger.BalanceAfter)
+		}
+
+		total += reward.Amount
+	}
+
+	return total, nil
+}
+
+// findOrderByTradeRef 根据微信交易号或商户订单号定位订单
+func findOrderByTradeRef(db *gorm.DB, transactionId, outTradeNo string) (*model.Order, error) {
+	var order model.Order
+	if strings.TrimSpace(transactionId) != "" {
+		err := db.Where("trade_no = ? AND deleted_at IS NULL", transactionId).First(&order).Error
+		if err == nil {
+			return &order, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+	}
+
+	if strings.TrimSpace(outTradeNo) != "" {
+		err := db.Where("out_trade_no = ? AND deleted_at IS NULL", strings.TrimSpace(outTradeNo)).First(&order).Error
+		if err == nil {
+			return &order, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+	}
+
+	// 兼容未生成商户订单号时以订单ID下单的情况
+	orderId, err := strconv.ParseInt(strings.TrimSpace(outTradeNo), 10, 64)
+	if err != nil || orderId <= 0 {
+		return nil, errors.New("订单不存在")
+	}
+	if err := db.Where("id = ? AND deleted_at IS NULL", orderId).First(&order).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("订单不存在")
+		}
+		return nil, err
+	}
+
+	return &order, nil
+}
@@ -4,17 +4,46 @@
 package order
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
+	"dmh/api/internal/service"
 	"dmh/api/internal/svc"
 	"dmh/api/internal/types"
+	"dmh/common/metrics"
+	"dmh/common/syncadapter"
 	"dmh/model"
 	"github.com/zeromicro/go-zero/core/logx"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+const maxDistributionLevel = 3
+
+// 支付通知来源
+const (
+	paymentProviderWechatV2 = "wechat_v2"
+	paymentProviderWechatV3 = "wechat_v3"
+)
+
+var errPaymentAmountMismatch = errors.New("支付金额与订单金额不一致")
+
+// paymentNotice 支付通知验签后归一化的内容
+type paymentNotice struct {
+	OutTradeNo    string
+	TransactionId string
+	TotalFee      int64     // 支付金额（分）
+	PaidAt        time.Time // 渠道记录的支付完成时间，与对账单的交易时间一致
+}
+
 type PaymentCallbackLogic struct {
 	logx.Logger
 	ctx    context.Context
@@ -30,6 +59,12 @@ func NewPaymentCallbackLogic(ctx context.Context, svcCtx *svc.ServiceContext) *P
 }
 
 func (l *PaymentCallbackLogic) PaymentCallback(req *types.PaymentCallbackReq) error {
+	return l.completePayment(req, time.Time{})
+}
+
+// completePayment 完成支付并结算奖励；paidAt 为渠道的支付完成时间，为空时取当前时间。
+// 对账按支付时间所在日期核对账单，使用渠道时间可避免跨零点的订单被误报为缺失
+func (l *PaymentCallbackLogic) completePayment(req *types.PaymentCallbackReq, paidAt time.Time) error {
 	tx := l.svcCtx.DB.Begin()
 	defer func() {
 		if r := recover(); r != nil {
@@ -38,23 +73,46 @@ func (l *PaymentCallbackLogic) PaymentCallback(req *types.PaymentCallbackReq) er
 		}
 	}()
 
+	// 锁定订单行，并发的重复通知在此排队，后到者看到已支付状态直接返回，避免重复结算奖励
 	var order model.Order
-	if err := tx.Where("id = ?", req.OrderId).First(&order).Error; err != nil {
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", req.OrderId).First(&order).Error; err != nil {
 		tx.Rollback()
 		l.Errorf("Failed to query order: %v", err)
 		return errors.New("Order not found")
 	}
 
-	if order.PayStatus == "paid" {
+	if order.PayStatus == "paid" || order.PayStatus == "refunded" {
 		tx.Rollback()
 		l.Infof("Order already paid: orderId=%d", req.OrderId)
 		return nil
 	}
 
+	// 订单未记录金额时以活动当前价格为准，活动无需支付时拒绝，避免任意金额完成支付
+	expected := order.Amount
+	if expected <= 0 {
+		price, err := campaignPrice(tx, order.CampaignId)
+		if err != nil {
+			tx.Rollback()
+			l.Errorf("Failed to query campaign price: orderId=%d, err=%v", order.Id, err)
+			return err
+		}
+		expected = price
+	}
+	if expected <= 0 || math.Round(expected*100) != math.Round(req.Amount*100) {
+		tx.Rollback()
+		l.Errorf("Payment amount mismatch: orderId=%d, expected=%.2f, paid=%.2f", order.Id, expected, req.Amount)
+		return errPaymentAmountMismatch
+	}
+
+	now := time.Now()
+	if paidAt.IsZero() || paidAt.After(now) {
+		paidAt = now
+	}
+	order.Status = "paid"
 	order.PayStatus = "paid"
 	order.TradeNo = req.TradeNo
 	order.Amount = req.Amount
-	now := time.Now()
+	order.PaidAt = &paidAt
 	order.UpdatedAt = now
 
 	if err := tx.Save(&order).Error; err != nil {
@@ -69,6 +127,18 @@ func (l *PaymentCallbackLogic) PaymentCallback(req *types.PaymentCallbackReq) er
 		return err
 	}
 
+	if err := l.svcCtx.Webhooks.PublishOrder(tx, service.WebhookEventOrderPaid, &order); err != nil {
+		tx.Rollback()
+		l.Errorf("Failed to record webhook event: %v", err)
+		return err
+	}
+
+	if err := l.svcCtx.Outbox.Add(tx, syncadapter.OutboxEventOrderPaid, order.Id); err != nil {
+		tx.Rollback()
+		l.Errorf("Failed to record sync event: %v", err)
+		return err
+	}
+
 	if err := tx.Commit().Error; err != nil {
 		l.Errorf("Failed to commit transaction: %v", err)
 		return err
@@ -79,6 +149,137 @@ func (l *PaymentCallbackLogic) PaymentCallback(req *types.PaymentCallbackReq) er
 	return nil
 }
 
+// campaignPrice 活动报名应付金额，未配置支付时为 0
+func campaignPrice(db *gorm.DB, campaignId int64) (float64, error) {
+	var campaign model.Campaign
+	if err := db.Select("id", "payment_config").Where("id = ?", campaignId).First(&campaign).Error; err != nil {
+		return 0, err
+	}
+	config, err := model.ParseCampaignPaymentConfig(campaign.PaymentConfig)
+	if err != nil {
+		return 0, err
+	}
+	return config.Price(), nil
+}
+
+// PaymentNotify 处理支付渠道推送的原始通知：验签后按商户订单号完成支付，报文无论成败都会留档
+func (l *PaymentCallbackLogic) PaymentNotify(header http.Header, body []byte) error {
+	if l.svcCtx == nil || l.svcCtx.DB == nil {
+		return errors.New("服务未初始化")
+	}
+
+	record := &model.PaymentNotification{
+		Provider: paymentProviderWechatV3,
+		RawBody:  string(body),
+	}
+	if IsXMLPaymentNotify(body) {
+		record.Provider = paymentProviderWechatV2
+	}
+	defer l.savePaymentNotification(record)
+
+	fail := func(status string, err error) error {
+		record.Status = status
+		record.ErrorMsg = truncateNotifyError(err.Error())
+		return err
+	}
+
+	if l.svcCtx.WeChatPayService == nil {
+		return fail("failed", errors.New("微信支付服务未初始化"))
+	}
+
+	notice, err := l.parsePaymentNotice(record.Provider, header, body)
+	if err != nil {
+		l.Errorf("支付通知验签失败: provider=%s, err=%v", record.Provider, err)
+		return fail("rejected", err)
+	}
+	record.OutTradeNo = notice.OutTradeNo
+	record.TransactionId = notice.TransactionId
+	record.Amount = float64(notice.TotalFee) / 100
+
+	order, err := findOrderByTradeRef(l.svcCtx.DB, notice.TransactionId, notice.OutTradeNo)
+	if err != nil {
+		l.Errorf("支付通知对应订单不存在: outTradeNo=%s, transactionId=%s, err=%v", notice.OutTradeNo, notice.TransactionId, err)
+		return fail("rejected", err)
+	}
+	record.OrderId = order.Id
+
+	if order.PayStatus == "paid" || order.PayStatus == "refunded" {
+		l.Infof("订单已支付，忽略重复通知: orderId=%d, transactionId=%s", order.Id, notice.TransactionId)
+		record.Status = "duplicate"
+		return nil
+	}
+
+	err = l.completePayment(&types.PaymentCallbackReq{
+		OrderId:   order.Id,
+		PayStatus: "paid",
+		Amount:    record.Amount,
+		TradeNo:   notice.TransactionId,
+	}, notice.PaidAt)
+	if errors.Is(err, errPaymentAmountMismatch) {
+		return fail("rejected", err)
+	}
+	if err != nil {
+		return fail("failed", err)
+	}
+
+	record.Status = "processed"
+	return nil
+}
+
+// IsXMLPaymentNotify 判断通知是否为 v2 XML 报文，否则按 v3 JSON 处理
+func IsXMLPaymentNotify(body []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(body), []byte("<"))
+}
+
+func (l *PaymentCallbackLogic) parsePaymentNotice(provider string, header http.Header, body []byte) (*paymentNotice, error) {
+	payService := l.svcCtx.WeChatPayService
+
+	if provider == paymentProviderWechatV2 {
+		notify, err := payService.ParseNotifyRequest(string(body))
+		if err != nil {
+			return nil, err
+		}
+		// time_end 为北京时间 yyyyMMddHHmmss，解析失败时按处理时间记录
+		paidAt, _ := time.ParseInLocation("20060102150405", notify.TimeEnd, time.Local)
+		return &paymentNotice{
+			OutTradeNo:    notify.OutTradeNo,
+			TransactionId: notify.TransactionID,
+			TotalFee:      notify.TotalFee,
+			PaidAt:        paidAt,
+		}, nil
+	}
+
+	transaction, err := payService.ParsePayNotifyV3(header, body)
+	if err != nil {
+		return nil, err
+	}
+	if transaction.TradeState != "" && transaction.TradeState != "SUCCESS" {
+		return nil, fmt.Errorf("支付未成功: %s", transaction.TradeState)
+	}
+	paidAt, _ := time.Parse(time.RFC3339, transaction.SuccessTime)
+	return &paymentNotice{
+		OutTradeNo:    transaction.OutTradeNo,
+		TransactionId: transaction.TransactionID,
+		TotalFee:      transaction.Amount.Total,
+		PaidAt:        paidAt,
+	}, nil
+}
+
+func (l *PaymentCallbackLogic) savePaymentNotification(record *model.PaymentNotification) {
+	metrics.PaymentCallbacks.WithLabelValues(record.Provider, record.Status).Inc()
+	if err := l.svcCtx.DB.Create(record).Error; err != nil {
+		l.Errorf("保存支付通知失败: outTradeNo=%s, err=%v", record.OutTradeNo, err)
+	}
+}
+
+func truncateNotifyError(msg string) string {
+	runes := []rune(msg)
+	if len(runes) > 500 {
+		return string(runes[:500])
+	}
+	return msg
+}
+
 func (l *PaymentCallbackLogic) calculateAndSettleRewards(tx *gorm.DB, order model.Order) error {
 	var campaign model.Campaign
 	if err := tx.Where("id = ?", order.CampaignId).First(&campaign).Error; err != nil {
@@ -92,13 +293,19 @@ func (l *PaymentCallbackLogic) calculateAndSettleRewards(tx *gorm.DB, order mode
 	}
 
 	var referrerDistributor model.Distributor
-	if err := tx.Where("user_id = ? AND status = ?", order.ReferrerId, "active").
+	if err := tx.Where("user_id = ? AND brand_id = ? AND status = ?", order.ReferrerId, campaign.BrandId, "active").
 		First(&referrerDistributor).Error; err != nil {
 		l.Errorf("Failed to query distributor: %v", err)
 		return errors.New("Referrer not found as active distributor")
 	}
 
-	rewardAmount := order.Amount * campaign.RewardRule
+	chain, err := l.resolveDistributorChain(tx, referrerDistributor, campaign)
+	if err != nil {
+		l.Errorf("Failed to resolve distributor chain: %v", err)
+		return err
+	}
+
+	campaignRates := parseDistributionRewards(campaign.DistributionRewards)
 
 	var distributorLevelRewards []model.DistributorLevelReward
 	if err := tx.Where("brand_id = ?", campaign.BrandId).
@@ -106,47 +313,155 @@ func (l *PaymentCallbackLogic) calculateAndSettleRewards(tx *gorm.DB, order mode
 		l.Errorf("Failed to query level rewards: %v", err)
 		return errors.New("Failed to get reward configuration")
 	}
-
-	levelRewardPercent := 0.0
+	brandRates := make(map[int]float64, len(distributorLevelRewards))
 	for _, levelReward := range distributorLevelRewards {
-		if levelReward.Level == referrerDistributor.Level {
-			levelRewardPercent = levelReward.RewardPercentage
-			break
+		brandRates[levelReward.Level] = levelReward.RewardPercentage
+	}
+
+	rewardAmount := order.Amount * campaign.RewardRule
+	now := time.Now()
+
+	path := make([]string, 0, len(chain))
+	for i, distributor := range chain {
+		level := i + 1
+		path = append(path, strconv.FormatInt(distributor.Id, 10))
+
+		rewardPercent, ok := campaignRates[level]
+		if !ok {
+			rewardPercent = brandRates[level]
+		}
+
+		if rewardPercent <= 0 {
+			if level == 1 {
+				l.Errorf("No reward config for distributor level: level=%d", level)
+				return errors.New("Reward configuration not found for distributor level")
+			}
+			l.Infof("No reward config for level %d, skipping: distributorId=%d, orderId=%d", level, distributor.Id, order.Id)
+			continue
+		}
+
+		actualReward := math.Round(rewardAmount*rewardPercent) / 100
+
+		rewardRecord := model.DistributorReward{
+			DistributorId: distributor.Id,
+			UserId:        distributor.UserId,
+			OrderId:       order.Id,
+			CampaignId:    order.CampaignId,
+			Amount:        actualReward,
+			Level:         level,
+			RewardRate:    rewardPercent,
+			Status:        "settled",
+			SettledAt:     &now,
 		}
+
+		if err := tx.Create(&rewardRecord).Error; err != nil {
+			l.Errorf("Failed to create reward record: %v", err)
+			return err
+		}
+
+		if err := l.svcCtx.Outbox.Add(tx, syncadapter.OutboxEventRewardSettled, rewardRecord.Id); err != nil {
+			l.Errorf("Failed to record reward sync event: %v", err)
+			return err
+		}
+
+		if err := l.svcCtx.Webhooks.PublishReward(tx, campaign.BrandId, &rewardRecord); err != nil {
+			l.Errorf("Failed to record reward webhook event: %v", err)
+			return err
+		}
+
+		if err := tx.Model(&model.Distributor{}).Where("id = ?", distributor.Id).
+			UpdateColumn("total_earnings", gorm.Expr("total_earnings + ?", actualReward)).Error; err != nil {
+			l.Errorf("Failed to update distributor earnings: %v", err)
+			return err
+		}
+
+		if _, err := service.NewBalanceService(tx).Apply(service.BalanceChange{
+			UserId:         distributor.UserId,
+			Type:           service.BalanceTxRewardCredit,
+			CounterAccount: service.BalanceAccountRewardExpense,
+			Amount:         actualReward,
+			RefType:        "order",
+			RefId:          order.Id,
+			Remark:         fmt.Sprintf("第%d级分销奖励", level),
+		}); err != nil {
+			l.Errorf("Failed to credit user balance: userId=%d, err=%v", distributor.UserId, err)
+			return err
+		}
+
+		l.Infof("Reward settled successfully: distributorId=%d, orderId=%d, level=%d, amount=%.2f", distributor.Id, order.Id, level, actualReward)
 	}
 
-	if levelRewardPercent == 0 {
-		l.Errorf("No reward config for distributor level: level=%d", referrerDistributor.Level)
-		return errors.New("Reward configuration not found for distributor level")
+	distributorPath := strings.Join(path, ",")
+	if err := tx.Model(&model.Order{}).Where("id = ?", order.Id).
+		UpdateColumn("distributor_path", distributorPath).Error; err != nil {
+		l.Errorf("Failed to update distributor path: %v", err)
+		return err
 	}
 
-	actualReward := rewardAmount * (levelRewardPercent / 100)
+	return nil
+}
 
-	now := time.Now()
-	rewardRecord := model.DistributorReward{
-		DistributorId: referrerDistributor.Id,
-		UserId:        referrerDistributor.UserId,
-		OrderId:       order.Id,
-		CampaignId:    order.CampaignId,
-		Amount:        actualReward,
-		Level:         referrerDistributor.Level,
-		RewardRate:    levelRewardPercent,
-		Status:        "settled",
-		SettledAt:     &now,
-	}
-
-	if err := tx.Create(&rewardRecord).Error; err != nil {
-		l.Errorf("Failed to create reward record: %v", err)
-		return err
+// resolveDistributorChain 从直接推荐人开始沿 ParentId 向上查找分销链，层级受活动分销层级限制
+func (l *PaymentCallbackLogic) resolveDistributorChain(tx *gorm.DB, referrer model.Distributor, campaign model.Campaign) ([]model.Distributor, error) {
+	maxLevel := campaign.DistributionLevel
+	if maxLevel < 1 {
+		maxLevel = 1
+	}
+	if maxLevel > maxDistributionLevel {
+		maxLevel = maxDistributionLevel
 	}
 
-	referrerDistributor.TotalEarnings += actualReward
-	if err := tx.Save(&referrerDistributor).Error; err != nil {
-		l.Errorf("Failed to update distributor earnings: %v", err)
-		return err
+	chain := []model.Distributor{referrer}
+	visited := map[int64]bool{referrer.Id: true}
+	current := referrer
+
+	for len(chain) < maxLevel && current.ParentId != nil && *current.ParentId > 0 {
+		parentId := *current.ParentId
+		if visited[parentId] {
+			l.Errorf("Distributor chain cycle detected: distributorId=%d, parentId=%d", current.Id, parentId)
+			break
+		}
+		visited[parentId] = true
+
+		var parent model.Distributor
+		err := tx.Where("id = ? AND brand_id = ? AND deleted_at IS NULL", parentId, campaign.BrandId).First(&parent).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		current = parent
+		if parent.Status != "active" {
+			l.Infof("Parent distributor inactive, stop walking chain: distributorId=%d, status=%s", parent.Id, parent.Status)
+			break
+		}
+		chain = append(chain, parent)
 	}
 
-	l.Infof("Reward settled successfully: distributorId=%d, orderId=%d, amount=%.2f", referrerDistributor.Id, order.Id, actualReward)
+	return chain, nil
+}
 
-	return nil
+// parseDistributionRewards 解析活动各级奖励比例，兼容 {"level1":10} 与 {"1":10} 两种格式
+func parseDistributionRewards(raw *string) map[int]float64 {
+	rates := make(map[int]float64)
+	if raw == nil || strings.TrimSpace(*raw) == "" {
+		return rates
+	}
+
+	var parsed map[string]float64
+	if err := json.Unmarshal([]byte(*raw), &parsed); err != nil {
+		return rates
+	}
+
+	for key, value := range parsed {
+		level, err := strconv.Atoi(strings.TrimPrefix(strings.ToLower(strings.TrimSpace(key)), "level"))
+		if err != nil || level < 1 || level > maxDistributionLevel {
+			continue
+		}
+		rates[level] = value
+	}
+
+	return rates
 }
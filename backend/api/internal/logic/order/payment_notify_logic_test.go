@@ -0,0 +1,167 @@
+package order
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"dmh/api/internal/svc"
+	"dmh/common/wechatpay"
+	"dmh/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func newNotifyPayService() *wechatpay.Service {
+	return wechatpay.NewService(&wechatpay.Config{AppID: "wx-app", MchID: "mch-1", APIKey: "key123"})
+}
+
+// signedPayNotifyXML 构造按 v2 规则签名的支付成功通知
+func signedPayNotifyXML(payService *wechatpay.Service, outTradeNo, transactionId string, totalFee int64) string {
+	params := map[string]string{
+		"return_code":    "SUCCESS",
+		"result_code":    "SUCCESS",
+		"appid":          "wx-app",
+		"mch_id":         "mch-1",
+		"nonce_str":      "nonce",
+		"out_trade_no":   outTradeNo,
+		"transaction_id": transactionId,
+		"total_fee":      fmt.Sprintf("%d", totalFee),
+		"trade_type":     "NATIVE",
+		"time_end":       time.Now().Add(-90 * time.Second).Format("20060102150405"),
+	}
+	xml := "<xml>"
+	for key, value := range params {
+		xml += fmt.Sprintf("<%s>%s</%s>", key, value, key)
+	}
+	return xml + "<sign>" + payService.GenerateMD5Sign(params) + "</sign></xml>"
+}
+
+func createUnpaidNotifyOrder(t *testing.T, db *gorm.DB, amount float64) *model.Order {
+	campaign := &model.Campaign{
+		Name:       "支付通知活动",
+		FormFields: `[]`,
+		StartTime:  time.Now().Add(-1 * time.Hour),
+		EndTime:    time.Now().Add(24 * time.Hour),
+		Status:     "active",
+		BrandId:    1,
+	}
+	require.NoError(t, db.Create(campaign).Error)
+
+	order := &model.Order{
+		CampaignId: campaign.Id,
+		Phone:      "13800138020",
+		FormData:   `{}`,
+		Status:     "pending",
+		PayStatus:  "unpaid",
+		Amount:     amount,
+	}
+	require.NoError(t, db.Create(order).Error)
+	return order
+}
+
+func TestPaymentNotify_SignedXMLMarksOrderPaid(t *testing.T) {
+	db := setupTestDB(t)
+	order := createUnpaidNotifyOrder(t, db, 99.90)
+	payService := newNotifyPayService()
+	body := signedPayNotifyXML(payService, fmt.Sprintf("%d", order.Id), "WX_TX_1", 9990)
+
+	logic := NewPaymentCallbackLogic(context.Background(), &svc.ServiceContext{DB: db, WeChatPayService: payService})
+	require.NoError(t, logic.PaymentNotify(http.Header{}, []byte(body)))
+	require.NoError(t, logic.PaymentNotify(http.Header{}, []byte(body)))
+
+	var updated model.Order
+	require.NoError(t, db.First(&updated, order.Id).Error)
+	assert.Equal(t, "paid", updated.PayStatus)
+	assert.Equal(t, "WX_TX_1", updated.TradeNo)
+	// 支付时间取通知中的 time_end，而非处理通知的时间
+	require.NotNil(t, updated.PaidAt)
+	assert.WithinDuration(t, time.Now().Add(-90*time.Second), *updated.PaidAt, 5*time.Second)
+
+	var records []model.PaymentNotification
+	require.NoError(t, db.Where("order_id = ?", order.Id).Order("id ASC").Find(&records).Error)
+	require.Len(t, records, 2)
+	assert.Equal(t, "processed", records[0].Status)
+	assert.Equal(t, "wechat_v2", records[0].Provider)
+	assert.Equal(t, body, records[0].RawBody)
+	assert.Equal(t, "duplicate", records[1].Status)
+}
+
+func TestPaymentNotify_RejectsBadSignature(t *testing.T) {
+	db := setupTestDB(t)
+	order := createUnpaidNotifyOrder(t, db, 99.90)
+	payService := newNotifyPayService()
+	forger := wechatpay.NewService(&wechatpay.Config{AppID: "wx-app", MchID: "mch-1", APIKey: "wrong-key"})
+	body := signedPayNotifyXML(forger, fmt.Sprintf("%d", order.Id), "WX_TX_2", 9990)
+
+	logic := NewPaymentCallbackLogic(context.Background(), &svc.ServiceContext{DB: db, WeChatPayService: payService})
+	err := logic.PaymentNotify(http.Header{}, []byte(body))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "签名验证失败")
+
+	var updated model.Order
+	require.NoError(t, db.First(&updated, order.Id).Error)
+	assert.Equal(t, "unpaid", updated.PayStatus)
+
+	var record model.PaymentNotification
+	require.NoError(t, db.Order("id DESC").First(&record).Error)
+	assert.Equal(t, "rejected", record.Status)
+	assert.Equal(t, body, record.RawBody)
+}
+
+func TestPaymentNotify_RejectsAmountMismatch(t *testing.T) {
+	db := setupTestDB(t)
+	order := createUnpaidNotifyOrder(t, db, 99.90)
+	payService := newNotifyPayService()
+	body := signedPayNotifyXML(payService, fmt.Sprintf("%d", order.Id), "WX_TX_3", 1)
+
+	logic := NewPaymentCallbackLogic(context.Background(), &svc.ServiceContext{DB: db, WeChatPayService: payService})
+	err := logic.PaymentNotify(http.Header{}, []byte(body))
+	require.ErrorIs(t, err, errPaymentAmountMismatch)
+
+	var updated model.Order
+	require.NoError(t, db.First(&updated, order.Id).Error)
+	assert.Equal(t, "unpaid", updated.PayStatus)
+	assert.Equal(t, 99.90, updated.Amount)
+
+	var record model.PaymentNotification
+	require.NoError(t, db.Where("order_id = ?", order.Id).First(&record).Error)
+	assert.Equal(t, "rejected", record.Status)
+	assert.Equal(t, 0.01, record.Amount)
+}
+
+func TestPaymentNotify_UnpricedOrderUsesCampaignPrice(t *testing.T) {
+	db := setupTestDB(t)
+	payService := newNotifyPayService()
+	logic := NewPaymentCallbackLogic(context.Background(), &svc.ServiceContext{DB: db, WeChatPayService: payService})
+
+	// 免费活动的订单不接受支付通知
+	free := createUnpaidNotifyOrder(t, db, 0)
+	body := signedPayNotifyXML(payService, fmt.Sprintf("%d", free.Id), "WX_TX_4", 1)
+	require.ErrorIs(t, logic.PaymentNotify(http.Header{}, []byte(body)), errPaymentAmountMismatch)
+
+	// 订单未记录金额时按活动价格核对
+	priced := createUnpaidNotifyOrder(t, db, 0)
+	require.NoError(t, db.Model(&model.Campaign{}).Where("id = ?", priced.CampaignId).
+		Update("payment_config", `{"requirePayment":true,"paymentType":"full","paymentAmount":9.9}`).Error)
+	body = signedPayNotifyXML(payService, fmt.Sprintf("%d", priced.Id), "WX_TX_5", 1)
+	require.ErrorIs(t, logic.PaymentNotify(http.Header{}, []byte(body)), errPaymentAmountMismatch)
+	body = signedPayNotifyXML(payService, fmt.Sprintf("%d", priced.Id), "WX_TX_6", 990)
+	require.NoError(t, logic.PaymentNotify(http.Header{}, []byte(body)))
+
+	var updated model.Order
+	require.NoError(t, db.First(&updated, free.Id).Error)
+	assert.Equal(t, "unpaid", updated.PayStatus)
+	require.NoError(t, db.First(&updated, priced.Id).Error)
+	assert.Equal(t, "paid", updated.PayStatus)
+	assert.Equal(t, 9.9, updated.Amount)
+}
+
+func TestIsXMLPaymentNotify(t *testing.T) {
+	assert.True(t, IsXMLPaymentNotify([]byte("  <xml></xml>")))
+	assert.False(t, IsXMLPaymentNotify([]byte(`{"id":"evt-1"}`)))
+}
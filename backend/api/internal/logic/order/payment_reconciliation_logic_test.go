@@ -0,0 +1,161 @@
+package order
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"dmh/api/internal/svc"
+	"dmh/api/internal/types"
+	"dmh/common/wechatpay"
+	"dmh/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const reconcileBillHeader = "交易时间,公众账号ID,商户号,特约商户号,设备号,微信订单号,商户订单号,用户标识,交易类型,交易状态,付款银行,货币种类,应结订单金额,代金券金额,微信退款单号,商户退款单号,退款金额,充值券退款金额,退款类型,退款状态,商品名称,商户数据包,手续费,费率,订单金额,申请退款金额,费率备注\n"
+
+func reconcileBillRow(transactionId, outTradeNo, state string, amount float64) string {
+	return fmt.Sprintf("`2026-10-17 10:00:00,`wx-app,`mch-1,`0,`,`%s,`%s,`openid-1,`NATIVE,`%s,`OTHERS,`CNY,`%.2f,`0.00,`0,`0,`0.00,`0.00,`,`,`活动报名,`,`0.00000,`0.60%%,`%.2f,`0.00,`\n",
+		transactionId, outTradeNo, state, amount, amount)
+}
+
+func TestReconcilePaymentsLogic_PermissionDenied(t *testing.T) {
+	ctx := context.WithValue(context.Background(), "roles", []string{"brand_admin"})
+	logic := NewReconcilePaymentsLogic(ctx, &svc.ServiceContext{})
+
+	_, err := logic.ReconcilePayments(&types.ReconcilePaymentsReq{BillDate: "2026-10-17"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "权限不足")
+}
+
+func TestReconcilePaymentsLogic_ReportsAndRepairs(t *testing.T) {
+	db := setupTestDB(t)
+	campaign := createTestCampaign(t, db)
+	paidAt := time.Date(2026, 10, 17, 10, 0, 0, 0, time.Local)
+
+	newOrder := func(phone, payStatus, tradeNo, outTradeNo string, amount float64) *model.Order {
+		order := &model.Order{
+			CampaignId: campaign.Id,
+			Phone:      phone,
+			FormData:   `{}`,
+			Status:     "pending",
+			PayStatus:  payStatus,
+			Amount:     amount,
+			TradeNo:    tradeNo,
+			OutTradeNo: outTradeNo,
+		}
+		if payStatus == "paid" {
+			order.Status = "paid"
+			order.PaidAt = &paidAt
+		}
+		require.NoError(t, db.Create(order).Error)
+		return order
+	}
+
+	matched := newOrder("13800000001", "paid", "TX_A", "DMH_A", 99)
+	unpaid := newOrder("13800000002", "unpaid", "", "DMH_B", 50)
+	missing := newOrder("13800000003", "paid", "TX_C", "DMH_C", 30)
+	mismatch := newOrder("13800000004", "paid", "TX_D", "DMH_D", 20)
+
+	bill := reconcileBillHeader +
+		reconcileBillRow("TX_A", "DMH_A", "SUCCESS", 99) +
+		reconcileBillRow("TX_B", "DMH_B", "SUCCESS", 50) +
+		reconcileBillRow("TX_D", "DMH_D", "SUCCESS", 25) +
+		reconcileBillRow("TX_E", "DMH_E", "SUCCESS", 10) +
+		reconcileBillRow("TX_A", "DMH_A", "REFUND", 0)
+
+	svcCtx := &svc.ServiceContext{DB: db}
+	resp, err := NewReconcilePaymentsLogic(refundAdminCtx(1), svcCtx).ReconcilePayments(&types.ReconcilePaymentsReq{
+		BillDate:    "2026-10-17",
+		BillContent: bill,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "upload", resp.Source)
+	assert.Equal(t, 4, resp.BillCount)
+	assert.Equal(t, 184.0, resp.BillAmount)
+	assert.Equal(t, 1, resp.MatchedCount)
+	assert.Equal(t, 1, resp.MissingCount)
+	assert.Equal(t, 2, resp.ExtraCount)
+	assert.Equal(t, 1, resp.MismatchCount)
+	assert.Equal(t, 0, resp.RepairedCount)
+
+	byOrder := make(map[int64]types.PaymentReconciliationItemResp)
+	for _, item := range resp.Items {
+		byOrder[item.OrderId] = item
+	}
+	assert.Equal(t, reconcileItemMissing, byOrder[missing.Id].Type)
+	assert.Equal(t, reconcileItemAmountMismatch, byOrder[mismatch.Id].Type)
+	assert.Equal(t, reconcileItemExtra, byOrder[unpaid.Id].Type)
+	assert.False(t, byOrder[unpaid.Id].Repaired)
+	assert.Equal(t, "TX_E", byOrder[0].TransactionId)
+	_, reported := byOrder[matched.Id]
+	assert.False(t, reported)
+
+	// 上传的账单只报告，不改动订单
+	var untouched model.Order
+	require.NoError(t, db.First(&untouched, unpaid.Id).Error)
+	assert.Equal(t, "unpaid", untouched.PayStatus)
+
+	stored, err := NewGetPaymentReconciliationLogic(refundAdminCtx(1), svcCtx).GetPaymentReconciliation(&types.GetPaymentReconciliationReq{Id: resp.Id})
+	require.NoError(t, err)
+	assert.Equal(t, resp.BillDate, stored.BillDate)
+	assert.Len(t, stored.Items, len(resp.Items))
+}
+
+func TestReconcilePaymentsLogic_RepairsFromDownloadedBill(t *testing.T) {
+	db := setupTestDB(t)
+	campaign := createTestCampaign(t, db)
+	paidAt := time.Date(2026, 10, 17, 10, 0, 0, 0, time.Local)
+
+	unpaid := &model.Order{
+		CampaignId: campaign.Id,
+		Phone:      "13800000002",
+		FormData:   `{}`,
+		Status:     "pending",
+		PayStatus:  "unpaid",
+		Amount:     50,
+		OutTradeNo: "DMH_B",
+	}
+	require.NoError(t, db.Create(unpaid).Error)
+
+	bill := reconcileBillHeader + reconcileBillRow("TX_B", "DMH_B", "SUCCESS", 50)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(bill))
+	}))
+	defer server.Close()
+
+	svcCtx := &svc.ServiceContext{
+		DB:               db,
+		WeChatPayService: wechatpay.NewService(&wechatpay.Config{AppID: "wx-app", MchID: "mch-1", APIKey: "key123", UnifiedOrderURL: server.URL}),
+	}
+	resp, err := NewReconcilePaymentsLogic(refundAdminCtx(1), svcCtx).ReconcilePayments(&types.ReconcilePaymentsReq{BillDate: "2026-10-17"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "download", resp.Source)
+	assert.Equal(t, 1, resp.RepairedCount)
+	require.Len(t, resp.Items, 1)
+	assert.True(t, resp.Items[0].Repaired)
+
+	var repaired model.Order
+	require.NoError(t, db.First(&repaired, unpaid.Id).Error)
+	assert.Equal(t, "paid", repaired.PayStatus)
+	assert.Equal(t, "TX_B", repaired.TradeNo)
+	require.NotNil(t, repaired.PaidAt)
+	assert.True(t, paidAt.Equal(*repaired.PaidAt), "补单的支付时间应取账单交易时间: %v", repaired.PaidAt)
+
+	// 补单订单归属账单交易日，次日对账不会误报缺失
+	next, err := NewReconcilePaymentsLogic(refundAdminCtx(1), svcCtx).ReconcilePayments(&types.ReconcilePaymentsReq{
+		BillDate:    "2026-10-18",
+		BillContent: reconcileBillHeader,
+	})
+	require.NoError(t, err)
+	for _, item := range next.Items {
+		assert.NotEqual(t, unpaid.Id, item.OrderId)
+	}
+}
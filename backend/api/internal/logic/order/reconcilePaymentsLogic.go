@@ -0,0 +1,267 @@
+// Code scaffolded by goctl. Safe to edit.
+// goctl 1.9.2
+
+package order
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"dmh/api/internal/middleware"
+	"dmh/api/internal/svc"
+	"dmh/api/internal/types"
+	"dmh/common/wechatpay"
+	"dmh/model"
+
+	"github.com/zeromicro/go-zero/core/logx"
+	"gorm.io/gorm"
+)
+
+// 对账差异类型
+const (
+	reconcileItemMissing        = "missing"         // 订单已支付但账单中没有
+	reconcileItemExtra          = "extra"           // 账单中有但订单未支付或不存在
+	reconcileItemAmountMismatch = "amount_mismatch" // 金额不一致
+)
+
+type ReconcilePaymentsLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewReconcilePaymentsLogic(ctx context.Context, svcCtx *svc.ServiceContext) *ReconcilePaymentsLogic {
+	return &ReconcilePaymentsLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+// ReconcilePayments 核对指定账单日的微信交易账单与订单，账单已支付而订单未支付的通过支付回调补单。
+// 管理员上传的账单内容不可信，只生成报告不补单，补单仅针对服务自行下载的账单
+func (l *ReconcilePaymentsLogic) ReconcilePayments(req *types.ReconcilePaymentsReq) (resp *types.PaymentReconciliationResp, err error) {
+	if !middleware.IsPlatformAdmin(l.ctx) {
+		return nil, fmt.Errorf("权限不足，仅平台管理员可执行对账")
+	}
+
+	operatorId, err := middleware.GetUserIDFromContext(l.ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	billDate := time.Now().AddDate(0, 0, -1)
+	if strings.TrimSpace(req.BillDate) != "" {
+		billDate, err = time.ParseInLocation("2006-01-02", strings.TrimSpace(req.BillDate), time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("账单日格式错误，应为YYYY-MM-DD")
+		}
+	}
+	billDate = time.Date(billDate.Year(), billDate.Month(), billDate.Day(), 0, 0, 0, 0, time.Local)
+
+	source := "upload"
+	data := []byte(req.BillContent)
+	if strings.TrimSpace(req.BillContent) == "" {
+		source = "download"
+		if l.svcCtx.WeChatPayService == nil {
+			return nil, errors.New("微信支付服务未配置")
+		}
+		data, err = l.svcCtx.WeChatPayService.DownloadTradeBill(billDate)
+		if errors.Is(err, wechatpay.ErrBillNotExist) {
+			data, err = nil, nil
+		}
+		if err != nil {
+			l.Errorf("下载对账单失败: billDate=%s, err=%v", billDate.Format("2006-01-02"), err)
+			return nil, fmt.Errorf("下载对账单失败: %v", err)
+		}
+	}
+
+	bill, err := wechatpay.ParseTradeBill(data)
+	if err != nil {
+		return nil, fmt.Errorf("解析对账单失败: %v", err)
+	}
+
+	report, items, err := l.reconcile(billDate, bill, source == "download")
+	if err != nil {
+		return nil, err
+	}
+	report.Source = source
+	report.OperatorId = &operatorId
+
+	err = l.svcCtx.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(report).Error; err != nil {
+			return err
+		}
+		for i := range items {
+			items[i].ReconciliationId = report.Id
+		}
+		if len(items) > 0 {
+			return tx.Create(&items).Error
+		}
+		return nil
+	})
+	if err != nil {
+		l.Errorf("保存对账报告失败: %v", err)
+		return nil, fmt.Errorf("保存对账报告失败: %v", err)
+	}
+
+	l.Infof("支付对账完成: billDate=%s, bill=%d, matched=%d, missing=%d, extra=%d, mismatch=%d, repaired=%d",
+		report.BillDate, report.BillCount, report.MatchedCount, report.MissingCount, report.ExtraCount, report.MismatchCount, report.RepairedCount)
+
+	return buildPaymentReconciliationResp(report, items), nil
+}
+
+func (l *ReconcilePaymentsLogic) reconcile(billDate time.Time, bill *wechatpay.TradeBill, repair bool) (*model.PaymentReconciliation, []model.PaymentReconciliationItem, error) {
+	report := &model.PaymentReconciliation{BillDate: billDate.Format("2006-01-02")}
+	items := make([]model.PaymentReconciliationItem, 0)
+	seen := make(map[int64]bool)
+	var billFen int64
+
+	for _, record := range bill.Records {
+		// 退款行由退款通知处理，这里只核对支付
+		if record.TradeState != wechatpay.BillTradeStateSuccess {
+			continue
+		}
+		report.BillCount++
+		billFen += record.TotalFee
+
+		item, orderId := l.reconcileRecord(record, repair)
+		if orderId > 0 {
+			seen[orderId] = true
+		}
+		if item == nil {
+			report.MatchedCount++
+			continue
+		}
+		items = append(items, *item)
+	}
+	report.BillAmount = float64(billFen) / 100
+
+	// 订单的支付时间取自渠道通知或账单的交易时间，与账单按同一日期归属
+	var paidOrders []model.Order
+	if err := l.svcCtx.DB.
+		Where("pay_status IN ? AND amount > 0 AND paid_at >= ? AND paid_at < ? AND deleted_at IS NULL",
+			[]string{"paid", "refunded"}, billDate, billDate.AddDate(0, 0, 1)).
+		Find(&paidOrders).Error; err != nil {
+		l.Errorf("查询已支付订单失败: %v", err)
+		return nil, nil, fmt.Errorf("查询已支付订单失败: %v", err)
+	}
+	for _, order := range paidOrders {
+		if seen[order.Id] {
+			continue
+		}
+		items = append(items, model.PaymentReconciliationItem{
+			Type:          reconcileItemMissing,
+			OrderId:       order.Id,
+			TransactionId: order.TradeNo,
+			OutTradeNo:    order.OutTradeNo,
+			OrderAmount:   order.Amount,
+			Remark:        "订单已支付但账单中没有该交易",
+		})
+	}
+
+	for _, item := range items {
+		switch item.Type {
+		case reconcileItemMissing:
+			report.MissingCount++
+		case reconcileItemExtra:
+			report.ExtraCount++
+		case reconcileItemAmountMismatch:
+			report.MismatchCount++
+		}
+		if item.Repaired {
+			report.RepairedCount++
+		}
+	}
+
+	return report, items, nil
+}
+
+// reconcileRecord 核对账单中的一笔支付，一致时返回 nil；repair 为 false 时只报告不补单
+func (l *ReconcilePaymentsLogic) reconcileRecord(record wechatpay.TradeBillRecord, repair bool) (*model.PaymentReconciliationItem, int64) {
+	item := &model.PaymentReconciliationItem{
+		TransactionId: record.TransactionId,
+		OutTradeNo:    record.OutTradeNo,
+		BillAmount:    float64(record.TotalFee) / 100,
+	}
+
+	order, err := findOrderByTradeRef(l.svcCtx.DB, record.TransactionId, record.OutTradeNo)
+	if err != nil {
+		item.Type = reconcileItemExtra
+		item.Remark = err.Error()
+		return item, 0
+	}
+	item.OrderId = order.Id
+	item.OrderAmount = order.Amount
+
+	if order.PayStatus == "paid" || order.PayStatus == "refunded" {
+		if int64(math.Round(order.Amount*100)) != record.TotalFee {
+			item.Type = reconcileItemAmountMismatch
+			item.Remark = "账单金额与订单金额不一致"
+			return item, order.Id
+		}
+		return nil, order.Id
+	}
+
+	if !repair {
+		item.Type = reconcileItemExtra
+		item.Remark = "订单未支付，上传账单仅作核对不补单，需人工确认"
+		return item, order.Id
+	}
+
+	// 账单已支付但订单未支付（支付通知丢失），按正常支付回调补单，支付时间取账单的交易时间
+	err = NewPaymentCallbackLogic(l.ctx, l.svcCtx).completePayment(&types.PaymentCallbackReq{
+		OrderId:   order.Id,
+		PayStatus: "paid",
+		Amount:    item.BillAmount,
+		TradeNo:   record.TransactionId,
+	}, record.TradeTime)
+	switch {
+	case errors.Is(err, errPaymentAmountMismatch):
+		item.Type = reconcileItemAmountMismatch
+		item.Remark = "订单未支付且账单金额与订单金额不一致，需人工处理"
+	case err != nil:
+		item.Type = reconcileItemExtra
+		item.Remark = truncateNotifyError("补单失败: " + err.Error())
+	default:
+		item.Type = reconcileItemExtra
+		item.Repaired = true
+		item.Remark = "订单未支付，已按账单补单"
+		l.Infof("对账补单成功: orderId=%d, transactionId=%s", order.Id, record.TransactionId)
+	}
+	return item, order.Id
+}
+
+func buildPaymentReconciliationResp(report *model.PaymentReconciliation, items []model.PaymentReconciliationItem) *types.PaymentReconciliationResp {
+	resp := &types.PaymentReconciliationResp{
+		Id:            report.Id,
+		BillDate:      report.BillDate,
+		Source:        report.Source,
+		BillCount:     report.BillCount,
+		BillAmount:    report.BillAmount,
+		MatchedCount:  report.MatchedCount,
+		MissingCount:  report.MissingCount,
+		ExtraCount:    report.ExtraCount,
+		MismatchCount: report.MismatchCount,
+		RepairedCount: report.RepairedCount,
+		Items:         make([]types.PaymentReconciliationItemResp, 0, len(items)),
+		CreatedAt:     report.CreatedAt.Format("2006-01-02T15:04:05"),
+	}
+	for _, item := range items {
+		resp.Items = append(resp.Items, types.PaymentReconciliationItemResp{
+			Type:          item.Type,
+			OrderId:       item.OrderId,
+			TransactionId: item.TransactionId,
+			OutTradeNo:    item.OutTradeNo,
+			BillAmount:    item.BillAmount,
+			OrderAmount:   item.OrderAmount,
+			Repaired:      item.Repaired,
+			Remark:        item.Remark,
+		})
+	}
+	return resp
+}
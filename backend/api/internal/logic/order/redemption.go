@@ -0,0 +1,128 @@
+package order
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"dmh/model"
+
+	"gorm.io/gorm"
+)
+
+// 乐观锁冲突时重新读取订单的次数
+const maxRedemptionAttempts = 3
+
+var (
+	errRedemptionsExhausted = errors.New("订单已核销")
+	errNothingToReverse     = errors.New("订单尚未核销，无法取消核销")
+	errRedemptionConflict   = errors.New("订单核销状态已变化，请重试")
+	errOrderRefunded        = errors.New("订单已退款，无法核销")
+	errOrderCancelled       = errors.New("订单已取消，无法核销")
+)
+
+// redeemOrder 消耗订单的一次核销次数并写入核销记录；record 由调用方填写核销方式、设备等，次序与状态在此补全。
+// 按读取到的已核销次数做条件更新，并发核销同一订单时不会超出次数；已退款或已取消的订单不可核销，与退款并发时同样以条件更新拦截
+func redeemOrder(tx *gorm.DB, order *model.Order, record model.VerificationRecord, at time.Time, verifiedBy int64) (*model.VerificationRecord, error) {
+	for attempt := 0; ; attempt++ {
+		switch {
+		case order.PayStatus == "refunded":
+			return nil, errOrderRefunded
+		case order.Status == "cancelled":
+			return nil, errOrderCancelled
+		case order.RemainingRedemptions() == 0:
+			return nil, errRedemptionsExhausted
+		}
+
+		redeemed := order.RedeemedCount + 1
+		status := order.RedemptionStatus(redeemed)
+		updated := tx.Model(&model.Order{}).
+			Where("id = ? AND redeemed_count = ?", order.Id, order.RedeemedCount).
+			Where("status <> ? AND pay_status <> ?", "cancelled", "refunded").
+			Updates(map[string]interface{}{
+				"redeemed_count":      redeemed,
+				"verification_status": status,
+				"verified_at":         &at,
+				"verified_by":         verifiedBy,
+			})
+		if updated.Error != nil {
+			return nil, updated.Error
+		}
+		if updated.RowsAffected == 0 {
+			if attempt+1 >= maxRedemptionAttempts {
+				return nil, errRedemptionConflict
+			}
+			if err := tx.Where("id = ?", order.Id).First(order).Error; err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		record.OrderID = order.Id
+		record.VerificationStatus = "verified"
+		record.VerifiedAt = &at
+		record.VerifiedBy = &verifiedBy
+		record.RedemptionNo = redeemed
+		if err := tx.Create(&record).Error; err != nil {
+			return nil, err
+		}
+
+		order.RedeemedCount, order.VerificationStatus = redeemed, status
+		order.VerifiedAt, order.VerifiedBy = &at, &verifiedBy
+		return &record, nil
+	}
+}
+
+// isRedemptionRejected 订单状态不允许再核销，而非数据库错误
+func isRedemptionRejected(err error) bool {
+	return errors.Is(err, errRedemptionsExhausted) || errors.Is(err, errRedemptionConflict) ||
+		errors.Is(err, errOrderRefunded) || errors.Is(err, errOrderCancelled)
+}
+
+// reverseRedemption 撤销最近一次核销；次数归零时订单回到 cancelled，与单次核销的取消行为一致
+func reverseRedemption(tx *gorm.DB, order *model.Order, record model.VerificationRecord) (*model.VerificationRecord, error) {
+	for attempt := 0; ; attempt++ {
+		if order.RedeemedCount <= 0 {
+			return nil, errNothingToReverse
+		}
+
+		redeemed := order.RedeemedCount - 1
+		status := order.RedemptionStatus(redeemed)
+		updates := map[string]interface{}{
+			"redeemed_count":      redeemed,
+			"verification_status": status,
+		}
+		if redeemed == 0 {
+			updates["verified_at"] = nil
+			updates["verified_by"] = nil
+		}
+		updated := tx.Model(&model.Order{}).
+			Where("id = ? AND redeemed_count = ?", order.Id, order.RedeemedCount).
+			Updates(updates)
+		if updated.Error != nil {
+			return nil, updated.Error
+		}
+		if updated.RowsAffected == 0 {
+			if attempt+1 >= maxRedemptionAttempts {
+				return nil, errRedemptionConflict
+			}
+			if err := tx.Where("id = ?", order.Id).First(order).Error; err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		record.OrderID = order.Id
+		record.VerificationStatus = "cancelled"
+		record.RedemptionNo = order.RedeemedCount
+		if err := tx.Create(&record).Error; err != nil {
+			return nil, fmt.Errorf("创建取消核销记录失败: %w", err)
+		}
+
+		order.RedeemedCount, order.VerificationStatus = redeemed, status
+		if redeemed == 0 {
+			order.VerifiedAt, order.VerifiedBy = nil, nil
+		}
+		return &record, nil
+	}
+}
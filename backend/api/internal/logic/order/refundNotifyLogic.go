@@ -0,0 +1,112 @@
+// Code scaffolded by goctl. Safe to edit.
+// goctl 1.9.2
+
+package order
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"dmh/api/internal/service"
+	"dmh/api/internal/svc"
+	"dmh/common/wechatpay"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type RefundNotifyLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewRefundNotifyLogic(ctx context.Context, svcCtx *svc.ServiceContext) *RefundNotifyLogic {
+	return &RefundNotifyLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+// RefundNotify 处理微信退款结果通知（v2 XML / v3 JSON），返回 nil 表示通知已受理
+func (l *RefundNotifyLogic) RefundNotify(header http.Header, body []byte) error {
+	if l.svcCtx.WeChatPayService == nil {
+		return errors.New("微信支付服务未初始化")
+	}
+
+	var info *wechatpay.RefundNotifyInfo
+	var err error
+	if IsXMLPaymentNotify(body) {
+		info, err = l.svcCtx.WeChatPayService.ParseRefundNotifyRequest(string(body))
+	} else {
+		info, err = l.svcCtx.WeChatPayService.ParseRefundNotifyV3(header, body)
+	}
+	if err != nil {
+		l.Errorf("解析退款通知失败: %v", err)
+		return err
+	}
+
+	order, err := findOrderByTradeRef(l.svcCtx.DB, info.TransactionID, info.OutTradeNo)
+	if err != nil {
+		l.Errorf("退款通知对应订单不存在: transactionId=%s, outTradeNo=%s, err=%v", info.TransactionID, info.OutTradeNo, err)
+		return err
+	}
+
+	refundNo := strings.TrimSpace(info.OutRefundNo)
+	if refundNo == "" {
+		refundNo = info.RefundID
+	}
+
+	if info.RefundStatus != wechatpay.RefundStatusSuccess {
+		// 退款关闭或异常，释放待处理退款单占用的金额，由管理员重新发起
+		failed, err := failOrderRefund(l.svcCtx.DB, order.Id, refundNo)
+		if err != nil {
+			l.Errorf("标记退款失败出错: orderId=%d, refundNo=%s, err=%v", order.Id, refundNo, err)
+			return err
+		}
+		if !failed {
+			// 只有待处理的退款单能标记失败，已成功的退款单收到失败通知时保留原状态，应答后需人工核对
+			if existing, err := findRefundByNo(l.svcCtx.DB, order.Id, refundNo); err == nil && existing != nil && existing.Status == refundStatusSucceeded {
+				l.Errorf("退款通知与退款单状态冲突，保留成功状态: orderId=%d, refundNo=%s, notifyStatus=%s", order.Id, refundNo, info.RefundStatus)
+				return nil
+			}
+		}
+		l.Errorf("微信退款未成功: orderId=%d, refundNo=%s, status=%s, marked=%v", order.Id, refundNo, info.RefundStatus, failed)
+		return nil
+	}
+
+	refund, duplicated, err := applyOrderRefund(l.svcCtx.DB, l.Logger, orderRefundInput{
+		OrderId:  order.Id,
+		RefundNo: refundNo,
+		Amount:   float64(info.RefundFee) / 100,
+		Source:   "wechat",
+		Reason:   fmt.Sprintf("微信退款 %s", info.RefundID),
+		Seats:    service.NewCampaignSeatService(l.svcCtx.DB, l.svcCtx.SeatCounter),
+		Outbox:   l.svcCtx.Outbox,
+	})
+	if errors.Is(err, errRefundAlreadyFailed) {
+		// 退款单已标记失败又收到成功通知，不覆盖失败状态，应答后需人工核对
+		l.Errorf("退款通知与退款单状态冲突，保留失败状态: orderId=%d, refundNo=%s, refundFee=%d", order.Id, refundNo, info.RefundFee)
+		return nil
+	}
+	if errors.Is(err, errOrderAlreadyRefunded) {
+		// 订单已全额退款仍收到新的退款单，说明两边金额不一致，应答后需人工核对
+		l.Errorf("订单已全额退款，退款通知未入账: orderId=%d, refundNo=%s, refundFee=%d", order.Id, refundNo, info.RefundFee)
+		return nil
+	}
+	if err != nil {
+		l.Errorf("处理退款通知失败: orderId=%d, refundNo=%s, err=%v", order.Id, refundNo, err)
+		return err
+	}
+
+	if duplicated {
+		l.Infof("退款通知已处理: orderId=%d, refundNo=%s", order.Id, refund.RefundNo)
+		return nil
+	}
+
+	l.Infof("微信退款通知处理成功: orderId=%d, refundNo=%s, amount=%.2f", order.Id, refund.RefundNo, refund.Amount)
+	return nil
+}
@@ -0,0 +1,135 @@
+// Code scaffolded by goctl. Safe to edit.
+// goctl 1.9.2
+
+package order
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"dmh/api/internal/middleware"
+	"dmh/api/internal/service"
+	"dmh/api/internal/svc"
+	"dmh/api/internal/types"
+	"dmh/common/wechatpay"
+	"dmh/model"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type RefundOrderLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewRefundOrderLogic(ctx context.Context, svcCtx *svc.ServiceContext) *RefundOrderLogic {
+	return &RefundOrderLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+func (l *RefundOrderLogic) RefundOrder(req *types.RefundOrderReq) (resp *types.RefundOrderResp, err error) {
+	if !middleware.IsPlatformAdmin(l.ctx) {
+		l.Errorf("退款权限不足: orderId=%d", req.Id)
+		return nil, fmt.Errorf("权限不足，仅平台管理员可执行退款操作")
+	}
+
+	operatorId, err := middleware.GetUserIDFromContext(l.ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if l.svcCtx.WeChatPayService == nil {
+		return nil, errors.New("微信支付服务未初始化")
+	}
+
+	refundNo := req.RefundNo
+	if refundNo == "" {
+		refundNo = fmt.Sprintf("RF%d%d", req.Id, time.Now().UnixNano())
+	}
+
+	input := orderRefundInput{
+		OrderId:    req.Id,
+		RefundNo:   refundNo,
+		Amount:     req.Amount,
+		Source:     "admin",
+		Reason:     req.Reason,
+		OperatorId: &operatorId,
+		Seats:      service.NewCampaignSeatService(l.svcCtx.DB, l.svcCtx.SeatCounter),
+		Outbox:     l.svcCtx.Outbox,
+	}
+	refund, order, duplicated, err := reserveOrderRefund(l.svcCtx.DB, input)
+	if err != nil {
+		l.Errorf("订单退款失败: orderId=%d, refundNo=%s, err=%v", req.Id, refundNo, err)
+		return nil, err
+	}
+	if duplicated && refund.Status == refundStatusSucceeded {
+		l.Infof("退款单已处理，直接返回: orderId=%d, refundNo=%s", req.Id, refund.RefundNo)
+		return l.buildResp(refund)
+	}
+
+	// 待处理的退款单（含重试）统一以原退款单号调用微信，由微信保证幂等
+	outTradeNo := order.OutTradeNo
+	if outTradeNo == "" {
+		outTradeNo = strconv.FormatInt(order.Id, 10)
+	}
+	result, err := l.svcCtx.WeChatPayService.Refund(&wechatpay.RefundRequest{
+		TransactionID: order.TradeNo,
+		OutTradeNo:    outTradeNo,
+		OutRefundNo:   refund.RefundNo,
+		TotalFee:      int64(math.Round(order.Amount * 100)),
+		RefundFee:     int64(math.Round(refund.Amount * 100)),
+		Reason:        req.Reason,
+	})
+	if err != nil {
+		// 结果未知时保留待处理退款单，占用的金额不会被重复退款
+		l.Errorf("微信退款结果未知: orderId=%d, refundNo=%s, err=%v", req.Id, refund.RefundNo, err)
+		return nil, fmt.Errorf("微信退款结果未知，请使用相同退款单号 %s 重试: %w", refund.RefundNo, err)
+	}
+	if !result.Accepted {
+		if _, err := failOrderRefund(l.svcCtx.DB, order.Id, refund.RefundNo); err != nil {
+			l.Errorf("标记退款失败出错: orderId=%d, refundNo=%s, err=%v", req.Id, refund.RefundNo, err)
+		}
+		l.Errorf("微信拒绝退款: orderId=%d, refundNo=%s, code=%s, msg=%s", req.Id, refund.RefundNo, result.ErrCode, result.ErrCodeDes)
+		return nil, fmt.Errorf("微信拒绝退款: %s %s", result.ErrCode, result.ErrCodeDes)
+	}
+
+	if result.Status != wechatpay.RefundStatusSuccess {
+		l.Infof("微信已受理退款，等待退款通知: orderId=%d, refundNo=%s, amount=%.2f", req.Id, refund.RefundNo, refund.Amount)
+		return l.buildResp(refund)
+	}
+
+	refund, _, err = applyOrderRefund(l.svcCtx.DB, l.Logger, input)
+	if err != nil {
+		l.Errorf("确认退款失败: orderId=%d, refundNo=%s, err=%v", req.Id, refundNo, err)
+		return nil, err
+	}
+
+	l.Infof("订单退款成功: orderId=%d, refundNo=%s, amount=%.2f, clawback=%.2f", req.Id, refund.RefundNo, refund.Amount, refund.ClawbackAmount)
+	return l.buildResp(refund)
+}
+
+func (l *RefundOrderLogic) buildResp(refund *model.OrderRefund) (*types.RefundOrderResp, error) {
+	var order model.Order
+	if err := l.svcCtx.DB.Select("id", "pay_status", "refunded_amount").First(&order, refund.OrderId).Error; err != nil {
+		return nil, err
+	}
+
+	return &types.RefundOrderResp{
+		OrderId:        refund.OrderId,
+		RefundNo:       refund.RefundNo,
+		Amount:         refund.Amount,
+		ClawbackAmount: refund.ClawbackAmount,
+		Status:         refund.Status,
+		RefundedAmount: order.RefundedAmount,
+		PayStatus:      order.PayStatus,
+		RefundedAt:     refund.CreatedAt.Format("2006-01-02T15:04:05"),
+	}, nil
+}
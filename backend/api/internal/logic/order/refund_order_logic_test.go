@@ -0,0 +1,278 @@
+package order
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"dmh/api/internal/svc"
+	"dmh/api/internal/types"
+	"dmh/common/wechatpay"
+	"dmh/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// newRefundSvcCtx 使用模拟微信支付的退款测试上下文，模拟模式下退款同步成功
+func newRefundSvcCtx(db *gorm.DB) *svc.ServiceContext {
+	payService := wechatpay.NewService(&wechatpay.Config{MchID: "mch-1", APIKey: "key123", MockEnabled: true})
+	return &svc.ServiceContext{DB: db, WeChatPayService: payService}
+}
+
+func refundAdminCtx(userID int64) context.Context {
+	ctx := context.WithValue(context.Background(), "roles", []string{"platform_admin"})
+	return context.WithValue(ctx, "userId", userID)
+}
+
+// createPaidOrderWithReward 创建一笔已支付且已给 user 100 结算 20 元奖励的订单
+func createPaidOrderWithReward(t *testing.T, db *gorm.DB, balance float64) (*model.Order, *model.Distributor) {
+	campaign := &model.Campaign{
+		Name:               "退款活动",
+		FormFields:         `[]`,
+		RewardRule:         1.00,
+		StartTime:          time.Now().Add(-1 * time.Hour),
+		EndTime:            time.Now().Add(24 * time.Hour),
+		Status:             "active",
+		BrandId:            1,
+		EnableDistribution: true,
+	}
+	require.NoError(t, db.Create(campaign).Error)
+
+	distributor := &model.Distributor{UserId: 100, BrandId: 1, Level: 1, Status: "active", TotalEarnings: 20}
+	require.NoError(t, db.Create(distributor).Error)
+	require.NoError(t, db.Create(&model.UserBalance{UserId: 100, Balance: balance, TotalReward: 20}).Error)
+
+	now := time.Now()
+	order := &model.Order{
+		CampaignId: campaign.Id,
+		Phone:      "13800138010",
+		FormData:   `{}`,
+		ReferrerId: 100,
+		Status:     "paid",
+		PayStatus:  "paid",
+		Amount:     200.00,
+		TradeNo:    "TX_REFUND_1",
+		PaidAt:     &now,
+	}
+	require.NoError(t, db.Create(order).Error)
+
+	require.NoError(t, db.Create(&model.DistributorReward{
+		DistributorId: distributor.Id,
+		UserId:        100,
+		OrderId:       order.Id,
+		CampaignId:    campaign.Id,
+		Amount:        20.00,
+		Level:         1,
+		RewardRate:    10,
+		Status:        "settled",
+		SettledAt:     &now,
+	}).Error)
+
+	return order, distributor
+}
+
+func TestRefundOrderLogic_PermissionDenied(t *testing.T) {
+	ctx := context.WithValue(context.Background(), "roles", []string{"brand_admin"})
+	logic := NewRefundOrderLogic(ctx, &svc.ServiceContext{})
+
+	_, err := logic.RefundOrder(&types.RefundOrderReq{Id: 1})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "权限不足")
+}
+
+func TestRefundOrderLogic_ReversesRewardsAndIsIdempotent(t *testing.T) {
+	db := setupTestDB(t)
+	order, distributor := createPaidOrderWithReward(t, db, 5.00)
+
+	logic := NewRefundOrderLogic(refundAdminCtx(1), newRefundSvcCtx(db))
+	resp, err := logic.RefundOrder(&types.RefundOrderReq{Id: order.Id, RefundNo: "RF-001", Reason: "用户取消"})
+	require.NoError(t, err)
+	assert.Equal(t, 200.00, resp.Amount)
+	assert.Equal(t, 20.00, resp.ClawbackAmount)
+	assert.Equal(t, "succeeded", resp.Status)
+	assert.Equal(t, "refunded", resp.PayStatus)
+
+	var updatedOrder model.Order
+	require.NoError(t, db.First(&updatedOrder, order.Id).Error)
+	assert.Equal(t, "refunded", updatedOrder.PayStatus)
+
+	var reward model.DistributorReward
+	require.NoError(t, db.Where("order_id = ?", order.Id).First(&reward).Error)
+	assert.Equal(t, "cancelled", reward.Status)
+
+	var updatedDistributor model.Distributor
+	require.NoError(t, db.First(&updatedDistributor, distributor.Id).Error)
+	assert.Equal(t, 0.00, updatedDistributor.TotalEarnings)
+
+	var balance model.UserBalance
+	require.NoError(t, db.Where("user_id = ?", 100).First(&balance).Error)
+	assert.Equal(t, -15.00, balance.Balance)
+
+	var ledger model.BalanceTransaction
+	require.NoError(t, db.Where("user_id = ? AND type = ?", 100, "refund_clawback").First(&ledger).Error)
+	assert.Equal(t, -20.00, ledger.Amount)
+	assert.Equal(t, 5.00, ledger.BalanceBefore)
+	assert.Equal(t, -15.00, ledger.BalanceAfter)
+
+	again, err := logic.RefundOrder(&types.RefundOrderReq{Id: order.Id, RefundNo: "RF-001"})
+	require.NoError(t, err)
+	assert.Equal(t, resp.RefundNo, again.RefundNo)
+
+	require.NoError(t, db.Where("user_id = ?", 100).First(&balance).Error)
+	assert.Equal(t, -15.00, balance.Balance)
+
+	_, err = logic.RefundOrder(&types.RefundOrderReq{Id: order.Id, RefundNo: "RF-002"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "订单已退款")
+}
+
+func TestRefundOrderLogic_UnpaidOrder(t *testing.T) {
+	db := setupTestDB(t)
+	order := &model.Order{CampaignId: 1, Phone: "13800138011", FormData: `{}`, Status: "pending", PayStatus: "unpaid"}
+	require.NoError(t, db.Create(order).Error)
+
+	logic := NewRefundOrderLogic(refundAdminCtx(1), newRefundSvcCtx(db))
+	_, err := logic.RefundOrder(&types.RefundOrderReq{Id: order.Id, RefundNo: "RF-003"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "订单未支付")
+}
+
+func TestRefundOrderLogic_PartialRefunds(t *testing.T) {
+	db := setupTestDB(t)
+	order, distributor := createPaidOrderWithReward(t, db, 50.00)
+
+	logic := NewRefundOrderLogic(refundAdminCtx(1), newRefundSvcCtx(db))
+	resp, err := logic.RefundOrder(&types.RefundOrderReq{Id: order.Id, RefundNo: "RF-P1", Amount: 50})
+	require.NoError(t, err)
+	assert.Equal(t, 50.00, resp.Amount)
+	assert.Equal(t, 0.00, resp.ClawbackAmount)
+	assert.Equal(t, 50.00, resp.RefundedAmount)
+	assert.Equal(t, "paid", resp.PayStatus)
+
+	// 部分退款不取消订单与奖励
+	var reward model.DistributorReward
+	require.NoError(t, db.Where("order_id = ?", order.Id).First(&reward).Error)
+	assert.Equal(t, "settled", reward.Status)
+
+	_, err = logic.RefundOrder(&types.RefundOrderReq{Id: order.Id, RefundNo: "RF-P2", Amount: 200})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "可退金额")
+
+	resp, err = logic.RefundOrder(&types.RefundOrderReq{Id: order.Id, RefundNo: "RF-P3"})
+	require.NoError(t, err)
+	assert.Equal(t, 150.00, resp.Amount)
+	assert.Equal(t, 20.00, resp.ClawbackAmount)
+	assert.Equal(t, 200.00, resp.RefundedAmount)
+	assert.Equal(t, "refunded", resp.PayStatus)
+
+	var updatedOrder model.Order
+	require.NoError(t, db.First(&updatedOrder, order.Id).Error)
+	assert.Equal(t, "cancelled", updatedOrder.Status)
+
+	var updatedDistributor model.Distributor
+	require.NoError(t, db.First(&updatedDistributor, distributor.Id).Error)
+	assert.Equal(t, 0.00, updatedDistributor.TotalEarnings)
+}
+
+func TestRefundNotifyLogic_FinalizesPendingRefund(t *testing.T) {
+	db := setupTestDB(t)
+	order, _ := createPaidOrderWithReward(t, db, 50.00)
+	require.NoError(t, db.Create(&model.OrderRefund{OrderId: order.Id, RefundNo: "RF-PENDING", Amount: 80, Source: "admin", Status: "pending"}).Error)
+	require.NoError(t, db.Create(&model.OrderRefund{OrderId: order.Id, RefundNo: "RF-CLOSED", Amount: 120, Source: "admin", Status: "pending"}).Error)
+
+	svcCtx := newRefundSvcCtx(db)
+	notify := func(refundNo string, fee int64, status string) []byte {
+		reqInfo, err := svcCtx.WeChatPayService.EncryptRefundReqInfo([]byte("<root>" +
+			"<transaction_id>TX_REFUND_1</transaction_id>" +
+			"<out_refund_no>" + refundNo + "</out_refund_no>" +
+			"<refund_fee>" + strconv.FormatInt(fee, 10) + "</refund_fee>" +
+			"<refund_status>" + status + "</refund_status>" +
+			"</root>"))
+		require.NoError(t, err)
+		return []byte("<xml><return_code>SUCCESS</return_code><mch_id>mch-1</mch_id><req_info>" + reqInfo + "</req_info></xml>")
+	}
+
+	logic := NewRefundNotifyLogic(context.Background(), svcCtx)
+	require.NoError(t, logic.RefundNotify(nil, notify("RF-PENDING", 8000, "SUCCESS")))
+	require.NoError(t, logic.RefundNotify(nil, notify("RF-CLOSED", 12000, "REFUNDCLOSE")))
+
+	var refund model.OrderRefund
+	require.NoError(t, db.Where("refund_no = ?", "RF-PENDING").First(&refund).Error)
+	assert.Equal(t, "succeeded", refund.Status)
+	require.NoError(t, db.Where("refund_no = ?", "RF-CLOSED").First(&refund).Error)
+	assert.Equal(t, "failed", refund.Status)
+
+	var updatedOrder model.Order
+	require.NoError(t, db.First(&updatedOrder, order.Id).Error)
+	assert.Equal(t, "paid", updatedOrder.PayStatus)
+	assert.Equal(t, 80.00, updatedOrder.RefundedAmount)
+}
+
+func TestRefundNotifyLogic_KeepsTerminalRefundStatus(t *testing.T) {
+	db := setupTestDB(t)
+	order, _ := createPaidOrderWithReward(t, db, 50.00)
+	require.NoError(t, db.Create(&model.OrderRefund{OrderId: order.Id, RefundNo: "RF-FAILED", Amount: 80, Source: "admin", Status: "failed"}).Error)
+	require.NoError(t, db.Create(&model.OrderRefund{OrderId: order.Id, RefundNo: "RF-DONE", Amount: 20, Source: "admin", Status: "succeeded"}).Error)
+	require.NoError(t, db.Model(&model.Order{}).Where("id = ?", order.Id).Update("refunded_amount", 20).Error)
+
+	svcCtx := newRefundSvcCtx(db)
+	notify := func(refundNo string, fee int64, status string) []byte {
+		reqInfo, err := svcCtx.WeChatPayService.EncryptRefundReqInfo([]byte("<root>" +
+			"<transaction_id>TX_REFUND_1</transaction_id>" +
+			"<out_refund_no>" + refundNo + "</out_refund_no>" +
+			"<refund_fee>" + strconv.FormatInt(fee, 10) + "</refund_fee>" +
+			"<refund_status>" + status + "</refund_status>" +
+			"</root>"))
+		require.NoError(t, err)
+		return []byte("<xml><return_code>SUCCESS</return_code><mch_id>mch-1</mch_id><req_info>" + reqInfo + "</req_info></xml>")
+	}
+
+	// 迟到的冲突通知照常应答，但不改变已终结的退款单
+	logic := NewRefundNotifyLogic(context.Background(), svcCtx)
+	require.NoError(t, logic.RefundNotify(nil, notify("RF-FAILED", 8000, "SUCCESS")))
+	require.NoError(t, logic.RefundNotify(nil, notify("RF-DONE", 2000, "REFUNDCLOSE")))
+
+	var refund model.OrderRefund
+	require.NoError(t, db.Where("refund_no = ?", "RF-FAILED").First(&refund).Error)
+	assert.Equal(t, "failed", refund.Status)
+	require.NoError(t, db.Where("refund_no = ?", "RF-DONE").First(&refund).Error)
+	assert.Equal(t, "succeeded", refund.Status)
+
+	var updatedOrder model.Order
+	require.NoError(t, db.First(&updatedOrder, order.Id).Error)
+	assert.Equal(t, "paid", updatedOrder.PayStatus)
+	assert.Equal(t, 20.00, updatedOrder.RefundedAmount)
+}
+
+func TestRefundNotifyLogic_Success(t *testing.T) {
+	db := setupTestDB(t)
+	order, _ := createPaidOrderWithReward(t, db, 50.00)
+
+	payService := wechatpay.NewService(&wechatpay.Config{MchID: "mch-1", APIKey: "key123", MockEnabled: true})
+	reqInfo, err := payService.EncryptRefundReqInfo([]byte("<root>" +
+		"<transaction_id>TX_REFUND_1</transaction_id>" +
+		"<out_refund_no>WX-RF-1</out_refund_no>" +
+		"<refund_id>rf-1</refund_id>" +
+		"<refund_fee>20000</refund_fee>" +
+		"<refund_status>SUCCESS</refund_status>" +
+		"</root>"))
+	require.NoError(t, err)
+	body := "<xml><return_code>SUCCESS</return_code><mch_id>mch-1</mch_id><req_info>" + reqInfo + "</req_info></xml>"
+
+	logic := NewRefundNotifyLogic(context.Background(), &svc.ServiceContext{DB: db, WeChatPayService: payService})
+	require.NoError(t, logic.RefundNotify(nil, []byte(body)))
+	require.NoError(t, logic.RefundNotify(nil, []byte(body)))
+
+	var refunds []model.OrderRefund
+	require.NoError(t, db.Where("order_id = ?", order.Id).Find(&refunds).Error)
+	require.Len(t, refunds, 1)
+	assert.Equal(t, "wechat", refunds[0].Source)
+	assert.Equal(t, 200.00, refunds[0].Amount)
+
+	var balance model.UserBalance
+	require.NoError(t, db.Where("user_id = ?", 100).First(&balance).Error)
+	assert.Equal(t, 30.00, balance.Balance)
+}
@@ -5,12 +5,9 @@ package order
 
 import (
 	"context"
-	"crypto/md5"
-	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"strconv"
-	"strings"
+	"time"
 
 	"dmh/api/internal/svc"
 	"dmh/api/internal/types"
@@ -34,15 +31,14 @@ func NewScanOrderLogic(ctx context.Context, svcCtx *svc.ServiceContext) *ScanOrd
 }
 
 func (l *ScanOrderLogic) ScanOrder(req *types.ScanOrderReq) (resp *types.ScanOrderResp, err error) {
-	orderId, phone, timestamp, signature := l.parseVerificationCode(req.Code)
-	if orderId == 0 {
-		l.Errorf("核销码格式无效: %s", req.Code)
-		return nil, fmt.Errorf("核销码无效")
+	// 先校验角色再解析核销码，无权限的请求不能借短码查询探测订单
+	if !hasVerificationPermission(l.ctx) {
+		return nil, fmt.Errorf("权限不足，仅品牌管理员或平台管理员可扫码查看订单")
 	}
 
-	if !l.verifySignature(req.Code, orderId, phone, timestamp, signature) {
-		l.Errorf("核销码签名验证失败: orderId=%d, code=%s", orderId, req.Code)
-		return nil, fmt.Errorf("核销码无效")
+	orderId, err := resolveVerificationCode(l.ctx, l.Logger, l.svcCtx, req.Code, req.CampaignId, time.Now())
+	if err != nil {
+		return nil, err
 	}
 
 	var order model.Order
@@ -50,6 +46,9 @@ func (l *ScanOrderLogic) ScanOrder(req *types.ScanOrderReq) (resp *types.ScanOrd
 		l.Errorf("查询订单失败: %v", err)
 		return nil, fmt.Errorf("订单不存在")
 	}
+	if err := authorizeVerification(l.ctx, l.Logger, l.svcCtx, &order, "扫码查看"); err != nil {
+		return nil, err
+	}
 
 	var formData map[string]string
 	if err := json.Unmarshal([]byte(order.FormData), &formData); err != nil {
@@ -67,36 +66,3 @@ func (l *ScanOrderLogic) ScanOrder(req *types.ScanOrderReq) (resp *types.ScanOrd
 		FormData:  formData,
 	}, nil
 }
-
-func (l *ScanOrderLogic) parseVerificationCode(code string) (orderId int64, phone string, timestamp int64, signature string) {
-	if code == "" {
-		return 0, "", 0, ""
-	}
-
-	parts := strings.Split(code, "_")
-	if len(parts) != 4 {
-		return 0, "", 0, ""
-	}
-
-	orderIdVal := int64(0)
-	if parts[0] != "" {
-		orderIdVal, _ = strconv.ParseInt(parts[0], 10, 64)
-	}
-
-	timestampVal := int64(0)
-	if parts[2] != "" {
-		timestampVal, _ = strconv.ParseInt(parts[2], 10, 64)
-	}
-
-	return orderIdVal, parts[1], timestampVal, parts[3]
-}
-
-func (l *ScanOrderLogic) verifySignature(code string, orderId int64, phone string, timestamp int64, signature string) bool {
-	secretKey := "dmh-verification-secret-2026"
-
-	signatureData := fmt.Sprintf("%d_%s_%d_%s", orderId, phone, timestamp, secretKey)
-	hash := md5.Sum([]byte(signatureData))
-	expectedSignature := hex.EncodeToString(hash[:])
-
-	return signature == expectedSignature
-}
@@ -0,0 +1,106 @@
+package order
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"dmh/api/internal/middleware"
+	"dmh/api/internal/service"
+	"dmh/api/internal/svc"
+	"dmh/common/metrics"
+	"dmh/model"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+var errVerificationAttemptsExceeded = errors.New("核销码错误次数过多，请稍后再试")
+
+// resolveVerificationCode 校验核销码或短码并返回订单ID，扫码、核销与取消核销共用；at 为核销发生时间，离线核销时为设备时间
+func resolveVerificationCode(ctx context.Context, logger logx.Logger, svcCtx *svc.ServiceContext, code string, campaignId int64, at time.Time) (int64, error) {
+	if shortCode, ok := service.NormalizeShortCode(code); ok {
+		return resolveShortCode(ctx, logger, svcCtx, shortCode, campaignId)
+	}
+
+	if svcCtx.VerificationTokens == nil {
+		logger.Errorf("核销码服务未配置，无法校验核销码")
+		return 0, fmt.Errorf("核销服务暂不可用")
+	}
+
+	token, err := svcCtx.VerificationTokens.ParseAt(code, at)
+	if errors.Is(err, service.ErrVerificationTokenExpired) {
+		logger.Errorf("核销码已过期: orderId=%d, legacy=%v", token.OrderId, token.Legacy)
+		return 0, err
+	}
+	if err != nil {
+		logger.Errorf("核销码校验失败: code=%s", code)
+		return 0, fmt.Errorf("核销码无效")
+	}
+	if token.Legacy {
+		logger.Infof("使用旧格式核销码: orderId=%d", token.OrderId)
+	}
+	return token.OrderId, nil
+}
+
+// resolveShortCode 按短码查找订单；短码可被猜测，失败次数按用户或IP限流，超限后记录安全事件
+func resolveShortCode(ctx context.Context, logger logx.Logger, svcCtx *svc.ServiceContext, shortCode string, campaignId int64) (int64, error) {
+	actor := verificationActor(ctx)
+	limiter := svcCtx.VerificationLimiter
+	if limiter != nil && limiter.GetRemaining(actor) <= 0 {
+		metrics.RateLimitRejections.WithLabelValues("verification_code").Inc()
+		logger.Errorf("短码核销已锁定: actor=%s", actor)
+		return 0, errVerificationAttemptsExceeded
+	}
+
+	query := svcCtx.DB.Model(&model.Order{}).Where("short_code = ? AND deleted_at IS NULL", shortCode)
+	if campaignId > 0 {
+		query = query.Where("campaign_id = ?", campaignId)
+	}
+	var orderIds []int64
+	if err := query.Limit(2).Pluck("id", &orderIds).Error; err != nil {
+		logger.Errorf("按短码查询订单失败: %v", err)
+		return 0, fmt.Errorf("核销码查询失败")
+	}
+
+	switch len(orderIds) {
+	case 1:
+		return orderIds[0], nil
+	case 0:
+		recordShortCodeFailure(ctx, logger, svcCtx, actor, shortCode, campaignId)
+		return 0, fmt.Errorf("核销码无效")
+	default:
+		return 0, fmt.Errorf("该短码对应多个活动，请指定活动")
+	}
+}
+
+func recordShortCodeFailure(ctx context.Context, logger logx.Logger, svcCtx *svc.ServiceContext, actor, shortCode string, campaignId int64) {
+	logger.Errorf("短码不存在: actor=%s, campaignId=%d, code=%s", actor, campaignId, shortCode)
+
+	limiter := svcCtx.VerificationLimiter
+	if limiter == nil {
+		return
+	}
+	limiter.Allow(actor)
+	if limiter.GetRemaining(actor) > 0 {
+		return
+	}
+
+	// 本次失败触发锁定，每个锁定窗口只记录一次
+	logVerificationSecurityEvent(ctx, logger, svcCtx, "verification_code_brute_force", "high",
+		fmt.Sprintf("%s 短码核销失败次数过多，已锁定至 %s", actor, limiter.GetResetTime(actor).Format("2006-01-02 15:04:05")),
+		map[string]interface{}{
+			"actor":       actor,
+			"campaign_id": campaignId,
+			"last_code":   shortCode,
+		},
+	)
+}
+
+// verificationActor 限流维度：已登录按用户，否则按客户端IP
+func verificationActor(ctx context.Context) string {
+	if uid, err := middleware.GetUserIDFromContext(ctx); err == nil && uid > 0 {
+		return fmt.Sprintf("user:%d", uid)
+	}
+	return "ip:" + middleware.GetClientInfo(ctx).IP
+}
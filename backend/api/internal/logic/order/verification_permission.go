@@ -2,55 +2,147 @@ package order
 
 import (
 	"context"
-	"strings"
+	"errors"
+	"fmt"
+
+	"dmh/api/internal/middleware"
+	"dmh/api/internal/service"
+	"dmh/api/internal/svc"
+	"dmh/model"
+
+	"github.com/zeromicro/go-zero/core/logx"
 )
 
+var (
+	errVerificationCrossBrand = errors.New("无权核销其他品牌的订单")
+	errVerificationOutOfScope = errors.New("该订单不在您的核销范围内")
+)
+
+// hasVerificationPermission 品牌管理员与平台管理员可执行核销
 func hasVerificationPermission(ctx context.Context) bool {
 	if ctx == nil {
 		return false
 	}
+	return middleware.HasRole(ctx, "brand_admin")
+}
 
-	rolesVal := ctx.Value("roles")
-	if rolesVal == nil {
-		return false
+// authorizeVerification 校验当前用户可操作订单：订单所属活动的品牌须可访问，配置了核销范围的人员只能操作范围内的活动或场地。
+// 越权尝试记录安全事件
+func authorizeVerification(ctx context.Context, logger logx.Logger, svcCtx *svc.ServiceContext, order *model.Order, action string) error {
+	var campaign model.Campaign
+	if err := svcCtx.DB.Select("id", "brand_id", "venue").Where("id = ?", order.CampaignId).First(&campaign).Error; err != nil {
+		logger.Errorf("查询订单所属活动失败: orderId=%d, err=%v", order.Id, err)
+		return fmt.Errorf("订单所属活动不存在")
 	}
+	return authorizeCampaignVerification(ctx, logger, svcCtx, &campaign, order.Id, action)
+}
 
-	roles := normalizeRoles(rolesVal)
-	if len(roles) == 0 {
-		return false
+// authorizeCampaignVerification 按活动校验核销权限，orderId 仅用于安全事件记录，按活动整体操作时为 0
+func authorizeCampaignVerification(ctx context.Context, logger logx.Logger, svcCtx *svc.ServiceContext, campaign *model.Campaign, orderId int64, action string) error {
+	details := map[string]interface{}{
+		"action":      action,
+		"order_id":    orderId,
+		"campaign_id": campaign.Id,
+		"brand_id":    campaign.BrandId,
+	}
+	target := fmt.Sprintf("活动 %d", campaign.Id)
+	if orderId > 0 {
+		target = fmt.Sprintf("订单 %d（活动 %d）", orderId, campaign.Id)
 	}
 
-	for _, role := range roles {
-		if role == "platform_admin" || role == "brand_admin" {
-			return true
+	if !middleware.CanAccessBrand(ctx, campaign.BrandId) {
+		logVerificationSecurityEvent(ctx, logger, svcCtx, "verification_cross_brand", "high",
+			fmt.Sprintf("%s越权：%s属于品牌 %d", action, target, campaign.BrandId), details)
+		return errVerificationCrossBrand
+	}
+	if middleware.IsPlatformAdmin(ctx) {
+		return nil
+	}
+
+	scopes, err := loadVerificationScopes(ctx, svcCtx, campaign.BrandId)
+	if err != nil {
+		logger.Errorf("查询核销范围失败: %v", err)
+		return fmt.Errorf("核销权限校验失败")
+	}
+	if len(scopes) == 0 {
+		return nil
+	}
+	for i := range scopes {
+		if scopes[i].Matches(campaign) {
+			return nil
 		}
 	}
 
-	return false
+	details["venue"] = campaign.Venue
+	logVerificationSecurityEvent(ctx, logger, svcCtx, "verification_out_of_scope", "medium",
+		fmt.Sprintf("%s越权：%s不在核销范围内", action, target), details)
+	return errVerificationOutOfScope
 }
 
-func normalizeRoles(raw interface{}) []string {
-	switch v := raw.(type) {
-	case []string:
-		return v
-	case []interface{}:
-		roles := make([]string, 0, len(v))
-		for _, item := range v {
-			if s, ok := item.(string); ok {
-				trimmed := strings.TrimSpace(s)
-				if trimmed != "" {
-					roles = append(roles, trimmed)
-				}
+// verifiableCampaignIds 当前用户可查看核销记录的活动；平台管理员返回 all=true
+func verifiableCampaignIds(ctx context.Context, svcCtx *svc.ServiceContext) (ids []int64, all bool, err error) {
+	if middleware.IsPlatformAdmin(ctx) {
+		return nil, true, nil
+	}
+	brandIds, err := middleware.GetUserBrandIDs(ctx)
+	if err != nil || len(brandIds) == 0 {
+		return []int64{}, false, err
+	}
+
+	var campaigns []model.Campaign
+	if err := svcCtx.DB.Select("id", "brand_id", "venue").Where("brand_id IN ?", brandIds).Find(&campaigns).Error; err != nil {
+		return nil, false, err
+	}
+
+	scopes, err := loadVerificationScopes(ctx, svcCtx, brandIds...)
+	if err != nil {
+		return nil, false, err
+	}
+	scopesByBrand := make(map[int64][]model.VerificationScope)
+	for _, scope := range scopes {
+		scopesByBrand[scope.BrandId] = append(scopesByBrand[scope.BrandId], scope)
+	}
+
+	ids = make([]int64, 0, len(campaigns))
+	for i := range campaigns {
+		scopes := scopesByBrand[campaigns[i].BrandId]
+		allowed := len(scopes) == 0
+		for j := range scopes {
+			if scopes[j].Matches(&campaigns[i]) {
+				allowed = true
+				break
 			}
 		}
-		return roles
-	case string:
-		trimmed := strings.TrimSpace(v)
-		if trimmed == "" {
-			return nil
+		if allowed {
+			ids = append(ids, campaigns[i].Id)
 		}
-		return []string{trimmed}
-	default:
-		return nil
+	}
+	return ids, false, nil
+}
+
+// loadVerificationScopes 当前用户在指定品牌下的核销范围
+func loadVerificationScopes(ctx context.Context, svcCtx *svc.ServiceContext, brandIds ...int64) ([]model.VerificationScope, error) {
+	userId, err := middleware.GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, nil
+	}
+	var scopes []model.VerificationScope
+	err = svcCtx.DB.Where("user_id = ? AND brand_id IN ?", userId, brandIds).Find(&scopes).Error
+	return scopes, err
+}
+
+// logVerificationSecurityEvent 记录核销相关的安全事件
+func logVerificationSecurityEvent(ctx context.Context, logger logx.Logger, svcCtx *svc.ServiceContext, eventType, severity, description string, details map[string]interface{}) {
+	logger.Errorf("核销安全事件 %s: actor=%s, %s", eventType, verificationActor(ctx), description)
+
+	client := middleware.GetClientInfo(ctx)
+	var userId *int64
+	if uid, err := middleware.GetUserIDFromContext(ctx); err == nil {
+		userId = &uid
+	}
+	err := service.NewAuditService(svcCtx.DB).LogSecurityEvent(eventType, severity, userId, "",
+		client.IP, client.UserAgent, description, details)
+	if err != nil {
+		logger.Errorf("记录安全事件失败: %v", err)
 	}
 }
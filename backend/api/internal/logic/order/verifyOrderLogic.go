@@ -5,13 +5,11 @@ package order
 
 import (
 	"context"
-	"crypto/md5"
-	"encoding/hex"
 	"fmt"
-	"strconv"
-	"strings"
 	"time"
 
+	"dmh/api/internal/middleware"
+	"dmh/api/internal/service"
 	"dmh/api/internal/svc"
 	"dmh/api/internal/types"
 	"dmh/model"
@@ -34,30 +32,33 @@ func NewVerifyOrderLogic(ctx context.Context, svcCtx *svc.ServiceContext) *Verif
 }
 
 func (l *VerifyOrderLogic) VerifyOrder(req *types.VerifyOrderReq) (resp *types.VerifyOrderResp, err error) {
-	orderId, phone, timestamp, signature := l.parseVerificationCode(req.Code)
-	if orderId == 0 {
-		l.Errorf("核销码格式无效: %s", req.Code)
-		return nil, fmt.Errorf("核销码无效")
-	}
-
-	if !l.verifySignature(req.Code, orderId, phone, timestamp, signature) {
-		l.Errorf("核销码签名验证失败: orderId=%d, code=%s", orderId, req.Code)
-		return nil, fmt.Errorf("核销码无效")
-	}
-
+	// 先校验角色再解析核销码，无权限的请求不能借短码查询探测订单
 	if !hasVerificationPermission(l.ctx) {
 		l.Errorf("核销权限不足: code=%s", req.Code)
 		return nil, fmt.Errorf("权限不足，仅品牌管理员或平台管理员可执行核销操作")
 	}
 
+	orderId, err := resolveVerificationCode(l.ctx, l.Logger, l.svcCtx, req.Code, req.CampaignId, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
 	var order model.Order
 	if err := l.svcCtx.DB.Where("id = ? AND deleted_at IS NULL", orderId).First(&order).Error; err != nil {
 		l.Errorf("查询订单失败: %v", err)
 		return nil, fmt.Errorf("订单不存在")
 	}
+	if err := authorizeVerification(l.ctx, l.Logger, l.svcCtx, &order, "核销"); err != nil {
+		return nil, err
+	}
 
-	if order.VerificationStatus == "verified" {
-		return nil, fmt.Errorf("订单已核销")
+	switch {
+	case order.PayStatus == "refunded":
+		return nil, errOrderRefunded
+	case order.Status == "cancelled":
+		return nil, errOrderCancelled
+	case order.RemainingRedemptions() == 0:
+		return nil, errRedemptionsExhausted
 	}
 
 	remark := "品牌管理员核销"
@@ -69,30 +70,30 @@ func (l *VerifyOrderLogic) VerifyOrder(req *types.VerifyOrderReq) (resp *types.V
 		}
 	}()
 
-	now := time.Now()
-	if err := tx.Model(&order).Updates(map[string]interface{}{
-		"verification_status": "verified",
-		"verified_at":         &now,
-		"verified_by":         l.getUserId(),
-	}).Error; err != nil {
-		tx.Rollback()
-		l.Errorf("更新订单核销状态失败: %v", err)
-		return nil, fmt.Errorf("核销失败: %w", err)
+	var verifiedBy int64
+	if uid, err := middleware.GetUserIDFromContext(l.ctx); err == nil {
+		verifiedBy = uid
 	}
 
-	verifiedBy := l.getUserId()
-	verificationRecord := model.VerificationRecord{
-		OrderID:            order.Id,
-		VerificationStatus: "verified",
-		VerifiedAt:         &now,
-		VerifiedBy:         &verifiedBy,
+	// 多次卡每次核销消耗一次，各自生成核销记录
+	now := time.Now()
+	record, err := redeemOrder(tx, &order, model.VerificationRecord{
 		VerificationCode:   req.Code,
 		VerificationMethod: "manual",
 		Remark:             remark,
+	}, now, verifiedBy)
+	if err != nil {
+		tx.Rollback()
+		l.Errorf("核销订单失败: orderId=%d, err=%v", order.Id, err)
+		if isRedemptionRejected(err) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("核销失败: %w", err)
 	}
-	if err := tx.Create(&verificationRecord).Error; err != nil {
+
+	if err := l.svcCtx.Webhooks.PublishOrder(tx, service.WebhookEventOrderVerified, &order); err != nil {
 		tx.Rollback()
-		l.Errorf("创建核销记录失败: %v", err)
+		l.Errorf("记录 Webhook 事件失败: %v", err)
 		return nil, fmt.Errorf("核销失败 %w", err)
 	}
 
@@ -101,60 +102,15 @@ func (l *VerifyOrderLogic) VerifyOrder(req *types.VerifyOrderReq) (resp *types.V
 		return nil, fmt.Errorf("核销失败 %w", err)
 	}
 
-	l.Infof("订单核销成功: orderId=%d, code=%s, remark=%s", orderId, req.Code, remark)
+	l.Infof("订单核销成功: orderId=%d, redemption=%d/%d, code=%s, remark=%s",
+		orderId, record.RedemptionNo, order.RedemptionQuota, req.Code, remark)
 
 	return &types.VerifyOrderResp{
-		OrderId:    order.Id,
-		Status:     "verified",
-		VerifiedAt: now.Format("2006-01-02T15:04:05"),
+		OrderId:         order.Id,
+		Status:          order.VerificationStatus,
+		VerifiedAt:      now.Format("2006-01-02T15:04:05"),
+		RedemptionNo:    record.RedemptionNo,
+		RedeemedCount:   order.RedeemedCount,
+		RedemptionQuota: order.RedemptionQuota,
 	}, nil
 }
-
-func (l *VerifyOrderLogic) parseVerificationCode(code string) (orderId int64, phone string, timestamp int64, signature string) {
-	if code == "" {
-		return 0, "", 0, ""
-	}
-
-	parts := strings.Split(code, "_")
-	if len(parts) != 4 {
-		return 0, "", 0, ""
-	}
-
-	orderIdVal := int64(0)
-	if parts[0] != "" {
-		orderIdVal, _ = strconv.ParseInt(parts[0], 10, 64)
-	}
-
-	timestampVal := int64(0)
-	if parts[2] != "" {
-		timestampVal, _ = strconv.ParseInt(parts[2], 10, 64)
-	}
-
-	return orderIdVal, parts[1], timestampVal, parts[3]
-}
-
-func (l *VerifyOrderLogic) verifySignature(code string, orderId int64, phone string, timestamp int64, signature string) bool {
-	secretKey := "dmh-verification-secret-2026"
-
-	signatureData := fmt.Sprintf("%d_%s_%d_%s", orderId, phone, timestamp, secretKey)
-	hash := md5.Sum([]byte(signatureData))
-	expectedSignature := hex.EncodeToString(hash[:])
-
-	if signature != expectedSignature {
-		return false
-	}
-
-	return true
-}
-
-func (l *VerifyOrderLogic) getUserId() int64 {
-	userIdValue := l.ctx.Value("userId")
-	if userIdValue != nil {
-		if uid, ok := userIdValue.(int64); ok {
-			return uid
-		} else if uid, ok := userIdValue.(float64); ok {
-			return int64(uid)
-		}
-	}
-	return 0
-}
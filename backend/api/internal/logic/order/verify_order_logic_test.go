@@ -2,9 +2,12 @@ package order
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
+	"dmh/api/internal/middleware"
 	"dmh/api/internal/svc"
 	"dmh/api/internal/types"
 	"dmh/model"
@@ -15,29 +18,29 @@ import (
 
 func verificationAdminCtx(userID int64) context.Context {
 	ctx := context.WithValue(context.Background(), "roles", []string{"brand_admin"})
+	ctx = context.WithValue(ctx, "brandIds", []int64{1})
 	if userID > 0 {
 		ctx = context.WithValue(ctx, "userId", userID)
 	}
 	return ctx
 }
 
-func createVerificationCode(orderID int64, phone string) string {
-	createLogic := &CreateOrderLogic{}
-	return createLogic.generateVerificationCode(orderID, phone, time.Now().Unix())
-}
-
 func insertOrderForVerification(t *testing.T, dbCtx *svc.ServiceContext, status string, verificationStatus string) *model.Order {
+	campaign := createTestCampaign(t, dbCtx.DB)
 	order := &model.Order{
-		CampaignId:         1,
+		CampaignId:         campaign.Id,
 		Phone:              "13800138000",
 		FormData:           `{"name":"测试"}`,
 		Status:             status,
 		PayStatus:          "paid",
 		VerificationStatus: verificationStatus,
 	}
+	if verificationStatus == "verified" {
+		order.RedeemedCount = 1
+	}
 	require.NoError(t, dbCtx.DB.Create(order).Error)
 
-	code := createVerificationCode(order.Id, order.Phone)
+	code := issueTestVerificationCode(t, order.Id)
 	require.NoError(t, dbCtx.DB.Model(order).Update("verification_code", code).Error)
 	order.VerificationCode = code
 	return order
@@ -46,7 +49,7 @@ func insertOrderForVerification(t *testing.T, dbCtx *svc.ServiceContext, status
 func TestVerifyOrderLogic_InvalidCode(t *testing.T) {
 	db := setupTestDB(t)
 
-	logic := NewVerifyOrderLogic(context.Background(), &svc.ServiceContext{DB: db})
+	logic := NewVerifyOrderLogic(context.Background(), newTestSvcCtx(db))
 	resp, err := logic.VerifyOrder(&types.VerifyOrderReq{Code: "invalid_code"})
 
 	assert.Error(t, err)
@@ -57,8 +60,8 @@ func TestVerifyOrderLogic_InvalidCode(t *testing.T) {
 func TestVerifyOrderLogic_OrderNotFound(t *testing.T) {
 	db := setupTestDB(t)
 
-	logic := NewVerifyOrderLogic(verificationAdminCtx(0), &svc.ServiceContext{DB: db})
-	missingCode := createVerificationCode(9999, "13800138000")
+	logic := NewVerifyOrderLogic(verificationAdminCtx(0), newTestSvcCtx(db))
+	missingCode := issueTestVerificationCode(t, 9999)
 
 	resp, err := logic.VerifyOrder(&types.VerifyOrderReq{Code: missingCode})
 	assert.Error(t, err)
@@ -69,7 +72,7 @@ func TestVerifyOrderLogic_OrderNotFound(t *testing.T) {
 func TestVerifyOrderLogic_CreatesVerificationRecord(t *testing.T) {
 	db := setupTestDB(t)
 
-	svcCtx := &svc.ServiceContext{DB: db}
+	svcCtx := newTestSvcCtx(db)
 	order := insertOrderForVerification(t, svcCtx, "paid", "unverified")
 
 	logic := NewVerifyOrderLogic(verificationAdminCtx(777), svcCtx)
@@ -95,7 +98,7 @@ func TestVerifyOrderLogic_CreatesVerificationRecord(t *testing.T) {
 func TestVerifyOrderLogic_PermissionDenied(t *testing.T) {
 	db := setupTestDB(t)
 
-	svcCtx := &svc.ServiceContext{DB: db}
+	svcCtx := newTestSvcCtx(db)
 	order := insertOrderForVerification(t, svcCtx, "paid", "unverified")
 
 	ctx := context.WithValue(context.Background(), "roles", []string{"participant"})
@@ -110,7 +113,7 @@ func TestVerifyOrderLogic_PermissionDenied(t *testing.T) {
 func TestUnverifyOrderLogic_Success(t *testing.T) {
 	db := setupTestDB(t)
 
-	svcCtx := &svc.ServiceContext{DB: db}
+	svcCtx := newTestSvcCtx(db)
 	order := insertOrderForVerification(t, svcCtx, "paid", "verified")
 
 	logic := NewUnverifyOrderLogic(verificationAdminCtx(0), svcCtx)
@@ -134,7 +137,7 @@ func TestUnverifyOrderLogic_Success(t *testing.T) {
 func TestUnverifyOrderLogic_OrderNotVerified(t *testing.T) {
 	db := setupTestDB(t)
 
-	svcCtx := &svc.ServiceContext{DB: db}
+	svcCtx := newTestSvcCtx(db)
 	order := insertOrderForVerification(t, svcCtx, "paid", "unverified")
 
 	logic := NewUnverifyOrderLogic(verificationAdminCtx(0), svcCtx)
@@ -147,7 +150,7 @@ func TestUnverifyOrderLogic_OrderNotVerified(t *testing.T) {
 func TestUnverifyOrderLogic_PermissionDenied(t *testing.T) {
 	db := setupTestDB(t)
 
-	svcCtx := &svc.ServiceContext{DB: db}
+	svcCtx := newTestSvcCtx(db)
 	order := insertOrderForVerification(t, svcCtx, "paid", "verified")
 
 	ctx := context.WithValue(context.Background(), "roles", []string{"participant"})
@@ -162,11 +165,249 @@ func TestUnverifyOrderLogic_PermissionDenied(t *testing.T) {
 func TestUnverifyOrderLogic_OrderNotFound(t *testing.T) {
 	db := setupTestDB(t)
 
-	logic := NewUnverifyOrderLogic(verificationAdminCtx(0), &svc.ServiceContext{DB: db})
-	missingCode := createVerificationCode(9999, "13800138000")
+	logic := NewUnverifyOrderLogic(verificationAdminCtx(0), newTestSvcCtx(db))
+	missingCode := issueTestVerificationCode(t, 9999)
 
 	resp, err := logic.UnverifyOrder(&types.UnverifyOrderReq{Code: missingCode})
 	assert.Error(t, err)
 	assert.Nil(t, resp)
 	assert.Contains(t, err.Error(), "订单不存在")
 }
+
+func TestVerifyOrderLogic_ShortCode(t *testing.T) {
+	db := setupTestDB(t)
+
+	svcCtx := newTestSvcCtx(db)
+	order := insertOrderForVerification(t, svcCtx, "paid", "unverified")
+	require.NoError(t, db.Model(order).Update("short_code", "ABC234").Error)
+
+	// 忽略大小写与分隔符
+	scan, err := NewScanOrderLogic(verificationAdminCtx(1), svcCtx).ScanOrder(&types.ScanOrderReq{Code: "abc-234"})
+	require.NoError(t, err)
+	assert.Equal(t, order.Id, scan.OrderId)
+
+	_, err = NewVerifyOrderLogic(verificationAdminCtx(1), svcCtx).VerifyOrder(&types.VerifyOrderReq{Code: "ABC234", CampaignId: order.CampaignId + 1})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "核销码无效")
+
+	resp, err := NewVerifyOrderLogic(verificationAdminCtx(1), svcCtx).VerifyOrder(&types.VerifyOrderReq{Code: "ABC234", CampaignId: order.CampaignId})
+	require.NoError(t, err)
+	assert.Equal(t, order.Id, resp.OrderId)
+}
+
+func TestScanOrderLogic_ShortCodeBruteForceLocksOut(t *testing.T) {
+	db := setupTestDB(t)
+
+	svcCtx := newTestSvcCtx(db)
+	svcCtx.VerificationLimiter = middleware.NewMemoryRateLimiter(3, time.Minute)
+	order := insertOrderForVerification(t, svcCtx, "paid", "unverified")
+	require.NoError(t, db.Model(order).Update("short_code", "ZZZ999").Error)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/orders/scan", nil)
+	req.RemoteAddr = "203.0.113.7:51234"
+	ctx := middleware.WithClientInfo(req.WithContext(verificationAdminCtx(1)))
+
+	for _, guess := range []string{"AAA222", "AAA223", "AAA224"} {
+		_, err := NewScanOrderLogic(ctx, svcCtx).ScanOrder(&types.ScanOrderReq{Code: guess})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "核销码无效")
+	}
+
+	// 锁定后正确的短码也被拒绝
+	_, err := NewScanOrderLogic(ctx, svcCtx).ScanOrder(&types.ScanOrderReq{Code: "ZZZ999"})
+	assert.ErrorIs(t, err, errVerificationAttemptsExceeded)
+
+	var events []model.SecurityEvent
+	require.NoError(t, db.Where("event_type = ?", "verification_code_brute_force").Find(&events).Error)
+	require.Len(t, events, 1)
+	assert.Equal(t, "203.0.113.7", events[0].ClientIP)
+	assert.Equal(t, "high", events[0].Severity)
+
+	// 其他来源不受影响
+	other := httptest.NewRequest(http.MethodGet, "/api/v1/orders/scan", nil)
+	other.RemoteAddr = "198.51.100.1:40000"
+	scan, err := NewScanOrderLogic(middleware.WithClientInfo(other.WithContext(verificationAdminCtx(1))), svcCtx).ScanOrder(&types.ScanOrderReq{Code: "ZZZ999"})
+	require.NoError(t, err)
+	assert.Equal(t, order.Id, scan.OrderId)
+}
+
+func TestScanOrderLogic_PermissionCheckedBeforeShortCode(t *testing.T) {
+	db := setupTestDB(t)
+
+	svcCtx := newTestSvcCtx(db)
+	svcCtx.VerificationLimiter = middleware.NewMemoryRateLimiter(1, time.Minute)
+	order := insertOrderForVerification(t, svcCtx, "paid", "unverified")
+	require.NoError(t, db.Model(order).Update("short_code", "QRS567").Error)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/orders/scan", nil)
+	req.RemoteAddr = "203.0.113.9:51234"
+	participant := middleware.WithClientInfo(req.WithContext(context.WithValue(context.Background(), "roles", []string{"participant"})))
+
+	// 无权限请求直接拒绝，不解析短码也不消耗尝试次数
+	for _, guess := range []string{"QRS567", "AAA222", "AAA223"} {
+		_, err := NewScanOrderLogic(participant, svcCtx).ScanOrder(&types.ScanOrderReq{Code: guess})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "权限不足")
+	}
+
+	admin := middleware.WithClientInfo(req.WithContext(verificationAdminCtx(1)))
+	scan, err := NewScanOrderLogic(admin, svcCtx).ScanOrder(&types.ScanOrderReq{Code: "QRS567"})
+	require.NoError(t, err)
+	assert.Equal(t, order.Id, scan.OrderId)
+}
+
+func TestVerifyOrderLogic_MultiUseRedemptions(t *testing.T) {
+	db := setupTestDB(t)
+
+	svcCtx := newTestSvcCtx(db)
+	order := insertOrderForVerification(t, svcCtx, "paid", "unverified")
+	require.NoError(t, db.Model(order).Update("redemption_quota", 3).Error)
+
+	logic := NewVerifyOrderLogic(verificationAdminCtx(777), svcCtx)
+	for i, status := range []string{"partial", "partial", "verified"} {
+		resp, err := logic.VerifyOrder(&types.VerifyOrderReq{Code: order.VerificationCode})
+		require.NoError(t, err)
+		assert.Equal(t, status, resp.Status)
+		assert.Equal(t, i+1, resp.RedemptionNo)
+		assert.Equal(t, 3, resp.RedemptionQuota)
+	}
+
+	_, err := logic.VerifyOrder(&types.VerifyOrderReq{Code: order.VerificationCode})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "订单已核销")
+
+	var records []model.VerificationRecord
+	require.NoError(t, db.Where("order_id = ?", order.Id).Order("id ASC").Find(&records).Error)
+	require.Len(t, records, 3)
+	for i, record := range records {
+		assert.Equal(t, i+1, record.RedemptionNo)
+	}
+}
+
+func TestVerifyOrderLogic_RejectsRefundedAndCancelled(t *testing.T) {
+	db := setupTestDB(t)
+
+	svcCtx := newTestSvcCtx(db)
+	refunded := insertOrderForVerification(t, svcCtx, "paid", "unverified")
+	require.NoError(t, db.Model(refunded).Update("pay_status", "refunded").Error)
+	cancelled := insertOrderForVerification(t, svcCtx, "paid", "unverified")
+	require.NoError(t, db.Model(cancelled).Update("status", "cancelled").Error)
+
+	logic := NewVerifyOrderLogic(verificationAdminCtx(777), svcCtx)
+	_, err := logic.VerifyOrder(&types.VerifyOrderReq{Code: refunded.VerificationCode})
+	assert.ErrorIs(t, err, errOrderRefunded)
+	_, err = logic.VerifyOrder(&types.VerifyOrderReq{Code: cancelled.VerificationCode})
+	assert.ErrorIs(t, err, errOrderCancelled)
+
+	// 读取订单后才退款：条件更新拦截，不写入核销记录
+	stale := insertOrderForVerification(t, svcCtx, "paid", "unverified")
+	require.NoError(t, db.Model(&model.Order{}).Where("id = ?", stale.Id).Update("pay_status", "refunded").Error)
+	_, err = redeemOrder(db, stale, model.VerificationRecord{VerificationMethod: "manual"}, time.Now(), 777)
+	assert.ErrorIs(t, err, errOrderRefunded)
+
+	var count int64
+	require.NoError(t, db.Model(&model.VerificationRecord{}).
+		Where("order_id IN ?", []int64{refunded.Id, cancelled.Id, stale.Id}).Count(&count).Error)
+	assert.Equal(t, int64(0), count)
+}
+
+func TestUnverifyOrderLogic_ReversesSingleRedemption(t *testing.T) {
+	db := setupTestDB(t)
+
+	svcCtx := newTestSvcCtx(db)
+	order := insertOrderForVerification(t, svcCtx, "paid", "unverified")
+	require.NoError(t, db.Model(order).Update("redemption_quota", 3).Error)
+
+	verify := NewVerifyOrderLogic(verificationAdminCtx(777), svcCtx)
+	for i := 0; i < 2; i++ {
+		_, err := verify.VerifyOrder(&types.VerifyOrderReq{Code: order.VerificationCode})
+		require.NoError(t, err)
+	}
+
+	unverify := NewUnverifyOrderLogic(verificationAdminCtx(777), svcCtx)
+	resp, err := unverify.UnverifyOrder(&types.UnverifyOrderReq{Code: order.VerificationCode})
+	require.NoError(t, err)
+	assert.Equal(t, "partial", resp.Status)
+	assert.Equal(t, 2, resp.RedemptionNo)
+	assert.Equal(t, 1, resp.RedeemedCount)
+
+	var fresh model.Order
+	require.NoError(t, db.First(&fresh, order.Id).Error)
+	assert.Equal(t, 1, fresh.RedeemedCount)
+	assert.Equal(t, "partial", fresh.VerificationStatus)
+	assert.NotNil(t, fresh.VerifiedAt)
+
+	// 使用记录：两次核销与一次撤销
+	history, err := NewGetVerificationRecordsLogic(verificationAdminCtx(777), svcCtx).
+		GetVerificationRecords(&types.VerificationRecordsReq{OrderId: order.Id})
+	require.NoError(t, err)
+	require.Len(t, history.Records, 3)
+	assert.Equal(t, "cancelled", history.Records[0].VerificationStatus)
+	assert.Equal(t, 2, history.Records[0].RedemptionNo)
+
+	resp, err = unverify.UnverifyOrder(&types.UnverifyOrderReq{Code: order.VerificationCode})
+	require.NoError(t, err)
+	assert.Equal(t, "unverified", resp.Status)
+	require.NoError(t, db.First(&fresh, order.Id).Error)
+	assert.Equal(t, "cancelled", fresh.VerificationStatus)
+	assert.Nil(t, fresh.VerifiedAt)
+}
+
+func TestVerifyOrderLogic_CrossBrandDenied(t *testing.T) {
+	db := setupTestDB(t)
+
+	svcCtx := newTestSvcCtx(db)
+	order := insertOrderForVerification(t, svcCtx, "paid", "unverified")
+
+	// 品牌 2 的管理员不能核销品牌 1 的订单
+	ctx := context.WithValue(verificationAdminCtx(42), "brandIds", []int64{2})
+	_, err := NewVerifyOrderLogic(ctx, svcCtx).VerifyOrder(&types.VerifyOrderReq{Code: order.VerificationCode})
+	assert.ErrorIs(t, err, errVerificationCrossBrand)
+
+	_, err = NewScanOrderLogic(ctx, svcCtx).ScanOrder(&types.ScanOrderReq{Code: order.VerificationCode})
+	assert.ErrorIs(t, err, errVerificationCrossBrand)
+
+	var fresh model.Order
+	require.NoError(t, db.First(&fresh, order.Id).Error)
+	assert.Equal(t, "unverified", fresh.VerificationStatus)
+
+	var events []model.SecurityEvent
+	require.NoError(t, db.Where("event_type = ?", "verification_cross_brand").Find(&events).Error)
+	require.Len(t, events, 2)
+	assert.Equal(t, "high", events[0].Severity)
+	require.NotNil(t, events[0].UserID)
+	assert.Equal(t, int64(42), *events[0].UserID)
+}
+
+func TestVerifyOrderLogic_StaffScope(t *testing.T) {
+	db := setupTestDB(t)
+
+	svcCtx := newTestSvcCtx(db)
+	inScope := insertOrderForVerification(t, svcCtx, "paid", "unverified")
+	outOfScope := insertOrderForVerification(t, svcCtx, "paid", "unverified")
+	require.NoError(t, db.Model(&model.Campaign{}).Where("id = ?", inScope.CampaignId).Update("venue", "徐汇店").Error)
+	require.NoError(t, db.Model(&model.Campaign{}).Where("id = ?", outOfScope.CampaignId).Update("venue", "静安店").Error)
+	require.NoError(t, db.Create(&model.VerificationScope{UserId: 55, BrandId: 1, Venue: "徐汇店"}).Error)
+
+	logic := NewVerifyOrderLogic(verificationAdminCtx(55), svcCtx)
+	_, err := logic.VerifyOrder(&types.VerifyOrderReq{Code: inScope.VerificationCode})
+	require.NoError(t, err)
+
+	_, err = logic.VerifyOrder(&types.VerifyOrderReq{Code: outOfScope.VerificationCode})
+	assert.ErrorIs(t, err, errVerificationOutOfScope)
+
+	var count int64
+	require.NoError(t, db.Model(&model.SecurityEvent{}).Where("event_type = ?", "verification_out_of_scope").Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+
+	// 核销记录只包含范围内活动的订单
+	records, err := NewGetVerificationRecordsLogic(verificationAdminCtx(55), svcCtx).
+		GetVerificationRecords(&types.VerificationRecordsReq{})
+	require.NoError(t, err)
+	require.Len(t, records.Records, 1)
+	assert.Equal(t, inScope.Id, records.Records[0].OrderId)
+
+	// 未配置范围的同品牌人员不受限制
+	_, err = NewVerifyOrderLogic(verificationAdminCtx(56), svcCtx).VerifyOrder(&types.VerifyOrderReq{Code: outOfScope.VerificationCode})
+	require.NoError(t, err)
+}
@@ -0,0 +1,73 @@
+// Code scaffolded by goctl. Safe to edit.
+// goctl 1.9.2
+
+package reward
+
+import (
+	"context"
+	"errors"
+
+	"dmh/api/internal/middleware"
+	"dmh/api/internal/service"
+	"dmh/api/internal/svc"
+	"dmh/api/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type GetBalanceTransactionsLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewGetBalanceTransactionsLogic(ctx context.Context, svcCtx *svc.ServiceContext) *GetBalanceTransactionsLogic {
+	return &GetBalanceTransactionsLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+func (l *GetBalanceTransactionsLogic) GetBalanceTransactions(req *types.BalanceTransactionListReq) (resp *types.BalanceTransactionListResp, err error) {
+	if req.UserId <= 0 {
+		return nil, errors.New("userId is required")
+	}
+
+	// 仅本人或平台管理员可查看余额流水
+	if !middleware.IsPlatformAdmin(l.ctx) {
+		currentUserId, err := middleware.GetUserIDFromContext(l.ctx)
+		if err != nil || currentUserId != req.UserId {
+			return nil, errors.New("permission denied")
+		}
+	}
+
+	transactions, total, err := service.NewBalanceService(l.svcCtx.DB).ListTransactions(req.UserId, req.Type, req.Page, req.PageSize)
+	if err != nil {
+		l.Errorf("Failed to get balance transactions: %v", err)
+		return nil, errors.New("failed to get balance transactions")
+	}
+
+	list := make([]types.BalanceTransactionResp, 0, len(transactions))
+	for _, tx := range transactions {
+		list = append(list, types.BalanceTransactionResp{
+			Id:             tx.Id,
+			UserId:         tx.UserId,
+			Type:           tx.Type,
+			Account:        tx.Account,
+			CounterAccount: tx.CounterAccount,
+			Amount:         tx.Amount,
+			BalanceBefore:  tx.BalanceBefore,
+			BalanceAfter:   tx.BalanceAfter,
+			RefType:        tx.RefType,
+			RefId:          tx.RefId,
+			Remark:         tx.Remark,
+			CreatedAt:      tx.CreatedAt.Format("2006-01-02 15:04:05"),
+		})
+	}
+
+	return &types.BalanceTransactionListResp{
+		Total:        total,
+		Transactions: list,
+	}, nil
+}
@@ -18,8 +18,8 @@ import (
 func setupRewardTestDB(t *testing.T) *gorm.DB {
 	t.Helper()
 	db := testutil.SetupGormTestDB(t)
-	db.AutoMigrate(&model.Brand{}, &model.Campaign{}, &model.Distributor{}, &model.User{}, &model.DistributorReward{}, &model.UserBalance{}, &model.Order{})
-	testutil.ClearTables(db, "distributor_rewards", "user_balances", "orders", "distributors", "campaigns", "brands", "users")
+	db.AutoMigrate(&model.Brand{}, &model.Campaign{}, &model.Distributor{}, &model.User{}, &model.DistributorReward{}, &model.UserBalance{}, &model.BalanceTransaction{}, &model.Order{})
+	testutil.ClearTables(db, "distributor_rewards", "balance_transactions", "user_balances", "orders", "distributors", "campaigns", "brands", "users")
 	return db
 }
 
@@ -169,3 +169,36 @@ func TestGetBalanceLogic(t *testing.T) {
 		})
 	}
 }
+
+func TestGetBalanceTransactionsLogic(t *testing.T) {
+	db := setupRewardTestDB(t)
+
+	db.Create(&model.BalanceTransaction{UserId: 1, Type: "reward_credit", Account: "available", CounterAccount: "reward_expense", Amount: 20, BalanceBefore: 0, BalanceAfter: 20})
+	db.Create(&model.BalanceTransaction{UserId: 1, Type: "withdrawal_hold", Account: "available", CounterAccount: "withdrawal_hold", Amount: -10, BalanceBefore: 20, BalanceAfter: 10})
+	db.Create(&model.BalanceTransaction{UserId: 2, Type: "reward_credit", Account: "available", CounterAccount: "reward_expense", Amount: 5, BalanceBefore: 0, BalanceAfter: 5})
+
+	svcCtx := &svc.ServiceContext{DB: db}
+
+	t.Run("本人查询", func(t *testing.T) {
+		ctx := context.WithValue(context.Background(), "userId", int64(1))
+		resp, err := NewGetBalanceTransactionsLogic(ctx, svcCtx).GetBalanceTransactions(&types.BalanceTransactionListReq{UserId: 1})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), resp.Total)
+		assert.Len(t, resp.Transactions, 2)
+	})
+
+	t.Run("按类型过滤", func(t *testing.T) {
+		ctx := context.WithValue(context.Background(), "roles", []string{"platform_admin"})
+		resp, err := NewGetBalanceTransactionsLogic(ctx, svcCtx).GetBalanceTransactions(&types.BalanceTransactionListReq{UserId: 1, Type: "withdrawal_hold"})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), resp.Total)
+		assert.Equal(t, -10.0, resp.Transactions[0].Amount)
+	})
+}
+
+func TestGetBalanceTransactionsLogic_PermissionDenied(t *testing.T) {
+	ctx := context.WithValue(context.Background(), "userId", int64(2))
+	_, err := NewGetBalanceTransactionsLogic(ctx, &svc.ServiceContext{}).GetBalanceTransactions(&types.BalanceTransactionListReq{UserId: 1})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "permission denied")
+}
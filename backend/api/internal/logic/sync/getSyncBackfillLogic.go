@@ -0,0 +1,51 @@
+// Code scaffolded by goctl. Safe to edit.
+// goctl 1.9.2
+
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"dmh/api/internal/middleware"
+	"dmh/api/internal/svc"
+	"dmh/api/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type GetSyncBackfillLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewGetSyncBackfillLogic(ctx context.Context, svcCtx *svc.ServiceContext) *GetSyncBackfillLogic {
+	return &GetSyncBackfillLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+// GetSyncBackfill 按检查点查询回填进度，并核对源表与目标表的记录数
+func (l *GetSyncBackfillLogic) GetSyncBackfill(req *types.SyncBackfillStatusReq) (resp *types.SyncBackfillResp, err error) {
+	if !middleware.IsPlatformAdmin(l.ctx) {
+		return nil, fmt.Errorf("权限不足，仅平台管理员可查看回填进度")
+	}
+	if l.svcCtx.SyncBackfiller == nil {
+		return nil, errors.New("外部同步未启用")
+	}
+
+	result, err := l.svcCtx.SyncBackfiller.Progress(l.ctx, req.Name)
+	if err != nil {
+		l.Errorf("查询回填进度失败: name=%s, err=%v", req.Name, err)
+		return nil, err
+	}
+	if len(result.Targets) == 0 {
+		return nil, errors.New("回填任务不存在")
+	}
+
+	return toSyncBackfillResp(result, l.svcCtx.SyncBackfiller.Running()), nil
+}
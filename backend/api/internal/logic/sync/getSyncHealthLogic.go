@@ -12,6 +12,14 @@ import (
 	"github.com/zeromicro/go-zero/core/logx"
 )
 
+// 同步健康状态
+const (
+	syncHealthHealthy   = "healthy"
+	syncHealthDegraded  = "degraded"  // 可以同步，但死信队列中有待处理的任务
+	syncHealthUnhealthy = "unhealthy" // 外部数据库、队列或Worker不可用
+	syncHealthDisabled  = "disabled"
+)
+
 type GetSyncHealthLogic struct {
 	logx.Logger
 	ctx    context.Context
@@ -26,17 +34,76 @@ func NewGetSyncHealthLogic(ctx context.Context, svcCtx *svc.ServiceContext) *Get
 	}
 }
 
+// GetSyncHealth 检查外部数据库连接、同步队列积压与Worker运行状态
 func (l *GetSyncHealthLogic) GetSyncHealth() (resp *types.SyncHealthResp, err error) {
 	resp = &types.SyncHealthResp{
-		Status: "healthy",
-		Database: map[string]interface{}{
-			"status":  "connected",
-			"latency": "5ms",
-		},
-		Queue: map[string]interface{}{
-			"status": "running",
-			"size":   100,
-		},
+		Status:   syncHealthHealthy,
+		Database: map[string]interface{}{"status": "disconnected"},
+		Queue:    map[string]interface{}{"status": "unavailable"},
+		Worker:   map[string]interface{}{"alive": false},
+	}
+
+	if !l.svcCtx.Config.ExternalSync.Enabled {
+		resp.Status = syncHealthDisabled
+		resp.Database["status"] = syncHealthDisabled
+		resp.Queue["status"] = syncHealthDisabled
+		return resp, nil
+	}
+
+	healthy := true
+
+	if adapter := l.svcCtx.SyncAdapter; adapter != nil {
+		resp.Database["type"] = adapter.DatabaseType()
+		latency, err := adapter.Ping()
+		resp.Database["latency"] = latency.String()
+		if err != nil {
+			resp.Database["error"] = err.Error()
+			healthy = false
+		} else {
+			resp.Database["status"] = "connected"
+		}
+	} else {
+		healthy = false
+	}
+
+	var deadLetters int64
+	if queue := l.svcCtx.SyncQueue; queue != nil {
+		size, err := queue.Length()
+		if err != nil {
+			resp.Queue["error"] = err.Error()
+			healthy = false
+		} else {
+			resp.Queue["status"] = "running"
+			resp.Queue["size"] = size
+			if delayed, err := queue.DelayedLength(); err == nil {
+				resp.Queue["delayed"] = delayed
+			}
+			if deadLetters, err = queue.DeadLetterLength(); err == nil {
+				resp.Queue["deadLetters"] = deadLetters
+			}
+		}
+	} else {
+		healthy = false
+	}
+
+	if worker := l.svcCtx.SyncWorker; worker != nil {
+		alive := worker.Alive()
+		resp.Worker["alive"] = alive
+		if beat := worker.LastHeartbeat(); !beat.IsZero() {
+			resp.Worker["lastHeartbeat"] = beat.Format("2006-01-02T15:04:05")
+		}
+		if !alive {
+			healthy = false
+		}
+	} else {
+		healthy = false
+	}
+
+	switch {
+	case !healthy:
+		resp.Status = syncHealthUnhealthy
+	case deadLetters > 0:
+		resp.Status = syncHealthDegraded
 	}
 
 	return resp, nil
@@ -5,9 +5,12 @@ package sync
 
 import (
 	"context"
+	"fmt"
 
 	"dmh/api/internal/svc"
 	"dmh/api/internal/types"
+	"dmh/common/syncadapter"
+	"dmh/model"
 
 	"github.com/zeromicro/go-zero/core/logx"
 )
@@ -26,8 +29,42 @@ func NewGetSyncStatsLogic(ctx context.Context, svcCtx *svc.ServiceContext) *GetS
 	}
 }
 
+// GetSyncStats 按同步日志统计同步结果，平均耗时取自同步适配器的运行指标
 func (l *GetSyncStatsLogic) GetSyncStats() (resp *types.SyncStatsResp, err error) {
-	// todo: add your logic here and delete this line
+	var rows []struct {
+		SyncStatus string
+		Count      int64
+	}
+	if err := l.svcCtx.DB.Model(&model.SyncLog{}).
+		Select("sync_status, COUNT(*) AS count").
+		Group("sync_status").
+		Scan(&rows).Error; err != nil {
+		l.Errorf("统计同步日志失败: %v", err)
+		return nil, fmt.Errorf("统计同步日志失败: %v", err)
+	}
+
+	resp = &types.SyncStatsResp{AvgTime: "0s"}
+	for _, row := range rows {
+		resp.TotalSyncs += row.Count
+		switch row.SyncStatus {
+		case syncadapter.SyncStatusSynced:
+			resp.SuccessSyncs = row.Count
+		case syncadapter.SyncStatusFailed:
+			resp.FailedSyncs = row.Count
+		case syncadapter.SyncStatusPending:
+			resp.PendingSyncs = row.Count
+		}
+	}
+	if resp.TotalSyncs > 0 {
+		resp.SuccessRate = float64(resp.SuccessSyncs) / float64(resp.TotalSyncs)
+	}
+
+	// 平均耗时只统计本进程启动以来的同步
+	if l.svcCtx.SyncAdapter != nil {
+		if avgTime, ok := l.svcCtx.SyncAdapter.Metrics().GetStats()["avg_time"].(string); ok {
+			resp.AvgTime = avgTime
+		}
+	}
 
-	return
+	return resp, nil
 }
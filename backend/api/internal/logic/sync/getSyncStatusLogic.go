@@ -5,11 +5,15 @@ package sync
 
 import (
 	"context"
+	"errors"
 
 	"dmh/api/internal/svc"
 	"dmh/api/internal/types"
+	"dmh/common/syncadapter"
+	"dmh/model"
 
 	"github.com/zeromicro/go-zero/core/logx"
+	"gorm.io/gorm"
 )
 
 type GetSyncStatusLogic struct {
@@ -26,8 +30,41 @@ func NewGetSyncStatusLogic(ctx context.Context, svcCtx *svc.ServiceContext) *Get
 	}
 }
 
-func (l *GetSyncStatusLogic) GetSyncStatus() (resp *types.SyncStatusResp, err error) {
-	// todo: add your logic here and delete this line
+// GetSyncStatus 查询订单的同步状态，尚无同步记录时返回订单上的状态
+func (l *GetSyncStatusLogic) GetSyncStatus(req *types.SyncOrderReq) (resp *types.SyncStatusResp, err error) {
+	if req.OrderId <= 0 {
+		return nil, errors.New("订单ID无效")
+	}
+
+	var log model.SyncLog
+	err = l.svcCtx.DB.Where("order_id = ? AND sync_type = ?", req.OrderId, syncadapter.SyncTypeOrder).First(&log).Error
+	if err == nil {
+		resp = &types.SyncStatusResp{
+			OrderId:    req.OrderId,
+			SyncStatus: log.SyncStatus,
+			Attempts:   log.Attempts,
+			ErrorMsg:   log.ErrorMsg,
+		}
+		if log.SyncedAt != nil {
+			resp.SyncTime = log.SyncedAt.Format("2006-01-02T15:04:05")
+		}
+		return resp, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	// 尚未尝试同步，以订单上的同步状态为准
+	var order model.Order
+	if err := l.svcCtx.DB.Select("id", "sync_status").Where("id = ? AND deleted_at IS NULL", req.OrderId).First(&order).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("订单不存在")
+		}
+		return nil, err
+	}
 
-	return
+	return &types.SyncStatusResp{
+		OrderId:    order.Id,
+		SyncStatus: order.SyncStatus,
+	}, nil
 }
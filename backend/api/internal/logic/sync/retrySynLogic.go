@@ -5,11 +5,17 @@ package sync
 
 import (
 	"context"
+	"errors"
+	"fmt"
 
+	"dmh/api/internal/middleware"
 	"dmh/api/internal/svc"
 	"dmh/api/internal/types"
+	"dmh/common/syncadapter"
+	"dmh/model"
 
 	"github.com/zeromicro/go-zero/core/logx"
+	"gorm.io/gorm"
 )
 
 type RetrySynLogic struct {
@@ -26,8 +32,60 @@ func NewRetrySynLogic(ctx context.Context, svcCtx *svc.ServiceContext) *RetrySyn
 	}
 }
 
-func (l *RetrySynLogic) RetrySyn() (resp *types.RetrySyncResp, err error) {
-	// todo: add your logic here and delete this line
+// RetrySyn 将订单及其分销奖励重新加入同步队列
+func (l *RetrySynLogic) RetrySyn(req *types.SyncOrderReq) (resp *types.RetrySyncResp, err error) {
+	if !middleware.IsPlatformAdmin(l.ctx) {
+		return nil, fmt.Errorf("权限不足，仅平台管理员可重试同步")
+	}
+	if req.OrderId <= 0 {
+		return nil, errors.New("订单ID无效")
+	}
+	if l.svcCtx.SyncQueue == nil {
+		return nil, errors.New("外部同步未启用")
+	}
+
+	var order model.Order
+	if err := l.svcCtx.DB.Where("id = ? AND deleted_at IS NULL", req.OrderId).First(&order).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("订单不存在")
+		}
+		return nil, err
+	}
+
+	tasks, err := syncadapter.OrderSyncTasks(l.svcCtx.DB, order.Id)
+	if err != nil {
+		l.Errorf("构建同步任务失败: orderId=%d, err=%v", order.Id, err)
+		return nil, fmt.Errorf("构建同步任务失败: %v", err)
+	}
+
+	rewardIds := make([]int64, 0, len(tasks))
+	for _, task := range tasks {
+		if err := l.svcCtx.SyncQueue.Enqueue(task); err != nil {
+			l.Errorf("加入同步队列失败: orderId=%d, err=%v", order.Id, err)
+			return nil, fmt.Errorf("加入同步队列失败: %v", err)
+		}
+		if task.Type == syncadapter.SyncTypeDistributorReward {
+			rewardIds = append(rewardIds, task.RewardId)
+		}
+	}
+
+	// 重新入队后状态回到待同步，由Worker处理后更新
+	if err := l.svcCtx.DB.Model(&model.Order{}).Where("id = ?", order.Id).
+		Update("sync_status", syncadapter.SyncStatusPending).Error; err != nil {
+		l.Errorf("更新订单同步状态失败: orderId=%d, err=%v", order.Id, err)
+	}
+	if err := l.svcCtx.DB.Model(&model.SyncLog{}).
+		Where("(order_id = ? AND sync_type = ?) OR (order_id IN ? AND sync_type = ?)",
+			order.Id, syncadapter.SyncTypeOrder, rewardIds, syncadapter.SyncTypeDistributorReward).
+		Update("sync_status", syncadapter.SyncStatusPending).Error; err != nil {
+		l.Errorf("更新同步日志失败: orderId=%d, err=%v", order.Id, err)
+	}
+
+	l.Infof("订单已重新加入同步队列: orderId=%d, rewards=%d", order.Id, len(rewardIds))
 
-	return
+	return &types.RetrySyncResp{
+		OrderId: order.Id,
+		Message: fmt.Sprintf("已加入同步队列（订单1条，奖励%d条）", len(rewardIds)),
+		TaskId:  tasks[0].TaskId,
+	}, nil
 }
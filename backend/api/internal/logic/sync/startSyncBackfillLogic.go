@@ -0,0 +1,86 @@
+// Code scaffolded by goctl. Safe to edit.
+// goctl 1.9.2
+
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"dmh/api/internal/middleware"
+	"dmh/api/internal/svc"
+	"dmh/api/internal/types"
+	"dmh/common/syncadapter"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type StartSyncBackfillLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewStartSyncBackfillLogic(ctx context.Context, svcCtx *svc.ServiceContext) *StartSyncBackfillLogic {
+	return &StartSyncBackfillLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+// StartSyncBackfill 将历史订单与奖励回填到外部数据库：预演时直接返回源表与目标表的记录数，否则在后台执行
+func (l *StartSyncBackfillLogic) StartSyncBackfill(req *types.SyncBackfillReq) (resp *types.SyncBackfillResp, err error) {
+	if !middleware.IsPlatformAdmin(l.ctx) {
+		return nil, fmt.Errorf("权限不足，仅平台管理员可回填同步数据")
+	}
+	if l.svcCtx.SyncBackfiller == nil {
+		return nil, errors.New("外部同步未启用")
+	}
+
+	from, to, err := syncadapter.ParseBackfillRange(req.StartDate, req.EndDate)
+	if err != nil {
+		return nil, err
+	}
+	opts := syncadapter.BackfillOptions{
+		Name:          req.Name,
+		Targets:       req.Targets,
+		From:          from,
+		To:            to,
+		BrandId:       req.BrandId,
+		CampaignId:    req.CampaignId,
+		BatchSize:     req.BatchSize,
+		RatePerSecond: req.RatePerSecond,
+		DryRun:        req.DryRun,
+		Reset:         req.Reset,
+	}
+
+	if req.DryRun {
+		result, err := l.svcCtx.SyncBackfiller.Run(l.ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		return toSyncBackfillResp(result, l.svcCtx.SyncBackfiller.Running()), nil
+	}
+
+	if err := l.svcCtx.SyncBackfiller.Start(opts); err != nil {
+		if errors.Is(err, syncadapter.ErrBackfillRunning) {
+			return nil, errors.New("已有回填任务在执行")
+		}
+		return nil, err
+	}
+
+	name := req.Name
+	if name == "" {
+		name = syncadapter.DefaultBackfillName
+	}
+	l.Infof("外部同步回填已启动: name=%s, targets=%v, start=%s, end=%s, brandId=%d, campaignId=%d",
+		name, req.Targets, req.StartDate, req.EndDate, req.BrandId, req.CampaignId)
+
+	return &types.SyncBackfillResp{
+		Name:    name,
+		Running: true,
+		Targets: []types.SyncBackfillTarget{},
+	}, nil
+}
@@ -0,0 +1,32 @@
+package sync
+
+import (
+	"dmh/api/internal/types"
+	"dmh/common/syncadapter"
+)
+
+// toSyncBackfillResp 转换回填结果，目标表记录数与源表全部记录数一致时视为已对齐
+func toSyncBackfillResp(result *syncadapter.BackfillResult, running bool) *types.SyncBackfillResp {
+	resp := &types.SyncBackfillResp{
+		Name:    result.Name,
+		DryRun:  result.DryRun,
+		Running: running,
+		Targets: make([]types.SyncBackfillTarget, 0, len(result.Targets)),
+	}
+	for _, progress := range result.Targets {
+		resp.Targets = append(resp.Targets, types.SyncBackfillTarget{
+			Target:      progress.Target,
+			Status:      progress.Status,
+			SourceCount: progress.SourceCount,
+			SourceTotal: progress.SourceTotal,
+			TargetCount: progress.TargetCount,
+			Consistent:  progress.TargetCount >= 0 && progress.TargetCount == progress.SourceTotal,
+			Synced:      progress.Synced,
+			Failed:      progress.Failed,
+			LastId:      progress.LastId,
+			FailedIds:   progress.FailedIds,
+			LastError:   progress.LastError,
+		})
+	}
+	return resp
+}
@@ -3,12 +3,23 @@ package sync
 import (
 	"context"
 	"testing"
+	"time"
 
+	"dmh/api/internal/handler/testutil"
 	"dmh/api/internal/svc"
+	"dmh/api/internal/types"
+	"dmh/common/syncadapter"
+	"dmh/model"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
+func syncAdminCtx() context.Context {
+	ctx := context.WithValue(context.Background(), "roles", []string{"platform_admin"})
+	return context.WithValue(ctx, "userId", int64(1))
+}
+
 func TestSyncLogicConstructors(t *testing.T) {
 	ctx := context.Background()
 	svcCtx := &svc.ServiceContext{}
@@ -17,6 +28,8 @@ func TestSyncLogicConstructors(t *testing.T) {
 	assert.NotNil(t, NewGetSyncStatusLogic(ctx, svcCtx))
 	assert.NotNil(t, NewGetSyncStatsLogic(ctx, svcCtx))
 	assert.NotNil(t, NewRetrySynLogic(ctx, svcCtx))
+	assert.NotNil(t, NewStartSyncBackfillLogic(ctx, svcCtx))
+	assert.NotNil(t, NewGetSyncBackfillLogic(ctx, svcCtx))
 }
 
 func TestGetSyncHealthBehavior(t *testing.T) {
@@ -24,27 +37,96 @@ func TestGetSyncHealthBehavior(t *testing.T) {
 	resp, err := logic.GetSyncHealth()
 	assert.NoError(t, err)
 	assert.NotNil(t, resp)
-	assert.Equal(t, "healthy", resp.Status)
+	assert.Equal(t, syncHealthDisabled, resp.Status)
 	assert.NotNil(t, resp.Database)
 	assert.NotNil(t, resp.Queue)
+	assert.NotNil(t, resp.Worker)
+
+	// 已启用但外部数据库、队列与Worker均不可用
+	svcCtx := &svc.ServiceContext{}
+	svcCtx.Config.ExternalSync.Enabled = true
+	resp, err = NewGetSyncHealthLogic(context.Background(), svcCtx).GetSyncHealth()
+	assert.NoError(t, err)
+	assert.Equal(t, syncHealthUnhealthy, resp.Status)
+	assert.Equal(t, "disconnected", resp.Database["status"])
+	assert.Equal(t, "unavailable", resp.Queue["status"])
+	assert.Equal(t, false, resp.Worker["alive"])
 }
 
-func TestSyncTodoMethodsCurrentBehavior(t *testing.T) {
-	ctx := context.Background()
+func TestRetrySyn_Validation(t *testing.T) {
+	svcCtx := &svc.ServiceContext{}
+
+	_, err := NewRetrySynLogic(context.Background(), svcCtx).RetrySyn(&types.SyncOrderReq{OrderId: 1})
+	assert.Error(t, err)
+
+	_, err = NewRetrySynLogic(syncAdminCtx(), svcCtx).RetrySyn(&types.SyncOrderReq{OrderId: 0})
+	assert.EqualError(t, err, "订单ID无效")
+
+	_, err = NewRetrySynLogic(syncAdminCtx(), svcCtx).RetrySyn(&types.SyncOrderReq{OrderId: 1})
+	assert.EqualError(t, err, "外部同步未启用")
+}
+
+func TestSyncBackfill_Validation(t *testing.T) {
 	svcCtx := &svc.ServiceContext{}
 
-	status := NewGetSyncStatusLogic(ctx, svcCtx)
-	statusResp, statusErr := status.GetSyncStatus()
-	assert.NoError(t, statusErr)
-	assert.Nil(t, statusResp)
+	_, err := NewStartSyncBackfillLogic(context.Background(), svcCtx).StartSyncBackfill(&types.SyncBackfillReq{DryRun: true})
+	assert.Error(t, err)
+
+	_, err = NewStartSyncBackfillLogic(syncAdminCtx(), svcCtx).StartSyncBackfill(&types.SyncBackfillReq{DryRun: true})
+	assert.EqualError(t, err, "外部同步未启用")
+
+	_, err = NewGetSyncBackfillLogic(context.Background(), svcCtx).GetSyncBackfill(&types.SyncBackfillStatusReq{Name: "erp"})
+	assert.Error(t, err)
+
+	_, err = NewGetSyncBackfillLogic(syncAdminCtx(), svcCtx).GetSyncBackfill(&types.SyncBackfillStatusReq{Name: "erp"})
+	assert.EqualError(t, err, "外部同步未启用")
+}
+
+func TestToSyncBackfillResp(t *testing.T) {
+	resp := toSyncBackfillResp(&syncadapter.BackfillResult{
+		Name: "erp",
+		Targets: []syncadapter.BackfillProgress{
+			{Target: "order", Status: "completed", SourceTotal: 10, TargetCount: 10, Synced: 10},
+			{Target: "reward", Status: "paused", SourceTotal: 5, TargetCount: 3, Synced: 3},
+			{Target: "reward", SourceTotal: 0, TargetCount: -1},
+		},
+	}, true)
+
+	assert.Equal(t, "erp", resp.Name)
+	assert.True(t, resp.Running)
+	require.Len(t, resp.Targets, 3)
+	assert.True(t, resp.Targets[0].Consistent)
+	assert.False(t, resp.Targets[1].Consistent)
+	assert.False(t, resp.Targets[2].Consistent)
+}
+
+func TestGetSyncStatusAndStats_FromSyncLogs(t *testing.T) {
+	db := testutil.SetupGormTestDB(t)
+	require.NoError(t, db.AutoMigrate(&model.SyncLog{}))
+	testutil.ClearTables(db, "sync_logs")
+	svcCtx := &svc.ServiceContext{DB: db}
+
+	syncedAt := time.Now()
+	require.NoError(t, db.Create(&model.SyncLog{OrderId: 9001, SyncType: "order", SyncStatus: "synced", Attempts: 1, SyncedAt: &syncedAt}).Error)
+	require.NoError(t, db.Create(&model.SyncLog{OrderId: 9002, SyncType: "order", SyncStatus: "failed", Attempts: 5, ErrorMsg: "connection refused"}).Error)
+	require.NoError(t, db.Create(&model.SyncLog{OrderId: 9003, SyncType: "distributor_reward", SyncStatus: "pending", Attempts: 2}).Error)
+
+	status, err := NewGetSyncStatusLogic(context.Background(), svcCtx).GetSyncStatus(&types.SyncOrderReq{OrderId: 9002})
+	require.NoError(t, err)
+	assert.Equal(t, "failed", status.SyncStatus)
+	assert.Equal(t, 5, status.Attempts)
+	assert.Equal(t, "connection refused", status.ErrorMsg)
 
-	stats := NewGetSyncStatsLogic(ctx, svcCtx)
-	statsResp, statsErr := stats.GetSyncStats()
-	assert.NoError(t, statsErr)
-	assert.Nil(t, statsResp)
+	status, err = NewGetSyncStatusLogic(context.Background(), svcCtx).GetSyncStatus(&types.SyncOrderReq{OrderId: 9001})
+	require.NoError(t, err)
+	assert.Equal(t, "synced", status.SyncStatus)
+	assert.NotEmpty(t, status.SyncTime)
 
-	retry := NewRetrySynLogic(ctx, svcCtx)
-	retryResp, retryErr := retry.RetrySyn()
-	assert.NoError(t, retryErr)
-	assert.Nil(t, retryResp)
+	stats, err := NewGetSyncStatsLogic(context.Background(), svcCtx).GetSyncStats()
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), stats.TotalSyncs)
+	assert.Equal(t, int64(1), stats.SuccessSyncs)
+	assert.Equal(t, int64(1), stats.FailedSyncs)
+	assert.Equal(t, int64(1), stats.PendingSyncs)
+	assert.InDelta(t, 1.0/3, stats.SuccessRate, 0.0001)
 }
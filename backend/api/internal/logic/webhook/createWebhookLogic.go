@@ -0,0 +1,75 @@
+// Code scaffolded by goctl. Safe to edit.
+// goctl 1.9.2
+
+package webhook
+
+import (
+	"context"
+	"errors"
+
+	"dmh/api/internal/middleware"
+	"dmh/api/internal/service"
+	"dmh/api/internal/svc"
+	"dmh/api/internal/types"
+	"dmh/model"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type CreateWebhookLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewCreateWebhookLogic(ctx context.Context, svcCtx *svc.ServiceContext) *CreateWebhookLogic {
+	return &CreateWebhookLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+// CreateWebhook 为品牌注册 Webhook 端点，签名密钥仅在创建时返回一次
+func (l *CreateWebhookLogic) CreateWebhook(req *types.WebhookCreateReq) (resp *types.WebhookResp, err error) {
+	if req.BrandId <= 0 {
+		return nil, errors.New("品牌ID无效")
+	}
+	if err := checkBrandAccess(l.ctx, l.svcCtx.DB, req.BrandId); err != nil {
+		return nil, err
+	}
+	url, err := validateWebhookUrl(req.Url)
+	if err != nil {
+		return nil, err
+	}
+	events, err := normalizeWebhookEvents(req.Events)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkWebhookTarget(l.ctx, l.svcCtx, url); err != nil {
+		return nil, err
+	}
+	secret, err := service.GenerateWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+	userId, _ := middleware.GetUserIDFromContext(l.ctx)
+
+	endpoint := &model.WebhookEndpoint{
+		BrandId:     req.BrandId,
+		Url:         url,
+		Secret:      secret,
+		Events:      events,
+		Status:      "active",
+		Description: req.Description,
+		CreatedBy:   userId,
+	}
+	if err := l.svcCtx.DB.Create(endpoint).Error; err != nil {
+		l.Errorf("创建 Webhook 失败: brandId=%d, err=%v", req.BrandId, err)
+		return nil, errors.New("创建 Webhook 失败")
+	}
+
+	result := toWebhookResp(endpoint)
+	result.Secret = secret
+	return &result, nil
+}
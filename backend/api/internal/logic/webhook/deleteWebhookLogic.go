@@ -0,0 +1,41 @@
+// Code scaffolded by goctl. Safe to edit.
+// goctl 1.9.2
+
+package webhook
+
+import (
+	"context"
+	"errors"
+
+	"dmh/api/internal/svc"
+	"dmh/api/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type DeleteWebhookLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewDeleteWebhookLogic(ctx context.Context, svcCtx *svc.ServiceContext) *DeleteWebhookLogic {
+	return &DeleteWebhookLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+// DeleteWebhook 删除端点，保留投递记录；未投递的记录在下次投递时标记为失败
+func (l *DeleteWebhookLogic) DeleteWebhook(req *types.WebhookIdReq) (resp *types.CommonResp, err error) {
+	endpoint, err := loadEndpoint(l.ctx, l.svcCtx.DB, req.Id)
+	if err != nil {
+		return nil, err
+	}
+	if err := l.svcCtx.DB.Delete(endpoint).Error; err != nil {
+		l.Errorf("删除 Webhook 失败: id=%d, err=%v", endpoint.Id, err)
+		return nil, errors.New("删除 Webhook 失败")
+	}
+	return &types.CommonResp{Message: "删除成功"}, nil
+}
@@ -0,0 +1,71 @@
+// Code scaffolded by goctl. Safe to edit.
+// goctl 1.9.2
+
+package webhook
+
+import (
+	"context"
+
+	"dmh/api/internal/svc"
+	"dmh/api/internal/types"
+	"dmh/model"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type GetWebhookDeliveriesLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewGetWebhookDeliveriesLogic(ctx context.Context, svcCtx *svc.ServiceContext) *GetWebhookDeliveriesLogic {
+	return &GetWebhookDeliveriesLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+// GetWebhookDeliveries 分页查询端点的投递日志
+func (l *GetWebhookDeliveriesLogic) GetWebhookDeliveries(req *types.WebhookDeliveryListReq) (resp *types.WebhookDeliveryListResp, err error) {
+	endpoint, err := loadEndpoint(l.ctx, l.svcCtx.DB, req.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	page, pageSize := req.Page, req.PageSize
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	query := l.svcCtx.DB.Model(&model.WebhookDelivery{}).Where("endpoint_id = ?", endpoint.Id)
+	if req.Status != "" {
+		query = query.Where("status = ?", req.Status)
+	}
+	if req.EventType != "" {
+		query = query.Where("event_type = ?", req.EventType)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+	var deliveries []model.WebhookDelivery
+	if err := query.Order("id DESC").Offset(int((page - 1) * pageSize)).Limit(int(pageSize)).Find(&deliveries).Error; err != nil {
+		l.Errorf("查询 Webhook 投递记录失败: endpointId=%d, err=%v", endpoint.Id, err)
+		return nil, err
+	}
+
+	resp = &types.WebhookDeliveryListResp{
+		Total:      total,
+		Deliveries: make([]types.WebhookDeliveryResp, 0, len(deliveries)),
+	}
+	for i := range deliveries {
+		resp.Deliveries = append(resp.Deliveries, toWebhookDeliveryResp(&deliveries[i]))
+	}
+	return resp, nil
+}
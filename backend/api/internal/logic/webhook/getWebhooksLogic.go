@@ -0,0 +1,62 @@
+// Code scaffolded by goctl. Safe to edit.
+// goctl 1.9.2
+
+package webhook
+
+import (
+	"context"
+
+	"dmh/api/internal/svc"
+	"dmh/api/internal/types"
+	"dmh/model"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type GetWebhooksLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewGetWebhooksLogic(ctx context.Context, svcCtx *svc.ServiceContext) *GetWebhooksLogic {
+	return &GetWebhooksLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+// GetWebhooks 查询可管理品牌的 Webhook 端点
+func (l *GetWebhooksLogic) GetWebhooks(req *types.WebhookListReq) (resp *types.WebhookListResp, err error) {
+	query := l.svcCtx.DB.Model(&model.WebhookEndpoint{})
+	if req.BrandId > 0 {
+		if err := checkBrandAccess(l.ctx, l.svcCtx.DB, req.BrandId); err != nil {
+			return nil, err
+		}
+		query = query.Where("brand_id = ?", req.BrandId)
+	} else {
+		brandIds, all, err := managedBrandIds(l.ctx, l.svcCtx.DB)
+		if err != nil {
+			return nil, err
+		}
+		if !all {
+			query = query.Where("brand_id IN ?", append(brandIds, 0))
+		}
+	}
+
+	var endpoints []model.WebhookEndpoint
+	if err := query.Order("id DESC").Find(&endpoints).Error; err != nil {
+		l.Errorf("查询 Webhook 失败: %v", err)
+		return nil, err
+	}
+
+	resp = &types.WebhookListResp{
+		Total:    int64(len(endpoints)),
+		Webhooks: make([]types.WebhookResp, 0, len(endpoints)),
+	}
+	for i := range endpoints {
+		resp.Webhooks = append(resp.Webhooks, toWebhookResp(&endpoints[i]))
+	}
+	return resp, nil
+}
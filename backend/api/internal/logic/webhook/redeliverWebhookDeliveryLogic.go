@@ -0,0 +1,68 @@
+// Code scaffolded by goctl. Safe to edit.
+// goctl 1.9.2
+
+package webhook
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"dmh/api/internal/service"
+	"dmh/api/internal/svc"
+	"dmh/api/internal/types"
+	"dmh/model"
+
+	"github.com/zeromicro/go-zero/core/logx"
+	"gorm.io/gorm"
+)
+
+type RedeliverWebhookDeliveryLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewRedeliverWebhookDeliveryLogic(ctx context.Context, svcCtx *svc.ServiceContext) *RedeliverWebhookDeliveryLogic {
+	return &RedeliverWebhookDeliveryLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+// RedeliverWebhookDelivery 以原事件内容新增一条投递记录，由投递Worker尽快推送
+func (l *RedeliverWebhookDeliveryLogic) RedeliverWebhookDelivery(req *types.WebhookDeliveryIdReq) (resp *types.WebhookDeliveryResp, err error) {
+	var original model.WebhookDelivery
+	if err := l.svcCtx.DB.First(&original, req.Id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("投递记录不存在")
+		}
+		return nil, err
+	}
+	endpoint, err := loadEndpoint(l.ctx, l.svcCtx.DB, original.EndpointId)
+	if err != nil {
+		return nil, err
+	}
+	if endpoint.Status != "active" {
+		return nil, errors.New("Webhook 已停用，无法重新投递")
+	}
+
+	delivery := &model.WebhookDelivery{
+		EndpointId:    original.EndpointId,
+		BrandId:       original.BrandId,
+		EventId:       original.EventId,
+		EventType:     original.EventType,
+		Payload:       original.Payload,
+		Status:        service.WebhookDeliveryPending,
+		NextAttemptAt: time.Now(),
+		RedeliveryOf:  &original.Id,
+	}
+	if err := l.svcCtx.DB.Create(delivery).Error; err != nil {
+		l.Errorf("重新投递 Webhook 失败: deliveryId=%d, err=%v", original.Id, err)
+		return nil, errors.New("重新投递失败")
+	}
+
+	result := toWebhookDeliveryResp(delivery)
+	return &result, nil
+}
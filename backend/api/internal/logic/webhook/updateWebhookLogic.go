@@ -0,0 +1,83 @@
+// Code scaffolded by goctl. Safe to edit.
+// goctl 1.9.2
+
+package webhook
+
+import (
+	"context"
+	"errors"
+
+	"dmh/api/internal/service"
+	"dmh/api/internal/svc"
+	"dmh/api/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type UpdateWebhookLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewUpdateWebhookLogic(ctx context.Context, svcCtx *svc.ServiceContext) *UpdateWebhookLogic {
+	return &UpdateWebhookLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+// UpdateWebhook 修改端点地址、订阅事件与状态，可轮换签名密钥
+func (l *UpdateWebhookLogic) UpdateWebhook(req *types.WebhookUpdateReq) (resp *types.WebhookResp, err error) {
+	endpoint, err := loadEndpoint(l.ctx, l.svcCtx.DB, req.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{}
+	if req.Url != "" {
+		url, err := validateWebhookUrl(req.Url)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkWebhookTarget(l.ctx, l.svcCtx, url); err != nil {
+			return nil, err
+		}
+		updates["url"] = url
+	}
+	if req.Events != nil {
+		events, err := normalizeWebhookEvents(req.Events)
+		if err != nil {
+			return nil, err
+		}
+		updates["events"] = events
+	}
+	if req.Status != "" {
+		if req.Status != "active" && req.Status != "disabled" {
+			return nil, errors.New("状态只能是 active 或 disabled")
+		}
+		updates["status"] = req.Status
+	}
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+	var secret string
+	if req.RotateSecret {
+		if secret, err = service.GenerateWebhookSecret(); err != nil {
+			return nil, err
+		}
+		updates["secret"] = secret
+	}
+
+	if len(updates) > 0 {
+		if err := l.svcCtx.DB.Model(endpoint).Updates(updates).Error; err != nil {
+			l.Errorf("更新 Webhook 失败: id=%d, err=%v", endpoint.Id, err)
+			return nil, errors.New("更新 Webhook 失败")
+		}
+	}
+
+	result := toWebhookResp(endpoint)
+	result.Secret = secret
+	return &result, nil
+}
@@ -0,0 +1,157 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"dmh/api/internal/middleware"
+	"dmh/api/internal/service"
+	"dmh/api/internal/svc"
+	"dmh/api/internal/types"
+	"dmh/model"
+
+	"gorm.io/gorm"
+)
+
+var errWebhookForbidden = errors.New("权限不足，仅平台管理员或品牌管理员可管理 Webhook")
+
+const webhookResolveTimeout = 5 * time.Second
+
+// managedBrandIds 当前用户可管理的品牌，平台管理员返回 all=true
+func managedBrandIds(ctx context.Context, db *gorm.DB) (ids []int64, all bool, err error) {
+	if middleware.IsPlatformAdmin(ctx) {
+		return nil, true, nil
+	}
+	if !middleware.HasRole(ctx, "brand_admin") {
+		return nil, false, errWebhookForbidden
+	}
+	userId, err := middleware.GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, false, errWebhookForbidden
+	}
+	if err := db.Model(&model.UserBrand{}).Where("user_id = ?", userId).Pluck("brand_id", &ids).Error; err != nil {
+		return nil, false, err
+	}
+	return ids, false, nil
+}
+
+// checkBrandAccess 校验当前用户是否可管理品牌 brandId 的 Webhook
+func checkBrandAccess(ctx context.Context, db *gorm.DB, brandId int64) error {
+	ids, all, err := managedBrandIds(ctx, db)
+	if err != nil || all {
+		return err
+	}
+	for _, id := range ids {
+		if id == brandId {
+			return nil
+		}
+	}
+	return errWebhookForbidden
+}
+
+// loadEndpoint 查询端点并校验品牌权限
+func loadEndpoint(ctx context.Context, db *gorm.DB, id int64) (*model.WebhookEndpoint, error) {
+	var endpoint model.WebhookEndpoint
+	if err := db.First(&endpoint, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("Webhook 不存在")
+		}
+		return nil, err
+	}
+	if err := checkBrandAccess(ctx, db, endpoint.BrandId); err != nil {
+		return nil, err
+	}
+	return &endpoint, nil
+}
+
+// validateWebhookUrl 只允许 http/https 的绝对地址
+func validateWebhookUrl(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("无效的 Webhook 地址: %s", raw)
+	}
+	if len(raw) > 500 {
+		return "", errors.New("Webhook 地址过长")
+	}
+	return raw, nil
+}
+
+// checkWebhookTarget 解析地址中的主机，拒绝指向内网、回环或链路本地地址的回调；投递时会再次校验实际连接的地址
+func checkWebhookTarget(ctx context.Context, svcCtx *svc.ServiceContext, raw string) error {
+	if svcCtx.Config.Webhook.AllowPrivateNetwork {
+		return nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("无效的 Webhook 地址: %s", raw)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, webhookResolveTimeout)
+	defer cancel()
+	return service.CheckWebhookHost(ctx, u.Hostname())
+}
+
+// normalizeWebhookEvents 校验并去重订阅的事件类型，返回逗号分隔的存储格式
+func normalizeWebhookEvents(events []string) (string, error) {
+	seen := make(map[string]bool, len(events))
+	normalized := make([]string, 0, len(events))
+	for _, event := range events {
+		event = strings.TrimSpace(event)
+		if !service.IsWebhookEventType(event) {
+			return "", fmt.Errorf("不支持的事件类型: %s", event)
+		}
+		if !seen[event] {
+			seen[event] = true
+			normalized = append(normalized, event)
+		}
+	}
+	if len(normalized) == 0 {
+		return "", errors.New("至少订阅一个事件类型")
+	}
+	return strings.Join(normalized, ","), nil
+}
+
+func toWebhookResp(endpoint *model.WebhookEndpoint) types.WebhookResp {
+	return types.WebhookResp{
+		Id:          endpoint.Id,
+		BrandId:     endpoint.BrandId,
+		Url:         endpoint.Url,
+		Events:      strings.Split(endpoint.Events, ","),
+		Status:      endpoint.Status,
+		Description: endpoint.Description,
+		CreatedAt:   endpoint.CreatedAt.Format(time.DateTime),
+		UpdatedAt:   endpoint.UpdatedAt.Format(time.DateTime),
+	}
+}
+
+func toWebhookDeliveryResp(delivery *model.WebhookDelivery) types.WebhookDeliveryResp {
+	resp := types.WebhookDeliveryResp{
+		Id:             delivery.Id,
+		EndpointId:     delivery.EndpointId,
+		EventId:        delivery.EventId,
+		EventType:      delivery.EventType,
+		Payload:        delivery.Payload,
+		Status:         delivery.Status,
+		Attempts:       delivery.Attempts,
+		ResponseStatus: delivery.ResponseStatus,
+		ResponseBody:   delivery.ResponseBody,
+		LastError:      delivery.LastError,
+		DurationMs:     delivery.DurationMs,
+		CreatedAt:      delivery.CreatedAt.Format(time.DateTime),
+	}
+	if delivery.RedeliveryOf != nil {
+		resp.RedeliveryOf = *delivery.RedeliveryOf
+	}
+	if delivery.Status == service.WebhookDeliveryPending {
+		resp.NextAttemptAt = delivery.NextAttemptAt.Format(time.DateTime)
+	}
+	if delivery.DeliveredAt != nil {
+		resp.DeliveredAt = delivery.DeliveredAt.Format(time.DateTime)
+	}
+	return resp
+}
@@ -0,0 +1,61 @@
+package webhook
+
+import (
+	"context"
+	"testing"
+
+	"dmh/api/internal/service"
+	"dmh/api/internal/svc"
+	"dmh/api/internal/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func webhookAdminCtx() context.Context {
+	ctx := context.WithValue(context.Background(), "roles", []string{"platform_admin"})
+	return context.WithValue(ctx, "userId", int64(1))
+}
+
+func TestCreateWebhook_Validation(t *testing.T) {
+	svcCtx := &svc.ServiceContext{}
+
+	_, err := NewCreateWebhookLogic(context.Background(), svcCtx).CreateWebhook(&types.WebhookCreateReq{
+		BrandId: 1, Url: "https://example.com/hook", Events: []string{"order.paid"},
+	})
+	assert.ErrorIs(t, err, errWebhookForbidden)
+
+	logic := NewCreateWebhookLogic(webhookAdminCtx(), svcCtx)
+	_, err = logic.CreateWebhook(&types.WebhookCreateReq{Url: "https://example.com/hook", Events: []string{"order.paid"}})
+	assert.EqualError(t, err, "品牌ID无效")
+
+	_, err = logic.CreateWebhook(&types.WebhookCreateReq{BrandId: 1, Url: "ftp://example.com", Events: []string{"order.paid"}})
+	assert.Error(t, err)
+
+	_, err = logic.CreateWebhook(&types.WebhookCreateReq{BrandId: 1, Url: "https://example.com/hook", Events: []string{"order.deleted"}})
+	assert.EqualError(t, err, "不支持的事件类型: order.deleted")
+
+	for _, raw := range []string{"http://127.0.0.1:8080/hook", "http://169.254.169.254/latest/meta-data", "http://[::1]/hook", "http://localhost/hook"} {
+		_, err = logic.CreateWebhook(&types.WebhookCreateReq{BrandId: 1, Url: raw, Events: []string{"order.paid"}})
+		assert.ErrorIs(t, err, service.ErrWebhookAddressForbidden, raw)
+	}
+}
+
+func TestNormalizeWebhookEvents(t *testing.T) {
+	events, err := normalizeWebhookEvents([]string{"order.paid", " reward.settled", "order.paid"})
+	assert.NoError(t, err)
+	assert.Equal(t, "order.paid,reward.settled", events)
+
+	_, err = normalizeWebhookEvents(nil)
+	assert.EqualError(t, err, "至少订阅一个事件类型")
+}
+
+func TestValidateWebhookUrl(t *testing.T) {
+	url, err := validateWebhookUrl(" http://erp.example.com/dmh ")
+	assert.NoError(t, err)
+	assert.Equal(t, "http://erp.example.com/dmh", url)
+
+	for _, raw := range []string{"", "/relative", "https://", "javascript:alert(1)"} {
+		_, err := validateWebhookUrl(raw)
+		assert.Error(t, err, raw)
+	}
+}
@@ -5,13 +5,18 @@ package withdrawal
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 
+	"dmh/api/internal/service"
 	"dmh/api/internal/svc"
 	"dmh/api/internal/types"
+	"dmh/common/syncadapter"
 	"dmh/model"
 
 	"github.com/zeromicro/go-zero/core/logx"
+	"gorm.io/gorm"
 )
 
 type ApplyWithdrawalLogic struct {
@@ -37,6 +42,17 @@ func (l *ApplyWithdrawalLogic) ApplyWithdrawal(req *types.WithdrawalApplyReq, us
 		return nil, fmt.Errorf("amount must be at least 10")
 	}
 
+	payType := strings.TrimSpace(req.PayType)
+	if payType == "" {
+		payType = "bank"
+	}
+	if payType != "bank" && payType != "wechat" {
+		return nil, fmt.Errorf("invalid pay type")
+	}
+	if payType == "wechat" && strings.TrimSpace(req.PayAccount) == "" {
+		return nil, fmt.Errorf("openid is required for wechat withdrawal")
+	}
+
 	userBalance := &model.UserBalance{}
 	if err := l.svcCtx.DB.Where("user_id = ?", userId).First(userBalance).Error; err != nil {
 		l.Errorf("Failed to get user balance: %v", err)
@@ -66,17 +82,41 @@ func (l *ApplyWithdrawalLogic) ApplyWithdrawal(req *types.WithdrawalApplyReq, us
 		BankName:    req.BankName,
 		BankAccount: req.BankAccount,
 		AccountName: req.AccountName,
+		PayType:     payType,
+		PayAccount:  strings.TrimSpace(req.PayAccount),
+		PayRealName: strings.TrimSpace(req.PayRealName),
 	}
 
-	if err := l.svcCtx.DB.Create(withdrawal).Error; err != nil {
-		l.Errorf("Failed to create withdrawal: %v", err)
-		return nil, fmt.Errorf("failed to create withdrawal")
-	}
-
-	userBalance.Balance -= req.Amount
-	if err := l.svcCtx.DB.Save(userBalance).Error; err != nil {
-		l.Errorf("Failed to deduct balance: %v", err)
-		return nil, fmt.Errorf("failed to deduct balance")
+	// 创建提现申请与冻结余额在同一事务内完成
+	err = l.svcCtx.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(withdrawal).Error; err != nil {
+			l.Errorf("Failed to create withdrawal: %v", err)
+			return fmt.Errorf("failed to create withdrawal")
+		}
+
+		if _, err := service.NewBalanceService(tx).Apply(service.BalanceChange{
+			UserId:         userId,
+			Type:           service.BalanceTxWithdrawalHold,
+			CounterAccount: service.BalanceAccountWithdrawalHold,
+			Amount:         -req.Amount,
+			RefType:        "withdrawal",
+			RefId:          withdrawal.ID,
+			Remark:         "提现申请冻结",
+		}); err != nil {
+			if errors.Is(err, service.ErrInsufficientBalance) {
+				return err
+			}
+			l.Errorf("Failed to deduct balance: %v", err)
+			return fmt.Errorf("failed to deduct balance")
+		}
+
+		if err := l.svcCtx.Webhooks.PublishWithdrawal(tx, withdrawal); err != nil {
+			return err
+		}
+		return l.svcCtx.Outbox.Add(tx, syncadapter.OutboxEventWithdrawalChanged, withdrawal.ID)
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	l.Infof("Withdrawal applied successfully: id=%d, userId=%d, amount=%.2f", withdrawal.ID, userId, req.Amount)
@@ -86,6 +126,7 @@ func (l *ApplyWithdrawalLogic) ApplyWithdrawal(req *types.WithdrawalApplyReq, us
 		UserId:      withdrawal.UserID,
 		Amount:      withdrawal.Amount,
 		Status:      withdrawal.Status,
+		PayType:     withdrawal.PayType,
 		BankName:    withdrawal.BankName,
 		BankAccount: withdrawal.BankAccount,
 		AccountName: withdrawal.AccountName,
@@ -8,11 +8,14 @@ import (
 	"fmt"
 	"time"
 
+	"dmh/api/internal/service"
 	"dmh/api/internal/svc"
 	"dmh/api/internal/types"
+	"dmh/common/syncadapter"
 	"dmh/model"
 
 	"github.com/zeromicro/go-zero/core/logx"
+	"gorm.io/gorm"
 )
 
 type ApproveWithdrawalLogic struct {
@@ -48,24 +51,54 @@ func (l *ApproveWithdrawalLogic) ApproveWithdrawal(withdrawalId int64, req *type
 	withdrawal.Status = req.Status
 	withdrawal.ApprovedBy = &adminId
 	withdrawal.ApprovedAt = &now
+	updates := map[string]interface{}{
+		"status":      withdrawal.Status,
+		"approved_by": withdrawal.ApprovedBy,
+		"approved_at": withdrawal.ApprovedAt,
+	}
 
 	if req.Status == "rejected" {
 		withdrawal.RejectedReason = req.Remark
-		userBalance := &model.UserBalance{}
-		if err := l.svcCtx.DB.Where("user_id = ?", withdrawal.UserID).First(userBalance).Error; err != nil {
-			l.Errorf("Failed to get user balance: %v", err)
-			return nil, fmt.Errorf("failed to get user balance")
+		updates["rejected_reason"] = withdrawal.RejectedReason
+	}
+
+	// 驳回时解冻余额，与提现状态更新在同一事务内完成
+	err = l.svcCtx.DB.Transaction(func(tx *gorm.DB) error {
+		// 以待审核状态为条件更新，并发审核时只有一个请求生效，避免重复解冻
+		result := tx.Model(&model.Withdrawal{}).
+			Where("id = ? AND status = ?", withdrawal.ID, "pending").
+			Updates(updates)
+		if result.Error != nil {
+			l.Errorf("Failed to update withdrawal: %v", result.Error)
+			return fmt.Errorf("failed to update withdrawal")
 		}
-		userBalance.Balance += withdrawal.Amount
-		if err := l.svcCtx.DB.Save(userBalance).Error; err != nil {
-			l.Errorf("Failed to refund balance: %v", err)
-			return nil, fmt.Errorf("failed to refund balance")
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("withdrawal can only be approved when pending")
 		}
-	}
 
-	if err := l.svcCtx.DB.Save(withdrawal).Error; err != nil {
-		l.Errorf("Failed to update withdrawal: %v", err)
-		return nil, fmt.Errorf("failed to update withdrawal")
+		if req.Status == "rejected" {
+			if _, err := service.NewBalanceService(tx).Apply(service.BalanceChange{
+				UserId:         withdrawal.UserID,
+				Type:           service.BalanceTxWithdrawalRelease,
+				CounterAccount: service.BalanceAccountWithdrawalHold,
+				Amount:         withdrawal.Amount,
+				RefType:        "withdrawal",
+				RefId:          withdrawal.ID,
+				Remark:         req.Remark,
+				OperatorId:     &adminId,
+			}); err != nil {
+				l.Errorf("Failed to refund balance: %v", err)
+				return fmt.Errorf("failed to refund balance")
+			}
+		}
+
+		if err := l.svcCtx.Webhooks.PublishWithdrawal(tx, withdrawal); err != nil {
+			return err
+		}
+		return l.svcCtx.Outbox.Add(tx, syncadapter.OutboxEventWithdrawalChanged, withdrawal.ID)
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	l.Infof("Withdrawal approved: id=%d, status=%s, adminId=%d", withdrawalId, req.Status, adminId)
@@ -75,6 +108,7 @@ func (l *ApproveWithdrawalLogic) ApproveWithdrawal(withdrawalId int64, req *type
 		UserId:      withdrawal.UserID,
 		Amount:      withdrawal.Amount,
 		Status:      withdrawal.Status,
+		PayType:     withdrawal.PayType,
 		BankName:    withdrawal.BankName,
 		BankAccount: withdrawal.BankAccount,
 		AccountName: withdrawal.AccountName,
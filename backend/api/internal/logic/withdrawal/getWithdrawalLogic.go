@@ -44,6 +44,8 @@ func (l *GetWithdrawalLogic) GetWithdrawal(withdrawalId int64) (resp *types.With
 		Username:    user.Username,
 		Amount:      withdrawal.Amount,
 		Status:      withdrawal.Status,
+		PayType:     withdrawal.PayType,
+		TradeNo:     withdrawal.TradeNo,
 		BankName:    withdrawal.BankName,
 		BankAccount: withdrawal.BankAccount,
 		AccountName: withdrawal.AccountName,
@@ -54,6 +56,9 @@ func (l *GetWithdrawalLogic) GetWithdrawal(withdrawalId int64) (resp *types.With
 	if withdrawal.ApprovedAt != nil {
 		resp.ApprovedAt = withdrawal.ApprovedAt.Format("2006-01-02 15:04:05")
 	}
+	if withdrawal.PaidAt != nil {
+		resp.PaidAt = withdrawal.PaidAt.Format("2006-01-02 15:04:05")
+	}
 
 	return resp, nil
 }
@@ -70,6 +70,8 @@ func (l *GetWithdrawalsLogic) GetWithdrawals(req *types.WithdrawalListReq) (resp
 			RealName:    user.RealName,
 			Amount:      w.Amount,
 			Status:      w.Status,
+			PayType:     w.PayType,
+			TradeNo:     w.TradeNo,
 			BankName:    w.BankName,
 			BankAccount: w.BankAccount,
 			AccountName: w.AccountName,
@@ -83,6 +85,9 @@ func (l *GetWithdrawalsLogic) GetWithdrawals(req *types.WithdrawalListReq) (resp
 		if w.ApprovedAt != nil {
 			withdrawalResp.ApprovedAt = w.ApprovedAt.Format("2006-01-02 15:04:05")
 		}
+		if w.PaidAt != nil {
+			withdrawalResp.PaidAt = w.PaidAt.Format("2006-01-02 15:04:05")
+		}
 
 		respList = append(respList, withdrawalResp)
 	}
@@ -3,6 +3,7 @@ package withdrawal
 
 import (
 	"context"
+	"sync"
 	"testing"
 	"time"
 
@@ -18,8 +19,8 @@ import (
 func setupWithdrawalTestDB(t *testing.T) *gorm.DB {
 	t.Helper()
 	db := testutil.SetupGormTestDB(t)
-	db.AutoMigrate(&model.Withdrawal{}, &model.User{}, &model.Distributor{}, &model.Brand{}, &model.UserBalance{})
-	testutil.ClearTables(db, "withdrawals", "user_balances", "distributors", "brands", "users")
+	db.AutoMigrate(&model.Withdrawal{}, &model.User{}, &model.Distributor{}, &model.Brand{}, &model.UserBalance{}, &model.BalanceTransaction{})
+	testutil.ClearTables(db, "withdrawals", "balance_transactions", "user_balances", "distributors", "brands", "users")
 	return db
 }
 
@@ -231,6 +232,38 @@ func TestApproveWithdrawalLogic(t *testing.T) {
 	}
 }
 
+// TestApproveWithdrawalLogic_ConcurrentReject 并发驳回同一提现只有一个请求生效，余额只解冻一次
+func TestApproveWithdrawalLogic_ConcurrentReject(t *testing.T) {
+	db := setupWithdrawalTestDB(t)
+	db.Create(&model.UserBalance{UserId: 1, Balance: 500})
+	db.Create(&model.Withdrawal{ID: 1, UserID: 1, BrandId: 1, DistributorId: 1, Amount: 100, Status: "pending"})
+
+	logic := NewApproveWithdrawalLogic(context.Background(), &svc.ServiceContext{DB: db})
+
+	var wg sync.WaitGroup
+	errs := make([]error, 4)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = logic.ApproveWithdrawal(1, &types.WithdrawalApproveReq{Status: "rejected", Remark: "并发驳回"}, 1)
+		}(i)
+	}
+	wg.Wait()
+
+	succeeded := 0
+	for _, err := range errs {
+		if err == nil {
+			succeeded++
+		}
+	}
+	assert.Equal(t, 1, succeeded)
+
+	var balance model.UserBalance
+	db.Where("user_id = ?", 1).First(&balance)
+	assert.Equal(t, float64(600), balance.Balance)
+}
+
 func TestGetWithdrawalsLogic(t *testing.T) {
 	db := setupWithdrawalTestDB(t)
 
@@ -389,3 +422,15 @@ func TestGetWithdrawalLogic(t *testing.T) {
 		})
 	}
 }
+
+func TestApplyWithdrawalLogic_InvalidPayType(t *testing.T) {
+	logic := NewApplyWithdrawalLogic(context.Background(), &svc.ServiceContext{})
+
+	_, err := logic.ApplyWithdrawal(&types.WithdrawalApplyReq{Amount: 100, PayType: "alipay"}, 1)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid pay type")
+
+	_, err = logic.ApplyWithdrawal(&types.WithdrawalApplyReq{Amount: 100, PayType: "wechat"}, 1)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "openid is required")
+}
@@ -337,13 +337,52 @@ func IsPlatformAdmin(ctx context.Context) bool {
 	return HasRole(ctx, "platform_admin")
 }
 
-// GetUserBrandIDs 从context中获取用户管理的品牌ID列表（已废弃，保留兼容性）
+// GetUserBrandIDs 从context中获取用户管理的品牌ID列表（登录时写入 JWT 的 brandIds）
 func GetUserBrandIDs(ctx context.Context) ([]int64, error) {
-	return []int64{}, nil // 返回空列表
+	switch value := ctx.Value("brandIds").(type) {
+	case []int64:
+		return value, nil
+	case []interface{}:
+		// go-zero 的 JWT 中间件按 JSON 解析声明，数字为 json.Number
+		brandIDs := make([]int64, 0, len(value))
+		for _, item := range value {
+			switch id := item.(type) {
+			case json.Number:
+				parsed, err := id.Int64()
+				if err != nil {
+					return nil, fmt.Errorf("品牌ID转换失败: %v", err)
+				}
+				brandIDs = append(brandIDs, parsed)
+			case float64:
+				brandIDs = append(brandIDs, int64(id))
+			case int64:
+				brandIDs = append(brandIDs, id)
+			}
+		}
+		return brandIDs, nil
+	}
+	if claims, err := GetUserFromContext(ctx); err == nil {
+		return claims.BrandIDs, nil
+	}
+	return []int64{}, nil
 }
 
-// CanAccessBrand 检查用户是否可以访问指定品牌
+// CanAccessBrand 检查用户是否可以访问指定品牌：平台管理员可访问所有品牌，品牌管理员只能访问自己管理的品牌
 func CanAccessBrand(ctx context.Context, brandID int64) bool {
-	// 只有平台管理员可以访问所有品牌
-	return IsPlatformAdmin(ctx)
+	if IsPlatformAdmin(ctx) {
+		return true
+	}
+	if brandID <= 0 || !HasRole(ctx, "brand_admin") {
+		return false
+	}
+	brandIDs, err := GetUserBrandIDs(ctx)
+	if err != nil {
+		return false
+	}
+	for _, id := range brandIDs {
+		if id == brandID {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+)
+
+type clientInfoKey struct{}
+
+// ClientInfo 请求来源，供业务逻辑记录安全事件与按来源限流
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+}
+
+// WithClientInfo 将请求来源写入 context
+func WithClientInfo(r *http.Request) context.Context {
+	return context.WithValue(r.Context(), clientInfoKey{}, ClientInfo{
+		IP:        clientIP(r),
+		UserAgent: r.UserAgent(),
+	})
+}
+
+// GetClientInfo 从context中获取请求来源，未设置时 IP 为 unknown
+func GetClientInfo(ctx context.Context) ClientInfo {
+	if info, ok := ctx.Value(clientInfoKey{}).(ClientInfo); ok {
+		return info
+	}
+	return ClientInfo{IP: "unknown"}
+}
+
+func clientIP(r *http.Request) string {
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		return strings.TrimSpace(strings.Split(forwarded, ",")[0])
+	}
+	if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); realIP != "" {
+		return realIP
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	if r.RemoteAddr != "" {
+		return r.RemoteAddr
+	}
+	return "unknown"
+}
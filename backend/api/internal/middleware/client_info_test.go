@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientInfo(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/orders/scan", nil)
+	req.RemoteAddr = "192.0.2.10:53211"
+	req.Header.Set("User-Agent", "venue-scanner/1.0")
+	info := GetClientInfo(WithClientInfo(req))
+	assert.Equal(t, "192.0.2.10", info.IP)
+	assert.Equal(t, "venue-scanner/1.0", info.UserAgent)
+
+	req.Header.Set("X-Forwarded-For", "203.0.113.5, 10.0.0.1")
+	assert.Equal(t, "203.0.113.5", GetClientInfo(WithClientInfo(req)).IP)
+
+	assert.Equal(t, "unknown", GetClientInfo(context.Background()).IP)
+}
@@ -2,14 +2,23 @@ package middleware
 
 import (
 	"net/http"
+	"strconv"
 	"time"
 
+	"dmh/common/metrics"
+
 	"github.com/zeromicro/go-zero/core/logx"
+	"github.com/zeromicro/go-zero/core/search"
+	"github.com/zeromicro/go-zero/rest"
 )
 
+// unmatchedRoute 未注册路由模板时的指标标签，避免按原始路径产生大量标签值
+const unmatchedRoute = "unmatched"
+
 // PerformanceMiddleware 性能监控中间件
 type PerformanceMiddleware struct {
 	logger logx.Logger
+	routes map[string]*search.Tree // 按请求方法索引的路由模板
 }
 
 // NewPerformanceMiddleware 创建性能监控中间件
@@ -19,6 +28,20 @@ func NewPerformanceMiddleware() *PerformanceMiddleware {
 	}
 }
 
+// WithRoutes 设置已注册的路由，指标按路由模板（如 /api/v1/orders/:id）而不是原始路径统计
+func (m *PerformanceMiddleware) WithRoutes(routes []rest.Route) *PerformanceMiddleware {
+	m.routes = make(map[string]*search.Tree)
+	for _, route := range routes {
+		tree, ok := m.routes[route.Method]
+		if !ok {
+			tree = search.NewTree()
+			m.routes[route.Method] = tree
+		}
+		_ = tree.Add(route.Path, route.Path)
+	}
+	return m
+}
+
 // Handle 处理请求并记录性能指标
 func (m *PerformanceMiddleware) Handle(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -33,6 +56,10 @@ func (m *PerformanceMiddleware) Handle(next http.HandlerFunc) http.HandlerFunc {
 
 		duration := time.Since(start)
 
+		route := m.route(method, path)
+		metrics.HTTPRequests.WithLabelValues(method, route, strconv.Itoa(wrapped.statusCode)).Inc()
+		metrics.HTTPDuration.WithLabelValues(method, route).Observe(duration.Seconds())
+
 		// 记录慢请求 (超过 500ms)
 		if duration > 500*time.Millisecond {
 			m.logger.Slowf("[SLOW] %s %s - %d - %v", method, path, wrapped.statusCode, duration)
@@ -48,6 +75,18 @@ func (m *PerformanceMiddleware) Handle(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+func (m *PerformanceMiddleware) route(method, path string) string {
+	tree, ok := m.routes[method]
+	if !ok {
+		return unmatchedRoute
+	}
+	result, ok := tree.Search(path)
+	if !ok {
+		return unmatchedRoute
+	}
+	return result.Item.(string)
+}
+
 // responseWriter 包装 http.ResponseWriter 以捕获状态码
 type responseWriter struct {
 	http.ResponseWriter
@@ -5,7 +5,11 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"dmh/common/metrics"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	"github.com/zeromicro/go-zero/rest"
 )
 
 func TestPerformanceMiddlewareHandlePassThrough(t *testing.T) {
@@ -32,3 +36,23 @@ func TestResponseWriterWriteHeader(t *testing.T) {
 	assert.Equal(t, http.StatusBadRequest, rw.statusCode)
 	assert.Equal(t, http.StatusBadRequest, resp.Code)
 }
+
+func TestPerformanceMiddlewareRecordsRouteTemplate(t *testing.T) {
+	m := NewPerformanceMiddleware().WithRoutes([]rest.Route{
+		{Method: http.MethodGet, Path: "/api/v1/orders/:id"},
+	})
+	h := m.Handle(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	counter := metrics.HTTPRequests.WithLabelValues(http.MethodGet, "/api/v1/orders/:id", "404")
+	before := testutil.ToFloat64(counter)
+	h(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/orders/42", nil))
+	h(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/orders/43", nil))
+	assert.Equal(t, before+2, testutil.ToFloat64(counter))
+
+	unmatched := metrics.HTTPRequests.WithLabelValues(http.MethodPost, unmatchedRoute, "404")
+	before = testutil.ToFloat64(unmatched)
+	h(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/v1/orders/42", nil))
+	assert.Equal(t, before+1, testutil.ToFloat64(unmatched))
+}
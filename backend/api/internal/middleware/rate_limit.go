@@ -8,6 +8,8 @@ import (
 	"sync"
 	"time"
 
+	"dmh/common/metrics"
+
 	"github.com/zeromicro/go-zero/core/logx"
 )
 
@@ -227,11 +229,17 @@ type RedisClient interface {
 }
 
 func RateLimitMiddleware(limiter RateLimiter) func(http.Handler) http.Handler {
+	return NamedRateLimitMiddleware("default", limiter)
+}
+
+// NamedRateLimitMiddleware 与 RateLimitMiddleware 相同，拒绝次数按 name 计入指标
+func NamedRateLimitMiddleware(name string, limiter RateLimiter) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			userID := getUserID(r)
 
 			if !limiter.Allow(userID) {
+				metrics.RateLimitRejections.WithLabelValues(name).Inc()
 				remaining := limiter.GetRemaining(userID)
 				resetTime := limiter.GetResetTime(userID)
 
@@ -8,6 +8,9 @@ import (
 	"testing"
 	"time"
 
+	"dmh/common/metrics"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -43,6 +46,19 @@ func TestRateLimitMiddlewareReturns429(t *testing.T) {
 	assert.Contains(t, resp2.Body.String(), "请求过于频繁")
 }
 
+func TestNamedRateLimitMiddlewareCountsRejections(t *testing.T) {
+	h := NamedRateLimitMiddleware("poster", NewMemoryRateLimiter(1, time.Minute))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	counter := metrics.RateLimitRejections.WithLabelValues("poster")
+	before := testutil.ToFloat64(counter)
+
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/demo", nil)
+		req.RemoteAddr = "127.0.0.2:1000"
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	assert.Equal(t, before+2, testutil.ToFloat64(counter))
+}
+
 type mockRedisClient struct {
 	counts  map[string]int64
 	expires map[string]int
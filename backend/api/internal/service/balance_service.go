@@ -0,0 +1,235 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"dmh/model"
+
+	mysqlDriver "github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 余额流水类型
+const (
+	BalanceTxRewardCredit      = "reward_credit"
+	BalanceTxWithdrawalHold    = "withdrawal_hold"
+	BalanceTxWithdrawalRelease = "withdrawal_release"
+	BalanceTxPayout            = "payout"
+	BalanceTxRefundClawback    = "refund_clawback"
+	BalanceTxManualAdjustment  = "manual_adjustment"
+)
+
+// 余额账户，流水的本方账户与对方账户之和恒为零
+const (
+	BalanceAccountAvailable      = "available"       // 用户可用余额（即 user_balances.balance）
+	BalanceAccountWithdrawalHold = "withdrawal_hold" // 提现冻结
+	BalanceAccountRewardExpense  = "reward_expense"  // 平台奖励支出
+	BalanceAccountPayout         = "payout"          // 已打款
+	BalanceAccountAdjustment     = "adjustment"      // 人工调整
+)
+
+// ErrInsufficientBalance 可用余额不足
+var ErrInsufficientBalance = errors.New("insufficient balance")
+
+// BalanceChange 一次余额变动
+type BalanceChange struct {
+	UserId         int64
+	Type           string
+	Account        string  // 本方账户，默认为可用余额
+	CounterAccount string  // 对方账户
+	Amount         float64 // 本方账户变动金额，正数入账、负数出账
+	AllowNegative  bool    // 是否允许可用余额变为负数（如退款追回）
+	RefType        string
+	RefId          int64
+	Remark         string
+	OperatorId     *int64
+}
+
+// BalanceService 用户余额服务，所有余额变动都经由此处写入流水
+type BalanceService struct {
+	db *gorm.DB
+}
+
+// NewBalanceService 创建余额服务，db 可以是调用方的事务
+func NewBalanceService(db *gorm.DB) *BalanceService {
+	return &BalanceService{
+		db: db,
+	}
+}
+
+// Apply 写入一条余额流水并更新用户余额，二者在同一事务内完成
+func (s *BalanceService) Apply(change BalanceChange) (*model.BalanceTransaction, error) {
+	if change.UserId <= 0 {
+		return nil, errors.New("invalid user id")
+	}
+	if strings.TrimSpace(change.Type) == "" || strings.TrimSpace(change.CounterAccount) == "" {
+		return nil, errors.New("balance change type and counter account are required")
+	}
+	if change.Account == "" {
+		change.Account = BalanceAccountAvailable
+	}
+	change.Amount = math.Round(change.Amount*100) / 100
+	if change.Amount == 0 {
+		return nil, errors.New("balance change amount must not be zero")
+	}
+
+	var ledger *model.BalanceTransaction
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		balance, err := applyBalanceChange(tx, change)
+		if err != nil {
+			return err
+		}
+
+		ledger = &model.BalanceTransaction{
+			UserId:         change.UserId,
+			Type:           change.Type,
+			Account:        change.Account,
+			CounterAccount: change.CounterAccount,
+			Amount:         change.Amount,
+			BalanceBefore:  balance.Balance,
+			BalanceAfter:   balance.Balance,
+			RefType:        change.RefType,
+			RefId:          change.RefId,
+			Remark:         change.Remark,
+			OperatorId:     change.OperatorId,
+		}
+		if change.Account == BalanceAccountAvailable {
+			ledger.BalanceAfter = math.Round((balance.Balance+change.Amount)*100) / 100
+		}
+
+		return tx.Create(ledger).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ledger, nil
+}
+
+// applyBalanceChange 锁定并更新 user_balances，返回变动前的余额记录。
+// 调用方通常处于可重复读事务中，普通读取只能看到事务快照，因此必须使用锁定读取最新余额，
+// 并发变动在行锁上串行，不在事务内重试
+func applyBalanceChange(tx *gorm.DB, change BalanceChange) (*model.UserBalance, error) {
+	balance, err := lockUserBalance(tx, change.UserId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if change.Account != BalanceAccountAvailable {
+			return &model.UserBalance{UserId: change.UserId}, nil
+		}
+		if change.Amount < 0 && !change.AllowNegative {
+			return nil, ErrInsufficientBalance
+		}
+
+		created := model.UserBalance{
+			UserId:      change.UserId,
+			Balance:     change.Amount,
+			TotalReward: rewardDelta(change),
+		}
+		err = tx.Create(&created).Error
+		if err == nil {
+			return &model.UserBalance{UserId: change.UserId}, nil
+		}
+		if !isDuplicateKeyError(err) {
+			return nil, err
+		}
+		// 并发创建的记录已提交，锁定读取可见
+		balance, err = lockUserBalance(tx, change.UserId)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// 冻结账户之间的流转（如打款）不影响可用余额
+	if change.Account != BalanceAccountAvailable {
+		return balance, nil
+	}
+
+	if change.Amount < 0 && !change.AllowNegative && balance.Balance+change.Amount < 0 {
+		return nil, ErrInsufficientBalance
+	}
+
+	updates := map[string]interface{}{
+		"balance": gorm.Expr("balance + ?", change.Amount),
+		"version": gorm.Expr("version + 1"),
+	}
+	if delta := rewardDelta(change); delta != 0 {
+		updates["total_reward"] = gorm.Expr("total_reward + ?", delta)
+	}
+
+	result := tx.Model(&model.UserBalance{}).
+		Where("id = ? AND version = ?", balance.Id, balance.Version).
+		Updates(updates)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected != 1 {
+		return nil, fmt.Errorf("user balance update conflict: userId=%d", change.UserId)
+	}
+	return balance, nil
+}
+
+func lockUserBalance(tx *gorm.DB, userId int64) (*model.UserBalance, error) {
+	var balance model.UserBalance
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ?", userId).First(&balance).Error; err != nil {
+		return nil, err
+	}
+	return &balance, nil
+}
+
+// rewardDelta 奖励入账与退款追回同时计入累计奖励
+func rewardDelta(change BalanceChange) float64 {
+	switch change.Type {
+	case BalanceTxRewardCredit, BalanceTxRefundClawback:
+		return change.Amount
+	default:
+		return 0
+	}
+}
+
+func isDuplicateKeyError(err error) bool {
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return true
+	}
+	var mysqlErr *mysqlDriver.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "duplicate entry") || strings.Contains(msg, "unique constraint")
+}
+
+// ListTransactions 分页查询用户余额流水，按时间倒序
+func (s *BalanceService) ListTransactions(userId int64, txType string, page, pageSize int) ([]model.BalanceTransaction, int64, error) {
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+	if pageSize > 100 {
+		pageSize = 100
+	}
+
+	query := s.db.Model(&model.BalanceTransaction{}).Where("user_id = ?", userId)
+	if txType != "" {
+		query = query.Where("type = ?", txType)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var transactions []model.BalanceTransaction
+	if err := query.Order("created_at DESC, id DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&transactions).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return transactions, total, nil
+}
@@ -0,0 +1,171 @@
+package service
+
+import (
+	"testing"
+
+	"dmh/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+type BalanceServiceTestSuite struct {
+	suite.Suite
+	db             *gorm.DB
+	balanceService *BalanceService
+}
+
+func (suite *BalanceServiceTestSuite) SetupSuite() {
+	db, err := gorm.Open(mysql.Open("root:Admin168@tcp(127.0.0.1:3306)/dmh_test?charset=utf8mb4&parseTime=true&loc=Local"), &gorm.Config{})
+	suite.Require().NoError(err)
+
+	err = db.AutoMigrate(
+		&model.UserBalance{},
+		&model.BalanceTransaction{},
+	)
+	suite.Require().NoError(err)
+
+	suite.db = db
+	suite.balanceService = NewBalanceService(db)
+}
+
+func (suite *BalanceServiceTestSuite) TearDownSuite() {
+	sqlDB, _ := suite.db.DB()
+	sqlDB.Close()
+}
+
+func (suite *BalanceServiceTestSuite) SetupTest() {
+	suite.Require().NoError(suite.db.Exec("TRUNCATE TABLE balance_transactions").Error)
+	suite.Require().NoError(suite.db.Exec("TRUNCATE TABLE user_balances").Error)
+}
+
+func (suite *BalanceServiceTestSuite) TestRewardCreditCreatesBalance() {
+	ledger, err := suite.balanceService.Apply(BalanceChange{
+		UserId:         1,
+		Type:           BalanceTxRewardCredit,
+		CounterAccount: BalanceAccountRewardExpense,
+		Amount:         12.5,
+		RefType:        "order",
+		RefId:          100,
+	})
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), BalanceAccountAvailable, ledger.Account)
+	assert.Equal(suite.T(), 0.0, ledger.BalanceBefore)
+	assert.Equal(suite.T(), 12.5, ledger.BalanceAfter)
+
+	var balance model.UserBalance
+	suite.Require().NoError(suite.db.Where("user_id = ?", 1).First(&balance).Error)
+	assert.Equal(suite.T(), 12.5, balance.Balance)
+	assert.Equal(suite.T(), 12.5, balance.TotalReward)
+}
+
+func (suite *BalanceServiceTestSuite) TestWithdrawalHoldAndRelease() {
+	suite.Require().NoError(suite.db.Create(&model.UserBalance{UserId: 2, Balance: 100, TotalReward: 100}).Error)
+
+	_, err := suite.balanceService.Apply(BalanceChange{
+		UserId:         2,
+		Type:           BalanceTxWithdrawalHold,
+		CounterAccount: BalanceAccountWithdrawalHold,
+		Amount:         -150,
+	})
+	assert.ErrorIs(suite.T(), err, ErrInsufficientBalance)
+
+	hold, err := suite.balanceService.Apply(BalanceChange{
+		UserId:         2,
+		Type:           BalanceTxWithdrawalHold,
+		CounterAccount: BalanceAccountWithdrawalHold,
+		Amount:         -60,
+	})
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), 40.0, hold.BalanceAfter)
+
+	release, err := suite.balanceService.Apply(BalanceChange{
+		UserId:         2,
+		Type:           BalanceTxWithdrawalRelease,
+		CounterAccount: BalanceAccountWithdrawalHold,
+		Amount:         60,
+	})
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), 100.0, release.BalanceAfter)
+
+	var balance model.UserBalance
+	suite.Require().NoError(suite.db.Where("user_id = ?", 2).First(&balance).Error)
+	assert.Equal(suite.T(), 100.0, balance.Balance)
+	assert.Equal(suite.T(), 100.0, balance.TotalReward)
+	assert.Equal(suite.T(), int64(2), balance.Version)
+}
+
+func (suite *BalanceServiceTestSuite) TestClawbackAllowsNegativeBalance() {
+	suite.Require().NoError(suite.db.Create(&model.UserBalance{UserId: 3, Balance: 5, TotalReward: 20}).Error)
+
+	ledger, err := suite.balanceService.Apply(BalanceChange{
+		UserId:         3,
+		Type:           BalanceTxRefundClawback,
+		CounterAccount: BalanceAccountRewardExpense,
+		Amount:         -20,
+		AllowNegative:  true,
+	})
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), -15.0, ledger.BalanceAfter)
+
+	var balance model.UserBalance
+	suite.Require().NoError(suite.db.Where("user_id = ?", 3).First(&balance).Error)
+	assert.Equal(suite.T(), -15.0, balance.Balance)
+	assert.Equal(suite.T(), 0.0, balance.TotalReward)
+}
+
+func (suite *BalanceServiceTestSuite) TestPayoutDoesNotChangeAvailableBalance() {
+	suite.Require().NoError(suite.db.Create(&model.UserBalance{UserId: 4, Balance: 30}).Error)
+
+	ledger, err := suite.balanceService.Apply(BalanceChange{
+		UserId:         4,
+		Type:           BalanceTxPayout,
+		Account:        BalanceAccountWithdrawalHold,
+		CounterAccount: BalanceAccountPayout,
+		Amount:         -50,
+	})
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), 30.0, ledger.BalanceBefore)
+	assert.Equal(suite.T(), 30.0, ledger.BalanceAfter)
+
+	var balance model.UserBalance
+	suite.Require().NoError(suite.db.Where("user_id = ?", 4).First(&balance).Error)
+	assert.Equal(suite.T(), 30.0, balance.Balance)
+}
+
+func (suite *BalanceServiceTestSuite) TestListTransactions() {
+	for i := 0; i < 3; i++ {
+		_, err := suite.balanceService.Apply(BalanceChange{
+			UserId:         5,
+			Type:           BalanceTxRewardCredit,
+			CounterAccount: BalanceAccountRewardExpense,
+			Amount:         float64(i + 1),
+		})
+		suite.Require().NoError(err)
+	}
+
+	list, total, err := suite.balanceService.ListTransactions(5, BalanceTxRewardCredit, 1, 2)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), int64(3), total)
+	suite.Require().Len(list, 2)
+	assert.Equal(suite.T(), 6.0, list[0].BalanceAfter)
+}
+
+func TestBalanceServiceTestSuite(t *testing.T) {
+	suite.Run(t, new(BalanceServiceTestSuite))
+}
+
+func TestBalanceServiceApply_InvalidChange(t *testing.T) {
+	s := NewBalanceService(nil)
+
+	_, err := s.Apply(BalanceChange{UserId: 1, Type: BalanceTxManualAdjustment, CounterAccount: BalanceAccountAdjustment})
+	assert.Error(t, err)
+
+	_, err = s.Apply(BalanceChange{UserId: 1, Amount: 10})
+	assert.Error(t, err)
+
+	_, err = s.Apply(BalanceChange{Type: BalanceTxManualAdjustment, CounterAccount: BalanceAccountAdjustment, Amount: 10})
+	assert.Error(t, err)
+}
@@ -0,0 +1,251 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"dmh/model"
+
+	"github.com/zeromicro/go-zero/core/logx"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrCampaignFull 活动总名额已满
+	ErrCampaignFull = errors.New("活动名额已满")
+	// ErrCampaignDailyFull 活动当日名额已满
+	ErrCampaignDailyFull = errors.New("今日名额已满，请明天再来")
+	// ErrSeatCounterMissing 名额计数尚未初始化
+	ErrSeatCounterMissing = errors.New("名额计数不存在")
+)
+
+// seatCounterTTL 计数闲置超过该时长后过期，下次预占时从数据库重新统计，修正 Redis 不可用期间产生的偏差。
+// 每次预占与释放都会续期，避免下单中的预占尚未落库时计数过期、按数据库重建后少计名额
+const seatCounterTTL = 10 * time.Minute
+
+// SeatCounter 活动名额计数器（Redis），不可用时名额校验退回数据库加锁统计
+type SeatCounter interface {
+	// Reserve 所有计数均未达到上限时原子加一、续期 ttl 并返回 -1，否则不做修改并返回已满计数的下标；
+	// 任一计数不存在时返回 ErrSeatCounterMissing
+	Reserve(ctx context.Context, keys []string, limits []int64, ttl time.Duration) (int, error)
+	// Init 计数不存在时以给定值初始化
+	Init(ctx context.Context, key string, value int64, ttl time.Duration) error
+	// Release 计数存在且大于零时减一，并续期 ttl
+	Release(ctx context.Context, key string, ttl time.Duration) error
+	// Reset 删除计数，下次预占时从数据库重新统计
+	Reset(ctx context.Context, key string) error
+}
+
+// CampaignSeatService 活动名额（总量与每日限额）的预占与释放
+type CampaignSeatService struct {
+	db      *gorm.DB
+	counter SeatCounter
+	logger  logx.Logger
+}
+
+// NewCampaignSeatService 创建名额服务，counter 为 nil 时只使用数据库
+func NewCampaignSeatService(db *gorm.DB, counter SeatCounter) *CampaignSeatService {
+	return &CampaignSeatService{
+		db:      db,
+		counter: counter,
+		logger:  logx.WithContext(context.Background()),
+	}
+}
+
+// SeatUsage 已占用的名额
+type SeatUsage struct {
+	Total int64
+	Today int64
+}
+
+// CreateOrder 在活动名额限制内创建订单，名额已满时返回 ErrCampaignFull 或 ErrCampaignDailyFull。
+// afterCreate 与插入订单在同一事务内执行（如写入核销码），返回错误时订单回滚并释放名额
+func (s *CampaignSeatService) CreateOrder(ctx context.Context, campaign *model.Campaign, order *model.Order, afterCreate func(tx *gorm.DB) error) error {
+	create := func(tx *gorm.DB) error {
+		if err := tx.Create(order).Error; err != nil {
+			return err
+		}
+		if afterCreate != nil {
+			return afterCreate(tx)
+		}
+		return nil
+	}
+
+	if !campaign.HasQuota() {
+		return s.db.Transaction(create)
+	}
+
+	now := time.Now()
+	if s.counter != nil {
+		err := s.reserve(ctx, campaign, now)
+		if err == nil {
+			order.SeatCounted = true
+			if err := s.db.Transaction(create); err != nil {
+				order.SeatCounted = false
+				s.release(ctx, campaign.Id, now)
+				return err
+			}
+			return nil
+		}
+		if errors.Is(err, ErrCampaignFull) || errors.Is(err, ErrCampaignDailyFull) {
+			return err
+		}
+		s.logger.Errorf("名额计数不可用，退回数据库校验: campaignId=%d, err=%v", campaign.Id, err)
+	}
+
+	// 锁定活动行，保证同一活动的名额校验与下单串行执行
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var locked model.Campaign
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&locked, campaign.Id).Error; err != nil {
+			return err
+		}
+
+		usage, err := s.countUsage(tx, campaign.Id, now)
+		if err != nil {
+			return err
+		}
+		if err := checkQuota(campaign, usage); err != nil {
+			return err
+		}
+
+		return create(tx)
+	})
+}
+
+// Release 订单取消或过期后释放名额；数据库统计只计有效订单，这里只需回退 Redis 计数。
+// 数据库兜底创建的订单未计入计数，回退会使计数偏低，改为删除计数由下次预占按数据库重建
+func (s *CampaignSeatService) Release(ctx context.Context, order *model.Order) {
+	if s.counter == nil {
+		return
+	}
+	if order.SeatCounted {
+		s.release(ctx, order.CampaignId, order.CreatedAt)
+		return
+	}
+	for _, key := range []string{totalSeatKey(order.CampaignId), dailySeatKey(order.CampaignId, order.CreatedAt)} {
+		if err := s.counter.Reset(ctx, key); err != nil {
+			s.logger.Errorf("重置名额计数失败: key=%s, err=%v", key, err)
+		}
+	}
+}
+
+// Remaining 剩余可报名名额，未设置名额限制时返回 nil
+func (s *CampaignSeatService) Remaining(campaign *model.Campaign) (*int64, error) {
+	if !campaign.HasQuota() {
+		return nil, nil
+	}
+
+	usage, err := s.countUsage(s.db, campaign.Id, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	remaining := int64(-1)
+	if campaign.TotalQuota > 0 {
+		remaining = int64(campaign.TotalQuota) - usage.Total
+	}
+	if campaign.DailyQuota > 0 {
+		daily := int64(campaign.DailyQuota) - usage.Today
+		if remaining < 0 || daily < remaining {
+			remaining = daily
+		}
+	}
+	if remaining < 0 {
+		remaining = 0
+	}
+	return &remaining, nil
+}
+
+func (s *CampaignSeatService) reserve(ctx context.Context, campaign *model.Campaign, now time.Time) error {
+	keys, limits := seatCounterKeys(campaign, now)
+
+	full, err := s.counter.Reserve(ctx, keys, limits, seatCounterTTL)
+	if errors.Is(err, ErrSeatCounterMissing) {
+		usage, countErr := s.countUsage(s.db, campaign.Id, now)
+		if countErr != nil {
+			return countErr
+		}
+		for _, key := range keys {
+			value := usage.Total
+			if key != totalSeatKey(campaign.Id) {
+				value = usage.Today
+			}
+			if err := s.counter.Init(ctx, key, value, seatCounterTTL); err != nil {
+				return err
+			}
+		}
+		full, err = s.counter.Reserve(ctx, keys, limits, seatCounterTTL)
+	}
+	if err != nil {
+		return err
+	}
+
+	if full < 0 {
+		return nil
+	}
+	if keys[full] == totalSeatKey(campaign.Id) {
+		return ErrCampaignFull
+	}
+	return ErrCampaignDailyFull
+}
+
+func (s *CampaignSeatService) release(ctx context.Context, campaignId int64, day time.Time) {
+	for _, key := range []string{totalSeatKey(campaignId), dailySeatKey(campaignId, day)} {
+		if err := s.counter.Release(ctx, key, seatCounterTTL); err != nil {
+			s.logger.Errorf("释放名额计数失败: key=%s, err=%v", key, err)
+		}
+	}
+}
+
+// countUsage 统计有效订单占用的名额，已取消或删除的订单不占名额
+func (s *CampaignSeatService) countUsage(db *gorm.DB, campaignId int64, now time.Time) (SeatUsage, error) {
+	var usage SeatUsage
+	active := db.Model(&model.Order{}).Where("campaign_id = ? AND status <> ? AND deleted_at IS NULL", campaignId, "cancelled")
+	if err := active.Session(&gorm.Session{}).Count(&usage.Total).Error; err != nil {
+		return usage, fmt.Errorf("统计活动名额失败: %w", err)
+	}
+
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	if err := active.Session(&gorm.Session{}).
+		Where("created_at >= ? AND created_at < ?", dayStart, dayStart.AddDate(0, 0, 1)).
+		Count(&usage.Today).Error; err != nil {
+		return usage, fmt.Errorf("统计活动名额失败: %w", err)
+	}
+	return usage, nil
+}
+
+func checkQuota(campaign *model.Campaign, usage SeatUsage) error {
+	if campaign.TotalQuota > 0 && usage.Total >= int64(campaign.TotalQuota) {
+		return ErrCampaignFull
+	}
+	if campaign.DailyQuota > 0 && usage.Today >= int64(campaign.DailyQuota) {
+		return ErrCampaignDailyFull
+	}
+	return nil
+}
+
+func seatCounterKeys(campaign *model.Campaign, now time.Time) ([]string, []int64) {
+	var keys []string
+	var limits []int64
+	if campaign.TotalQuota > 0 {
+		keys = append(keys, totalSeatKey(campaign.Id))
+		limits = append(limits, int64(campaign.TotalQuota))
+	}
+	if campaign.DailyQuota > 0 {
+		keys = append(keys, dailySeatKey(campaign.Id, now))
+		limits = append(limits, int64(campaign.DailyQuota))
+	}
+	return keys, limits
+}
+
+// 同一活动的计数使用相同的 hash tag，保证 Lua 脚本在集群模式下落在同一节点
+func totalSeatKey(campaignId int64) string {
+	return fmt.Sprintf("dmh:seats:{campaign:%d}:total", campaignId)
+}
+
+func dailySeatKey(campaignId int64, day time.Time) string {
+	return fmt.Sprintf("dmh:seats:{campaign:%d}:%s", campaignId, day.Format("20060102"))
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"dmh/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// memorySeatCounter 内存实现的名额计数器，语义与 Redis 脚本一致
+type memorySeatCounter struct {
+	values map[string]int64
+}
+
+func (c *memorySeatCounter) Reserve(ctx context.Context, keys []string, limits []int64, ttl time.Duration) (int, error) {
+	for i, key := range keys {
+		used, ok := c.values[key]
+		if !ok {
+			return 0, ErrSeatCounterMissing
+		}
+		if used >= limits[i] {
+			return i, nil
+		}
+	}
+	for _, key := range keys {
+		c.values[key]++
+	}
+	return -1, nil
+}
+
+func (c *memorySeatCounter) Init(ctx context.Context, key string, value int64, ttl time.Duration) error {
+	if _, ok := c.values[key]; !ok {
+		c.values[key] = value
+	}
+	return nil
+}
+
+func (c *memorySeatCounter) Release(ctx context.Context, key string, ttl time.Duration) error {
+	if c.values[key] > 0 {
+		c.values[key]--
+	}
+	return nil
+}
+
+func (c *memorySeatCounter) Reset(ctx context.Context, key string) error {
+	delete(c.values, key)
+	return nil
+}
+
+func TestCampaignSeatReserveWithCounter(t *testing.T) {
+	now := time.Date(2026, 10, 18, 9, 0, 0, 0, time.Local)
+	campaign := &model.Campaign{Id: 7, TotalQuota: 3, DailyQuota: 2}
+	counter := &memorySeatCounter{values: map[string]int64{
+		totalSeatKey(7):      1,
+		dailySeatKey(7, now): 0,
+	}}
+	seats := NewCampaignSeatService(nil, counter)
+
+	assert.NoError(t, seats.reserve(context.Background(), campaign, now))
+	assert.NoError(t, seats.reserve(context.Background(), campaign, now))
+	assert.ErrorIs(t, seats.reserve(context.Background(), campaign, now), ErrCampaignFull)
+	assert.Equal(t, int64(2), counter.values[dailySeatKey(7, now)])
+
+	campaign.TotalQuota = 10
+	assert.ErrorIs(t, seats.reserve(context.Background(), campaign, now), ErrCampaignDailyFull)
+
+	seats.Release(context.Background(), &model.Order{CampaignId: 7, CreatedAt: now, SeatCounted: true})
+	assert.Equal(t, int64(2), counter.values[totalSeatKey(7)])
+	assert.NoError(t, seats.reserve(context.Background(), campaign, now))
+
+	tomorrow := now.AddDate(0, 0, 1)
+	counter.values[dailySeatKey(7, tomorrow)] = 0
+	assert.NoError(t, seats.reserve(context.Background(), campaign, tomorrow))
+}
+
+func TestCampaignSeatReleaseUncountedOrderResetsCounter(t *testing.T) {
+	now := time.Date(2026, 10, 18, 9, 0, 0, 0, time.Local)
+	counter := &memorySeatCounter{values: map[string]int64{
+		totalSeatKey(7):      2,
+		dailySeatKey(7, now): 1,
+	}}
+	seats := NewCampaignSeatService(nil, counter)
+
+	// 数据库兜底创建的订单未计入计数，释放时不回退，删除计数等待按数据库重建
+	seats.Release(context.Background(), &model.Order{CampaignId: 7, CreatedAt: now})
+	_, total := counter.values[totalSeatKey(7)]
+	_, daily := counter.values[dailySeatKey(7, now)]
+	assert.False(t, total)
+	assert.False(t, daily)
+}
+
+func TestCheckQuota(t *testing.T) {
+	assert.NoError(t, checkQuota(&model.Campaign{TotalQuota: 2}, SeatUsage{Total: 1, Today: 1}))
+	assert.ErrorIs(t, checkQuota(&model.Campaign{TotalQuota: 2}, SeatUsage{Total: 2}), ErrCampaignFull)
+	assert.ErrorIs(t, checkQuota(&model.Campaign{DailyQuota: 1}, SeatUsage{Total: 5, Today: 1}), ErrCampaignDailyFull)
+	assert.NoError(t, checkQuota(&model.Campaign{}, SeatUsage{Total: 100, Today: 100}))
+}
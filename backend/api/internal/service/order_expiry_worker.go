@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"dmh/common/wechatpay"
+	"dmh/model"
+
+	"github.com/zeromicro/go-zero/core/logx"
+	"gorm.io/gorm"
+)
+
+// OrderExpiryWorkerConfig 未支付订单关闭Worker配置
+type OrderExpiryWorkerConfig struct {
+	Interval  time.Duration // 扫描间隔
+	Window    time.Duration // 下单后超过该时长仍未支付则关闭
+	BatchSize int           // 每轮最多关闭的订单数
+}
+
+// OrderExpiryWorker 关闭超时未支付的订单，释放手机号的报名名额
+type OrderExpiryWorker struct {
+	db         *gorm.DB
+	payService *wechatpay.Service
+	seats      *CampaignSeatService
+	config     OrderExpiryWorkerConfig
+	logger     logx.Logger
+	stop       chan struct{}
+}
+
+// NewOrderExpiryWorker 创建未支付订单关闭Worker
+func NewOrderExpiryWorker(db *gorm.DB, payService *wechatpay.Service, seats *CampaignSeatService, config OrderExpiryWorkerConfig) *OrderExpiryWorker {
+	if config.Interval <= 0 {
+		config.Interval = time.Minute
+	}
+	if config.Window <= 0 {
+		config.Window = 30 * time.Minute
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 100
+	}
+
+	return &OrderExpiryWorker{
+		db:         db,
+		payService: payService,
+		seats:      seats,
+		config:     config,
+		logger:     logx.WithContext(context.Background()),
+		stop:       make(chan struct{}),
+	}
+}
+
+// Start 启动Worker，阻塞直到 Stop 被调用
+func (w *OrderExpiryWorker) Start() {
+	w.logger.Info("OrderExpiryWorker started")
+
+	ticker := time.NewTicker(w.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		w.RunOnce()
+
+		select {
+		case <-w.stop:
+			w.logger.Info("OrderExpiryWorker stopping...")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stop 停止Worker
+func (w *OrderExpiryWorker) Stop() {
+	close(w.stop)
+}
+
+// RunOnce 关闭一批超时未支付的订单，返回本轮关闭的数量
+func (w *OrderExpiryWorker) RunOnce() int {
+	deadline := time.Now().Add(-w.config.Window)
+
+	var orders []model.Order
+	if err := expiredOrderQuery(w.db, deadline).
+		Order("id ASC").
+		Limit(w.config.BatchSize).
+		Find(&orders).Error; err != nil {
+		w.logger.Errorf("Failed to query expired orders: %v", err)
+		return 0
+	}
+
+	closed := 0
+	for i := range orders {
+		if w.expire(&orders[i], deadline) {
+			closed++
+		}
+	}
+
+	return closed
+}
+
+// expiredOrderQuery 需要支付、下单超过时限仍未支付且未核销的订单；免费活动的订单不会过期
+func expiredOrderQuery(db *gorm.DB, deadline time.Time) *gorm.DB {
+	return db.Model(&model.Order{}).
+		Where("status = ? AND pay_status = ? AND amount > 0", "pending", "unpaid").
+		Where("verification_status NOT IN ?", []string{"verified", "partial"}).
+		Where("created_at <= ? AND deleted_at IS NULL", deadline)
+}
+
+func (w *OrderExpiryWorker) expire(order *model.Order, deadline time.Time) bool {
+	// 先关闭微信侧订单，防止用户在订单取消后仍能扫码支付
+	if order.OutTradeNo != "" && w.payService != nil {
+		err := w.payService.CloseOrder(order.OutTradeNo)
+		if errors.Is(err, wechatpay.ErrOrderPaid) {
+			w.logger.Infof("Order paid before expiry, waiting for payment notify: id=%d, outTradeNo=%s", order.Id, order.OutTradeNo)
+			return false
+		}
+		if err != nil {
+			w.logger.Errorf("Failed to close wechat order, will retry: id=%d, outTradeNo=%s, err=%v", order.Id, order.OutTradeNo, err)
+			return false
+		}
+	}
+
+	closed := false
+	err := w.db.Transaction(func(tx *gorm.DB) error {
+		updated := expiredOrderQuery(tx, deadline).
+			Where("id = ?", order.Id).
+			Updates(map[string]interface{}{
+				"status":         "cancelled",
+				"pay_code_url":   "",
+				"pay_expires_at": nil,
+			})
+		if updated.Error != nil {
+			return updated.Error
+		}
+		if updated.RowsAffected == 0 {
+			return nil
+		}
+		closed = true
+
+		return NewAuditService(tx).LogUserAction(&AuditContext{Username: "system"}, "expire_order", "order",
+			strconv.FormatInt(order.Id, 10), map[string]interface{}{
+				"campaignId": order.CampaignId,
+				"phone":      order.Phone,
+				"amount":     order.Amount,
+				"outTradeNo": order.OutTradeNo,
+				"createdAt":  order.CreatedAt.Format("2006-01-02T15:04:05"),
+			})
+	})
+	if err != nil {
+		w.logger.Errorf("Failed to expire order: id=%d, err=%v", order.Id, err)
+		return false
+	}
+
+	if closed {
+		if w.seats != nil {
+			w.seats.Release(context.Background(), order)
+		}
+		w.logger.Infof("Order expired: id=%d, campaignId=%d, outTradeNo=%s", order.Id, order.CampaignId, order.OutTradeNo)
+	}
+	return closed
+}
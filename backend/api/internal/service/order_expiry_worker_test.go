@@ -0,0 +1,149 @@
+package service
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"dmh/common/wechatpay"
+	"dmh/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+type OrderExpiryWorkerTestSuite struct {
+	suite.Suite
+	db *gorm.DB
+}
+
+func (suite *OrderExpiryWorkerTestSuite) SetupSuite() {
+	db, err := gorm.Open(mysql.Open("root:Admin168@tcp(127.0.0.1:3306)/dmh_test?charset=utf8mb4&parseTime=true&loc=Local"), &gorm.Config{})
+	suite.Require().NoError(err)
+
+	err = db.AutoMigrate(&model.Order{}, &model.AuditLog{})
+	suite.Require().NoError(err)
+
+	suite.db = db
+}
+
+func (suite *OrderExpiryWorkerTestSuite) TearDownSuite() {
+	sqlDB, _ := suite.db.DB()
+	sqlDB.Close()
+}
+
+func (suite *OrderExpiryWorkerTestSuite) SetupTest() {
+	suite.Require().NoError(suite.db.Exec("TRUNCATE TABLE orders").Error)
+	suite.Require().NoError(suite.db.Exec("TRUNCATE TABLE audit_logs").Error)
+}
+
+// newStandInWorker 使用本地模拟网关创建Worker，gateway 返回关单响应XML
+func (suite *OrderExpiryWorkerTestSuite) newStandInWorker(gateway func(body string) string) (*OrderExpiryWorker, *httptest.Server) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		_, _ = w.Write([]byte(gateway(string(body))))
+	}))
+
+	payService := wechatpay.NewService(&wechatpay.Config{
+		AppID:           "wx-app",
+		MchID:           "mch-1",
+		APIKey:          "key123",
+		UnifiedOrderURL: server.URL,
+	})
+
+	return NewOrderExpiryWorker(suite.db, payService, nil, OrderExpiryWorkerConfig{Window: 30 * time.Minute}), server
+}
+
+func (suite *OrderExpiryWorkerTestSuite) createOrder(phone string, amount float64, age time.Duration, outTradeNo string) *model.Order {
+	order := &model.Order{
+		CampaignId:         1,
+		Phone:              phone,
+		FormData:           "{}",
+		Status:             "pending",
+		PayStatus:          "unpaid",
+		Amount:             amount,
+		VerificationStatus: "unverified",
+		OutTradeNo:         outTradeNo,
+		PayCodeURL:         "weixin://wxpay/bizpayurl?pr=abc",
+	}
+	suite.Require().NoError(suite.db.Create(order).Error)
+	suite.Require().NoError(suite.db.Model(order).Update("created_at", time.Now().Add(-age)).Error)
+	return order
+}
+
+func (suite *OrderExpiryWorkerTestSuite) TestExpiresUnpaidOrders() {
+	closeRequests := []string{}
+	worker, server := suite.newStandInWorker(func(body string) string {
+		closeRequests = append(closeRequests, body)
+		return "<xml><return_code>SUCCESS</return_code><result_code>SUCCESS</result_code></xml>"
+	})
+	defer server.Close()
+
+	expired := suite.createOrder("13800000001", 99, time.Hour, "DMH1")
+	noPrepay := suite.createOrder("13800000002", 99, time.Hour, "")
+	fresh := suite.createOrder("13800000003", 99, time.Minute, "DMH3")
+	free := suite.createOrder("13800000004", 0, time.Hour, "")
+
+	assert.Equal(suite.T(), 2, worker.RunOnce())
+	assert.Len(suite.T(), closeRequests, 1)
+	assert.True(suite.T(), strings.Contains(closeRequests[0], "DMH1"))
+
+	var updated model.Order
+	suite.Require().NoError(suite.db.First(&updated, expired.Id).Error)
+	assert.Equal(suite.T(), "cancelled", updated.Status)
+	assert.Empty(suite.T(), updated.PayCodeURL)
+	suite.Require().NoError(suite.db.First(&updated, noPrepay.Id).Error)
+	assert.Equal(suite.T(), "cancelled", updated.Status)
+	suite.Require().NoError(suite.db.First(&updated, fresh.Id).Error)
+	assert.Equal(suite.T(), "pending", updated.Status)
+	suite.Require().NoError(suite.db.First(&updated, free.Id).Error)
+	assert.Equal(suite.T(), "pending", updated.Status)
+
+	var logs []model.AuditLog
+	suite.Require().NoError(suite.db.Where("action = ?", "expire_order").Find(&logs).Error)
+	assert.Len(suite.T(), logs, 2)
+
+	assert.Equal(suite.T(), 0, worker.RunOnce())
+}
+
+func (suite *OrderExpiryWorkerTestSuite) TestPaidAtGatewayKeepsOrder() {
+	worker, server := suite.newStandInWorker(func(string) string {
+		return "<xml><return_code>SUCCESS</return_code><result_code>FAIL</result_code>" +
+			"<err_code>ORDERPAID</err_code><err_code_des>订单已支付</err_code_des></xml>"
+	})
+	defer server.Close()
+
+	order := suite.createOrder("13800000005", 99, time.Hour, "DMH5")
+	assert.Equal(suite.T(), 0, worker.RunOnce())
+
+	var updated model.Order
+	suite.Require().NoError(suite.db.First(&updated, order.Id).Error)
+	assert.Equal(suite.T(), "pending", updated.Status)
+}
+
+func (suite *OrderExpiryWorkerTestSuite) TestCloseFailureRetriesNextRound() {
+	fail := true
+	worker, server := suite.newStandInWorker(func(string) string {
+		if fail {
+			return "<xml><return_code>SUCCESS</return_code><result_code>FAIL</result_code>" +
+				"<err_code>SYSTEMERROR</err_code><err_code_des>系统繁忙</err_code_des></xml>"
+		}
+		return "<xml><return_code>SUCCESS</return_code><result_code>SUCCESS</result_code></xml>"
+	})
+	defer server.Close()
+
+	suite.createOrder("13800000006", 99, time.Hour, "DMH6")
+	assert.Equal(suite.T(), 0, worker.RunOnce())
+
+	fail = false
+	assert.Equal(suite.T(), 1, worker.RunOnce())
+}
+
+func TestOrderExpiryWorkerTestSuite(t *testing.T) {
+	suite.Run(t, new(OrderExpiryWorkerTestSuite))
+}
@@ -0,0 +1,337 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"dmh/common/syncadapter"
+	"dmh/common/wechatpay"
+	"dmh/model"
+
+	"github.com/zeromicro/go-zero/core/logx"
+	"gorm.io/gorm"
+)
+
+// PayoutWorkerConfig 提现打款Worker配置
+type PayoutWorkerConfig struct {
+	Interval     time.Duration // 轮询间隔
+	BatchSize    int           // 每轮最多处理的提现数
+	MaxAttempts  int           // 最大打款尝试次数；确认未付款时解冻余额，结果未知时转人工核对
+	RetryBackoff time.Duration // 重试退避基数，第 n 次重试等待 n 倍
+	Lease        time.Duration // 处理租约，防止多个实例重复打款
+}
+
+// PayoutWorker 将已审核通过的微信提现通过企业付款打到用户零钱
+type PayoutWorker struct {
+	db         *gorm.DB
+	payService *wechatpay.Service
+	outbox     *syncadapter.Outbox
+	webhooks   *WebhookPublisher
+	config     PayoutWorkerConfig
+	logger     logx.Logger
+	stop       chan struct{}
+}
+
+// NewPayoutWorker 创建提现打款Worker，outbox、webhooks 为 nil 时不记录外部同步事件与 Webhook
+func NewPayoutWorker(db *gorm.DB, payService *wechatpay.Service, outbox *syncadapter.Outbox, webhooks *WebhookPublisher, config PayoutWorkerConfig) *PayoutWorker {
+	if config.Interval <= 0 {
+		config.Interval = 30 * time.Second
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 20
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 5
+	}
+	if config.RetryBackoff <= 0 {
+		config.RetryBackoff = time.Minute
+	}
+	if config.Lease <= 0 {
+		config.Lease = 5 * time.Minute
+	}
+
+	return &PayoutWorker{
+		db:         db,
+		payService: payService,
+		outbox:     outbox,
+		webhooks:   webhooks,
+		config:     config,
+		logger:     logx.WithContext(context.Background()),
+		stop:       make(chan struct{}),
+	}
+}
+
+// Start 启动Worker，阻塞直到 Stop 被调用
+func (w *PayoutWorker) Start() {
+	w.logger.Info("PayoutWorker started")
+
+	ticker := time.NewTicker(w.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		w.RunOnce()
+
+		select {
+		case <-w.stop:
+			w.logger.Info("PayoutWorker stopping...")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stop 停止Worker
+func (w *PayoutWorker) Stop() {
+	close(w.stop)
+}
+
+// RunOnce 处理一批到期的提现，返回本轮处理的数量
+func (w *PayoutWorker) RunOnce() int {
+	now := time.Now()
+
+	var ids []int64
+	if err := w.dueQuery(now).
+		Order("id ASC").
+		Limit(w.config.BatchSize).
+		Pluck("id", &ids).Error; err != nil {
+		w.logger.Errorf("Failed to query payout withdrawals: %v", err)
+		return 0
+	}
+
+	processed := 0
+	for _, id := range ids {
+		if w.claim(id, now) {
+			w.process(id)
+			processed++
+		}
+	}
+
+	return processed
+}
+
+// dueQuery 待打款的提现：已审核通过，或处理中且已到重试时间/租约过期；次数用尽仍在处理中的等待人工核对
+func (w *PayoutWorker) dueQuery(now time.Time) *gorm.DB {
+	return w.db.Model(&model.Withdrawal{}).
+		Where("pay_type = ?", "wechat").
+		Where("status = ? OR (status = ? AND payout_attempts < ? AND (next_payout_at IS NULL OR next_payout_at <= ?))",
+			"approved", "processing", w.config.MaxAttempts, now)
+}
+
+// claim 以条件更新抢占提现并设置处理租约
+func (w *PayoutWorker) claim(id int64, now time.Time) bool {
+	result := w.dueQuery(now).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":         "processing",
+		"next_payout_at": now.Add(w.config.Lease),
+	})
+	if result.Error != nil {
+		w.logger.Errorf("Failed to claim withdrawal: id=%d, err=%v", id, result.Error)
+		return false
+	}
+	return result.RowsAffected == 1
+}
+
+func (w *PayoutWorker) process(id int64) {
+	var withdrawal model.Withdrawal
+	if err := w.db.First(&withdrawal, id).Error; err != nil {
+		w.logger.Errorf("Failed to load withdrawal: id=%d, err=%v", id, err)
+		return
+	}
+
+	attempts := withdrawal.PayoutAttempts + 1
+	if withdrawal.PayAccount == "" {
+		w.fail(&withdrawal, attempts, "收款openid为空")
+		return
+	}
+
+	tradeNo := PayoutTradeNo(withdrawal.ID)
+	result, err := w.payService.Transfer(&wechatpay.TransferRequest{
+		PartnerTradeNo: tradeNo,
+		OpenID:         withdrawal.PayAccount,
+		Amount:         int64(math.Round(withdrawal.Amount * 100)),
+		Desc:           "提现到账",
+		ReUserName:     withdrawal.PayRealName,
+	})
+	if err == nil && result.Success {
+		w.complete(&withdrawal, attempts, result)
+		return
+	}
+	if err == nil && !result.Retryable() {
+		w.fail(&withdrawal, attempts, fmt.Sprintf("%s %s", result.ErrCode, result.ErrCodeDes))
+		return
+	}
+
+	// 通信失败或系统繁忙时付款可能已经到账，先查询付款单再决定重试、退回或转人工
+	reason := ""
+	if err != nil {
+		reason = err.Error()
+	} else {
+		reason = fmt.Sprintf("%s %s", result.ErrCode, result.ErrCodeDes)
+	}
+
+	query, err := w.payService.QueryTransfer(tradeNo)
+	switch {
+	case err != nil:
+		w.unknown(&withdrawal, attempts, fmt.Sprintf("%s；查询付款结果失败: %v", reason, err))
+	case query.Found && query.Status == wechatpay.TransferStatusSuccess:
+		w.complete(&withdrawal, attempts, &wechatpay.TransferResult{
+			Success:        true,
+			PartnerTradeNo: tradeNo,
+			PaymentNo:      query.PaymentNo,
+			PaymentTime:    query.PaymentTime,
+		})
+	case query.Found && query.Status == wechatpay.TransferStatusFailed:
+		w.fail(&withdrawal, attempts, fmt.Sprintf("%s；付款失败: %s", reason, query.Reason))
+	case !query.Found:
+		// 微信侧不存在该付款单，付款未发起，可用同一单号重试
+		if attempts < w.config.MaxAttempts {
+			w.retry(&withdrawal, attempts, reason)
+			return
+		}
+		w.fail(&withdrawal, attempts, reason)
+	default:
+		w.unknown(&withdrawal, attempts, fmt.Sprintf("%s；付款处理中", reason))
+	}
+}
+
+// retry 记录本次结果并按退避时间安排下一次打款，商户付款单号不变
+func (w *PayoutWorker) retry(withdrawal *model.Withdrawal, attempts int, reason string) {
+	next := time.Now().Add(time.Duration(attempts) * w.config.RetryBackoff)
+	if err := w.db.Model(&model.Withdrawal{}).Where("id = ? AND status = ?", withdrawal.ID, "processing").
+		Updates(map[string]interface{}{
+			"payout_attempts": attempts,
+			"payout_error":    truncatePayoutError(reason),
+			"next_payout_at":  next,
+		}).Error; err != nil {
+		w.logger.Errorf("Failed to schedule payout retry: id=%d, err=%v", withdrawal.ID, err)
+		return
+	}
+	w.logger.Infof("Payout will retry: id=%d, attempts=%d, reason=%s", withdrawal.ID, attempts, reason)
+}
+
+// unknown 付款结果未知：次数未用尽时稍后重试（同一单号幂等），用尽后保持处理中等待人工核对，不解冻余额
+func (w *PayoutWorker) unknown(withdrawal *model.Withdrawal, attempts int, reason string) {
+	if attempts < w.config.MaxAttempts {
+		w.retry(withdrawal, attempts, reason)
+		return
+	}
+
+	if err := w.db.Model(&model.Withdrawal{}).Where("id = ? AND status = ?", withdrawal.ID, "processing").
+		Updates(map[string]interface{}{
+			"payout_attempts": attempts,
+			"payout_error":    truncatePayoutError("付款结果未知，需人工核对: " + reason),
+			"next_payout_at":  nil,
+		}).Error; err != nil {
+		w.logger.Errorf("Failed to mark payout for review: id=%d, err=%v", withdrawal.ID, err)
+		return
+	}
+	w.logger.Errorf("Payout outcome unknown, manual review required: id=%d, attempts=%d, reason=%s", withdrawal.ID, attempts, reason)
+}
+
+// complete 打款成功，记录微信付款单号并写入打款流水
+func (w *PayoutWorker) complete(withdrawal *model.Withdrawal, attempts int, result *wechatpay.TransferResult) {
+	paidAt := time.Now()
+	if t, err := time.ParseInLocation("2006-01-02 15:04:05", result.PaymentTime, time.Local); err == nil {
+		paidAt = t
+	}
+
+	err := w.db.Transaction(func(tx *gorm.DB) error {
+		updated := tx.Model(&model.Withdrawal{}).Where("id = ? AND status = ?", withdrawal.ID, "processing").
+			Updates(map[string]interface{}{
+				"status":          "completed",
+				"paid_at":         paidAt,
+				"trade_no":        result.PaymentNo,
+				"payout_attempts": attempts,
+				"payout_error":    "",
+				"next_payout_at":  nil,
+			})
+		if updated.Error != nil {
+			return updated.Error
+		}
+		if updated.RowsAffected == 0 {
+			return fmt.Errorf("withdrawal %d is no longer processing", withdrawal.ID)
+		}
+
+		_, err := NewBalanceService(tx).Apply(BalanceChange{
+			UserId:         withdrawal.UserID,
+			Type:           BalanceTxPayout,
+			Account:        BalanceAccountWithdrawalHold,
+			CounterAccount: BalanceAccountPayout,
+			Amount:         -withdrawal.Amount,
+			RefType:        "withdrawal",
+			RefId:          withdrawal.ID,
+			Remark:         fmt.Sprintf("微信付款单号%s", result.PaymentNo),
+		})
+		if err != nil {
+			return err
+		}
+		withdrawal.Status, withdrawal.PaidAt, withdrawal.TradeNo = "completed", &paidAt, result.PaymentNo
+		if err := w.webhooks.PublishWithdrawal(tx, withdrawal); err != nil {
+			return err
+		}
+		return w.outbox.Add(tx, syncadapter.OutboxEventWithdrawalChanged, withdrawal.ID)
+	})
+	if err != nil {
+		w.logger.Errorf("Failed to complete withdrawal: id=%d, err=%v", withdrawal.ID, err)
+		return
+	}
+
+	w.logger.Infof("Payout completed: id=%d, paymentNo=%s", withdrawal.ID, result.PaymentNo)
+}
+
+// fail 确认未付款（业务失败、付款单失败或不存在），解冻提现金额退回用户余额
+func (w *PayoutWorker) fail(withdrawal *model.Withdrawal, attempts int, reason string) {
+	err := w.db.Transaction(func(tx *gorm.DB) error {
+		updated := tx.Model(&model.Withdrawal{}).Where("id = ? AND status = ?", withdrawal.ID, "processing").
+			Updates(map[string]interface{}{
+				"status":          "failed",
+				"payout_attempts": attempts,
+				"payout_error":    truncatePayoutError(reason),
+				"next_payout_at":  nil,
+			})
+		if updated.Error != nil {
+			return updated.Error
+		}
+		if updated.RowsAffected == 0 {
+			return fmt.Errorf("withdrawal %d is no longer processing", withdrawal.ID)
+		}
+
+		_, err := NewBalanceService(tx).Apply(BalanceChange{
+			UserId:         withdrawal.UserID,
+			Type:           BalanceTxWithdrawalRelease,
+			CounterAccount: BalanceAccountWithdrawalHold,
+			Amount:         withdrawal.Amount,
+			RefType:        "withdrawal",
+			RefId:          withdrawal.ID,
+			Remark:         "打款失败退回",
+		})
+		if err != nil {
+			return err
+		}
+		withdrawal.Status = "failed"
+		if err := w.webhooks.PublishWithdrawal(tx, withdrawal); err != nil {
+			return err
+		}
+		return w.outbox.Add(tx, syncadapter.OutboxEventWithdrawalChanged, withdrawal.ID)
+	})
+	if err != nil {
+		w.logger.Errorf("Failed to mark withdrawal failed: id=%d, err=%v", withdrawal.ID, err)
+		return
+	}
+
+	w.logger.Errorf("Payout failed: id=%d, attempts=%d, reason=%s", withdrawal.ID, attempts, reason)
+}
+
+// PayoutTradeNo 提现对应的商户付款单号，重试时保持不变以保证微信侧幂等
+func PayoutTradeNo(withdrawalId int64) string {
+	return fmt.Sprintf("WD%010d", withdrawalId)
+}
+
+func truncatePayoutError(reason string) string {
+	runes := []rune(reason)
+	if len(runes) > 500 {
+		return string(runes[:500])
+	}
+	return reason
+}
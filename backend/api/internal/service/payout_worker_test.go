@@ -0,0 +1,290 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"dmh/common/syncadapter"
+	"dmh/common/wechatpay"
+	"dmh/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+type PayoutWorkerTestSuite struct {
+	suite.Suite
+	db *gorm.DB
+}
+
+func (suite *PayoutWorkerTestSuite) SetupSuite() {
+	db, err := gorm.Open(mysql.Open("root:Admin168@tcp(127.0.0.1:3306)/dmh_test?charset=utf8mb4&parseTime=true&loc=Local"), &gorm.Config{})
+	suite.Require().NoError(err)
+
+	err = db.AutoMigrate(
+		&model.Withdrawal{},
+		&model.UserBalance{},
+		&model.BalanceTransaction{},
+		&model.OutboxEvent{},
+		&model.WebhookEndpoint{},
+		&model.WebhookDelivery{},
+	)
+	suite.Require().NoError(err)
+
+	suite.db = db
+}
+
+func (suite *PayoutWorkerTestSuite) TearDownSuite() {
+	sqlDB, _ := suite.db.DB()
+	sqlDB.Close()
+}
+
+func (suite *PayoutWorkerTestSuite) SetupTest() {
+	suite.Require().NoError(suite.db.Exec("TRUNCATE TABLE withdrawals").Error)
+	suite.Require().NoError(suite.db.Exec("TRUNCATE TABLE balance_transactions").Error)
+	suite.Require().NoError(suite.db.Exec("TRUNCATE TABLE user_balances").Error)
+	suite.Require().NoError(suite.db.Exec("TRUNCATE TABLE outbox_events").Error)
+	suite.Require().NoError(suite.db.Exec("TRUNCATE TABLE webhook_endpoints").Error)
+	suite.Require().NoError(suite.db.Exec("TRUNCATE TABLE webhook_deliveries").Error)
+}
+
+// newStandInWorker 使用本地模拟网关创建Worker，gateway 按请求路径返回企业付款或查询响应XML，返回空串时响应 HTTP 500
+func (suite *PayoutWorkerTestSuite) newStandInWorker(gateway func(path string) string, maxAttempts int) (*PayoutWorker, *httptest.Server) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := gateway(r.URL.Path)
+		if body == "" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+
+	certFile, keyFile := writeTestClientCert(suite.T())
+	payService := wechatpay.NewService(&wechatpay.Config{
+		AppID:         "wx-app",
+		MchID:         "mch-1",
+		APIKey:        "key123",
+		APIClientCert: certFile,
+		APIClientKey:  keyFile,
+		TransferURL:   server.URL + "/mmpaymkttransfers/promotion/transfers",
+	})
+
+	return NewPayoutWorker(suite.db, payService, syncadapter.NewOutbox(), NewWebhookPublisher(), PayoutWorkerConfig{
+		MaxAttempts:  maxAttempts,
+		RetryBackoff: time.Millisecond,
+	}), server
+}
+
+func (suite *PayoutWorkerTestSuite) createApprovedWithdrawal(userId int64, amount float64) *model.Withdrawal {
+	suite.Require().NoError(suite.db.Create(&model.UserBalance{UserId: userId, Balance: 0}).Error)
+
+	withdrawal := &model.Withdrawal{
+		UserID:      userId,
+		Amount:      amount,
+		Status:      "approved",
+		PayType:     "wechat",
+		PayAccount:  "openid-1",
+		PayRealName: "张三",
+	}
+	suite.Require().NoError(suite.db.Create(withdrawal).Error)
+	return withdrawal
+}
+
+func (suite *PayoutWorkerTestSuite) TestPayoutCompleted() {
+	withdrawal := suite.createApprovedWithdrawal(1, 88.8)
+	suite.Require().NoError(suite.db.Create(&model.WebhookEndpoint{
+		BrandId: withdrawal.BrandId, Url: "https://example.com/hook", Secret: "whsec_test", Events: WebhookEventWithdrawalStatusChanged, Status: "active",
+	}).Error)
+	worker, server := suite.newStandInWorker(func(string) string {
+		return "<xml><return_code>SUCCESS</return_code><result_code>SUCCESS</result_code>" +
+			"<payment_no>pay-1</payment_no><payment_time>2026-10-18 10:00:00</payment_time></xml>"
+	}, 3)
+	defer server.Close()
+
+	assert.Equal(suite.T(), 1, worker.RunOnce())
+
+	var updated model.Withdrawal
+	suite.Require().NoError(suite.db.First(&updated, withdrawal.ID).Error)
+	assert.Equal(suite.T(), "completed", updated.Status)
+	assert.Equal(suite.T(), "pay-1", updated.TradeNo)
+	assert.NotNil(suite.T(), updated.PaidAt)
+	assert.Equal(suite.T(), 1, updated.PayoutAttempts)
+
+	var ledger model.BalanceTransaction
+	suite.Require().NoError(suite.db.Where("ref_type = ? AND ref_id = ?", "withdrawal", withdrawal.ID).First(&ledger).Error)
+	assert.Equal(suite.T(), BalanceTxPayout, ledger.Type)
+	assert.Equal(suite.T(), -88.8, ledger.Amount)
+
+	var event model.OutboxEvent
+	suite.Require().NoError(suite.db.Where("aggregate_type = ? AND aggregate_id = ?", syncadapter.SyncTypeWithdrawal, withdrawal.ID).First(&event).Error)
+	assert.Equal(suite.T(), syncadapter.OutboxEventWithdrawalChanged, event.EventType)
+	assert.Equal(suite.T(), syncadapter.OutboxStatusPending, event.Status)
+
+	var delivery model.WebhookDelivery
+	suite.Require().NoError(suite.db.Where("event_type = ?", WebhookEventWithdrawalStatusChanged).First(&delivery).Error)
+	assert.Contains(suite.T(), delivery.Payload, `"status":"completed"`)
+	assert.Contains(suite.T(), delivery.Payload, `"tradeNo":"pay-1"`)
+
+	assert.Equal(suite.T(), 0, worker.RunOnce())
+}
+
+func (suite *PayoutWorkerTestSuite) TestPayoutFailedReleasesBalance() {
+	withdrawal := suite.createApprovedWithdrawal(2, 50)
+	worker, server := suite.newStandInWorker(func(string) string {
+		return "<xml><return_code>SUCCESS</return_code><result_code>FAIL</result_code>" +
+			"<err_code>NAME_MISMATCH</err_code><err_code_des>姓名校验出错</err_code_des></xml>"
+	}, 3)
+	defer server.Close()
+
+	worker.RunOnce()
+
+	var updated model.Withdrawal
+	suite.Require().NoError(suite.db.First(&updated, withdrawal.ID).Error)
+	assert.Equal(suite.T(), "failed", updated.Status)
+	assert.Contains(suite.T(), updated.PayoutError, "NAME_MISMATCH")
+
+	var balance model.UserBalance
+	suite.Require().NoError(suite.db.Where("user_id = ?", 2).First(&balance).Error)
+	assert.Equal(suite.T(), 50.0, balance.Balance)
+}
+
+func (suite *PayoutWorkerTestSuite) TestPayoutRetriesThenFails() {
+	withdrawal := suite.createApprovedWithdrawal(3, 20)
+	calls := 0
+	worker, server := suite.newStandInWorker(func(path string) string {
+		if strings.HasSuffix(path, "/gettransferinfo") {
+			return "<xml><return_code>SUCCESS</return_code><result_code>FAIL</result_code><err_code>NOT_FOUND</err_code></xml>"
+		}
+		calls++
+		return "<xml><return_code>SUCCESS</return_code><result_code>FAIL</result_code>" +
+			"<err_code>SYSTEMERROR</err_code><err_code_des>系统繁忙</err_code_des></xml>"
+	}, 2)
+	defer server.Close()
+
+	worker.RunOnce()
+
+	var updated model.Withdrawal
+	suite.Require().NoError(suite.db.First(&updated, withdrawal.ID).Error)
+	assert.Equal(suite.T(), "processing", updated.Status)
+	assert.Equal(suite.T(), 1, updated.PayoutAttempts)
+
+	time.Sleep(5 * time.Millisecond)
+	worker.RunOnce()
+
+	suite.Require().NoError(suite.db.First(&updated, withdrawal.ID).Error)
+	assert.Equal(suite.T(), "failed", updated.Status)
+	assert.Equal(suite.T(), 2, updated.PayoutAttempts)
+	assert.Equal(suite.T(), 2, calls)
+
+	var balance model.UserBalance
+	suite.Require().NoError(suite.db.Where("user_id = ?", 3).First(&balance).Error)
+	assert.Equal(suite.T(), 20.0, balance.Balance)
+}
+
+func (suite *PayoutWorkerTestSuite) TestNonWechatWithdrawalIgnored() {
+	suite.Require().NoError(suite.db.Create(&model.Withdrawal{UserID: 4, Amount: 10, Status: "approved", PayType: "bank"}).Error)
+	worker, server := suite.newStandInWorker(func(string) string { return "" }, 3)
+	defer server.Close()
+
+	assert.Equal(suite.T(), 0, worker.RunOnce())
+}
+
+func (suite *PayoutWorkerTestSuite) TestPayoutTransportErrorButPaid() {
+	withdrawal := suite.createApprovedWithdrawal(5, 30)
+	worker, server := suite.newStandInWorker(func(path string) string {
+		if strings.HasSuffix(path, "/gettransferinfo") {
+			return "<xml><return_code>SUCCESS</return_code><result_code>SUCCESS</result_code><status>SUCCESS</status>" +
+				"<detail_id>pay-5</detail_id><payment_time>2026-10-18 10:00:00</payment_time></xml>"
+		}
+		return ""
+	}, 3)
+	defer server.Close()
+
+	worker.RunOnce()
+
+	var updated model.Withdrawal
+	suite.Require().NoError(suite.db.First(&updated, withdrawal.ID).Error)
+	assert.Equal(suite.T(), "completed", updated.Status)
+	assert.Equal(suite.T(), "pay-5", updated.TradeNo)
+
+	var balance model.UserBalance
+	suite.Require().NoError(suite.db.Where("user_id = ?", 5).First(&balance).Error)
+	assert.Equal(suite.T(), 0.0, balance.Balance)
+}
+
+func (suite *PayoutWorkerTestSuite) TestPayoutUnknownOutcomeKeptForReview() {
+	withdrawal := suite.createApprovedWithdrawal(6, 40)
+	worker, server := suite.newStandInWorker(func(path string) string {
+		if strings.HasSuffix(path, "/gettransferinfo") {
+			return "<xml><return_code>SUCCESS</return_code><result_code>SUCCESS</result_code><status>PROCESSING</status></xml>"
+		}
+		return ""
+	}, 2)
+	defer server.Close()
+
+	worker.RunOnce()
+	time.Sleep(5 * time.Millisecond)
+	worker.RunOnce()
+
+	var updated model.Withdrawal
+	suite.Require().NoError(suite.db.First(&updated, withdrawal.ID).Error)
+	assert.Equal(suite.T(), "processing", updated.Status)
+	assert.Equal(suite.T(), 2, updated.PayoutAttempts)
+	assert.Contains(suite.T(), updated.PayoutError, "人工核对")
+	assert.Nil(suite.T(), updated.NextPayoutAt)
+
+	// 结果未知时不解冻余额，也不再自动重试
+	var balance model.UserBalance
+	suite.Require().NoError(suite.db.Where("user_id = ?", 6).First(&balance).Error)
+	assert.Equal(suite.T(), 0.0, balance.Balance)
+	assert.Equal(suite.T(), 0, worker.RunOnce())
+}
+
+func TestPayoutWorkerTestSuite(t *testing.T) {
+	suite.Run(t, new(PayoutWorkerTestSuite))
+}
+
+func TestPayoutTradeNo(t *testing.T) {
+	assert.Equal(t, "WD0000000042", PayoutTradeNo(42))
+}
+
+// writeTestClientCert 生成自签名商户API证书，模拟网关为 HTTP 时证书不参与握手
+func writeTestClientCert(t *testing.T) (string, string) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "mch-1"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create cert: %v", err)
+	}
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "apiclient_cert.pem"), filepath.Join(dir, "apiclient_key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}), 0600); err != nil {
+		t.Fatalf("write cert: %v", err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}), 0600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+	return certFile, keyFile
+}
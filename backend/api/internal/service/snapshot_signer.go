@@ -0,0 +1,47 @@
+package service
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// SnapshotSigner 用 Ed25519 私钥签名离线核销快照，设备只持有公钥，无法据此伪造核销码或快照
+type SnapshotSigner struct {
+	keyId      string
+	privateKey ed25519.PrivateKey
+}
+
+// NewSnapshotSigner 创建快照签名器，privateKey 为 base64 编码的 32 字节种子或 64 字节私钥
+func NewSnapshotSigner(keyId, privateKey string) (*SnapshotSigner, error) {
+	if keyId == "" {
+		return nil, fmt.Errorf("快照签名密钥ID未设置")
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(privateKey))
+	if err != nil {
+		return nil, fmt.Errorf("快照签名私钥不是有效的 base64: %w", err)
+	}
+
+	var key ed25519.PrivateKey
+	switch len(raw) {
+	case ed25519.SeedSize:
+		key = ed25519.NewKeyFromSeed(raw)
+	case ed25519.PrivateKeySize:
+		key = ed25519.PrivateKey(raw)
+	default:
+		return nil, fmt.Errorf("快照签名私钥长度无效: %d", len(raw))
+	}
+
+	return &SnapshotSigner{keyId: keyId, privateKey: key}, nil
+}
+
+// Sign 签名快照数据，返回密钥ID与 base64 编码的 Ed25519 签名
+func (s *SnapshotSigner) Sign(payload []byte) (keyId string, signature string) {
+	return s.keyId, base64.StdEncoding.EncodeToString(ed25519.Sign(s.privateKey, payload))
+}
+
+// PublicKey 返回 base64 编码的公钥，随设备配置下发用于校验快照
+func (s *SnapshotSigner) PublicKey() string {
+	return base64.StdEncoding.EncodeToString(s.privateKey.Public().(ed25519.PublicKey))
+}
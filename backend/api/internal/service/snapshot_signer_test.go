@@ -0,0 +1,36 @@
+package service
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotSigner_SignVerifiesWithPublicKey(t *testing.T) {
+	seed := base64.StdEncoding.EncodeToString(make([]byte, ed25519.SeedSize))
+	signer, err := NewSnapshotSigner("s1", seed)
+	require.NoError(t, err)
+
+	payload := []byte(`{"campaignId":1}`)
+	keyId, signature := signer.Sign(payload)
+	assert.Equal(t, "s1", keyId)
+
+	publicKey, err := base64.StdEncoding.DecodeString(signer.PublicKey())
+	require.NoError(t, err)
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	require.NoError(t, err)
+	assert.True(t, ed25519.Verify(publicKey, payload, sig))
+	assert.False(t, ed25519.Verify(publicKey, []byte(`{"campaignId":2}`), sig))
+}
+
+func TestNewSnapshotSigner_InvalidKey(t *testing.T) {
+	_, err := NewSnapshotSigner("", base64.StdEncoding.EncodeToString(make([]byte, ed25519.SeedSize)))
+	assert.Error(t, err)
+	_, err = NewSnapshotSigner("s1", "not-base64!")
+	assert.Error(t, err)
+	_, err = NewSnapshotSigner("s1", base64.StdEncoding.EncodeToString([]byte("short")))
+	assert.Error(t, err)
+}
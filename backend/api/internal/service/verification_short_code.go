@@ -0,0 +1,48 @@
+package service
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// shortCodeAlphabet 去掉易混淆的 0/O、1/I 后的 32 个字符
+const shortCodeAlphabet = "23456789ABCDEFGHJKLMNPQRSTUVWXYZ"
+
+const (
+	MinShortCodeLength = 6
+	MaxShortCodeLength = 8
+)
+
+// GenerateShortCode 生成随机核销短码，唯一性由调用方在活动内校验
+func GenerateShortCode(length int) (string, error) {
+	if length < MinShortCodeLength || length > MaxShortCodeLength {
+		return "", fmt.Errorf("核销短码长度须在 %d-%d 之间: %d", MinShortCodeLength, MaxShortCodeLength, length)
+	}
+
+	max := big.NewInt(int64(len(shortCodeAlphabet)))
+	code := make([]byte, length)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = shortCodeAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
+
+// NormalizeShortCode 规整手动输入的短码（忽略大小写、空格与连字符），不是短码时返回 false
+func NormalizeShortCode(input string) (string, bool) {
+	code := strings.ToUpper(strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(input)))
+	if len(code) < MinShortCodeLength || len(code) > MaxShortCodeLength {
+		return "", false
+	}
+	for i := 0; i < len(code); i++ {
+		if strings.IndexByte(shortCodeAlphabet, code[i]) < 0 {
+			return "", false
+		}
+	}
+	return code, true
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateShortCode(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 200; i++ {
+		code, err := GenerateShortCode(6)
+		require.NoError(t, err)
+		assert.Len(t, code, 6)
+
+		normalized, ok := NormalizeShortCode(code)
+		assert.True(t, ok)
+		assert.Equal(t, code, normalized)
+		assert.NotContains(t, code, "0")
+		assert.NotContains(t, code, "O")
+		seen[code] = true
+	}
+	assert.Greater(t, len(seen), 190)
+
+	code, err := GenerateShortCode(8)
+	require.NoError(t, err)
+	assert.Len(t, code, 8)
+
+	_, err = GenerateShortCode(5)
+	assert.Error(t, err)
+	_, err = GenerateShortCode(9)
+	assert.Error(t, err)
+}
+
+func TestNormalizeShortCode(t *testing.T) {
+	code, ok := NormalizeShortCode(" ab3-x9k ")
+	assert.True(t, ok)
+	assert.Equal(t, "AB3X9K", code)
+
+	for _, input := range []string{
+		"",
+		"AB3X9",           // 太短
+		"AB3X9KAB3",       // 太长
+		"AB0X9K",          // 含易混淆字符
+		"v2.k1.abc.def.g", // 签名核销码
+		"1_13800138000_1700000000_abc",
+	} {
+		_, ok := NormalizeShortCode(input)
+		assert.False(t, ok, input)
+	}
+}
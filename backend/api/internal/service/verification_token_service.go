@@ -0,0 +1,216 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 核销码格式：v2.<密钥ID>.<订单引用>.<过期时间>.<签名>
+// 订单引用为订单ID与密钥派生掩码异或后的 base64url，不含手机号，也不暴露连续的订单ID；
+// 过期时间为 36 进制 Unix 秒；签名为 HMAC-SHA256(密钥, 前四段) 前 16 字节的 base64url。
+const verificationTokenVersion = "v2"
+
+var (
+	ErrVerificationTokenInvalid = errors.New("核销码无效")
+	ErrVerificationTokenExpired = errors.New("核销码已过期")
+)
+
+// VerificationKey 核销码签名密钥
+type VerificationKey struct {
+	Id     string
+	Secret string
+}
+
+// VerificationTokenConfig 核销码签名配置
+type VerificationTokenConfig struct {
+	ActiveKeyId  string            // 签发新核销码使用的密钥
+	Keys         []VerificationKey // 仍可校验的密钥，轮换时保留旧密钥直到旧码过期
+	TTL          time.Duration     // 活动结束后核销码的有效时长
+	LegacySecret string            // 旧格式（订单ID_手机号_时间戳_MD5）的签名密钥
+	LegacyUntil  time.Time         // 旧格式核销码的截止时间，为零值时不再接受旧格式
+}
+
+// VerificationToken 解析后的核销码
+type VerificationToken struct {
+	OrderId   int64
+	KeyId     string
+	ExpiresAt time.Time // 旧格式核销码为零值
+	Legacy    bool
+}
+
+// VerificationTokenService 签发与校验订单核销码
+type VerificationTokenService struct {
+	config VerificationTokenConfig
+	keys   map[string][]byte
+	now    func() time.Time
+}
+
+// NewVerificationTokenService 创建核销码服务，签发密钥必须存在且不为空
+func NewVerificationTokenService(config VerificationTokenConfig) (*VerificationTokenService, error) {
+	keys := make(map[string][]byte, len(config.Keys))
+	for _, key := range config.Keys {
+		if key.Id == "" || strings.ContainsAny(key.Id, "._") {
+			return nil, fmt.Errorf("核销码密钥ID无效: %q", key.Id)
+		}
+		if key.Secret == "" {
+			return nil, fmt.Errorf("核销码密钥 %s 未设置", key.Id)
+		}
+		if _, exists := keys[key.Id]; exists {
+			return nil, fmt.Errorf("核销码密钥ID重复: %s", key.Id)
+		}
+		keys[key.Id] = []byte(key.Secret)
+	}
+	if _, ok := keys[config.ActiveKeyId]; !ok {
+		return nil, fmt.Errorf("核销码签发密钥 %q 未配置", config.ActiveKeyId)
+	}
+	if config.TTL <= 0 {
+		config.TTL = 7 * 24 * time.Hour
+	}
+
+	return &VerificationTokenService{
+		config: config,
+		keys:   keys,
+		now:    time.Now,
+	}, nil
+}
+
+// Issue 签发核销码，有效期至 validUntil（通常为活动结束时间）之后 TTL
+func (s *VerificationTokenService) Issue(orderId int64, validUntil time.Time) (string, error) {
+	if orderId <= 0 {
+		return "", fmt.Errorf("订单ID无效: %d", orderId)
+	}
+
+	now := s.now()
+	if validUntil.Before(now) {
+		validUntil = now
+	}
+	expiresAt := validUntil.Add(s.config.TTL)
+
+	keyId := s.config.ActiveKeyId
+	key := s.keys[keyId]
+	payload := strings.Join([]string{
+		verificationTokenVersion,
+		keyId,
+		encodeOrderRef(key, orderId),
+		strconv.FormatInt(expiresAt.Unix(), 36),
+	}, ".")
+
+	return payload + "." + signVerificationPayload(key, payload), nil
+}
+
+// Parse 校验核销码签名与有效期，返回其中的订单ID
+func (s *VerificationTokenService) Parse(code string) (*VerificationToken, error) {
+	return s.ParseAt(code, s.now())
+}
+
+// ParseAt 按指定时间校验有效期，用于离线核销按设备核销时间判断
+func (s *VerificationTokenService) ParseAt(code string, at time.Time) (*VerificationToken, error) {
+	if strings.HasPrefix(code, verificationTokenVersion+".") {
+		return s.parseToken(code, at)
+	}
+	return s.parseLegacy(code, at)
+}
+
+func (s *VerificationTokenService) parseToken(code string, at time.Time) (*VerificationToken, error) {
+	parts := strings.Split(code, ".")
+	if len(parts) != 5 {
+		return nil, ErrVerificationTokenInvalid
+	}
+
+	key, ok := s.keys[parts[1]]
+	if !ok {
+		return nil, ErrVerificationTokenInvalid
+	}
+
+	payload := strings.Join(parts[:4], ".")
+	if !hmac.Equal([]byte(parts[4]), []byte(signVerificationPayload(key, payload))) {
+		return nil, ErrVerificationTokenInvalid
+	}
+
+	orderId, err := decodeOrderRef(key, parts[2])
+	if err != nil {
+		return nil, ErrVerificationTokenInvalid
+	}
+	expiresAtUnix, err := strconv.ParseInt(parts[3], 36, 64)
+	if err != nil {
+		return nil, ErrVerificationTokenInvalid
+	}
+
+	token := &VerificationToken{
+		OrderId:   orderId,
+		KeyId:     parts[1],
+		ExpiresAt: time.Unix(expiresAtUnix, 0),
+	}
+	if !at.Before(token.ExpiresAt) {
+		return token, ErrVerificationTokenExpired
+	}
+	return token, nil
+}
+
+// parseLegacy 校验迁移前签发的 MD5 核销码，仅在迁移窗口内有效
+func (s *VerificationTokenService) parseLegacy(code string, at time.Time) (*VerificationToken, error) {
+	parts := strings.Split(code, "_")
+	if len(parts) != 4 {
+		return nil, ErrVerificationTokenInvalid
+	}
+
+	orderId, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || orderId <= 0 {
+		return nil, ErrVerificationTokenInvalid
+	}
+	if s.config.LegacySecret == "" {
+		return nil, ErrVerificationTokenInvalid
+	}
+
+	signatureData := fmt.Sprintf("%s_%s_%s_%s", parts[0], parts[1], parts[2], s.config.LegacySecret)
+	hash := md5.Sum([]byte(signatureData))
+	if !hmac.Equal([]byte(parts[3]), []byte(hex.EncodeToString(hash[:]))) {
+		return nil, ErrVerificationTokenInvalid
+	}
+
+	token := &VerificationToken{OrderId: orderId, Legacy: true}
+	if s.config.LegacyUntil.IsZero() || !at.Before(s.config.LegacyUntil) {
+		return token, ErrVerificationTokenExpired
+	}
+	return token, nil
+}
+
+func signVerificationPayload(key []byte, payload string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// orderRefMask 由密钥派生订单ID掩码
+func orderRefMask(key []byte) uint64 {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("order-ref"))
+	return binary.BigEndian.Uint64(mac.Sum(nil)[:8])
+}
+
+func encodeOrderRef(key []byte, orderId int64) string {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, uint64(orderId)^orderRefMask(key))
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+func decodeOrderRef(key []byte, ref string) (int64, error) {
+	buf, err := base64.RawURLEncoding.DecodeString(ref)
+	if err != nil || len(buf) != 8 {
+		return 0, ErrVerificationTokenInvalid
+	}
+	orderId := int64(binary.BigEndian.Uint64(buf) ^ orderRefMask(key))
+	if orderId <= 0 {
+		return 0, ErrVerificationTokenInvalid
+	}
+	return orderId, nil
+}
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestVerificationTokens(t *testing.T, activeKeyId string, keys ...VerificationKey) *VerificationTokenService {
+	tokens, err := NewVerificationTokenService(VerificationTokenConfig{
+		ActiveKeyId:  activeKeyId,
+		Keys:         keys,
+		TTL:          time.Hour,
+		LegacySecret: "legacy-secret",
+		LegacyUntil:  time.Now().Add(24 * time.Hour),
+	})
+	require.NoError(t, err)
+	return tokens
+}
+
+func legacyVerificationCode(orderId int64, phone string, secret string) string {
+	ts := time.Now().Unix()
+	hash := md5.Sum([]byte(fmt.Sprintf("%d_%s_%d_%s", orderId, phone, ts, secret)))
+	return fmt.Sprintf("%d_%s_%d_%s", orderId, phone, ts, hex.EncodeToString(hash[:]))
+}
+
+func TestVerificationTokenService_IssueAndParse(t *testing.T) {
+	tokens := newTestVerificationTokens(t, "k1", VerificationKey{Id: "k1", Secret: "secret-1"})
+
+	code, err := tokens.Issue(12345, time.Now())
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(code, "v2.k1."))
+	assert.NotContains(t, code, "12345")
+	assert.LessOrEqual(t, len(code), 64)
+
+	token, err := tokens.Parse(code)
+	require.NoError(t, err)
+	assert.Equal(t, int64(12345), token.OrderId)
+	assert.Equal(t, "k1", token.KeyId)
+	assert.False(t, token.Legacy)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), token.ExpiresAt, 2*time.Second)
+}
+
+func TestVerificationTokenService_RejectsTampering(t *testing.T) {
+	tokens := newTestVerificationTokens(t, "k1", VerificationKey{Id: "k1", Secret: "secret-1"})
+	code, err := tokens.Issue(1, time.Now())
+	require.NoError(t, err)
+	parts := strings.Split(code, ".")
+
+	other, err := tokens.Issue(2, time.Now())
+	require.NoError(t, err)
+	otherParts := strings.Split(other, ".")
+
+	for _, tampered := range []string{
+		strings.Join([]string{parts[0], parts[1], otherParts[2], parts[3], parts[4]}, "."),
+		strings.Join([]string{parts[0], parts[1], parts[2], "zzzzzz", parts[4]}, "."),
+		strings.Join([]string{parts[0], "k9", parts[2], parts[3], parts[4]}, "."),
+		code + ".extra",
+		"",
+		"invalid_code",
+	} {
+		_, err := tokens.Parse(tampered)
+		assert.ErrorIs(t, err, ErrVerificationTokenInvalid, tampered)
+	}
+}
+
+func TestVerificationTokenService_Expiry(t *testing.T) {
+	tokens := newTestVerificationTokens(t, "k1", VerificationKey{Id: "k1", Secret: "secret-1"})
+
+	// 有效期从活动结束时间起算
+	code, err := tokens.Issue(1, time.Now().Add(48*time.Hour))
+	require.NoError(t, err)
+	tokens.now = func() time.Time { return time.Now().Add(48*time.Hour + 30*time.Minute) }
+	_, err = tokens.Parse(code)
+	assert.NoError(t, err)
+
+	tokens.now = func() time.Time { return time.Now().Add(50 * time.Hour) }
+	token, err := tokens.Parse(code)
+	assert.ErrorIs(t, err, ErrVerificationTokenExpired)
+	assert.Equal(t, int64(1), token.OrderId)
+}
+
+func TestVerificationTokenService_KeyRotation(t *testing.T) {
+	before := newTestVerificationTokens(t, "k1", VerificationKey{Id: "k1", Secret: "secret-1"})
+	oldCode, err := before.Issue(7, time.Now())
+	require.NoError(t, err)
+
+	rotated := newTestVerificationTokens(t, "k2",
+		VerificationKey{Id: "k1", Secret: "secret-1"},
+		VerificationKey{Id: "k2", Secret: "secret-2"},
+	)
+	newCode, err := rotated.Issue(7, time.Now())
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(newCode, "v2.k2."))
+
+	for _, code := range []string{oldCode, newCode} {
+		token, err := rotated.Parse(code)
+		require.NoError(t, err)
+		assert.Equal(t, int64(7), token.OrderId)
+	}
+
+	// 旧密钥下线后，旧码不再有效
+	retired := newTestVerificationTokens(t, "k2", VerificationKey{Id: "k2", Secret: "secret-2"})
+	_, err = retired.Parse(oldCode)
+	assert.ErrorIs(t, err, ErrVerificationTokenInvalid)
+}
+
+func TestVerificationTokenService_LegacyCodes(t *testing.T) {
+	tokens := newTestVerificationTokens(t, "k1", VerificationKey{Id: "k1", Secret: "secret-1"})
+
+	code := legacyVerificationCode(42, "13800138000", "legacy-secret")
+	token, err := tokens.Parse(code)
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), token.OrderId)
+	assert.True(t, token.Legacy)
+
+	_, err = tokens.Parse(legacyVerificationCode(42, "13800138000", "wrong-secret"))
+	assert.ErrorIs(t, err, ErrVerificationTokenInvalid)
+
+	// 迁移窗口结束后旧码过期
+	tokens.now = func() time.Time { return time.Now().Add(48 * time.Hour) }
+	_, err = tokens.Parse(code)
+	assert.ErrorIs(t, err, ErrVerificationTokenExpired)
+}
+
+func TestVerificationTokenService_ParseAt(t *testing.T) {
+	tokens := newTestVerificationTokens(t, "k1", VerificationKey{Id: "k1", Secret: "secret-1"})
+	code, err := tokens.Issue(3, time.Now())
+	require.NoError(t, err)
+
+	// 离线核销按设备核销时间判断，上传时已过期但核销时有效
+	tokens.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	_, err = tokens.Parse(code)
+	assert.ErrorIs(t, err, ErrVerificationTokenExpired)
+
+	token, err := tokens.ParseAt(code, time.Now().Add(30*time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), token.OrderId)
+}
+
+func TestNewVerificationTokenService_InvalidConfig(t *testing.T) {
+	cases := []VerificationTokenConfig{
+		{ActiveKeyId: "k1"},
+		{ActiveKeyId: "k1", Keys: []VerificationKey{{Id: "k1"}}},
+		{ActiveKeyId: "k.1", Keys: []VerificationKey{{Id: "k.1", Secret: "s"}}},
+		{ActiveKeyId: "k1", Keys: []VerificationKey{{Id: "k1", Secret: "a"}, {Id: "k1", Secret: "b"}}},
+		{ActiveKeyId: "k2", Keys: []VerificationKey{{Id: "k1", Secret: "a"}}},
+	}
+	for _, config := range cases {
+		_, err := NewVerificationTokenService(config)
+		assert.Error(t, err, "%+v", config)
+	}
+}
@@ -0,0 +1,293 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"dmh/model"
+
+	"gorm.io/gorm"
+)
+
+// Webhook 事件类型
+const (
+	WebhookEventOrderCreated            = "order.created"
+	WebhookEventOrderPaid               = "order.paid"
+	WebhookEventOrderVerified           = "order.verified"
+	WebhookEventRewardSettled           = "reward.settled"
+	WebhookEventWithdrawalStatusChanged = "withdrawal.status_changed"
+	WebhookEventDistributorApproved     = "distributor.approved"
+)
+
+// WebhookEventTypes 可订阅的事件类型
+var WebhookEventTypes = []string{
+	WebhookEventOrderCreated,
+	WebhookEventOrderPaid,
+	WebhookEventOrderVerified,
+	WebhookEventRewardSettled,
+	WebhookEventWithdrawalStatusChanged,
+	WebhookEventDistributorApproved,
+}
+
+// Webhook 投递状态
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryDelivered = "delivered"
+	WebhookDeliveryFailed    = "failed"
+)
+
+// Webhook 请求头
+const (
+	WebhookHeaderEvent     = "X-DMH-Event"
+	WebhookHeaderEventId   = "X-DMH-Event-Id"
+	WebhookHeaderDelivery  = "X-DMH-Delivery"
+	WebhookHeaderSignature = "X-DMH-Signature" // t=<时间戳>,v1=<HMAC-SHA256(secret, "<时间戳>.<请求体>") 十六进制>
+)
+
+// WebhookEvent 推送给集成方的 JSON
+type WebhookEvent struct {
+	Id        string      `json:"id"`
+	Type      string      `json:"type"`
+	BrandId   int64       `json:"brandId"`
+	CreatedAt string      `json:"createdAt"`
+	Data      interface{} `json:"data"`
+}
+
+// WebhookOrderData 订单事件数据
+type WebhookOrderData struct {
+	OrderId            int64   `json:"orderId"`
+	CampaignId         int64   `json:"campaignId"`
+	MemberId           *int64  `json:"memberId,omitempty"`
+	Phone              string  `json:"phone"`
+	Amount             float64 `json:"amount"`
+	Status             string  `json:"status"`
+	PayStatus          string  `json:"payStatus"`
+	VerificationStatus string  `json:"verificationStatus"`
+	RedemptionQuota    int     `json:"redemptionQuota"`
+	RedeemedCount      int     `json:"redeemedCount"`
+	PaidAt             string  `json:"paidAt,omitempty"`
+	VerifiedAt         string  `json:"verifiedAt,omitempty"`
+	CreatedAt          string  `json:"createdAt"`
+}
+
+// WebhookRewardData 分销奖励事件数据
+type WebhookRewardData struct {
+	RewardId      int64   `json:"rewardId"`
+	DistributorId int64   `json:"distributorId"`
+	UserId        int64   `json:"userId"`
+	OrderId       int64   `json:"orderId"`
+	CampaignId    int64   `json:"campaignId"`
+	Level         int     `json:"level"`
+	Amount        float64 `json:"amount"`
+	SettledAt     string  `json:"settledAt,omitempty"`
+}
+
+// WebhookWithdrawalData 提现事件数据
+type WebhookWithdrawalData struct {
+	WithdrawalId  int64   `json:"withdrawalId"`
+	UserId        int64   `json:"userId"`
+	DistributorId int64   `json:"distributorId"`
+	Amount        float64 `json:"amount"`
+	Status        string  `json:"status"`
+	PayType       string  `json:"payType"`
+	TradeNo       string  `json:"tradeNo,omitempty"`
+	PaidAt        string  `json:"paidAt,omitempty"`
+}
+
+// WebhookDistributorData 分销商事件数据
+type WebhookDistributorData struct {
+	DistributorId int64  `json:"distributorId"`
+	UserId        int64  `json:"userId"`
+	Level         int    `json:"level"`
+	Status        string `json:"status"`
+	ApprovedAt    string `json:"approvedAt,omitempty"`
+}
+
+// WebhookPublisher 为订阅了事件的品牌端点生成待投递记录，与业务数据在同一事务内写入。
+// 未启用 Webhook 时为 nil，发布直接跳过
+type WebhookPublisher struct{}
+
+// NewWebhookPublisher 创建 Webhook 发布器
+func NewWebhookPublisher() *WebhookPublisher {
+	return &WebhookPublisher{}
+}
+
+// Publish 在事务 tx 内为品牌下订阅了 eventType 的启用端点各生成一条投递记录
+func (p *WebhookPublisher) Publish(tx *gorm.DB, brandId int64, eventType string, data interface{}) error {
+	if p == nil {
+		return nil
+	}
+	if !IsWebhookEventType(eventType) {
+		return fmt.Errorf("unknown webhook event type %q", eventType)
+	}
+
+	var endpoints []model.WebhookEndpoint
+	if err := tx.Where("brand_id = ? AND status = ?", brandId, "active").Find(&endpoints).Error; err != nil {
+		return err
+	}
+
+	var subscribed []model.WebhookEndpoint
+	for _, endpoint := range endpoints {
+		if WebhookSubscribes(endpoint.Events, eventType) {
+			subscribed = append(subscribed, endpoint)
+		}
+	}
+	if len(subscribed) == 0 {
+		return nil
+	}
+
+	eventId, err := randomHex(16)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	payload, err := json.Marshal(WebhookEvent{
+		Id:        "evt_" + eventId,
+		Type:      eventType,
+		BrandId:   brandId,
+		CreatedAt: now.Format(time.RFC3339),
+		Data:      data,
+	})
+	if err != nil {
+		return err
+	}
+
+	deliveries := make([]model.WebhookDelivery, 0, len(subscribed))
+	for _, endpoint := range subscribed {
+		deliveries = append(deliveries, model.WebhookDelivery{
+			EndpointId:    endpoint.Id,
+			BrandId:       brandId,
+			EventId:       "evt_" + eventId,
+			EventType:     eventType,
+			Payload:       string(payload),
+			Status:        WebhookDeliveryPending,
+			NextAttemptAt: now,
+		})
+	}
+	return tx.Create(&deliveries).Error
+}
+
+// PublishOrder 发布订单事件，品牌取订单所属活动的品牌
+func (p *WebhookPublisher) PublishOrder(tx *gorm.DB, eventType string, order *model.Order) error {
+	if p == nil {
+		return nil
+	}
+	var campaign model.Campaign
+	if err := tx.Select("id", "brand_id").Where("id = ?", order.CampaignId).First(&campaign).Error; err != nil {
+		return fmt.Errorf("get campaign of order %d failed: %w", order.Id, err)
+	}
+
+	data := WebhookOrderData{
+		OrderId:            order.Id,
+		CampaignId:         order.CampaignId,
+		MemberId:           order.MemberID,
+		Phone:              order.Phone,
+		Amount:             order.Amount,
+		Status:             order.Status,
+		PayStatus:          order.PayStatus,
+		VerificationStatus: order.VerificationStatus,
+		RedemptionQuota:    order.RedemptionQuota,
+		RedeemedCount:      order.RedeemedCount,
+		PaidAt:             formatWebhookTime(order.PaidAt),
+		VerifiedAt:         formatWebhookTime(order.VerifiedAt),
+		CreatedAt:          order.CreatedAt.Format(time.RFC3339),
+	}
+	return p.Publish(tx, campaign.BrandId, eventType, data)
+}
+
+// PublishReward 发布分销奖励结算事件
+func (p *WebhookPublisher) PublishReward(tx *gorm.DB, brandId int64, reward *model.DistributorReward) error {
+	return p.Publish(tx, brandId, WebhookEventRewardSettled, WebhookRewardData{
+		RewardId:      reward.Id,
+		DistributorId: reward.DistributorId,
+		UserId:        reward.UserId,
+		OrderId:       reward.OrderId,
+		CampaignId:    reward.CampaignId,
+		Level:         reward.Level,
+		Amount:        reward.Amount,
+		SettledAt:     formatWebhookTime(reward.SettledAt),
+	})
+}
+
+// PublishWithdrawal 发布提现状态变更事件
+func (p *WebhookPublisher) PublishWithdrawal(tx *gorm.DB, withdrawal *model.Withdrawal) error {
+	return p.Publish(tx, withdrawal.BrandId, WebhookEventWithdrawalStatusChanged, WebhookWithdrawalData{
+		WithdrawalId:  withdrawal.ID,
+		UserId:        withdrawal.UserID,
+		DistributorId: withdrawal.DistributorId,
+		Amount:        withdrawal.Amount,
+		Status:        withdrawal.Status,
+		PayType:       withdrawal.PayType,
+		TradeNo:       withdrawal.TradeNo,
+		PaidAt:        formatWebhookTime(withdrawal.PaidAt),
+	})
+}
+
+// PublishDistributorApproved 发布分销商审核通过事件
+func (p *WebhookPublisher) PublishDistributorApproved(tx *gorm.DB, distributor *model.Distributor) error {
+	return p.Publish(tx, distributor.BrandId, WebhookEventDistributorApproved, WebhookDistributorData{
+		DistributorId: distributor.Id,
+		UserId:        distributor.UserId,
+		Level:         distributor.Level,
+		Status:        distributor.Status,
+		ApprovedAt:    formatWebhookTime(distributor.ApprovedAt),
+	})
+}
+
+// IsWebhookEventType 是否为可订阅的事件类型
+func IsWebhookEventType(eventType string) bool {
+	for _, t := range WebhookEventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookSubscribes 端点订阅的事件列表（逗号分隔）是否包含 eventType
+func WebhookSubscribes(events, eventType string) bool {
+	for _, event := range strings.Split(events, ",") {
+		if strings.TrimSpace(event) == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// SignWebhookPayload 计算请求体签名：HMAC-SHA256(secret, "<时间戳>.<请求体>") 的十六进制
+func SignWebhookPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(fmt.Sprintf("%d.", timestamp)))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// GenerateWebhookSecret 生成端点签名密钥
+func GenerateWebhookSecret() (string, error) {
+	secret, err := randomHex(24)
+	if err != nil {
+		return "", err
+	}
+	return "whsec_" + secret, nil
+}
+
+func randomHex(n int) (string, error) {
+	bytes := make([]byte, n)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
+
+func formatWebhookTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
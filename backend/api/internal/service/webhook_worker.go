@@ -0,0 +1,184 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"dmh/common/leasepoll"
+	"dmh/model"
+
+	"github.com/zeromicro/go-zero/core/logx"
+	"gorm.io/gorm"
+)
+
+// WebhookWorkerConfig Webhook 投递Worker配置
+type WebhookWorkerConfig struct {
+	Interval            time.Duration // 轮询间隔
+	BatchSize           int           // 每轮最多投递的记录数
+	MaxAttempts         int           // 最大尝试次数，超过后标记为失败，可手动重新投递
+	RetryBackoff        time.Duration // 重试退避基数，第 n 次失败等待 2^(n-1) 倍，最长 6 小时
+	Timeout             time.Duration // 单次请求超时
+	Lease               time.Duration // 处理租约，防止多个实例重复投递
+	AllowPrivateNetwork bool          // 允许投递到内网、回环等非公网地址，仅用于测试或纯内网部署
+}
+
+// WebhookWorker 将待投递记录以签名的 POST 请求推送到端点，2xx 视为成功
+type WebhookWorker struct {
+	*leasepoll.Poller
+	db     *gorm.DB
+	client *http.Client
+	config WebhookWorkerConfig
+	logger logx.Logger
+}
+
+// NewWebhookWorker 创建 Webhook 投递Worker
+func NewWebhookWorker(db *gorm.DB, config WebhookWorkerConfig) *WebhookWorker {
+	if config.Interval <= 0 {
+		config.Interval = 5 * time.Second
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 50
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 8
+	}
+	if config.RetryBackoff <= 0 {
+		config.RetryBackoff = 30 * time.Second
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Second
+	}
+	if config.Lease <= 0 {
+		config.Lease = config.Timeout + time.Minute
+	}
+
+	w := &WebhookWorker{
+		db:     db,
+		client: newWebhookHTTPClient(config.Timeout, config.AllowPrivateNetwork),
+		config: config,
+		logger: logx.WithContext(context.Background()),
+	}
+	w.Poller = leasepoll.New(leasepoll.Config{
+		Name:      "WebhookWorker",
+		Interval:  config.Interval,
+		BatchSize: config.BatchSize,
+		Lease:     config.Lease,
+	}, w.dueQuery, w.deliver)
+	return w
+}
+
+// dueQuery 待投递且已到投递时间的记录
+func (w *WebhookWorker) dueQuery(now time.Time) *gorm.DB {
+	return w.db.Model(&model.WebhookDelivery{}).
+		Where("status = ? AND next_attempt_at <= ?", WebhookDeliveryPending, now)
+}
+
+// deliver 推送一条记录并保存结果，失败时按退避时间等待下次投递
+func (w *WebhookWorker) deliver(id int64) bool {
+	var delivery model.WebhookDelivery
+	if err := w.db.First(&delivery, id).Error; err != nil {
+		w.logger.Errorf("Failed to load webhook delivery: id=%d, err=%v", id, err)
+		return false
+	}
+	attempts := delivery.Attempts + 1
+
+	var endpoint model.WebhookEndpoint
+	if err := w.db.First(&endpoint, delivery.EndpointId).Error; err != nil || endpoint.Status != "active" {
+		w.finish(&delivery, map[string]interface{}{
+			"status":     WebhookDeliveryFailed,
+			"attempts":   attempts,
+			"last_error": "端点不存在或已停用",
+		})
+		return false
+	}
+
+	start := time.Now()
+	statusCode, body, err := w.post(&endpoint, &delivery)
+	updates := map[string]interface{}{
+		"attempts":        attempts,
+		"response_status": statusCode,
+		"response_body":   truncateWebhookField(body),
+		"duration_ms":     time.Since(start).Milliseconds(),
+	}
+	if err == nil && statusCode >= 200 && statusCode < 300 {
+		updates["status"] = WebhookDeliveryDelivered
+		updates["delivered_at"] = time.Now()
+		updates["last_error"] = ""
+		w.finish(&delivery, updates)
+		return true
+	}
+
+	if err == nil {
+		err = fmt.Errorf("unexpected status %d", statusCode)
+	}
+	updates["last_error"] = truncateWebhookField(err.Error())
+	if attempts >= w.config.MaxAttempts {
+		updates["status"] = WebhookDeliveryFailed
+		w.logger.Errorf("Webhook delivery failed: id=%d, endpoint=%d, event=%s, attempts=%d, err=%v",
+			delivery.Id, endpoint.Id, delivery.EventType, attempts, err)
+	} else {
+		updates["next_attempt_at"] = time.Now().Add(w.backoff(attempts))
+		w.logger.Infof("Webhook delivery will retry: id=%d, endpoint=%d, attempts=%d, err=%v", delivery.Id, endpoint.Id, attempts, err)
+	}
+	w.finish(&delivery, updates)
+	return false
+}
+
+// post 发送签名请求，返回状态码与响应内容
+func (w *WebhookWorker) post(endpoint *model.WebhookEndpoint, delivery *model.WebhookDelivery) (int, string, error) {
+	body := []byte(delivery.Payload)
+	timestamp := time.Now().Unix()
+
+	req, err := http.NewRequest(http.MethodPost, endpoint.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "DMH-Webhook/1.0")
+	req.Header.Set(WebhookHeaderEvent, delivery.EventType)
+	req.Header.Set(WebhookHeaderEventId, delivery.EventId)
+	req.Header.Set(WebhookHeaderDelivery, strconv.FormatInt(delivery.Id, 10))
+	req.Header.Set(WebhookHeaderSignature, fmt.Sprintf("t=%d,v1=%s", timestamp, SignWebhookPayload(endpoint.Secret, timestamp, body)))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+	return resp.StatusCode, string(respBody), nil
+}
+
+func (w *WebhookWorker) finish(delivery *model.WebhookDelivery, updates map[string]interface{}) {
+	if err := w.db.Model(&model.WebhookDelivery{}).Where("id = ?", delivery.Id).Updates(updates).Error; err != nil {
+		// 保存失败时租约到期后会再次投递
+		w.logger.Errorf("Failed to save webhook delivery result: id=%d, err=%v", delivery.Id, err)
+	}
+}
+
+// backoff 第 attempts 次失败后的等待时间
+func (w *WebhookWorker) backoff(attempts int) time.Duration {
+	delay := w.config.RetryBackoff
+	for i := 1; i < attempts && delay < 6*time.Hour; i++ {
+		delay *= 2
+	}
+	if delay > 6*time.Hour {
+		delay = 6 * time.Hour
+	}
+	return delay
+}
+
+// truncateWebhookField 按字符截断，适配 varchar(500) 列
+func truncateWebhookField(value string) string {
+	runes := []rune(value)
+	if len(runes) > 500 {
+		return string(runes[:500])
+	}
+	return value
+}
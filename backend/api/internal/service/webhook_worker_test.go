@@ -0,0 +1,208 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"dmh/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+func TestSignWebhookPayload(t *testing.T) {
+	body := []byte(`{"id":"evt_1"}`)
+	sig := SignWebhookPayload("whsec_test", 1700000000, body)
+
+	assert.Len(t, sig, 64)
+	assert.Equal(t, sig, SignWebhookPayload("whsec_test", 1700000000, body))
+	assert.NotEqual(t, sig, SignWebhookPayload("whsec_other", 1700000000, body))
+	assert.NotEqual(t, sig, SignWebhookPayload("whsec_test", 1700000001, body))
+}
+
+func TestWebhookSubscribes(t *testing.T) {
+	events := "order.paid, reward.settled"
+	assert.True(t, WebhookSubscribes(events, WebhookEventOrderPaid))
+	assert.True(t, WebhookSubscribes(events, WebhookEventRewardSettled))
+	assert.False(t, WebhookSubscribes(events, WebhookEventOrderCreated))
+	assert.False(t, WebhookSubscribes("", WebhookEventOrderCreated))
+
+	assert.True(t, IsWebhookEventType(WebhookEventDistributorApproved))
+	assert.False(t, IsWebhookEventType("order.deleted"))
+}
+
+func TestWebhookPublisher_Nil(t *testing.T) {
+	var p *WebhookPublisher
+	assert.NoError(t, p.Publish(nil, 1, WebhookEventOrderPaid, nil))
+	assert.NoError(t, p.PublishOrder(nil, WebhookEventOrderPaid, &model.Order{}))
+	assert.NoError(t, p.PublishWithdrawal(nil, &model.Withdrawal{}))
+}
+
+func TestWebhookWorker_Backoff(t *testing.T) {
+	w := NewWebhookWorker(nil, WebhookWorkerConfig{RetryBackoff: time.Minute})
+	assert.Equal(t, time.Minute, w.backoff(1))
+	assert.Equal(t, 4*time.Minute, w.backoff(3))
+	assert.Equal(t, 6*time.Hour, w.backoff(20))
+
+	secret, err := GenerateWebhookSecret()
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(secret, "whsec_"))
+}
+
+func TestCheckWebhookHost(t *testing.T) {
+	for _, host := range []string{"127.0.0.1", "10.1.2.3", "172.16.0.1", "192.168.1.1", "169.254.169.254", "0.0.0.0", "100.64.0.1", "::1", "fe80::1", "fd00::1", "::ffff:127.0.0.1", "localhost"} {
+		assert.ErrorIs(t, CheckWebhookHost(context.Background(), host), ErrWebhookAddressForbidden, host)
+	}
+	for _, host := range []string{"8.8.8.8", "203.0.113.10", "2001:4860:4860::8888"} {
+		assert.NoError(t, CheckWebhookHost(context.Background(), host), host)
+	}
+}
+
+// TestWebhookWorker_PostGuards 投递时拒绝连接内网地址，且不跟随重定向
+func TestWebhookWorker_PostGuards(t *testing.T) {
+	var redirected bool
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		redirected = true
+	}))
+	defer target.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target.URL, http.StatusFound)
+	}))
+	defer server.Close()
+
+	delivery := &model.WebhookDelivery{Id: 1, EventType: WebhookEventOrderPaid, Payload: "{}"}
+	endpoint := &model.WebhookEndpoint{Url: server.URL, Secret: "whsec_test"}
+
+	_, _, err := NewWebhookWorker(nil, WebhookWorkerConfig{}).post(endpoint, delivery)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrWebhookAddressForbidden)
+
+	status, _, err := NewWebhookWorker(nil, WebhookWorkerConfig{AllowPrivateNetwork: true}).post(endpoint, delivery)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusFound, status)
+	assert.False(t, redirected)
+}
+
+type WebhookWorkerTestSuite struct {
+	suite.Suite
+	db *gorm.DB
+}
+
+func (suite *WebhookWorkerTestSuite) SetupSuite() {
+	db, err := gorm.Open(mysql.Open("root:Admin168@tcp(127.0.0.1:3306)/dmh_test?charset=utf8mb4&parseTime=true&loc=Local"), &gorm.Config{})
+	suite.Require().NoError(err)
+	suite.Require().NoError(db.AutoMigrate(&model.WebhookEndpoint{}, &model.WebhookDelivery{}))
+	suite.db = db
+}
+
+func (suite *WebhookWorkerTestSuite) TearDownSuite() {
+	sqlDB, _ := suite.db.DB()
+	sqlDB.Close()
+}
+
+func (suite *WebhookWorkerTestSuite) SetupTest() {
+	suite.Require().NoError(suite.db.Exec("TRUNCATE TABLE webhook_endpoints").Error)
+	suite.Require().NoError(suite.db.Exec("TRUNCATE TABLE webhook_deliveries").Error)
+}
+
+func (suite *WebhookWorkerTestSuite) createEndpoint(url, events string) *model.WebhookEndpoint {
+	endpoint := &model.WebhookEndpoint{BrandId: 1, Url: url, Secret: "whsec_test", Events: events, Status: "active"}
+	suite.Require().NoError(suite.db.Create(endpoint).Error)
+	return endpoint
+}
+
+// TestPublishOnlySubscribedEndpoints 只为订阅了事件的启用端点生成投递记录
+func (suite *WebhookWorkerTestSuite) TestPublishOnlySubscribedEndpoints() {
+	paid := suite.createEndpoint("http://a.example.com", "order.paid")
+	suite.createEndpoint("http://b.example.com", "order.created")
+	disabled := suite.createEndpoint("http://c.example.com", "order.paid")
+	suite.Require().NoError(suite.db.Model(disabled).Update("status", "disabled").Error)
+
+	err := NewWebhookPublisher().Publish(suite.db, 1, WebhookEventOrderPaid, map[string]int{"orderId": 7})
+	suite.Require().NoError(err)
+
+	var deliveries []model.WebhookDelivery
+	suite.Require().NoError(suite.db.Find(&deliveries).Error)
+	suite.Require().Len(deliveries, 1)
+	suite.Equal(paid.Id, deliveries[0].EndpointId)
+	suite.Equal(WebhookDeliveryPending, deliveries[0].Status)
+
+	var event WebhookEvent
+	suite.Require().NoError(json.Unmarshal([]byte(deliveries[0].Payload), &event))
+	suite.Equal(WebhookEventOrderPaid, event.Type)
+	suite.Equal(deliveries[0].EventId, event.Id)
+}
+
+// TestDeliverSigned 投递请求携带可校验的签名
+func (suite *WebhookWorkerTestSuite) TestDeliverSigned() {
+	var signature, event string
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signature = r.Header.Get(WebhookHeaderSignature)
+		event = r.Header.Get(WebhookHeaderEvent)
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	suite.createEndpoint(server.URL, "order.paid")
+	suite.Require().NoError(NewWebhookPublisher().Publish(suite.db, 1, WebhookEventOrderPaid, nil))
+
+	suite.Equal(1, NewWebhookWorker(suite.db, WebhookWorkerConfig{AllowPrivateNetwork: true}).RunOnce())
+	suite.Equal(WebhookEventOrderPaid, event)
+
+	var timestamp int64
+	var sig string
+	_, err := fmt.Sscanf(strings.Replace(signature, ",v1=", " ", 1), "t=%d %s", &timestamp, &sig)
+	suite.Require().NoError(err)
+	suite.Equal(SignWebhookPayload("whsec_test", timestamp, body), sig)
+
+	var delivery model.WebhookDelivery
+	suite.Require().NoError(suite.db.First(&delivery).Error)
+	suite.Equal(WebhookDeliveryDelivered, delivery.Status)
+	suite.Equal(http.StatusNoContent, delivery.ResponseStatus)
+	suite.NotNil(delivery.DeliveredAt)
+}
+
+// TestDeliverRetryThenFail 非 2xx 响应按退避重试，达到最大次数后标记为失败
+func (suite *WebhookWorkerTestSuite) TestDeliverRetryThenFail() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	suite.createEndpoint(server.URL, "order.paid")
+	suite.Require().NoError(NewWebhookPublisher().Publish(suite.db, 1, WebhookEventOrderPaid, nil))
+	worker := NewWebhookWorker(suite.db, WebhookWorkerConfig{MaxAttempts: 2, RetryBackoff: time.Hour, AllowPrivateNetwork: true})
+
+	suite.Equal(0, worker.RunOnce())
+	var delivery model.WebhookDelivery
+	suite.Require().NoError(suite.db.First(&delivery).Error)
+	suite.Equal(WebhookDeliveryPending, delivery.Status)
+	suite.Equal(1, delivery.Attempts)
+	suite.True(delivery.NextAttemptAt.After(time.Now().Add(30 * time.Minute)))
+
+	// 退避期间不再投递
+	suite.Equal(0, worker.RunOnce())
+
+	suite.Require().NoError(suite.db.Model(&delivery).Update("next_attempt_at", time.Now().Add(-time.Second)).Error)
+	worker.RunOnce()
+	suite.Require().NoError(suite.db.First(&delivery).Error)
+	suite.Equal(WebhookDeliveryFailed, delivery.Status)
+	suite.Equal(2, delivery.Attempts)
+	suite.Equal(http.StatusInternalServerError, delivery.ResponseStatus)
+}
+
+func TestWebhookWorkerTestSuite(t *testing.T) {
+	suite.Run(t, new(WebhookWorkerTestSuite))
+}
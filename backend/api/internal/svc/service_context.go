@@ -12,6 +12,7 @@ import (
 	"dmh/api/internal/middleware"
 	"dmh/api/internal/service"
 	"dmh/common/poster"
+	"dmh/common/syncadapter"
 	"dmh/common/wechatpay"
 
 	"github.com/redis/go-redis/v9"
@@ -29,7 +30,17 @@ type ServiceContext struct {
 	PosterService        *poster.Service
 	PosterRateLimiter    middleware.RateLimiter
 	DefaultRateLimiter   middleware.RateLimiter
+	VerificationLimiter  middleware.RateLimiter // 按用户或IP统计短码核销失败次数
 	WeChatPayService     *wechatpay.Service
+	SeatCounter          service.SeatCounter               // 活动名额计数，Redis 不可用时为 nil
+	SyncQueue            syncadapter.TaskQueue             // 外部同步队列，未启用同步或队列不可用时为 nil
+	SyncAdapter          *syncadapter.SyncAdapter          // 外部数据库连接，连接失败时为 nil
+	SyncWorker           *syncadapter.SyncWorker           // 同步Worker，由 main 启动
+	Outbox               *syncadapter.Outbox               // 外部同步发件箱，未启用同步时为 nil
+	SyncBackfiller       *syncadapter.Backfiller           // 历史数据回填，外部数据库未连接时为 nil
+	Webhooks             *service.WebhookPublisher         // 业务事件的 Webhook 推送，未启用时为 nil
+	VerificationTokens   *service.VerificationTokenService // 核销码签发与校验，密钥未配置时为 nil
+	SnapshotSigner       *service.SnapshotSigner           // 离线核销快照签名，私钥未配置时为 nil
 	PermissionMiddleware *middleware.PermissionMiddleware
 }
 
@@ -57,6 +68,70 @@ func (r *redisAdapter) TTL(ctx context.Context, key string) (string, error) {
 	return fmt.Sprintf("%d", int(ttl.Seconds())), nil
 }
 
+// seatReserveScript 所有名额计数均未满时一起加一并续期；ARGV 依次为各计数上限与过期毫秒数。
+// 返回 -2 表示计数不存在，返回 0 起的下标表示该计数已满
+var seatReserveScript = redis.NewScript(`
+local ttl = ARGV[#KEYS + 1]
+for i, key in ipairs(KEYS) do
+	local used = redis.call('GET', key)
+	if not used then
+		return -2
+	end
+	if tonumber(used) >= tonumber(ARGV[i]) then
+		return i - 1
+	end
+end
+for _, key in ipairs(KEYS) do
+	redis.call('INCR', key)
+	redis.call('PEXPIRE', key, ttl)
+end
+return -1
+`)
+
+var seatReleaseScript = redis.NewScript(`
+local used = redis.call('GET', KEYS[1])
+if not used then
+	return 0
+end
+redis.call('PEXPIRE', KEYS[1], ARGV[1])
+if tonumber(used) > 0 then
+	return redis.call('DECR', KEYS[1])
+end
+return 0
+`)
+
+type redisSeatCounter struct {
+	client *redis.Client
+}
+
+func (r *redisSeatCounter) Reserve(ctx context.Context, keys []string, limits []int64, ttl time.Duration) (int, error) {
+	args := make([]interface{}, len(limits), len(limits)+1)
+	for i, limit := range limits {
+		args[i] = limit
+	}
+	args = append(args, ttl.Milliseconds())
+	result, err := seatReserveScript.Run(ctx, r.client, keys, args...).Int()
+	if err != nil {
+		return 0, err
+	}
+	if result == -2 {
+		return 0, service.ErrSeatCounterMissing
+	}
+	return result, nil
+}
+
+func (r *redisSeatCounter) Init(ctx context.Context, key string, value int64, ttl time.Duration) error {
+	return r.client.SetNX(ctx, key, value, ttl).Err()
+}
+
+func (r *redisSeatCounter) Release(ctx context.Context, key string, ttl time.Duration) error {
+	return seatReleaseScript.Run(ctx, r.client, []string{key}, ttl.Milliseconds()).Err()
+}
+
+func (r *redisSeatCounter) Reset(ctx context.Context, key string) error {
+	return r.client.Del(ctx, key).Err()
+}
+
 func NewServiceContext(c config.Config) *ServiceContext {
 	// 初始化GORM数据库连接
 	db, err := gorm.Open(mysql.Open(c.Mysql.DataSource), &gorm.Config{})
@@ -81,47 +156,117 @@ func NewServiceContext(c config.Config) *ServiceContext {
 	posterService := poster.NewService("/opt/data/posters", "http://localhost:8889/api/v1")
 
 	wechatPayConfig := &wechatpay.Config{
-		AppID:           c.WeChatPay.AppID,
-		MchID:           c.WeChatPay.MchID,
-		APIKey:          c.WeChatPay.APIKey,
-		APIKeyV3:        c.WeChatPay.APIKeyV3,
-		APIClientCert:   c.WeChatPay.APIClientCert,
-		APIClientKey:    c.WeChatPay.APIClientKey,
-		NotifyURL:       c.WeChatPay.NotifyURL,
-		RefundNotifyURL: c.WeChatPay.RefundNotifyURL,
-		Sandbox:         c.WeChatPay.Sandbox,
-		CacheTTL:        c.WeChatPay.CacheTTL,
-		MockEnabled:     c.WeChatPay.MockEnabled,
-		UnifiedOrderURL: c.WeChatPay.UnifiedOrderURL,
-		HTTPTimeoutMs:   c.WeChatPay.HTTPTimeoutMs,
+		AppID:            c.WeChatPay.AppID,
+		MchID:            c.WeChatPay.MchID,
+		APIVersion:       c.WeChatPay.APIVersion,
+		APIKey:           c.WeChatPay.APIKey,
+		APIKeyV3:         c.WeChatPay.APIKeyV3,
+		MchSerialNo:      c.WeChatPay.MchSerialNo,
+		APIClientCert:    c.WeChatPay.APIClientCert,
+		APIClientKey:     c.WeChatPay.APIClientKey,
+		NotifyURL:        c.WeChatPay.NotifyURL,
+		RefundNotifyURL:  c.WeChatPay.RefundNotifyURL,
+		Sandbox:          c.WeChatPay.Sandbox,
+		CacheTTL:         c.WeChatPay.CacheTTL,
+		MockEnabled:      c.WeChatPay.MockEnabled,
+		UnifiedOrderURL:  c.WeChatPay.UnifiedOrderURL,
+		RefundURL:        c.WeChatPay.RefundURL,
+		TransferURL:      c.WeChatPay.TransferURL,
+		TransferQueryURL: c.WeChatPay.TransferQueryURL,
+		V3BaseURL:        c.WeChatPay.V3BaseURL,
+		HTTPTimeoutMs:    c.WeChatPay.HTTPTimeoutMs,
 	}
 	wechatPayService := wechatpay.NewService(wechatPayConfig)
-	logx.Infof("微信支付配置: AppID=%s, MchID=%s, Sandbox=%v, MockEnabled=%v, CacheTTL=%ds",
-		c.WeChatPay.AppID, c.WeChatPay.MchID, c.WeChatPay.Sandbox, c.WeChatPay.MockEnabled, c.WeChatPay.CacheTTL)
+	logx.Infof("微信支付配置: AppID=%s, MchID=%s, APIVersion=%s, Sandbox=%v, MockEnabled=%v, CacheTTL=%ds",
+		c.WeChatPay.AppID, c.WeChatPay.MchID, c.WeChatPay.APIVersion, c.WeChatPay.Sandbox, c.WeChatPay.MockEnabled, c.WeChatPay.CacheTTL)
 
 	ctx := context.Background()
+	var redisClient *redis.Client
+	if c.Redis.Host != "" {
+		redisClient = redis.NewClient(&redis.Options{
+			Addr:     c.Redis.Host,
+			Password: c.Redis.Pass,
+			DB:       0,
+			PoolSize: 10,
+		})
+		if err := redisClient.Ping(ctx).Err(); err != nil {
+			logx.Errorf("Redis连接失败: %v", err)
+			redisClient = nil
+		}
+	}
+
 	var redisAdapterClient middleware.RedisClient
-	useRedis := c.RateLimit.PosterGenerate.Storage == "redis" || c.RateLimit.Default.Storage == "redis"
+	useRedis := c.RateLimit.PosterGenerate.Storage == "redis" || c.RateLimit.Default.Storage == "redis" || c.RateLimit.VerificationCode.Storage == "redis"
 	if useRedis {
-		if c.Redis.Host == "" {
-			logx.Errorf("Redis未配置，已退回内存存储")
+		if redisClient == nil {
+			logx.Errorf("Redis未配置或不可用，已退回内存存储")
 		} else {
-			redisClient := redis.NewClient(&redis.Options{
-				Addr:     c.Redis.Host,
-				Password: c.Redis.Pass,
-				DB:       0,
-				PoolSize: 10,
-			})
-			if err := redisClient.Ping(ctx).Err(); err != nil {
-				logx.Errorf("Redis连接失败: %v", err)
-			} else {
-				redisAdapterClient = &redisAdapter{client: redisClient}
-			}
+			redisAdapterClient = &redisAdapter{client: redisClient}
 		}
 	} else {
 		logx.Info("使用内存存储")
 	}
 
+	// 活动名额计数，Redis 不可用时名额校验退回数据库
+	var seatCounter service.SeatCounter
+	if redisClient != nil {
+		seatCounter = &redisSeatCounter{client: redisClient}
+	}
+
+	// 外部数据库同步队列；发件箱在 Redis 不可用时照常写入，恢复后由中继补投
+	var syncQueue syncadapter.TaskQueue
+	var outbox *syncadapter.Outbox
+	if c.ExternalSync.Enabled {
+		outbox = syncadapter.NewOutbox()
+		queue, err := syncadapter.NewTaskQueue(c.ExternalSync.QueueBackend, redisClient, c.ExternalSync.QueueKey, syncadapter.StreamQueueConfig{
+			ClaimIdle: time.Duration(c.ExternalSync.ClaimIdleSeconds) * time.Second,
+		})
+		if err != nil {
+			logx.Errorf("外部同步队列不可用，同步事件暂存在发件箱中: %v", err)
+		} else {
+			syncQueue = queue
+		}
+	}
+
+	var webhooks *service.WebhookPublisher
+	if c.Webhook.Enabled {
+		webhooks = service.NewWebhookPublisher()
+	}
+
+	verificationTokens, err := newVerificationTokenService(c)
+	if err != nil {
+		logx.Errorf("核销码服务初始化失败，无法签发与校验核销码: %v", err)
+	}
+
+	// 离线快照使用独立的非对称密钥，设备只需公钥，不接触核销码签名密钥
+	var snapshotSigner *service.SnapshotSigner
+	if c.Verification.SnapshotSigningKey != "" {
+		snapshotSigner, err = service.NewSnapshotSigner(c.Verification.SnapshotKeyId, c.Verification.SnapshotSigningKey)
+		if err != nil {
+			logx.Errorf("离线快照签名密钥无效，无法下载核销快照: %v", err)
+		} else {
+			logx.Infof("离线快照签名公钥: keyId=%s, publicKey=%s", c.Verification.SnapshotKeyId, snapshotSigner.PublicKey())
+		}
+	}
+
+	var syncAdapter *syncadapter.SyncAdapter
+	var syncWorker *syncadapter.SyncWorker
+	var syncBackfiller *syncadapter.Backfiller
+	if syncQueue != nil && db != nil {
+		adapter, err := newSyncAdapter(c, db)
+		if err != nil {
+			logx.Errorf("外部同步初始化失败，同步Worker未启动: %v", err)
+		} else {
+			syncAdapter = adapter
+			syncWorker = syncadapter.NewSyncWorkerWithRetry(adapter, syncQueue, db, syncadapter.RetryPolicy{
+				MaxAttempts: c.ExternalSync.MaxAttempts,
+				BaseBackoff: time.Duration(c.ExternalSync.RetryBackoffSeconds) * time.Second,
+				MaxBackoff:  time.Duration(c.ExternalSync.MaxBackoffSeconds) * time.Second,
+			}).WithConcurrency(c.ExternalSync.Consumers)
+			syncBackfiller = syncadapter.NewBackfiller(db, adapter)
+		}
+	}
+
 	createRateLimiter := func(storageType string, redisClient middleware.RedisClient, maxRequests int, duration int, prefix string) middleware.RateLimiter {
 		if storageType == "redis" && redisClient != nil {
 			return middleware.NewRedisRateLimiter(redisClient, prefix, maxRequests, time.Duration(duration)*time.Second)
@@ -131,6 +276,7 @@ func NewServiceContext(c config.Config) *ServiceContext {
 
 	posterRateLimiter := createRateLimiter(c.RateLimit.PosterGenerate.Storage, redisAdapterClient, c.RateLimit.PosterGenerate.MaxRequests, c.RateLimit.PosterGenerate.WindowDuration, "poster")
 	defaultRateLimiter := createRateLimiter(c.RateLimit.Default.Storage, redisAdapterClient, c.RateLimit.Default.MaxRequests, c.RateLimit.Default.WindowDuration, "default")
+	verificationLimiter := createRateLimiter(c.RateLimit.VerificationCode.Storage, redisAdapterClient, c.RateLimit.VerificationCode.MaxRequests, c.RateLimit.VerificationCode.WindowDuration, "verification")
 
 	// 初始化权限中间件
 	var permissionMiddleware *middleware.PermissionMiddleware
@@ -153,7 +299,60 @@ func NewServiceContext(c config.Config) *ServiceContext {
 		PosterService:        posterService,
 		PosterRateLimiter:    posterRateLimiter,
 		DefaultRateLimiter:   defaultRateLimiter,
+		VerificationLimiter:  verificationLimiter,
 		WeChatPayService:     wechatPayService,
+		SeatCounter:          seatCounter,
+		SyncQueue:            syncQueue,
+		SyncAdapter:          syncAdapter,
+		SyncWorker:           syncWorker,
+		Outbox:               outbox,
+		SyncBackfiller:       syncBackfiller,
+		Webhooks:             webhooks,
+		VerificationTokens:   verificationTokens,
+		SnapshotSigner:       snapshotSigner,
 		PermissionMiddleware: permissionMiddleware,
 	}
 }
+
+// newVerificationTokenService 按配置创建核销码服务，旧格式核销码仅在 LegacyUntil 当天结束前有效
+func newVerificationTokenService(c config.Config) (*service.VerificationTokenService, error) {
+	tokenConfig := service.VerificationTokenConfig{
+		ActiveKeyId:  c.Verification.ActiveKeyId,
+		TTL:          time.Duration(c.Verification.TTLHours) * time.Hour,
+		LegacySecret: c.Verification.LegacySecret,
+	}
+	for _, key := range c.Verification.Keys {
+		tokenConfig.Keys = append(tokenConfig.Keys, service.VerificationKey{Id: key.Id, Secret: key.Secret})
+	}
+	if c.Verification.LegacyUntil != "" {
+		until, err := time.ParseInLocation("2006-01-02", c.Verification.LegacyUntil, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("LegacyUntil 格式无效: %w", err)
+		}
+		tokenConfig.LegacyUntil = until.AddDate(0, 0, 1)
+	}
+	return service.NewVerificationTokenService(tokenConfig)
+}
+
+// newSyncAdapter 连接外部数据库，并按配置文件或 sync_field_mappings 表中的字段映射校验目标表结构
+func newSyncAdapter(c config.Config, db *gorm.DB) (*syncadapter.SyncAdapter, error) {
+	mapping := c.ExternalSync.Mapping
+	if c.ExternalSync.MappingSource == "db" {
+		var err error
+		if mapping, err = syncadapter.LoadMappingConfig(db); err != nil {
+			return nil, err
+		}
+	}
+
+	return syncadapter.NewSyncAdapter(syncadapter.ExternalSyncConfig{
+		Type:     c.ExternalSync.Database.Type,
+		Host:     c.ExternalSync.Database.Host,
+		Port:     c.ExternalSync.Database.Port,
+		User:     c.ExternalSync.Database.User,
+		Password: c.ExternalSync.Database.Password,
+		Database: c.ExternalSync.Database.Database,
+		Schema:   c.ExternalSync.Database.Schema,
+		Charset:  c.ExternalSync.Database.Charset,
+		Mapping:  mapping,
+	})
+}
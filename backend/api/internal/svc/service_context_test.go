@@ -2,10 +2,12 @@ package svc
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
 	"dmh/api/internal/config"
+	"dmh/api/internal/service"
 
 	"github.com/redis/go-redis/v9"
 )
@@ -60,6 +62,62 @@ func TestRedisAdapterMethods(t *testing.T) {
 	_ = client.Del(ctx, key)
 }
 
+func TestRedisSeatCounter(t *testing.T) {
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:6379"})
+	t.Cleanup(func() { _ = client.Close() })
+
+	ctx := context.Background()
+	if err := client.Ping(ctx).Err(); err != nil {
+		t.Skipf("redis not available: %v", err)
+	}
+
+	counter := &redisSeatCounter{client: client}
+	keys := []string{"svc_test_seats_total", "svc_test_seats_day"}
+	_ = client.Del(ctx, keys...)
+	t.Cleanup(func() { _ = client.Del(ctx, keys...) })
+
+	if _, err := counter.Reserve(ctx, keys, []int64{2, 5}, time.Hour); !errors.Is(err, service.ErrSeatCounterMissing) {
+		t.Fatalf("expected missing counter, got %v", err)
+	}
+	for _, key := range keys {
+		if err := counter.Init(ctx, key, 1, time.Minute); err != nil {
+			t.Fatalf("init failed: %v", err)
+		}
+	}
+
+	if full, err := counter.Reserve(ctx, keys, []int64{2, 5}, time.Hour); err != nil || full != -1 {
+		t.Fatalf("expected reserve success, got %d %v", full, err)
+	}
+	if full, err := counter.Reserve(ctx, keys, []int64{2, 5}, time.Hour); err != nil || full != 0 {
+		t.Fatalf("expected total counter full, got %d %v", full, err)
+	}
+	if value, _ := client.Get(ctx, keys[1]).Int(); value != 2 {
+		t.Fatalf("daily counter should not change when full: %d", value)
+	}
+
+	// 预占续期，计数不会在下单过程中按初始化时的过期时间失效
+	if ttl := client.PTTL(ctx, keys[0]).Val(); ttl <= time.Minute {
+		t.Fatalf("expected reserve to refresh ttl, got %v", ttl)
+	}
+
+	if err := counter.Release(ctx, keys[0], 2*time.Hour); err != nil {
+		t.Fatalf("release failed: %v", err)
+	}
+	if ttl := client.PTTL(ctx, keys[0]).Val(); ttl <= time.Hour {
+		t.Fatalf("expected release to refresh ttl, got %v", ttl)
+	}
+	if full, err := counter.Reserve(ctx, keys, []int64{2, 5}, time.Hour); err != nil || full != -1 {
+		t.Fatalf("expected reserve after release, got %d %v", full, err)
+	}
+
+	if err := counter.Reset(ctx, keys[0]); err != nil {
+		t.Fatalf("reset failed: %v", err)
+	}
+	if _, err := counter.Reserve(ctx, keys, []int64{2, 5}, time.Hour); !errors.Is(err, service.ErrSeatCounterMissing) {
+		t.Fatalf("expected missing counter after reset, got %v", err)
+	}
+}
+
 func TestNewServiceContext(t *testing.T) {
 	c := config.Config{}
 	c.Mysql.DataSource = "root:Admin168@tcp(127.0.0.1:3306)/dmh_test?charset=utf8mb4&parseTime=true&loc=Local"
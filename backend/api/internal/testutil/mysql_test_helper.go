@@ -190,19 +190,36 @@ func migrateTestSchema(db *gorm.DB) error {
 		&model.UserRole{},
 		&model.RolePermission{},
 		&model.UserBrand{},
+		&model.VerificationScope{},
 		&model.Menu{},
 		&model.Member{},
 		&model.Order{},
+		&model.OrderRefund{},
+		&model.PaymentNotification{},
+		&model.PaymentReconciliation{},
+		&model.PaymentReconciliationItem{},
+		&model.BalanceTransaction{},
+		&model.Reward{},
 		&model.VerificationRecord{},
 		&model.Distributor{},
 		&model.DistributorLevelReward{},
 		&model.DistributorReward{},
+		&model.UserBalance{},
 		&model.UserFeedback{},
 		&model.Withdrawal{},
 		&model.PosterTemplate{},
 		&model.PasswordPolicy{},
 		&model.AuditLog{},
+		&model.SecurityEvent{},
 		&model.SyncLog{},
+		&model.SyncAttempt{},
+		&model.SyncFieldMapping{},
+		&model.OutboxEvent{},
+		&model.SyncBackfillCheckpoint{},
+		&model.SyncInboundChange{},
+		&model.SyncInboundCursor{},
+		&model.WebhookEndpoint{},
+		&model.WebhookDelivery{},
 		&model.PageConfig{},
 	}
 
@@ -227,6 +244,8 @@ func migrateTestSchema(db *gorm.DB) error {
 			distribution_rewards JSON,
 			payment_config JSON,
 			poster_template_id BIGINT DEFAULT 1,
+			total_quota INT NOT NULL DEFAULT 0,
+			daily_quota INT NOT NULL DEFAULT 0,
 			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
 			updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
 			deleted_at DATETIME,
@@ -235,8 +254,14 @@ func migrateTestSchema(db *gorm.DB) error {
 		)
 	`)
 
-	// Create unique index for order duplicate guard
-	if err := db.Exec("ALTER TABLE orders ADD UNIQUE KEY uk_orders_campaign_phone (campaign_id, phone)").Error; err != nil {
+	// Create unique index for order duplicate guard (cancelled/deleted orders are excluded via active_flag)
+	if err := db.Exec("ALTER TABLE orders ADD COLUMN active_flag TINYINT GENERATED ALWAYS AS (IF(status = 'cancelled' OR deleted_at IS NOT NULL, NULL, 1)) STORED").Error; err != nil {
+		errMsg := strings.ToLower(err.Error())
+		if !strings.Contains(errMsg, "duplicate column name") {
+			return fmt.Errorf("failed to create column active_flag: %w", err)
+		}
+	}
+	if err := db.Exec("ALTER TABLE orders ADD UNIQUE KEY uk_orders_campaign_phone (campaign_id, phone, active_flag)").Error; err != nil {
 		errMsg := strings.ToLower(err.Error())
 		if !strings.Contains(errMsg, "duplicate key name") {
 			return fmt.Errorf("failed to create unique index uk_orders_campaign_phone: %w", err)
@@ -72,6 +72,48 @@ type BalanceResp struct {
 	TotalReward float64 `json:"totalReward"`
 }
 
+type BalanceTransactionListReq struct {
+	UserId   int64  `path:"userId"`
+	Type     string `form:"type,optional"`
+	Page     int    `form:"page,optional"`
+	PageSize int    `form:"pageSize,optional"`
+}
+
+type BalanceTransactionListResp struct {
+	Total        int64                    `json:"total"`
+	Transactions []BalanceTransactionResp `json:"transactions"`
+}
+
+type BalanceTransactionResp struct {
+	Id             int64   `json:"id"`
+	UserId         int64   `json:"userId"`
+	Type           string  `json:"type"`
+	Account        string  `json:"account"`
+	CounterAccount string  `json:"counterAccount"`
+	Amount         float64 `json:"amount"`
+	BalanceBefore  float64 `json:"balanceBefore"`
+	BalanceAfter   float64 `json:"balanceAfter"`
+	RefType        string  `json:"refType"`
+	RefId          int64   `json:"refId"`
+	Remark         string  `json:"remark"`
+	CreatedAt      string  `json:"createdAt"`
+}
+
+type BatchVerifyOrdersReq struct {
+	DeviceId   string                    `json:"deviceId"`            // 核销设备标识
+	CampaignId int64                     `json:"campaignId,optional"` // 快照所属活动，指定后拒绝其他活动的订单
+	SnapshotAt string                    `json:"snapshotAt,optional"` // 设备所用快照的生成时间，早于该时间的核销记录视为无效
+	Items      []OfflineVerificationItem `json:"items"`
+}
+
+type BatchVerifyOrdersResp struct {
+	Applied    int                         `json:"applied"`
+	Duplicates int                         `json:"duplicates"`
+	Conflicts  int                         `json:"conflicts"`
+	Invalid    int                         `json:"invalid"`
+	Results    []OfflineVerificationResult `json:"results"`
+}
+
 type BindEmailReq struct {
 	Email string `json:"email"`
 	Code  string `json:"code"`
@@ -198,6 +240,11 @@ type CampaignResp struct {
 	DistributionRewards string      `json:"distributionRewards"`
 	PaymentConfig       string      `json:"paymentConfig"`
 	PosterTemplateId    int64       `json:"posterTemplateId"`
+	TotalQuota          int         `json:"totalQuota"`
+	DailyQuota          int         `json:"dailyQuota"`
+	RedemptionsPerOrder int         `json:"redemptionsPerOrder"`
+	Venue               string      `json:"venue"`
+	RemainingSeats      *int64      `json:"remainingSeats"` // 剩余名额，不限量时为 null
 	CreatedAt           string      `json:"createdAt"`
 	UpdatedAt           string      `json:"updatedAt"`
 }
@@ -230,6 +277,10 @@ type CreateCampaignReq struct {
 	DistributionLevel   int         `json:"distributionLevel,optional"`   // 分销层级
 	DistributionRewards string      `json:"distributionRewards,optional"` // 分销奖励配置（JSON字符串）
 	PosterTemplateId    int64       `json:"posterTemplateId,optional"`    // 海报模板ID
+	TotalQuota          int         `json:"totalQuota,optional"`          // 总名额，0 表示不限
+	DailyQuota          int         `json:"dailyQuota,optional"`          // 每日名额，0 表示不限
+	RedemptionsPerOrder int         `json:"redemptionsPerOrder,optional"` // 每单可核销次数，默认 1
+	Venue               string      `json:"venue,optional"`               // 活动场地
 }
 
 type CreateMenuReq struct {
@@ -523,15 +574,34 @@ type MenuResp struct {
 	CreatedAt string     `json:"createdAt"`
 }
 
+type OfflineVerificationItem struct {
+	ClientId   string `json:"clientId"`   // 设备端核销ID，重复上传时去重
+	Code       string `json:"code"`       // 核销码或短码
+	VerifiedAt string `json:"verifiedAt"` // 设备核销时间，RFC3339
+}
+
+type OfflineVerificationResult struct {
+	ClientId   string `json:"clientId"`
+	OrderId    int64  `json:"orderId,omitempty"`
+	Status     string `json:"status"`           // applied, duplicate, conflict, invalid
+	Reason     string `json:"reason,omitempty"` // already_verified, refunded, cancelled, campaign_mismatch, invalid_code ...
+	Message    string `json:"message,omitempty"`
+	VerifiedAt string `json:"verifiedAt,omitempty"` // 生效的核销时间；冲突时为已有核销的时间
+}
+
 type OrderResp struct {
-	Id         int64             `json:"id"`
-	CampaignId int64             `json:"campaignId"`
-	Phone      string            `json:"phone"`
-	FormData   map[string]string `json:"formData"`
-	ReferrerId int64             `json:"referrerId"`
-	Status     string            `json:"status"`
-	Amount     float64           `json:"amount"`
-	CreatedAt  string            `json:"createdAt"`
+	Id               int64             `json:"id"`
+	CampaignId       int64             `json:"campaignId"`
+	Phone            string            `json:"phone"`
+	FormData         map[string]string `json:"formData"`
+	ReferrerId       int64             `json:"referrerId"`
+	Status           string            `json:"status"`
+	Amount           float64           `json:"amount"`
+	CreatedAt        string            `json:"createdAt"`
+	RedemptionQuota  int               `json:"redemptionQuota"`            // 可核销次数
+	RedeemedCount    int               `json:"redeemedCount"`              // 已核销次数
+	VerificationCode string            `json:"verificationCode,omitempty"` // 核销二维码内容，仅下单时返回
+	ShortCode        string            `json:"shortCode,omitempty"`        // 可手动输入的核销短码，仅下单时返回
 }
 
 type OrderListResp struct {
@@ -539,6 +609,20 @@ type OrderListResp struct {
 	Orders []OrderResp `json:"orders"`
 }
 
+type OrderPaymentQrcodeReq struct {
+	Id int64 `path:"id"`
+}
+
+type OrderPaymentQrcodeResp struct {
+	OrderId      int64   `json:"orderId"`
+	OutTradeNo   string  `json:"outTradeNo"`
+	Amount       float64 `json:"amount"`
+	CampaignName string  `json:"campaignName"`
+	CodeUrl      string  `json:"codeUrl"`      // 微信 Native 支付链接
+	QrcodeBase64 string  `json:"qrcodeBase64"` // 支付二维码（PNG data URI）
+	ExpireAt     string  `json:"expireAt"`
+}
+
 type PageConfigReq struct {
 	Id         int64                    `path:"id"`
 	Components []map[string]interface{} `json:"components"`
@@ -589,7 +673,7 @@ type PaymentCallbackReq struct {
 }
 
 type PaymentQrcodeResp struct {
-	QrcodeUrl    string  `json:"qrcodeUrl"`
+	QrcodeUrl    string  `json:"qrcodeUrl"` // 不再返回，见 /orders/:id/payment-qrcode
 	Amount       float64 `json:"amount"`
 	CampaignName string  `json:"campaignName"`
 }
@@ -648,6 +732,59 @@ type RefreshTokenResp struct {
 	Token string `json:"token"`
 }
 
+type RefundOrderReq struct {
+	Id       int64   `path:"id"`
+	RefundNo string  `json:"refundNo,optional"` // 退款单号（幂等键，不传则自动生成）
+	Amount   float64 `json:"amount,optional"`   // 退款金额，不传则退还剩余可退金额
+	Reason   string  `json:"reason,optional"`   // 退款原因
+}
+
+type RefundOrderResp struct {
+	OrderId        int64   `json:"orderId"`
+	RefundNo       string  `json:"refundNo"`
+	Amount         float64 `json:"amount"`
+	ClawbackAmount float64 `json:"clawbackAmount"` // 追回的分销奖励总额
+	Status         string  `json:"status"`         // pending: 微信处理中，以退款通知为准；succeeded: 已退款
+	RefundedAmount float64 `json:"refundedAmount"` // 订单累计已退款金额
+	PayStatus      string  `json:"payStatus"`
+	RefundedAt     string  `json:"refundedAt"`
+}
+
+type ReconcilePaymentsReq struct {
+	BillDate    string `json:"billDate,optional"`    // 账单日 YYYY-MM-DD，默认前一天
+	BillContent string `json:"billContent,optional"` // 上传的账单CSV，为空时从微信支付下载
+}
+
+type GetPaymentReconciliationReq struct {
+	Id int64 `path:"id"`
+}
+
+type PaymentReconciliationItemResp struct {
+	Type          string  `json:"type"` // missing, extra, amount_mismatch
+	OrderId       int64   `json:"orderId"`
+	TransactionId string  `json:"transactionId"`
+	OutTradeNo    string  `json:"outTradeNo"`
+	BillAmount    float64 `json:"billAmount"`
+	OrderAmount   float64 `json:"orderAmount"`
+	Repaired      bool    `json:"repaired"`
+	Remark        string  `json:"remark"`
+}
+
+type PaymentReconciliationResp struct {
+	Id            int64                           `json:"id"`
+	BillDate      string                          `json:"billDate"`
+	Source        string                          `json:"source"`
+	BillCount     int                             `json:"billCount"`
+	BillAmount    float64                         `json:"billAmount"`
+	MatchedCount  int                             `json:"matchedCount"`
+	MissingCount  int                             `json:"missingCount"`
+	ExtraCount    int                             `json:"extraCount"`
+	MismatchCount int                             `json:"mismatchCount"`
+	RepairedCount int                             `json:"repairedCount"`
+	Items         []PaymentReconciliationItemResp `json:"items"`
+	CreatedAt     string                          `json:"createdAt"`
+}
+
 type RegisterReq struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
@@ -707,16 +844,19 @@ type RoleResp struct {
 }
 
 type ScanOrderReq struct {
-	Code string `json:"code" form:"code" validate:"required"` // 核销码
+	Code       string `json:"code" form:"code" validate:"required"`           // 核销码或短码
+	CampaignId int64  `json:"campaignId,optional" form:"campaignId,optional"` // 短码所属活动，短码在多个活动中重复时必填
 }
 
 type ScanOrderResp struct {
-	OrderId   int64             `json:"orderId"`
-	Status    string            `json:"status"`
-	PayStatus string            `json:"payStatus"`
-	MemberId  *int64            `json:"memberId"`
-	Phone     string            `json:"phone"`
-	FormData  map[string]string `json:"formData"`
+	OrderId         int64             `json:"orderId"`
+	Status          string            `json:"status"`
+	PayStatus       string            `json:"payStatus"`
+	MemberId        *int64            `json:"memberId"`
+	Phone           string            `json:"phone"`
+	FormData        map[string]string `json:"formData"`
+	RedemptionQuota int               `json:"redemptionQuota"` // 可核销次数
+	RedeemedCount   int               `json:"redeemedCount"`   // 已核销次数
 }
 
 type SecurityEventListResp struct {
@@ -754,6 +894,12 @@ type SetDistributorLevelRewardsReq struct {
 	Rewards []SetDistributorLevelRewardReq `json:"rewards"`
 }
 
+type SetVerificationScopesReq struct {
+	BrandId int64                   `path:"id"`
+	UserId  int64                   `path:"userId"`
+	Scopes  []VerificationScopeItem `json:"scopes"` // 为空时取消限制
+}
+
 type SubordinateListResp struct {
 	Total        int64             `json:"total"`
 	Subordinates []SubordinateResp `json:"subordinates"`
@@ -769,16 +915,60 @@ type SubordinateResp struct {
 	CreatedAt     string  `json:"createdAt"`
 }
 
+type SyncBackfillReq struct {
+	Name          string   `json:"name,optional"`
+	Targets       []string `json:"targets,optional"`
+	StartDate     string   `json:"startDate,optional"`
+	EndDate       string   `json:"endDate,optional"`
+	BrandId       int64    `json:"brandId,optional"`
+	CampaignId    int64    `json:"campaignId,optional"`
+	BatchSize     int      `json:"batchSize,optional"`
+	RatePerSecond int      `json:"ratePerSecond,optional"`
+	DryRun        bool     `json:"dryRun,optional"`
+	Reset         bool     `json:"reset,optional"`
+}
+
+type SyncBackfillResp struct {
+	Name    string               `json:"name"`
+	DryRun  bool                 `json:"dryRun"`
+	Running bool                 `json:"running"`
+	Targets []SyncBackfillTarget `json:"targets"`
+}
+
+type SyncBackfillStatusReq struct {
+	Name string `path:"name"`
+}
+
+type SyncBackfillTarget struct {
+	Target      string  `json:"target"`
+	Status      string  `json:"status"`
+	SourceCount int64   `json:"sourceCount"`
+	SourceTotal int64   `json:"sourceTotal"`
+	TargetCount int64   `json:"targetCount"`
+	Consistent  bool    `json:"consistent"`
+	Synced      int64   `json:"synced"`
+	Failed      int64   `json:"failed"`
+	LastId      int64   `json:"lastId"`
+	FailedIds   []int64 `json:"failedIds,optional"`
+	LastError   string  `json:"lastError,optional"`
+}
+
 type SyncHealthResp struct {
 	Status   string                 `json:"status"`
 	Database map[string]interface{} `json:"database"`
 	Queue    map[string]interface{} `json:"queue"`
+	Worker   map[string]interface{} `json:"worker"`
+}
+
+type SyncOrderReq struct {
+	OrderId int64 `path:"orderId"`
 }
 
 type SyncStatsResp struct {
 	TotalSyncs   int64   `json:"totalSyncs"`
 	SuccessSyncs int64   `json:"successSyncs"`
 	FailedSyncs  int64   `json:"failedSyncs"`
+	PendingSyncs int64   `json:"pendingSyncs"`
 	SuccessRate  float64 `json:"successRate"`
 	AvgTime      string  `json:"avgTime"`
 }
@@ -792,13 +982,17 @@ type SyncStatusResp struct {
 }
 
 type UnverifyOrderReq struct {
-	Code   string `json:"code"   validate:"required"` // 核销码
-	Reason string `json:"reason,optional"`            // 取消原因
+	Code       string `json:"code"   validate:"required"` // 核销码或短码
+	CampaignId int64  `json:"campaignId,optional"`        // 短码所属活动
+	Reason     string `json:"reason,optional"`            // 取消原因
 }
 
 type UnverifyOrderResp struct {
-	OrderId int64  `json:"orderId"`
-	Status  string `json:"status"`
+	OrderId         int64  `json:"orderId"`
+	Status          string `json:"status"`
+	RedemptionNo    int    `json:"redemptionNo"` // 被撤销的核销次序
+	RedeemedCount   int    `json:"redeemedCount"`
+	RedemptionQuota int    `json:"redemptionQuota"`
 }
 
 type UpdateBrandReq struct {
@@ -828,6 +1022,10 @@ type UpdateCampaignReq struct {
 	DistributionLevel   *int        `json:"distributionLevel,optional"`   // 分销层级
 	DistributionRewards *string     `json:"distributionRewards,optional"` // 分销奖励配置（JSON字符串）
 	PosterTemplateId    *int64      `json:"posterTemplateId,optional"`    // 海报模板ID
+	TotalQuota          *int        `json:"totalQuota,optional"`          // 总名额，0 表示不限
+	DailyQuota          *int        `json:"dailyQuota,optional"`          // 每日名额，0 表示不限
+	RedemptionsPerOrder *int        `json:"redemptionsPerOrder,optional"` // 每单可核销次数，只影响之后的订单
+	Venue               *string     `json:"venue,optional"`               // 活动场地
 }
 
 type UpdateDistributorLevelReq struct {
@@ -921,24 +1119,158 @@ type VerificationRecordResp struct {
 	VerificationCode   string `json:"verificationCode"`
 	VerificationMethod string `json:"verificationMethod"`
 	Remark             string `json:"remark"`
+	RedemptionNo       int    `json:"redemptionNo"` // 第几次核销
+	DeviceId           string `json:"deviceId,omitempty"`
 	CreatedAt          string `json:"createdAt"`
 }
 
+type VerificationRecordsReq struct {
+	OrderId int64 `form:"orderId,optional"` // 指定订单时返回该订单的使用记录
+}
+
 type VerificationRecordsListResp struct {
 	Total   int64                    `json:"total"`
 	Records []VerificationRecordResp `json:"records"`
 }
 
+type VerificationScopeItem struct {
+	CampaignId int64  `json:"campaignId,optional"` // 0 表示品牌下所有活动
+	Venue      string `json:"venue,optional"`      // 空表示不限场地
+}
+
+type VerificationScopeListReq struct {
+	BrandId int64 `path:"id"`
+	UserId  int64 `form:"userId,optional"`
+}
+
+type VerificationScopeListResp struct {
+	Total  int64                   `json:"total"`
+	Scopes []VerificationScopeResp `json:"scopes"`
+}
+
+type VerificationScopeResp struct {
+	Id         int64  `json:"id"`
+	UserId     int64  `json:"userId"`
+	BrandId    int64  `json:"brandId"`
+	CampaignId int64  `json:"campaignId"`
+	Venue      string `json:"venue"`
+	CreatedBy  int64  `json:"createdBy"`
+	CreatedAt  string `json:"createdAt"`
+}
+
+type VerificationSnapshotOrder struct {
+	OrderId            int64  `json:"orderId"`
+	CodeHash           string `json:"codeHash"`                // SHA-256(核销码) 十六进制
+	ShortCodeHash      string `json:"shortCodeHash,omitempty"` // SHA-256(短码) 十六进制
+	Phone              string `json:"phone"`                   // 脱敏手机号
+	VerificationStatus string `json:"verificationStatus"`
+	RedemptionQuota    int    `json:"redemptionQuota"`
+	RedeemedCount      int    `json:"redeemedCount"`
+}
+
+type VerificationSnapshotReq struct {
+	CampaignId int64 `form:"campaignId"`
+}
+
+type VerificationSnapshotResp struct {
+	CampaignId  int64                       `json:"campaignId"`
+	GeneratedAt string                      `json:"generatedAt"`
+	ExpiresAt   string                      `json:"expiresAt"`
+	Orders      []VerificationSnapshotOrder `json:"orders"`
+	KeyId       string                      `json:"keyId"`
+	Signature   string                      `json:"signature"` // Ed25519 签名（base64），覆盖 keyId 与 signature 以外的字段
+}
+
 type VerifyOrderReq struct {
-	Code   string `json:"code" validate:"required"` // 核销码
-	Remark string `json:"remark,optional"`          // 核销备注
+	Code       string `json:"code" validate:"required"` // 核销码或短码
+	CampaignId int64  `json:"campaignId,optional"`      // 短码所属活动
+	Remark     string `json:"remark,optional"`          // 核销备注
 }
 
 type VerifyOrderResp struct {
-	OrderId    int64  `json:"orderId"`
-	Status     string `json:"status"`
-	VerifiedAt string `json:"verifiedAt"` // ⚠️ 临时使用 string，应改为 *time.Time
-	VerifiedBy *int64 `json:"verifiedBy"`
+	OrderId         int64  `json:"orderId"`
+	Status          string `json:"status"`
+	VerifiedAt      string `json:"verifiedAt"` // ⚠️ 临时使用 string，应改为 *time.Time
+	VerifiedBy      *int64 `json:"verifiedBy"`
+	RedemptionNo    int    `json:"redemptionNo"` // 本次为第几次核销，次数用完前状态为 partial
+	RedeemedCount   int    `json:"redeemedCount"`
+	RedemptionQuota int    `json:"redemptionQuota"`
+}
+
+type WebhookCreateReq struct {
+	BrandId     int64    `json:"brandId"`
+	Url         string   `json:"url"`
+	Events      []string `json:"events"`
+	Description string   `json:"description,optional"`
+}
+
+type WebhookDeliveryIdReq struct {
+	Id int64 `path:"id"`
+}
+
+type WebhookDeliveryListReq struct {
+	Id        int64  `path:"id"`
+	Status    string `form:"status,optional"`
+	EventType string `form:"eventType,optional"`
+	Page      int64  `form:"page,optional"`
+	PageSize  int64  `form:"pageSize,optional"`
+}
+
+type WebhookDeliveryListResp struct {
+	Total      int64                 `json:"total"`
+	Deliveries []WebhookDeliveryResp `json:"deliveries"`
+}
+
+type WebhookDeliveryResp struct {
+	Id             int64  `json:"id"`
+	EndpointId     int64  `json:"endpointId"`
+	EventId        string `json:"eventId"`
+	EventType      string `json:"eventType"`
+	Payload        string `json:"payload"`
+	Status         string `json:"status"`
+	Attempts       int    `json:"attempts"`
+	ResponseStatus int    `json:"responseStatus"`
+	ResponseBody   string `json:"responseBody"`
+	LastError      string `json:"lastError"`
+	DurationMs     int64  `json:"durationMs"`
+	RedeliveryOf   int64  `json:"redeliveryOf,omitempty"`
+	NextAttemptAt  string `json:"nextAttemptAt"`
+	DeliveredAt    string `json:"deliveredAt"`
+	CreatedAt      string `json:"createdAt"`
+}
+
+type WebhookIdReq struct {
+	Id int64 `path:"id"`
+}
+
+type WebhookListReq struct {
+	BrandId int64 `form:"brandId,optional"`
+}
+
+type WebhookListResp struct {
+	Total    int64         `json:"total"`
+	Webhooks []WebhookResp `json:"webhooks"`
+}
+
+type WebhookResp struct {
+	Id          int64    `json:"id"`
+	BrandId     int64    `json:"brandId"`
+	Url         string   `json:"url"`
+	Events      []string `json:"events"`
+	Status      string   `json:"status"`
+	Description string   `json:"description"`
+	Secret      string   `json:"secret,omitempty"` // 仅创建和轮换密钥时返回
+	CreatedAt   string   `json:"createdAt"`
+	UpdatedAt   string   `json:"updatedAt"`
+}
+
+type WebhookUpdateReq struct {
+	Id           int64    `path:"id"`
+	Url          string   `json:"url,optional"`
+	Events       []string `json:"events,optional"`
+	Status       string   `json:"status,optional"`
+	Description  *string  `json:"description,optional"`
+	RotateSecret bool     `json:"rotateSecret,optional"`
 }
 
 type WithdrawalApplyReq struct {
@@ -946,6 +1278,9 @@ type WithdrawalApplyReq struct {
 	BankName    string  `json:"bankName"`
 	BankAccount string  `json:"bankAccount"`
 	AccountName string  `json:"accountName"`
+	PayType     string  `json:"payType,optional"`     // bank/wechat，默认 bank
+	PayAccount  string  `json:"payAccount,optional"`  // 微信提现时为收款用户 openid
+	PayRealName string  `json:"payRealName,optional"` // 收款人实名，非空时微信打款强制校验
 }
 
 type WithdrawalListReq struct {
@@ -976,6 +1311,9 @@ type WithdrawalResp struct {
 	BankAccount string  `json:"bankAccount"`
 	AccountName string  `json:"accountName"`
 	Status      string  `json:"status"`
+	PayType     string  `json:"payType,optional"`
+	TradeNo     string  `json:"tradeNo,optional"`
+	PaidAt      string  `json:"paidAt,optional"`
 	Remark      string  `json:"remark,optional"`
 	ApprovedBy  int64   `json:"approvedBy,optional"`
 	ApprovedAt  string  `json:"approvedAt,optional"`
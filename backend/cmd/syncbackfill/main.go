@@ -0,0 +1,157 @@
+// syncbackfill 将历史订单与分销奖励回填到外部数据库，用于客户 ERP 接入或目标表被清空后的全量同步。
+//
+//	go run ./cmd/syncbackfill -f api/etc/dmh-api.yaml -start 2026-01-01 -end 2026-06-30 -dry-run
+//
+// 同名回填中断后再次执行会从检查点继续，-reset 从头开始。
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"dmh/common/syncadapter"
+
+	"github.com/zeromicro/go-zero/core/conf"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// config 只读取 API 配置文件中回填需要的部分
+type config struct {
+	Mysql struct {
+		DataSource string
+	}
+	ExternalSync struct {
+		MappingSource string                    `json:",default=config,options=config|db"`
+		Mapping       syncadapter.MappingConfig `json:",optional"`
+		Database      struct {
+			Type     string
+			Host     string
+			Port     int
+			User     string
+			Password string
+			Database string
+			Schema   string `json:",optional"`
+			Charset  string `json:",optional"`
+		}
+	}
+}
+
+func main() {
+	var (
+		configFile = flag.String("f", "api/etc/dmh-api.yaml", "API 配置文件")
+		dsn        = flag.String("dsn", "", "DMH 数据库连接，默认取配置文件中的 Mysql.DataSource")
+		name       = flag.String("name", syncadapter.DefaultBackfillName, "回填名称，同名回填从检查点继续")
+		targets    = flag.String("targets", "order,reward", "回填目标，逗号分隔")
+		start      = flag.String("start", "", "创建日期起始 YYYY-MM-DD")
+		end        = flag.String("end", "", "创建日期结束 YYYY-MM-DD（含当天）")
+		brandId    = flag.Int64("brand", 0, "只回填该品牌的数据")
+		campaignId = flag.Int64("campaign", 0, "只回填该活动的数据")
+		batchSize  = flag.Int("batch", 200, "每批读取的记录数")
+		rate       = flag.Int("rate", 0, "每秒最多写入的记录数，0 表示不限速")
+		dryRun     = flag.Bool("dry-run", false, "只统计源表与目标表记录数，不写入")
+		reset      = flag.Bool("reset", false, "丢弃检查点从头回填")
+	)
+	flag.Parse()
+
+	if err := run(*configFile, *dsn, syncadapter.BackfillOptions{
+		Name:          *name,
+		Targets:       splitTargets(*targets),
+		BrandId:       *brandId,
+		CampaignId:    *campaignId,
+		BatchSize:     *batchSize,
+		RatePerSecond: *rate,
+		DryRun:        *dryRun,
+		Reset:         *reset,
+	}, *start, *end); err != nil {
+		fmt.Fprintln(os.Stderr, "回填失败:", err)
+		os.Exit(1)
+	}
+}
+
+func run(configFile, dsn string, opts syncadapter.BackfillOptions, start, end string) error {
+	var c config
+	if err := conf.Load(configFile, &c); err != nil {
+		return fmt.Errorf("加载配置失败: %w", err)
+	}
+	if dsn == "" {
+		dsn = c.Mysql.DataSource
+	}
+
+	var err error
+	if opts.From, opts.To, err = syncadapter.ParseBackfillRange(start, end); err != nil {
+		return err
+	}
+
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		return fmt.Errorf("连接数据库失败: %w", err)
+	}
+
+	mapping := c.ExternalSync.Mapping
+	if c.ExternalSync.MappingSource == "db" {
+		if mapping, err = syncadapter.LoadMappingConfig(db); err != nil {
+			return err
+		}
+	}
+	adapter, err := syncadapter.NewSyncAdapter(syncadapter.ExternalSyncConfig{
+		Type:     c.ExternalSync.Database.Type,
+		Host:     c.ExternalSync.Database.Host,
+		Port:     c.ExternalSync.Database.Port,
+		User:     c.ExternalSync.Database.User,
+		Password: c.ExternalSync.Database.Password,
+		Database: c.ExternalSync.Database.Database,
+		Schema:   c.ExternalSync.Database.Schema,
+		Charset:  c.ExternalSync.Database.Charset,
+		Mapping:  mapping,
+	})
+	if err != nil {
+		return fmt.Errorf("连接外部数据库失败: %w", err)
+	}
+	defer adapter.Close()
+
+	// Ctrl+C 时保存检查点后退出
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	result, err := syncadapter.NewBackfiller(db, adapter).Run(ctx, opts)
+	if result != nil {
+		printResult(result)
+	}
+	return err
+}
+
+func splitTargets(value string) []string {
+	var targets []string
+	for _, target := range strings.Split(value, ",") {
+		if target = strings.TrimSpace(target); target != "" {
+			targets = append(targets, target)
+		}
+	}
+	return targets
+}
+
+func printResult(result *syncadapter.BackfillResult) {
+	mode := ""
+	if result.DryRun {
+		mode = "（预演）"
+	}
+	fmt.Printf("回填 %s%s\n", result.Name, mode)
+	fmt.Printf("%-8s %-10s %10s %10s %10s %10s %8s %10s\n", "目标", "状态", "匹配", "源表", "目标表", "已写入", "失败", "检查点")
+	for _, p := range result.Targets {
+		fmt.Printf("%-8s %-10s %10d %10d %10d %10d %8d %10d\n",
+			p.Target, p.Status, p.SourceCount, p.SourceTotal, p.TargetCount, p.Synced, p.Failed, p.LastId)
+		if p.TargetCount >= 0 && p.TargetCount != p.SourceTotal {
+			fmt.Printf("  目标表记录数与源表不一致：相差 %d\n", p.SourceTotal-p.TargetCount)
+		}
+		if len(p.FailedIds) > 0 {
+			fmt.Printf("  失败记录: %v\n  最近错误: %s\n", p.FailedIds, p.LastError)
+		}
+	}
+}
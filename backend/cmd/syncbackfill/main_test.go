@@ -0,0 +1,16 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitTargets(t *testing.T) {
+	got := splitTargets(" order, ,reward ")
+	if want := []string{"order", "reward"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("splitTargets() = %v, want %v", got, want)
+	}
+	if got := splitTargets(""); len(got) != 0 {
+		t.Fatalf("splitTargets(\"\") = %v, want empty", got)
+	}
+}
@@ -0,0 +1,116 @@
+// Package leasepoll 按租约轮询待处理记录：查询到期记录，以条件更新抢占处理租约后逐条处理。
+// 多实例部署时同一条记录同一时刻只会被一个实例处理，处理中断的记录在租约到期后重新被轮询到
+package leasepoll
+
+import (
+	"context"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/logx"
+	"gorm.io/gorm"
+)
+
+// Config 轮询配置
+type Config struct {
+	Name      string        // 日志中的Worker名称
+	Interval  time.Duration // 轮询间隔
+	BatchSize int           // 每轮最多处理的记录数
+	Lease     time.Duration // 处理租约，抢占时将下次处理时间推迟到租约到期
+	Column    string        // 下次处理时间列，默认 next_attempt_at
+}
+
+// DueQuery 返回 now 时刻到期的记录查询，抢占时在该查询上追加 id 条件做条件更新
+type DueQuery func(now time.Time) *gorm.DB
+
+// Handler 处理一条已抢占的记录，返回是否处理成功
+type Handler func(id int64) bool
+
+// Poller 租约轮询器
+type Poller struct {
+	config Config
+	due    DueQuery
+	handle Handler
+	logger logx.Logger
+	stop   chan struct{}
+}
+
+// New 创建租约轮询器
+func New(config Config, due DueQuery, handle Handler) *Poller {
+	if config.Interval <= 0 {
+		config.Interval = 5 * time.Second
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 50
+	}
+	if config.Lease <= 0 {
+		config.Lease = time.Minute
+	}
+	if config.Column == "" {
+		config.Column = "next_attempt_at"
+	}
+
+	return &Poller{
+		config: config,
+		due:    due,
+		handle: handle,
+		logger: logx.WithContext(context.Background()),
+		stop:   make(chan struct{}),
+	}
+}
+
+// Start 启动轮询，阻塞直到 Stop 被调用
+func (p *Poller) Start() {
+	p.logger.Infof("%s started", p.config.Name)
+
+	ticker := time.NewTicker(p.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		p.RunOnce()
+
+		select {
+		case <-p.stop:
+			p.logger.Infof("%s stopping...", p.config.Name)
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stop 停止轮询
+func (p *Poller) Stop() {
+	close(p.stop)
+}
+
+// RunOnce 处理一批到期的记录，返回处理成功的数量
+func (p *Poller) RunOnce() int {
+	now := time.Now()
+
+	var ids []int64
+	if err := p.due(now).
+		Order("id ASC").
+		Limit(p.config.BatchSize).
+		Pluck("id", &ids).Error; err != nil {
+		p.logger.Errorf("%s failed to query due records: %v", p.config.Name, err)
+		return 0
+	}
+
+	handled := 0
+	for _, id := range ids {
+		if p.claim(id, now) && p.handle(id) {
+			handled++
+		}
+	}
+
+	return handled
+}
+
+// claim 以条件更新抢占记录并设置处理租约
+func (p *Poller) claim(id int64, now time.Time) bool {
+	result := p.due(now).Where("id = ?", id).Update(p.config.Column, now.Add(p.config.Lease))
+	if result.Error != nil {
+		p.logger.Errorf("%s failed to claim record: id=%d, err=%v", p.config.Name, id, result.Error)
+		return false
+	}
+	return result.RowsAffected == 1
+}
@@ -0,0 +1,26 @@
+package leasepoll
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew_Defaults(t *testing.T) {
+	p := New(Config{Name: "TestPoller"}, nil, nil)
+
+	assert.Equal(t, 5*time.Second, p.config.Interval)
+	assert.Equal(t, 50, p.config.BatchSize)
+	assert.Equal(t, time.Minute, p.config.Lease)
+	assert.Equal(t, "next_attempt_at", p.config.Column)
+}
+
+func TestNew_KeepsConfig(t *testing.T) {
+	p := New(Config{Interval: time.Second, BatchSize: 10, Lease: time.Hour, Column: "next_payout_at"}, nil, nil)
+
+	assert.Equal(t, time.Second, p.config.Interval)
+	assert.Equal(t, 10, p.config.BatchSize)
+	assert.Equal(t, time.Hour, p.config.Lease)
+	assert.Equal(t, "next_payout_at", p.config.Column)
+}
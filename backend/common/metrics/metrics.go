@@ -0,0 +1,155 @@
+package metrics
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "dmh"
+
+// Registry 本服务的指标注册表，与 go-zero 内置的默认注册表分开，避免指标重名
+var Registry = prometheus.NewRegistry()
+
+var (
+	// HTTPRequests 按路由模板与状态码统计的请求数
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route and status code.",
+	}, []string{"method", "route", "code"})
+
+	// HTTPDuration 按路由模板统计的请求耗时
+	HTTPDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method and route.",
+		Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"method", "route"})
+
+	// SyncOperations 外部数据库同步次数，result 为 success/failure
+	SyncOperations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "sync_operations_total",
+		Help:      "External database sync operations by type and result.",
+	}, []string{"type", "result"})
+
+	// SyncDuration 外部数据库同步耗时
+	SyncDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "sync_duration_seconds",
+		Help:      "External database sync latency by type.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"type"})
+
+	// PaymentCallbacks 支付通知处理结果，result 与支付通知留档的状态一致
+	PaymentCallbacks = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "payment_callbacks_total",
+		Help:      "Payment notifications by provider and outcome.",
+	}, []string{"provider", "result"})
+
+	// PosterDuration 海报生成耗时
+	PosterDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "poster_generate_duration_seconds",
+		Help:      "Poster generation latency by poster type and result.",
+		Buckets:   []float64{.05, .1, .25, .5, 1, 2, 5, 10},
+	}, []string{"type", "result"})
+
+	// RateLimitRejections 被限流拒绝的请求数
+	RateLimitRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limit_rejections_total",
+		Help:      "Requests rejected by rate limiters.",
+	}, []string{"limiter"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests,
+		HTTPDuration,
+		SyncOperations,
+		SyncDuration,
+		PaymentCallbacks,
+		PosterDuration,
+		RateLimitRejections,
+	)
+}
+
+// Handler 以 Prometheus 文本格式输出 Registry 中的指标，token 非空时要求 Bearer 令牌
+func Handler(token string) http.HandlerFunc {
+	h := promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+	return func(w http.ResponseWriter, r *http.Request) {
+		if token != "" {
+			got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+		}
+		h.ServeHTTP(w, r)
+	}
+}
+
+// Result 将 err 转为 success/failure 标签值
+func Result(err error) string {
+	if err != nil {
+		return "failure"
+	}
+	return "success"
+}
+
+// RegisterDB 导出数据库连接池状态，name 区分不同的连接池
+func RegisterDB(db *sql.DB, name string) error {
+	return register(collectors.NewDBStatsCollector(db, name))
+}
+
+// RegisterSyncQueue 导出同步队列深度，采集时读取队列长度，读取失败时不输出
+func RegisterSyncQueue(pending, dead func() (int64, error)) error {
+	return register(&queueCollector{
+		desc: prometheus.NewDesc(prometheus.BuildFQName(namespace, "sync", "queue_depth"),
+			"Tasks waiting in the external sync queue.", []string{"queue"}, nil),
+		lengths: map[string]func() (int64, error){"pending": pending, "dead": dead},
+	})
+}
+
+// register 重复注册时忽略，便于测试中多次初始化
+func register(c prometheus.Collector) error {
+	err := Registry.Register(c)
+	var already prometheus.AlreadyRegisteredError
+	if errors.As(err, &already) {
+		return nil
+	}
+	return err
+}
+
+type queueCollector struct {
+	desc    *prometheus.Desc
+	lengths map[string]func() (int64, error)
+}
+
+func (c *queueCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *queueCollector) Collect(ch chan<- prometheus.Metric) {
+	for queue, length := range c.lengths {
+		if length == nil {
+			continue
+		}
+		n, err := length()
+		if err != nil {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(n), queue)
+	}
+}
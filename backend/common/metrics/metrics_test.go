@@ -0,0 +1,48 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func scrape(t *testing.T, h http.HandlerFunc, auth string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	resp := httptest.NewRecorder()
+	h(resp, req)
+	return resp
+}
+
+func TestHandler_Token(t *testing.T) {
+	assert.Equal(t, http.StatusOK, scrape(t, Handler(""), "").Code)
+
+	h := Handler("scrape-secret")
+	assert.Equal(t, http.StatusUnauthorized, scrape(t, h, "").Code)
+	assert.Equal(t, http.StatusUnauthorized, scrape(t, h, "Bearer wrong").Code)
+	assert.Equal(t, http.StatusOK, scrape(t, h, "Bearer scrape-secret").Code)
+}
+
+func TestRegisterSyncQueue(t *testing.T) {
+	pending := func() (int64, error) { return 7, nil }
+	dead := func() (int64, error) { return 0, errors.New("redis down") }
+	assert.NoError(t, RegisterSyncQueue(pending, dead))
+	// 重复注册不报错
+	assert.NoError(t, RegisterSyncQueue(pending, dead))
+
+	body := scrape(t, Handler(""), "").Body.String()
+	assert.Contains(t, body, `dmh_sync_queue_depth{queue="pending"} 7`)
+	assert.NotContains(t, body, `queue="dead"`)
+	assert.Contains(t, body, "go_goroutines")
+}
+
+func TestResult(t *testing.T) {
+	assert.Equal(t, "success", Result(nil))
+	assert.Equal(t, "failure", Result(errors.New("x")))
+}
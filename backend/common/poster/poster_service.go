@@ -1,12 +1,16 @@
 package poster
 
 import (
+	"encoding/base64"
 	"fmt"
 	"image/color"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
+	"dmh/common/metrics"
+
 	"github.com/fogleman/gg"
 	"github.com/skip2/go-qrcode"
 )
@@ -32,6 +36,13 @@ func NewService(posterDir, baseURL string) *Service {
 
 // GenerateCampaignPoster 生成活动专属海报
 func (s *Service) GenerateCampaignPoster(campaignName, campaignDesc, distributorName, qrcodeData string) (string, error) {
+	start := time.Now()
+	posterURL, err := s.generateCampaignPoster(campaignName, campaignDesc, distributorName, qrcodeData)
+	metrics.PosterDuration.WithLabelValues("campaign", metrics.Result(err)).Observe(time.Since(start).Seconds())
+	return posterURL, err
+}
+
+func (s *Service) generateCampaignPoster(campaignName, campaignDesc, distributorName, qrcodeData string) (string, error) {
 	fmt.Printf("[PosterService] GenerateCampaignPoster called: name=%s, desc=%s, distributor=%s\n", campaignName, campaignDesc, distributorName)
 
 	// 1. 创建画布（海报尺寸：750x1334 px，即微信朋友圈图片尺寸）
@@ -97,6 +108,13 @@ func (s *Service) GenerateCampaignPoster(campaignName, campaignDesc, distributor
 
 // GenerateDistributorPoster 生成通用分销商海报
 func (s *Service) GenerateDistributorPoster(distributorName string, campaignCount int) (string, error) {
+	start := time.Now()
+	posterURL, err := s.generateDistributorPoster(distributorName, campaignCount)
+	metrics.PosterDuration.WithLabelValues("distributor", metrics.Result(err)).Observe(time.Since(start).Seconds())
+	return posterURL, err
+}
+
+func (s *Service) generateDistributorPoster(distributorName string, campaignCount int) (string, error) {
 	// 1. 创建画布
 	width := 750
 	height := 1334
@@ -243,11 +261,18 @@ func (s *Service) generateQRCode(data string) (string, error) {
 	return tmpPath, nil
 }
 
-// GenerateQRCodeAsBase64 生成二维码并返回base64编码
+// GenerateQRCodeAsBase64 生成二维码PNG并返回 data URI 形式的base64编码
 func (s *Service) GenerateQRCodeAsBase64(data string) (string, error) {
-	// 简化处理：实际应该使用png.Encode
-	// 这里返回一个占位符
-	return "data:image/png;base64,iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mNk+M9QDwADhgGAWjR9awAAAABJRU5ErkJggg==", nil
+	if strings.TrimSpace(data) == "" {
+		return "", fmt.Errorf("二维码内容不能为空")
+	}
+
+	png, err := qrcode.Encode(data, qrcode.Medium, 256)
+	if err != nil {
+		return "", fmt.Errorf("生成二维码失败: %w", err)
+	}
+
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(png), nil
 }
 
 func generateUniqueID() int64 {
@@ -1,8 +1,11 @@
 package poster
 
 import (
+	"bytes"
+	"encoding/base64"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -79,6 +82,13 @@ func (suite *PosterServiceTestSuite) TestGenerateQRCodeAsBase64() {
 	assert.NoError(suite.T(), err)
 	assert.NotEmpty(suite.T(), result)
 	assert.Contains(suite.T(), result, "data:image/png;base64,")
+
+	png, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(result, "data:image/png;base64,"))
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), bytes.HasPrefix(png, []byte("\x89PNG")))
+
+	_, err = suite.service.GenerateQRCodeAsBase64("")
+	assert.Error(suite.T(), err)
 }
 
 func (suite *PosterServiceTestSuite) TestGenerateQRCode() {
@@ -0,0 +1,586 @@
+package syncadapter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"dmh/model"
+
+	"github.com/zeromicro/go-zero/core/logx"
+	"gorm.io/gorm"
+)
+
+// 回填检查点状态
+const (
+	BackfillStatusRunning   = "running"
+	BackfillStatusPaused    = "paused" // 被取消或出错中断，可按名称续跑
+	BackfillStatusCompleted = "completed"
+)
+
+// DefaultBackfillName 未指定名称时使用的检查点名称
+const DefaultBackfillName = "default"
+
+const (
+	defaultBackfillBatchSize = 200
+	maxBackfillBatchSize     = 1000
+	maxBackfillFailedIds     = 100
+	defaultBackfillLease     = 10 * time.Minute
+)
+
+// ErrBackfillRunning 同名回填的检查点租约被占用，正由本实例或其他实例执行
+var ErrBackfillRunning = errors.New("backfill is already running")
+
+// BackfillOptions 历史数据回填参数
+type BackfillOptions struct {
+	Name          string    // 检查点名称，同名回填从上次中断处继续
+	Targets       []string  // order, reward；为空时两者都回填
+	From          time.Time // 创建时间下限（含），零值不限
+	To            time.Time // 创建时间上限（不含），零值不限
+	BrandId       int64     // 只回填该品牌活动下的数据
+	CampaignId    int64     // 只回填该活动的数据
+	BatchSize     int       // 每批读取的记录数
+	RatePerSecond int       // 每秒最多写入的记录数，0 表示不限速
+	DryRun        bool      // 只统计源表与目标表记录数，不写入也不记录检查点
+	Reset         bool      // 丢弃已有检查点，从头回填
+}
+
+// backfillFilter 写入检查点的筛选条件，续跑时必须与上次一致
+type backfillFilter struct {
+	From       string `json:"from,omitempty"`
+	To         string `json:"to,omitempty"`
+	BrandId    int64  `json:"brandId,omitempty"`
+	CampaignId int64  `json:"campaignId,omitempty"`
+}
+
+// normalize 填充默认值并校验参数
+func (o BackfillOptions) normalize() (BackfillOptions, error) {
+	if o.Name == "" {
+		o.Name = DefaultBackfillName
+	}
+	if len(o.Name) > 64 {
+		return o, fmt.Errorf("backfill name is too long: %s", o.Name)
+	}
+	if len(o.Targets) == 0 {
+		o.Targets = []string{MappingTargetOrder, MappingTargetReward}
+	}
+	for _, target := range o.Targets {
+		if target != MappingTargetOrder && target != MappingTargetReward {
+			return o, fmt.Errorf("unknown backfill target %q", target)
+		}
+	}
+	if !o.From.IsZero() && !o.To.IsZero() && !o.To.After(o.From) {
+		return o, errors.New("backfill end time must be after start time")
+	}
+	if o.BatchSize <= 0 {
+		o.BatchSize = defaultBackfillBatchSize
+	}
+	if o.BatchSize > maxBackfillBatchSize {
+		o.BatchSize = maxBackfillBatchSize
+	}
+	if o.RatePerSecond < 0 {
+		o.RatePerSecond = 0
+	}
+	return o, nil
+}
+
+// filter 筛选条件的 JSON 表示
+func (o BackfillOptions) filter() string {
+	f := backfillFilter{BrandId: o.BrandId, CampaignId: o.CampaignId}
+	if !o.From.IsZero() {
+		f.From = o.From.Format(time.RFC3339)
+	}
+	if !o.To.IsZero() {
+		f.To = o.To.Format(time.RFC3339)
+	}
+	data, _ := json.Marshal(f)
+	return string(data)
+}
+
+// BackfillProgress 单个目标的回填进度与核对结果
+type BackfillProgress struct {
+	Target      string
+	Status      string
+	SourceCount int64   // 源表中符合筛选条件的记录数
+	SourceTotal int64   // 源表全部记录数
+	TargetCount int64   // 目标表记录数，无法统计时为 -1
+	Synced      int64   // 累计写入条数（含之前中断的运行）
+	Failed      int64   // 累计写入失败条数
+	LastId      int64   // 检查点：已处理到的源记录ID
+	FailedIds   []int64 // 本次运行写入失败的记录ID（最多 100 条）
+	LastError   string
+}
+
+// BackfillResult 回填结果
+type BackfillResult struct {
+	Name    string
+	DryRun  bool
+	Targets []BackfillProgress
+}
+
+// Backfiller 将历史订单与分销奖励按批通过 SyncAdapter 写入外部数据库。
+// 执行前抢占检查点行上的租约，多实例部署时同一回填同一时刻只会在一处执行
+type Backfiller struct {
+	db      *gorm.DB
+	adapter *SyncAdapter
+	logger  logx.Logger
+	owner   string        // 租约持有者前缀：主机名-进程号
+	lease   time.Duration // 检查点执行租约，每批写完后续期
+	runs    atomic.Int64
+}
+
+// backfillRun 一次回填持有的检查点租约
+type backfillRun struct {
+	owner       string
+	lease       time.Duration
+	checkpoints map[string]*model.SyncBackfillCheckpoint
+}
+
+// NewBackfiller 创建回填器
+func NewBackfiller(db *gorm.DB, adapter *SyncAdapter) *Backfiller {
+	host, _ := os.Hostname()
+	return &Backfiller{
+		db:      db,
+		adapter: adapter,
+		logger:  logx.WithContext(context.Background()),
+		owner:   fmt.Sprintf("%s-%d", host, os.Getpid()),
+		lease:   defaultBackfillLease,
+	}
+}
+
+// Running 是否有回填持有未到期的租约，包括其他实例上的回填
+func (b *Backfiller) Running() bool {
+	var count int64
+	if err := b.db.Model(&model.SyncBackfillCheckpoint{}).Where("lease_until > ?", time.Now()).Count(&count).Error; err != nil {
+		b.logger.Errorf("Failed to check backfill leases: %v", err)
+		return false
+	}
+	return count > 0
+}
+
+// Run 执行回填并等待完成；DryRun 时只统计记录数
+func (b *Backfiller) Run(ctx context.Context, opts BackfillOptions) (*BackfillResult, error) {
+	opts, err := opts.normalize()
+	if err != nil {
+		return nil, err
+	}
+	if opts.DryRun {
+		return b.run(ctx, opts, nil)
+	}
+
+	run, err := b.acquire(opts)
+	if err != nil {
+		return nil, err
+	}
+	return b.run(ctx, opts, run)
+}
+
+// Start 在后台执行回填，进度通过 Progress 查询
+func (b *Backfiller) Start(opts BackfillOptions) error {
+	opts, err := opts.normalize()
+	if err != nil {
+		return err
+	}
+	if opts.DryRun {
+		return errors.New("dry run should use Run")
+	}
+
+	// 租约在返回前抢占，冲突时调用方能立即得到 ErrBackfillRunning
+	run, err := b.acquire(opts)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		if _, err := b.run(context.Background(), opts, run); err != nil {
+			b.logger.Errorf("Backfill %s stopped: %v", opts.Name, err)
+		}
+	}()
+	return nil
+}
+
+// Progress 按检查点返回回填进度，并附上源表与目标表的记录数
+func (b *Backfiller) Progress(ctx context.Context, name string) (*BackfillResult, error) {
+	var checkpoints []model.SyncBackfillCheckpoint
+	if err := b.db.Where("name = ?", name).Order("id ASC").Find(&checkpoints).Error; err != nil {
+		return nil, err
+	}
+
+	result := &BackfillResult{Name: name}
+	for _, cp := range checkpoints {
+		progress := BackfillProgress{
+			Target:    cp.Target,
+			Status:    cp.Status,
+			Synced:    cp.Synced,
+			Failed:    cp.Failed,
+			LastId:    cp.LastId,
+			LastError: cp.LastError,
+		}
+		var f backfillFilter
+		_ = json.Unmarshal([]byte(cp.Filter), &f)
+		opts := BackfillOptions{BrandId: f.BrandId, CampaignId: f.CampaignId}
+		opts.From, _ = time.Parse(time.RFC3339, f.From)
+		opts.To, _ = time.Parse(time.RFC3339, f.To)
+		b.count(ctx, &progress, opts)
+		result.Targets = append(result.Targets, progress)
+	}
+	return result, nil
+}
+
+func (b *Backfiller) run(ctx context.Context, opts BackfillOptions, run *backfillRun) (*BackfillResult, error) {
+	if run != nil {
+		defer b.release(run)
+	}
+
+	result := &BackfillResult{Name: opts.Name, DryRun: opts.DryRun}
+	pace := newPacer(opts.RatePerSecond)
+
+	for _, target := range opts.Targets {
+		progress, err := b.runTarget(ctx, target, opts, pace, run)
+		result.Targets = append(result.Targets, progress)
+		if err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// runTarget 回填单个目标：按ID递增分批读取，每批写完后保存检查点
+func (b *Backfiller) runTarget(ctx context.Context, target string, opts BackfillOptions, pace *pacer, run *backfillRun) (BackfillProgress, error) {
+	progress := BackfillProgress{Target: target, Status: BackfillStatusRunning}
+
+	if opts.DryRun {
+		b.count(ctx, &progress, opts)
+		progress.Status = ""
+		return progress, nil
+	}
+
+	cp := run.checkpoints[target]
+	progress.LastId = cp.LastId
+	progress.Synced = cp.Synced
+	progress.Failed = cp.Failed
+
+	if cp.Status != BackfillStatusCompleted {
+		b.logger.Infof("Backfill %s/%s started from id %d", opts.Name, target, cp.LastId)
+		err := b.copyBatches(ctx, target, opts, pace, run, cp, &progress)
+		progress.LastId, progress.Synced, progress.Failed = cp.LastId, cp.Synced, cp.Failed
+
+		updates := map[string]interface{}{
+			"last_id": cp.LastId,
+			"synced":  cp.Synced,
+			"failed":  cp.Failed,
+		}
+		if err != nil {
+			updates["status"] = BackfillStatusPaused
+			updates["last_error"] = truncateError(err.Error(), 500)
+		} else {
+			updates["status"] = BackfillStatusCompleted
+			updates["last_error"] = truncateError(progress.LastError, 500)
+			updates["finished_at"] = time.Now()
+		}
+		if dbErr := b.db.Model(&model.SyncBackfillCheckpoint{}).Where("id = ? AND lease_owner = ?", cp.Id, run.owner).Updates(updates).Error; dbErr != nil {
+			b.logger.Errorf("Failed to save backfill checkpoint: name=%s, target=%s, err=%v", opts.Name, target, dbErr)
+		}
+		if err != nil {
+			progress.Status = BackfillStatusPaused
+			progress.LastError = err.Error()
+			return progress, err
+		}
+	}
+
+	progress.Status = BackfillStatusCompleted
+	b.count(ctx, &progress, opts)
+	b.logger.Infof("Backfill %s/%s completed: synced=%d, failed=%d, source=%d, target=%d",
+		opts.Name, target, progress.Synced, progress.Failed, progress.SourceTotal, progress.TargetCount)
+	return progress, nil
+}
+
+// acquire 为每个目标读取或新建检查点并抢占租约，任一目标失败时释放已抢占的租约
+func (b *Backfiller) acquire(opts BackfillOptions) (*backfillRun, error) {
+	run := &backfillRun{
+		owner:       fmt.Sprintf("%s-%d", b.owner, b.runs.Add(1)),
+		lease:       b.leaseFor(opts),
+		checkpoints: make(map[string]*model.SyncBackfillCheckpoint, len(opts.Targets)),
+	}
+	for _, target := range opts.Targets {
+		cp, err := b.checkpoint(run, opts.Name, target, opts)
+		if cp != nil {
+			run.checkpoints[target] = cp
+		}
+		if err != nil {
+			b.release(run)
+			return nil, err
+		}
+	}
+	return run, nil
+}
+
+// leaseFor 租约至少覆盖限速下写完两批的时间，避免慢速回填在两次续期之间失去租约
+func (b *Backfiller) leaseFor(opts BackfillOptions) time.Duration {
+	lease := b.lease
+	if opts.RatePerSecond > 0 {
+		if batch := 2 * time.Duration(opts.BatchSize) * time.Second / time.Duration(opts.RatePerSecond); batch > lease {
+			lease = batch
+		}
+	}
+	return lease
+}
+
+// release 释放本次回填持有的租约
+func (b *Backfiller) release(run *backfillRun) {
+	for target, cp := range run.checkpoints {
+		if err := b.db.Model(&model.SyncBackfillCheckpoint{}).Where("id = ? AND lease_owner = ?", cp.Id, run.owner).Updates(map[string]interface{}{
+			"lease_owner": "",
+			"lease_until": nil,
+		}).Error; err != nil {
+			b.logger.Errorf("Failed to release backfill lease: name=%s, target=%s, err=%v", cp.Name, target, err)
+		}
+	}
+}
+
+// checkpoint 读取或新建检查点并以条件更新抢占租约；租约未到期时返回 ErrBackfillRunning，
+// 筛选条件与已有检查点不一致时拒绝续跑。抢占成功后即使返回错误也会返回检查点，便于释放租约
+func (b *Backfiller) checkpoint(run *backfillRun, name, target string, opts BackfillOptions) (*model.SyncBackfillCheckpoint, error) {
+	filter := opts.filter()
+
+	var cp model.SyncBackfillCheckpoint
+	err := b.db.Where("name = ? AND target = ?", name, target).First(&cp).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		cp = model.SyncBackfillCheckpoint{Name: name, Target: target, Filter: filter, Status: BackfillStatusRunning}
+		if err = b.db.Create(&cp).Error; err != nil {
+			// 其他实例同时新建了同名检查点，以已有记录为准，由租约决定谁执行
+			err = b.db.Where("name = ? AND target = ?", name, target).First(&cp).Error
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load backfill checkpoint failed: %w", err)
+	}
+
+	now := time.Now()
+	result := b.db.Model(&model.SyncBackfillCheckpoint{}).
+		Where("id = ? AND (lease_until IS NULL OR lease_until <= ?)", cp.Id, now).
+		Updates(map[string]interface{}{
+			"lease_owner": run.owner,
+			"lease_until": now.Add(run.lease),
+		})
+	if result.Error != nil {
+		return nil, fmt.Errorf("claim backfill checkpoint failed: %w", result.Error)
+	}
+	if result.RowsAffected != 1 {
+		return nil, fmt.Errorf("%w: %s/%s", ErrBackfillRunning, name, target)
+	}
+	// 抢占前读到的进度可能已被上一个持有者更新，以抢占后的记录为准
+	if err := b.db.First(&cp, cp.Id).Error; err != nil {
+		return &cp, fmt.Errorf("load backfill checkpoint failed: %w", err)
+	}
+
+	if opts.Reset {
+		if err := b.db.Model(&cp).Updates(map[string]interface{}{
+			"filter":      filter,
+			"last_id":     0,
+			"synced":      0,
+			"failed":      0,
+			"status":      BackfillStatusRunning,
+			"last_error":  "",
+			"finished_at": nil,
+		}).Error; err != nil {
+			return &cp, fmt.Errorf("reset backfill checkpoint failed: %w", err)
+		}
+		cp.Filter, cp.LastId, cp.Synced, cp.Failed, cp.Status = filter, 0, 0, 0, BackfillStatusRunning
+		return &cp, nil
+	}
+
+	if cp.Filter != filter {
+		return &cp, fmt.Errorf("backfill %s/%s was started with filter %s, reset it to use %s", name, target, cp.Filter, filter)
+	}
+	if cp.Status != BackfillStatusCompleted {
+		if err := b.db.Model(&cp).Update("status", BackfillStatusRunning).Error; err != nil {
+			return &cp, err
+		}
+	}
+	return &cp, nil
+}
+
+// copyBatches 从检查点之后分批写入，每批结束保存一次检查点并续期租约；租约已被其他实例接管时停止
+func (b *Backfiller) copyBatches(ctx context.Context, target string, opts BackfillOptions, pace *pacer, run *backfillRun, cp *model.SyncBackfillCheckpoint, progress *BackfillProgress) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		n, err := b.syncBatch(ctx, target, opts, pace, cp, progress)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return nil
+		}
+
+		result := b.db.Model(&model.SyncBackfillCheckpoint{}).Where("id = ? AND lease_owner = ?", cp.Id, run.owner).Updates(map[string]interface{}{
+			"last_id":     cp.LastId,
+			"synced":      cp.Synced,
+			"failed":      cp.Failed,
+			"lease_until": time.Now().Add(run.lease),
+		})
+		if result.Error != nil {
+			return fmt.Errorf("save backfill checkpoint failed: %w", result.Error)
+		}
+		if result.RowsAffected != 1 {
+			return fmt.Errorf("backfill %s/%s lease was taken over by another instance", cp.Name, target)
+		}
+	}
+}
+
+// syncBatch 读取并写入一批记录，返回本批读取的条数
+func (b *Backfiller) syncBatch(ctx context.Context, target string, opts BackfillOptions, pace *pacer, cp *model.SyncBackfillCheckpoint, progress *BackfillProgress) (int, error) {
+	query := b.sourceQuery(target, opts).Where("id > ?", cp.LastId).Order("id ASC").Limit(opts.BatchSize)
+
+	// 单条写入失败只记录，不中断回填
+	record := func(id int64, err error) {
+		cp.LastId = id
+		if err == nil {
+			cp.Synced++
+			return
+		}
+		cp.Failed++
+		if len(progress.FailedIds) < maxBackfillFailedIds {
+			progress.FailedIds = append(progress.FailedIds, id)
+		}
+		progress.LastError = err.Error()
+		b.logger.Errorf("Backfill %s %d failed: %v", target, id, err)
+	}
+
+	switch target {
+	case MappingTargetOrder:
+		var orders []model.Order
+		if err := query.Find(&orders).Error; err != nil {
+			return 0, fmt.Errorf("query orders failed: %w", err)
+		}
+		for i := range orders {
+			if err := pace.wait(ctx); err != nil {
+				return i, err
+			}
+			record(orders[i].Id, b.adapter.SyncOrder(ctx, orderSyncData(&orders[i])))
+		}
+		return len(orders), nil
+	default:
+		var rewards []model.DistributorReward
+		if err := query.Find(&rewards).Error; err != nil {
+			return 0, fmt.Errorf("query distributor rewards failed: %w", err)
+		}
+		for i := range rewards {
+			if err := pace.wait(ctx); err != nil {
+				return i, err
+			}
+			record(rewards[i].Id, b.adapter.SyncReward(ctx, distributorRewardSyncData(&rewards[i])))
+		}
+		return len(rewards), nil
+	}
+}
+
+// sourceQuery 源表中符合筛选条件的记录
+func (b *Backfiller) sourceQuery(target string, opts BackfillOptions) *gorm.DB {
+	query := b.baseQuery(target)
+	if !opts.From.IsZero() {
+		query = query.Where("created_at >= ?", opts.From)
+	}
+	if !opts.To.IsZero() {
+		query = query.Where("created_at < ?", opts.To)
+	}
+	if opts.CampaignId > 0 {
+		query = query.Where("campaign_id = ?", opts.CampaignId)
+	}
+	if opts.BrandId > 0 {
+		query = query.Where("campaign_id IN (?)", b.db.Model(&model.Campaign{}).Select("id").Where("brand_id = ?", opts.BrandId))
+	}
+	return query
+}
+
+// baseQuery 源表全部记录
+func (b *Backfiller) baseQuery(target string) *gorm.DB {
+	if target == MappingTargetOrder {
+		return b.db.Model(&model.Order{}).Where("deleted_at IS NULL")
+	}
+	return b.db.Model(&model.DistributorReward{})
+}
+
+// count 统计源表与目标表的记录数，用于回填后的核对
+func (b *Backfiller) count(ctx context.Context, progress *BackfillProgress, opts BackfillOptions) {
+	if err := b.sourceQuery(progress.Target, opts).Count(&progress.SourceCount).Error; err != nil {
+		b.logger.Errorf("Failed to count backfill source: target=%s, err=%v", progress.Target, err)
+	}
+	if err := b.baseQuery(progress.Target).Count(&progress.SourceTotal).Error; err != nil {
+		b.logger.Errorf("Failed to count backfill source: target=%s, err=%v", progress.Target, err)
+	}
+
+	progress.TargetCount = -1
+	if b.adapter == nil {
+		return
+	}
+	count, err := b.adapter.TargetCount(ctx, progress.Target)
+	if err != nil {
+		b.logger.Errorf("Failed to count backfill target: target=%s, err=%v", progress.Target, err)
+		return
+	}
+	progress.TargetCount = count
+}
+
+// pacer 按固定间隔放行写入，实现每秒最多 N 条的限速
+type pacer struct {
+	interval time.Duration
+	next     time.Time
+}
+
+func newPacer(ratePerSecond int) *pacer {
+	if ratePerSecond <= 0 {
+		return &pacer{}
+	}
+	return &pacer{interval: time.Second / time.Duration(ratePerSecond)}
+}
+
+// wait 等待到下一次允许写入的时间
+func (p *pacer) wait(ctx context.Context) error {
+	if p.interval <= 0 {
+		return ctx.Err()
+	}
+
+	now := time.Now()
+	if p.next.Before(now) {
+		p.next = now
+	}
+	delay := p.next.Sub(now)
+	p.next = p.next.Add(p.interval)
+	if delay <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// ParseBackfillRange 解析 YYYY-MM-DD 格式的起止日期（含结束当天），为空表示不限
+func ParseBackfillRange(startDate, endDate string) (from, to time.Time, err error) {
+	if startDate = strings.TrimSpace(startDate); startDate != "" {
+		if from, err = time.ParseInLocation("2006-01-02", startDate, time.Local); err != nil {
+			return from, to, fmt.Errorf("invalid start date %q", startDate)
+		}
+	}
+	if endDate = strings.TrimSpace(endDate); endDate != "" {
+		if to, err = time.ParseInLocation("2006-01-02", endDate, time.Local); err != nil {
+			return from, to, fmt.Errorf("invalid end date %q", endDate)
+		}
+		to = to.AddDate(0, 0, 1)
+	}
+	return from, to, nil
+}
@@ -0,0 +1,182 @@
+package syncadapter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"dmh/model"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+func TestBackfillOptions_Normalize(t *testing.T) {
+	opts, err := BackfillOptions{BatchSize: 5000, RatePerSecond: -1}.normalize()
+	require.NoError(t, err)
+	assert.Equal(t, DefaultBackfillName, opts.Name)
+	assert.Equal(t, []string{MappingTargetOrder, MappingTargetReward}, opts.Targets)
+	assert.Equal(t, maxBackfillBatchSize, opts.BatchSize)
+	assert.Equal(t, 0, opts.RatePerSecond)
+
+	opts, err = BackfillOptions{}.normalize()
+	require.NoError(t, err)
+	assert.Equal(t, defaultBackfillBatchSize, opts.BatchSize)
+
+	_, err = BackfillOptions{Targets: []string{"withdrawal"}}.normalize()
+	assert.Error(t, err)
+
+	day := time.Date(2026, 10, 1, 0, 0, 0, 0, time.Local)
+	_, err = BackfillOptions{From: day, To: day}.normalize()
+	assert.Error(t, err)
+}
+
+func TestBackfillOptions_FilterIdentifiesScope(t *testing.T) {
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	a := BackfillOptions{From: from, BrandId: 3}.filter()
+	b := BackfillOptions{From: from, BrandId: 3, BatchSize: 50, RatePerSecond: 10}.filter()
+	c := BackfillOptions{From: from, BrandId: 4}.filter()
+
+	// 批大小与限速不影响续跑，筛选条件不同则视为另一次回填
+	assert.Equal(t, a, b)
+	assert.NotEqual(t, a, c)
+	assert.Equal(t, `{}`, BackfillOptions{}.filter())
+}
+
+func TestParseBackfillRange(t *testing.T) {
+	from, to, err := ParseBackfillRange("2026-01-01", "2026-01-31")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 1, 1, 0, 0, 0, 0, time.Local), from)
+	assert.Equal(t, time.Date(2026, 2, 1, 0, 0, 0, 0, time.Local), to)
+
+	from, to, err = ParseBackfillRange("", " ")
+	require.NoError(t, err)
+	assert.True(t, from.IsZero())
+	assert.True(t, to.IsZero())
+
+	_, _, err = ParseBackfillRange("2026/01/01", "")
+	assert.Error(t, err)
+}
+
+func TestPacer(t *testing.T) {
+	assert.NoError(t, newPacer(0).wait(context.Background()))
+
+	p := newPacer(50)
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		require.NoError(t, p.wait(context.Background()))
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 35*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Error(t, newPacer(1).wait(ctx))
+}
+
+func TestSyncAdapter_TargetCount(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+
+	adapter := &SyncAdapter{
+		db:      sqlDB,
+		config:  ExternalSyncConfig{Type: "oracle", Schema: "ERP"},
+		mapper:  NewFieldMapper(),
+		metrics: NewSyncMetrics(),
+		logger:  logx.WithContext(context.Background()),
+	}
+
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM ERP\.external_orders`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(42))
+
+	count, err := adapter.TargetCount(context.Background(), MappingTargetOrder)
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), count)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	_, err = adapter.TargetCount(context.Background(), MappingTargetWithdrawal)
+	assert.Error(t, err)
+}
+
+func TestBackfiller_RunResumesFromCheckpoint(t *testing.T) {
+	db := setupSyncWorkerTestDB(t)
+	require.NoError(t, db.AutoMigrate(&model.SyncBackfillCheckpoint{}))
+	require.NoError(t, db.Exec("DELETE FROM sync_backfill_checkpoints").Error)
+	require.NoError(t, db.Exec("DELETE FROM orders").Error)
+	for id := int64(1); id <= 3; id++ {
+		require.NoError(t, db.Create(&model.Order{Id: id, CampaignId: 9, Phone: "13800138000", FormData: "{}", PayStatus: "paid"}).Error)
+	}
+
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+	adapter := &SyncAdapter{db: sqlDB, mapper: NewFieldMapper(), metrics: NewSyncMetrics(), logger: logx.WithContext(context.Background())}
+	backfiller := NewBackfiller(db, adapter)
+	opts := BackfillOptions{Name: "resume", Targets: []string{MappingTargetOrder}, CampaignId: 9, BatchSize: 2}
+
+	// 上次运行中断在第 2 条
+	require.NoError(t, db.Create(&model.SyncBackfillCheckpoint{
+		Name: "resume", Target: MappingTargetOrder, Filter: opts.filter(), LastId: 2, Synced: 2, Status: BackfillStatusPaused,
+	}).Error)
+
+	// 续跑只写入剩余的一条
+	mock.ExpectExec("INSERT INTO external_orders").WithArgs(int64(3), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+		sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM external_orders`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+	result, err := backfiller.Run(context.Background(), opts)
+	require.NoError(t, err)
+	assert.Equal(t, BackfillStatusCompleted, result.Targets[0].Status)
+	assert.Equal(t, int64(3), result.Targets[0].Synced)
+	assert.Equal(t, int64(3), result.Targets[0].TargetCount)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	var cp model.SyncBackfillCheckpoint
+	require.NoError(t, db.Where("name = ? AND target = ?", "resume", MappingTargetOrder).First(&cp).Error)
+	assert.Equal(t, int64(3), cp.LastId)
+	assert.Equal(t, BackfillStatusCompleted, cp.Status)
+
+	// 筛选条件变化时拒绝续跑
+	_, err = backfiller.Run(context.Background(), BackfillOptions{Name: "resume", Targets: []string{MappingTargetOrder}, CampaignId: 10})
+	assert.Error(t, err)
+}
+
+func TestBackfiller_LeaseExcludesConcurrentRuns(t *testing.T) {
+	db := setupSyncWorkerTestDB(t)
+	require.NoError(t, db.AutoMigrate(&model.SyncBackfillCheckpoint{}))
+	require.NoError(t, db.Exec("DELETE FROM sync_backfill_checkpoints").Error)
+	require.NoError(t, db.Exec("DELETE FROM orders").Error)
+
+	backfiller := NewBackfiller(db, nil)
+	opts := BackfillOptions{Name: "lease", Targets: []string{MappingTargetOrder}}
+
+	// 其他实例持有未到期的租约
+	leaseUntil := time.Now().Add(time.Minute)
+	require.NoError(t, db.Create(&model.SyncBackfillCheckpoint{
+		Name: "lease", Target: MappingTargetOrder, Filter: "{}", Status: BackfillStatusRunning,
+		LeaseOwner: "other-host-1-1", LeaseUntil: &leaseUntil,
+	}).Error)
+	assert.True(t, backfiller.Running())
+
+	_, err := backfiller.Run(context.Background(), opts)
+	assert.ErrorIs(t, err, ErrBackfillRunning)
+	assert.ErrorIs(t, backfiller.Start(opts), ErrBackfillRunning)
+
+	// 租约到期后可以接管，完成后释放租约
+	expired := time.Now().Add(-time.Second)
+	require.NoError(t, db.Model(&model.SyncBackfillCheckpoint{}).Where("name = ?", "lease").Update("lease_until", expired).Error)
+
+	result, err := backfiller.Run(context.Background(), opts)
+	require.NoError(t, err)
+	assert.Equal(t, BackfillStatusCompleted, result.Targets[0].Status)
+
+	var cp model.SyncBackfillCheckpoint
+	require.NoError(t, db.Where("name = ? AND target = ?", "lease", MappingTargetOrder).First(&cp).Error)
+	assert.Empty(t, cp.LeaseOwner)
+	assert.Nil(t, cp.LeaseUntil)
+	assert.False(t, backfiller.Running())
+}
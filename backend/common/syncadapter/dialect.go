@@ -0,0 +1,211 @@
+package syncadapter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// upsertSpec 按主键幂等写入外部表的语句描述
+type upsertSpec struct {
+	Table          string
+	KeyColumns     []string // 判断记录是否已存在的列
+	Columns        []string // 写入的列，顺序与绑定参数一致
+	UpdateColumns  []string // 记录已存在时更新的列
+	SyncedAtColumn string   // 写入数据库当前时间的同步时间列
+}
+
+// dialect 外部数据库的 SQL 方言
+type dialect interface {
+	// Placeholder 第 n 个绑定参数（从 1 开始）
+	Placeholder(n int) string
+	// Upsert 生成插入或更新语句
+	Upsert(schema string, spec upsertSpec) string
+	// ColumnsQuery 查询目标表列名的语句，schema 为空时查询当前连接的默认 Schema
+	ColumnsQuery(schema, table string) (string, []interface{})
+	// SelectAfter 按 (orderColumn, keyColumn) 递增读取最多 limit 行，keyColumn 为空时只按 orderColumn 排序；
+	// after 为 true 时只读取游标之后的行，绑定参数见 afterClause
+	SelectAfter(schema, table string, columns []string, orderColumn, keyColumn string, after bool, limit int) string
+}
+
+// dialectFor 根据外部数据库类型选择方言，未设置类型时按 MySQL 处理
+func dialectFor(dbType string) (dialect, error) {
+	switch strings.ToLower(strings.TrimSpace(dbType)) {
+	case "", "mysql":
+		return mysqlDialect{}, nil
+	case "oracle":
+		return oracleDialect{}, nil
+	case "sqlserver":
+		return sqlServerDialect{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported database type: %s", dbType)
+	}
+}
+
+func qualifiedTable(schema, table string) string {
+	if schema = strings.TrimSpace(schema); schema != "" {
+		return schema + "." + table
+	}
+	return table
+}
+
+func placeholders(d dialect, n int) []string {
+	binds := make([]string, n)
+	for i := range binds {
+		binds[i] = d.Placeholder(i + 1)
+	}
+	return binds
+}
+
+// mysqlDialect INSERT ... ON DUPLICATE KEY UPDATE，依赖外部表上的主键或唯一索引
+type mysqlDialect struct{}
+
+func (mysqlDialect) Placeholder(int) string {
+	return "?"
+}
+
+func (d mysqlDialect) Upsert(schema string, spec upsertSpec) string {
+	columns := spec.Columns
+	values := placeholders(d, len(spec.Columns))
+	updates := make([]string, 0, len(spec.UpdateColumns)+1)
+	for _, col := range spec.UpdateColumns {
+		updates = append(updates, fmt.Sprintf("%s = VALUES(%s)", col, col))
+	}
+	if spec.SyncedAtColumn != "" {
+		columns = append(columns[:len(columns):len(columns)], spec.SyncedAtColumn)
+		values = append(values, "NOW()")
+		updates = append(updates, spec.SyncedAtColumn+" = NOW()")
+	}
+	if len(updates) == 0 {
+		// 没有需要更新的列时保持原记录不变
+		updates = append(updates, fmt.Sprintf("%s = %s", spec.KeyColumns[0], spec.KeyColumns[0]))
+	}
+
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) ON DUPLICATE KEY UPDATE %s",
+		qualifiedTable(schema, spec.Table),
+		strings.Join(columns, ", "),
+		strings.Join(values, ", "),
+		strings.Join(updates, ", "))
+}
+
+func (mysqlDialect) ColumnsQuery(schema, table string) (string, []interface{}) {
+	if schema == "" {
+		return "SELECT column_name FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = ?", []interface{}{table}
+	}
+	return "SELECT column_name FROM information_schema.columns WHERE table_schema = ? AND table_name = ?", []interface{}{schema, table}
+}
+
+func (d mysqlDialect) SelectAfter(schema, table string, columns []string, orderColumn, keyColumn string, after bool, limit int) string {
+	return fmt.Sprintf("SELECT %s FROM %s%s ORDER BY %s LIMIT %d",
+		strings.Join(columns, ", "), qualifiedTable(schema, table), afterClause(d, orderColumn, keyColumn, after), orderBy(orderColumn, keyColumn), limit)
+}
+
+// oracleDialect MERGE INTO ... USING (SELECT ... FROM dual)，参数按 :1、:2 绑定
+type oracleDialect struct{}
+
+func (oracleDialect) Placeholder(n int) string {
+	return fmt.Sprintf(":%d", n)
+}
+
+func (d oracleDialect) Upsert(schema string, spec upsertSpec) string {
+	return mergeStatement(d, qualifiedTable(schema, spec.Table), spec, " FROM dual", "SYSTIMESTAMP", "")
+}
+
+// ColumnsQuery 未加引号的 Oracle 标识符以大写存储在数据字典中
+func (oracleDialect) ColumnsQuery(schema, table string) (string, []interface{}) {
+	if schema == "" {
+		return "SELECT column_name FROM user_tab_columns WHERE table_name = :1", []interface{}{strings.ToUpper(table)}
+	}
+	return "SELECT column_name FROM all_tab_columns WHERE owner = :1 AND table_name = :2", []interface{}{strings.ToUpper(schema), strings.ToUpper(table)}
+}
+
+// SelectAfter 使用 Oracle 12c 起支持的 FETCH FIRST 限制行数
+func (d oracleDialect) SelectAfter(schema, table string, columns []string, orderColumn, keyColumn string, after bool, limit int) string {
+	return fmt.Sprintf("SELECT %s FROM %s%s ORDER BY %s FETCH FIRST %d ROWS ONLY",
+		strings.Join(columns, ", "), qualifiedTable(schema, table), afterClause(d, orderColumn, keyColumn, after), orderBy(orderColumn, keyColumn), limit)
+}
+
+// sqlServerDialect MERGE ... WITH (HOLDLOCK)，参数按 @p1、@p2 绑定；MERGE 语句必须以分号结尾
+type sqlServerDialect struct{}
+
+func (sqlServerDialect) Placeholder(n int) string {
+	return fmt.Sprintf("@p%d", n)
+}
+
+func (d sqlServerDialect) Upsert(schema string, spec upsertSpec) string {
+	// 加范围锁，避免并发写入同一主键时两条 MERGE 都走插入分支
+	return mergeStatement(d, qualifiedTable(schema, spec.Table)+" WITH (HOLDLOCK)", spec, "", "SYSDATETIME()", ";")
+}
+
+func (sqlServerDialect) ColumnsQuery(schema, table string) (string, []interface{}) {
+	if schema == "" {
+		return "SELECT column_name FROM information_schema.columns WHERE table_schema = SCHEMA_NAME() AND table_name = @p1", []interface{}{table}
+	}
+	return "SELECT column_name FROM information_schema.columns WHERE table_schema = @p1 AND table_name = @p2", []interface{}{schema, table}
+}
+
+func (d sqlServerDialect) SelectAfter(schema, table string, columns []string, orderColumn, keyColumn string, after bool, limit int) string {
+	return fmt.Sprintf("SELECT TOP (%d) %s FROM %s%s ORDER BY %s",
+		limit, strings.Join(columns, ", "), qualifiedTable(schema, table), afterClause(d, orderColumn, keyColumn, after), orderBy(orderColumn, keyColumn))
+}
+
+// afterClause 只有水位列时绑定 1 个参数（水位）；带行键时为复合游标，依次绑定水位、水位、行键，
+// 水位相同的行按行键继续读取，不会因水位重复而漏读
+func afterClause(d dialect, column, keyColumn string, after bool) string {
+	if !after {
+		return ""
+	}
+	if keyColumn == "" {
+		return fmt.Sprintf(" WHERE %s > %s", column, d.Placeholder(1))
+	}
+	return fmt.Sprintf(" WHERE (%s > %s OR (%s = %s AND %s > %s))",
+		column, d.Placeholder(1), column, d.Placeholder(2), keyColumn, d.Placeholder(3))
+}
+
+func orderBy(column, keyColumn string) string {
+	if keyColumn == "" {
+		return column
+	}
+	return column + ", " + keyColumn
+}
+
+// mergeStatement 生成 Oracle 与 SQL Server 通用的 MERGE 语句
+func mergeStatement(d dialect, target string, spec upsertSpec, fromClause, now, terminator string) string {
+	binds := placeholders(d, len(spec.Columns))
+	source := make([]string, len(spec.Columns))
+	for i, col := range spec.Columns {
+		source[i] = fmt.Sprintf("%s AS %s", binds[i], col)
+	}
+
+	on := make([]string, len(spec.KeyColumns))
+	for i, col := range spec.KeyColumns {
+		on[i] = fmt.Sprintf("t.%s = s.%s", col, col)
+	}
+
+	updates := make([]string, 0, len(spec.UpdateColumns)+1)
+	for _, col := range spec.UpdateColumns {
+		updates = append(updates, fmt.Sprintf("t.%s = s.%s", col, col))
+	}
+
+	columns := append([]string{}, spec.Columns...)
+	values := make([]string, len(spec.Columns))
+	for i, col := range spec.Columns {
+		values[i] = "s." + col
+	}
+
+	if spec.SyncedAtColumn != "" {
+		updates = append(updates, fmt.Sprintf("t.%s = %s", spec.SyncedAtColumn, now))
+		columns = append(columns, spec.SyncedAtColumn)
+		values = append(values, now)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "MERGE INTO %s t USING (SELECT %s%s) s ON (%s) ",
+		target, strings.Join(source, ", "), fromClause, strings.Join(on, " AND "))
+	// 没有需要更新的列时只插入新记录
+	if len(updates) > 0 {
+		fmt.Fprintf(&b, "WHEN MATCHED THEN UPDATE SET %s ", strings.Join(updates, ", "))
+	}
+	fmt.Fprintf(&b, "WHEN NOT MATCHED THEN INSERT (%s) VALUES (%s)%s",
+		strings.Join(columns, ", "), strings.Join(values, ", "), terminator)
+	return b.String()
+}
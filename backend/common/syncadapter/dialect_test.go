@@ -0,0 +1,148 @@
+package syncadapter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+func TestDialectFor(t *testing.T) {
+	for _, dbType := range []string{"", "mysql", "MySQL", "oracle", "sqlserver"} {
+		d, err := dialectFor(dbType)
+		assert.NoError(t, err, dbType)
+		assert.NotNil(t, d, dbType)
+	}
+
+	_, err := dialectFor("postgres")
+	assert.Error(t, err)
+}
+
+func TestMySQLDialect_Upsert(t *testing.T) {
+	d, _ := dialectFor("mysql")
+
+	assert.Equal(t,
+		"INSERT INTO external_rewards (reward_id, user_id, member_id, order_id, amount, status, settled_at, synced_at) "+
+			"VALUES (?, ?, ?, ?, ?, ?, ?, NOW()) "+
+			"ON DUPLICATE KEY UPDATE member_id = VALUES(member_id), amount = VALUES(amount), status = VALUES(status), "+
+			"settled_at = VALUES(settled_at), synced_at = NOW()",
+		d.Upsert("", DefaultMappingConfig().Reward.upsertSpec()))
+
+	assert.Contains(t, d.Upsert("erp", DefaultMappingConfig().Order.upsertSpec()), "INSERT INTO erp.external_orders (")
+}
+
+func TestOracleDialect_Upsert(t *testing.T) {
+	d, _ := dialectFor("oracle")
+
+	assert.Equal(t,
+		"MERGE INTO ERP.external_rewards t USING (SELECT :1 AS reward_id, :2 AS user_id, :3 AS member_id, :4 AS order_id, "+
+			":5 AS amount, :6 AS status, :7 AS settled_at FROM dual) s ON (t.reward_id = s.reward_id) "+
+			"WHEN MATCHED THEN UPDATE SET t.member_id = s.member_id, t.amount = s.amount, t.status = s.status, "+
+			"t.settled_at = s.settled_at, t.synced_at = SYSTIMESTAMP "+
+			"WHEN NOT MATCHED THEN INSERT (reward_id, user_id, member_id, order_id, amount, status, settled_at, synced_at) "+
+			"VALUES (s.reward_id, s.user_id, s.member_id, s.order_id, s.amount, s.status, s.settled_at, SYSTIMESTAMP)",
+		d.Upsert("ERP", DefaultMappingConfig().Reward.upsertSpec()))
+
+	assert.Contains(t, d.Upsert("", DefaultMappingConfig().Order.upsertSpec()), ":9 AS created_at FROM dual")
+}
+
+func TestSQLServerDialect_Upsert(t *testing.T) {
+	d, _ := dialectFor("sqlserver")
+
+	assert.Equal(t,
+		"MERGE INTO dbo.external_rewards WITH (HOLDLOCK) t USING (SELECT @p1 AS reward_id, @p2 AS user_id, @p3 AS member_id, "+
+			"@p4 AS order_id, @p5 AS amount, @p6 AS status, @p7 AS settled_at) s ON (t.reward_id = s.reward_id) "+
+			"WHEN MATCHED THEN UPDATE SET t.member_id = s.member_id, t.amount = s.amount, t.status = s.status, "+
+			"t.settled_at = s.settled_at, t.synced_at = SYSDATETIME() "+
+			"WHEN NOT MATCHED THEN INSERT (reward_id, user_id, member_id, order_id, amount, status, settled_at, synced_at) "+
+			"VALUES (s.reward_id, s.user_id, s.member_id, s.order_id, s.amount, s.status, s.settled_at, SYSDATETIME());",
+		d.Upsert("dbo", DefaultMappingConfig().Reward.upsertSpec()))
+
+	assert.Contains(t, d.Upsert("", DefaultMappingConfig().Order.upsertSpec()), "@p9 AS created_at) s ON (t.order_id = s.order_id)")
+}
+
+func TestDialect_SelectAfter(t *testing.T) {
+	columns := []string{"id", "change_type"}
+	mysql, _ := dialectFor("mysql")
+	oracle, _ := dialectFor("oracle")
+	sqlServer, _ := dialectFor("sqlserver")
+
+	assert.Equal(t, "SELECT id, change_type FROM external_changes ORDER BY id LIMIT 50",
+		mysql.SelectAfter("", "external_changes", columns, "id", "", false, 50))
+	assert.Equal(t, "SELECT id, change_type FROM ERP.external_changes WHERE id > :1 ORDER BY id FETCH FIRST 50 ROWS ONLY",
+		oracle.SelectAfter("ERP", "external_changes", columns, "id", "", true, 50))
+	assert.Equal(t, "SELECT TOP (50) id, change_type FROM dbo.external_changes WHERE id > @p1 ORDER BY id",
+		sqlServer.SelectAfter("dbo", "external_changes", columns, "id", "", true, 50))
+
+	// 复合游标：水位相同的行按行键继续读取
+	assert.Equal(t, "SELECT id, change_type FROM external_changes WHERE (updated_at > ? OR (updated_at = ? AND id > ?)) ORDER BY updated_at, id LIMIT 50",
+		mysql.SelectAfter("", "external_changes", columns, "updated_at", "id", true, 50))
+	assert.Equal(t, "SELECT TOP (50) id, change_type FROM dbo.external_changes WHERE (updated_at > @p1 OR (updated_at = @p2 AND id > @p3)) ORDER BY updated_at, id",
+		sqlServer.SelectAfter("dbo", "external_changes", columns, "updated_at", "id", true, 50))
+}
+
+func TestSyncOrder_SQLServerUsesMerge(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	adapter := &SyncAdapter{
+		db:      db,
+		config:  ExternalSyncConfig{Type: "sqlserver", Schema: "dbo"},
+		mapper:  NewFieldMapper(),
+		metrics: NewSyncMetrics(),
+		logger:  logx.WithContext(context.Background()),
+	}
+
+	mock.ExpectExec(sqlServerDialect{}.Upsert("dbo", DefaultMappingConfig().Order.upsertSpec())).
+		WithArgs(int64(1), int64(2), int64(0), "", "13800138000", "{}", 9.9, "paid", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = adapter.SyncOrder(context.Background(), &SyncOrderData{
+		OrderId:    1,
+		CampaignId: 2,
+		Phone:      "13800138000",
+		FormData:   map[string]interface{}{},
+		Amount:     9.9,
+		PayStatus:  "paid",
+		CreatedAt:  time.Now(),
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSyncReward_OracleUsesMerge(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	adapter := &SyncAdapter{
+		db:      db,
+		config:  ExternalSyncConfig{Type: "oracle"},
+		mapper:  NewFieldMapper(),
+		metrics: NewSyncMetrics(),
+		logger:  logx.WithContext(context.Background()),
+	}
+
+	mock.ExpectExec(oracleDialect{}.Upsert("", DefaultMappingConfig().Reward.upsertSpec())).
+		WithArgs(int64(10), int64(20), int64(0), int64(30), 5.5, "settled", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = adapter.SyncReward(context.Background(), &SyncRewardData{
+		RewardId:  10,
+		UserId:    20,
+		OrderId:   30,
+		Amount:    5.5,
+		Status:    "settled",
+		SettledAt: time.Now(),
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
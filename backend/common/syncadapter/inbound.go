@@ -0,0 +1,421 @@
+package syncadapter
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"dmh/model"
+
+	"github.com/zeromicro/go-zero/core/logx"
+	"gorm.io/gorm"
+)
+
+// 外部变更类型
+const (
+	InboundOrderRefunded   = "order_refunded"   // 外部系统退款，按正常退款流程处理
+	InboundOrderVerified   = "order_verified"   // 外部系统核销
+	InboundOrderUnverified = "order_unverified" // 外部系统取消核销
+	InboundMemberUpdated   = "member_updated"   // 会员手机号或状态变更
+)
+
+// 外部变更的处理结果
+const (
+	InboundStatusApplied  = "applied"
+	InboundStatusSkipped  = "skipped"  // 本地已是目标状态
+	InboundStatusConflict = "conflict" // 本地在外部变更之后修改过，按冲突规则未应用
+	InboundStatusRejected = "rejected" // 不满足业务规则，如订单不存在
+)
+
+// 双方都修改过同一记录时的冲突规则
+const (
+	ConflictLatestWins   = "latest_wins"   // 以变更时间较晚的一方为准
+	ConflictExternalWins = "external_wins" // 始终以外部系统为准
+)
+
+var inboundChangeTypes = []string{InboundOrderRefunded, InboundOrderVerified, InboundOrderUnverified, InboundMemberUpdated}
+
+// InboundMapping 外部变更表的列映射，除表名、水位列与类型列外均可不配置
+type InboundMapping struct {
+	Table           string            `json:",optional"`
+	WatermarkColumn string            `json:",optional"` // 单调递增的列，如自增ID或更新时间
+	KeyColumn       string            `json:",optional"` // 行的唯一键，水位列可能重复（如更新时间）时必须配置，与水位组成复合游标
+	TypeColumn      string            `json:",optional"` // 变更类型列
+	TypeMap         map[string]string `json:",optional"` // 外部类型取值与变更类型的对照，未配置时取值须为变更类型本身
+	OrderIdColumn   string            `json:",optional"`
+	MemberIdColumn  string            `json:",optional"`
+	PhoneColumn     string            `json:",optional"` // 会员手机号
+	StatusColumn    string            `json:",optional"` // 会员状态 active/disabled
+	AmountColumn    string            `json:",optional"` // 退款金额，为空时全额退款
+	ReferenceColumn string            `json:",optional"` // 外部单号，如退款单号
+	ChangedAtColumn string            `json:",optional"` // 外部系统的变更时间，用于冲突判断
+}
+
+// DefaultInboundMapping 默认从 external_changes 读取变更，列名与字段一致
+func DefaultInboundMapping() InboundMapping {
+	return InboundMapping{
+		Table:           "external_changes",
+		WatermarkColumn: "id",
+		TypeColumn:      "change_type",
+		OrderIdColumn:   "order_id",
+		MemberIdColumn:  "member_id",
+		PhoneColumn:     "phone",
+		StatusColumn:    "member_status",
+		AmountColumn:    "amount",
+		ReferenceColumn: "reference_no",
+		ChangedAtColumn: "changed_at",
+	}
+}
+
+// WithDefaults 未配置变更表时使用默认映射
+func (m InboundMapping) WithDefaults() InboundMapping {
+	if strings.TrimSpace(m.Table) == "" {
+		return DefaultInboundMapping()
+	}
+	return m
+}
+
+// Validate 检查列名与类型对照是否有效
+func (m InboundMapping) Validate() error {
+	for name, column := range map[string]string{"table": m.Table, "watermark column": m.WatermarkColumn, "type column": m.TypeColumn} {
+		if !identifierPattern.MatchString(column) {
+			return fmt.Errorf("inbound mapping: invalid %s %q", name, column)
+		}
+	}
+	for _, column := range append(m.optionalColumns(), m.KeyColumn) {
+		if column != "" && !identifierPattern.MatchString(column) {
+			return fmt.Errorf("inbound mapping: invalid column %q", column)
+		}
+	}
+	if m.OrderIdColumn == "" && m.MemberIdColumn == "" {
+		return errors.New("inbound mapping: order id column or member id column is required")
+	}
+	for external, changeType := range m.TypeMap {
+		if !containsString(inboundChangeTypes, changeType) {
+			return fmt.Errorf("inbound mapping: type %q maps to unknown change type %q", external, changeType)
+		}
+	}
+	return nil
+}
+
+func (m InboundMapping) optionalColumns() []string {
+	return []string{m.OrderIdColumn, m.MemberIdColumn, m.PhoneColumn, m.StatusColumn, m.AmountColumn, m.ReferenceColumn, m.ChangedAtColumn}
+}
+
+// keyColumn 复合游标的行键列，未配置或与水位列相同时为空，水位本身即唯一键
+func (m InboundMapping) keyColumn() string {
+	if m.KeyColumn == m.WatermarkColumn {
+		return ""
+	}
+	return m.KeyColumn
+}
+
+// columns 读取的列，前两列固定为水位与类型，配置了行键时第三列为行键
+func (m InboundMapping) columns() []string {
+	columns := []string{m.WatermarkColumn, m.TypeColumn}
+	if key := m.keyColumn(); key != "" {
+		columns = append(columns, key)
+	}
+	for _, column := range m.optionalColumns() {
+		if column != "" {
+			columns = append(columns, column)
+		}
+	}
+	return columns
+}
+
+// InboundChange 外部变更表的一行
+type InboundChange struct {
+	Watermark string
+	Key       string // 行键，未配置行键列时与水位相同
+	Type      string
+	OrderId   int64
+	MemberId  int64
+	Phone     string
+	Status    string
+	Amount    float64
+	Reference string
+	ChangedAt time.Time // 外部变更时间，未配置或为空时为零值
+	Raw       map[string]string
+}
+
+// InboundResult 一条变更的处理结果
+type InboundResult struct {
+	Status string
+	Detail string
+}
+
+// InboundApplier 按业务规则应用外部变更并记录审计。
+// 返回 error 表示暂时性故障（如数据库不可用），导入停在该条变更下次重试；业务规则不允许时返回 rejected
+type InboundApplier interface {
+	Apply(ctx context.Context, change *InboundChange, policy string) (InboundResult, error)
+}
+
+// ResolveConflict 本地记录最后修改于 localChangedAt 时判断是否应用外部变更，conflict 为 true 表示双方都修改过
+func ResolveConflict(policy string, change *InboundChange, localChangedAt *time.Time) (apply bool, conflict bool) {
+	if localChangedAt == nil || change.ChangedAt.IsZero() || !localChangedAt.After(change.ChangedAt) {
+		return true, false
+	}
+	return policy == ConflictExternalWins, true
+}
+
+// FetchChanges 读取游标 (after, afterKey) 之后的变更，after 为空时从头读取；未配置行键列时忽略 afterKey
+func (s *SyncAdapter) FetchChanges(ctx context.Context, mapping InboundMapping, after, afterKey string, limit int) ([]*InboundChange, error) {
+	d, err := dialectFor(s.config.Type)
+	if err != nil {
+		return nil, err
+	}
+	columns := mapping.columns()
+	keyColumn := mapping.keyColumn()
+	query := d.SelectAfter(s.config.Schema, mapping.Table, columns, mapping.WatermarkColumn, keyColumn, after != "", limit)
+
+	var args []interface{}
+	if after != "" {
+		args = append(args, watermarkArg(after))
+		if keyColumn != "" {
+			args = append(args, watermarkArg(after), watermarkArg(afterKey))
+		}
+	}
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query inbound changes failed: %w", err)
+	}
+	defer rows.Close()
+
+	var changes []*InboundChange
+	for rows.Next() {
+		values := make([]sql.NullString, len(columns))
+		dest := make([]interface{}, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("scan inbound change failed: %w", err)
+		}
+
+		raw := make(map[string]string, len(columns))
+		for i, column := range columns {
+			raw[column] = values[i].String
+		}
+		change := mapping.parse(raw)
+		// 时间水位可能重复，只按水位推进会漏读同一时刻的行
+		if keyColumn == "" {
+			if _, ok := watermarkArg(change.Watermark).(time.Time); ok {
+				return nil, fmt.Errorf("inbound mapping: watermark column %s holds timestamps, key column is required", mapping.WatermarkColumn)
+			}
+		}
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
+
+// parse 将一行转换为变更，取值无法解析时保留零值，由应用方拒绝
+func (m InboundMapping) parse(raw map[string]string) *InboundChange {
+	change := &InboundChange{
+		Watermark: raw[m.WatermarkColumn],
+		Key:       raw[m.WatermarkColumn],
+		Type:      strings.TrimSpace(raw[m.TypeColumn]),
+		Phone:     strings.TrimSpace(raw[m.PhoneColumn]),
+		Status:    strings.TrimSpace(raw[m.StatusColumn]),
+		Reference: strings.TrimSpace(raw[m.ReferenceColumn]),
+		Raw:       raw,
+	}
+	if key := m.keyColumn(); key != "" {
+		change.Key = raw[key]
+	}
+	if mapped, ok := m.TypeMap[change.Type]; ok {
+		change.Type = mapped
+	}
+	if m.OrderIdColumn != "" {
+		change.OrderId, _ = strconv.ParseInt(strings.TrimSpace(raw[m.OrderIdColumn]), 10, 64)
+	}
+	if m.MemberIdColumn != "" {
+		change.MemberId, _ = strconv.ParseInt(strings.TrimSpace(raw[m.MemberIdColumn]), 10, 64)
+	}
+	if m.AmountColumn != "" {
+		change.Amount, _ = strconv.ParseFloat(strings.TrimSpace(raw[m.AmountColumn]), 64)
+	}
+	if m.ChangedAtColumn != "" {
+		change.ChangedAt = parseInboundTime(raw[m.ChangedAtColumn])
+	}
+	return change
+}
+
+// watermarkArg 数字水位按整数比较，时间水位按时间比较，其余按字符串比较
+func watermarkArg(watermark string) interface{} {
+	if n, err := strconv.ParseInt(watermark, 10, 64); err == nil {
+		return n
+	}
+	if t := parseInboundTime(watermark); !t.IsZero() {
+		return t
+	}
+	return watermark
+}
+
+func parseInboundTime(value string) time.Time {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}
+	}
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t
+	}
+	if t, err := time.ParseInLocation("2006-01-02 15:04:05", value, time.Local); err == nil {
+		return t
+	}
+	return time.Time{}
+}
+
+// InboundImporterConfig 外部变更导入配置
+type InboundImporterConfig struct {
+	Interval       time.Duration // 轮询间隔
+	BatchSize      int           // 每轮最多读取的变更数
+	ConflictPolicy string        // latest_wins, external_wins
+	Mapping        InboundMapping
+}
+
+// InboundImporter 轮询外部变更表，逐条交给 InboundApplier 应用并记录结果，处理后推进 (水位, 行键) 游标。
+// 同一行键的变更只处理一次，记录结果与推进游标之间中断时不会重复应用
+type InboundImporter struct {
+	adapter *SyncAdapter
+	db      *gorm.DB
+	applier InboundApplier
+	config  InboundImporterConfig
+	logger  logx.Logger
+	stop    chan struct{}
+}
+
+// NewInboundImporter 创建外部变更导入器
+func NewInboundImporter(adapter *SyncAdapter, db *gorm.DB, applier InboundApplier, config InboundImporterConfig) (*InboundImporter, error) {
+	if config.Interval <= 0 {
+		config.Interval = 30 * time.Second
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 100
+	}
+	if config.ConflictPolicy == "" {
+		config.ConflictPolicy = ConflictLatestWins
+	}
+	if config.ConflictPolicy != ConflictLatestWins && config.ConflictPolicy != ConflictExternalWins {
+		return nil, fmt.Errorf("unknown inbound conflict policy %q", config.ConflictPolicy)
+	}
+	config.Mapping = config.Mapping.WithDefaults()
+	if err := config.Mapping.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &InboundImporter{
+		adapter: adapter,
+		db:      db,
+		applier: applier,
+		config:  config,
+		logger:  logx.WithContext(context.Background()),
+		stop:    make(chan struct{}),
+	}, nil
+}
+
+// Start 启动导入，阻塞直到 Stop 被调用
+func (i *InboundImporter) Start() {
+	i.logger.Infof("InboundImporter started: table=%s", i.config.Mapping.Table)
+
+	ticker := time.NewTicker(i.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		i.RunOnce()
+
+		select {
+		case <-i.stop:
+			i.logger.Info("InboundImporter stopping...")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stop 停止导入
+func (i *InboundImporter) Stop() {
+	close(i.stop)
+}
+
+// RunOnce 导入一批变更，返回处理的数量
+func (i *InboundImporter) RunOnce() int {
+	ctx := context.Background()
+	table := i.config.Mapping.Table
+
+	var cursor model.SyncInboundCursor
+	if err := i.db.Where(model.SyncInboundCursor{SourceTable: table}).FirstOrCreate(&cursor).Error; err != nil {
+		i.logger.Errorf("Failed to load inbound cursor: table=%s, err=%v", table, err)
+		return 0
+	}
+
+	changes, err := i.adapter.FetchChanges(ctx, i.config.Mapping, cursor.Watermark, cursor.RowKey, i.config.BatchSize)
+	if err != nil {
+		i.logger.Errorf("Failed to fetch inbound changes: table=%s, err=%v", table, err)
+		return 0
+	}
+
+	processed := 0
+	for _, change := range changes {
+		if err := i.process(ctx, change); err != nil {
+			i.logger.Errorf("Failed to apply inbound change, will retry: table=%s, watermark=%s, key=%s, err=%v", table, change.Watermark, change.Key, err)
+			break
+		}
+		if err := i.db.Model(&cursor).Updates(map[string]interface{}{"watermark": change.Watermark, "row_key": change.Key}).Error; err != nil {
+			i.logger.Errorf("Failed to advance inbound cursor: table=%s, watermark=%s, key=%s, err=%v", table, change.Watermark, change.Key, err)
+			break
+		}
+		processed++
+	}
+
+	return processed
+}
+
+// process 应用一条变更并记录结果，已记录过的行直接跳过
+func (i *InboundImporter) process(ctx context.Context, change *InboundChange) error {
+	table := i.config.Mapping.Table
+
+	var count int64
+	if err := i.db.Model(&model.SyncInboundChange{}).
+		Where("source_table = ? AND row_key = ?", table, change.Key).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	result := InboundResult{Status: InboundStatusRejected, Detail: fmt.Sprintf("未知的变更类型: %s", change.Type)}
+	if containsString(inboundChangeTypes, change.Type) {
+		var err error
+		if result, err = i.applier.Apply(ctx, change, i.config.ConflictPolicy); err != nil {
+			return err
+		}
+	}
+	if result.Status == InboundStatusConflict || result.Status == InboundStatusRejected {
+		i.logger.Infof("Inbound change not applied: table=%s, watermark=%s, key=%s, type=%s, status=%s, detail=%s",
+			table, change.Watermark, change.Key, change.Type, result.Status, result.Detail)
+	}
+
+	payload, _ := json.Marshal(change.Raw)
+	record := &model.SyncInboundChange{
+		SourceTable: table,
+		Watermark:   change.Watermark,
+		RowKey:      change.Key,
+		ChangeType:  truncateError(change.Type, 32),
+		OrderId:     change.OrderId,
+		MemberId:    change.MemberId,
+		Payload:     string(payload),
+		Status:      result.Status,
+		Detail:      truncateError(result.Detail, 500),
+	}
+	if !change.ChangedAt.IsZero() {
+		record.ExternalChangedAt = &change.ChangedAt
+	}
+	return i.db.Create(record).Error
+}
@@ -0,0 +1,192 @@
+package syncadapter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"dmh/model"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+func TestInboundMapping_Validate(t *testing.T) {
+	assert.NoError(t, InboundMapping{}.WithDefaults().Validate())
+
+	assert.Error(t, InboundMapping{Table: "changes", WatermarkColumn: "id", TypeColumn: "type"}.Validate())
+	assert.Error(t, InboundMapping{Table: "changes;drop", WatermarkColumn: "id", TypeColumn: "type", OrderIdColumn: "order_id"}.Validate())
+	assert.Error(t, InboundMapping{Table: "changes", WatermarkColumn: "id", TypeColumn: "type", OrderIdColumn: "order_id",
+		TypeMap: map[string]string{"RF": "order_deleted"}}.Validate())
+}
+
+func TestInboundMapping_Parse(t *testing.T) {
+	mapping := InboundMapping{
+		Table:           "ERP_CHANGE_LOG",
+		WatermarkColumn: "SEQ_NO",
+		TypeColumn:      "CHG_TYPE",
+		TypeMap:         map[string]string{"RF": InboundOrderRefunded},
+		OrderIdColumn:   "ORDER_NO",
+		AmountColumn:    "AMT",
+		ChangedAtColumn: "CHG_TIME",
+	}
+	assert.Equal(t, []string{"SEQ_NO", "CHG_TYPE", "ORDER_NO", "AMT", "CHG_TIME"}, mapping.columns())
+
+	change := mapping.parse(map[string]string{"SEQ_NO": "12", "CHG_TYPE": "RF", "ORDER_NO": " 301 ", "AMT": "9.90", "CHG_TIME": "2026-10-01T08:00:00Z"})
+	assert.Equal(t, "12", change.Watermark)
+	assert.Equal(t, InboundOrderRefunded, change.Type)
+	assert.Equal(t, int64(301), change.OrderId)
+	assert.Equal(t, 9.9, change.Amount)
+	assert.Equal(t, time.Date(2026, 10, 1, 8, 0, 0, 0, time.UTC), change.ChangedAt)
+}
+
+func TestWatermarkArg(t *testing.T) {
+	assert.Equal(t, int64(42), watermarkArg("42"))
+	assert.Equal(t, time.Date(2026, 10, 1, 8, 0, 0, 0, time.Local), watermarkArg("2026-10-01 08:00:00"))
+	assert.Equal(t, "A-001", watermarkArg("A-001"))
+}
+
+func TestResolveConflict(t *testing.T) {
+	external := time.Date(2026, 10, 1, 8, 0, 0, 0, time.Local)
+	change := &InboundChange{ChangedAt: external}
+	earlier := external.Add(-time.Minute)
+	later := external.Add(time.Minute)
+
+	apply, conflict := ResolveConflict(ConflictLatestWins, change, &earlier)
+	assert.True(t, apply)
+	assert.False(t, conflict)
+
+	apply, conflict = ResolveConflict(ConflictLatestWins, change, &later)
+	assert.False(t, apply)
+	assert.True(t, conflict)
+
+	apply, conflict = ResolveConflict(ConflictExternalWins, change, &later)
+	assert.True(t, apply)
+	assert.True(t, conflict)
+
+	// 外部未提供变更时间时无法判断先后，直接应用
+	apply, _ = ResolveConflict(ConflictLatestWins, &InboundChange{}, &later)
+	assert.True(t, apply)
+}
+
+func TestSyncAdapter_FetchChanges(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+
+	adapter := &SyncAdapter{db: sqlDB, mapper: NewFieldMapper(), metrics: NewSyncMetrics(), logger: logx.WithContext(context.Background())}
+	mapping := InboundMapping{Table: "external_changes", WatermarkColumn: "id", TypeColumn: "change_type", OrderIdColumn: "order_id"}
+
+	mock.ExpectQuery(`SELECT id, change_type, order_id FROM external_changes WHERE id > \? ORDER BY id LIMIT 2`).
+		WithArgs(int64(10)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "change_type", "order_id"}).
+			AddRow(11, "order_verified", 301).
+			AddRow(12, "order_refunded", nil))
+
+	changes, err := adapter.FetchChanges(context.Background(), mapping, "10", "", 2)
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+	assert.Equal(t, "11", changes[0].Watermark)
+	assert.Equal(t, InboundOrderVerified, changes[0].Type)
+	assert.Equal(t, int64(301), changes[0].OrderId)
+	assert.Equal(t, int64(0), changes[1].OrderId)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSyncAdapter_FetchChangesCompositeCursor(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+
+	adapter := &SyncAdapter{db: sqlDB, mapper: NewFieldMapper(), metrics: NewSyncMetrics(), logger: logx.WithContext(context.Background())}
+	mapping := InboundMapping{Table: "external_changes", WatermarkColumn: "updated_at", KeyColumn: "id", TypeColumn: "change_type", OrderIdColumn: "order_id"}
+	after := time.Date(2026, 10, 1, 8, 0, 0, 0, time.Local)
+
+	mock.ExpectQuery(`SELECT updated_at, change_type, id, order_id FROM external_changes WHERE \(updated_at > \? OR \(updated_at = \? AND id > \?\)\) ORDER BY updated_at, id LIMIT 10`).
+		WithArgs(after, after, int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"updated_at", "change_type", "id", "order_id"}).
+			AddRow("2026-10-01 08:00:00", "order_verified", 8, 301))
+
+	changes, err := adapter.FetchChanges(context.Background(), mapping, "2026-10-01 08:00:00", "7", 10)
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, "8", changes[0].Key)
+	assert.Equal(t, "2026-10-01 08:00:00", changes[0].Watermark)
+
+	// 时间水位未配置行键时拒绝导入，避免同一时刻的行被跳过
+	mapping.KeyColumn = ""
+	mock.ExpectQuery(`SELECT updated_at, change_type, order_id FROM external_changes ORDER BY updated_at LIMIT 10`).
+		WillReturnRows(sqlmock.NewRows([]string{"updated_at", "change_type", "order_id"}).
+			AddRow("2026-10-01 08:00:00", "order_verified", 301))
+	_, err = adapter.FetchChanges(context.Background(), mapping, "", "", 10)
+	assert.Error(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNewInboundImporter_Config(t *testing.T) {
+	importer, err := NewInboundImporter(nil, nil, nil, InboundImporterConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, 30*time.Second, importer.config.Interval)
+	assert.Equal(t, ConflictLatestWins, importer.config.ConflictPolicy)
+	assert.Equal(t, "external_changes", importer.config.Mapping.Table)
+
+	_, err = NewInboundImporter(nil, nil, nil, InboundImporterConfig{ConflictPolicy: "local_wins"})
+	assert.Error(t, err)
+}
+
+// stubApplier 按订单ID返回预设结果，订单 0 模拟数据库故障
+type stubApplier struct {
+	applied []string
+}
+
+func (s *stubApplier) Apply(ctx context.Context, change *InboundChange, policy string) (InboundResult, error) {
+	if change.OrderId == 0 {
+		return InboundResult{}, errors.New("database unavailable")
+	}
+	s.applied = append(s.applied, change.Watermark)
+	return InboundResult{Status: InboundStatusApplied}, nil
+}
+
+func TestInboundImporter_RunOnceAdvancesWatermark(t *testing.T) {
+	db := setupSyncWorkerTestDB(t)
+	require.NoError(t, db.AutoMigrate(&model.SyncInboundChange{}, &model.SyncInboundCursor{}))
+	require.NoError(t, db.Exec("DELETE FROM sync_inbound_changes").Error)
+	require.NoError(t, db.Exec("DELETE FROM sync_inbound_cursors").Error)
+
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+	adapter := &SyncAdapter{db: sqlDB, mapper: NewFieldMapper(), metrics: NewSyncMetrics(), logger: logx.WithContext(context.Background())}
+
+	applier := &stubApplier{}
+	importer, err := NewInboundImporter(adapter, db, applier, InboundImporterConfig{
+		Mapping: InboundMapping{Table: "external_changes", WatermarkColumn: "id", TypeColumn: "change_type", OrderIdColumn: "order_id"},
+	})
+	require.NoError(t, err)
+
+	// 第 3 条应用失败，水位停在第 2 条
+	mock.ExpectQuery(`SELECT id, change_type, order_id FROM external_changes ORDER BY id LIMIT 100`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "change_type", "order_id"}).
+			AddRow(1, "order_verified", 301).
+			AddRow(2, "unknown", 302).
+			AddRow(3, "order_verified", 0))
+	assert.Equal(t, 2, importer.RunOnce())
+	assert.Equal(t, []string{"1"}, applier.applied)
+
+	var cursor model.SyncInboundCursor
+	require.NoError(t, db.Where("source_table = ?", "external_changes").First(&cursor).Error)
+	assert.Equal(t, "2", cursor.Watermark)
+
+	var rejected model.SyncInboundChange
+	require.NoError(t, db.Where("source_table = ? AND watermark = ?", "external_changes", "2").First(&rejected).Error)
+	assert.Equal(t, InboundStatusRejected, rejected.Status)
+
+	mock.ExpectQuery(`SELECT id, change_type, order_id FROM external_changes WHERE id > \? ORDER BY id LIMIT 100`).
+		WithArgs(int64(2)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "change_type", "order_id"}))
+	assert.Equal(t, 0, importer.RunOnce())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
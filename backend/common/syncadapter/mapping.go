@@ -0,0 +1,342 @@
+package syncadapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+
+	"dmh/model"
+
+	"gorm.io/gorm"
+)
+
+// 同步映射的目标类型
+const (
+	MappingTargetOrder      = "order"
+	MappingTargetReward     = "reward"
+	MappingTargetWithdrawal = "withdrawal"
+)
+
+// 取值转换
+const (
+	TransformFen   = "fen"   // 金额（元）转换为整数分
+	TransformYuan  = "yuan"  // 金额保留两位小数
+	TransformMap   = "map"   // 按 ValueMap 翻译取值，如状态码
+	TransformDate  = "date:" // 时间按 Go 时间格式输出字符串，如 date:20060102
+	formSourceHead = "form." // 取报名表单中的字段
+)
+
+// 可映射的源字段
+var (
+	orderSourceFields  = []string{"order_id", "campaign_id", "member_id", "unionid", "phone", "form_data", "amount", "pay_status", "created_at"}
+	rewardSourceFields = []string{"reward_id", "user_id", "member_id", "order_id", "amount", "status", "settled_at"}
+
+	withdrawalSourceFields = []string{"withdrawal_id", "user_id", "brand_id", "distributor_id", "amount", "status", "pay_type", "trade_no", "paid_at", "created_at", "updated_at"}
+)
+
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_$#]*$`)
+
+// ColumnMapping 目标表的一列
+type ColumnMapping struct {
+	Column     string            `json:",optional"` // 目标列名
+	Source     string            `json:",optional"` // 源字段，form.<key> 取表单字段；为空时写入常量 Value
+	Value      string            `json:",optional"` // 常量值
+	Transform  string            `json:",optional"` // fen, yuan, map, date:<layout>
+	ValueMap   map[string]string `json:",optional"` // Transform 为 map 时的取值对照，* 匹配其余取值
+	Key        bool              `json:",optional"` // 判断记录是否已存在的列
+	InsertOnly bool              `json:",optional"` // 只在插入时写入，记录已存在时不更新
+}
+
+// TableMapping 同步到一张目标表的映射
+type TableMapping struct {
+	Table          string          `json:",optional"`
+	SyncedAtColumn string          `json:",optional"` // 写入同步时间的列，为空时不写
+	Columns        []ColumnMapping `json:",optional"`
+}
+
+// MappingConfig 订单、奖励与提现的同步映射，订单与奖励未配置时使用默认映射，提现未配置时不同步
+type MappingConfig struct {
+	Order      TableMapping `json:",optional"`
+	Reward     TableMapping `json:",optional"`
+	Withdrawal TableMapping `json:",optional"`
+}
+
+// DefaultMappingConfig 默认同步到 external_orders 与 external_rewards，列名与源字段一致
+func DefaultMappingConfig() MappingConfig {
+	return MappingConfig{
+		Order: TableMapping{
+			Table:          "external_orders",
+			SyncedAtColumn: "synced_at",
+			Columns: []ColumnMapping{
+				{Column: "order_id", Source: "order_id", Key: true},
+				{Column: "campaign_id", Source: "campaign_id", InsertOnly: true},
+				{Column: "member_id", Source: "member_id"},
+				{Column: "unionid", Source: "unionid"},
+				{Column: "phone", Source: "phone", InsertOnly: true},
+				{Column: "form_data", Source: "form_data", InsertOnly: true},
+				{Column: "amount", Source: "amount"},
+				{Column: "pay_status", Source: "pay_status"},
+				{Column: "created_at", Source: "created_at", InsertOnly: true},
+			},
+		},
+		Reward: TableMapping{
+			Table:          "external_rewards",
+			SyncedAtColumn: "synced_at",
+			Columns: []ColumnMapping{
+				{Column: "reward_id", Source: "reward_id", Key: true},
+				{Column: "user_id", Source: "user_id", InsertOnly: true},
+				{Column: "member_id", Source: "member_id"},
+				{Column: "order_id", Source: "order_id", InsertOnly: true},
+				{Column: "amount", Source: "amount"},
+				{Column: "status", Source: "status"},
+				{Column: "settled_at", Source: "settled_at"},
+			},
+		},
+	}
+}
+
+// WithDefaults 未配置的目标使用默认映射
+func (c MappingConfig) WithDefaults() MappingConfig {
+	defaults := DefaultMappingConfig()
+	if strings.TrimSpace(c.Order.Table) == "" {
+		c.Order = defaults.Order
+	}
+	if strings.TrimSpace(c.Reward.Table) == "" {
+		c.Reward = defaults.Reward
+	}
+	return c
+}
+
+// Validate 检查映射配置本身是否有效
+func (c MappingConfig) Validate() error {
+	if err := c.Order.validate(MappingTargetOrder, orderSourceFields); err != nil {
+		return err
+	}
+	if err := c.Reward.validate(MappingTargetReward, rewardSourceFields); err != nil {
+		return err
+	}
+	if !c.Withdrawal.Configured() {
+		return nil
+	}
+	return c.Withdrawal.validate(MappingTargetWithdrawal, withdrawalSourceFields)
+}
+
+// Configured 是否配置了目标表
+func (m TableMapping) Configured() bool {
+	return strings.TrimSpace(m.Table) != ""
+}
+
+func (m TableMapping) validate(target string, sources []string) error {
+	if !identifierPattern.MatchString(m.Table) {
+		return fmt.Errorf("%s mapping: invalid table name %q", target, m.Table)
+	}
+	if m.SyncedAtColumn != "" && !identifierPattern.MatchString(m.SyncedAtColumn) {
+		return fmt.Errorf("%s mapping: invalid synced_at column %q", target, m.SyncedAtColumn)
+	}
+	if len(m.Columns) == 0 {
+		return fmt.Errorf("%s mapping: no columns", target)
+	}
+
+	seen := make(map[string]bool, len(m.Columns))
+	keys := 0
+	for _, col := range m.Columns {
+		if !identifierPattern.MatchString(col.Column) {
+			return fmt.Errorf("%s mapping: invalid column name %q", target, col.Column)
+		}
+		name := strings.ToLower(col.Column)
+		if seen[name] || strings.EqualFold(col.Column, m.SyncedAtColumn) {
+			return fmt.Errorf("%s mapping: duplicate column %s", target, col.Column)
+		}
+		seen[name] = true
+
+		if col.Source != "" && !strings.HasPrefix(col.Source, formSourceHead) && !containsString(sources, col.Source) {
+			return fmt.Errorf("%s mapping: unknown source %q for column %s", target, col.Source, col.Column)
+		}
+		if col.Source == formSourceHead {
+			return fmt.Errorf("%s mapping: empty form field for column %s", target, col.Column)
+		}
+		if col.Source == "" && col.Transform != "" {
+			return fmt.Errorf("%s mapping: constant column %s cannot have a transform", target, col.Column)
+		}
+
+		switch {
+		case col.Transform == "", col.Transform == TransformFen, col.Transform == TransformYuan:
+		case col.Transform == TransformMap:
+			if len(col.ValueMap) == 0 {
+				return fmt.Errorf("%s mapping: column %s uses map transform without ValueMap", target, col.Column)
+			}
+		case strings.HasPrefix(col.Transform, TransformDate):
+			if strings.TrimPrefix(col.Transform, TransformDate) == "" {
+				return fmt.Errorf("%s mapping: column %s has empty date layout", target, col.Column)
+			}
+		default:
+			return fmt.Errorf("%s mapping: unknown transform %q for column %s", target, col.Transform, col.Column)
+		}
+
+		if col.Key {
+			if col.Source == "" {
+				return fmt.Errorf("%s mapping: key column %s must have a source", target, col.Column)
+			}
+			keys++
+		}
+	}
+	if keys == 0 {
+		return fmt.Errorf("%s mapping: at least one key column is required", target)
+	}
+	return nil
+}
+
+// upsertSpec 按映射生成写入语句描述
+func (m TableMapping) upsertSpec() upsertSpec {
+	spec := upsertSpec{Table: m.Table, SyncedAtColumn: m.SyncedAtColumn}
+	for _, col := range m.Columns {
+		spec.Columns = append(spec.Columns, col.Column)
+		switch {
+		case col.Key:
+			spec.KeyColumns = append(spec.KeyColumns, col.Column)
+		case !col.InsertOnly:
+			spec.UpdateColumns = append(spec.UpdateColumns, col.Column)
+		}
+	}
+	return spec
+}
+
+// targetColumns 目标表需要存在的列
+func (m TableMapping) targetColumns() []string {
+	columns := make([]string, 0, len(m.Columns)+1)
+	for _, col := range m.Columns {
+		columns = append(columns, col.Column)
+	}
+	if m.SyncedAtColumn != "" {
+		columns = append(columns, m.SyncedAtColumn)
+	}
+	return columns
+}
+
+// values 按列顺序计算写入的值
+func (m TableMapping) values(record map[string]interface{}, formData map[string]interface{}) ([]interface{}, error) {
+	values := make([]interface{}, 0, len(m.Columns))
+	for _, col := range m.Columns {
+		if col.Source == "" {
+			values = append(values, col.Value)
+			continue
+		}
+
+		var value interface{}
+		if key, ok := strings.CutPrefix(col.Source, formSourceHead); ok {
+			value = formData[key]
+			// 表单中的嵌套值按 JSON 写入
+			switch value.(type) {
+			case map[string]interface{}, []interface{}:
+				data, _ := json.Marshal(value)
+				value = string(data)
+			}
+		} else {
+			value = record[col.Source]
+		}
+
+		value, err := transformValue(col, value)
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %w", col.Column, err)
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+func transformValue(col ColumnMapping, value interface{}) (interface{}, error) {
+	if value == nil || col.Transform == "" {
+		return value, nil
+	}
+
+	switch {
+	case col.Transform == TransformFen, col.Transform == TransformYuan:
+		amount, ok := toFloat(value)
+		if !ok {
+			return nil, fmt.Errorf("amount transform needs a number, got %T", value)
+		}
+		if col.Transform == TransformFen {
+			return int64(math.Round(amount * 100)), nil
+		}
+		return math.Round(amount*100) / 100, nil
+
+	case col.Transform == TransformMap:
+		key := fmt.Sprint(value)
+		if mapped, ok := col.ValueMap[key]; ok {
+			return mapped, nil
+		}
+		if mapped, ok := col.ValueMap["*"]; ok {
+			return mapped, nil
+		}
+		return nil, fmt.Errorf("no mapping for value %q", key)
+
+	case strings.HasPrefix(col.Transform, TransformDate):
+		t, ok := value.(time.Time)
+		if !ok {
+			return nil, fmt.Errorf("date transform needs a time, got %T", value)
+		}
+		if t.IsZero() {
+			return nil, nil
+		}
+		return t.Format(strings.TrimPrefix(col.Transform, TransformDate)), nil
+	}
+
+	return value, nil
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadMappingConfig 从 sync_field_mappings 读取启用的映射，未配置的目标使用默认映射
+func LoadMappingConfig(db *gorm.DB) (MappingConfig, error) {
+	var rows []model.SyncFieldMapping
+	if err := db.Where("enabled = ?", true).Order("id ASC").Find(&rows).Error; err != nil {
+		return MappingConfig{}, fmt.Errorf("query sync field mappings failed: %w", err)
+	}
+
+	var cfg MappingConfig
+	for _, row := range rows {
+		var table TableMapping
+		if err := json.Unmarshal([]byte(row.Mapping), &table); err != nil {
+			return MappingConfig{}, fmt.Errorf("decode %s mapping (id=%d) failed: %w", row.Target, row.Id, err)
+		}
+		switch row.Target {
+		case MappingTargetOrder:
+			cfg.Order = table
+		case MappingTargetReward:
+			cfg.Reward = table
+		case MappingTargetWithdrawal:
+			cfg.Withdrawal = table
+		default:
+			return MappingConfig{}, fmt.Errorf("unknown sync mapping target %q (id=%d)", row.Target, row.Id)
+		}
+	}
+	return cfg.WithDefaults(), nil
+}
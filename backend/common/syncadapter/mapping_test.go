@@ -0,0 +1,168 @@
+package syncadapter
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// erpOrderMapping 客户 ERP 的订单表：金额以分存储、状态使用编码、表单中的姓名单独成列
+func erpOrderMapping() TableMapping {
+	return TableMapping{
+		Table:          "ERP_ORDER",
+		SyncedAtColumn: "SYNC_TIME",
+		Columns: []ColumnMapping{
+			{Column: "ORDER_NO", Source: "order_id", Key: true},
+			{Column: "SOURCE_SYS", Value: "DMH", InsertOnly: true},
+			{Column: "PAY_FEN", Source: "amount", Transform: TransformFen},
+			{Column: "PAY_STATE", Source: "pay_status", Transform: TransformMap, ValueMap: map[string]string{"paid": "1", "*": "0"}},
+			{Column: "ORDER_DATE", Source: "created_at", Transform: "date:20060102", InsertOnly: true},
+			{Column: "CUST_NAME", Source: "form.name"},
+		},
+	}
+}
+
+func TestMappingConfig_DefaultsAreValid(t *testing.T) {
+	cfg := MappingConfig{}.WithDefaults()
+	assert.NoError(t, cfg.Validate())
+	assert.Equal(t, "external_orders", cfg.Order.Table)
+	assert.Equal(t, "external_rewards", cfg.Reward.Table)
+
+	cfg = MappingConfig{Order: erpOrderMapping()}.WithDefaults()
+	assert.NoError(t, cfg.Validate())
+	assert.Equal(t, "ERP_ORDER", cfg.Order.Table)
+	assert.Equal(t, "external_rewards", cfg.Reward.Table)
+}
+
+func TestMappingConfig_ValidateErrors(t *testing.T) {
+	cases := map[string]func(m *TableMapping){
+		"invalid table name": func(m *TableMapping) { m.Table = "erp order" },
+		"unknown source":     func(m *TableMapping) { m.Columns[2].Source = "price" },
+		"unknown transform":  func(m *TableMapping) { m.Columns[2].Transform = "cents" },
+		"without ValueMap":   func(m *TableMapping) { m.Columns[3].ValueMap = nil },
+		"empty date layout":  func(m *TableMapping) { m.Columns[4].Transform = "date:" },
+		"duplicate column":   func(m *TableMapping) { m.Columns[5].Column = "pay_fen" },
+		"key column":         func(m *TableMapping) { m.Columns[0].Key = false },
+		"cannot have a transform": func(m *TableMapping) {
+			m.Columns[1].Transform = TransformFen
+		},
+	}
+	for want, mutate := range cases {
+		mapping := erpOrderMapping()
+		mutate(&mapping)
+		err := MappingConfig{Order: mapping}.WithDefaults().Validate()
+		if assert.Error(t, err, want) {
+			assert.Contains(t, err.Error(), want)
+		}
+	}
+}
+
+func TestMappingConfig_WithdrawalIsOptional(t *testing.T) {
+	cfg := MappingConfig{}.WithDefaults()
+	assert.False(t, cfg.Withdrawal.Configured())
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Withdrawal = TableMapping{
+		Table: "ERP_WITHDRAW",
+		Columns: []ColumnMapping{
+			{Column: "WD_NO", Source: "withdrawal_id", Key: true},
+			{Column: "WD_STATE", Source: "status"},
+		},
+	}
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Withdrawal.Columns[1].Source = "settled_at"
+	err := cfg.Validate()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "withdrawal mapping")
+	}
+}
+
+func TestTableMapping_Values(t *testing.T) {
+	createdAt := time.Date(2026, 10, 18, 9, 30, 0, 0, time.Local)
+	record := NewFieldMapper().MapOrder(&SyncOrderData{OrderId: 7, Amount: 19.99, PayStatus: "paid", CreatedAt: createdAt})
+
+	values, err := erpOrderMapping().values(record, map[string]interface{}{"name": "张三"})
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{int64(7), "DMH", int64(1999), "1", "20261018", "张三"}, values)
+
+	// 未列出的状态使用 * 对应的编码，表单缺少的字段写入 NULL
+	record["pay_status"] = "refunded"
+	values, err = erpOrderMapping().values(record, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "0", values[3])
+	assert.Nil(t, values[5])
+
+	mapping := erpOrderMapping()
+	mapping.Columns[3].ValueMap = map[string]string{"paid": "1"}
+	_, err = mapping.values(record, nil)
+	assert.Error(t, err)
+}
+
+func TestSyncOrder_CustomMapping(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer db.Close()
+
+	adapter := &SyncAdapter{
+		db:      db,
+		config:  ExternalSyncConfig{Type: "oracle", Schema: "ERP", Mapping: MappingConfig{Order: erpOrderMapping()}},
+		mapper:  NewFieldMapper(),
+		metrics: NewSyncMetrics(),
+		logger:  logx.WithContext(context.Background()),
+	}
+
+	mock.ExpectExec("MERGE INTO ERP.ERP_ORDER t USING (SELECT :1 AS ORDER_NO, :2 AS SOURCE_SYS, :3 AS PAY_FEN, :4 AS PAY_STATE, "+
+		":5 AS ORDER_DATE, :6 AS CUST_NAME FROM dual) s ON (t.ORDER_NO = s.ORDER_NO) "+
+		"WHEN MATCHED THEN UPDATE SET t.PAY_FEN = s.PAY_FEN, t.PAY_STATE = s.PAY_STATE, t.CUST_NAME = s.CUST_NAME, t.SYNC_TIME = SYSTIMESTAMP "+
+		"WHEN NOT MATCHED THEN INSERT (ORDER_NO, SOURCE_SYS, PAY_FEN, PAY_STATE, ORDER_DATE, CUST_NAME, SYNC_TIME) "+
+		"VALUES (s.ORDER_NO, s.SOURCE_SYS, s.PAY_FEN, s.PAY_STATE, s.ORDER_DATE, s.CUST_NAME, SYSTIMESTAMP)").
+		WithArgs(int64(7), "DMH", int64(1000), "1", "20261018", "李四").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = adapter.SyncOrder(context.Background(), &SyncOrderData{
+		OrderId:   7,
+		FormData:  map[string]interface{}{"name": "李四"},
+		Amount:    10,
+		PayStatus: "paid",
+		CreatedAt: time.Date(2026, 10, 18, 9, 30, 0, 0, time.Local),
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestValidateTargetSchema(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	adapter := &SyncAdapter{
+		db:     db,
+		config: ExternalSyncConfig{Type: "sqlserver", Schema: "dbo", Mapping: MappingConfig{Order: erpOrderMapping()}},
+	}
+
+	columnsQuery := regexp.QuoteMeta("SELECT column_name FROM information_schema.columns WHERE table_schema = @p1 AND table_name = @p2")
+	mock.ExpectQuery(columnsQuery).WithArgs("dbo", "ERP_ORDER").
+		WillReturnRows(sqlmock.NewRows([]string{"column_name"}).
+			AddRow("ORDER_NO").AddRow("SOURCE_SYS").AddRow("PAY_FEN").AddRow("PAY_STATE").AddRow("ORDER_DATE").AddRow("SYNC_TIME"))
+
+	err = adapter.ValidateTargetSchema(context.Background())
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "missing columns: CUST_NAME")
+	}
+
+	mock.ExpectQuery(columnsQuery).WithArgs("dbo", "ERP_ORDER").
+		WillReturnRows(sqlmock.NewRows([]string{"column_name"}))
+
+	err = adapter.ValidateTargetSchema(context.Background())
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "dbo.ERP_ORDER not found")
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
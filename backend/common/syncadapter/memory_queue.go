@@ -0,0 +1,168 @@
+package syncadapter
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// MemoryQueue 进程内同步队列，用于测试与单机部署。任务保存在内存中，进程重启后丢失
+type MemoryQueue struct {
+	mu       sync.Mutex
+	pending  []*SyncTask
+	delayed  []memoryDelayedTask
+	dead     []*SyncTask
+	inflight map[string]*SyncTask // 已取出未确认的任务
+	seq      int64
+	notify   chan struct{}
+}
+
+type memoryDelayedTask struct {
+	task  *SyncTask
+	dueAt time.Time
+}
+
+// NewMemoryQueue 创建进程内同步队列
+func NewMemoryQueue() *MemoryQueue {
+	return &MemoryQueue{
+		inflight: make(map[string]*SyncTask),
+		notify:   make(chan struct{}, 1),
+	}
+}
+
+// Enqueue 将任务加入队列，保存副本，调用方之后修改任务不影响队列
+func (q *MemoryQueue) Enqueue(task *SyncTask) error {
+	q.mu.Lock()
+	q.pending = append(q.pending, cloneTask(task))
+	q.mu.Unlock()
+	q.signal()
+	return nil
+}
+
+// Dequeue 取出任务，timeout 内没有任务时返回 ErrQueueEmpty
+func (q *MemoryQueue) Dequeue(timeout time.Duration) (*SyncTask, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		q.mu.Lock()
+		if len(q.pending) > 0 {
+			task := q.pending[0]
+			q.pending = q.pending[1:]
+			q.seq++
+			task.receipt = strconv.FormatInt(q.seq, 10)
+			q.inflight[task.receipt] = task
+			more := len(q.pending) > 0
+			q.mu.Unlock()
+			// 仍有任务时唤醒下一个等待的消费者
+			if more {
+				q.signal()
+			}
+			return cloneTask(task), nil
+		}
+		q.mu.Unlock()
+
+		select {
+		case <-q.notify:
+		case <-timer.C:
+			return nil, ErrQueueEmpty
+		}
+	}
+}
+
+// Ack 确认任务已处理完成
+func (q *MemoryQueue) Ack(task *SyncTask) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.inflight, task.receipt)
+	return nil
+}
+
+// EnqueueDelayed 延迟入队
+func (q *MemoryQueue) EnqueueDelayed(task *SyncTask, delay time.Duration) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.delayed = append(q.delayed, memoryDelayedTask{task: cloneTask(task), dueAt: time.Now().Add(delay)})
+	return nil
+}
+
+// PromoteDue 将到期的延迟任务按到期时间顺序移回待处理队列
+func (q *MemoryQueue) PromoteDue(now time.Time, limit int) (int, error) {
+	q.mu.Lock()
+	sort.SliceStable(q.delayed, func(i, j int) bool { return q.delayed[i].dueAt.Before(q.delayed[j].dueAt) })
+	moved := 0
+	for moved < len(q.delayed) && moved < limit && !q.delayed[moved].dueAt.After(now) {
+		q.pending = append(q.pending, q.delayed[moved].task)
+		moved++
+	}
+	q.delayed = q.delayed[moved:]
+	q.mu.Unlock()
+
+	if moved > 0 {
+		q.signal()
+	}
+	return moved, nil
+}
+
+// Length 待处理的任务数，含已取出未确认的任务
+func (q *MemoryQueue) Length() (int64, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return int64(len(q.pending) + len(q.inflight)), nil
+}
+
+// DelayedLength 获取等待重试的任务数
+func (q *MemoryQueue) DelayedLength() (int64, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return int64(len(q.delayed)), nil
+}
+
+// DeadLetter 移入死信队列
+func (q *MemoryQueue) DeadLetter(task *SyncTask) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.dead = append(q.dead, cloneTask(task))
+	return nil
+}
+
+// DeadLetters 查询死信队列中的任务
+func (q *MemoryQueue) DeadLetters(offset, limit int64) ([]*SyncTask, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	tasks := make([]*SyncTask, 0)
+	for i := offset; i < int64(len(q.dead)) && i < offset+limit; i++ {
+		tasks = append(tasks, cloneTask(q.dead[i]))
+	}
+	return tasks, nil
+}
+
+// DeadLetterLength 获取死信队列长度
+func (q *MemoryQueue) DeadLetterLength() (int64, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return int64(len(q.dead)), nil
+}
+
+// Clear 清空队列
+func (q *MemoryQueue) Clear() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.pending, q.delayed, q.dead = nil, nil, nil
+	q.inflight = make(map[string]*SyncTask)
+	return nil
+}
+
+func (q *MemoryQueue) signal() {
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+func cloneTask(task *SyncTask) *SyncTask {
+	clone := *task
+	return &clone
+}
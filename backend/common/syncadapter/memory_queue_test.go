@@ -0,0 +1,113 @@
+package syncadapter
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryQueue_FIFOAndAck(t *testing.T) {
+	q := NewMemoryQueue()
+	task := &SyncTask{TaskId: "1", Type: SyncTypeOrder, OrderId: 1}
+	require.NoError(t, q.Enqueue(task))
+	require.NoError(t, q.Enqueue(&SyncTask{TaskId: "2", Type: SyncTypeOrder, OrderId: 2}))
+
+	// 入队保存副本
+	task.OrderId = 99
+
+	first, err := q.Dequeue(time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, "1", first.TaskId)
+	assert.Equal(t, int64(1), first.OrderId)
+
+	// 未确认的任务仍计入长度
+	length, _ := q.Length()
+	assert.Equal(t, int64(2), length)
+	require.NoError(t, q.Ack(first))
+	length, _ = q.Length()
+	assert.Equal(t, int64(1), length)
+
+	second, err := q.Dequeue(time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, "2", second.TaskId)
+
+	_, err = q.Dequeue(10 * time.Millisecond)
+	assert.ErrorIs(t, err, ErrQueueEmpty)
+}
+
+func TestMemoryQueue_DelayedAndDeadLetters(t *testing.T) {
+	q := NewMemoryQueue()
+	require.NoError(t, q.EnqueueDelayed(&SyncTask{TaskId: "later"}, time.Hour))
+	require.NoError(t, q.EnqueueDelayed(&SyncTask{TaskId: "due"}, 0))
+
+	moved, err := q.PromoteDue(time.Now(), 100)
+	require.NoError(t, err)
+	assert.Equal(t, 1, moved)
+	delayed, _ := q.DelayedLength()
+	assert.Equal(t, int64(1), delayed)
+
+	task, err := q.Dequeue(time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, "due", task.TaskId)
+
+	task.LastError = "connection refused"
+	require.NoError(t, q.DeadLetter(task))
+	dead, err := q.DeadLetters(0, 10)
+	require.NoError(t, err)
+	require.Len(t, dead, 1)
+	assert.Equal(t, "connection refused", dead[0].LastError)
+
+	require.NoError(t, q.Clear())
+	length, _ := q.DeadLetterLength()
+	assert.Equal(t, int64(0), length)
+}
+
+func TestMemoryQueue_ConcurrentConsumers(t *testing.T) {
+	q := NewMemoryQueue()
+	const total = 200
+
+	var mu sync.Mutex
+	seen := make(map[string]int)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				task, err := q.Dequeue(200 * time.Millisecond)
+				if err != nil {
+					return
+				}
+				mu.Lock()
+				seen[task.TaskId]++
+				mu.Unlock()
+				_ = q.Ack(task)
+			}
+		}()
+	}
+	for i := 0; i < total; i++ {
+		require.NoError(t, q.Enqueue(&SyncTask{TaskId: time.Duration(i).String()}))
+	}
+	wg.Wait()
+
+	assert.Len(t, seen, total)
+	for id, n := range seen {
+		assert.Equal(t, 1, n, id)
+	}
+	length, _ := q.Length()
+	assert.Equal(t, int64(0), length)
+}
+
+func TestNewTaskQueue(t *testing.T) {
+	q, err := NewTaskQueue(QueueBackendMemory, nil, "", StreamQueueConfig{})
+	require.NoError(t, err)
+	assert.IsType(t, &MemoryQueue{}, q)
+
+	_, err = NewTaskQueue(QueueBackendStream, nil, "dmh:sync:tasks", StreamQueueConfig{})
+	assert.Error(t, err)
+	_, err = NewTaskQueue("kafka", nil, "dmh:sync:tasks", StreamQueueConfig{})
+	assert.Error(t, err)
+}
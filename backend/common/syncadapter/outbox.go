@@ -0,0 +1,221 @@
+package syncadapter
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"dmh/common/leasepoll"
+	"dmh/model"
+
+	"github.com/zeromicro/go-zero/core/logx"
+	"gorm.io/gorm"
+)
+
+// 发件箱事件类型
+const (
+	OutboxEventOrderPaid         = "order.paid"
+	OutboxEventOrderRefunded     = "order.refunded"
+	OutboxEventRewardSettled     = "reward.settled"
+	OutboxEventWithdrawalChanged = "withdrawal.changed"
+)
+
+// 发件箱事件状态
+const (
+	OutboxStatusPending   = "pending"
+	OutboxStatusDelivered = "delivered"
+)
+
+// outboxAggregates 事件类型对应的聚合类型
+var outboxAggregates = map[string]string{
+	OutboxEventOrderPaid:         SyncTypeOrder,
+	OutboxEventOrderRefunded:     SyncTypeOrder,
+	OutboxEventRewardSettled:     SyncTypeDistributorReward,
+	OutboxEventWithdrawalChanged: SyncTypeWithdrawal,
+}
+
+// Outbox 外部同步发件箱：事件与业务数据在同一事务内写入，事务回滚时事件一并回滚。
+// 未启用外部同步时为 nil，写入直接跳过
+type Outbox struct{}
+
+// NewOutbox 创建发件箱
+func NewOutbox() *Outbox {
+	return &Outbox{}
+}
+
+// Add 在事务 tx 内记录一条事件
+func (o *Outbox) Add(tx *gorm.DB, eventType string, aggregateId int64) error {
+	if o == nil {
+		return nil
+	}
+	aggregateType, ok := outboxAggregates[eventType]
+	if !ok {
+		return fmt.Errorf("unknown outbox event type %q", eventType)
+	}
+
+	return tx.Create(&model.OutboxEvent{
+		EventType:     eventType,
+		AggregateType: aggregateType,
+		AggregateId:   aggregateId,
+		Status:        OutboxStatusPending,
+		NextAttemptAt: time.Now(),
+	}).Error
+}
+
+// OutboxRelayConfig 发件箱中继配置
+type OutboxRelayConfig struct {
+	Interval     time.Duration // 轮询间隔
+	BatchSize    int           // 每轮最多投递的事件数
+	RetryBackoff time.Duration // 投递失败的退避基数，第 n 次失败等待 n 倍，最长 10 分钟
+	Lease        time.Duration // 处理租约，防止多个实例同时投递同一事件
+}
+
+// OutboxRelay 将待投递的发件箱事件转换为同步任务写入 SyncQueue，写入成功后标记为已投递。
+// 写入队列与标记之间中断时事件会被再次投递（至少一次），同步写入为幂等更新，重复投递无副作用
+type OutboxRelay struct {
+	*leasepoll.Poller
+	db     *gorm.DB
+	queue  TaskQueue
+	config OutboxRelayConfig
+	logger logx.Logger
+}
+
+// NewOutboxRelay 创建发件箱中继
+func NewOutboxRelay(db *gorm.DB, queue TaskQueue, config OutboxRelayConfig) *OutboxRelay {
+	if config.Interval <= 0 {
+		config.Interval = 2 * time.Second
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 100
+	}
+	if config.RetryBackoff <= 0 {
+		config.RetryBackoff = 10 * time.Second
+	}
+	if config.Lease <= 0 {
+		config.Lease = time.Minute
+	}
+
+	r := &OutboxRelay{
+		db:     db,
+		queue:  queue,
+		config: config,
+		logger: logx.WithContext(context.Background()),
+	}
+	r.Poller = leasepoll.New(leasepoll.Config{
+		Name:      "OutboxRelay",
+		Interval:  config.Interval,
+		BatchSize: config.BatchSize,
+		Lease:     config.Lease,
+	}, r.dueQuery, r.deliver)
+	return r
+}
+
+// dueQuery 待投递且已到投递时间的事件
+func (r *OutboxRelay) dueQuery(now time.Time) *gorm.DB {
+	return r.db.Model(&model.OutboxEvent{}).
+		Where("status = ? AND next_attempt_at <= ?", OutboxStatusPending, now)
+}
+
+// deliver 将事件写入同步队列并标记为已投递，失败时按退避时间等待下次投递
+func (r *OutboxRelay) deliver(id int64) bool {
+	var event model.OutboxEvent
+	if err := r.db.Where("id = ?", id).First(&event).Error; err != nil {
+		r.logger.Errorf("Failed to load outbox event: id=%d, err=%v", id, err)
+		return false
+	}
+
+	if err := r.publish(&event); err != nil {
+		attempts := event.Attempts + 1
+		r.logger.Errorf("Failed to publish outbox event: id=%d, type=%s, aggregateId=%d, attempts=%d, err=%v",
+			event.Id, event.EventType, event.AggregateId, attempts, err)
+		if dbErr := r.db.Model(&model.OutboxEvent{}).Where("id = ?", event.Id).Updates(map[string]interface{}{
+			"attempts":        attempts,
+			"last_error":      truncateError(err.Error(), 500),
+			"next_attempt_at": time.Now().Add(r.backoff(attempts)),
+		}).Error; dbErr != nil {
+			r.logger.Errorf("Failed to record outbox failure: id=%d, err=%v", event.Id, dbErr)
+		}
+		return false
+	}
+
+	now := time.Now()
+	if err := r.db.Model(&model.OutboxEvent{}).Where("id = ?", event.Id).Updates(map[string]interface{}{
+		"status":       OutboxStatusDelivered,
+		"delivered_at": now,
+		"last_error":   "",
+	}).Error; err != nil {
+		// 标记失败时租约到期后会再次投递
+		r.logger.Errorf("Failed to mark outbox event delivered: id=%d, err=%v", event.Id, err)
+		return false
+	}
+	return true
+}
+
+// publish 将事件转换为同步任务并写入队列
+func (r *OutboxRelay) publish(event *model.OutboxEvent) error {
+	tasks, err := OutboxEventTasks(r.db, event)
+	if err != nil {
+		return err
+	}
+	for _, task := range tasks {
+		if err := r.queue.Enqueue(task); err != nil {
+			return fmt.Errorf("enqueue %s task failed: %w", task.Type, err)
+		}
+	}
+	return nil
+}
+
+// backoff 第 attempts 次失败后的等待时间
+func (r *OutboxRelay) backoff(attempts int) time.Duration {
+	delay := time.Duration(attempts) * r.config.RetryBackoff
+	if delay > 10*time.Minute {
+		delay = 10 * time.Minute
+	}
+	return delay
+}
+
+// OutboxEventTasks 事件对应的同步任务：退款同步订单及其全部分销奖励，其余事件同步事件本身的聚合
+func OutboxEventTasks(db *gorm.DB, event *model.OutboxEvent) ([]*SyncTask, error) {
+	now := time.Now()
+	switch event.EventType {
+	case OutboxEventOrderPaid:
+		return []*SyncTask{{
+			TaskId:    fmt.Sprintf("order-%d-%d", event.AggregateId, now.UnixNano()),
+			Type:      SyncTypeOrder,
+			OrderId:   event.AggregateId,
+			CreatedAt: now,
+		}}, nil
+	case OutboxEventOrderRefunded:
+		return OrderSyncTasks(db, event.AggregateId)
+	case OutboxEventRewardSettled:
+		var reward model.DistributorReward
+		if err := db.Select("id", "order_id").Where("id = ?", event.AggregateId).First(&reward).Error; err != nil {
+			return nil, fmt.Errorf("get distributor reward failed: %w", err)
+		}
+		return []*SyncTask{{
+			TaskId:    fmt.Sprintf("reward-%d-%d", reward.Id, now.UnixNano()),
+			Type:      SyncTypeDistributorReward,
+			OrderId:   reward.OrderId,
+			RewardId:  reward.Id,
+			CreatedAt: now,
+		}}, nil
+	case OutboxEventWithdrawalChanged:
+		return []*SyncTask{{
+			TaskId:       fmt.Sprintf("withdrawal-%d-%d", event.AggregateId, now.UnixNano()),
+			Type:         SyncTypeWithdrawal,
+			WithdrawalId: event.AggregateId,
+			CreatedAt:    now,
+		}}, nil
+	default:
+		return nil, fmt.Errorf("unknown outbox event type %q", event.EventType)
+	}
+}
+
+// truncateError 按字符截断错误信息，适配 varchar 列长度
+func truncateError(msg string, max int) string {
+	runes := []rune(msg)
+	if len(runes) <= max {
+		return msg
+	}
+	return string(runes[:max])
+}
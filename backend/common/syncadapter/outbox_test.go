@@ -0,0 +1,95 @@
+package syncadapter
+
+import (
+	"testing"
+	"time"
+
+	"dmh/model"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutbox_NilSkipsWrite(t *testing.T) {
+	var outbox *Outbox
+
+	assert.NoError(t, outbox.Add(nil, OutboxEventOrderPaid, 1))
+}
+
+func TestOutbox_UnknownEventType(t *testing.T) {
+	err := NewOutbox().Add(nil, "order.shipped", 1)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "order.shipped")
+}
+
+func TestOutboxEventTasks(t *testing.T) {
+	tasks, err := OutboxEventTasks(nil, &model.OutboxEvent{EventType: OutboxEventOrderPaid, AggregateId: 7})
+	assert.NoError(t, err)
+	assert.Len(t, tasks, 1)
+	assert.Equal(t, SyncTypeOrder, tasks[0].Type)
+	assert.Equal(t, int64(7), tasks[0].OrderId)
+
+	tasks, err = OutboxEventTasks(nil, &model.OutboxEvent{EventType: OutboxEventWithdrawalChanged, AggregateId: 9})
+	assert.NoError(t, err)
+	assert.Len(t, tasks, 1)
+	assert.Equal(t, SyncTypeWithdrawal, tasks[0].Type)
+	assert.Equal(t, int64(9), tasks[0].WithdrawalId)
+
+	_, err = OutboxEventTasks(nil, &model.OutboxEvent{EventType: "unknown"})
+	assert.Error(t, err)
+}
+
+func TestNewOutboxRelay_Defaults(t *testing.T) {
+	relay := NewOutboxRelay(nil, &SyncQueue{}, OutboxRelayConfig{})
+
+	assert.Equal(t, 2*time.Second, relay.config.Interval)
+	assert.Equal(t, 100, relay.config.BatchSize)
+	assert.Equal(t, 10*time.Second, relay.config.RetryBackoff)
+	assert.Equal(t, time.Minute, relay.config.Lease)
+}
+
+func TestOutboxRelay_Backoff(t *testing.T) {
+	relay := NewOutboxRelay(nil, &SyncQueue{}, OutboxRelayConfig{RetryBackoff: time.Minute})
+
+	assert.Equal(t, time.Minute, relay.backoff(1))
+	assert.Equal(t, 3*time.Minute, relay.backoff(3))
+	assert.Equal(t, 10*time.Minute, relay.backoff(20))
+}
+
+func TestTruncateError(t *testing.T) {
+	assert.Equal(t, "abc", truncateError("abc", 5))
+	assert.Equal(t, "同步失", truncateError("同步失败", 3))
+}
+
+func TestOutboxRelay_RunOnceDeliversToQueue(t *testing.T) {
+	redisClient := redis.NewClient(&redis.Options{Addr: "localhost:6379"})
+	defer redisClient.Close()
+
+	queue := NewSyncQueue(redisClient, "test_outbox_relay")
+	if err := queue.Clear(); err != nil {
+		t.Skipf("Redis not available: %v", err)
+		return
+	}
+
+	db := setupSyncWorkerTestDB(t)
+	assert.NoError(t, db.AutoMigrate(&model.OutboxEvent{}))
+	assert.NoError(t, db.Exec("DELETE FROM outbox_events").Error)
+
+	outbox := NewOutbox()
+	assert.NoError(t, outbox.Add(db, OutboxEventOrderPaid, 501))
+	assert.NoError(t, outbox.Add(db, OutboxEventWithdrawalChanged, 601))
+
+	relay := NewOutboxRelay(db, queue, OutboxRelayConfig{})
+	assert.Equal(t, 2, relay.RunOnce())
+
+	length, _ := queue.Length()
+	assert.Equal(t, int64(2), length)
+
+	var pending int64
+	db.Model(&model.OutboxEvent{}).Where("status = ?", OutboxStatusPending).Count(&pending)
+	assert.Equal(t, int64(0), pending)
+
+	// 已投递的事件不会重复投递
+	assert.Equal(t, 0, relay.RunOnce())
+}
@@ -0,0 +1,63 @@
+package syncadapter
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// 队列实现
+const (
+	QueueBackendStream = "stream" // Redis Streams，消费者组确认，崩溃后自动接管未确认任务
+	QueueBackendList   = "list"   // Redis 列表，取出即删除，仅为兼容旧部署保留
+	QueueBackendMemory = "memory" // 进程内队列，用于测试与单机部署，重启后任务丢失
+)
+
+// ErrQueueEmpty 等待超时仍没有任务。与 Redis 客户端的 redis.Nil 相同，兼容已有的判断
+var ErrQueueEmpty = redis.Nil
+
+// TaskQueue 同步任务队列。Dequeue 取出的任务需在处理完成（同步成功、延迟重试或进入死信队列）后 Ack，
+// 支持确认的实现会把消费者崩溃时未确认的任务重新投递给其他消费者。所有方法都可以被多个消费者并发调用
+type TaskQueue interface {
+	// Enqueue 将任务加入待处理队列
+	Enqueue(task *SyncTask) error
+	// Dequeue 取出一个任务，timeout 内没有任务时返回 ErrQueueEmpty
+	Dequeue(timeout time.Duration) (*SyncTask, error)
+	// Ack 确认任务已处理完成
+	Ack(task *SyncTask) error
+	// EnqueueDelayed 延迟入队，到期后由 PromoteDue 移回待处理队列
+	EnqueueDelayed(task *SyncTask, delay time.Duration) error
+	// PromoteDue 将到期的延迟任务移回待处理队列，返回移动的任务数
+	PromoteDue(now time.Time, limit int) (int, error)
+	// Length 待处理的任务数（含已取出未确认的任务）
+	Length() (int64, error)
+	// DelayedLength 等待重试的任务数
+	DelayedLength() (int64, error)
+	// DeadLetter 将超过重试次数的任务移入死信队列
+	DeadLetter(task *SyncTask) error
+	// DeadLetters 查询死信队列中的任务
+	DeadLetters(offset, limit int64) ([]*SyncTask, error)
+	// DeadLetterLength 死信队列长度
+	DeadLetterLength() (int64, error)
+	// Clear 清空队列（含延迟队列与死信队列）
+	Clear() error
+}
+
+// NewTaskQueue 按 backend 创建同步队列，memory 以外的实现需要 Redis
+func NewTaskQueue(backend string, redisClient *redis.Client, queueKey string, config StreamQueueConfig) (TaskQueue, error) {
+	switch backend {
+	case QueueBackendMemory:
+		return NewMemoryQueue(), nil
+	case QueueBackendList, QueueBackendStream, "":
+		if redisClient == nil {
+			return nil, fmt.Errorf("sync queue backend %q requires redis", backend)
+		}
+		if backend == QueueBackendList {
+			return NewSyncQueue(redisClient, queueKey), nil
+		}
+		return NewStreamQueue(redisClient, queueKey, config), nil
+	default:
+		return nil, fmt.Errorf("unknown sync queue backend %q", backend)
+	}
+}
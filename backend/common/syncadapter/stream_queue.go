@@ -0,0 +1,270 @@
+package syncadapter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// promoteDueStreamScript 将到期的延迟任务以及旧版本写入列表的任务原子地移入 Stream
+var promoteDueStreamScript = redis.NewScript(`
+local moved = 0
+local tasks = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+for _, task in ipairs(tasks) do
+	redis.call('ZREM', KEYS[1], task)
+	redis.call('XADD', KEYS[2], '*', 'task', task)
+	moved = moved + 1
+end
+for i = 1, tonumber(ARGV[2]) do
+	local task = redis.call('LPOP', KEYS[3])
+	if not task then
+		break
+	end
+	redis.call('XADD', KEYS[2], '*', 'task', task)
+	moved = moved + 1
+end
+return moved
+`)
+
+// StreamQueueConfig Redis Streams 队列配置
+type StreamQueueConfig struct {
+	Group         string        // 消费者组，同一组内的消费者分摊任务
+	Consumer      string        // 消费者名称，默认 主机名-进程号
+	ClaimIdle     time.Duration // 任务取出后超过该时间未确认，视为消费者已崩溃并由其他消费者接管
+	MaxDeliveries int64         // 接管超过该次数仍未确认的任务直接移入死信队列，避免反复导致崩溃
+}
+
+// StreamQueue 基于 Redis Streams 的同步队列。任务通过消费者组分发，Ack 后才从 Stream 删除；
+// 消费者崩溃时未确认的任务在 ClaimIdle 后被其他消费者接管。
+// 延迟队列与死信队列沿用 SyncQueue 的键，旧版本写入列表的任务在 PromoteDue 时迁入 Stream
+type StreamQueue struct {
+	redis       *redis.Client
+	list        *SyncQueue // 延迟队列、死信队列与旧版本列表
+	stream      string
+	config      StreamQueueConfig
+	groupReady  atomic.Bool
+	lastReclaim atomic.Int64 // 最近一次没有可接管任务的时间（UnixNano）
+	logger      logx.Logger
+}
+
+// NewStreamQueue 创建 Redis Streams 同步队列
+func NewStreamQueue(redisClient *redis.Client, queueKey string, config StreamQueueConfig) *StreamQueue {
+	if config.Group == "" {
+		config.Group = "dmh-sync"
+	}
+	if config.Consumer == "" {
+		host, _ := os.Hostname()
+		config.Consumer = fmt.Sprintf("%s-%d", host, os.Getpid())
+	}
+	if config.ClaimIdle <= 0 {
+		config.ClaimIdle = 5 * time.Minute
+	}
+	if config.MaxDeliveries <= 0 {
+		config.MaxDeliveries = 5
+	}
+
+	return &StreamQueue{
+		redis:  redisClient,
+		list:   NewSyncQueue(redisClient, queueKey),
+		stream: queueKey + ":stream",
+		config: config,
+		logger: logx.WithContext(context.Background()),
+	}
+}
+
+// Enqueue 将任务加入 Stream
+func (q *StreamQueue) Enqueue(task *SyncTask) error {
+	data, err := json.Marshal(task)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	return q.redis.XAdd(ctx, &redis.XAddArgs{Stream: q.stream, Values: map[string]interface{}{"task": data}}).Err()
+}
+
+// Dequeue 优先接管崩溃消费者遗留的任务，否则阻塞读取新任务
+func (q *StreamQueue) Dequeue(timeout time.Duration) (*SyncTask, error) {
+	ctx := context.Background()
+	if err := q.ensureGroup(ctx); err != nil {
+		return nil, err
+	}
+
+	task, err := q.reclaim(ctx)
+	if err != nil || task != nil {
+		return task, err
+	}
+
+	block := timeout
+	if block <= 0 {
+		block = -1 // 不阻塞；go-redis 中 0 表示永久阻塞
+	}
+	streams, err := q.redis.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    q.config.Group,
+		Consumer: q.config.Consumer,
+		Streams:  []string{q.stream, ">"},
+		Count:    1,
+		Block:    block,
+	}).Result()
+	if err != nil {
+		q.resetGroupOnMissing(err)
+		return nil, err
+	}
+	if len(streams) == 0 || len(streams[0].Messages) == 0 {
+		return nil, ErrQueueEmpty
+	}
+	return q.decode(ctx, streams[0].Messages[0])
+}
+
+// reclaim 接管超过 ClaimIdle 未确认的任务，没有可接管的任务后在 ClaimIdle/2 内不再检查
+func (q *StreamQueue) reclaim(ctx context.Context) (*SyncTask, error) {
+	if time.Since(time.Unix(0, q.lastReclaim.Load())) < q.config.ClaimIdle/2 {
+		return nil, nil
+	}
+
+	for {
+		messages, _, err := q.redis.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   q.stream,
+			Group:    q.config.Group,
+			Consumer: q.config.Consumer,
+			MinIdle:  q.config.ClaimIdle,
+			Start:    "0-0",
+			Count:    1,
+		}).Result()
+		if err != nil {
+			q.resetGroupOnMissing(err)
+			return nil, err
+		}
+		if len(messages) == 0 {
+			q.lastReclaim.Store(time.Now().UnixNano())
+			return nil, nil
+		}
+
+		msg := messages[0]
+		task, err := q.decode(ctx, msg)
+		if err != nil {
+			return nil, err
+		}
+		q.logger.Infof("Reclaimed unacknowledged sync task: id=%s, taskId=%s", msg.ID, task.TaskId)
+
+		pending, err := q.redis.XPendingExt(ctx, &redis.XPendingExtArgs{
+			Stream: q.stream, Group: q.config.Group, Start: msg.ID, End: msg.ID, Count: 1,
+		}).Result()
+		if err != nil || len(pending) == 0 || pending[0].RetryCount <= q.config.MaxDeliveries {
+			return task, nil
+		}
+
+		task.LastError = fmt.Sprintf("任务被接管 %d 次仍未确认", pending[0].RetryCount)
+		q.logger.Errorf("Sync task dead-lettered after repeated reclaim: id=%s, taskId=%s", msg.ID, task.TaskId)
+		if err := q.DeadLetter(task); err != nil {
+			return nil, err
+		}
+		if err := q.Ack(task); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// decode 解析消息，无法解析的消息直接确认删除
+func (q *StreamQueue) decode(ctx context.Context, msg redis.XMessage) (*SyncTask, error) {
+	raw, _ := msg.Values["task"].(string)
+	var task SyncTask
+	if err := json.Unmarshal([]byte(raw), &task); err != nil {
+		q.logger.Errorf("Failed to decode sync task, dropped: id=%s, err=%v", msg.ID, err)
+		_ = q.Ack(&SyncTask{receipt: msg.ID})
+		return nil, err
+	}
+	task.receipt = msg.ID
+	return &task, nil
+}
+
+// Ack 确认并从 Stream 删除任务
+func (q *StreamQueue) Ack(task *SyncTask) error {
+	if task == nil || task.receipt == "" {
+		return nil
+	}
+
+	ctx := context.Background()
+	_, err := q.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.XAck(ctx, q.stream, q.config.Group, task.receipt)
+		pipe.XDel(ctx, q.stream, task.receipt)
+		return nil
+	})
+	return err
+}
+
+// EnqueueDelayed 延迟入队
+func (q *StreamQueue) EnqueueDelayed(task *SyncTask, delay time.Duration) error {
+	return q.list.EnqueueDelayed(task, delay)
+}
+
+// PromoteDue 将到期的延迟任务与旧版本列表中的任务移入 Stream
+func (q *StreamQueue) PromoteDue(now time.Time, limit int) (int, error) {
+	ctx := context.Background()
+	keys := []string{q.list.delayedKey(), q.stream, q.list.key}
+	return promoteDueStreamScript.Run(ctx, q.redis, keys, strconv.FormatInt(now.UnixMilli(), 10), limit).Int()
+}
+
+// Length Stream 中的任务数，含已取出未确认的任务
+func (q *StreamQueue) Length() (int64, error) {
+	ctx := context.Background()
+	return q.redis.XLen(ctx, q.stream).Result()
+}
+
+// DelayedLength 获取等待重试的任务数
+func (q *StreamQueue) DelayedLength() (int64, error) {
+	return q.list.DelayedLength()
+}
+
+// DeadLetter 移入死信队列
+func (q *StreamQueue) DeadLetter(task *SyncTask) error {
+	return q.list.DeadLetter(task)
+}
+
+// DeadLetters 查询死信队列中的任务
+func (q *StreamQueue) DeadLetters(offset, limit int64) ([]*SyncTask, error) {
+	return q.list.DeadLetters(offset, limit)
+}
+
+// DeadLetterLength 获取死信队列长度
+func (q *StreamQueue) DeadLetterLength() (int64, error) {
+	return q.list.DeadLetterLength()
+}
+
+// Clear 清空 Stream（含消费者组）、延迟队列与死信队列
+func (q *StreamQueue) Clear() error {
+	ctx := context.Background()
+	if err := q.redis.Del(ctx, q.stream).Err(); err != nil {
+		return err
+	}
+	q.groupReady.Store(false)
+	return q.list.Clear()
+}
+
+// ensureGroup 创建消费者组，已存在时忽略
+func (q *StreamQueue) ensureGroup(ctx context.Context) error {
+	if q.groupReady.Load() {
+		return nil
+	}
+	err := q.redis.XGroupCreateMkStream(ctx, q.stream, q.config.Group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return err
+	}
+	q.groupReady.Store(true)
+	return nil
+}
+
+// resetGroupOnMissing Stream 被删除后消费者组随之消失，下次读取时重新创建
+func (q *StreamQueue) resetGroupOnMissing(err error) {
+	if err != nil && strings.HasPrefix(err.Error(), "NOGROUP") {
+		q.groupReady.Store(false)
+	}
+}
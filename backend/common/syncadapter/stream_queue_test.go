@@ -0,0 +1,84 @@
+package syncadapter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStreamQueue(t *testing.T, key string, config StreamQueueConfig) (*StreamQueue, *redis.Client) {
+	redisClient := redis.NewClient(&redis.Options{Addr: "localhost:6379"})
+	t.Cleanup(func() { redisClient.Close() })
+
+	queue := NewStreamQueue(redisClient, key, config)
+	if err := queue.Clear(); err != nil {
+		t.Skipf("Redis not available: %v", err)
+	}
+	return queue, redisClient
+}
+
+func TestStreamQueue_DequeueAck(t *testing.T) {
+	queue, _ := newTestStreamQueue(t, "test_stream_ack", StreamQueueConfig{Consumer: "c1"})
+
+	require.NoError(t, queue.Enqueue(&SyncTask{TaskId: "task_1", Type: SyncTypeOrder, OrderId: 123}))
+	task, err := queue.Dequeue(time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, "task_1", task.TaskId)
+	assert.NotEmpty(t, task.receipt)
+
+	length, _ := queue.Length()
+	assert.Equal(t, int64(1), length)
+	require.NoError(t, queue.Ack(task))
+	length, _ = queue.Length()
+	assert.Equal(t, int64(0), length)
+
+	_, err = queue.Dequeue(100 * time.Millisecond)
+	assert.ErrorIs(t, err, ErrQueueEmpty)
+}
+
+// TestStreamQueue_ReclaimFromCrashedConsumer 消费者取出任务后崩溃，其他消费者在 ClaimIdle 后接管
+func TestStreamQueue_ReclaimFromCrashedConsumer(t *testing.T) {
+	crashed, client := newTestStreamQueue(t, "test_stream_reclaim", StreamQueueConfig{Consumer: "crashed", ClaimIdle: 100 * time.Millisecond})
+	survivor := NewStreamQueue(client, "test_stream_reclaim", StreamQueueConfig{Consumer: "survivor", ClaimIdle: 100 * time.Millisecond})
+
+	require.NoError(t, crashed.Enqueue(&SyncTask{TaskId: "lost", Type: SyncTypeOrder, OrderId: 1}))
+	_, err := crashed.Dequeue(time.Second)
+	require.NoError(t, err)
+
+	// 未超过 ClaimIdle 时不接管
+	_, err = survivor.Dequeue(10 * time.Millisecond)
+	assert.ErrorIs(t, err, ErrQueueEmpty)
+
+	time.Sleep(150 * time.Millisecond)
+	survivor.lastReclaim.Store(0)
+	task, err := survivor.Dequeue(10 * time.Millisecond)
+	require.NoError(t, err)
+	assert.Equal(t, "lost", task.TaskId)
+	require.NoError(t, survivor.Ack(task))
+
+	length, _ := survivor.Length()
+	assert.Equal(t, int64(0), length)
+}
+
+func TestStreamQueue_PromoteDueMigratesLegacyList(t *testing.T) {
+	queue, _ := newTestStreamQueue(t, "test_stream_promote", StreamQueueConfig{})
+
+	// 旧版本实例仍写入列表
+	require.NoError(t, queue.list.Enqueue(&SyncTask{TaskId: "legacy", Type: SyncTypeOrder}))
+	require.NoError(t, queue.EnqueueDelayed(&SyncTask{TaskId: "due", Type: SyncTypeOrder}, 0))
+	require.NoError(t, queue.EnqueueDelayed(&SyncTask{TaskId: "later", Type: SyncTypeOrder}, time.Hour))
+
+	moved, err := queue.PromoteDue(time.Now(), 100)
+	require.NoError(t, err)
+	assert.Equal(t, 2, moved)
+
+	length, _ := queue.Length()
+	assert.Equal(t, int64(2), length)
+	delayed, _ := queue.DelayedLength()
+	assert.Equal(t, int64(1), delayed)
+	legacy, _ := queue.list.Length()
+	assert.Equal(t, int64(0), legacy)
+}
@@ -5,8 +5,12 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
 
+	"dmh/common/metrics"
+
 	_ "github.com/go-sql-driver/mysql"
 	_ "github.com/godror/godror"        // Oracle 驱动
 	_ "github.com/microsoft/go-mssqldb" // SQL Server 驱动
@@ -21,8 +25,9 @@ type ExternalSyncConfig struct {
 	User     string
 	Password string
 	Database string
-	Schema   string // SQL Server 专用
+	Schema   string // 外部表所在的 Schema（Oracle 为表所属用户），为空时使用连接默认值
 	Charset  string
+	Mapping  MappingConfig // 目标表与字段映射，未配置的目标使用默认映射
 }
 
 // SyncAdapter 外部数据库同步适配器
@@ -36,6 +41,11 @@ type SyncAdapter struct {
 
 // NewSyncAdapter 创建同步适配器
 func NewSyncAdapter(config ExternalSyncConfig) (*SyncAdapter, error) {
+	config.Mapping = config.Mapping.WithDefaults()
+	if err := config.Mapping.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid sync field mapping: %w", err)
+	}
+
 	// 1. 根据数据库类型连接
 	db, err := connectDatabase(config)
 	if err != nil {
@@ -59,13 +69,23 @@ func NewSyncAdapter(config ExternalSyncConfig) (*SyncAdapter, error) {
 	// 5. 初始化指标收集
 	metrics := NewSyncMetrics()
 
-	return &SyncAdapter{
+	adapter := &SyncAdapter{
 		db:      db,
 		config:  config,
 		mapper:  mapper,
 		logger:  logx.WithContext(context.Background()),
 		metrics: metrics,
-	}, nil
+	}
+
+	// 6. 校验目标表结构与映射一致，避免运行中才发现列不存在
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := adapter.ValidateTargetSchema(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return adapter, nil
 }
 
 // connectDatabase 根据类型连接不同的数据库
@@ -129,43 +149,33 @@ func (s *SyncAdapter) SyncOrder(ctx context.Context, data *SyncOrderData) error
 	startTime := time.Now()
 
 	// 1. 转换数据格式
-	externalData := s.mapper.MapOrder(data)
+	record := s.mapper.MapOrder(data)
 
 	// 2. 将FormData转换为JSON字符串
 	formDataJSON, err := json.Marshal(data.FormData)
 	if err != nil {
 		formDataJSON = []byte("{}")
 	}
+	record["form_data"] = string(formDataJSON)
 
-	// 3. 构建INSERT语句（支持幂等性）
-	query := `
-		INSERT INTO external_orders 
-		(order_id, campaign_id, member_id, unionid, phone, form_data, amount, pay_status, created_at, synced_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, NOW())
-		ON DUPLICATE KEY UPDATE
-		member_id = VALUES(member_id),
-		unionid = VALUES(unionid),
-		amount = VALUES(amount),
-		pay_status = VALUES(pay_status),
-		synced_at = NOW()
-	`
+	// 3. 按字段映射计算写入值，按目标数据库方言构建幂等写入语句
+	mapping := s.mapping().Order
+	args, err := mapping.values(record, data.FormData)
+	if err != nil {
+		s.metrics.RecordSync("order", false, time.Since(startTime))
+		return fmt.Errorf("failed to map order %d: %w", data.OrderId, err)
+	}
+	query, err := s.upsertQuery(mapping.upsertSpec())
+	if err != nil {
+		s.metrics.RecordSync("order", false, time.Since(startTime))
+		return err
+	}
 
 	// 4. 执行插入（带超时控制）
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	_, err = s.db.ExecContext(ctx, query,
-		externalData["order_id"],
-		externalData["campaign_id"],
-		externalData["member_id"],
-		externalData["unionid"],
-		externalData["phone"],
-		string(formDataJSON),
-		externalData["amount"],
-		externalData["pay_status"],
-		externalData["created_at"],
-	)
-
+	_, err = s.db.ExecContext(ctx, query, args...)
 	if err != nil {
 		s.metrics.RecordSync("order", false, time.Since(startTime))
 		return fmt.Errorf("failed to sync order to external database: %w", err)
@@ -194,35 +204,26 @@ func (s *SyncAdapter) SyncReward(ctx context.Context, data *SyncRewardData) erro
 	startTime := time.Now()
 
 	// 1. 转换数据格式
-	externalData := s.mapper.MapReward(data)
-
-	// 2. 构建INSERT语句
-	query := `
-		INSERT INTO external_rewards
-		(reward_id, user_id, member_id, order_id, amount, status, settled_at, synced_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, NOW())
-		ON DUPLICATE KEY UPDATE
-		member_id = VALUES(member_id),
-		amount = VALUES(amount),
-		status = VALUES(status),
-		settled_at = VALUES(settled_at),
-		synced_at = NOW()
-	`
+	record := s.mapper.MapReward(data)
+
+	// 2. 按字段映射计算写入值，按目标数据库方言构建幂等写入语句
+	mapping := s.mapping().Reward
+	args, err := mapping.values(record, nil)
+	if err != nil {
+		s.metrics.RecordSync("reward", false, time.Since(startTime))
+		return fmt.Errorf("failed to map reward %d: %w", data.RewardId, err)
+	}
+	query, err := s.upsertQuery(mapping.upsertSpec())
+	if err != nil {
+		s.metrics.RecordSync("reward", false, time.Since(startTime))
+		return err
+	}
 
 	// 3. 执行插入（带超时控制）
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	_, err := s.db.ExecContext(ctx, query,
-		externalData["reward_id"],
-		externalData["user_id"],
-		externalData["member_id"],
-		externalData["order_id"],
-		externalData["amount"],
-		externalData["status"],
-		externalData["settled_at"],
-	)
-
+	_, err = s.db.ExecContext(ctx, query, args...)
 	if err != nil {
 		s.metrics.RecordSync("reward", false, time.Since(startTime))
 		return fmt.Errorf("failed to sync reward to external database: %w", err)
@@ -235,6 +236,155 @@ func (s *SyncAdapter) SyncReward(ctx context.Context, data *SyncRewardData) erro
 	return nil
 }
 
+// SyncWithdrawalData 同步提现数据
+type SyncWithdrawalData struct {
+	WithdrawalId  int64
+	UserId        int64
+	BrandId       int64
+	DistributorId int64
+	Amount        float64
+	Status        string
+	PayType       string
+	TradeNo       string
+	PaidAt        *time.Time // 未打款时为空
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// SyncsWithdrawals 是否配置了提现同步目标
+func (s *SyncAdapter) SyncsWithdrawals() bool {
+	return s.mapping().Withdrawal.Configured()
+}
+
+// SyncWithdrawal 同步提现到外部数据库
+func (s *SyncAdapter) SyncWithdrawal(ctx context.Context, data *SyncWithdrawalData) error {
+	startTime := time.Now()
+
+	mapping := s.mapping().Withdrawal
+	if !mapping.Configured() {
+		return fmt.Errorf("withdrawal sync target is not configured")
+	}
+
+	args, err := mapping.values(s.mapper.MapWithdrawal(data), nil)
+	if err != nil {
+		s.metrics.RecordSync("withdrawal", false, time.Since(startTime))
+		return fmt.Errorf("failed to map withdrawal %d: %w", data.WithdrawalId, err)
+	}
+	query, err := s.upsertQuery(mapping.upsertSpec())
+	if err != nil {
+		s.metrics.RecordSync("withdrawal", false, time.Since(startTime))
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if _, err := s.db.ExecContext(ctx, query, args...); err != nil {
+		s.metrics.RecordSync("withdrawal", false, time.Since(startTime))
+		return fmt.Errorf("failed to sync withdrawal to external database: %w", err)
+	}
+
+	s.metrics.RecordSync("withdrawal", true, time.Since(startTime))
+	s.logger.Infof("withdrawal %d synced successfully in %v", data.WithdrawalId, time.Since(startTime))
+
+	return nil
+}
+
+// mapping 生效的字段映射
+func (s *SyncAdapter) mapping() MappingConfig {
+	return s.config.Mapping.WithDefaults()
+}
+
+// upsertQuery 按外部数据库类型生成写入语句，表名带上配置的 Schema
+func (s *SyncAdapter) upsertQuery(spec upsertSpec) (string, error) {
+	d, err := dialectFor(s.config.Type)
+	if err != nil {
+		return "", err
+	}
+	return d.Upsert(s.config.Schema, spec), nil
+}
+
+// ValidateTargetSchema 检查映射的目标表及列在外部数据库中存在
+func (s *SyncAdapter) ValidateTargetSchema(ctx context.Context) error {
+	d, err := dialectFor(s.config.Type)
+	if err != nil {
+		return err
+	}
+
+	mapping := s.mapping()
+	tables := []TableMapping{mapping.Order, mapping.Reward}
+	if mapping.Withdrawal.Configured() {
+		tables = append(tables, mapping.Withdrawal)
+	}
+	for _, table := range tables {
+		existing, err := s.tableColumns(ctx, d, table.Table)
+		if err != nil {
+			return err
+		}
+		if len(existing) == 0 {
+			return fmt.Errorf("sync target table %s not found", qualifiedTable(s.config.Schema, table.Table))
+		}
+
+		var missing []string
+		for _, col := range table.targetColumns() {
+			if !existing[strings.ToLower(col)] {
+				missing = append(missing, col)
+			}
+		}
+		if len(missing) > 0 {
+			return fmt.Errorf("sync target table %s is missing columns: %s",
+				qualifiedTable(s.config.Schema, table.Table), strings.Join(missing, ", "))
+		}
+	}
+	return nil
+}
+
+// TargetCount 目标表的记录数，target 为 order、reward 或 withdrawal
+func (s *SyncAdapter) TargetCount(ctx context.Context, target string) (int64, error) {
+	mapping := s.mapping()
+	var table TableMapping
+	switch target {
+	case MappingTargetOrder:
+		table = mapping.Order
+	case MappingTargetReward:
+		table = mapping.Reward
+	case MappingTargetWithdrawal:
+		table = mapping.Withdrawal
+	default:
+		return 0, fmt.Errorf("unknown sync target %q", target)
+	}
+	if !table.Configured() {
+		return 0, fmt.Errorf("%s sync target is not configured", target)
+	}
+
+	var count int64
+	query := "SELECT COUNT(*) FROM " + qualifiedTable(s.config.Schema, table.Table)
+	if err := s.db.QueryRowContext(ctx, query).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count %s: %w", table.Table, err)
+	}
+	return count, nil
+}
+
+// tableColumns 目标表的列名（小写）
+func (s *SyncAdapter) tableColumns(ctx context.Context, d dialect, table string) (map[string]bool, error) {
+	query, args := d.ColumnsQuery(s.config.Schema, table)
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query columns of %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		columns[strings.ToLower(name)] = true
+	}
+	return columns, rows.Err()
+}
+
 // AsyncSyncOrder 异步同步订单（放入队列）
 func (s *SyncAdapter) AsyncSyncOrder(data *SyncOrderData) {
 	// 这里应该将同步任务放入消息队列（如Redis、RabbitMQ等）
@@ -263,6 +413,28 @@ func (s *SyncAdapter) HealthCheck() error {
 	return s.db.PingContext(ctx)
 }
 
+// Ping 检查外部数据库连接并返回耗时
+func (s *SyncAdapter) Ping() (time.Duration, error) {
+	startTime := time.Now()
+	err := s.HealthCheck()
+	return time.Since(startTime), err
+}
+
+// DB 外部数据库连接，用于导出连接池指标
+func (s *SyncAdapter) DB() *sql.DB {
+	return s.db
+}
+
+// DatabaseType 外部数据库类型
+func (s *SyncAdapter) DatabaseType() string {
+	return s.config.Type
+}
+
+// Metrics 本进程启动以来的同步指标
+func (s *SyncAdapter) Metrics() *SyncMetrics {
+	return s.metrics
+}
+
 // =============================================================================
 // FieldMapper - 字段映射器
 // =============================================================================
@@ -300,11 +472,34 @@ func (m *FieldMapper) MapReward(data *SyncRewardData) map[string]interface{} {
 	}
 }
 
+// MapWithdrawal 映射提现数据
+func (m *FieldMapper) MapWithdrawal(data *SyncWithdrawalData) map[string]interface{} {
+	var paidAt interface{}
+	if data.PaidAt != nil {
+		paidAt = *data.PaidAt
+	}
+	return map[string]interface{}{
+		"withdrawal_id":  data.WithdrawalId,
+		"user_id":        data.UserId,
+		"brand_id":       data.BrandId,
+		"distributor_id": data.DistributorId,
+		"amount":         data.Amount,
+		"status":         data.Status,
+		"pay_type":       data.PayType,
+		"trade_no":       data.TradeNo,
+		"paid_at":        paidAt,
+		"created_at":     data.CreatedAt,
+		"updated_at":     data.UpdatedAt,
+	}
+}
+
 // =============================================================================
 // SyncMetrics - 同步指标收集
 // =============================================================================
 
+// SyncMetrics 同步计数，字段由 mu 保护，并发读取请使用 GetStats
 type SyncMetrics struct {
+	mu           sync.Mutex
 	TotalSyncs   int64
 	SuccessSyncs int64
 	FailedSyncs  int64
@@ -315,8 +510,18 @@ func NewSyncMetrics() *SyncMetrics {
 	return &SyncMetrics{}
 }
 
-// RecordSync 记录同步指标
+// RecordSync 记录同步指标，同时计入 Prometheus 指标。Worker 与业务请求会并发调用
 func (m *SyncMetrics) RecordSync(syncType string, success bool, duration time.Duration) {
+	result := "success"
+	if !success {
+		result = "failure"
+	}
+	metrics.SyncOperations.WithLabelValues(syncType, result).Inc()
+	metrics.SyncDuration.WithLabelValues(syncType).Observe(duration.Seconds())
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	m.TotalSyncs++
 	m.TotalTime += duration
 
@@ -329,6 +534,9 @@ func (m *SyncMetrics) RecordSync(syncType string, success bool, duration time.Du
 
 // GetStats 获取统计信息
 func (m *SyncMetrics) GetStats() map[string]interface{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	avgTime := time.Duration(0)
 	if m.TotalSyncs > 0 {
 		avgTime = m.TotalTime / time.Duration(m.TotalSyncs)
@@ -3,11 +3,14 @@ package syncadapter
 import (
 	"context"
 	"database/sql"
+	"sync"
 	"testing"
 	"time"
 
+	"dmh/common/metrics"
 	"dmh/model"
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 	"github.com/zeromicro/go-zero/core/logx"
@@ -135,6 +138,25 @@ func TestSyncMetrics_RecordSync_Failure(t *testing.T) {
 	assert.Equal(t, 50*time.Millisecond, metrics.TotalTime)
 }
 
+func TestSyncMetrics_RecordSync_Concurrent(t *testing.T) {
+	m := NewSyncMetrics()
+	exported := metrics.SyncOperations.WithLabelValues("withdrawal", "success")
+	before := testutil.ToFloat64(exported)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.RecordSync("withdrawal", true, time.Millisecond)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(50), m.GetStats()["total_syncs"])
+	assert.Equal(t, before+50, testutil.ToFloat64(exported))
+}
+
 func TestSyncMetrics_GetStats(t *testing.T) {
 	metrics := NewSyncMetrics()
 
@@ -3,23 +3,71 @@ package syncadapter
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"strconv"
 	"time"
 
+	"dmh/model"
+
 	"github.com/redis/go-redis/v9"
 	"github.com/zeromicro/go-zero/core/logx"
+	"gorm.io/gorm"
 )
 
 // SyncTask 同步任务
 type SyncTask struct {
-	TaskId    string    `json:"task_id"`
-	Type      string    `json:"type"` // order, reward
-	OrderId   int64     `json:"order_id"`
-	RewardId  int64     `json:"reward_id,omitempty"`
-	Attempts  int       `json:"attempts"`
-	CreatedAt time.Time `json:"created_at"`
+	TaskId       string    `json:"task_id"`
+	Type         string    `json:"type"` // order, reward, distributor_reward, withdrawal
+	OrderId      int64     `json:"order_id"`
+	RewardId     int64     `json:"reward_id,omitempty"`
+	WithdrawalId int64     `json:"withdrawal_id,omitempty"`
+	Attempts     int       `json:"attempts"`
+	LastError    string    `json:"last_error,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+
+	receipt string // 队列内的消息标识，Ack 时使用
+}
+
+// OrderSyncTasks 构建订单及其分销奖励的同步任务（含退款取消的奖励，使外部状态一并更新）
+func OrderSyncTasks(db *gorm.DB, orderId int64) ([]*SyncTask, error) {
+	now := time.Now()
+	tasks := []*SyncTask{{
+		TaskId:    fmt.Sprintf("order-%d-%d", orderId, now.UnixNano()),
+		Type:      SyncTypeOrder,
+		OrderId:   orderId,
+		CreatedAt: now,
+	}}
+
+	var rewardIds []int64
+	if err := db.Model(&model.DistributorReward{}).
+		Where("order_id = ?", orderId).
+		Pluck("id", &rewardIds).Error; err != nil {
+		return tasks, fmt.Errorf("query distributor rewards failed: %w", err)
+	}
+	for _, rewardId := range rewardIds {
+		tasks = append(tasks, &SyncTask{
+			TaskId:    fmt.Sprintf("reward-%d-%d", rewardId, now.UnixNano()),
+			Type:      SyncTypeDistributorReward,
+			OrderId:   orderId,
+			RewardId:  rewardId,
+			CreatedAt: now,
+		})
+	}
+	return tasks, nil
 }
 
-// SyncQueue 同步队列（基于Redis）
+// promoteDueScript 将到期的延迟任务原子地移回待处理队列
+var promoteDueScript = redis.NewScript(`
+local tasks = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+for _, task in ipairs(tasks) do
+	redis.call('ZREM', KEYS[1], task)
+	redis.call('RPUSH', KEYS[2], task)
+end
+return #tasks
+`)
+
+// SyncQueue 基于 Redis 列表的同步队列。取出即从列表删除，不支持确认，
+// 处理中进程崩溃会丢失任务，新部署请使用 StreamQueue
 type SyncQueue struct {
 	redis  *redis.Client
 	key    string
@@ -66,14 +114,93 @@ func (q *SyncQueue) Dequeue(timeout time.Duration) (*SyncTask, error) {
 	return &task, nil
 }
 
+// Ack 列表取出时已删除任务，无需确认
+func (q *SyncQueue) Ack(task *SyncTask) error {
+	return nil
+}
+
 // Length 获取队列长度
 func (q *SyncQueue) Length() (int64, error) {
 	ctx := context.Background()
 	return q.redis.LLen(ctx, q.key).Result()
 }
 
-// Clear 清空队列
+// Clear 清空队列（含延迟队列与死信队列）
 func (q *SyncQueue) Clear() error {
 	ctx := context.Background()
-	return q.redis.Del(ctx, q.key).Err()
+	return q.redis.Del(ctx, q.key, q.delayedKey(), q.deadKey()).Err()
+}
+
+// EnqueueDelayed 延迟入队，到期后由 PromoteDue 移回待处理队列
+func (q *SyncQueue) EnqueueDelayed(task *SyncTask, delay time.Duration) error {
+	data, err := json.Marshal(task)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	dueAt := time.Now().Add(delay).UnixMilli()
+	return q.redis.ZAdd(ctx, q.delayedKey(), redis.Z{Score: float64(dueAt), Member: data}).Err()
+}
+
+// PromoteDue 将到期的延迟任务移回待处理队列，返回移动的任务数
+func (q *SyncQueue) PromoteDue(now time.Time, limit int) (int, error) {
+	ctx := context.Background()
+	keys := []string{q.delayedKey(), q.key}
+	n, err := promoteDueScript.Run(ctx, q.redis, keys, strconv.FormatInt(now.UnixMilli(), 10), limit).Int()
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// DelayedLength 获取等待重试的任务数
+func (q *SyncQueue) DelayedLength() (int64, error) {
+	ctx := context.Background()
+	return q.redis.ZCard(ctx, q.delayedKey()).Result()
+}
+
+// DeadLetter 超过重试次数的任务移入死信队列
+func (q *SyncQueue) DeadLetter(task *SyncTask) error {
+	data, err := json.Marshal(task)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	return q.redis.RPush(ctx, q.deadKey(), data).Err()
+}
+
+// DeadLetters 查询死信队列中的任务
+func (q *SyncQueue) DeadLetters(offset, limit int64) ([]*SyncTask, error) {
+	ctx := context.Background()
+	items, err := q.redis.LRange(ctx, q.deadKey(), offset, offset+limit-1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	tasks := make([]*SyncTask, 0, len(items))
+	for _, item := range items {
+		var task SyncTask
+		if err := json.Unmarshal([]byte(item), &task); err != nil {
+			q.logger.Errorf("Failed to decode dead letter task: %v", err)
+			continue
+		}
+		tasks = append(tasks, &task)
+	}
+	return tasks, nil
+}
+
+// DeadLetterLength 获取死信队列长度
+func (q *SyncQueue) DeadLetterLength() (int64, error) {
+	ctx := context.Background()
+	return q.redis.LLen(ctx, q.deadKey()).Result()
+}
+
+func (q *SyncQueue) delayedKey() string {
+	return q.key + ":delayed"
+}
+
+func (q *SyncQueue) deadKey() string {
+	return q.key + ":dead"
 }
@@ -163,3 +163,43 @@ func TestSyncQueue_Clear(t *testing.T) {
 	length, _ := queue.Length()
 	assert.Equal(t, int64(0), length)
 }
+
+func TestSyncQueue_DelayedAndDeadLetter(t *testing.T) {
+	redisClient := redis.NewClient(&redis.Options{
+		Addr:     "localhost:6379",
+		Password: "",
+		DB:       0,
+	})
+	defer redisClient.Close()
+
+	queue := NewSyncQueue(redisClient, "test_delayed")
+
+	err := queue.Clear()
+	if err != nil {
+		t.Skipf("Redis not available: %v", err)
+		return
+	}
+
+	assert.NoError(t, queue.EnqueueDelayed(&SyncTask{TaskId: "due", Type: "order", Attempts: 1}, 0))
+	assert.NoError(t, queue.EnqueueDelayed(&SyncTask{TaskId: "later", Type: "order", Attempts: 1}, time.Hour))
+
+	moved, err := queue.PromoteDue(time.Now(), 100)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, moved)
+
+	length, _ := queue.Length()
+	assert.Equal(t, int64(1), length)
+	delayed, _ := queue.DelayedLength()
+	assert.Equal(t, int64(1), delayed)
+
+	task, err := queue.Dequeue(time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, "due", task.TaskId)
+
+	task.LastError = "connection refused"
+	assert.NoError(t, queue.DeadLetter(task))
+	dead, err := queue.DeadLetters(0, 10)
+	assert.NoError(t, err)
+	assert.Len(t, dead, 1)
+	assert.Equal(t, "connection refused", dead[0].LastError)
+}
@@ -8,6 +8,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"dmh/model"
@@ -16,68 +19,292 @@ import (
 	"gorm.io/gorm"
 )
 
-// SyncWorker 同步Worker
+// 同步任务类型
+const (
+	SyncTypeOrder             = "order"
+	SyncTypeReward            = "reward"
+	SyncTypeDistributorReward = "distributor_reward"
+	SyncTypeWithdrawal        = "withdrawal"
+)
+
+// 同步状态
+const (
+	SyncStatusPending = "pending"
+	SyncStatusSynced  = "synced"
+	SyncStatusFailed  = "failed"
+)
+
+// RetryPolicy 同步失败重试策略
+type RetryPolicy struct {
+	MaxAttempts int           // 最大尝试次数，用尽后进入死信队列
+	BaseBackoff time.Duration // 首次重试等待时间，之后每次翻倍
+	MaxBackoff  time.Duration // 重试等待时间上限
+}
+
+// DefaultRetryPolicy 默认重试策略
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 5,
+		BaseBackoff: 10 * time.Second,
+		MaxBackoff:  10 * time.Minute,
+	}
+}
+
+// Backoff 第 attempts 次失败后的等待时间
+func (p RetryPolicy) Backoff(attempts int) time.Duration {
+	backoff := p.BaseBackoff
+	for i := 1; i < attempts && backoff < p.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+	return backoff
+}
+
+// SyncWorker 同步Worker，可运行多个并发消费者
 type SyncWorker struct {
-	adapter *SyncAdapter
-	queue   *SyncQueue
-	db      *gorm.DB
-	logger  logx.Logger
-	stop    chan struct{}
+	adapter     *SyncAdapter
+	queue       TaskQueue
+	db          *gorm.DB
+	retry       RetryPolicy
+	concurrency int
+	locks       [64]sync.Mutex // 按同步对象分段加锁，同一记录不会被两个消费者同时同步
+	logger      logx.Logger
+	stop        chan struct{}
+	done        chan struct{}
+	started     atomic.Bool
+	beat        atomic.Int64 // 最近一次轮询的时间（UnixNano）
 }
 
+// workerStaleAfter 超过该时间没有轮询即认为Worker已停止响应
+const workerStaleAfter = 30 * time.Second
+
 // NewSyncWorker 创建同步Worker
-func NewSyncWorker(adapter *SyncAdapter, queue *SyncQueue, db *gorm.DB) *SyncWorker {
+func NewSyncWorker(adapter *SyncAdapter, queue TaskQueue, db *gorm.DB) *SyncWorker {
+	return NewSyncWorkerWithRetry(adapter, queue, db, DefaultRetryPolicy())
+}
+
+// NewSyncWorkerWithRetry 创建指定重试策略的同步Worker
+func NewSyncWorkerWithRetry(adapter *SyncAdapter, queue TaskQueue, db *gorm.DB, retry RetryPolicy) *SyncWorker {
+	defaults := DefaultRetryPolicy()
+	if retry.MaxAttempts <= 0 {
+		retry.MaxAttempts = defaults.MaxAttempts
+	}
+	if retry.BaseBackoff <= 0 {
+		retry.BaseBackoff = defaults.BaseBackoff
+	}
+	if retry.MaxBackoff < retry.BaseBackoff {
+		retry.MaxBackoff = defaults.MaxBackoff
+		if retry.MaxBackoff < retry.BaseBackoff {
+			retry.MaxBackoff = retry.BaseBackoff
+		}
+	}
+
 	return &SyncWorker{
-		adapter: adapter,
-		queue:   queue,
-		db:      db,
-		logger:  logx.WithContext(context.Background()),
-		stop:    make(chan struct{}),
+		adapter:     adapter,
+		queue:       queue,
+		db:          db,
+		retry:       retry,
+		concurrency: 1,
+		logger:      logx.WithContext(context.Background()),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+}
+
+// WithConcurrency 设置并发消费者数量，需在 Start 之前调用
+func (w *SyncWorker) WithConcurrency(n int) *SyncWorker {
+	if n > 0 {
+		w.concurrency = n
 	}
+	return w
 }
 
-// Start 启动Worker
+// Start 启动Worker，阻塞直到所有消费者退出
 func (w *SyncWorker) Start() {
-	w.logger.Info("SyncWorker started")
+	w.started.Store(true)
+	defer close(w.done)
+	w.logger.Infof("SyncWorker started: consumers=%d", w.concurrency)
 
+	var wg sync.WaitGroup
+	for i := 0; i < w.concurrency; i++ {
+		wg.Add(1)
+		go func(promote bool) {
+			defer wg.Done()
+			w.consume(promote)
+		}(i == 0)
+	}
+	wg.Wait()
+	w.logger.Info("SyncWorker stopped")
+}
+
+// consume 单个消费者的循环，promote 为 true 的消费者负责将到期的延迟任务移回队列
+func (w *SyncWorker) consume(promote bool) {
 	for {
+		w.beat.Store(time.Now().UnixNano())
 		select {
 		case <-w.stop:
-			w.logger.Info("SyncWorker stopping...")
 			return
 		default:
-			task, err := w.queue.Dequeue(5 * time.Second)
-			if err != nil {
-				w.logger.Errorf("Failed to dequeue task: %v", err)
-				continue
-			}
-			if task == nil {
-				continue
+		}
+
+		if promote {
+			if _, err := w.queue.PromoteDue(time.Now(), 100); err != nil {
+				w.logger.Errorf("Failed to promote delayed tasks: %v", err)
 			}
+		}
 
-			if task.Type == "order" {
-				w.syncOrder(task.OrderId)
-			} else if task.Type == "reward" {
-				w.syncReward(task.RewardId)
+		task, err := w.queue.Dequeue(5 * time.Second)
+		if errors.Is(err, ErrQueueEmpty) {
+			continue
+		}
+		if err != nil {
+			w.logger.Errorf("Failed to dequeue task: %v", err)
+			// 队列不可用时稍作等待，避免空转
+			select {
+			case <-w.stop:
+				return
+			case <-time.After(time.Second):
 			}
+			continue
+		}
+		if task == nil {
+			continue
 		}
+
+		w.handle(task)
+	}
+}
+
+// handle 处理任务并确认；重新入队失败时不确认，由队列重新投递
+func (w *SyncWorker) handle(task *SyncTask) {
+	lock := w.lockFor(task)
+	lock.Lock()
+	err := w.process(task)
+	lock.Unlock()
+	if err != nil {
+		return
+	}
+	if err := w.queue.Ack(task); err != nil {
+		w.logger.Errorf("Failed to ack task: taskId=%s, err=%v", task.TaskId, err)
 	}
 }
 
-// Stop 停止Worker
+// lockFor 同一同步对象的任务映射到同一把锁
+func (w *SyncWorker) lockFor(task *SyncTask) *sync.Mutex {
+	id := task.OrderId
+	switch task.Type {
+	case SyncTypeReward, SyncTypeDistributorReward:
+		id = task.RewardId
+	case SyncTypeWithdrawal:
+		id = task.WithdrawalId
+	}
+	h := fnv.New32a()
+	fmt.Fprintf(h, "%s:%d", task.Type, id)
+	return &w.locks[h.Sum32()%uint32(len(w.locks))]
+}
+
+// Stop 停止Worker，等待正在处理的任务完成
 func (w *SyncWorker) Stop() {
 	close(w.stop)
+	if w.started.Load() {
+		<-w.done
+	}
+}
+
+// LastHeartbeat 最近一次轮询队列的时间，未启动时为零值
+func (w *SyncWorker) LastHeartbeat() time.Time {
+	beat := w.beat.Load()
+	if beat == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, beat)
+}
+
+// Alive Worker 已启动、未停止且仍在轮询队列
+func (w *SyncWorker) Alive() bool {
+	if !w.started.Load() {
+		return false
+	}
+	select {
+	case <-w.done:
+		return false
+	default:
+	}
+	return time.Since(w.LastHeartbeat()) < workerStaleAfter
+}
+
+// process 执行一次同步：成功记为已同步，失败按退避时间延迟重试，超过最大次数后进入死信队列。
+// 仅在任务无法移入延迟队列或死信队列时返回错误
+func (w *SyncWorker) process(task *SyncTask) error {
+	var refId int64
+	var err error
+	switch task.Type {
+	case SyncTypeOrder:
+		refId = task.OrderId
+		err = w.syncOrder(task.OrderId)
+	case SyncTypeReward:
+		refId = task.RewardId
+		err = w.syncReward(task.RewardId)
+	case SyncTypeDistributorReward:
+		refId = task.RewardId
+		err = w.syncDistributorReward(task.RewardId)
+	case SyncTypeWithdrawal:
+		// 未配置提现同步目标时直接丢弃
+		if !w.adapter.SyncsWithdrawals() {
+			return nil
+		}
+		refId = task.WithdrawalId
+		err = w.syncWithdrawal(task.WithdrawalId)
+	default:
+		w.logger.Errorf("Unknown sync task type: taskId=%s, type=%s", task.TaskId, task.Type)
+		return nil
+	}
+
+	task.Attempts++
+	if err == nil {
+		task.LastError = ""
+		w.saveSyncLog(task.Type, refId, SyncStatusSynced, "", task.Attempts)
+		return nil
+	}
+
+	task.LastError = err.Error()
+	if task.Attempts >= w.retry.MaxAttempts {
+		w.logger.Errorf("Sync task dead-lettered: taskId=%s, type=%s, id=%d, attempts=%d, err=%v",
+			task.TaskId, task.Type, refId, task.Attempts, err)
+		w.saveSyncLog(task.Type, refId, SyncStatusFailed, task.LastError, task.Attempts)
+		if dlErr := w.queue.DeadLetter(task); dlErr != nil {
+			w.logger.Errorf("Failed to dead-letter task: taskId=%s, err=%v", task.TaskId, dlErr)
+			return dlErr
+		}
+		return nil
+	}
+
+	backoff := w.retry.Backoff(task.Attempts)
+	w.logger.Errorf("Sync task failed, retry in %s: taskId=%s, type=%s, id=%d, attempts=%d, err=%v",
+		backoff, task.TaskId, task.Type, refId, task.Attempts, err)
+	w.saveSyncLog(task.Type, refId, SyncStatusPending, task.LastError, task.Attempts)
+	if qErr := w.queue.EnqueueDelayed(task, backoff); qErr != nil {
+		w.logger.Errorf("Failed to requeue task: taskId=%s, err=%v", task.TaskId, qErr)
+		return qErr
+	}
+	return nil
 }
 
 // syncOrder 同步订单
-func (w *SyncWorker) syncOrder(orderId int64) {
+func (w *SyncWorker) syncOrder(orderId int64) error {
 	var order model.Order
 	if err := w.db.Where("id = ? AND deleted_at IS NULL", orderId).First(&order).Error; err != nil {
 		w.logger.Errorf("Failed to query order: %v", err)
-		return
+		return fmt.Errorf("get order failed: %w", err)
 	}
 
-	// 2. 解析表单数据
+	return w.adapter.SyncOrder(context.Background(), orderSyncData(&order))
+}
+
+// orderSyncData 由订单构建同步数据
+func orderSyncData(order *model.Order) *SyncOrderData {
 	var formData map[string]interface{}
 	if err := json.Unmarshal([]byte(order.FormData), &formData); err != nil {
 		formData = make(map[string]interface{})
@@ -88,8 +315,7 @@ func (w *SyncWorker) syncOrder(orderId int64) {
 		memberID = *order.MemberID
 	}
 
-	// 3. 构建同步数据
-	syncData := &SyncOrderData{
+	return &SyncOrderData{
 		OrderId:    order.Id,
 		CampaignId: order.CampaignId,
 		MemberId:   memberID,
@@ -100,9 +326,6 @@ func (w *SyncWorker) syncOrder(orderId int64) {
 		PayStatus:  order.PayStatus,
 		CreatedAt:  order.CreatedAt,
 	}
-
-	// 4. 执行同步
-	_ = w.adapter.SyncOrder(context.Background(), syncData)
 }
 
 // syncReward 同步奖励
@@ -133,90 +356,109 @@ func (w *SyncWorker) syncReward(rewardId int64) error {
 	}
 
 	// 3. 执行同步
-	_ = w.adapter.SyncReward(context.Background(), syncData)
-	return nil
+	return w.adapter.SyncReward(context.Background(), syncData)
 }
 
-// updateSyncStatus 更新同步状态
-func (w *SyncWorker) updateSyncStatus(orderId int64, status, errorMsg string) {
-	var syncedAt *time.Time
-	if status == "synced" {
-		now := time.Now()
-		syncedAt = &now
+// syncDistributorReward 同步分销奖励（支付成功时结算产生）
+func (w *SyncWorker) syncDistributorReward(rewardId int64) error {
+	var reward model.DistributorReward
+	if err := w.db.Where("id = ?", rewardId).First(&reward).Error; err != nil {
+		return fmt.Errorf("get distributor reward failed: %w", err)
 	}
 
-	// 创建或更新sync_logs记录
-	log := model.SyncLog{
-		OrderId:    orderId,
-		SyncType:   "order",
-		SyncStatus: status,
-		ErrorMsg:   errorMsg,
-		SyncedAt:   syncedAt,
-		UpdatedAt:  time.Now(),
-	}
+	return w.adapter.SyncReward(context.Background(), distributorRewardSyncData(&reward))
+}
 
-	// 尝试查找现有记录
-	var existing model.SyncLog
-	result := w.db.Where("order_id = ? AND sync_type = ?", orderId, "order").First(&existing)
+// distributorRewardSyncData 由分销奖励构建同步数据
+func distributorRewardSyncData(reward *model.DistributorReward) *SyncRewardData {
+	data := &SyncRewardData{
+		RewardId: reward.Id,
+		UserId:   reward.UserId,
+		OrderId:  reward.OrderId,
+		Amount:   reward.Amount,
+		Status:   reward.Status,
+	}
+	if reward.SettledAt != nil {
+		data.SettledAt = *reward.SettledAt
+	}
+	return data
+}
 
-	if result.Error == nil {
-		updates := map[string]interface{}{
-			"sync_status": status,
-			"error_msg":   errorMsg,
-			"synced_at":   syncedAt,
-			"attempts":    existing.Attempts + 1,
-			"updated_at":  time.Now(),
-		}
-		_ = w.db.Model(&model.SyncLog{}).Where("id = ?", existing.Id).Updates(updates).Error
-		log.Attempts = existing.Attempts + 1
-	} else if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-		// 创建新记录
-		w.db.Create(&log)
-	} else {
-		w.logger.Errorf("query sync log failed: %v", result.Error)
+// syncWithdrawal 同步提现
+func (w *SyncWorker) syncWithdrawal(withdrawalId int64) error {
+	var withdrawal model.Withdrawal
+	if err := w.db.Where("id = ?", withdrawalId).First(&withdrawal).Error; err != nil {
+		return fmt.Errorf("get withdrawal failed: %w", err)
 	}
 
-	w.logger.Infof("Sync status updated: orderId=%d, status=%s, attempts=%d", orderId, status, log.Attempts)
+	return w.adapter.SyncWithdrawal(context.Background(), &SyncWithdrawalData{
+		WithdrawalId:  withdrawal.ID,
+		UserId:        withdrawal.UserID,
+		BrandId:       withdrawal.BrandId,
+		DistributorId: withdrawal.DistributorId,
+		Amount:        withdrawal.Amount,
+		Status:        withdrawal.Status,
+		PayType:       withdrawal.PayType,
+		TradeNo:       withdrawal.TradeNo,
+		PaidAt:        withdrawal.PaidAt,
+		CreatedAt:     withdrawal.CreatedAt,
+		UpdatedAt:     withdrawal.UpdatedAt,
+	})
 }
 
-// updateRewardSyncStatus 更新奖励同步状态
-func (w *SyncWorker) updateRewardSyncStatus(rewardId int64, status, errorMsg string) {
+// saveSyncLog 写入一次同步尝试的历史，并更新 sync_logs 中该对象的最新状态；订单同步还会回写订单的同步状态
+func (w *SyncWorker) saveSyncLog(syncType string, refId int64, status, errorMsg string, attempts int) {
+	now := time.Now()
 	var syncedAt *time.Time
-	if status == "synced" {
-		now := time.Now()
+	if status == SyncStatusSynced {
 		syncedAt = &now
 	}
 
-	// 创建或更新sync_logs记录
-	log := model.SyncLog{
-		OrderId:    rewardId,
-		SyncType:   "reward",
+	if err := w.db.Create(&model.SyncAttempt{
+		RefId:      refId,
+		SyncType:   syncType,
+		Attempt:    attempts,
 		SyncStatus: status,
 		ErrorMsg:   errorMsg,
-		SyncedAt:   syncedAt,
-		UpdatedAt:  time.Now(),
+	}).Error; err != nil {
+		w.logger.Errorf("create sync attempt failed: %v", err)
 	}
 
-	// 尝试查找现有记录
 	var existing model.SyncLog
-	result := w.db.Where("order_id = ? AND sync_type = ?", rewardId, "reward").First(&existing)
-
+	result := w.db.Where("order_id = ? AND sync_type = ?", refId, syncType).First(&existing)
 	if result.Error == nil {
 		updates := map[string]interface{}{
 			"sync_status": status,
 			"error_msg":   errorMsg,
 			"synced_at":   syncedAt,
-			"attempts":    existing.Attempts + 1,
-			"updated_at":  time.Now(),
+			"attempts":    attempts,
+			"updated_at":  now,
+		}
+		if err := w.db.Model(&model.SyncLog{}).Where("id = ?", existing.Id).Updates(updates).Error; err != nil {
+			w.logger.Errorf("update sync log failed: %v", err)
 		}
-		_ = w.db.Model(&model.SyncLog{}).Where("id = ?", existing.Id).Updates(updates).Error
-		log.Attempts = existing.Attempts + 1
 	} else if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-		// 创建新记录
-		w.db.Create(&log)
+		log := model.SyncLog{
+			OrderId:    refId,
+			SyncType:   syncType,
+			SyncStatus: status,
+			Attempts:   attempts,
+			ErrorMsg:   errorMsg,
+			SyncedAt:   syncedAt,
+			UpdatedAt:  now,
+		}
+		if err := w.db.Create(&log).Error; err != nil {
+			w.logger.Errorf("create sync log failed: %v", err)
+		}
 	} else {
-		w.logger.Errorf("query reward sync log failed: %v", result.Error)
+		w.logger.Errorf("query sync log failed: %v", result.Error)
+	}
+
+	if syncType == SyncTypeOrder {
+		if err := w.db.Model(&model.Order{}).Where("id = ?", refId).Update("sync_status", status).Error; err != nil {
+			w.logger.Errorf("update order sync status failed: %v", err)
+		}
 	}
 
-	w.logger.Infof("Reward sync status updated: rewardId=%d, status=%s, attempts=%d", rewardId, status, log.Attempts)
+	w.logger.Infof("Sync status updated: type=%s, id=%d, status=%s, attempts=%d", syncType, refId, status, attempts)
 }
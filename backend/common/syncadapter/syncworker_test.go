@@ -2,9 +2,11 @@ package syncadapter
 
 import (
 	"testing"
+	"time"
 
 	"dmh/model"
 
+	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
@@ -17,7 +19,7 @@ func setupSyncWorkerTestDB(t *testing.T) *gorm.DB {
 		t.Fatalf("Failed to open test database: %v", err)
 	}
 
-	err = db.AutoMigrate(&model.Order{}, &model.Reward{}, &model.SyncLog{})
+	err = db.AutoMigrate(&model.Order{}, &model.Reward{}, &model.SyncLog{}, &model.SyncAttempt{})
 	if err != nil {
 		t.Fatalf("Failed to migrate database: %v", err)
 	}
@@ -28,6 +30,9 @@ func setupSyncWorkerTestDB(t *testing.T) *gorm.DB {
 	if err := db.Exec("TRUNCATE TABLE sync_logs").Error; err != nil {
 		t.Fatalf("Failed to truncate sync_logs: %v", err)
 	}
+	if err := db.Exec("TRUNCATE TABLE sync_attempts").Error; err != nil {
+		t.Fatalf("Failed to truncate sync_attempts: %v", err)
+	}
 	if err := db.Exec("TRUNCATE TABLE rewards").Error; err != nil {
 		t.Fatalf("Failed to truncate rewards: %v", err)
 	}
@@ -67,114 +72,158 @@ func TestSyncWorker_Stop(t *testing.T) {
 	})
 }
 
-func TestUpdateSyncStatus_Create(t *testing.T) {
+func TestSaveSyncLog_Create(t *testing.T) {
 	db := setupSyncWorkerTestDB(t)
-	adapter := &SyncAdapter{}
-	queue := &SyncQueue{}
+	worker := NewSyncWorker(&SyncAdapter{}, &SyncQueue{}, db)
 
-	worker := NewSyncWorker(adapter, queue, db)
-
-	worker.updateSyncStatus(123, "synced", "")
+	worker.saveSyncLog(SyncTypeOrder, 123, SyncStatusSynced, "", 1)
 
 	var log model.SyncLog
-	err := db.Where("order_id = ? AND sync_type = ?", 123, "order").First(&log).Error
+	err := db.Where("order_id = ? AND sync_type = ?", 123, SyncTypeOrder).First(&log).Error
 
 	assert.NoError(t, err)
 	assert.Equal(t, int64(123), log.OrderId)
-	assert.Equal(t, "order", log.SyncType)
-	assert.Equal(t, "synced", log.SyncStatus)
-	assert.Equal(t, 0, log.Attempts)
+	assert.Equal(t, SyncTypeOrder, log.SyncType)
+	assert.Equal(t, SyncStatusSynced, log.SyncStatus)
+	assert.Equal(t, 1, log.Attempts)
 	assert.NotNil(t, log.SyncedAt)
 }
 
-func TestUpdateSyncStatus_Update(t *testing.T) {
+func TestSaveSyncLog_RecordsEveryAttempt(t *testing.T) {
 	db := setupSyncWorkerTestDB(t)
-	adapter := &SyncAdapter{}
-	queue := &SyncQueue{}
+	worker := NewSyncWorker(&SyncAdapter{}, &SyncQueue{}, db)
+
+	worker.saveSyncLog(SyncTypeReward, 456, SyncStatusPending, "connection error", 1)
+	worker.saveSyncLog(SyncTypeReward, 456, SyncStatusFailed, "database error", 2)
+
+	// sync_logs 只保留最新状态
+	var logs []model.SyncLog
+	assert.NoError(t, db.Where("order_id = ? AND sync_type = ?", 456, SyncTypeReward).Find(&logs).Error)
+	if assert.Len(t, logs, 1) {
+		assert.Equal(t, SyncStatusFailed, logs[0].SyncStatus)
+		assert.Equal(t, "database error", logs[0].ErrorMsg)
+		assert.Equal(t, 2, logs[0].Attempts)
+		assert.Nil(t, logs[0].SyncedAt)
+	}
 
-	worker := NewSyncWorker(adapter, queue, db)
+	// 每次尝试各有一行历史
+	var attempts []model.SyncAttempt
+	assert.NoError(t, db.Where("ref_id = ? AND sync_type = ?", 456, SyncTypeReward).Order("id ASC").Find(&attempts).Error)
+	if assert.Len(t, attempts, 2) {
+		assert.Equal(t, 1, attempts[0].Attempt)
+		assert.Equal(t, SyncStatusPending, attempts[0].SyncStatus)
+		assert.Equal(t, "connection error", attempts[0].ErrorMsg)
+		assert.Equal(t, 2, attempts[1].Attempt)
+		assert.Equal(t, SyncStatusFailed, attempts[1].SyncStatus)
+	}
+}
 
-	worker.updateSyncStatus(123, "synced", "")
+func TestSaveSyncLog_UpdatesOrderSyncStatus(t *testing.T) {
+	db := setupSyncWorkerTestDB(t)
+	worker := NewSyncWorker(&SyncAdapter{}, &SyncQueue{}, db)
 
-	worker.updateSyncStatus(123, "synced", "")
+	order := &model.Order{CampaignId: 1, Phone: "13800138000", FormData: `{}`, Status: "paid", PayStatus: "paid"}
+	assert.NoError(t, db.Create(order).Error)
 
-	var log model.SyncLog
-	err := db.Where("order_id = ? AND sync_type = ?", 123, "order").First(&log).Error
+	worker.saveSyncLog(SyncTypeOrder, order.Id, SyncStatusFailed, "timeout", 3)
 
-	assert.NoError(t, err)
-	assert.Equal(t, 1, log.Attempts)
+	var updated model.Order
+	assert.NoError(t, db.First(&updated, order.Id).Error)
+	assert.Equal(t, SyncStatusFailed, updated.SyncStatus)
 }
 
-func TestUpdateSyncStatus_Failed(t *testing.T) {
-	db := setupSyncWorkerTestDB(t)
-	adapter := &SyncAdapter{}
-	queue := &SyncQueue{}
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 5, BaseBackoff: 10 * time.Second, MaxBackoff: time.Minute}
 
-	worker := NewSyncWorker(adapter, queue, db)
-
-	worker.updateSyncStatus(123, "failed", "connection error")
+	assert.Equal(t, 10*time.Second, policy.Backoff(1))
+	assert.Equal(t, 20*time.Second, policy.Backoff(2))
+	assert.Equal(t, 40*time.Second, policy.Backoff(3))
+	assert.Equal(t, time.Minute, policy.Backoff(4))
+	assert.Equal(t, time.Minute, policy.Backoff(10))
+}
 
-	var log model.SyncLog
-	err := db.Where("order_id = ? AND sync_type = ?", 123, "order").First(&log).Error
+func TestNewSyncWorkerWithRetry_Defaults(t *testing.T) {
+	worker := NewSyncWorkerWithRetry(&SyncAdapter{}, &SyncQueue{}, nil, RetryPolicy{})
 
-	assert.NoError(t, err)
-	assert.Equal(t, "failed", log.SyncStatus)
-	assert.Equal(t, "connection error", log.ErrorMsg)
-	assert.Nil(t, log.SyncedAt)
+	assert.Equal(t, DefaultRetryPolicy(), worker.retry)
 }
 
-func TestUpdateRewardSyncStatus_Create(t *testing.T) {
-	db := setupSyncWorkerTestDB(t)
-	adapter := &SyncAdapter{}
-	queue := &SyncQueue{}
+func TestSyncWorker_Process_RetryThenDeadLetter(t *testing.T) {
+	redisClient := redis.NewClient(&redis.Options{Addr: "localhost:6379"})
+	defer redisClient.Close()
 
-	worker := NewSyncWorker(adapter, queue, db)
+	queue := NewSyncQueue(redisClient, "test_worker_retry")
+	if err := queue.Clear(); err != nil {
+		t.Skipf("Redis not available: %v", err)
+		return
+	}
 
-	worker.updateRewardSyncStatus(456, "synced", "")
+	db := setupSyncWorkerTestDB(t)
+	worker := NewSyncWorkerWithRetry(&SyncAdapter{}, queue, db, RetryPolicy{MaxAttempts: 2, BaseBackoff: time.Hour, MaxBackoff: time.Hour})
 
-	var log model.SyncLog
-	err := db.Where("order_id = ? AND sync_type = ?", 456, "reward").First(&log).Error
+	// 订单不存在，同步必然失败
+	task := &SyncTask{TaskId: "order-404", Type: SyncTypeOrder, OrderId: 404}
+	worker.process(task)
 
-	assert.NoError(t, err)
-	assert.Equal(t, int64(456), log.OrderId)
-	assert.Equal(t, "reward", log.SyncType)
-	assert.Equal(t, "synced", log.SyncStatus)
-	assert.Equal(t, 0, log.Attempts)
-	assert.NotNil(t, log.SyncedAt)
-}
+	delayed, _ := queue.DelayedLength()
+	assert.Equal(t, int64(1), delayed)
+	var log model.SyncLog
+	assert.NoError(t, db.Where("order_id = ? AND sync_type = ?", 404, SyncTypeOrder).First(&log).Error)
+	assert.Equal(t, SyncStatusPending, log.SyncStatus)
+	assert.Equal(t, 1, log.Attempts)
 
-func TestUpdateRewardSyncStatus_Update(t *testing.T) {
-	db := setupSyncWorkerTestDB(t)
-	adapter := &SyncAdapter{}
-	queue := &SyncQueue{}
+	worker.process(task)
 
-	worker := NewSyncWorker(adapter, queue, db)
+	dead, _ := queue.DeadLetterLength()
+	assert.Equal(t, int64(1), dead)
+	assert.NoError(t, db.Where("order_id = ? AND sync_type = ?", 404, SyncTypeOrder).First(&log).Error)
+	assert.Equal(t, SyncStatusFailed, log.SyncStatus)
+	assert.Equal(t, 2, log.Attempts)
+	assert.NotEmpty(t, log.ErrorMsg)
 
-	worker.updateRewardSyncStatus(456, "synced", "")
+	var attempts int64
+	assert.NoError(t, db.Model(&model.SyncAttempt{}).Where("ref_id = ? AND sync_type = ?", 404, SyncTypeOrder).Count(&attempts).Error)
+	assert.Equal(t, int64(2), attempts)
+}
 
-	worker.updateRewardSyncStatus(456, "synced", "")
+func TestSyncWorker_Alive(t *testing.T) {
+	worker := NewSyncWorker(&SyncAdapter{}, &SyncQueue{}, nil)
+	assert.False(t, worker.Alive())
+	assert.True(t, worker.LastHeartbeat().IsZero())
 
-	var log model.SyncLog
-	err := db.Where("order_id = ? AND sync_type = ?", 456, "reward").First(&log).Error
+	worker.started.Store(true)
+	worker.beat.Store(time.Now().UnixNano())
+	assert.True(t, worker.Alive())
 
-	assert.NoError(t, err)
-	assert.Equal(t, 1, log.Attempts)
+	worker.beat.Store(time.Now().Add(-time.Minute).UnixNano())
+	assert.False(t, worker.Alive())
 }
 
-func TestUpdateRewardSyncStatus_Failed(t *testing.T) {
-	db := setupSyncWorkerTestDB(t)
-	adapter := &SyncAdapter{}
-	queue := &SyncQueue{}
+// TestSyncWorker_ConcurrentConsumersAckTasks 多个消费者并发处理，每个任务处理后确认
+func TestSyncWorker_ConcurrentConsumersAckTasks(t *testing.T) {
+	queue := NewMemoryQueue()
+	for i := int64(1); i <= 50; i++ {
+		// 未配置提现同步目标，任务处理后直接确认
+		assert.NoError(t, queue.Enqueue(&SyncTask{TaskId: "w", Type: SyncTypeWithdrawal, WithdrawalId: i}))
+	}
 
-	worker := NewSyncWorker(adapter, queue, db)
+	worker := NewSyncWorker(&SyncAdapter{}, queue, nil).WithConcurrency(4)
+	go worker.Start()
 
-	worker.updateRewardSyncStatus(456, "failed", "database error")
+	assert.Eventually(t, func() bool {
+		length, _ := queue.Length()
+		return length == 0
+	}, 5*time.Second, 10*time.Millisecond)
+	worker.Stop()
+	assert.False(t, worker.Alive())
+}
 
-	var log model.SyncLog
-	err := db.Where("order_id = ? AND sync_type = ?", 456, "reward").First(&log).Error
+func TestSyncWorker_LockFor(t *testing.T) {
+	worker := NewSyncWorker(&SyncAdapter{}, NewMemoryQueue(), nil)
 
-	assert.NoError(t, err)
-	assert.Equal(t, "failed", log.SyncStatus)
-	assert.Equal(t, "database error", log.ErrorMsg)
-	assert.Nil(t, log.SyncedAt)
+	a := worker.lockFor(&SyncTask{Type: SyncTypeOrder, OrderId: 7})
+	b := worker.lockFor(&SyncTask{Type: SyncTypeOrder, OrderId: 7, TaskId: "other"})
+	assert.Same(t, a, b)
+	assert.Same(t, worker.lockFor(&SyncTask{Type: SyncTypeDistributorReward, RewardId: 3}),
+		worker.lockFor(&SyncTask{Type: SyncTypeDistributorReward, OrderId: 9, RewardId: 3}))
 }
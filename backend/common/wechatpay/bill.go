@@ -0,0 +1,259 @@
+package wechatpay
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrBillNotExist 当日没有交易，微信不生成账单
+var ErrBillNotExist = errors.New("微信支付账单不存在")
+
+// 账单交易状态
+const (
+	BillTradeStateSuccess = "SUCCESS"
+	BillTradeStateRefund  = "REFUND"
+	BillTradeStateRevoked = "REVOKED"
+)
+
+// TradeBillRecord 交易账单中的一行（金额单位：分）
+type TradeBillRecord struct {
+	TradeTime     time.Time
+	AppID         string
+	MchID         string
+	TransactionId string
+	OutTradeNo    string
+	TradeType     string
+	TradeState    string // SUCCESS, REFUND, REVOKED
+	TotalFee      int64  // 订单金额
+	RefundId      string
+	OutRefundNo   string
+	RefundFee     int64
+}
+
+// TradeBill 解析后的交易账单
+type TradeBill struct {
+	Records []TradeBillRecord
+}
+
+type downloadBillErrorResponse struct {
+	ReturnCode string `xml:"return_code"`
+	ReturnMsg  string `xml:"return_msg"`
+	ErrorCode  string `xml:"error_code"`
+}
+
+type v3TradeBillResponse struct {
+	HashType    string `json:"hash_type"`
+	HashValue   string `json:"hash_value"`
+	DownloadURL string `json:"download_url"`
+}
+
+// 账单表头（全部订单账单）
+const (
+	billColTradeTime     = "交易时间"
+	billColAppID         = "公众账号ID"
+	billColMchID         = "商户号"
+	billColTransactionId = "微信订单号"
+	billColOutTradeNo    = "商户订单号"
+	billColTradeType     = "交易类型"
+	billColTradeState    = "交易状态"
+	billColTotalFee      = "订单金额"
+	billColSettlementFee = "应结订单金额"
+	billColRefundId      = "微信退款单号"
+	billColOutRefundNo   = "商户退款单号"
+	billColRefundFee     = "申请退款金额"
+	billColRefundFeeOld  = "退款金额"
+	billSummaryPrefix    = "总交易单数"
+)
+
+// DownloadTradeBill 下载指定日期的全部交易账单（CSV 文本）
+func (s *Service) DownloadTradeBill(billDate time.Time) ([]byte, error) {
+	if s.config.MockEnabled {
+		return []byte(billColTradeTime + "," + billColTransactionId + "," + billColOutTradeNo + "," + billColTradeState + "," + billColTotalFee + "\n"), nil
+	}
+
+	if s.IsV3() {
+		return s.downloadTradeBillV3(billDate)
+	}
+
+	if strings.TrimSpace(s.config.AppID) == "" || strings.TrimSpace(s.config.MchID) == "" || strings.TrimSpace(s.config.APIKey) == "" {
+		return nil, errors.New("微信支付配置不完整")
+	}
+
+	params := map[string]string{
+		"appid":     s.config.AppID,
+		"mch_id":    s.config.MchID,
+		"nonce_str": generateNonce(),
+		"bill_date": billDate.Format("20060102"),
+		"bill_type": "ALL",
+	}
+	params["sign"] = s.GenerateMD5Sign(params)
+
+	body, err := s.postXMLRaw(s.downloadBillURL, params)
+	if err != nil {
+		return nil, err
+	}
+
+	// 成功时直接返回账单文本，失败时返回 XML
+	if bytes.HasPrefix(bytes.TrimSpace(body), []byte("<xml>")) {
+		var resp downloadBillErrorResponse
+		if err := xml.Unmarshal(body, &resp); err != nil {
+			return nil, fmt.Errorf("解析微信支付响应失败: %w", err)
+		}
+		if resp.ErrorCode == "20002" || strings.EqualFold(strings.TrimSpace(resp.ReturnMsg), "No Bill Exist") {
+			return nil, ErrBillNotExist
+		}
+		return nil, fmt.Errorf("微信支付下载账单失败: %s %s", strings.TrimSpace(resp.ErrorCode), strings.TrimSpace(resp.ReturnMsg))
+	}
+
+	return body, nil
+}
+
+func (s *Service) downloadTradeBillV3(billDate time.Time) ([]byte, error) {
+	if err := s.checkV3Config(); err != nil {
+		return nil, err
+	}
+
+	query := url.Values{}
+	query.Set("bill_date", billDate.Format("2006-01-02"))
+	query.Set("bill_type", "ALL")
+	respBody, err := s.doV3Request(http.MethodGet, "/v3/bill/tradebill?"+query.Encode(), nil)
+	if err != nil {
+		if strings.Contains(err.Error(), "NO_STATEMENT_EXIST") {
+			return nil, ErrBillNotExist
+		}
+		return nil, err
+	}
+
+	var bill v3TradeBillResponse
+	if err := json.Unmarshal(respBody, &bill); err != nil {
+		return nil, fmt.Errorf("解析微信支付响应失败: %w", err)
+	}
+
+	downloadURL, err := url.Parse(bill.DownloadURL)
+	if err != nil || bill.DownloadURL == "" {
+		return nil, errors.New("微信支付账单下载地址无效")
+	}
+
+	// 下载接口不返回应答签名，以申请账单时返回的摘要校验完整性
+	_, data, err := s.sendV3Request(http.MethodGet, downloadURL.RequestURI(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(bill.HashType, "SHA1") {
+		sum := sha1.Sum(data)
+		if !strings.EqualFold(hex.EncodeToString(sum[:]), bill.HashValue) {
+			return nil, errors.New("微信支付账单摘要校验失败")
+		}
+	}
+
+	return data, nil
+}
+
+// ParseTradeBill 解析交易账单 CSV，字段值带有防止表格软件转换格式的 ` 前缀，末尾的汇总部分会被忽略
+func ParseTradeBill(data []byte) (*TradeBill, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return &TradeBill{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取账单表头失败: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+	for _, required := range []string{billColTransactionId, billColOutTradeNo, billColTradeState} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("账单缺少字段: %s", required)
+		}
+	}
+
+	totalCol := billColTotalFee
+	if _, ok := columns[totalCol]; !ok {
+		totalCol = billColSettlementFee
+	}
+	refundCol := billColRefundFee
+	if _, ok := columns[refundCol]; !ok {
+		refundCol = billColRefundFeeOld
+	}
+
+	bill := &TradeBill{}
+	for line := 2; ; line++ {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("读取账单第%d行失败: %w", line, err)
+		}
+		if len(row) == 0 || strings.HasPrefix(strings.TrimSpace(row[0]), billSummaryPrefix) {
+			break
+		}
+
+		field := func(name string) string {
+			idx, ok := columns[name]
+			if !ok || idx >= len(row) {
+				return ""
+			}
+			return strings.TrimPrefix(strings.TrimSpace(row[idx]), "`")
+		}
+
+		record := TradeBillRecord{
+			AppID:         field(billColAppID),
+			MchID:         field(billColMchID),
+			TransactionId: field(billColTransactionId),
+			OutTradeNo:    field(billColOutTradeNo),
+			TradeType:     field(billColTradeType),
+			TradeState:    field(billColTradeState),
+			RefundId:      field(billColRefundId),
+			OutRefundNo:   field(billColOutRefundNo),
+		}
+		if record.TotalFee, err = parseBillAmount(field(totalCol)); err != nil {
+			return nil, fmt.Errorf("账单第%d行订单金额无效: %w", line, err)
+		}
+		if record.RefundFee, err = parseBillAmount(field(refundCol)); err != nil {
+			return nil, fmt.Errorf("账单第%d行退款金额无效: %w", line, err)
+		}
+		if value := field(billColTradeTime); value != "" {
+			if record.TradeTime, err = time.ParseInLocation("2006-01-02 15:04:05", value, time.Local); err != nil {
+				return nil, fmt.Errorf("账单第%d行交易时间无效: %w", line, err)
+			}
+		}
+
+		bill.Records = append(bill.Records, record)
+	}
+
+	return bill, nil
+}
+
+// parseBillAmount 账单金额（元）转换为分
+func parseBillAmount(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	amount, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, err
+	}
+	return int64(math.Round(amount * 100)), nil
+}
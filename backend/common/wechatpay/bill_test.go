@@ -0,0 +1,89 @@
+package wechatpay
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const sampleTradeBill = "\xef\xbb\xbf交易时间,公众账号ID,商户号,特约商户号,设备号,微信订单号,商户订单号,用户标识,交易类型,交易状态,付款银行,货币种类,应结订单金额,代金券金额,微信退款单号,商户退款单号,退款金额,充值券退款金额,退款类型,退款状态,商品名称,商户数据包,手续费,费率,订单金额,申请退款金额,费率备注\n" +
+	"`2026-10-17 10:00:00,`wx-app,`mch-1,`0,`,`4200000001,`DMH1,`openid-1,`NATIVE,`SUCCESS,`OTHERS,`CNY,`99.00,`0.00,`0,`0,`0.00,`0.00,`,`,`活动报名,`,`0.59000,`0.60%,`99.00,`0.00,`\n" +
+	"`2026-10-17 12:00:00,`wx-app,`mch-1,`0,`,`4200000001,`DMH1,`openid-1,`NATIVE,`REFUND,`OTHERS,`CNY,`0.00,`0.00,`5000001,`RF1,`99.00,`0.00,`ORIGINAL,`SUCCESS,`活动报名,`,`-0.59000,`0.60%,`0.00,`99.00,`\n" +
+	"总交易单数,应结订单总金额,退款总金额,充值券退款总金额,手续费总金额,订单总金额,申请退款总金额\n" +
+	"`2,`99.00,`99.00,`0.00,`0.00000,`99.00,`99.00\n"
+
+func TestParseTradeBill(t *testing.T) {
+	bill, err := ParseTradeBill([]byte(sampleTradeBill))
+	if err != nil {
+		t.Fatalf("parse bill: %v", err)
+	}
+	if len(bill.Records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(bill.Records))
+	}
+
+	paid := bill.Records[0]
+	if paid.TransactionId != "4200000001" || paid.OutTradeNo != "DMH1" || paid.TradeState != BillTradeStateSuccess || paid.TotalFee != 9900 {
+		t.Fatalf("unexpected paid record: %+v", paid)
+	}
+	if paid.TradeTime.Format("2006-01-02 15:04:05") != "2026-10-17 10:00:00" {
+		t.Fatalf("unexpected trade time: %v", paid.TradeTime)
+	}
+
+	refund := bill.Records[1]
+	if refund.TradeState != BillTradeStateRefund || refund.RefundFee != 9900 || refund.OutRefundNo != "RF1" {
+		t.Fatalf("unexpected refund record: %+v", refund)
+	}
+}
+
+func TestParseTradeBillRejectsUnknownFormat(t *testing.T) {
+	if _, err := ParseTradeBill([]byte("a,b,c\n1,2,3\n")); err == nil {
+		t.Fatalf("expected missing column error")
+	}
+
+	bill, err := ParseTradeBill(nil)
+	if err != nil || len(bill.Records) != 0 {
+		t.Fatalf("empty bill should parse: %+v, %v", bill, err)
+	}
+}
+
+func TestDownloadTradeBillUsesStandInEndpoint(t *testing.T) {
+	var received map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = parseXMLParams(t, body)
+		_, _ = w.Write([]byte(sampleTradeBill))
+	}))
+	defer server.Close()
+
+	s := NewService(&Config{AppID: "wx-app", MchID: "mch-1", APIKey: "key123", UnifiedOrderURL: server.URL})
+	data, err := s.DownloadTradeBill(time.Date(2026, 10, 17, 0, 0, 0, 0, time.Local))
+	if err != nil {
+		t.Fatalf("download bill: %v", err)
+	}
+	if string(data) != sampleTradeBill {
+		t.Fatalf("unexpected bill content")
+	}
+	if received["bill_date"] != "20261017" || received["bill_type"] != "ALL" {
+		t.Fatalf("unexpected request params: %v", received)
+	}
+	sign := received["sign"]
+	delete(received, "sign")
+	if !s.VerifyMD5Sign(received, sign) {
+		t.Fatalf("request sign invalid")
+	}
+}
+
+func TestDownloadTradeBillNoBill(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("<xml><return_code>FAIL</return_code><return_msg>No Bill Exist</return_msg><error_code>20002</error_code></xml>"))
+	}))
+	defer server.Close()
+
+	s := NewService(&Config{AppID: "wx-app", MchID: "mch-1", APIKey: "key123", UnifiedOrderURL: server.URL})
+	if _, err := s.DownloadTradeBill(time.Now()); !errors.Is(err, ErrBillNotExist) {
+		t.Fatalf("expected ErrBillNotExist, got %v", err)
+	}
+}
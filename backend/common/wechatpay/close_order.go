@@ -0,0 +1,73 @@
+package wechatpay
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ErrOrderPaid 关闭订单时微信返回订单已支付，应等待支付通知而不是取消订单
+var ErrOrderPaid = errors.New("微信支付订单已支付")
+
+type closeOrderResponse struct {
+	ReturnCode string `xml:"return_code"`
+	ReturnMsg  string `xml:"return_msg"`
+	ResultCode string `xml:"result_code"`
+	ErrCode    string `xml:"err_code"`
+	ErrCodeDes string `xml:"err_code_des"`
+}
+
+// CloseOrder 关闭未支付的微信支付订单，订单已关闭时视为成功
+func (s *Service) CloseOrder(outTradeNo string) error {
+	if strings.TrimSpace(outTradeNo) == "" {
+		return errors.New("订单号不能为空")
+	}
+
+	if s.config.MockEnabled {
+		return nil
+	}
+
+	if s.IsV3() {
+		if err := s.checkV3Config(); err != nil {
+			return err
+		}
+		path := fmt.Sprintf("/v3/pay/transactions/out-trade-no/%s/close", url.PathEscape(outTradeNo))
+		_, _, err := s.sendV3Request(http.MethodPost, path, map[string]string{"mchid": s.config.MchID})
+		return err
+	}
+
+	if strings.TrimSpace(s.config.AppID) == "" || strings.TrimSpace(s.config.MchID) == "" || strings.TrimSpace(s.config.APIKey) == "" {
+		return errors.New("微信支付配置不完整")
+	}
+
+	params := map[string]string{
+		"appid":        s.config.AppID,
+		"mch_id":       s.config.MchID,
+		"out_trade_no": outTradeNo,
+		"nonce_str":    generateNonce(),
+	}
+	params["sign"] = s.GenerateMD5Sign(params)
+
+	var resp closeOrderResponse
+	if err := s.postXML(s.closeOrderURL, params, &resp); err != nil {
+		return err
+	}
+
+	if resp.ReturnCode != "SUCCESS" {
+		return fmt.Errorf("微信支付通信失败: %s", strings.TrimSpace(resp.ReturnMsg))
+	}
+	if resp.ResultCode == "SUCCESS" {
+		return nil
+	}
+
+	switch resp.ErrCode {
+	case "ORDERCLOSED":
+		return nil
+	case "ORDERPAID":
+		return ErrOrderPaid
+	default:
+		return fmt.Errorf("微信支付关单失败: %s %s", strings.TrimSpace(resp.ErrCode), strings.TrimSpace(resp.ErrCodeDes))
+	}
+}
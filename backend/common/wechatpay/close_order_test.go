@@ -0,0 +1,49 @@
+package wechatpay
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCloseOrderMock(t *testing.T) {
+	if err := newTestService().CloseOrder("DMH1"); err != nil {
+		t.Fatalf("close order error: %v", err)
+	}
+	if err := newTestService().CloseOrder(""); err == nil {
+		t.Fatalf("expected empty order no error")
+	}
+}
+
+func TestCloseOrderRealMode(t *testing.T) {
+	var received map[string]string
+	response := "<xml><return_code>SUCCESS</return_code><result_code>SUCCESS</result_code></xml>"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = parseXMLParams(t, body)
+		_, _ = w.Write([]byte(response))
+	}))
+	defer server.Close()
+
+	s := NewService(&Config{AppID: "wx-app", MchID: "mch-1", APIKey: "key123", UnifiedOrderURL: server.URL})
+	if err := s.CloseOrder("DMH1"); err != nil {
+		t.Fatalf("close order error: %v", err)
+	}
+	sign := received["sign"]
+	delete(received, "sign")
+	if received["out_trade_no"] != "DMH1" || !s.VerifyMD5Sign(received, sign) {
+		t.Fatalf("unexpected request params: %v", received)
+	}
+
+	response = "<xml><return_code>SUCCESS</return_code><result_code>FAIL</result_code><err_code>ORDERCLOSED</err_code></xml>"
+	if err := s.CloseOrder("DMH1"); err != nil {
+		t.Fatalf("closed order should be treated as success: %v", err)
+	}
+
+	response = "<xml><return_code>SUCCESS</return_code><result_code>FAIL</result_code><err_code>ORDERPAID</err_code></xml>"
+	if err := s.CloseOrder("DMH1"); !errors.Is(err, ErrOrderPaid) {
+		t.Fatalf("expected ErrOrderPaid, got %v", err)
+	}
+}
@@ -0,0 +1,354 @@
+package wechatpay
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// 退款状态
+const (
+	RefundStatusSuccess    = "SUCCESS"
+	RefundStatusProcessing = "PROCESSING"
+	RefundStatusClosed     = "CLOSED"
+	RefundStatusAbnormal   = "ABNORMAL"
+)
+
+const v3RefundPath = "/v3/refund/domestic/refunds"
+
+// RefundRequest 申请退款请求
+type RefundRequest struct {
+	TransactionID string // 微信支付单号，为空时使用商户订单号
+	OutTradeNo    string
+	OutRefundNo   string // 商户退款单号，重试时必须保持不变
+	TotalFee      int64  // 订单总金额（分）
+	RefundFee     int64  // 退款金额（分）
+	Reason        string
+}
+
+// RefundResult 申请退款结果。Accepted 为 false 表示微信明确拒绝，可换单号重新发起
+type RefundResult struct {
+	Accepted   bool
+	Status     string // v3 同步返回的退款状态，v2 受理后以退款通知为准
+	RefundID   string // 微信退款单号
+	ErrCode    string
+	ErrCodeDes string
+}
+
+type refundResponse struct {
+	ReturnCode  string `xml:"return_code"`
+	ReturnMsg   string `xml:"return_msg"`
+	ResultCode  string `xml:"result_code"`
+	ErrCode     string `xml:"err_code"`
+	ErrCodeDes  string `xml:"err_code_des"`
+	OutRefundNo string `xml:"out_refund_no"`
+	RefundID    string `xml:"refund_id"`
+}
+
+type v3RefundAmount struct {
+	Refund   int64  `json:"refund"`
+	Total    int64  `json:"total"`
+	Currency string `json:"currency,omitempty"`
+}
+
+type v3RefundPayload struct {
+	TransactionID string         `json:"transaction_id,omitempty"`
+	OutTradeNo    string         `json:"out_trade_no,omitempty"`
+	OutRefundNo   string         `json:"out_refund_no"`
+	Reason        string         `json:"reason,omitempty"`
+	NotifyURL     string         `json:"notify_url,omitempty"`
+	Amount        v3RefundAmount `json:"amount"`
+}
+
+type v3RefundResult struct {
+	RefundID      string         `json:"refund_id"`
+	OutRefundNo   string         `json:"out_refund_no"`
+	TransactionID string         `json:"transaction_id"`
+	OutTradeNo    string         `json:"out_trade_no"`
+	Status        string         `json:"status"`
+	RefundStatus  string         `json:"refund_status"`
+	SuccessTime   string         `json:"success_time"`
+	Amount        v3RefundAmount `json:"amount"`
+}
+
+// Refund 申请退款。通信失败或结果未知返回 error，此时需使用同一退款单号重试；
+// 业务拒绝通过 RefundResult.Accepted=false 返回
+func (s *Service) Refund(req *RefundRequest) (*RefundResult, error) {
+	if req == nil || strings.TrimSpace(req.OutRefundNo) == "" {
+		return nil, errors.New("商户退款单号不能为空")
+	}
+	if strings.TrimSpace(req.TransactionID) == "" && strings.TrimSpace(req.OutTradeNo) == "" {
+		return nil, errors.New("退款需要微信支付单号或商户订单号")
+	}
+	if req.RefundFee <= 0 || req.TotalFee <= 0 || req.RefundFee > req.TotalFee {
+		return nil, errors.New("退款金额无效")
+	}
+
+	if s.config.MockEnabled {
+		return &RefundResult{
+			Accepted: true,
+			Status:   RefundStatusSuccess,
+			RefundID: fmt.Sprintf("mock_%s", req.OutRefundNo),
+		}, nil
+	}
+	if err := s.CheckRefundConfig(); err != nil {
+		return nil, err
+	}
+
+	if s.IsV3() {
+		return s.refundV3(req)
+	}
+
+	params := map[string]string{
+		"appid":         s.config.AppID,
+		"mch_id":        s.config.MchID,
+		"nonce_str":     generateNonce(),
+		"out_refund_no": req.OutRefundNo,
+		"total_fee":     fmt.Sprintf("%d", req.TotalFee),
+		"refund_fee":    fmt.Sprintf("%d", req.RefundFee),
+	}
+	if id := strings.TrimSpace(req.TransactionID); id != "" {
+		params["transaction_id"] = id
+	} else {
+		params["out_trade_no"] = req.OutTradeNo
+	}
+	if reason := strings.TrimSpace(req.Reason); reason != "" {
+		params["refund_desc"] = reason
+	}
+	if notifyURL := strings.TrimSpace(s.config.RefundNotifyURL); notifyURL != "" {
+		params["notify_url"] = notifyURL
+	}
+	params["sign"] = s.GenerateMD5Sign(params)
+
+	var resp refundResponse
+	if err := s.postWithCert(s.refundURL, params, &resp); err != nil {
+		return nil, err
+	}
+	if resp.ReturnCode != "SUCCESS" {
+		return nil, fmt.Errorf("微信退款通信失败: %s", strings.TrimSpace(resp.ReturnMsg))
+	}
+
+	errCode := strings.TrimSpace(resp.ErrCode)
+	if resp.ResultCode != "SUCCESS" {
+		// 系统繁忙类错误结果未知，需原单号重试
+		if errCode == "" || errCode == "SYSTEMERROR" || errCode == "BIZERR_NEED_RETRY" {
+			return nil, fmt.Errorf("微信退款结果未知: %s %s", errCode, strings.TrimSpace(resp.ErrCodeDes))
+		}
+		return &RefundResult{ErrCode: errCode, ErrCodeDes: strings.TrimSpace(resp.ErrCodeDes)}, nil
+	}
+
+	return &RefundResult{Accepted: true, Status: RefundStatusProcessing, RefundID: resp.RefundID}, nil
+}
+
+func (s *Service) refundV3(req *RefundRequest) (*RefundResult, error) {
+	payload := v3RefundPayload{
+		TransactionID: strings.TrimSpace(req.TransactionID),
+		OutRefundNo:   req.OutRefundNo,
+		Reason:        strings.TrimSpace(req.Reason),
+		NotifyURL:     strings.TrimSpace(s.config.RefundNotifyURL),
+		Amount:        v3RefundAmount{Refund: req.RefundFee, Total: req.TotalFee, Currency: "CNY"},
+	}
+	if payload.TransactionID == "" {
+		payload.OutTradeNo = req.OutTradeNo
+	}
+
+	body, err := s.doV3Request(http.MethodPost, v3RefundPath, payload)
+	if err != nil {
+		var apiErr *V3APIError
+		// 4xx（限频除外）为明确拒绝，其余情况结果未知
+		if errors.As(err, &apiErr) && apiErr.StatusCode >= 400 && apiErr.StatusCode < 500 && apiErr.StatusCode != http.StatusTooManyRequests {
+			return &RefundResult{ErrCode: apiErr.Code, ErrCodeDes: apiErr.Message}, nil
+		}
+		return nil, err
+	}
+
+	var result v3RefundResult
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("解析微信退款响应失败: %w", err)
+	}
+	if result.Status == RefundStatusClosed || result.Status == RefundStatusAbnormal {
+		return &RefundResult{Status: result.Status, RefundID: result.RefundID, ErrCode: result.Status}, nil
+	}
+
+	return &RefundResult{Accepted: true, Status: result.Status, RefundID: result.RefundID}, nil
+}
+
+// CheckRefundConfig 校验退款配置：v2 需要商户信息、退款地址与API证书，v3 需要完整的v3配置
+func (s *Service) CheckRefundConfig() error {
+	if s.config.MockEnabled {
+		return nil
+	}
+	if s.IsV3() {
+		return s.checkV3Config()
+	}
+	switch {
+	case strings.TrimSpace(s.config.AppID) == "" || strings.TrimSpace(s.config.MchID) == "" || strings.TrimSpace(s.config.APIKey) == "":
+		return errors.New("微信支付配置不完整")
+	case s.refundURL == "":
+		return errors.New("未配置微信退款地址 RefundURL")
+	case strings.TrimSpace(s.config.APIClientCert) == "" || strings.TrimSpace(s.config.APIClientKey) == "":
+		return errors.New("微信退款需要配置API证书")
+	}
+	return nil
+}
+
+// ParseRefundNotifyV3 校验并解析 v3 退款结果通知
+func (s *Service) ParseRefundNotifyV3(header http.Header, body []byte) (*RefundNotifyInfo, error) {
+	notify, plain, err := s.ParseNotifyV3(header, body)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(notify.EventType, "REFUND.") {
+		return nil, fmt.Errorf("非退款通知: %s", notify.EventType)
+	}
+
+	var result struct {
+		v3RefundResult
+		MchID string `json:"mchid"`
+	}
+	if err := json.Unmarshal(plain, &result); err != nil {
+		return nil, fmt.Errorf("解析退款通知内容失败: %w", err)
+	}
+	if strings.TrimSpace(s.config.MchID) != "" && result.MchID != s.config.MchID {
+		return nil, errors.New("退款通知商户号不匹配")
+	}
+	if strings.TrimSpace(result.OutRefundNo) == "" && strings.TrimSpace(result.RefundID) == "" {
+		return nil, errors.New("退款通知缺少退款单号")
+	}
+
+	return &RefundNotifyInfo{
+		TransactionID: result.TransactionID,
+		OutTradeNo:    result.OutTradeNo,
+		RefundID:      result.RefundID,
+		OutRefundNo:   result.OutRefundNo,
+		TotalFee:      result.Amount.Total,
+		RefundFee:     result.Amount.Refund,
+		RefundStatus:  result.RefundStatus,
+		SuccessTime:   result.SuccessTime,
+	}, nil
+}
+
+// RefundNotifyRequest 退款通知请求
+type RefundNotifyRequest struct {
+	ReturnCode string `xml:"return_code"`
+	ReturnMsg  string `xml:"return_msg"`
+	AppID      string `xml:"appid"`
+	MchID      string `xml:"mch_id"`
+	NonceStr   string `xml:"nonce_str"`
+	ReqInfo    string `xml:"req_info"`
+}
+
+// RefundNotifyInfo 退款通知解密后的内容
+type RefundNotifyInfo struct {
+	TransactionID       string `xml:"transaction_id"`
+	OutTradeNo          string `xml:"out_trade_no"`
+	RefundID            string `xml:"refund_id"`
+	OutRefundNo         string `xml:"out_refund_no"`
+	TotalFee            int64  `xml:"total_fee"`
+	RefundFee           int64  `xml:"refund_fee"`
+	SettlementRefundFee int64  `xml:"settlement_refund_fee"`
+	RefundStatus        string `xml:"refund_status"` // SUCCESS/CHANGE/REFUNDCLOSE
+	SuccessTime         string `xml:"success_time"`
+	RefundRecvAccout    string `xml:"refund_recv_accout"`
+	RefundAccount       string `xml:"refund_account"`
+	RefundRequestSource string `xml:"refund_request_source"`
+}
+
+// ParseRefundNotifyRequest 解析退款通知请求并解密 req_info
+func (s *Service) ParseRefundNotifyRequest(xmlData string) (*RefundNotifyInfo, error) {
+	var notify RefundNotifyRequest
+	if err := xml.Unmarshal([]byte(xmlData), &notify); err != nil {
+		return nil, fmt.Errorf("解析退款通知失败: %w", err)
+	}
+
+	if notify.ReturnCode != "SUCCESS" {
+		return nil, fmt.Errorf("退款通知失败: %s", strings.TrimSpace(notify.ReturnMsg))
+	}
+	if strings.TrimSpace(notify.MchID) != "" && strings.TrimSpace(s.config.MchID) != "" && notify.MchID != s.config.MchID {
+		return nil, errors.New("退款通知商户号不匹配")
+	}
+	if strings.TrimSpace(notify.ReqInfo) == "" {
+		return nil, errors.New("退款通知缺少加密信息")
+	}
+
+	plain, err := s.decryptRefundReqInfo(notify.ReqInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	var info RefundNotifyInfo
+	if err := xml.Unmarshal(plain, &info); err != nil {
+		return nil, fmt.Errorf("解析退款通知内容失败: %w", err)
+	}
+	if strings.TrimSpace(info.OutRefundNo) == "" && strings.TrimSpace(info.RefundID) == "" {
+		return nil, errors.New("退款通知缺少退款单号")
+	}
+
+	return &info, nil
+}
+
+// EncryptRefundReqInfo 按微信退款通知规则加密 req_info（AES-256-ECB，密钥为 API 密钥的 MD5 小写值）
+func (s *Service) EncryptRefundReqInfo(plain []byte) (string, error) {
+	block, err := aes.NewCipher(s.refundNotifyKey())
+	if err != nil {
+		return "", fmt.Errorf("初始化退款通知解密失败: %w", err)
+	}
+
+	blockSize := block.BlockSize()
+	padding := blockSize - len(plain)%blockSize
+	data := append(append([]byte{}, plain...), bytes.Repeat([]byte{byte(padding)}, padding)...)
+
+	encrypted := make([]byte, len(data))
+	for start := 0; start < len(data); start += blockSize {
+		block.Encrypt(encrypted[start:start+blockSize], data[start:start+blockSize])
+	}
+
+	return base64.StdEncoding.EncodeToString(encrypted), nil
+}
+
+func (s *Service) decryptRefundReqInfo(reqInfo string) ([]byte, error) {
+	encrypted, err := base64.StdEncoding.DecodeString(strings.TrimSpace(reqInfo))
+	if err != nil {
+		return nil, fmt.Errorf("退款通知内容解码失败: %w", err)
+	}
+
+	block, err := aes.NewCipher(s.refundNotifyKey())
+	if err != nil {
+		return nil, fmt.Errorf("初始化退款通知解密失败: %w", err)
+	}
+
+	blockSize := block.BlockSize()
+	if len(encrypted) == 0 || len(encrypted)%blockSize != 0 {
+		return nil, errors.New("退款通知内容长度无效")
+	}
+
+	plain := make([]byte, len(encrypted))
+	for start := 0; start < len(encrypted); start += blockSize {
+		block.Decrypt(plain[start:start+blockSize], encrypted[start:start+blockSize])
+	}
+
+	padding := int(plain[len(plain)-1])
+	if padding == 0 || padding > blockSize || padding > len(plain) {
+		return nil, errors.New("退款通知解密失败")
+	}
+	for _, b := range plain[len(plain)-padding:] {
+		if int(b) != padding {
+			return nil, errors.New("退款通知解密失败")
+		}
+	}
+
+	return plain[:len(plain)-padding], nil
+}
+
+func (s *Service) refundNotifyKey() []byte {
+	hash := md5.Sum([]byte(s.config.APIKey))
+	return []byte(hex.EncodeToString(hash[:]))
+}
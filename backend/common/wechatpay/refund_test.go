@@ -0,0 +1,197 @@
+package wechatpay
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func buildRefundNotifyXML(t *testing.T, s *Service, inner string) string {
+	t.Helper()
+	reqInfo, err := s.EncryptRefundReqInfo([]byte(inner))
+	if err != nil {
+		t.Fatalf("encrypt req_info error: %v", err)
+	}
+	return "<xml>" +
+		"<return_code>SUCCESS</return_code>" +
+		"<appid>wx-app</appid>" +
+		"<mch_id>mch-1</mch_id>" +
+		"<nonce_str>n</nonce_str>" +
+		"<req_info>" + reqInfo + "</req_info>" +
+		"</xml>"
+}
+
+func TestParseRefundNotifyRequest(t *testing.T) {
+	s := newTestService()
+	inner := "<root>" +
+		"<transaction_id>tx-1</transaction_id>" +
+		"<out_trade_no>o-1</out_trade_no>" +
+		"<refund_id>rf-1</refund_id>" +
+		"<out_refund_no>R-1</out_refund_no>" +
+		"<total_fee>100</total_fee>" +
+		"<refund_fee>100</refund_fee>" +
+		"<refund_status>SUCCESS</refund_status>" +
+		"</root>"
+
+	info, err := s.ParseRefundNotifyRequest(buildRefundNotifyXML(t, s, inner))
+	if err != nil {
+		t.Fatalf("parse refund notify error: %v", err)
+	}
+	if info.OutRefundNo != "R-1" || info.TransactionID != "tx-1" || info.RefundFee != 100 || info.RefundStatus != "SUCCESS" {
+		t.Fatalf("unexpected refund info: %+v", info)
+	}
+}
+
+func TestParseRefundNotifyRequestWrongKey(t *testing.T) {
+	s := newTestService()
+	other := NewService(&Config{MchID: "mch-1", APIKey: "another-key", MockEnabled: true})
+	xml := buildRefundNotifyXML(t, other, "<root><out_refund_no>R-1</out_refund_no></root>")
+
+	if _, err := s.ParseRefundNotifyRequest(xml); err == nil {
+		t.Fatalf("expected decrypt error")
+	}
+}
+
+func TestParseRefundNotifyRequestInvalid(t *testing.T) {
+	s := newTestService()
+
+	if _, err := s.ParseRefundNotifyRequest("<xml><return_code>FAIL</return_code><return_msg>oops</return_msg></xml>"); err == nil || !strings.Contains(err.Error(), "oops") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := s.ParseRefundNotifyRequest("<xml><return_code>SUCCESS</return_code><mch_id>mch-2</mch_id><req_info>x</req_info></xml>"); err == nil {
+		t.Fatalf("expected mch_id mismatch error")
+	}
+	if _, err := s.ParseRefundNotifyRequest("<xml><return_code>SUCCESS</return_code></xml>"); err == nil {
+		t.Fatalf("expected missing req_info error")
+	}
+}
+
+func TestRefundMock(t *testing.T) {
+	s := newTestService()
+	result, err := s.Refund(&RefundRequest{OutTradeNo: "o-1", OutRefundNo: "RF-1", TotalFee: 100, RefundFee: 60})
+	if err != nil {
+		t.Fatalf("refund error: %v", err)
+	}
+	if !result.Accepted || result.Status != RefundStatusSuccess || result.RefundID == "" {
+		t.Fatalf("unexpected refund result: %+v", result)
+	}
+
+	if _, err := s.Refund(&RefundRequest{OutTradeNo: "o-1", OutRefundNo: "RF-1", TotalFee: 100, RefundFee: 200}); err == nil {
+		t.Fatalf("expected invalid amount error")
+	}
+	if _, err := s.Refund(&RefundRequest{OutTradeNo: "o-1", TotalFee: 100, RefundFee: 100}); err == nil {
+		t.Fatalf("expected missing refund no error")
+	}
+}
+
+func TestRefundRealMode(t *testing.T) {
+	var lastParams map[string]string
+	replies := map[string]string{
+		"RF-OK":     "<xml><return_code>SUCCESS</return_code><result_code>SUCCESS</result_code><refund_id>50001</refund_id></xml>",
+		"RF-REJECT": "<xml><return_code>SUCCESS</return_code><result_code>FAIL</result_code><err_code>NOTENOUGH</err_code><err_code_des>余额不足</err_code_des></xml>",
+		"RF-BUSY":   "<xml><return_code>SUCCESS</return_code><result_code>FAIL</result_code><err_code>SYSTEMERROR</err_code></xml>",
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		lastParams = parseXMLParams(t, body)
+		_, _ = w.Write([]byte(replies[lastParams["out_refund_no"]]))
+	}))
+	defer server.Close()
+
+	s := newTransferTestService(t, server.URL)
+	s.refundURL = server.URL + "/secapi/pay/refund"
+
+	result, err := s.Refund(&RefundRequest{TransactionID: "tx-1", OutTradeNo: "o-1", OutRefundNo: "RF-OK", TotalFee: 100, RefundFee: 60, Reason: "协商退款"})
+	if err != nil {
+		t.Fatalf("refund error: %v", err)
+	}
+	if !result.Accepted || result.Status != RefundStatusProcessing || result.RefundID != "50001" {
+		t.Fatalf("unexpected refund result: %+v", result)
+	}
+	if lastParams["transaction_id"] != "tx-1" || lastParams["refund_fee"] != "60" || lastParams["total_fee"] != "100" || lastParams["sign"] == "" {
+		t.Fatalf("unexpected refund params: %+v", lastParams)
+	}
+
+	result, err = s.Refund(&RefundRequest{OutTradeNo: "o-1", OutRefundNo: "RF-REJECT", TotalFee: 100, RefundFee: 60})
+	if err != nil || result.Accepted || result.ErrCode != "NOTENOUGH" {
+		t.Fatalf("expected business rejection, got %+v %v", result, err)
+	}
+
+	if _, err := s.Refund(&RefundRequest{OutTradeNo: "o-1", OutRefundNo: "RF-BUSY", TotalFee: 100, RefundFee: 60}); err == nil {
+		t.Fatalf("expected unknown outcome error")
+	}
+}
+
+func TestRefundConfigErrors(t *testing.T) {
+	s := NewService(&Config{AppID: "wx-app", MchID: "mch-1", APIKey: "key123", UnifiedOrderURL: "http://127.0.0.1/pay/unifiedorder"})
+	if err := s.CheckRefundConfig(); err == nil || !strings.Contains(err.Error(), "RefundURL") {
+		t.Fatalf("expected missing refund url error, got %v", err)
+	}
+
+	s = NewService(&Config{AppID: "wx-app", MchID: "mch-1", APIKey: "key123"})
+	if err := s.CheckRefundConfig(); err == nil || !strings.Contains(err.Error(), "证书") {
+		t.Fatalf("expected missing cert error, got %v", err)
+	}
+}
+
+func TestRefundV3(t *testing.T) {
+	g := newV3TestGateway(t)
+	s := g.service()
+
+	result, err := s.Refund(&RefundRequest{TransactionID: "4200001", OutRefundNo: "RF-V3", TotalFee: 990, RefundFee: 300})
+	if err != nil {
+		t.Fatalf("refund v3 error: %v", err)
+	}
+	if !result.Accepted || result.Status != RefundStatusProcessing || result.RefundID != "50000001" {
+		t.Fatalf("unexpected refund result: %+v", result)
+	}
+	amount, _ := g.lastBody["amount"].(map[string]interface{})
+	if g.lastBody["transaction_id"] != "4200001" || amount["refund"] != float64(300) || amount["total"] != float64(990) {
+		t.Fatalf("unexpected refund payload: %+v", g.lastBody)
+	}
+
+	result, err = s.Refund(&RefundRequest{TransactionID: "4200001", OutRefundNo: "RF-REJECT", TotalFee: 990, RefundFee: 300})
+	if err != nil || result.Accepted || result.ErrCode != "NOT_ENOUGH" {
+		t.Fatalf("expected business rejection, got %+v %v", result, err)
+	}
+
+	if _, err := s.Refund(&RefundRequest{TransactionID: "4200001", OutRefundNo: "RF-BUSY", TotalFee: 990, RefundFee: 300}); err == nil {
+		t.Fatalf("expected unknown outcome error")
+	}
+}
+
+func TestParseRefundNotifyV3(t *testing.T) {
+	g := newV3TestGateway(t)
+	s := g.service()
+
+	refund, _ := json.Marshal(map[string]interface{}{
+		"mchid":          "mch-1",
+		"out_trade_no":   "order-v3",
+		"transaction_id": "4200001",
+		"out_refund_no":  "RF-V3",
+		"refund_id":      "50000001",
+		"refund_status":  "SUCCESS",
+		"amount":         map[string]interface{}{"total": 990, "refund": 300},
+	})
+	resource, err := s.EncryptV3Resource(refund, "notifynonce1", "refund")
+	if err != nil {
+		t.Fatalf("encrypt resource: %v", err)
+	}
+	body, _ := json.Marshal(map[string]interface{}{
+		"id":            "evt-2",
+		"event_type":    "REFUND.SUCCESS",
+		"resource_type": "encrypt-resource",
+		"resource":      resource,
+	})
+
+	info, err := s.ParseRefundNotifyV3(g.signHeaders(body), body)
+	if err != nil {
+		t.Fatalf("parse refund notify v3 error: %v", err)
+	}
+	if info.OutRefundNo != "RF-V3" || info.TransactionID != "4200001" || info.RefundFee != 300 || info.TotalFee != 990 || info.RefundStatus != RefundStatusSuccess {
+		t.Fatalf("unexpected refund info: %+v", info)
+	}
+}
@@ -3,6 +3,8 @@ package wechatpay
 import (
 	"bytes"
 	"crypto/md5"
+	"crypto/rsa"
+	"crypto/x509"
 	"encoding/hex"
 	"encoding/xml"
 	"errors"
@@ -11,24 +13,31 @@ import (
 	"net/http"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 )
 
 // Config 微信支付配置
 type Config struct {
-	AppID           string
-	MchID           string
-	APIKey          string
-	APIKeyV3        string
-	APIClientCert   string
-	APIClientKey    string
-	NotifyURL       string
-	RefundNotifyURL string
-	Sandbox         bool
-	CacheTTL        int
-	MockEnabled     bool
-	UnifiedOrderURL string
-	HTTPTimeoutMs   int
+	AppID            string
+	MchID            string
+	APIVersion       string // v2（默认）或 v3
+	APIKey           string
+	APIKeyV3         string
+	MchSerialNo      string // 商户API证书序列号（v3）
+	APIClientCert    string
+	APIClientKey     string
+	V3BaseURL        string // v3 接口地址，为空时使用微信支付正式地址
+	NotifyURL        string
+	RefundNotifyURL  string
+	Sandbox          bool
+	CacheTTL         int
+	MockEnabled      bool
+	UnifiedOrderURL  string
+	RefundURL        string // 申请退款地址，为空时使用微信支付正式（或沙箱）地址；配置了 UnifiedOrderURL 时必须单独配置
+	TransferURL      string // 企业付款地址，真实模式下必须配置
+	TransferQueryURL string // 企业付款查询地址，为空时由 TransferURL 推导
+	HTTPTimeoutMs    int
 }
 
 // NativePayRequest Native支付请求
@@ -61,6 +70,9 @@ type NativePayResponse struct {
 // NotifyRequest 支付通知请求
 type NotifyRequest struct {
 	ReturnCode    string `xml:"return_code"`
+	ResultCode    string `xml:"result_code"`
+	ErrCode       string `xml:"err_code"`
+	ErrCodeDes    string `xml:"err_code_des"`
 	AppID         string `xml:"appid"`
 	MchID         string `xml:"mch_id"`
 	DeviceInfo    string `xml:"device_info"`
@@ -69,7 +81,10 @@ type NotifyRequest struct {
 	OpenID        string `xml:"openid"`
 	OutTradeNo    string `xml:"out_trade_no"`
 	TransactionID string `xml:"transaction_id"`
+	TradeType     string `xml:"trade_type"`
+	BankType      string `xml:"bank_type"`
 	TotalFee      int64  `xml:"total_fee"`
+	CashFee       int64  `xml:"cash_fee"`
 	FeeType       string `xml:"fee_type"`
 	TimeEnd       string `xml:"time_end"`
 	Attach        string `xml:"attach"`
@@ -78,9 +93,26 @@ type NotifyRequest struct {
 
 // Service 微信支付服务
 type Service struct {
-	config          *Config
-	httpClient      *http.Client
-	unifiedOrderURL string
+	config           *Config
+	httpClient       *http.Client
+	unifiedOrderURL  string
+	closeOrderURL    string // 未单独配置时与 UnifiedOrderURL 指向同一模拟网关
+	downloadBillURL  string
+	refundURL        string
+	transferURL      string
+	transferQueryURL string
+	v3BaseURL        string
+
+	keyMu         sync.Mutex
+	mchPrivateKey *rsa.PrivateKey
+
+	certMu             sync.RWMutex
+	platformCerts      map[string]*x509.Certificate
+	certsFetchedAt     time.Time
+	missingCertSerials map[string]time.Time // 下载后仍未找到的序列号及其拒绝截止时间
+
+	certRefreshMu    sync.Mutex // 串行下载平台证书
+	certsAttemptedAt time.Time
 }
 
 // NewService 创建微信支付服务
@@ -95,18 +127,49 @@ func NewService(config *Config) *Service {
 	}
 
 	endpoint := strings.TrimSpace(config.UnifiedOrderURL)
+	closeEndpoint := endpoint
+	billEndpoint := endpoint
+	// 退款会实际退钱，不沿用模拟网关地址
+	refundEndpoint := strings.TrimSpace(config.RefundURL)
 	if endpoint == "" {
 		if config.Sandbox {
 			endpoint = "https://api.mch.weixin.qq.com/sandboxnew/pay/unifiedorder"
+			closeEndpoint = "https://api.mch.weixin.qq.com/sandboxnew/pay/closeorder"
+			billEndpoint = "https://api.mch.weixin.qq.com/sandboxnew/pay/downloadbill"
+			if refundEndpoint == "" {
+				refundEndpoint = "https://api.mch.weixin.qq.com/sandboxnew/pay/refund"
+			}
 		} else {
 			endpoint = "https://api.mch.weixin.qq.com/pay/unifiedorder"
+			closeEndpoint = "https://api.mch.weixin.qq.com/pay/closeorder"
+			billEndpoint = "https://api.mch.weixin.qq.com/pay/downloadbill"
+			if refundEndpoint == "" {
+				refundEndpoint = "https://api.mch.weixin.qq.com/secapi/pay/refund"
+			}
 		}
 	}
 
+	transferEndpoint := strings.TrimSpace(config.TransferURL)
+	transferQueryEndpoint := strings.TrimSpace(config.TransferQueryURL)
+	if transferQueryEndpoint == "" && strings.HasSuffix(transferEndpoint, transferPath) {
+		transferQueryEndpoint = strings.TrimSuffix(transferEndpoint, transferPath) + transferQueryPath
+	}
+
+	v3BaseURL := strings.TrimRight(strings.TrimSpace(config.V3BaseURL), "/")
+	if v3BaseURL == "" {
+		v3BaseURL = defaultV3BaseURL
+	}
+
 	return &Service{
-		config:          config,
-		httpClient:      &http.Client{Timeout: timeout},
-		unifiedOrderURL: endpoint,
+		config:           config,
+		httpClient:       &http.Client{Timeout: timeout},
+		unifiedOrderURL:  endpoint,
+		closeOrderURL:    closeEndpoint,
+		downloadBillURL:  billEndpoint,
+		refundURL:        refundEndpoint,
+		transferURL:      transferEndpoint,
+		transferQueryURL: transferQueryEndpoint,
+		v3BaseURL:        v3BaseURL,
 	}
 }
 
@@ -155,6 +218,14 @@ func (s *Service) VerifyMD5Sign(params map[string]string, sign string) bool {
 	return computedSign == sign
 }
 
+// PrepayTTL 预支付订单（二维码）有效期，取 CacheTTL，未配置时为2小时
+func (s *Service) PrepayTTL() time.Duration {
+	if s.config.CacheTTL > 0 {
+		return time.Duration(s.config.CacheTTL) * time.Second
+	}
+	return 2 * time.Hour
+}
+
 // CreateNativePay 创建Native支付订单
 func (s *Service) CreateNativePay(orderNo string, amount int64, body string) (*NativePayResponse, error) {
 	if strings.TrimSpace(orderNo) == "" {
@@ -167,6 +238,10 @@ func (s *Service) CreateNativePay(orderNo string, amount int64, body string) (*N
 		return nil, errors.New("支付描述不能为空")
 	}
 
+	if s.IsV3() && !s.config.MockEnabled {
+		return s.createNativePayV3(orderNo, amount, body)
+	}
+
 	if !s.config.MockEnabled {
 		if strings.TrimSpace(s.config.AppID) == "" || strings.TrimSpace(s.config.MchID) == "" || strings.TrimSpace(s.config.APIKey) == "" {
 			return nil, errors.New("微信支付配置不完整")
@@ -187,7 +262,7 @@ func (s *Service) CreateNativePay(orderNo string, amount int64, body string) (*N
 		"spbill_create_ip": "127.0.0.1",
 		"trade_type":       "NATIVE",
 		"attach":           attach,
-		"time_expire":      time.Now().Add(2 * time.Hour).Format("20060102150405"),
+		"time_expire":      time.Now().Add(s.PrepayTTL()).Format("20060102150405"),
 	}
 	if strings.TrimSpace(s.config.NotifyURL) != "" {
 		params["notify_url"] = strings.TrimSpace(s.config.NotifyURL)
@@ -252,6 +327,27 @@ func (s *Service) CreateNativePay(orderNo string, amount int64, body string) (*N
 	}, nil
 }
 
+// createNativePayV3 v3 模式下的 Native 下单，结果转换为与 v2 一致的响应
+func (s *Service) createNativePayV3(orderNo string, amount int64, body string) (*NativePayResponse, error) {
+	resp, err := s.CreateOrderV3(TradeTypeNative, &V3OrderRequest{
+		OutTradeNo:  orderNo,
+		Description: body,
+		Amount:      amount,
+		TimeExpire:  time.Now().Add(s.PrepayTTL()),
+		Attach:      fmt.Sprintf("order=%s&time=%d", orderNo, time.Now().Unix()),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &NativePayResponse{
+		ReturnCode: "SUCCESS",
+		AppID:      s.config.AppID,
+		MchID:      s.config.MchID,
+		CodeURL:    resp.CodeURL,
+	}, nil
+}
+
 type xmlPayload struct {
 	XMLName xml.Name      `xml:"xml"`
 	Items   []payloadItem `xml:",any"`
@@ -267,12 +363,35 @@ func (s *Service) unifiedOrder(params map[string]string) (*unifiedOrderResponse,
 		return nil, errors.New("微信支付统一下单地址未配置")
 	}
 
+	var parsed unifiedOrderResponse
+	if err := s.postXML(s.unifiedOrderURL, params, &parsed); err != nil {
+		return nil, err
+	}
+	return &parsed, nil
+}
+
+// postXML 以 XML 报文调用 v2 接口并解析应答
+func (s *Service) postXML(endpoint string, params map[string]string, out interface{}) error {
+	bodyBytes, err := s.postXMLRaw(endpoint, params)
+	if err != nil {
+		return err
+	}
+
+	if err := xml.Unmarshal(bodyBytes, out); err != nil {
+		return fmt.Errorf("解析微信支付响应失败: %w", err)
+	}
+
+	return nil
+}
+
+// postXMLRaw 以 XML 报文调用 v2 接口并返回原始应答，对账单等非 XML 应答使用
+func (s *Service) postXMLRaw(endpoint string, params map[string]string) ([]byte, error) {
 	xmlBody, err := buildXMLPayload(params)
 	if err != nil {
 		return nil, fmt.Errorf("构造微信支付请求失败: %w", err)
 	}
 
-	req, err := http.NewRequest(http.MethodPost, s.unifiedOrderURL, bytes.NewReader(xmlBody))
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(xmlBody))
 	if err != nil {
 		return nil, fmt.Errorf("创建微信支付请求失败: %w", err)
 	}
@@ -293,12 +412,7 @@ func (s *Service) unifiedOrder(params map[string]string) (*unifiedOrderResponse,
 		return nil, fmt.Errorf("微信支付HTTP状态异常: %d %s", resp.StatusCode, strings.TrimSpace(string(bodyBytes)))
 	}
 
-	var parsed unifiedOrderResponse
-	if err := xml.Unmarshal(bodyBytes, &parsed); err != nil {
-		return nil, fmt.Errorf("解析微信支付响应失败: %w", err)
-	}
-
-	return &parsed, nil
+	return bodyBytes, nil
 }
 
 func buildXMLPayload(params map[string]string) ([]byte, error) {
@@ -326,7 +440,7 @@ func buildXMLPayload(params map[string]string) ([]byte, error) {
 	return xmlData, nil
 }
 
-// ParseNotifyRequest 解析支付通知请求
+// ParseNotifyRequest 解析支付通知请求，签名覆盖通知中的全部字段
 func (s *Service) ParseNotifyRequest(xmlData string) (*NotifyRequest, error) {
 	var notify NotifyRequest
 	if err := xml.Unmarshal([]byte(xmlData), &notify); err != nil {
@@ -337,37 +451,51 @@ func (s *Service) ParseNotifyRequest(xmlData string) (*NotifyRequest, error) {
 		return nil, errors.New("支付失败")
 	}
 
+	var payload xmlPayload
+	if err := xml.Unmarshal([]byte(xmlData), &payload); err != nil {
+		return nil, fmt.Errorf("解析支付通知失败: %w", err)
+	}
+	params := make(map[string]string, len(payload.Items))
+	for _, item := range payload.Items {
+		if item.XMLName.Local == "sign" {
+			continue
+		}
+		params[item.XMLName.Local] = item.Value
+	}
+
 	// 验证签名
-	params := map[string]string{
-		"return_code":    notify.ReturnCode,
-		"appid":          notify.AppID,
-		"mch_id":         notify.MchID,
-		"device_info":    notify.DeviceInfo,
-		"nonce_str":      notify.NonceStr,
-		"out_trade_no":   notify.OutTradeNo,
-		"transaction_id": notify.TransactionID,
-		"total_fee":      fmt.Sprintf("%d", notify.TotalFee),
-		"fee_type":       notify.FeeType,
-		"time_end":       notify.TimeEnd,
-		"attach":         notify.Attach,
-		"is_subscribe":   notify.IsSubscribe,
-	}
-
-	if !s.VerifyMD5Sign(params, notify.Sign) {
+	if strings.TrimSpace(notify.Sign) == "" || !s.VerifyMD5Sign(params, notify.Sign) {
 		return nil, errors.New("签名验证失败")
 	}
 
+	if notify.ResultCode != "" && notify.ResultCode != "SUCCESS" {
+		return nil, fmt.Errorf("支付未成功: %s %s", notify.ErrCode, notify.ErrCodeDes)
+	}
+	if strings.TrimSpace(notify.MchID) != "" && strings.TrimSpace(s.config.MchID) != "" && notify.MchID != s.config.MchID {
+		return nil, errors.New("支付通知商户号不匹配")
+	}
+
 	return &notify, nil
 }
 
 // BuildNotifyResponse 构建支付通知响应
 func BuildNotifyResponse() string {
-	params := map[string]string{
-		"return_code": "SUCCESS",
-		"return_msg":  "OK",
-	}
+	return buildNotifyResult("SUCCESS", "OK")
+}
+
+// BuildNotifyFailResponse 构建处理失败的通知响应，微信会按策略重新通知
+func BuildNotifyFailResponse(msg string) string {
+	return buildNotifyResult("FAIL", msg)
+}
 
-	xmlData, _ := xml.Marshal(params)
+func buildNotifyResult(code, msg string) string {
+	xmlData, err := buildXMLPayload(map[string]string{
+		"return_code": code,
+		"return_msg":  msg,
+	})
+	if err != nil {
+		return ""
+	}
 	return string(xmlData)
 }
 
@@ -6,6 +6,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 )
 
 func newTestService() *Service {
@@ -162,14 +163,65 @@ func TestParseNotifyRequest(t *testing.T) {
 	}
 }
 
+func TestParseNotifyRequestSignsAllFields(t *testing.T) {
+	s := newTestService()
+	params := map[string]string{
+		"return_code":    "SUCCESS",
+		"result_code":    "SUCCESS",
+		"appid":          "wx-app",
+		"mch_id":         "mch-1",
+		"nonce_str":      "n",
+		"bank_type":      "CMC",
+		"cash_fee":       "100",
+		"out_trade_no":   "o-1",
+		"transaction_id": "tx-1",
+		"total_fee":      "100",
+		"trade_type":     "NATIVE",
+	}
+	sign := s.GenerateMD5Sign(params)
+	build := func(totalFee string) string {
+		return "<xml><return_code>SUCCESS</return_code><result_code>SUCCESS</result_code>" +
+			"<appid>wx-app</appid><mch_id>mch-1</mch_id><nonce_str>n</nonce_str>" +
+			"<bank_type>CMC</bank_type><cash_fee>100</cash_fee><out_trade_no>o-1</out_trade_no>" +
+			"<transaction_id>tx-1</transaction_id><total_fee>" + totalFee + "</total_fee>" +
+			"<trade_type>NATIVE</trade_type><sign>" + sign + "</sign></xml>"
+	}
+
+	notify, err := s.ParseNotifyRequest(build("100"))
+	if err != nil {
+		t.Fatalf("parse notify error: %v", err)
+	}
+	if notify.TotalFee != 100 || notify.TransactionID != "tx-1" {
+		t.Fatalf("unexpected notify: %+v", notify)
+	}
+
+	if _, err := s.ParseNotifyRequest(build("1")); err == nil {
+		t.Fatalf("expected sign error for tampered total_fee")
+	}
+}
+
 func TestBuildNotifyResponseAndNonce(t *testing.T) {
 	x := BuildNotifyResponse()
-	if x != "" {
+	if x != "<xml><return_code>SUCCESS</return_code><return_msg>OK</return_msg></xml>" {
 		t.Fatalf("unexpected notify response: %s", x)
 	}
+	f := BuildNotifyFailResponse("bad sign")
+	if !strings.Contains(f, "<return_code>FAIL</return_code>") || !strings.Contains(f, "bad sign") {
+		t.Fatalf("unexpected fail response: %s", f)
+	}
 	n1 := generateNonce()
 	n2 := generateNonce()
 	if n1 == "" || n2 == "" || n1 == n2 {
 		t.Fatalf("nonce invalid")
 	}
 }
+
+func TestPrepayTTL(t *testing.T) {
+	if ttl := newTestService().PrepayTTL(); ttl != 2*time.Hour {
+		t.Fatalf("unexpected default ttl: %v", ttl)
+	}
+	s := NewService(&Config{CacheTTL: 600})
+	if ttl := s.PrepayTTL(); ttl != 10*time.Minute {
+		t.Fatalf("unexpected ttl: %v", ttl)
+	}
+}
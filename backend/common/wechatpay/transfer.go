@@ -0,0 +1,293 @@
+package wechatpay
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// 企业付款与查询接口路径，查询地址未配置时由付款地址替换路径得到
+const (
+	transferPath      = "/promotion/transfers"
+	transferQueryPath = "/gettransferinfo"
+)
+
+// 企业付款查询状态
+const (
+	TransferStatusSuccess    = "SUCCESS"
+	TransferStatusFailed     = "FAILED"
+	TransferStatusProcessing = "PROCESSING"
+)
+
+// TransferRequest 企业付款到零钱请求
+type TransferRequest struct {
+	PartnerTradeNo string // 商户付款单号，重试时必须保持不变
+	OpenID         string
+	Amount         int64  // 付款金额（分）
+	Desc           string // 付款备注
+	ReUserName     string // 收款用户真实姓名，非空时强制校验
+}
+
+// TransferResult 企业付款结果
+type TransferResult struct {
+	Success        bool
+	PartnerTradeNo string
+	PaymentNo      string // 微信付款单号
+	PaymentTime    string
+	ErrCode        string
+	ErrCodeDes     string
+}
+
+// Retryable 付款失败后是否可使用同一商户付款单号重试
+func (r *TransferResult) Retryable() bool {
+	switch r.ErrCode {
+	case "SYSTEMERROR", "FREQ_LIMIT", "SEND_FAILED":
+		return true
+	default:
+		return false
+	}
+}
+
+// TransferQueryResult 企业付款查询结果
+type TransferQueryResult struct {
+	Found          bool // 为 false 时微信侧不存在该付款单，即付款未发起
+	PartnerTradeNo string
+	Status         string // SUCCESS / FAILED / PROCESSING
+	PaymentNo      string // 微信付款单号
+	PaymentTime    string
+	Reason         string // 失败原因
+}
+
+type transferQueryResponse struct {
+	ReturnCode     string `xml:"return_code"`
+	ReturnMsg      string `xml:"return_msg"`
+	ResultCode     string `xml:"result_code"`
+	ErrCode        string `xml:"err_code"`
+	ErrCodeDes     string `xml:"err_code_des"`
+	PartnerTradeNo string `xml:"partner_trade_no"`
+	DetailID       string `xml:"detail_id"`
+	Status         string `xml:"status"`
+	Reason         string `xml:"reason"`
+	PaymentTime    string `xml:"payment_time"`
+	TransferTime   string `xml:"transfer_time"`
+}
+
+type transferResponse struct {
+	ReturnCode     string `xml:"return_code"`
+	ReturnMsg      string `xml:"return_msg"`
+	ResultCode     string `xml:"result_code"`
+	ErrCode        string `xml:"err_code"`
+	ErrCodeDes     string `xml:"err_code_des"`
+	MchAppID       string `xml:"mch_appid"`
+	MchID          string `xml:"mchid"`
+	PartnerTradeNo string `xml:"partner_trade_no"`
+	PaymentNo      string `xml:"payment_no"`
+	PaymentTime    string `xml:"payment_time"`
+}
+
+// Transfer 企业付款到零钱。通信失败返回 error，业务失败通过 TransferResult.ErrCode 返回
+func (s *Service) Transfer(req *TransferRequest) (*TransferResult, error) {
+	if req == nil || strings.TrimSpace(req.PartnerTradeNo) == "" {
+		return nil, errors.New("商户付款单号不能为空")
+	}
+	if strings.TrimSpace(req.OpenID) == "" {
+		return nil, errors.New("收款用户openid不能为空")
+	}
+	if req.Amount <= 0 {
+		return nil, errors.New("付款金额必须大于0")
+	}
+
+	if err := s.CheckTransferConfig(); err != nil {
+		return nil, err
+	}
+
+	desc := strings.TrimSpace(req.Desc)
+	if desc == "" {
+		desc = "提现"
+	}
+
+	params := map[string]string{
+		"mch_appid":        s.config.AppID,
+		"mchid":            s.config.MchID,
+		"nonce_str":        generateNonce(),
+		"partner_trade_no": req.PartnerTradeNo,
+		"openid":           req.OpenID,
+		"check_name":       "NO_CHECK",
+		"amount":           fmt.Sprintf("%d", req.Amount),
+		"desc":             desc,
+		"spbill_create_ip": "127.0.0.1",
+	}
+	if name := strings.TrimSpace(req.ReUserName); name != "" {
+		params["check_name"] = "FORCE_CHECK"
+		params["re_user_name"] = name
+	}
+	params["sign"] = s.GenerateMD5Sign(params)
+
+	if s.config.MockEnabled {
+		return &TransferResult{
+			Success:        true,
+			PartnerTradeNo: req.PartnerTradeNo,
+			PaymentNo:      fmt.Sprintf("mock_%s", req.PartnerTradeNo),
+			PaymentTime:    time.Now().Format("2006-01-02 15:04:05"),
+		}, nil
+	}
+
+	var resp transferResponse
+	if err := s.postWithCert(s.transferURL, params, &resp); err != nil {
+		return nil, err
+	}
+
+	if resp.ReturnCode != "SUCCESS" {
+		return nil, fmt.Errorf("微信企业付款通信失败: %s", strings.TrimSpace(resp.ReturnMsg))
+	}
+
+	result := &TransferResult{
+		Success:        resp.ResultCode == "SUCCESS",
+		PartnerTradeNo: req.PartnerTradeNo,
+		PaymentNo:      resp.PaymentNo,
+		PaymentTime:    resp.PaymentTime,
+		ErrCode:        strings.TrimSpace(resp.ErrCode),
+		ErrCodeDes:     strings.TrimSpace(resp.ErrCodeDes),
+	}
+	if !result.Success && result.ErrCode == "" {
+		result.ErrCode = "SYSTEMERROR"
+	}
+
+	return result, nil
+}
+
+// QueryTransfer 按商户付款单号查询企业付款结果，付款通信失败或结果未知时用于确认是否已到账
+func (s *Service) QueryTransfer(partnerTradeNo string) (*TransferQueryResult, error) {
+	if strings.TrimSpace(partnerTradeNo) == "" {
+		return nil, errors.New("商户付款单号不能为空")
+	}
+	if err := s.CheckTransferConfig(); err != nil {
+		return nil, err
+	}
+
+	if s.config.MockEnabled {
+		return &TransferQueryResult{
+			Found:          true,
+			PartnerTradeNo: partnerTradeNo,
+			Status:         TransferStatusSuccess,
+			PaymentNo:      fmt.Sprintf("mock_%s", partnerTradeNo),
+			PaymentTime:    time.Now().Format("2006-01-02 15:04:05"),
+		}, nil
+	}
+
+	params := map[string]string{
+		"appid":            s.config.AppID,
+		"mch_id":           s.config.MchID,
+		"nonce_str":        generateNonce(),
+		"partner_trade_no": partnerTradeNo,
+	}
+	params["sign"] = s.GenerateMD5Sign(params)
+
+	var resp transferQueryResponse
+	if err := s.postWithCert(s.transferQueryURL, params, &resp); err != nil {
+		return nil, err
+	}
+	if resp.ReturnCode != "SUCCESS" {
+		return nil, fmt.Errorf("微信企业付款查询通信失败: %s", strings.TrimSpace(resp.ReturnMsg))
+	}
+	if resp.ResultCode != "SUCCESS" {
+		if strings.TrimSpace(resp.ErrCode) == "NOT_FOUND" {
+			return &TransferQueryResult{PartnerTradeNo: partnerTradeNo}, nil
+		}
+		return nil, fmt.Errorf("微信企业付款查询失败: %s %s", strings.TrimSpace(resp.ErrCode), strings.TrimSpace(resp.ErrCodeDes))
+	}
+
+	result := &TransferQueryResult{
+		Found:          true,
+		PartnerTradeNo: partnerTradeNo,
+		Status:         strings.TrimSpace(resp.Status),
+		PaymentNo:      resp.DetailID,
+		PaymentTime:    resp.PaymentTime,
+		Reason:         strings.TrimSpace(resp.Reason),
+	}
+	if result.PaymentTime == "" {
+		result.PaymentTime = resp.TransferTime
+	}
+	return result, nil
+}
+
+// CheckTransferConfig 校验企业付款配置：真实模式下需要商户信息、付款与查询地址以及API证书
+func (s *Service) CheckTransferConfig() error {
+	if s.config.MockEnabled {
+		return nil
+	}
+	switch {
+	case strings.TrimSpace(s.config.AppID) == "" || strings.TrimSpace(s.config.MchID) == "" || strings.TrimSpace(s.config.APIKey) == "":
+		return errors.New("微信支付配置不完整")
+	case s.transferURL == "":
+		return errors.New("未配置微信企业付款地址 TransferURL")
+	case s.transferQueryURL == "":
+		return errors.New("未配置微信企业付款查询地址 TransferQueryURL")
+	case strings.TrimSpace(s.config.APIClientCert) == "" || strings.TrimSpace(s.config.APIClientKey) == "":
+		return errors.New("微信企业付款需要配置API证书")
+	}
+	return nil
+}
+
+// postWithCert 使用商户API证书发送XML请求并解析响应，企业付款与退款共用
+func (s *Service) postWithCert(url string, params map[string]string, out interface{}) error {
+	client, err := s.certHTTPClient()
+	if err != nil {
+		return err
+	}
+
+	xmlBody, err := buildXMLPayload(params)
+	if err != nil {
+		return fmt.Errorf("构造微信支付请求失败: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(xmlBody))
+	if err != nil {
+		return fmt.Errorf("创建微信支付请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("调用微信支付接口失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("读取微信支付响应失败: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("微信支付HTTP状态异常: %d %s", resp.StatusCode, strings.TrimSpace(string(bodyBytes)))
+	}
+
+	if err := xml.Unmarshal(bodyBytes, out); err != nil {
+		return fmt.Errorf("解析微信支付响应失败: %w", err)
+	}
+	return nil
+}
+
+// certHTTPClient 企业付款、付款查询与退款需要双向证书
+func (s *Service) certHTTPClient() (*http.Client, error) {
+	cert, err := tls.LoadX509KeyPair(strings.TrimSpace(s.config.APIClientCert), strings.TrimSpace(s.config.APIClientKey))
+	if err != nil {
+		return nil, fmt.Errorf("加载微信支付API证书失败: %w", err)
+	}
+
+	return &http.Client{
+		Timeout: s.httpClient.Timeout,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				Certificates: []tls.Certificate{cert},
+				MinVersion:   tls.VersionTLS12,
+			},
+		},
+	}, nil
+}
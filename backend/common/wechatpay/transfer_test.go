@@ -0,0 +1,199 @@
+package wechatpay
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"encoding/xml"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTransferTestService 付款地址指向本地模拟网关并配置自签名API证书
+func newTransferTestService(t *testing.T, gatewayURL string) *Service {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "mch-1"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create cert: %v", err)
+	}
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "apiclient_cert.pem"), filepath.Join(dir, "apiclient_key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}), 0600); err != nil {
+		t.Fatalf("write cert: %v", err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}), 0600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+
+	return NewService(&Config{
+		AppID:         "wx-app",
+		MchID:         "mch-1",
+		APIKey:        "key123",
+		APIClientCert: certFile,
+		APIClientKey:  keyFile,
+		TransferURL:   gatewayURL + "/mmpaymkttransfers" + transferPath,
+	})
+}
+
+func TestTransferMock(t *testing.T) {
+	s := newTestService()
+	result, err := s.Transfer(&TransferRequest{PartnerTradeNo: "WD00000001", OpenID: "openid-1", Amount: 1000})
+	if err != nil {
+		t.Fatalf("transfer error: %v", err)
+	}
+	if !result.Success || result.PaymentNo == "" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+}
+
+func TestTransferInvalidInput(t *testing.T) {
+	s := newTestService()
+
+	if _, err := s.Transfer(&TransferRequest{OpenID: "openid-1", Amount: 1000}); err == nil {
+		t.Fatalf("expected partner trade no error")
+	}
+	if _, err := s.Transfer(&TransferRequest{PartnerTradeNo: "WD00000001", Amount: 1000}); err == nil {
+		t.Fatalf("expected openid error")
+	}
+	if _, err := s.Transfer(&TransferRequest{PartnerTradeNo: "WD00000001", OpenID: "openid-1"}); err == nil {
+		t.Fatalf("expected amount error")
+	}
+}
+
+func TestTransferRealModeUsesStandInEndpoint(t *testing.T) {
+	var received map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = parseXMLParams(t, body)
+		_, _ = w.Write([]byte("<xml><return_code>SUCCESS</return_code><result_code>SUCCESS</result_code>" +
+			"<partner_trade_no>WD00000001</partner_trade_no><payment_no>pay-1</payment_no>" +
+			"<payment_time>2026-10-18 10:00:00</payment_time></xml>"))
+	}))
+	defer server.Close()
+
+	s := newTransferTestService(t, server.URL)
+	result, err := s.Transfer(&TransferRequest{PartnerTradeNo: "WD00000001", OpenID: "openid-1", Amount: 1000, ReUserName: "张三"})
+	if err != nil {
+		t.Fatalf("transfer error: %v", err)
+	}
+	if !result.Success || result.PaymentNo != "pay-1" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+
+	if received["check_name"] != "FORCE_CHECK" || received["re_user_name"] != "张三" || received["amount"] != "1000" {
+		t.Fatalf("unexpected request params: %v", received)
+	}
+	sign := received["sign"]
+	delete(received, "sign")
+	if !s.VerifyMD5Sign(received, sign) {
+		t.Fatalf("request sign invalid")
+	}
+}
+
+func TestTransferRealModeBusinessFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("<xml><return_code>SUCCESS</return_code><result_code>FAIL</result_code>" +
+			"<err_code>NAME_MISMATCH</err_code><err_code_des>姓名校验出错</err_code_des></xml>"))
+	}))
+	defer server.Close()
+
+	s := newTransferTestService(t, server.URL)
+	result, err := s.Transfer(&TransferRequest{PartnerTradeNo: "WD00000002", OpenID: "openid-1", Amount: 1000})
+	if err != nil {
+		t.Fatalf("transfer error: %v", err)
+	}
+	if result.Success || result.ErrCode != "NAME_MISMATCH" || result.Retryable() {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+
+	if !(&TransferResult{ErrCode: "SYSTEMERROR"}).Retryable() {
+		t.Fatalf("SYSTEMERROR should be retryable")
+	}
+}
+
+func TestTransferConfigErrors(t *testing.T) {
+	// 未配置付款地址时不再借用统一下单地址
+	s := NewService(&Config{AppID: "wx-app", MchID: "mch-1", APIKey: "key123", UnifiedOrderURL: "http://127.0.0.1:1"})
+	_, err := s.Transfer(&TransferRequest{PartnerTradeNo: "WD00000003", OpenID: "openid-1", Amount: 1000})
+	if err == nil || !strings.Contains(err.Error(), "TransferURL") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	s = NewService(&Config{AppID: "wx-app", MchID: "mch-1", APIKey: "key123", TransferURL: "http://127.0.0.1:1" + transferPath})
+	if err := s.CheckTransferConfig(); err == nil || !strings.Contains(err.Error(), "API证书") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := s.QueryTransfer("WD00000003"); err == nil {
+		t.Fatalf("expected config error for query")
+	}
+
+	s = NewService(&Config{AppID: "wx-app", MchID: "mch-1", APIKey: "key123", TransferURL: "http://127.0.0.1:1/pay",
+		APIClientCert: "cert.pem", APIClientKey: "key.pem"})
+	if err := s.CheckTransferConfig(); err == nil || !strings.Contains(err.Error(), "TransferQueryURL") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestQueryTransfer(t *testing.T) {
+	var path string
+	status := "<status>SUCCESS</status><detail_id>pay-9</detail_id><payment_time>2026-10-18 10:00:00</payment_time>"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		if status == "" {
+			_, _ = w.Write([]byte("<xml><return_code>SUCCESS</return_code><result_code>FAIL</result_code><err_code>NOT_FOUND</err_code></xml>"))
+			return
+		}
+		_, _ = w.Write([]byte("<xml><return_code>SUCCESS</return_code><result_code>SUCCESS</result_code>" + status + "</xml>"))
+	}))
+	defer server.Close()
+
+	s := newTransferTestService(t, server.URL)
+	result, err := s.QueryTransfer("WD00000001")
+	if err != nil {
+		t.Fatalf("query error: %v", err)
+	}
+	if path != "/mmpaymkttransfers"+transferQueryPath {
+		t.Fatalf("unexpected query path: %s", path)
+	}
+	if !result.Found || result.Status != TransferStatusSuccess || result.PaymentNo != "pay-9" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+
+	status = ""
+	result, err = s.QueryTransfer("WD00000001")
+	if err != nil || result.Found {
+		t.Fatalf("expected not found, got %+v, %v", result, err)
+	}
+}
+
+func parseXMLParams(t *testing.T, body []byte) map[string]string {
+	t.Helper()
+	var payload xmlPayload
+	if err := xml.Unmarshal(body, &payload); err != nil {
+		t.Fatalf("parse request xml: %v", err)
+	}
+	params := make(map[string]string, len(payload.Items))
+	for _, item := range payload.Items {
+		params[item.XMLName.Local] = item.Value
+	}
+	return params
+}
@@ -0,0 +1,728 @@
+package wechatpay
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultV3BaseURL            = "https://api.mch.weixin.qq.com"
+	v3AuthSchema                = "WECHATPAY2-SHA256-RSA2048"
+	v3CertificatesPath          = "/v3/certificates"
+	platformCertRefreshInterval = 12 * time.Hour
+	v3SignatureMaxSkew          = 5 * time.Minute
+	// 两次下载平台证书的最短间隔，伪造序列号的请求不会频繁触发下载
+	platformCertMinRefreshInterval = time.Minute
+	// 下载后仍未找到的序列号在该时长内直接拒绝
+	platformCertMissingTTL = 5 * time.Minute
+)
+
+// v3 下单类型
+const (
+	TradeTypeNative = "NATIVE"
+	TradeTypeJSAPI  = "JSAPI"
+	TradeTypeH5     = "H5"
+)
+
+// V3EncryptedResource v3 接口中 AES-256-GCM 加密的数据
+type V3EncryptedResource struct {
+	Algorithm      string `json:"algorithm"`
+	Ciphertext     string `json:"ciphertext"`
+	AssociatedData string `json:"associated_data"`
+	OriginalType   string `json:"original_type"`
+	Nonce          string `json:"nonce"`
+}
+
+// V3Notify v3 回调通知
+type V3Notify struct {
+	ID           string              `json:"id"`
+	CreateTime   string              `json:"create_time"`
+	EventType    string              `json:"event_type"`
+	ResourceType string              `json:"resource_type"`
+	Summary      string              `json:"summary"`
+	Resource     V3EncryptedResource `json:"resource"`
+}
+
+// V3Transaction v3 支付成功通知解密后的交易信息
+type V3Transaction struct {
+	AppID          string `json:"appid"`
+	MchID          string `json:"mchid"`
+	OutTradeNo     string `json:"out_trade_no"`
+	TransactionID  string `json:"transaction_id"`
+	TradeType      string `json:"trade_type"`
+	TradeState     string `json:"trade_state"`
+	TradeStateDesc string `json:"trade_state_desc"`
+	SuccessTime    string `json:"success_time"`
+	Attach         string `json:"attach"`
+	Payer          struct {
+		OpenID string `json:"openid"`
+	} `json:"payer"`
+	Amount struct {
+		Total      int64  `json:"total"`
+		PayerTotal int64  `json:"payer_total"`
+		Currency   string `json:"currency"`
+	} `json:"amount"`
+}
+
+// V3OrderRequest v3 下单参数
+type V3OrderRequest struct {
+	OutTradeNo  string
+	Description string
+	Amount      int64     // 订单金额（分）
+	OpenID      string    // JSAPI 必填
+	ClientIP    string    // H5 必填
+	TimeExpire  time.Time // 为空时默认2小时
+	Attach      string
+}
+
+// JSAPIPayParams 前端调起 JSAPI 支付所需参数
+type JSAPIPayParams struct {
+	AppID     string `json:"appId"`
+	TimeStamp string `json:"timeStamp"`
+	NonceStr  string `json:"nonceStr"`
+	Package   string `json:"package"`
+	SignType  string `json:"signType"`
+	PaySign   string `json:"paySign"`
+}
+
+// V3OrderResponse v3 下单结果
+type V3OrderResponse struct {
+	TradeType   string
+	CodeURL     string          // NATIVE
+	PrepayID    string          // JSAPI
+	H5URL       string          // H5
+	JSAPIParams *JSAPIPayParams // JSAPI
+}
+
+type v3OrderPayload struct {
+	AppID       string         `json:"appid"`
+	MchID       string         `json:"mchid"`
+	Description string         `json:"description"`
+	OutTradeNo  string         `json:"out_trade_no"`
+	TimeExpire  string         `json:"time_expire,omitempty"`
+	Attach      string         `json:"attach,omitempty"`
+	NotifyURL   string         `json:"notify_url"`
+	Amount      v3OrderAmount  `json:"amount"`
+	Payer       *v3OrderPayer  `json:"payer,omitempty"`
+	SceneInfo   *v3OrderScenes `json:"scene_info,omitempty"`
+}
+
+type v3OrderAmount struct {
+	Total    int64  `json:"total"`
+	Currency string `json:"currency"`
+}
+
+type v3OrderPayer struct {
+	OpenID string `json:"openid"`
+}
+
+type v3OrderScenes struct {
+	PayerClientIP string         `json:"payer_client_ip"`
+	H5Info        *v3OrderH5Info `json:"h5_info,omitempty"`
+}
+
+type v3OrderH5Info struct {
+	Type string `json:"type"`
+}
+
+type v3OrderResult struct {
+	CodeURL  string `json:"code_url"`
+	PrepayID string `json:"prepay_id"`
+	H5URL    string `json:"h5_url"`
+}
+
+type v3ErrorResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// V3APIError v3 接口返回的非 2xx 应答，4xx 表示请求被明确拒绝
+type V3APIError struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *V3APIError) Error() string {
+	return fmt.Sprintf("微信支付请求失败: %d %s %s", e.StatusCode, e.Code, e.Message)
+}
+
+type v3CertificatesResponse struct {
+	Data []struct {
+		SerialNo           string              `json:"serial_no"`
+		EffectiveTime      string              `json:"effective_time"`
+		ExpireTime         string              `json:"expire_time"`
+		EncryptCertificate V3EncryptedResource `json:"encrypt_certificate"`
+	} `json:"data"`
+}
+
+// IsV3 是否使用 APIv3 协议
+func (s *Service) IsV3() bool {
+	return strings.EqualFold(strings.TrimSpace(s.config.APIVersion), "v3")
+}
+
+// CreateOrderV3 使用 APIv3 创建 Native/JSAPI/H5 订单
+func (s *Service) CreateOrderV3(tradeType string, req *V3OrderRequest) (*V3OrderResponse, error) {
+	if req == nil || strings.TrimSpace(req.OutTradeNo) == "" {
+		return nil, errors.New("订单号不能为空")
+	}
+	if req.Amount <= 0 {
+		return nil, errors.New("支付金额必须大于0")
+	}
+	if strings.TrimSpace(req.Description) == "" {
+		return nil, errors.New("支付描述不能为空")
+	}
+
+	var path string
+	payload := v3OrderPayload{
+		AppID:       s.config.AppID,
+		MchID:       s.config.MchID,
+		Description: req.Description,
+		OutTradeNo:  req.OutTradeNo,
+		Attach:      req.Attach,
+		NotifyURL:   strings.TrimSpace(s.config.NotifyURL),
+		Amount:      v3OrderAmount{Total: req.Amount, Currency: "CNY"},
+	}
+	expire := req.TimeExpire
+	if expire.IsZero() {
+		expire = time.Now().Add(2 * time.Hour)
+	}
+	payload.TimeExpire = expire.Format(time.RFC3339)
+
+	switch tradeType {
+	case TradeTypeNative:
+		path = "/v3/pay/transactions/native"
+	case TradeTypeJSAPI:
+		if strings.TrimSpace(req.OpenID) == "" {
+			return nil, errors.New("JSAPI支付需要用户openid")
+		}
+		path = "/v3/pay/transactions/jsapi"
+		payload.Payer = &v3OrderPayer{OpenID: req.OpenID}
+	case TradeTypeH5:
+		if strings.TrimSpace(req.ClientIP) == "" {
+			return nil, errors.New("H5支付需要用户IP")
+		}
+		path = "/v3/pay/transactions/h5"
+		payload.SceneInfo = &v3OrderScenes{PayerClientIP: req.ClientIP, H5Info: &v3OrderH5Info{Type: "Wap"}}
+	default:
+		return nil, fmt.Errorf("不支持的支付类型: %s", tradeType)
+	}
+
+	if s.config.MockEnabled {
+		return s.mockOrderV3(tradeType, req.OutTradeNo), nil
+	}
+
+	if err := s.checkV3Config(); err != nil {
+		return nil, err
+	}
+	if payload.NotifyURL == "" {
+		return nil, errors.New("微信支付回调地址未配置")
+	}
+
+	body, err := s.doV3Request(http.MethodPost, path, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	var result v3OrderResult
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("解析微信支付响应失败: %w", err)
+	}
+
+	resp := &V3OrderResponse{
+		TradeType: tradeType,
+		CodeURL:   result.CodeURL,
+		PrepayID:  result.PrepayID,
+		H5URL:     result.H5URL,
+	}
+	if tradeType == TradeTypeJSAPI {
+		if result.PrepayID == "" {
+			return nil, errors.New("微信支付响应缺少prepay_id")
+		}
+		params, err := s.BuildJSAPIPayParams(result.PrepayID)
+		if err != nil {
+			return nil, err
+		}
+		resp.JSAPIParams = params
+	}
+
+	return resp, nil
+}
+
+// BuildJSAPIPayParams 为 prepay_id 生成前端调起支付的签名参数
+func (s *Service) BuildJSAPIPayParams(prepayID string) (*JSAPIPayParams, error) {
+	params := &JSAPIPayParams{
+		AppID:     s.config.AppID,
+		TimeStamp: strconv.FormatInt(time.Now().Unix(), 10),
+		NonceStr:  generateV3Nonce(),
+		Package:   "prepay_id=" + prepayID,
+		SignType:  "RSA",
+	}
+
+	message := params.AppID + "\n" + params.TimeStamp + "\n" + params.NonceStr + "\n" + params.Package + "\n"
+	sign, err := s.signV3Message(message)
+	if err != nil {
+		return nil, err
+	}
+	params.PaySign = sign
+
+	return params, nil
+}
+
+func (s *Service) mockOrderV3(tradeType, outTradeNo string) *V3OrderResponse {
+	nonce := generateV3Nonce()
+	resp := &V3OrderResponse{TradeType: tradeType}
+	switch tradeType {
+	case TradeTypeNative:
+		resp.CodeURL = fmt.Sprintf("weixin://wxpay/bizpayurl?pr=%s", outTradeNo)
+	case TradeTypeJSAPI:
+		resp.PrepayID = fmt.Sprintf("prepay_%s", nonce)
+		resp.JSAPIParams = &JSAPIPayParams{
+			AppID:     s.config.AppID,
+			TimeStamp: strconv.FormatInt(time.Now().Unix(), 10),
+			NonceStr:  nonce,
+			Package:   "prepay_id=" + resp.PrepayID,
+			SignType:  "RSA",
+			PaySign:   "mock",
+		}
+	case TradeTypeH5:
+		resp.H5URL = fmt.Sprintf("https://wx.tenpay.com/cgi-bin/mmpayweb-bin/checkmweb?prepay_id=prepay_%s", nonce)
+	}
+	return resp
+}
+
+func (s *Service) checkV3Config() error {
+	if strings.TrimSpace(s.config.AppID) == "" || strings.TrimSpace(s.config.MchID) == "" ||
+		strings.TrimSpace(s.config.APIKeyV3) == "" || strings.TrimSpace(s.config.MchSerialNo) == "" ||
+		strings.TrimSpace(s.config.APIClientKey) == "" {
+		return errors.New("微信支付v3配置不完整")
+	}
+	return nil
+}
+
+// ParseNotifyV3 校验 v3 回调签名并解密通知资源
+func (s *Service) ParseNotifyV3(header http.Header, body []byte) (*V3Notify, []byte, error) {
+	if err := s.VerifyV3Signature(header, body); err != nil {
+		return nil, nil, err
+	}
+
+	var notify V3Notify
+	if err := json.Unmarshal(body, &notify); err != nil {
+		return nil, nil, fmt.Errorf("解析支付通知失败: %w", err)
+	}
+
+	plain, err := s.DecryptV3Resource(&notify.Resource)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &notify, plain, nil
+}
+
+// ParsePayNotifyV3 解析 v3 支付成功通知
+func (s *Service) ParsePayNotifyV3(header http.Header, body []byte) (*V3Transaction, error) {
+	notify, plain, err := s.ParseNotifyV3(header, body)
+	if err != nil {
+		return nil, err
+	}
+	if notify.EventType != "TRANSACTION.SUCCESS" {
+		return nil, fmt.Errorf("非支付成功通知: %s", notify.EventType)
+	}
+
+	var transaction V3Transaction
+	if err := json.Unmarshal(plain, &transaction); err != nil {
+		return nil, fmt.Errorf("解析支付通知内容失败: %w", err)
+	}
+	if strings.TrimSpace(s.config.MchID) != "" && transaction.MchID != s.config.MchID {
+		return nil, errors.New("支付通知商户号不匹配")
+	}
+
+	return &transaction, nil
+}
+
+// BuildNotifyV3Response 构建 v3 通知应答
+func BuildNotifyV3Response(success bool, message string) []byte {
+	code := "SUCCESS"
+	if !success {
+		code = "FAIL"
+	}
+	data, _ := json.Marshal(v3ErrorResponse{Code: code, Message: message})
+	return data
+}
+
+// DecryptV3Resource 使用 APIv3 密钥对 AES-256-GCM 加密数据解密
+func (s *Service) DecryptV3Resource(resource *V3EncryptedResource) ([]byte, error) {
+	if resource == nil {
+		return nil, errors.New("加密数据为空")
+	}
+	if resource.Algorithm != "" && resource.Algorithm != "AEAD_AES_256_GCM" {
+		return nil, fmt.Errorf("不支持的加密算法: %s", resource.Algorithm)
+	}
+
+	key := []byte(s.config.APIKeyV3)
+	if len(key) != 32 {
+		return nil, errors.New("APIv3密钥长度必须为32字节")
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(resource.Ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("加密数据解码失败: %w", err)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("初始化解密失败: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("初始化解密失败: %w", err)
+	}
+
+	plain, err := gcm.Open(nil, []byte(resource.Nonce), ciphertext, []byte(resource.AssociatedData))
+	if err != nil {
+		return nil, fmt.Errorf("解密失败: %w", err)
+	}
+
+	return plain, nil
+}
+
+// EncryptV3Resource 使用 APIv3 密钥加密数据，格式与微信回调资源一致
+func (s *Service) EncryptV3Resource(plain []byte, nonce, associatedData string) (*V3EncryptedResource, error) {
+	key := []byte(s.config.APIKeyV3)
+	if len(key) != 32 {
+		return nil, errors.New("APIv3密钥长度必须为32字节")
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("初始化加密失败: %w", err)
+	}
+	gcm, err := cipher.NewGCMWithNonceSize(block, len(nonce))
+	if err != nil {
+		return nil, fmt.Errorf("初始化加密失败: %w", err)
+	}
+
+	ciphertext := gcm.Seal(nil, []byte(nonce), plain, []byte(associatedData))
+	return &V3EncryptedResource{
+		Algorithm:      "AEAD_AES_256_GCM",
+		Ciphertext:     base64.StdEncoding.EncodeToString(ciphertext),
+		AssociatedData: associatedData,
+		Nonce:          nonce,
+	}, nil
+}
+
+// VerifyV3Signature 使用微信支付平台证书校验应答或回调签名
+func (s *Service) VerifyV3Signature(header http.Header, body []byte) error {
+	serial := header.Get("Wechatpay-Serial")
+	cert, err := s.platformCertificate(serial)
+	if err != nil {
+		return err
+	}
+	return verifyV3SignatureWithCert(header, body, cert)
+}
+
+func verifyV3SignatureWithCert(header http.Header, body []byte, cert *x509.Certificate) error {
+	signature := header.Get("Wechatpay-Signature")
+	timestamp := header.Get("Wechatpay-Timestamp")
+	nonce := header.Get("Wechatpay-Nonce")
+	if signature == "" || timestamp == "" || nonce == "" {
+		return errors.New("微信支付签名信息缺失")
+	}
+
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.New("微信支付签名时间戳无效")
+	}
+	if skew := time.Since(time.Unix(ts, 0)); skew > v3SignatureMaxSkew || skew < -v3SignatureMaxSkew {
+		return errors.New("微信支付签名已过期")
+	}
+
+	publicKey, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return errors.New("微信支付平台证书公钥类型无效")
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return errors.New("微信支付签名格式无效")
+	}
+
+	hashed := sha256.Sum256([]byte(timestamp + "\n" + nonce + "\n" + string(body) + "\n"))
+	if err := rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, hashed[:], sig); err != nil {
+		return errors.New("签名验证失败")
+	}
+
+	return nil
+}
+
+// platformCertificate 按序列号获取平台证书，缓存过期或序列号未知时重新下载。
+// 下载串行进行且限制频率，下载后仍未知的序列号短期内直接拒绝
+func (s *Service) platformCertificate(serial string) (*x509.Certificate, error) {
+	if strings.TrimSpace(serial) == "" {
+		return nil, errors.New("微信支付平台证书序列号缺失")
+	}
+
+	cert, ok, fresh, missing := s.cachedPlatformCertificate(serial)
+	if ok && fresh {
+		return cert, nil
+	}
+	if missing {
+		return nil, fmt.Errorf("未找到微信支付平台证书: %s", serial)
+	}
+
+	s.certRefreshMu.Lock()
+	defer s.certRefreshMu.Unlock()
+
+	// 等待期间其他请求可能已完成下载
+	cert, ok, fresh, missing = s.cachedPlatformCertificate(serial)
+	if ok && fresh {
+		return cert, nil
+	}
+	if missing {
+		return nil, fmt.Errorf("未找到微信支付平台证书: %s", serial)
+	}
+
+	if time.Since(s.certsAttemptedAt) >= platformCertMinRefreshInterval {
+		s.certsAttemptedAt = time.Now()
+		if err := s.refreshPlatformCertificates(); err != nil {
+			if ok {
+				return cert, nil
+			}
+			return nil, err
+		}
+		cert, ok, _, _ = s.cachedPlatformCertificate(serial)
+	}
+	if ok {
+		return cert, nil
+	}
+
+	s.certMu.Lock()
+	if s.missingCertSerials == nil {
+		s.missingCertSerials = make(map[string]time.Time)
+	}
+	s.missingCertSerials[serial] = time.Now().Add(platformCertMissingTTL)
+	s.certMu.Unlock()
+	return nil, fmt.Errorf("未找到微信支付平台证书: %s", serial)
+}
+
+// cachedPlatformCertificate 查询缓存的平台证书，missing 表示该序列号近期下载后仍未找到
+func (s *Service) cachedPlatformCertificate(serial string) (cert *x509.Certificate, ok, fresh, missing bool) {
+	s.certMu.RLock()
+	defer s.certMu.RUnlock()
+	cert, ok = s.platformCerts[serial]
+	fresh = time.Since(s.certsFetchedAt) < platformCertRefreshInterval
+	missing = !ok && time.Now().Before(s.missingCertSerials[serial])
+	return cert, ok, fresh, missing
+}
+
+// refreshPlatformCertificates 下载并解密平台证书，用下载到的证书校验应答签名后替换缓存
+func (s *Service) refreshPlatformCertificates() error {
+	if err := s.checkV3Config(); err != nil {
+		return err
+	}
+
+	header, body, err := s.sendV3Request(http.MethodGet, v3CertificatesPath, nil)
+	if err != nil {
+		return err
+	}
+
+	var parsed v3CertificatesResponse
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return fmt.Errorf("解析平台证书失败: %w", err)
+	}
+
+	certs := make(map[string]*x509.Certificate, len(parsed.Data))
+	for _, item := range parsed.Data {
+		plain, err := s.DecryptV3Resource(&item.EncryptCertificate)
+		if err != nil {
+			return fmt.Errorf("解密平台证书失败: %w", err)
+		}
+		block, _ := pem.Decode(plain)
+		if block == nil {
+			return errors.New("平台证书格式无效")
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return fmt.Errorf("解析平台证书失败: %w", err)
+		}
+		certs[item.SerialNo] = cert
+	}
+
+	signCert, ok := certs[header.Get("Wechatpay-Serial")]
+	if !ok {
+		return errors.New("平台证书应答签名证书未知")
+	}
+	if err := verifyV3SignatureWithCert(header, body, signCert); err != nil {
+		return err
+	}
+
+	s.certMu.Lock()
+	s.platformCerts = certs
+	s.certsFetchedAt = time.Now()
+	s.missingCertSerials = nil
+	s.certMu.Unlock()
+
+	return nil
+}
+
+// doV3Request 发送签名请求并校验应答签名
+func (s *Service) doV3Request(method, path string, payload interface{}) ([]byte, error) {
+	header, body, err := s.sendV3Request(method, path, payload)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.VerifyV3Signature(header, body); err != nil {
+		return nil, fmt.Errorf("微信支付应答签名校验失败: %w", err)
+	}
+	return body, nil
+}
+
+func (s *Service) sendV3Request(method, path string, payload interface{}) (http.Header, []byte, error) {
+	var body []byte
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, nil, fmt.Errorf("构造微信支付请求失败: %w", err)
+		}
+		body = data
+	}
+
+	authorization, err := s.buildV3Authorization(method, path, body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req, err := http.NewRequest(method, s.v3BaseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, fmt.Errorf("创建微信支付请求失败: %w", err)
+	}
+	req.Header.Set("Authorization", authorization)
+	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("调用微信支付失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("读取微信支付响应失败: %w", err)
+	}
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		var apiErr v3ErrorResponse
+		_ = json.Unmarshal(respBody, &apiErr)
+		return nil, nil, &V3APIError{StatusCode: resp.StatusCode, Code: apiErr.Code, Message: apiErr.Message}
+	}
+
+	return resp.Header, respBody, nil
+}
+
+// buildV3Authorization 生成 WECHATPAY2-SHA256-RSA2048 认证头
+func (s *Service) buildV3Authorization(method, canonicalURL string, body []byte) (string, error) {
+	nonce := generateV3Nonce()
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	message := method + "\n" + canonicalURL + "\n" + timestamp + "\n" + nonce + "\n" + string(body) + "\n"
+
+	signature, err := s.signV3Message(message)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf(`%s mchid="%s",nonce_str="%s",signature="%s",timestamp="%s",serial_no="%s"`,
+		v3AuthSchema, s.config.MchID, nonce, signature, timestamp, s.config.MchSerialNo), nil
+}
+
+// signV3Message 使用商户私钥进行 SHA256-RSA 签名
+func (s *Service) signV3Message(message string) (string, error) {
+	key, err := s.merchantPrivateKey()
+	if err != nil {
+		return "", err
+	}
+
+	hashed := sha256.Sum256([]byte(message))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hashed[:])
+	if err != nil {
+		return "", fmt.Errorf("微信支付请求签名失败: %w", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(signature), nil
+}
+
+func (s *Service) merchantPrivateKey() (*rsa.PrivateKey, error) {
+	s.keyMu.Lock()
+	defer s.keyMu.Unlock()
+
+	if s.mchPrivateKey != nil {
+		return s.mchPrivateKey, nil
+	}
+
+	path := strings.TrimSpace(s.config.APIClientKey)
+	if path == "" {
+		return nil, errors.New("未配置商户API私钥")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取商户API私钥失败: %w", err)
+	}
+
+	key, err := parseRSAPrivateKey(data)
+	if err != nil {
+		return nil, err
+	}
+	s.mchPrivateKey = key
+
+	return key, nil
+}
+
+func parseRSAPrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("商户API私钥格式无效")
+	}
+
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		rsaKey, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("商户API私钥不是RSA密钥")
+		}
+		return rsaKey, nil
+	}
+
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("解析商户API私钥失败: %w", err)
+	}
+	return key, nil
+}
+
+// generateV3Nonce 生成32位随机串（v3 要求 nonce 不超过32字符）
+func generateV3Nonce() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,393 @@
+package wechatpay
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testAPIKeyV3 = "0123456789abcdef0123456789abcdef"
+
+// v3TestGateway 模拟微信支付 v3 网关：校验商户签名，用平台私钥签名应答
+type v3TestGateway struct {
+	t            *testing.T
+	server       *httptest.Server
+	merchantKey  *rsa.PrivateKey
+	platformKey  *rsa.PrivateKey
+	platformCert []byte
+	keyFile      string
+	certRequests int
+	lastBody     map[string]interface{}
+	tamper       bool
+}
+
+func newV3TestGateway(t *testing.T) *v3TestGateway {
+	t.Helper()
+
+	merchantKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate merchant key: %v", err)
+	}
+	platformKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate platform key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "wechatpay-platform"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &platformKey.PublicKey, platformKey)
+	if err != nil {
+		t.Fatalf("create platform cert: %v", err)
+	}
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(merchantKey)
+	if err != nil {
+		t.Fatalf("marshal merchant key: %v", err)
+	}
+	keyFile := filepath.Join(t.TempDir(), "apiclient_key.pem")
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatalf("write merchant key: %v", err)
+	}
+
+	g := &v3TestGateway{
+		t:            t,
+		merchantKey:  merchantKey,
+		platformKey:  platformKey,
+		platformCert: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}),
+		keyFile:      keyFile,
+	}
+	g.server = httptest.NewServer(http.HandlerFunc(g.handle))
+	t.Cleanup(g.server.Close)
+
+	return g
+}
+
+func (g *v3TestGateway) service() *Service {
+	return NewService(&Config{
+		AppID:        "wx-app",
+		MchID:        "mch-1",
+		APIVersion:   "v3",
+		APIKeyV3:     testAPIKeyV3,
+		MchSerialNo:  "MERCHANT1",
+		APIClientKey: g.keyFile,
+		NotifyURL:    "https://example.com/notify",
+		V3BaseURL:    g.server.URL,
+	})
+}
+
+var authPattern = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+func (g *v3TestGateway) handle(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, v3AuthSchema+" ") {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	fields := map[string]string{}
+	for _, m := range authPattern.FindAllStringSubmatch(auth, -1) {
+		fields[m[1]] = m[2]
+	}
+	message := r.Method + "\n" + r.URL.RequestURI() + "\n" + fields["timestamp"] + "\n" + fields["nonce_str"] + "\n" + string(body) + "\n"
+	hashed := sha256.Sum256([]byte(message))
+	sig, _ := base64.StdEncoding.DecodeString(fields["signature"])
+	if err := rsa.VerifyPKCS1v15(&g.merchantKey.PublicKey, crypto.SHA256, hashed[:], sig); err != nil || fields["mchid"] != "mch-1" {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"code":"SIGN_ERROR","message":"签名错误"}`))
+		return
+	}
+
+	var resp interface{}
+	switch r.URL.Path {
+	case v3CertificatesPath:
+		g.certRequests++
+		s := &Service{config: &Config{APIKeyV3: testAPIKeyV3}}
+		encrypted, err := s.EncryptV3Resource(g.platformCert, "certnonce123", "certificate")
+		if err != nil {
+			g.t.Fatalf("encrypt cert: %v", err)
+		}
+		resp = map[string]interface{}{
+			"data": []map[string]interface{}{
+				{"serial_no": "PLATFORM1", "encrypt_certificate": encrypted},
+			},
+		}
+	case "/v3/pay/transactions/native":
+		g.lastBody = map[string]interface{}{}
+		_ = json.Unmarshal(body, &g.lastBody)
+		resp = map[string]string{"code_url": "weixin://wxpay/bizpayurl?pr=v3"}
+	case "/v3/pay/transactions/jsapi":
+		g.lastBody = map[string]interface{}{}
+		_ = json.Unmarshal(body, &g.lastBody)
+		resp = map[string]string{"prepay_id": "wx-prepay-1"}
+	case "/v3/pay/transactions/h5":
+		resp = map[string]string{"h5_url": "https://wx.tenpay.com/h5"}
+	case v3RefundPath:
+		g.lastBody = map[string]interface{}{}
+		_ = json.Unmarshal(body, &g.lastBody)
+		if g.lastBody["out_refund_no"] == "RF-REJECT" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"code":"NOT_ENOUGH","message":"基本账户余额不足"}`))
+			return
+		}
+		if g.lastBody["out_refund_no"] == "RF-BUSY" {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(`{"code":"SYSTEM_ERROR","message":"系统繁忙"}`))
+			return
+		}
+		resp = map[string]string{"refund_id": "50000001", "out_refund_no": g.lastBody["out_refund_no"].(string), "status": "PROCESSING"}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	data, _ := json.Marshal(resp)
+	for key, values := range g.signHeaders(data) {
+		w.Header()[key] = values
+	}
+	if g.tamper && r.URL.Path != v3CertificatesPath {
+		data = append(data, ' ')
+	}
+	_, _ = w.Write(data)
+}
+
+// signHeaders 使用平台私钥生成应答/通知签名头
+func (g *v3TestGateway) signHeaders(body []byte) http.Header {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonce := "gatewaynonce"
+	hashed := sha256.Sum256([]byte(timestamp + "\n" + nonce + "\n" + string(body) + "\n"))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, g.platformKey, crypto.SHA256, hashed[:])
+	if err != nil {
+		g.t.Fatalf("sign response: %v", err)
+	}
+
+	header := http.Header{}
+	header.Set("Wechatpay-Serial", "PLATFORM1")
+	header.Set("Wechatpay-Timestamp", timestamp)
+	header.Set("Wechatpay-Nonce", nonce)
+	header.Set("Wechatpay-Signature", base64.StdEncoding.EncodeToString(sig))
+	return header
+}
+
+func TestCreateNativePayV3(t *testing.T) {
+	g := newV3TestGateway(t)
+	s := g.service()
+
+	resp, err := s.CreateNativePay("order-v3", 990, "body")
+	if err != nil {
+		t.Fatalf("create native pay v3 error: %v", err)
+	}
+	if resp.CodeURL != "weixin://wxpay/bizpayurl?pr=v3" {
+		t.Fatalf("unexpected code_url: %s", resp.CodeURL)
+	}
+	amount := g.lastBody["amount"].(map[string]interface{})
+	if amount["total"].(float64) != 990 || g.lastBody["out_trade_no"] != "order-v3" {
+		t.Fatalf("unexpected request body: %v", g.lastBody)
+	}
+
+	// 平台证书已缓存，不会重复下载
+	if _, err := s.CreateOrderV3(TradeTypeH5, &V3OrderRequest{OutTradeNo: "order-h5", Description: "body", Amount: 1, ClientIP: "1.2.3.4"}); err != nil {
+		t.Fatalf("create h5 order error: %v", err)
+	}
+	if g.certRequests != 1 {
+		t.Fatalf("expected platform certificates to be cached, got %d downloads", g.certRequests)
+	}
+}
+
+func TestPlatformCertificateUnknownSerialThrottled(t *testing.T) {
+	g := newV3TestGateway(t)
+	s := g.service()
+
+	// 并发的未知序列号只触发一次下载
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.platformCertificate("FORGED1"); err == nil {
+				t.Errorf("expected unknown serial error")
+			}
+		}()
+	}
+	wg.Wait()
+	if g.certRequests != 1 {
+		t.Fatalf("expected one download for unknown serial, got %d", g.certRequests)
+	}
+
+	// 下载后仍未知的序列号与其他未知序列号在间隔内都不再下载
+	for _, serial := range []string{"FORGED1", "FORGED2", "FORGED3"} {
+		if _, err := s.platformCertificate(serial); err == nil {
+			t.Fatalf("expected unknown serial error for %s", serial)
+		}
+	}
+	if g.certRequests != 1 {
+		t.Fatalf("expected unknown serials to be throttled, got %d downloads", g.certRequests)
+	}
+
+	if _, err := s.platformCertificate("PLATFORM1"); err != nil {
+		t.Fatalf("expected cached platform certificate: %v", err)
+	}
+
+	// 间隔过后重新下载，仍未知的序列号进入拒绝缓存
+	s.certsAttemptedAt = time.Now().Add(-platformCertMinRefreshInterval)
+	if _, err := s.platformCertificate("FORGED4"); err == nil {
+		t.Fatalf("expected unknown serial error")
+	}
+	s.certsAttemptedAt = time.Now().Add(-platformCertMinRefreshInterval)
+	if _, err := s.platformCertificate("FORGED4"); err == nil {
+		t.Fatalf("expected unknown serial error")
+	}
+	if g.certRequests != 2 {
+		t.Fatalf("expected missing serial to be cached, got %d downloads", g.certRequests)
+	}
+}
+
+func TestCreateOrderV3JSAPI(t *testing.T) {
+	g := newV3TestGateway(t)
+	s := g.service()
+
+	resp, err := s.CreateOrderV3(TradeTypeJSAPI, &V3OrderRequest{OutTradeNo: "order-js", Description: "body", Amount: 100, OpenID: "openid-1"})
+	if err != nil {
+		t.Fatalf("create jsapi order error: %v", err)
+	}
+	if resp.PrepayID != "wx-prepay-1" || resp.JSAPIParams == nil {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+	payer := g.lastBody["payer"].(map[string]interface{})
+	if payer["openid"] != "openid-1" {
+		t.Fatalf("unexpected payer: %v", payer)
+	}
+
+	p := resp.JSAPIParams
+	hashed := sha256.Sum256([]byte(p.AppID + "\n" + p.TimeStamp + "\n" + p.NonceStr + "\n" + p.Package + "\n"))
+	sig, _ := base64.StdEncoding.DecodeString(p.PaySign)
+	if err := rsa.VerifyPKCS1v15(&g.merchantKey.PublicKey, crypto.SHA256, hashed[:], sig); err != nil {
+		t.Fatalf("paySign invalid: %v", err)
+	}
+
+	if _, err := s.CreateOrderV3(TradeTypeJSAPI, &V3OrderRequest{OutTradeNo: "order-js", Description: "body", Amount: 100}); err == nil {
+		t.Fatalf("expected openid error")
+	}
+}
+
+func TestCreateOrderV3RejectsTamperedResponse(t *testing.T) {
+	g := newV3TestGateway(t)
+	g.tamper = true
+
+	_, err := g.service().CreateOrderV3(TradeTypeNative, &V3OrderRequest{OutTradeNo: "order-x", Description: "body", Amount: 1})
+	if err == nil || !strings.Contains(err.Error(), "签名") {
+		t.Fatalf("expected signature error, got %v", err)
+	}
+}
+
+func TestCreateOrderV3Mock(t *testing.T) {
+	s := NewService(&Config{AppID: "wx-app", APIVersion: "v3", MockEnabled: true})
+
+	native, err := s.CreateOrderV3(TradeTypeNative, &V3OrderRequest{OutTradeNo: "o-1", Description: "body", Amount: 1})
+	if err != nil || !strings.Contains(native.CodeURL, "o-1") {
+		t.Fatalf("unexpected native mock: %+v %v", native, err)
+	}
+	jsapi, err := s.CreateOrderV3(TradeTypeJSAPI, &V3OrderRequest{OutTradeNo: "o-1", Description: "body", Amount: 1, OpenID: "openid"})
+	if err != nil || jsapi.JSAPIParams == nil {
+		t.Fatalf("unexpected jsapi mock: %+v %v", jsapi, err)
+	}
+	if _, err := s.CreateOrderV3("APP", &V3OrderRequest{OutTradeNo: "o-1", Description: "body", Amount: 1}); err == nil {
+		t.Fatalf("expected unsupported trade type error")
+	}
+}
+
+func TestCreateOrderV3InvalidConfig(t *testing.T) {
+	s := NewService(&Config{APIVersion: "v3"})
+	_, err := s.CreateNativePay("order-1", 100, "body")
+	if err == nil || !strings.Contains(err.Error(), "v3配置不完整") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestParsePayNotifyV3(t *testing.T) {
+	g := newV3TestGateway(t)
+	s := g.service()
+
+	transaction, _ := json.Marshal(map[string]interface{}{
+		"mchid":          "mch-1",
+		"out_trade_no":   "order-v3",
+		"transaction_id": "4200001",
+		"trade_state":    "SUCCESS",
+		"amount":         map[string]interface{}{"total": 990, "currency": "CNY"},
+	})
+	resource, err := s.EncryptV3Resource(transaction, "notifynonce1", "transaction")
+	if err != nil {
+		t.Fatalf("encrypt resource: %v", err)
+	}
+	body, _ := json.Marshal(map[string]interface{}{
+		"id":            "evt-1",
+		"event_type":    "TRANSACTION.SUCCESS",
+		"resource_type": "encrypt-resource",
+		"resource":      resource,
+	})
+
+	result, err := s.ParsePayNotifyV3(g.signHeaders(body), body)
+	if err != nil {
+		t.Fatalf("parse notify v3 error: %v", err)
+	}
+	if result.OutTradeNo != "order-v3" || result.TransactionID != "4200001" || result.Amount.Total != 990 {
+		t.Fatalf("unexpected transaction: %+v", result)
+	}
+
+	tampered := append([]byte{}, body...)
+	tampered = append(tampered, ' ')
+	if _, err := s.ParsePayNotifyV3(g.signHeaders(body), tampered); err == nil {
+		t.Fatalf("expected signature error for tampered body")
+	}
+}
+
+func TestDecryptV3ResourceWrongKey(t *testing.T) {
+	s := NewService(&Config{APIKeyV3: testAPIKeyV3})
+	resource, err := s.EncryptV3Resource([]byte("hello"), "nonce1234567", "ad")
+	if err != nil {
+		t.Fatalf("encrypt error: %v", err)
+	}
+	plain, err := s.DecryptV3Resource(resource)
+	if err != nil || string(plain) != "hello" {
+		t.Fatalf("decrypt roundtrip failed: %s %v", plain, err)
+	}
+
+	other := NewService(&Config{APIKeyV3: "ffffffffffffffffffffffffffffffff"})
+	if _, err := other.DecryptV3Resource(resource); err == nil {
+		t.Fatalf("expected decrypt error with wrong key")
+	}
+	if _, err := NewService(&Config{APIKeyV3: "short"}).DecryptV3Resource(resource); err == nil {
+		t.Fatalf("expected key length error")
+	}
+}
+
+func TestBuildNotifyV3Response(t *testing.T) {
+	if string(BuildNotifyV3Response(true, "成功")) != `{"code":"SUCCESS","message":"成功"}` {
+		t.Fatalf("unexpected success response")
+	}
+	if !strings.Contains(string(BuildNotifyV3Response(false, "bad")), `"FAIL"`) {
+		t.Fatalf("unexpected fail response")
+	}
+}
@@ -0,0 +1,23 @@
+-- Migration: Add balance transaction ledger
+-- Date: 2026-10-18
+-- 余额流水表，所有 user_balances 变动均需写入一条流水
+
+CREATE TABLE IF NOT EXISTS `balance_transactions` (
+  `id` BIGINT NOT NULL AUTO_INCREMENT,
+  `user_id` BIGINT NOT NULL COMMENT '用户ID',
+  `type` VARCHAR(30) NOT NULL COMMENT '流水类型: reward_credit/withdrawal_hold/withdrawal_release/payout/refund_clawback/manual_adjustment',
+  `account` VARCHAR(30) NOT NULL DEFAULT 'available' COMMENT '本方账户: available/withdrawal_hold',
+  `counter_account` VARCHAR(30) NOT NULL COMMENT '对方账户',
+  `amount` DECIMAL(10,2) NOT NULL COMMENT '本方账户变动金额，正数入账、负数出账',
+  `balance_before` DECIMAL(10,2) NOT NULL COMMENT '变动前可用余额',
+  `balance_after` DECIMAL(10,2) NOT NULL COMMENT '变动后可用余额',
+  `ref_type` VARCHAR(30) NULL COMMENT '关联业务类型: order/withdrawal/refund',
+  `ref_id` BIGINT NULL COMMENT '关联业务ID',
+  `remark` VARCHAR(500) NULL COMMENT '备注',
+  `operator_id` BIGINT NULL COMMENT '操作人ID',
+  `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `idx_balance_tx_user_created` (`user_id`, `created_at`),
+  KEY `idx_balance_transactions_type` (`type`),
+  KEY `idx_balance_tx_ref` (`ref_type`, `ref_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='用户余额流水';
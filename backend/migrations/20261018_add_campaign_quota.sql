@@ -0,0 +1,7 @@
+-- Migration: Add campaign participant quota
+-- Date: 2026-10-18
+-- 活动总名额与每日名额，0 表示不限
+
+ALTER TABLE `campaigns`
+ADD COLUMN `total_quota` INT NOT NULL DEFAULT 0 COMMENT '总名额，0 表示不限' AFTER `poster_template_id`,
+ADD COLUMN `daily_quota` INT NOT NULL DEFAULT 0 COMMENT '每日名额，0 表示不限' AFTER `total_quota`;
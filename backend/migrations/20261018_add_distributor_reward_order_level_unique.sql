@@ -0,0 +1,8 @@
+-- Migration: Unique distributor reward per order and level
+-- Date: 2026-10-18
+-- 同一订单每个分销级别只能结算一次奖励，防止重复支付通知并发时重复入账
+-- 执行前请先核对重复数据：
+--   SELECT order_id, level, COUNT(*) FROM distributor_rewards GROUP BY order_id, level HAVING COUNT(*) > 1;
+
+ALTER TABLE `distributor_rewards`
+  ADD UNIQUE KEY `uk_distributor_rewards_order_level` (`order_id`, `level`);
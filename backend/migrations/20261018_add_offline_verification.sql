@@ -0,0 +1,9 @@
+-- Migration: Add offline verification
+-- Date: 2026-10-18
+-- 离线核销批量上传时记录订单品牌、设备与设备端核销ID，重复上传按 (brand_id, device_id, client_ref) 唯一键去重；在线核销三列为 NULL
+
+ALTER TABLE `verification_records`
+ADD COLUMN `brand_id` BIGINT NULL DEFAULT NULL COMMENT '离线核销订单所属品牌' AFTER `remark`,
+ADD COLUMN `device_id` VARCHAR(64) NULL DEFAULT NULL COMMENT '离线核销设备' AFTER `brand_id`,
+ADD COLUMN `client_ref` VARCHAR(64) NULL DEFAULT NULL COMMENT '设备端核销ID' AFTER `device_id`,
+ADD UNIQUE KEY `idx_device_ref` (`brand_id`, `device_id`, `client_ref`);
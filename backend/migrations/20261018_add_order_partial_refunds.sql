@@ -0,0 +1,12 @@
+-- Migration: Track partial refunds and pending WeChat refunds
+-- Date: 2026-10-18
+-- 订单记录累计退款金额，全额退款后才取消订单；退款记录增加状态，发起微信退款后待通知确认
+
+ALTER TABLE `orders`
+  ADD COLUMN `refunded_amount` DECIMAL(10,2) NOT NULL DEFAULT 0.00 COMMENT '已退款金额' AFTER `pay_status`;
+
+UPDATE `orders` SET `refunded_amount` = `amount` WHERE `pay_status` = 'refunded';
+
+ALTER TABLE `order_refunds`
+  ADD COLUMN `status` VARCHAR(20) NOT NULL DEFAULT 'succeeded' COMMENT '退款状态: pending/succeeded/failed' AFTER `source`,
+  ADD KEY `idx_order_refunds_order_status` (`order_id`, `status`);
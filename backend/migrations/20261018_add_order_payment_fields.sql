@@ -0,0 +1,9 @@
+-- Migration: Add order payment fields
+-- Date: 2026-10-18
+-- 订单支付单号与 Native 预支付结果缓存
+
+ALTER TABLE `orders`
+ADD COLUMN `out_trade_no` VARCHAR(64) NOT NULL DEFAULT '' COMMENT '支付商户订单号' AFTER `trade_no`,
+ADD COLUMN `pay_code_url` VARCHAR(255) NOT NULL DEFAULT '' COMMENT 'Native 支付链接（预支付结果缓存）' AFTER `out_trade_no`,
+ADD COLUMN `pay_expires_at` DATETIME NULL COMMENT '预支付过期时间' AFTER `pay_code_url`,
+ADD KEY `idx_orders_out_trade_no` (`out_trade_no`);
@@ -0,0 +1,18 @@
+-- Migration: Add order redemptions
+-- Date: 2026-10-18
+-- 多次卡、团体票：活动配置每单可核销次数，订单记录可核销次数与已核销次数，每次核销单独记录
+
+ALTER TABLE `campaigns`
+ADD COLUMN `redemptions_per_order` INT NOT NULL DEFAULT 1 COMMENT '每单可核销次数' AFTER `daily_quota`;
+
+ALTER TABLE `orders`
+ADD COLUMN `redemption_quota` INT NOT NULL DEFAULT 1 COMMENT '可核销次数' AFTER `short_code`,
+ADD COLUMN `redeemed_count` INT NOT NULL DEFAULT 0 COMMENT '已核销次数' AFTER `redemption_quota`;
+
+-- 已核销的历史订单视为用完唯一一次
+UPDATE `orders` SET `redeemed_count` = 1 WHERE `verification_status` = 'verified';
+
+ALTER TABLE `verification_records`
+ADD COLUMN `redemption_no` INT NOT NULL DEFAULT 0 COMMENT '第几次核销' AFTER `remark`;
+
+UPDATE `verification_records` SET `redemption_no` = 1 WHERE `verification_status` IN ('verified', 'cancelled');
@@ -0,0 +1,19 @@
+-- Migration: Add order refund records
+-- Date: 2026-10-18
+-- 退款记录表，refund_no 唯一用于保证退款处理幂等
+
+CREATE TABLE IF NOT EXISTS `order_refunds` (
+  `id` BIGINT NOT NULL AUTO_INCREMENT,
+  `order_id` BIGINT NOT NULL COMMENT '订单ID',
+  `refund_no` VARCHAR(64) NOT NULL COMMENT '退款单号（幂等键）',
+  `amount` DECIMAL(10,2) NOT NULL DEFAULT 0.00 COMMENT '退款金额',
+  `clawback_amount` DECIMAL(10,2) NOT NULL DEFAULT 0.00 COMMENT '追回的分销奖励总额',
+  `source` VARCHAR(20) NOT NULL DEFAULT 'admin' COMMENT '退款来源: admin/wechat',
+  `reason` VARCHAR(500) NULL COMMENT '退款原因',
+  `operator_id` BIGINT NULL COMMENT '操作人ID',
+  `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `updated_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uk_order_refunds_refund_no` (`refund_no`),
+  KEY `idx_order_refunds_order_id` (`order_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='订单退款记录';
@@ -0,0 +1,6 @@
+-- Migration: Add order seat counted flag
+-- Date: 2026-10-18
+-- 记录订单名额是否经 Redis 计数预占；数据库兜底创建的订单未计入计数，释放时不回退计数，改为重建计数
+
+ALTER TABLE `orders`
+ADD COLUMN `seat_counted` TINYINT(1) NOT NULL DEFAULT 0 COMMENT '名额是否计入 Redis 计数' AFTER `redeemed_count`;
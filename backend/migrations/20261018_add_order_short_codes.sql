@@ -0,0 +1,7 @@
+-- Migration: Add order short codes
+-- Date: 2026-10-18
+-- 核销短码供无法扫码时手动输入，同一活动内唯一；历史订单为 NULL
+
+ALTER TABLE `orders`
+ADD COLUMN `short_code` VARCHAR(8) NULL COMMENT '核销短码' AFTER `verification_code`,
+ADD UNIQUE KEY `uk_orders_short_code` (`campaign_id`, `short_code`);
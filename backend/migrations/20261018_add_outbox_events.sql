@@ -0,0 +1,19 @@
+-- Migration: Add outbox events
+-- Date: 2026-10-18
+-- 外部同步发件箱：支付回调、奖励结算、退款与提现变更在同一事务内写入事件，由中继投递到同步队列后标记为已投递
+
+CREATE TABLE IF NOT EXISTS `outbox_events` (
+  `id` BIGINT NOT NULL AUTO_INCREMENT,
+  `event_type` VARCHAR(50) NOT NULL COMMENT '事件类型: order.paid/order.refunded/reward.settled/withdrawal.changed',
+  `aggregate_type` VARCHAR(30) NOT NULL COMMENT '聚合类型: order/distributor_reward/withdrawal',
+  `aggregate_id` BIGINT NOT NULL COMMENT '聚合ID',
+  `status` VARCHAR(20) NOT NULL DEFAULT 'pending' COMMENT '状态: pending/delivered',
+  `attempts` INT NOT NULL DEFAULT 0 COMMENT '投递失败次数',
+  `last_error` VARCHAR(500) NULL COMMENT '最近一次投递失败原因',
+  `next_attempt_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '下次投递时间',
+  `delivered_at` DATETIME NULL COMMENT '投递时间',
+  `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `idx_outbox_status_next` (`status`, `next_attempt_at`),
+  KEY `idx_outbox_aggregate` (`aggregate_type`, `aggregate_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='外部同步发件箱';
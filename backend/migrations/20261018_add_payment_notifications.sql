@@ -0,0 +1,22 @@
+-- Migration: Add payment notification audit log
+-- Date: 2026-10-18
+-- 支付结果通知原始报文，无论验签/校验是否通过都会记录
+
+CREATE TABLE IF NOT EXISTS `payment_notifications` (
+  `id` BIGINT NOT NULL AUTO_INCREMENT,
+  `provider` VARCHAR(20) NOT NULL COMMENT '通知来源: wechat_v2/wechat_v3',
+  `out_trade_no` VARCHAR(64) NOT NULL DEFAULT '' COMMENT '商户订单号',
+  `transaction_id` VARCHAR(64) NOT NULL DEFAULT '' COMMENT '支付平台交易号',
+  `order_id` BIGINT NOT NULL DEFAULT 0 COMMENT '订单ID',
+  `amount` DECIMAL(10,2) NOT NULL DEFAULT 0.00 COMMENT '通知中的支付金额',
+  `status` VARCHAR(20) NOT NULL COMMENT '处理结果: processed/duplicate/rejected/failed',
+  `error_msg` VARCHAR(500) NOT NULL DEFAULT '' COMMENT '拒绝或处理失败原因',
+  `raw_body` TEXT NULL COMMENT '原始通知报文',
+  `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `idx_payment_notifications_out_trade_no` (`out_trade_no`),
+  KEY `idx_payment_notifications_transaction_id` (`transaction_id`),
+  KEY `idx_payment_notifications_order_id` (`order_id`),
+  KEY `idx_payment_notifications_status` (`status`),
+  KEY `idx_payment_notifications_created_at` (`created_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='支付结果通知记录';
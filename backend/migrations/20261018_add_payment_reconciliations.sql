@@ -0,0 +1,38 @@
+-- Migration: Add payment reconciliation reports
+-- Date: 2026-10-18
+-- 微信交易账单与订单的对账报告及差异明细
+
+CREATE TABLE IF NOT EXISTS `payment_reconciliations` (
+  `id` BIGINT NOT NULL AUTO_INCREMENT,
+  `bill_date` VARCHAR(10) NOT NULL COMMENT '账单日 YYYY-MM-DD',
+  `source` VARCHAR(20) NOT NULL COMMENT '账单来源: download/upload',
+  `bill_count` INT NOT NULL DEFAULT 0 COMMENT '账单中的支付成功笔数',
+  `matched_count` INT NOT NULL DEFAULT 0 COMMENT '核对一致笔数',
+  `missing_count` INT NOT NULL DEFAULT 0 COMMENT '订单已支付但账单中没有',
+  `extra_count` INT NOT NULL DEFAULT 0 COMMENT '账单中有但订单未支付或不存在',
+  `mismatch_count` INT NOT NULL DEFAULT 0 COMMENT '金额不一致笔数',
+  `repaired_count` INT NOT NULL DEFAULT 0 COMMENT '自动补单成功笔数',
+  `bill_amount` DECIMAL(12,2) NOT NULL DEFAULT 0.00 COMMENT '账单支付成功总金额',
+  `operator_id` BIGINT NULL COMMENT '发起对账的用户ID',
+  `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `idx_payment_reconciliations_bill_date` (`bill_date`),
+  KEY `idx_payment_reconciliations_created_at` (`created_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='支付对账报告';
+
+CREATE TABLE IF NOT EXISTS `payment_reconciliation_items` (
+  `id` BIGINT NOT NULL AUTO_INCREMENT,
+  `reconciliation_id` BIGINT NOT NULL COMMENT '对账报告ID',
+  `type` VARCHAR(20) NOT NULL COMMENT '差异类型: missing/extra/amount_mismatch',
+  `order_id` BIGINT NOT NULL DEFAULT 0 COMMENT '订单ID',
+  `transaction_id` VARCHAR(64) NOT NULL DEFAULT '' COMMENT '微信订单号',
+  `out_trade_no` VARCHAR(64) NOT NULL DEFAULT '' COMMENT '商户订单号',
+  `bill_amount` DECIMAL(10,2) NOT NULL DEFAULT 0.00 COMMENT '账单金额',
+  `order_amount` DECIMAL(10,2) NOT NULL DEFAULT 0.00 COMMENT '订单金额',
+  `repaired` TINYINT(1) NOT NULL DEFAULT 0 COMMENT '是否已通过支付回调补单',
+  `remark` VARCHAR(500) NOT NULL DEFAULT '' COMMENT '差异说明或补单失败原因',
+  `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `idx_payment_reconciliation_items_reconciliation_id` (`reconciliation_id`),
+  KEY `idx_payment_reconciliation_items_order_id` (`order_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='支付对账差异明细';
@@ -0,0 +1,15 @@
+-- Migration: Add sync attempts
+-- Date: 2026-10-18
+-- 外部同步每次尝试写入一行历史，sync_logs 继续保存每个对象的最新状态
+
+CREATE TABLE IF NOT EXISTS `sync_attempts` (
+  `id` BIGINT NOT NULL AUTO_INCREMENT,
+  `ref_id` BIGINT NOT NULL COMMENT '订单、奖励或提现ID',
+  `sync_type` VARCHAR(20) NOT NULL COMMENT '同步类型: order/reward/distributor_reward/withdrawal',
+  `attempt` INT NOT NULL DEFAULT 0 COMMENT '第几次尝试',
+  `sync_status` VARCHAR(20) NOT NULL COMMENT '本次尝试后的状态: pending/synced/failed',
+  `error_msg` TEXT NULL COMMENT '失败原因',
+  `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `idx_sync_attempts_ref` (`sync_type`, `ref_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='外部同步尝试历史';
@@ -0,0 +1,22 @@
+-- Migration: Add sync backfill checkpoints
+-- Date: 2026-10-18
+-- 外部同步历史回填的检查点，中断后按名称从 last_id 之后继续
+
+CREATE TABLE IF NOT EXISTS `sync_backfill_checkpoints` (
+  `id` BIGINT NOT NULL AUTO_INCREMENT,
+  `name` VARCHAR(64) NOT NULL COMMENT '回填名称',
+  `target` VARCHAR(20) NOT NULL COMMENT '回填目标: order/reward',
+  `filter` VARCHAR(500) NOT NULL DEFAULT '' COMMENT '筛选条件 JSON',
+  `last_id` BIGINT NOT NULL DEFAULT 0 COMMENT '已处理到的源记录ID',
+  `synced` BIGINT NOT NULL DEFAULT 0 COMMENT '已写入条数',
+  `failed` BIGINT NOT NULL DEFAULT 0 COMMENT '写入失败条数',
+  `status` VARCHAR(20) NOT NULL DEFAULT 'running' COMMENT '状态: running/paused/completed',
+  `last_error` VARCHAR(500) NULL COMMENT '最近一次失败原因',
+  `lease_owner` VARCHAR(100) NOT NULL DEFAULT '' COMMENT '持有执行租约的实例',
+  `lease_until` DATETIME NULL COMMENT '执行租约到期时间',
+  `finished_at` DATETIME NULL COMMENT '完成时间',
+  `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `updated_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uk_backfill_name_target` (`name`, `target`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='外部同步回填检查点';
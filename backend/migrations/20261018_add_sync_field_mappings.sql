@@ -0,0 +1,17 @@
+-- Migration: Add sync field mappings
+-- Date: 2026-10-18
+-- 外部同步的字段映射配置（ExternalSync.MappingSource 为 db 时启用），mapping 为 JSON：
+-- {"Table":"ERP_ORDER","SyncedAtColumn":"SYNC_TIME","Columns":[{"Column":"ORDER_NO","Source":"order_id","Key":true},
+--  {"Column":"PAY_AMOUNT","Source":"amount","Transform":"fen"},{"Column":"STATUS","Source":"pay_status","Transform":"map","ValueMap":{"paid":"1","*":"0"}}]}
+
+CREATE TABLE IF NOT EXISTS `sync_field_mappings` (
+  `id` BIGINT NOT NULL AUTO_INCREMENT,
+  `target` VARCHAR(20) NOT NULL COMMENT '映射目标: order/reward',
+  `mapping` TEXT NOT NULL COMMENT '映射配置 JSON',
+  `enabled` TINYINT(1) NOT NULL DEFAULT 1 COMMENT '是否启用',
+  `remark` VARCHAR(255) NULL COMMENT '备注',
+  `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `updated_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `idx_sync_field_mappings_target` (`target`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='外部同步字段映射';
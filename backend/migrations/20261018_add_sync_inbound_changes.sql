@@ -0,0 +1,33 @@
+-- Migration: Add sync inbound changes
+-- Date: 2026-10-18
+-- 从外部数据库变更表导入订单与会员变更：逐条记录处理结果并按行键去重，按来源表保存 (水位, 行键) 复合游标
+
+CREATE TABLE IF NOT EXISTS `sync_inbound_changes` (
+  `id` BIGINT NOT NULL AUTO_INCREMENT,
+  `source_table` VARCHAR(64) NOT NULL COMMENT '外部变更表',
+  `row_key` VARCHAR(64) NOT NULL COMMENT '变更行的唯一键，未配置行键列时为水位',
+  `watermark` VARCHAR(64) NOT NULL COMMENT '变更行的水位值',
+  `change_type` VARCHAR(32) NOT NULL COMMENT '变更类型: order_refunded/order_verified/order_unverified/member_updated',
+  `order_id` BIGINT NOT NULL DEFAULT 0,
+  `member_id` BIGINT NOT NULL DEFAULT 0,
+  `payload` TEXT NULL COMMENT '外部变更行原始内容 JSON',
+  `external_changed_at` DATETIME NULL COMMENT '外部系统的变更时间',
+  `status` VARCHAR(20) NOT NULL COMMENT '处理结果: applied/skipped/conflict/rejected',
+  `detail` VARCHAR(500) NULL COMMENT '处理说明',
+  `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uk_inbound_source_row` (`source_table`, `row_key`),
+  KEY `idx_sync_inbound_changes_order_id` (`order_id`),
+  KEY `idx_sync_inbound_changes_member_id` (`member_id`),
+  KEY `idx_sync_inbound_changes_status` (`status`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='外部同步导入变更';
+
+CREATE TABLE IF NOT EXISTS `sync_inbound_cursors` (
+  `id` BIGINT NOT NULL AUTO_INCREMENT,
+  `source_table` VARCHAR(64) NOT NULL COMMENT '外部变更表',
+  `watermark` VARCHAR(64) NOT NULL DEFAULT '' COMMENT '已处理到的水位',
+  `row_key` VARCHAR(64) NOT NULL DEFAULT '' COMMENT '已处理到的行键，与水位组成复合游标',
+  `updated_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `idx_sync_inbound_cursors_source_table` (`source_table`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='外部同步导入水位';
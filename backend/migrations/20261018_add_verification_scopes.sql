@@ -0,0 +1,18 @@
+-- Migration: Add verification scopes
+-- Date: 2026-10-18
+-- 核销按订单所属品牌校验权限；核销人员可限定到指定活动或场地，未配置范围时可核销所管理品牌的全部订单
+
+ALTER TABLE `campaigns`
+ADD COLUMN `venue` VARCHAR(100) NOT NULL DEFAULT '' COMMENT '活动场地' AFTER `redemptions_per_order`;
+
+CREATE TABLE IF NOT EXISTS `verification_scopes` (
+  `id` BIGINT NOT NULL AUTO_INCREMENT,
+  `user_id` BIGINT NOT NULL COMMENT '核销人员',
+  `brand_id` BIGINT NOT NULL COMMENT '品牌',
+  `campaign_id` BIGINT NOT NULL DEFAULT 0 COMMENT '限定活动，0 表示不限',
+  `venue` VARCHAR(100) NOT NULL DEFAULT '' COMMENT '限定场地，为空表示不限',
+  `created_by` BIGINT NOT NULL DEFAULT 0,
+  `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `idx_scope_user_brand` (`user_id`, `brand_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='核销人员授权范围';
@@ -0,0 +1,45 @@
+-- Migration: Add webhooks
+-- Date: 2026-10-18
+-- 品牌注册的 Webhook 端点与投递记录，业务事件以 HMAC 签名的 JSON 推送给集成方
+
+CREATE TABLE IF NOT EXISTS `webhook_endpoints` (
+  `id` BIGINT NOT NULL AUTO_INCREMENT,
+  `brand_id` BIGINT NOT NULL COMMENT '品牌ID',
+  `url` VARCHAR(500) NOT NULL COMMENT '接收地址',
+  `secret` VARCHAR(100) NOT NULL COMMENT '签名密钥',
+  `events` VARCHAR(500) NOT NULL COMMENT '订阅的事件类型，逗号分隔',
+  `status` VARCHAR(20) NOT NULL DEFAULT 'active' COMMENT '状态: active/disabled',
+  `description` VARCHAR(200) NULL,
+  `created_by` BIGINT NOT NULL DEFAULT 0,
+  `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `updated_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `idx_webhook_endpoints_brand_id` (`brand_id`),
+  KEY `idx_webhook_endpoints_status` (`status`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='Webhook 端点';
+
+CREATE TABLE IF NOT EXISTS `webhook_deliveries` (
+  `id` BIGINT NOT NULL AUTO_INCREMENT,
+  `endpoint_id` BIGINT NOT NULL COMMENT 'Webhook 端点ID',
+  `brand_id` BIGINT NOT NULL COMMENT '品牌ID',
+  `event_id` VARCHAR(64) NOT NULL COMMENT '事件ID，接收方据此去重',
+  `event_type` VARCHAR(50) NOT NULL COMMENT '事件类型',
+  `payload` TEXT NOT NULL COMMENT '推送的 JSON',
+  `status` VARCHAR(20) NOT NULL DEFAULT 'pending' COMMENT '状态: pending/delivered/failed',
+  `attempts` INT NOT NULL DEFAULT 0 COMMENT '已尝试次数',
+  `next_attempt_at` DATETIME NOT NULL COMMENT '下次投递时间',
+  `response_status` INT NOT NULL DEFAULT 0 COMMENT '最近一次响应状态码',
+  `response_body` VARCHAR(500) NULL COMMENT '最近一次响应内容',
+  `last_error` VARCHAR(500) NULL COMMENT '最近一次失败原因',
+  `duration_ms` BIGINT NOT NULL DEFAULT 0 COMMENT '最近一次请求耗时',
+  `redelivery_of` BIGINT NULL COMMENT '手动重新投递的原记录ID',
+  `delivered_at` DATETIME NULL,
+  `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `updated_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `idx_webhook_deliveries_endpoint_id` (`endpoint_id`),
+  KEY `idx_webhook_deliveries_brand_id` (`brand_id`),
+  KEY `idx_webhook_deliveries_event_id` (`event_id`),
+  KEY `idx_webhook_deliveries_created_at` (`created_at`),
+  KEY `idx_webhook_delivery_status_next` (`status`, `next_attempt_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='Webhook 投递记录';
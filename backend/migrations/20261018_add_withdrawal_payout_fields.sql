@@ -0,0 +1,9 @@
+-- Migration: Add WeChat payout tracking to withdrawals
+-- Date: 2026-10-18
+-- 提现打款Worker使用的重试与租约字段
+
+ALTER TABLE `withdrawals`
+ADD COLUMN `payout_attempts` INT NOT NULL DEFAULT 0 COMMENT '打款尝试次数' AFTER `trade_no`,
+ADD COLUMN `next_payout_at` DATETIME NULL COMMENT '下次打款时间（重试或处理租约到期）' AFTER `payout_attempts`,
+ADD COLUMN `payout_error` VARCHAR(500) NULL COMMENT '最近一次打款失败原因' AFTER `next_payout_at`,
+ADD KEY `idx_withdrawals_next_payout_at` (`next_payout_at`);
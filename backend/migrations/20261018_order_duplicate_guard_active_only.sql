@@ -0,0 +1,8 @@
+-- Migration: Order duplicate guard ignores cancelled orders
+-- Date: 2026-10-18
+-- 同一活动同一手机号只允许一条有效订单；超时取消或删除的订单不再占用报名名额
+
+ALTER TABLE `orders`
+ADD COLUMN `active_flag` TINYINT GENERATED ALWAYS AS (IF(`status` = 'cancelled' OR `deleted_at` IS NOT NULL, NULL, 1)) STORED COMMENT '有效订单标记，取消或删除后为NULL',
+DROP INDEX `uk_campaign_phone`,
+ADD UNIQUE KEY `uk_orders_campaign_phone` (`campaign_id`, `phone`, `active_flag`);
@@ -1,6 +1,11 @@
 package model
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
 	"time"
 
 	_ "gorm.io/gorm" // gorm tags are used by gorm.io/gorm
@@ -32,20 +37,74 @@ type Campaign struct {
 	DistributionRewards *string    `gorm:"column:distribution_rewards;type:json" json:"distributionRewards,omitempty"`        // 各级奖励比例
 	PaymentConfig       *string    `gorm:"column:payment_config;type:json" json:"paymentConfig,omitempty"`                    // 支付配置
 	PosterTemplateId    int64      `gorm:"column:poster_template_id;default:1" json:"posterTemplateId"`                       // 海报模板ID
+	TotalQuota          int        `gorm:"column:total_quota;not null;default:0" json:"totalQuota"`                           // 总名额，0 表示不限
+	DailyQuota          int        `gorm:"column:daily_quota;not null;default:0" json:"dailyQuota"`                           // 每日名额，0 表示不限
+	RedemptionsPerOrder int        `gorm:"column:redemptions_per_order;not null;default:1" json:"redemptionsPerOrder"`        // 每单可核销次数（多次卡、团体票）
+	Venue               string     `gorm:"column:venue;type:varchar(100);not null;default:''" json:"venue"`                   // 活动场地，用于限定核销人员范围
 	CreatedAt           time.Time  `gorm:"column:created_at;not null;autoCreateTime" json:"createdAt"`
 	UpdatedAt           time.Time  `gorm:"column:updated_at;not null;autoUpdateTime" json:"updatedAt"`
 	DeletedAt           *time.Time `gorm:"column:deleted_at" json:"deletedAt,omitempty"`
 }
 
+// MaxRedemptionsPerOrder 每单可核销次数上限
+const MaxRedemptionsPerOrder = 100
+
 // TableName 表名
 func (m *Campaign) TableName() string {
 	return "campaigns"
 }
 
+// HasQuota 是否设置了名额限制
+func (m *Campaign) HasQuota() bool {
+	return m.TotalQuota > 0 || m.DailyQuota > 0
+}
+
+// CampaignPaymentConfig 活动支付配置（campaigns.payment_config）
+type CampaignPaymentConfig struct {
+	RequirePayment bool    `json:"requirePayment"`
+	PaymentType    string  `json:"paymentType"`             // deposit（订金）, full（全款）
+	PaymentAmount  float64 `json:"paymentAmount"`           // 支付金额（元）
+	DepositAmount  float64 `json:"depositAmount,omitempty"` // 兼容旧配置：订金金额
+	FullAmount     float64 `json:"fullAmount,omitempty"`    // 兼容旧配置：全款金额
+}
+
+// ParseCampaignPaymentConfig 解析活动支付配置，未配置时返回 nil
+func ParseCampaignPaymentConfig(raw *string) (*CampaignPaymentConfig, error) {
+	if raw == nil || strings.TrimSpace(*raw) == "" {
+		return nil, nil
+	}
+
+	var config CampaignPaymentConfig
+	if err := json.Unmarshal([]byte(*raw), &config); err != nil {
+		return nil, fmt.Errorf("支付配置格式错误: %w", err)
+	}
+	if config.PaymentAmount < 0 || config.DepositAmount < 0 || config.FullAmount < 0 {
+		return nil, errors.New("支付金额不能为负数")
+	}
+	return &config, nil
+}
+
+// Price 报名应付金额（元），免费活动为 0
+func (c *CampaignPaymentConfig) Price() float64 {
+	if c == nil {
+		return 0
+	}
+
+	price := c.PaymentAmount
+	if price <= 0 {
+		if c.PaymentType == "full" {
+			price = c.FullAmount
+		} else {
+			price = c.DepositAmount
+		}
+	}
+	return math.Round(price*100) / 100
+}
+
 // Order 订单模型
 type Order struct {
 	Id                 int64      `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
-	CampaignId         int64      `gorm:"column:campaign_id;not null;index" json:"campaignId"`
+	CampaignId         int64      `gorm:"column:campaign_id;not null;index;uniqueIndex:uk_orders_short_code,priority:1" json:"campaignId"`
 	MemberID           *int64     `gorm:"column:member_id;index" json:"memberId"`                // 关联会员ID（可选）
 	UnionID            string     `gorm:"column:unionid;type:varchar(100);index" json:"unionid"` // 微信 unionid
 	Phone              string     `gorm:"column:phone;type:varchar(20);not null;index" json:"phone"`
@@ -54,14 +113,22 @@ type Order struct {
 	DistributorPath    string     `gorm:"column:distributor_path;type:varchar(100);default:'';index:idx_distributor_path" json:"distributorPath"` // 分销链路径 "一级ID,二级ID,三级ID"
 	Status             string     `gorm:"column:status;type:varchar(20);not null;default:pending;index" json:"status"`                            // pending, paid, cancelled
 	Amount             float64    `gorm:"column:amount;type:decimal(10,2);not null;default:0.00" json:"amount"`
-	PayStatus          string     `gorm:"column:pay_status;type:varchar(20);not null;default:unpaid;index" json:"payStatus"` // unpaid, paid, refunded
+	PayStatus          string     `gorm:"column:pay_status;type:varchar(20);not null;default:unpaid;index" json:"payStatus"`     // unpaid, paid, refunded
+	RefundedAmount     float64    `gorm:"column:refunded_amount;type:decimal(10,2);not null;default:0.00" json:"refundedAmount"` // 已退款金额，全额退款后 pay_status 才变为 refunded
 	TradeNo            string     `gorm:"column:trade_no;type:varchar(100);default:''" json:"tradeNo"`
+	OutTradeNo         string     `gorm:"column:out_trade_no;type:varchar(64);default:'';index" json:"outTradeNo"`                        // 支付商户订单号
+	PayCodeURL         string     `gorm:"column:pay_code_url;type:varchar(255);default:''" json:"-"`                                      // Native 支付链接（预支付结果缓存）
+	PayExpiresAt       *time.Time `gorm:"column:pay_expires_at" json:"payExpiresAt,omitempty"`                                            // 预支付过期时间
 	PaidAt             *time.Time `gorm:"column:paid_at" json:"paidAt,omitempty"`                                                         // 支付时间
 	SyncStatus         string     `gorm:"column:sync_status;type:varchar(20);not null;default:pending;index" json:"syncStatus"`           // pending, synced, failed
-	VerificationStatus string     `gorm:"column:verification_status;type:varchar(20);default:unverified;index" json:"verificationStatus"` // unverified, verified, cancelled
+	VerificationStatus string     `gorm:"column:verification_status;type:varchar(20);default:unverified;index" json:"verificationStatus"` // unverified, partial, verified, cancelled
 	VerifiedAt         *time.Time `gorm:"column:verified_at" json:"verifiedAt,omitempty"`                                                 // 核销时间
 	VerifiedBy         *int64     `gorm:"column:verified_by" json:"verifiedBy,omitempty"`                                                 // 核销人用户ID
 	VerificationCode   string     `gorm:"column:verification_code;type:varchar(128);null" json:"verificationCode,omitempty"`              // 核销码（包含签名）
+	ShortCode          *string    `gorm:"column:short_code;type:varchar(8);uniqueIndex:uk_orders_short_code,priority:2" json:"shortCode"` // 核销短码，活动内唯一
+	RedemptionQuota    int        `gorm:"column:redemption_quota;not null;default:1" json:"redemptionQuota"`                              // 可核销次数，下单时取自活动配置
+	RedeemedCount      int        `gorm:"column:redeemed_count;not null;default:0" json:"redeemedCount"`                                  // 已核销次数
+	SeatCounted        bool       `gorm:"column:seat_counted;not null;default:false" json:"-"`                                            // 名额是否计入 Redis 计数，释放名额时只回退计入过的订单
 	CreatedAt          time.Time  `gorm:"column:created_at;not null;autoCreateTime;index" json:"createdAt"`
 	UpdatedAt          time.Time  `gorm:"column:updated_at;not null;autoUpdateTime" json:"updatedAt"`
 	DeletedAt          *time.Time `gorm:"column:deleted_at" json:"deletedAt,omitempty"`
@@ -72,6 +139,113 @@ func (m *Order) TableName() string {
 	return "orders"
 }
 
+// RemainingRedemptions 剩余可核销次数，状态已是 verified 时为 0
+func (m *Order) RemainingRedemptions() int {
+	if m.VerificationStatus == "verified" {
+		return 0
+	}
+	quota := m.RedemptionQuota
+	if quota < 1 {
+		quota = 1
+	}
+	if m.RedeemedCount >= quota {
+		return 0
+	}
+	return quota - m.RedeemedCount
+}
+
+// RedemptionStatus 按已核销次数计算核销状态：用完为 verified，部分使用为 partial
+func (m *Order) RedemptionStatus(redeemed int) string {
+	switch {
+	case redeemed <= 0:
+		return "cancelled"
+	case redeemed < m.RedemptionQuota:
+		return "partial"
+	default:
+		return "verified"
+	}
+}
+
+// OrderRefund 订单退款记录
+type OrderRefund struct {
+	Id             int64     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	OrderId        int64     `gorm:"column:order_id;not null;index" json:"orderId"`
+	RefundNo       string    `gorm:"column:refund_no;type:varchar(64);not null;uniqueIndex" json:"refundNo"` // 退款单号（幂等键）
+	Amount         float64   `gorm:"column:amount;type:decimal(10,2);not null;default:0.00" json:"amount"`
+	ClawbackAmount float64   `gorm:"column:clawback_amount;type:decimal(10,2);not null;default:0.00" json:"clawbackAmount"` // 追回的分销奖励总额
+	Source         string    `gorm:"column:source;type:varchar(20);not null;default:admin" json:"source"`                   // admin, wechat
+	Status         string    `gorm:"column:status;type:varchar(20);not null;default:succeeded" json:"status"`               // pending, succeeded, failed
+	Reason         string    `gorm:"column:reason;type:varchar(500)" json:"reason"`
+	OperatorId     *int64    `gorm:"column:operator_id" json:"operatorId,omitempty"`
+	CreatedAt      time.Time `gorm:"column:created_at;not null;autoCreateTime" json:"createdAt"`
+	UpdatedAt      time.Time `gorm:"column:updated_at;not null;autoUpdateTime" json:"updatedAt"`
+}
+
+// TableName 表名
+func (m *OrderRefund) TableName() string {
+	return "order_refunds"
+}
+
+// PaymentNotification 支付结果通知记录，保留原始报文用于审计
+type PaymentNotification struct {
+	Id            int64     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	Provider      string    `gorm:"column:provider;type:varchar(20);not null" json:"provider"` // wechat_v2, wechat_v3
+	OutTradeNo    string    `gorm:"column:out_trade_no;type:varchar(64);not null;default:'';index" json:"outTradeNo"`
+	TransactionId string    `gorm:"column:transaction_id;type:varchar(64);not null;default:'';index" json:"transactionId"`
+	OrderId       int64     `gorm:"column:order_id;not null;default:0;index" json:"orderId"`
+	Amount        float64   `gorm:"column:amount;type:decimal(10,2);not null;default:0.00" json:"amount"`   // 通知中的支付金额
+	Status        string    `gorm:"column:status;type:varchar(20);not null;index" json:"status"`            // processed, duplicate, rejected, failed
+	ErrorMsg      string    `gorm:"column:error_msg;type:varchar(500);not null;default:''" json:"errorMsg"` // 拒绝或处理失败原因
+	RawBody       string    `gorm:"column:raw_body;type:text" json:"rawBody"`                               // 原始通知报文
+	CreatedAt     time.Time `gorm:"column:created_at;not null;autoCreateTime;index" json:"createdAt"`
+}
+
+// TableName 表名
+func (m *PaymentNotification) TableName() string {
+	return "payment_notifications"
+}
+
+// PaymentReconciliation 支付对账报告，按账单日核对微信交易账单与订单
+type PaymentReconciliation struct {
+	Id            int64     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	BillDate      string    `gorm:"column:bill_date;type:varchar(10);not null;index" json:"billDate"` // 账单日 YYYY-MM-DD
+	Source        string    `gorm:"column:source;type:varchar(20);not null" json:"source"`            // download, upload
+	BillCount     int       `gorm:"column:bill_count;not null;default:0" json:"billCount"`            // 账单中的支付成功笔数
+	MatchedCount  int       `gorm:"column:matched_count;not null;default:0" json:"matchedCount"`
+	MissingCount  int       `gorm:"column:missing_count;not null;default:0" json:"missingCount"`   // 订单已支付但账单中没有
+	ExtraCount    int       `gorm:"column:extra_count;not null;default:0" json:"extraCount"`       // 账单中有但订单未支付或不存在
+	MismatchCount int       `gorm:"column:mismatch_count;not null;default:0" json:"mismatchCount"` // 金额不一致
+	RepairedCount int       `gorm:"column:repaired_count;not null;default:0" json:"repairedCount"` // 自动补单成功
+	BillAmount    float64   `gorm:"column:bill_amount;type:decimal(12,2);not null;default:0.00" json:"billAmount"`
+	OperatorId    *int64    `gorm:"column:operator_id" json:"operatorId,omitempty"`
+	CreatedAt     time.Time `gorm:"column:created_at;not null;autoCreateTime;index" json:"createdAt"`
+}
+
+// TableName 表名
+func (m *PaymentReconciliation) TableName() string {
+	return "payment_reconciliations"
+}
+
+// PaymentReconciliationItem 对账差异明细
+type PaymentReconciliationItem struct {
+	Id               int64     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	ReconciliationId int64     `gorm:"column:reconciliation_id;not null;index" json:"reconciliationId"`
+	Type             string    `gorm:"column:type;type:varchar(20);not null" json:"type"` // missing, extra, amount_mismatch
+	OrderId          int64     `gorm:"column:order_id;not null;default:0;index" json:"orderId"`
+	TransactionId    string    `gorm:"column:transaction_id;type:varchar(64);not null;default:''" json:"transactionId"`
+	OutTradeNo       string    `gorm:"column:out_trade_no;type:varchar(64);not null;default:''" json:"outTradeNo"`
+	BillAmount       float64   `gorm:"column:bill_amount;type:decimal(10,2);not null;default:0.00" json:"billAmount"`
+	OrderAmount      float64   `gorm:"column:order_amount;type:decimal(10,2);not null;default:0.00" json:"orderAmount"`
+	Repaired         bool      `gorm:"column:repaired;not null;default:false" json:"repaired"`            // 已通过支付回调补单
+	Remark           string    `gorm:"column:remark;type:varchar(500);not null;default:''" json:"remark"` // 差异说明或补单失败原因
+	CreatedAt        time.Time `gorm:"column:created_at;not null;autoCreateTime" json:"createdAt"`
+}
+
+// TableName 表名
+func (m *PaymentReconciliationItem) TableName() string {
+	return "payment_reconciliation_items"
+}
+
 // Reward 奖励记录模型
 type Reward struct {
 	Id         int64      `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
@@ -107,6 +281,28 @@ func (m *UserBalance) TableName() string {
 	return "user_balances"
 }
 
+// BalanceTransaction 余额流水（复式记账，每笔流水记录本方账户与对方账户）
+type BalanceTransaction struct {
+	Id             int64     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	UserId         int64     `gorm:"column:user_id;not null;index:idx_balance_tx_user_created,priority:1" json:"userId"`
+	Type           string    `gorm:"column:type;type:varchar(30);not null;index" json:"type"`                             // reward_credit, withdrawal_hold, withdrawal_release, payout, refund_clawback, manual_adjustment
+	Account        string    `gorm:"column:account;type:varchar(30);not null;default:available" json:"account"`           // available, withdrawal_hold
+	CounterAccount string    `gorm:"column:counter_account;type:varchar(30);not null" json:"counterAccount"`              // 对方账户
+	Amount         float64   `gorm:"column:amount;type:decimal(10,2);not null" json:"amount"`                             // 本方账户变动金额，正数入账、负数出账
+	BalanceBefore  float64   `gorm:"column:balance_before;type:decimal(10,2);not null" json:"balanceBefore"`              // 变动前可用余额
+	BalanceAfter   float64   `gorm:"column:balance_after;type:decimal(10,2);not null" json:"balanceAfter"`                // 变动后可用余额
+	RefType        string    `gorm:"column:ref_type;type:varchar(30);index:idx_balance_tx_ref,priority:1" json:"refType"` // order, withdrawal, refund
+	RefId          int64     `gorm:"column:ref_id;index:idx_balance_tx_ref,priority:2" json:"refId"`
+	Remark         string    `gorm:"column:remark;type:varchar(500)" json:"remark"`
+	OperatorId     *int64    `gorm:"column:operator_id" json:"operatorId,omitempty"`
+	CreatedAt      time.Time `gorm:"column:created_at;not null;autoCreateTime;index:idx_balance_tx_user_created,priority:2" json:"createdAt"`
+}
+
+// TableName 表名
+func (m *BalanceTransaction) TableName() string {
+	return "balance_transactions"
+}
+
 // SyncLog 同步日志模型
 type SyncLog struct {
 	Id         int64      `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
@@ -124,3 +320,112 @@ type SyncLog struct {
 func (m *SyncLog) TableName() string {
 	return "sync_logs"
 }
+
+// SyncAttempt 同步尝试历史，每次同步一行；sync_logs 只保留每个对象的最新状态
+type SyncAttempt struct {
+	Id         int64     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	RefId      int64     `gorm:"column:ref_id;not null;index:idx_sync_attempts_ref,priority:2" json:"refId"`                        // 订单、奖励或提现ID
+	SyncType   string    `gorm:"column:sync_type;type:varchar(20);not null;index:idx_sync_attempts_ref,priority:1" json:"syncType"` // order, reward, distributor_reward, withdrawal
+	Attempt    int       `gorm:"column:attempt;not null;default:0" json:"attempt"`                                                  // 第几次尝试
+	SyncStatus string    `gorm:"column:sync_status;type:varchar(20);not null" json:"syncStatus"`                                    // 本次尝试后的状态
+	ErrorMsg   string    `gorm:"column:error_msg;type:text" json:"errorMsg"`
+	CreatedAt  time.Time `gorm:"column:created_at;not null;autoCreateTime" json:"createdAt"`
+}
+
+// TableName 表名
+func (m *SyncAttempt) TableName() string {
+	return "sync_attempts"
+}
+
+// SyncFieldMapping 外部同步字段映射，每个目标（订单/奖励/提现）一条启用的配置
+type SyncFieldMapping struct {
+	Id        int64     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	Target    string    `gorm:"column:target;type:varchar(20);not null;index" json:"target"` // order, reward, withdrawal
+	Mapping   string    `gorm:"column:mapping;type:text;not null" json:"mapping"`            // 映射配置 JSON（表名、列、取值转换）
+	Enabled   bool      `gorm:"column:enabled;not null;default:true" json:"enabled"`
+	Remark    string    `gorm:"column:remark;type:varchar(255)" json:"remark"`
+	CreatedAt time.Time `gorm:"column:created_at;not null;autoCreateTime" json:"createdAt"`
+	UpdatedAt time.Time `gorm:"column:updated_at;not null;autoUpdateTime" json:"updatedAt"`
+}
+
+// TableName 表名
+func (m *SyncFieldMapping) TableName() string {
+	return "sync_field_mappings"
+}
+
+// OutboxEvent 外部同步发件箱事件，与业务数据在同一事务内写入，由中继投递到同步队列
+type OutboxEvent struct {
+	Id            int64      `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	EventType     string     `gorm:"column:event_type;type:varchar(50);not null" json:"eventType"`                                    // order.paid, order.refunded, reward.settled, withdrawal.changed
+	AggregateType string     `gorm:"column:aggregate_type;type:varchar(30);not null;index:idx_outbox_aggregate" json:"aggregateType"` // order, distributor_reward, withdrawal
+	AggregateId   int64      `gorm:"column:aggregate_id;not null;index:idx_outbox_aggregate" json:"aggregateId"`
+	Status        string     `gorm:"column:status;type:varchar(20);not null;default:pending;index:idx_outbox_status_next" json:"status"` // pending, delivered
+	Attempts      int        `gorm:"column:attempts;not null;default:0" json:"attempts"`
+	LastError     string     `gorm:"column:last_error;type:varchar(500)" json:"lastError"`
+	NextAttemptAt time.Time  `gorm:"column:next_attempt_at;not null;index:idx_outbox_status_next" json:"nextAttemptAt"`
+	DeliveredAt   *time.Time `gorm:"column:delivered_at" json:"deliveredAt"`
+	CreatedAt     time.Time  `gorm:"column:created_at;not null;autoCreateTime" json:"createdAt"`
+}
+
+// TableName 表名
+func (o *OutboxEvent) TableName() string {
+	return "outbox_events"
+}
+
+// SyncBackfillCheckpoint 外部同步历史回填的检查点，按回填名称与目标（订单/奖励）记录进度
+type SyncBackfillCheckpoint struct {
+	Id         int64      `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	Name       string     `gorm:"column:name;type:varchar(64);not null;uniqueIndex:uk_backfill_name_target" json:"name"`
+	Target     string     `gorm:"column:target;type:varchar(20);not null;uniqueIndex:uk_backfill_name_target" json:"target"` // order, reward
+	Filter     string     `gorm:"column:filter;type:varchar(500);not null;default:''" json:"filter"`                         // 筛选条件 JSON，续跑时需一致
+	LastId     int64      `gorm:"column:last_id;not null;default:0" json:"lastId"`                                           // 已处理到的源记录ID
+	Synced     int64      `gorm:"column:synced;not null;default:0" json:"synced"`
+	Failed     int64      `gorm:"column:failed;not null;default:0" json:"failed"`
+	Status     string     `gorm:"column:status;type:varchar(20);not null;default:running" json:"status"` // running, paused, completed
+	LastError  string     `gorm:"column:last_error;type:varchar(500)" json:"lastError"`
+	LeaseOwner string     `gorm:"column:lease_owner;type:varchar(100);not null;default:''" json:"leaseOwner"` // 持有执行租约的实例
+	LeaseUntil *time.Time `gorm:"column:lease_until" json:"leaseUntil"`                                       // 租约到期时间，到期前其他实例不能执行同一回填
+	FinishedAt *time.Time `gorm:"column:finished_at" json:"finishedAt"`
+	CreatedAt  time.Time  `gorm:"column:created_at;not null;autoCreateTime" json:"createdAt"`
+	UpdatedAt  time.Time  `gorm:"column:updated_at;not null;autoUpdateTime" json:"updatedAt"`
+}
+
+// TableName 表名
+func (c *SyncBackfillCheckpoint) TableName() string {
+	return "sync_backfill_checkpoints"
+}
+
+// SyncInboundChange 从外部数据库导入的一条变更及其处理结果，按来源表与行键去重
+type SyncInboundChange struct {
+	Id                int64      `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	SourceTable       string     `gorm:"column:source_table;type:varchar(64);not null;uniqueIndex:uk_inbound_source_row" json:"sourceTable"`
+	RowKey            string     `gorm:"column:row_key;type:varchar(64);not null;uniqueIndex:uk_inbound_source_row" json:"rowKey"` // 变更行的唯一键，未配置行键列时为水位
+	Watermark         string     `gorm:"column:watermark;type:varchar(64);not null" json:"watermark"`
+	ChangeType        string     `gorm:"column:change_type;type:varchar(32);not null" json:"changeType"` // order_refunded, order_verified, order_unverified, member_updated
+	OrderId           int64      `gorm:"column:order_id;not null;default:0;index" json:"orderId"`
+	MemberId          int64      `gorm:"column:member_id;not null;default:0;index" json:"memberId"`
+	Payload           string     `gorm:"column:payload;type:text" json:"payload"`                     // 外部变更行原始内容 JSON
+	ExternalChangedAt *time.Time `gorm:"column:external_changed_at" json:"externalChangedAt"`         // 外部系统的变更时间
+	Status            string     `gorm:"column:status;type:varchar(20);not null;index" json:"status"` // applied, skipped, conflict, rejected
+	Detail            string     `gorm:"column:detail;type:varchar(500)" json:"detail"`               // 处理说明，如冲突或拒绝原因
+	CreatedAt         time.Time  `gorm:"column:created_at;not null;autoCreateTime" json:"createdAt"`
+}
+
+// TableName 表名
+func (c *SyncInboundChange) TableName() string {
+	return "sync_inbound_changes"
+}
+
+// SyncInboundCursor 外部变更表的导入水位
+type SyncInboundCursor struct {
+	Id          int64     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	SourceTable string    `gorm:"column:source_table;type:varchar(64);not null;uniqueIndex" json:"sourceTable"`
+	Watermark   string    `gorm:"column:watermark;type:varchar(64);not null;default:''" json:"watermark"` // 已处理到的水位
+	RowKey      string    `gorm:"column:row_key;type:varchar(64);not null;default:''" json:"rowKey"`      // 已处理到的行键，与水位组成复合游标
+	UpdatedAt   time.Time `gorm:"column:updated_at;not null;autoUpdateTime" json:"updatedAt"`
+}
+
+// TableName 表名
+func (c *SyncInboundCursor) TableName() string {
+	return "sync_inbound_cursors"
+}
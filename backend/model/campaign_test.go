@@ -0,0 +1,86 @@
+package model
+
+import "testing"
+
+func TestParseCampaignPaymentConfig(t *testing.T) {
+	cases := []struct {
+		name  string
+		raw   string
+		price float64
+	}{
+		{"empty", "", 0},
+		{"free", `{"requirePayment":false}`, 0},
+		{"payment amount", `{"requirePayment":true,"paymentType":"deposit","paymentAmount":99.9}`, 99.9},
+		{"legacy deposit", `{"paymentType":"deposit","depositAmount":50,"fullAmount":200}`, 50},
+		{"legacy full", `{"paymentType":"full","depositAmount":50,"fullAmount":200}`, 200},
+	}
+
+	for _, tc := range cases {
+		raw := tc.raw
+		config, err := ParseCampaignPaymentConfig(&raw)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.name, err)
+		}
+		if got := config.Price(); got != tc.price {
+			t.Fatalf("%s: price mismatch: got %v want %v", tc.name, got, tc.price)
+		}
+	}
+
+	for _, raw := range []string{`{invalid`, `{"paymentAmount":-1}`} {
+		if _, err := ParseCampaignPaymentConfig(&raw); err == nil {
+			t.Fatalf("expected error for %s", raw)
+		}
+	}
+}
+
+func TestOrderRedemptions(t *testing.T) {
+	order := Order{RedemptionQuota: 3, VerificationStatus: "unverified"}
+	if got := order.RemainingRedemptions(); got != 3 {
+		t.Fatalf("remaining: got %d want 3", got)
+	}
+
+	cases := map[int]string{0: "cancelled", 1: "partial", 2: "partial", 3: "verified"}
+	for redeemed, want := range cases {
+		if got := order.RedemptionStatus(redeemed); got != want {
+			t.Fatalf("status of %d redemptions: got %s want %s", redeemed, got, want)
+		}
+	}
+
+	order.RedeemedCount, order.VerificationStatus = 2, "partial"
+	if got := order.RemainingRedemptions(); got != 1 {
+		t.Fatalf("remaining: got %d want 1", got)
+	}
+
+	// 迁移前的订单次数为 0 时按单次核销处理
+	legacy := Order{VerificationStatus: "unverified"}
+	if got := legacy.RemainingRedemptions(); got != 1 {
+		t.Fatalf("legacy remaining: got %d want 1", got)
+	}
+	legacy.VerificationStatus = "verified"
+	if got := legacy.RemainingRedemptions(); got != 0 {
+		t.Fatalf("verified remaining: got %d want 0", got)
+	}
+}
+
+func TestVerificationScopeMatches(t *testing.T) {
+	campaign := &Campaign{Id: 7, BrandId: 1, Venue: "徐汇店"}
+
+	cases := []struct {
+		name  string
+		scope VerificationScope
+		want  bool
+	}{
+		{"whole brand", VerificationScope{BrandId: 1}, true},
+		{"campaign", VerificationScope{BrandId: 1, CampaignId: 7}, true},
+		{"other campaign", VerificationScope{BrandId: 1, CampaignId: 8}, false},
+		{"venue", VerificationScope{BrandId: 1, Venue: "徐汇店"}, true},
+		{"other venue", VerificationScope{BrandId: 1, Venue: "静安店"}, false},
+		{"campaign and venue", VerificationScope{BrandId: 1, CampaignId: 7, Venue: "静安店"}, false},
+		{"other brand", VerificationScope{BrandId: 2}, false},
+	}
+	for _, tc := range cases {
+		if got := tc.scope.Matches(campaign); got != tc.want {
+			t.Fatalf("%s: got %v want %v", tc.name, got, tc.want)
+		}
+	}
+}
@@ -77,12 +77,12 @@ type DistributorReward struct {
 	Id            int64      `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
 	DistributorId int64      `gorm:"column:distributor_id;not null;index" json:"distributorId"`
 	UserId        int64      `gorm:"column:user_id;not null;index" json:"userId"`
-	OrderId       int64      `gorm:"column:order_id;not null;index" json:"orderId"`
+	OrderId       int64      `gorm:"column:order_id;not null;index;uniqueIndex:uk_distributor_rewards_order_level,priority:1" json:"orderId"`
 	CampaignId    int64      `gorm:"column:campaign_id;not null;index" json:"campaignId"`
 	Amount        float64    `gorm:"column:amount;type:decimal(10,2);not null;default:0.00" json:"amount"`
-	Level         int        `gorm:"column:level;not null" json:"level"`                              // 奖励级别 1/2/3
-	RewardRate    float64    `gorm:"column:reward_rate;type:decimal(5,2);not null" json:"rewardRate"` // 奖励比例
-	FromUserId    *int64     `gorm:"column:from_user_id" json:"fromUserId"`                           // 购买用户ID
+	Level         int        `gorm:"column:level;not null;uniqueIndex:uk_distributor_rewards_order_level,priority:2" json:"level"` // 奖励级别 1/2/3，同一订单每级只结算一次
+	RewardRate    float64    `gorm:"column:reward_rate;type:decimal(5,2);not null" json:"rewardRate"`                              // 奖励比例
+	FromUserId    *int64     `gorm:"column:from_user_id" json:"fromUserId"`                                                        // 购买用户ID
 	Status        string     `gorm:"column:status;type:varchar(20);not null;default:settled;index" json:"status"`
 	SettledAt     *time.Time `gorm:"column:settled_at" json:"settledAt"`
 	CreatedAt     time.Time  `gorm:"column:created_at;not null;autoCreateTime" json:"createdAt"`
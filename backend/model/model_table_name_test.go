@@ -12,6 +12,18 @@ func TestTableNames(t *testing.T) {
 		{"Role", Role{}.TableName(), "roles"},
 		{"Campaign", (&Campaign{}).TableName(), "campaigns"},
 		{"Order", (&Order{}).TableName(), "orders"},
+		{"OrderRefund", (&OrderRefund{}).TableName(), "order_refunds"},
+		{"PaymentNotification", (&PaymentNotification{}).TableName(), "payment_notifications"},
+		{"PaymentReconciliation", (&PaymentReconciliation{}).TableName(), "payment_reconciliations"},
+		{"PaymentReconciliationItem", (&PaymentReconciliationItem{}).TableName(), "payment_reconciliation_items"},
+		{"SyncFieldMapping", (&SyncFieldMapping{}).TableName(), "sync_field_mappings"},
+		{"OutboxEvent", (&OutboxEvent{}).TableName(), "outbox_events"},
+		{"SyncBackfillCheckpoint", (&SyncBackfillCheckpoint{}).TableName(), "sync_backfill_checkpoints"},
+		{"SyncInboundChange", (&SyncInboundChange{}).TableName(), "sync_inbound_changes"},
+		{"SyncInboundCursor", (&SyncInboundCursor{}).TableName(), "sync_inbound_cursors"},
+		{"WebhookEndpoint", WebhookEndpoint{}.TableName(), "webhook_endpoints"},
+		{"WebhookDelivery", WebhookDelivery{}.TableName(), "webhook_deliveries"},
+		{"BalanceTransaction", (&BalanceTransaction{}).TableName(), "balance_transactions"},
 		{"Reward", (&Reward{}).TableName(), "rewards"},
 		{"Distributor", Distributor{}.TableName(), "distributors"},
 		{"Member", Member{}.TableName(), "members"},
@@ -19,6 +31,7 @@ func TestTableNames(t *testing.T) {
 		{"UserFeedback", UserFeedback{}.TableName(), "user_feedback"},
 		{"PasswordPolicy", PasswordPolicy{}.TableName(), "password_policies"},
 		{"VerificationRecord", VerificationRecord{}.TableName(), "verification_records"},
+		{"VerificationScope", VerificationScope{}.TableName(), "verification_scopes"},
 	}
 
 	for _, tc := range cases {
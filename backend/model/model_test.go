@@ -23,7 +23,7 @@ func setupModelTestDB(t *testing.T) *gorm.DB {
 	err = db.AutoMigrate(
 		&User{}, &Role{}, &UserRole{}, &Permission{}, &RolePermission{},
 		&Brand{}, &UserBrand{}, &BrandAsset{},
-		&Campaign{}, &Order{}, &Reward{}, &UserBalance{}, &SyncLog{},
+		&Campaign{}, &Order{}, &Reward{}, &UserBalance{}, &SyncLog{}, &SyncAttempt{},
 		&Distributor{}, &DistributorApplication{}, &DistributorLevelReward{}, &DistributorReward{}, &DistributorLink{},
 		&Menu{}, &RoleMenu{},
 		&Withdrawal{},
@@ -355,6 +355,7 @@ func TestAllTableNames(t *testing.T) {
 		{"Reward", (&Reward{}).TableName(), "rewards"},
 		{"UserBalance", (&UserBalance{}).TableName(), "user_balances"},
 		{"SyncLog", (&SyncLog{}).TableName(), "sync_logs"},
+		{"SyncAttempt", (&SyncAttempt{}).TableName(), "sync_attempts"},
 		{"Distributor", Distributor{}.TableName(), "distributors"},
 		{"DistributorApplication", DistributorApplication{}.TableName(), "distributor_applications"},
 		{"DistributorLevelReward", DistributorLevelReward{}.TableName(), "distributor_level_rewards"},
@@ -10,8 +10,12 @@ type VerificationRecord struct {
 	VerifiedAt         *time.Time `gorm:"column:verified_at" json:"verifiedAt"`
 	VerifiedBy         *int64     `gorm:"column:verified_by;index" json:"verifiedBy"`
 	VerificationCode   string     `gorm:"column:verification_code;type:varchar(128)" json:"verificationCode"`
-	VerificationMethod string     `gorm:"column:verification_method;type:varchar(20);not null;default:manual" json:"verificationMethod"` // manual/auto/qrcode
+	VerificationMethod string     `gorm:"column:verification_method;type:varchar(20);not null;default:manual" json:"verificationMethod"` // manual/auto/qrcode/offline
 	Remark             string     `gorm:"column:remark;type:varchar(500)" json:"remark"`
+	RedemptionNo       int        `gorm:"column:redemption_no;not null;default:0" json:"redemptionNo"`                                         // 第几次核销，取消核销时为被撤销的那次
+	BrandID            *int64     `gorm:"column:brand_id;uniqueIndex:idx_device_ref,priority:1" json:"brandId,omitempty"`                      // 离线核销订单所属品牌，去重键按品牌隔离，在线核销为 NULL
+	DeviceID           *string    `gorm:"column:device_id;type:varchar(64);uniqueIndex:idx_device_ref,priority:2" json:"deviceId,omitempty"`   // 离线核销设备，在线核销为 NULL
+	ClientRef          *string    `gorm:"column:client_ref;type:varchar(64);uniqueIndex:idx_device_ref,priority:3" json:"clientRef,omitempty"` // 设备端核销ID，重复上传时去重
 	CreatedAt          time.Time  `gorm:"column:created_at;not null;autoCreateTime;index" json:"createdAt"`
 	UpdatedAt          time.Time  `gorm:"column:updated_at;not null;autoUpdateTime" json:"updatedAt"`
 }
@@ -103,6 +103,32 @@ func (UserBrand) TableName() string {
 	return "user_brands"
 }
 
+// VerificationScope 核销人员授权范围；用户在品牌下有记录时只能核销匹配任一记录的订单
+type VerificationScope struct {
+	Id         int64     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	UserId     int64     `gorm:"column:user_id;not null;index:idx_scope_user_brand,priority:1" json:"userId"`
+	BrandId    int64     `gorm:"column:brand_id;not null;index:idx_scope_user_brand,priority:2" json:"brandId"`
+	CampaignId int64     `gorm:"column:campaign_id;not null;default:0" json:"campaignId"`         // 0 表示不限活动
+	Venue      string    `gorm:"column:venue;type:varchar(100);not null;default:''" json:"venue"` // 为空表示不限场地
+	CreatedBy  int64     `gorm:"column:created_by;not null;default:0" json:"createdBy"`
+	CreatedAt  time.Time `gorm:"column:created_at;not null;autoCreateTime" json:"createdAt"`
+}
+
+func (VerificationScope) TableName() string {
+	return "verification_scopes"
+}
+
+// Matches 活动是否在该授权范围内
+func (s *VerificationScope) Matches(campaign *Campaign) bool {
+	if s.BrandId != campaign.BrandId {
+		return false
+	}
+	if s.CampaignId != 0 && s.CampaignId != campaign.Id {
+		return false
+	}
+	return s.Venue == "" || s.Venue == campaign.Venue
+}
+
 // Withdrawal 提现申请表
 type Withdrawal struct {
 	ID             int64      `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
@@ -120,6 +146,9 @@ type Withdrawal struct {
 	RejectedReason string     `gorm:"column:rejected_reason;type:text" json:"rejectedReason"`
 	PaidAt         *time.Time `gorm:"column:paid_at" json:"paidAt"`
 	TradeNo        string     `gorm:"column:trade_no;type:varchar(100)" json:"tradeNo"`
+	PayoutAttempts int        `gorm:"column:payout_attempts;not null;default:0" json:"payoutAttempts"` // 打款尝试次数
+	NextPayoutAt   *time.Time `gorm:"column:next_payout_at;index" json:"nextPayoutAt,omitempty"`       // 下次打款时间（重试或处理租约到期）
+	PayoutError    string     `gorm:"column:payout_error;type:varchar(500)" json:"payoutError"`        // 最近一次打款失败原因
 	CreatedAt      time.Time  `gorm:"column:created_at;not null;autoCreateTime;index" json:"createdAt"`
 	UpdatedAt      time.Time  `gorm:"column:updated_at;not null;autoUpdateTime" json:"updatedAt"`
 
@@ -0,0 +1,46 @@
+package model
+
+import "time"
+
+// WebhookEndpoint 品牌注册的 Webhook 接收地址
+type WebhookEndpoint struct {
+	Id          int64     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	BrandId     int64     `gorm:"column:brand_id;not null;index" json:"brandId"`
+	Url         string    `gorm:"column:url;type:varchar(500);not null" json:"url"`
+	Secret      string    `gorm:"column:secret;type:varchar(100);not null" json:"-"`                          // 签名密钥
+	Events      string    `gorm:"column:events;type:varchar(500);not null" json:"events"`                     // 订阅的事件类型，逗号分隔
+	Status      string    `gorm:"column:status;type:varchar(20);not null;default:active;index" json:"status"` // active/disabled
+	Description string    `gorm:"column:description;type:varchar(200)" json:"description"`
+	CreatedBy   int64     `gorm:"column:created_by;not null;default:0" json:"createdBy"`
+	CreatedAt   time.Time `gorm:"column:created_at;not null;autoCreateTime" json:"createdAt"`
+	UpdatedAt   time.Time `gorm:"column:updated_at;not null;autoUpdateTime" json:"updatedAt"`
+}
+
+func (WebhookEndpoint) TableName() string {
+	return "webhook_endpoints"
+}
+
+// WebhookDelivery Webhook 投递记录，每个事件对每个订阅端点一条，手动重新投递时新增一条
+type WebhookDelivery struct {
+	Id             int64      `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	EndpointId     int64      `gorm:"column:endpoint_id;not null;index" json:"endpointId"`
+	BrandId        int64      `gorm:"column:brand_id;not null;index" json:"brandId"`
+	EventId        string     `gorm:"column:event_id;type:varchar(64);not null;index" json:"eventId"` // 同一事件投递到多个端点或重新投递时相同，接收方据此去重
+	EventType      string     `gorm:"column:event_type;type:varchar(50);not null" json:"eventType"`
+	Payload        string     `gorm:"column:payload;type:text;not null" json:"payload"`
+	Status         string     `gorm:"column:status;type:varchar(20);not null;default:pending;index:idx_webhook_delivery_status_next" json:"status"` // pending/delivered/failed
+	Attempts       int        `gorm:"column:attempts;not null;default:0" json:"attempts"`
+	NextAttemptAt  time.Time  `gorm:"column:next_attempt_at;not null;index:idx_webhook_delivery_status_next" json:"nextAttemptAt"`
+	ResponseStatus int        `gorm:"column:response_status;not null;default:0" json:"responseStatus"` // 最近一次响应的 HTTP 状态码
+	ResponseBody   string     `gorm:"column:response_body;type:varchar(500)" json:"responseBody"`      // 最近一次响应内容（截断）
+	LastError      string     `gorm:"column:last_error;type:varchar(500)" json:"lastError"`
+	DurationMs     int64      `gorm:"column:duration_ms;not null;default:0" json:"durationMs"`
+	RedeliveryOf   *int64     `gorm:"column:redelivery_of" json:"redeliveryOf,omitempty"` // 手动重新投递的原投递记录
+	DeliveredAt    *time.Time `gorm:"column:delivered_at" json:"deliveredAt,omitempty"`
+	CreatedAt      time.Time  `gorm:"column:created_at;not null;autoCreateTime;index" json:"createdAt"`
+	UpdatedAt      time.Time  `gorm:"column:updated_at;not null;autoUpdateTime" json:"updatedAt"`
+}
+
+func (WebhookDelivery) TableName() string {
+	return "webhook_deliveries"
+}